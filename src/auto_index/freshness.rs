@@ -0,0 +1,219 @@
+//! Index freshness checks.
+//!
+//! Compares the modification times recorded in the index against the files
+//! currently on disk. The check only walks the project and stats files, so it
+//! is cheap enough to run before every search.
+
+use std::collections::{HashMap, HashSet};
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::time::UNIX_EPOCH;
+
+use crate::indexer::archive::archive_of;
+
+/// Maximum number of paths listed in a staleness warning
+const MAX_LISTED_FILES: usize = 5;
+
+/// Differences between the index and the files on disk.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct FreshnessReport {
+    /// Indexed files modified after they were indexed
+    pub modified: Vec<PathBuf>,
+    /// Files on disk that are not in the index
+    pub added: Vec<PathBuf>,
+    /// Indexed files that no longer exist
+    pub removed: Vec<PathBuf>,
+}
+
+impl FreshnessReport {
+    /// Whether the index is out of date with the files on disk
+    pub fn is_stale(&self) -> bool {
+        !self.modified.is_empty() || !self.added.is_empty() || !self.removed.is_empty()
+    }
+
+    /// Total number of out-of-date files
+    pub fn stale_count(&self) -> usize {
+        self.modified.len() + self.added.len() + self.removed.len()
+    }
+
+    /// Human-readable warning describing the stale files, relative to `root`.
+    pub fn warning(&self, root: &Path) -> String {
+        let mut parts = Vec::new();
+        if !self.modified.is_empty() {
+            parts.push(format!("{} modified", self.modified.len()));
+        }
+        if !self.added.is_empty() {
+            parts.push(format!("{} new", self.added.len()));
+        }
+        if !self.removed.is_empty() {
+            parts.push(format!("{} deleted", self.removed.len()));
+        }
+
+        let mut message = format!(
+            "Warning: index may be stale ({} since last index)",
+            parts.join(", ")
+        );
+
+        let listed = self
+            .modified
+            .iter()
+            .chain(&self.added)
+            .chain(&self.removed)
+            .take(MAX_LISTED_FILES);
+        for path in listed {
+            let display = path.strip_prefix(root).unwrap_or(path);
+            message.push_str(&format!("\n  {}", display.display()));
+        }
+        if self.stale_count() > MAX_LISTED_FILES {
+            message.push_str(&format!(
+                "\n  ... and {} more",
+                self.stale_count() - MAX_LISTED_FILES
+            ));
+        }
+
+        message.push_str(
+            "\nRun `coderag index` to update it, or pass --auto-refresh to reindex before searching.",
+        );
+        message
+    }
+}
+
+/// Compare recorded mtimes against the current files.
+///
+/// `indexed` maps file paths to the mtime (Unix seconds) stored at index
+/// time; `current` is the list of files the walker would index now. Files
+/// indexed from a source archive count as removed only once the archive
+/// itself is gone.
+pub fn check_freshness(indexed: &HashMap<PathBuf, i64>, current: &[PathBuf]) -> FreshnessReport {
+    let mut report = FreshnessReport::default();
+    let current_set: HashSet<&PathBuf> = current.iter().collect();
+
+    for path in current {
+        match indexed.get(path) {
+            Some(&stored) => {
+                if file_mtime(path).map_or(false, |mtime| mtime > stored) {
+                    report.modified.push(path.clone());
+                }
+            }
+            None => report.added.push(path.clone()),
+        }
+    }
+
+    report.removed = indexed
+        .keys()
+        .filter(|path| !current_set.contains(path))
+        .filter(|path| archive_of(path).map_or(true, |archive| !archive.exists()))
+        .cloned()
+        .collect();
+
+    report.modified.sort();
+    report.added.sort();
+    report.removed.sort();
+    report
+}
+
+/// Modification time of a file as a Unix timestamp in seconds
+fn file_mtime(path: &Path) -> Option<i64> {
+    let modified = fs::metadata(path).ok()?.modified().ok()?;
+    modified
+        .duration_since(UNIX_EPOCH)
+        .ok()
+        .map(|d| d.as_secs() as i64)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::time::{Duration, SystemTime};
+    use tempfile::tempdir;
+
+    fn set_mtime(path: &Path, time: SystemTime) {
+        let file = fs::File::options().write(true).open(path).unwrap();
+        file.set_modified(time).unwrap();
+    }
+
+    #[test]
+    fn test_fresh_index_is_not_stale() {
+        let dir = tempdir().unwrap();
+        let file = dir.path().join("lib.rs");
+        fs::write(&file, "fn a() {}").unwrap();
+
+        let indexed = HashMap::from([(file.clone(), file_mtime(&file).unwrap())]);
+        let report = check_freshness(&indexed, &[file]);
+
+        assert!(!report.is_stale());
+        assert_eq!(report.stale_count(), 0);
+    }
+
+    #[test]
+    fn test_file_changed_after_indexing_triggers_warning() {
+        let dir = tempdir().unwrap();
+        let file = dir.path().join("lib.rs");
+        fs::write(&file, "fn a() {}").unwrap();
+        let indexed_at = SystemTime::now() - Duration::from_secs(60);
+        set_mtime(&file, indexed_at);
+
+        let indexed = HashMap::from([(file.clone(), file_mtime(&file).unwrap())]);
+
+        // Edit the file after it was indexed
+        fs::write(&file, "fn a() { changed() }").unwrap();
+        set_mtime(&file, SystemTime::now());
+
+        let report = check_freshness(&indexed, &[file.clone()]);
+        assert!(report.is_stale());
+        assert_eq!(report.modified, vec![file]);
+
+        let warning = report.warning(dir.path());
+        assert!(warning.contains("index may be stale"));
+        assert!(warning.contains("1 modified"));
+        assert!(warning.contains("lib.rs"));
+        assert!(warning.contains("coderag index"));
+    }
+
+    #[test]
+    fn test_added_and_removed_files() {
+        let dir = tempdir().unwrap();
+        let kept = dir.path().join("kept.rs");
+        let added = dir.path().join("added.rs");
+        let removed = dir.path().join("removed.rs");
+        fs::write(&kept, "").unwrap();
+        fs::write(&added, "").unwrap();
+
+        let indexed = HashMap::from([
+            (kept.clone(), file_mtime(&kept).unwrap()),
+            (removed.clone(), 0),
+        ]);
+        let report = check_freshness(&indexed, &[kept, added.clone()]);
+
+        assert!(report.modified.is_empty());
+        assert_eq!(report.added, vec![added]);
+        assert_eq!(report.removed, vec![removed]);
+        assert_eq!(report.stale_count(), 2);
+    }
+
+    #[test]
+    fn test_archive_members_are_not_removed_while_archive_exists() {
+        let dir = tempdir().unwrap();
+        let archive = dir.path().join("bundle.zip");
+        fs::write(&archive, "").unwrap();
+        let member = PathBuf::from(format!("{}!/src/lib.rs", archive.display()));
+        let gone = PathBuf::from(format!("{}!/lib.rs", dir.path().join("gone.zip").display()));
+
+        let indexed = HashMap::from([(member, 0), (gone.clone(), 0)]);
+        let report = check_freshness(&indexed, &[]);
+        assert_eq!(report.removed, vec![gone]);
+    }
+
+    #[test]
+    fn test_warning_truncates_long_lists() {
+        let root = Path::new("/project");
+        let report = FreshnessReport {
+            modified: (0..8).map(|i| root.join(format!("f{}.rs", i))).collect(),
+            ..Default::default()
+        };
+        let warning = report.warning(root);
+        assert!(warning.contains("f4.rs"));
+        assert!(!warning.contains("f5.rs"));
+        assert!(warning.contains("and 3 more"));
+    }
+}
@@ -36,9 +36,11 @@
 //! - **Local**: `{project}/.coderag/index.lance` - Used when project has `.coderag/` directory
 //! - **Global**: `~/.local/share/coderag/indexes/{project-id}/index.lance` - Used for all other projects
 
+mod freshness;
 mod service;
 mod storage_resolver;
 
+pub use freshness::{check_freshness, FreshnessReport};
 pub use service::{AutoIndexError, AutoIndexPolicy, AutoIndexResult, AutoIndexService};
 pub use storage_resolver::{
     compute_project_id, sanitize_name, StorageError, StorageLocation, StorageResolver,
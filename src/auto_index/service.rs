@@ -4,6 +4,7 @@
 //! users to run `coderag search "query"` from any project directory without
 //! explicit initialization.
 
+use anyhow::Context;
 use std::path::Path;
 use std::time::Instant;
 use thiserror::Error;
@@ -11,12 +12,14 @@ use tracing::{debug, info, warn};
 
 use crate::config::Config;
 use crate::embeddings::EmbeddingGenerator;
-use crate::indexer::Walker;
-use crate::indexing::ParallelIndexer;
+use crate::indexer::{archive, git_ref, Walker};
+use crate::indexing::{FileContent, ParallelIndexer};
 use crate::project_detection::{DetectedProject, DetectionError, ProjectDetector};
 use crate::search::bm25::Bm25Search;
-use crate::storage::Storage;
+use crate::storage::{open_configured_store, open_existing_store, IndexedChunk, VectorStore};
+use crate::symbol::{CounterpartMatch, SymbolGraph};
 
+use super::freshness::{check_freshness, FreshnessReport};
 use super::storage_resolver::{StorageError, StorageLocation, StorageResolver};
 
 /// Errors during auto-indexing.
@@ -187,12 +190,77 @@ impl AutoIndexService {
         Ok(storage)
     }
 
+    /// Configuration of the project containing `cwd`, which selects the
+    /// storage backend its index is kept in.
+    pub fn project_config(&self, cwd: &Path) -> Result<Config, AutoIndexError> {
+        let project = self.detector.detect(cwd)?;
+        self.load_config(&project)
+    }
+
+    /// Compare the index for the project containing `cwd` against the files
+    /// on disk.
+    ///
+    /// Returns `None` when no index exists yet. Only file metadata is read,
+    /// so this is cheap compared to indexing.
+    pub async fn check_freshness(
+        &self,
+        cwd: &Path,
+    ) -> Result<Option<FreshnessReport>, AutoIndexError> {
+        let project = self.detector.detect(cwd)?;
+        let storage = StorageResolver::resolve(&project)?;
+        let config = self.load_config(&project)?;
+        self.freshness_report(&storage, &project, &config).await
+    }
+
+    /// Whether anything has been indexed for the project containing `cwd`,
+    /// in the store its configuration selects.
+    pub async fn index_exists(&self, cwd: &Path) -> Result<bool, AutoIndexError> {
+        let project = self.detector.detect(cwd)?;
+        let storage = StorageResolver::resolve(&project)?;
+        let config = self.load_config(&project)?;
+        let db = open_existing_store(&config.storage, storage.db_path()).await?;
+        Ok(db.is_some())
+    }
+
+    /// Delete everything indexed for the project containing `cwd`.
+    ///
+    /// Returns whether there was an index to clear.
+    pub async fn clear_index(&self, cwd: &Path) -> Result<bool, AutoIndexError> {
+        let project = self.detector.detect(cwd)?;
+        let storage = StorageResolver::resolve(&project)?;
+        let config = self.load_config(&project)?;
+        // Open with the stored dimension since we're just clearing
+        let Some(db) = open_existing_store(&config.storage, storage.db_path()).await? else {
+            return Ok(false);
+        };
+        db.clear().await?;
+        Ok(true)
+    }
+
+    /// Compare the index with the project files; None when nothing has been
+    /// indexed yet
+    async fn freshness_report(
+        &self,
+        storage: &StorageLocation,
+        project: &DetectedProject,
+        config: &Config,
+    ) -> Result<Option<FreshnessReport>, AutoIndexError> {
+        // Open with the stored dimension: the index may have been built with
+        // a model other than the default
+        let Some(db) = open_existing_store(&config.storage, storage.db_path()).await? else {
+            return Ok(None);
+        };
+        let indexed = db.file_mtimes().await?;
+        let files = Walker::new(project.root.clone(), &config.indexer).collect_files();
+        Ok(Some(check_freshness(&indexed, &files)))
+    }
+
     /// Check if indexing is needed based on policy.
     async fn needs_indexing(
         &self,
         storage: &StorageLocation,
-        _project: &DetectedProject,
-        _config: &Config,
+        project: &DetectedProject,
+        config: &Config,
     ) -> Result<bool, AutoIndexError> {
         match self.policy {
             AutoIndexPolicy::Never => {
@@ -200,22 +268,25 @@ impl AutoIndexService {
                 Ok(false)
             }
             AutoIndexPolicy::OnMissing => {
-                let exists = storage.index_exists();
+                // Server backends keep nothing at the database path, so ask
+                // the configured store
+                let exists = open_existing_store(&config.storage, storage.db_path())
+                    .await?
+                    .is_some();
                 debug!("Policy is OnMissing, index exists: {}", exists);
                 Ok(!exists)
             }
             AutoIndexPolicy::OnMissingOrStale => {
-                if !storage.index_exists() {
+                let Some(report) = self.freshness_report(storage, project, config).await? else {
                     debug!("Index does not exist, needs indexing");
                     return Ok(true);
-                }
+                };
 
-                // TODO: Implement mtime-based staleness check
-                // For now, if index exists, we assume it's up to date.
-                // The parallel indexer will still do incremental updates
-                // based on file mtimes when indexing is performed.
-                debug!("Index exists, assuming up to date (mtime check not yet implemented)");
-                Ok(false)
+                debug!(
+                    "Policy is OnMissingOrStale, {} stale files",
+                    report.stale_count()
+                );
+                Ok(report.is_stale())
             }
         }
     }
@@ -255,8 +326,13 @@ impl AutoIndexService {
         let vector_dimension = embedder.embedding_dimension();
 
         // Create storage and check for existing index
-        let db = Storage::new(storage.db_path(), vector_dimension).await?;
-        let existing_mtimes = db.get_file_mtimes().await?;
+        let db = open_configured_store(
+            &config.storage,
+            &embedder.index_path(storage.db_path()),
+            Some(vector_dimension),
+        )
+        .await?;
+        let existing_mtimes = db.file_mtimes().await?;
         let was_incremental = !existing_mtimes.is_empty();
 
         if was_incremental {
@@ -272,6 +348,23 @@ impl AutoIndexService {
         let walker = Walker::new(project.root.clone(), &config.indexer);
         let files: Vec<_> = walker.collect_files();
 
+        // Record the other paths of symlink-deduplicated files
+        if let Some(dir) = storage.storage_dir() {
+            if let Err(e) = walker.symlink_aliases().save(dir) {
+                warn!("Failed to save symlink aliases: {}", e);
+            }
+        }
+
+        // Drop files that were deleted since the last run so the index
+        // converges with the working tree
+        let removed = check_freshness(&existing_mtimes, &files).removed;
+        for path in &removed {
+            db.delete_file(path).await?;
+        }
+        if !removed.is_empty() {
+            debug!("Removed {} deleted files from index", removed.len());
+        }
+
         if files.is_empty() {
             info!("No files found to index");
             return Ok(AutoIndexResult {
@@ -293,10 +386,13 @@ impl AutoIndexService {
         ).await?;
         let result = indexer.index_files(files).await?;
 
-        // Build BM25 index for hybrid search
-        if result.chunks_created > 0 {
+        // Build BM25 index for hybrid search, and the symbol graph
+        if result.chunks_created > 0 || !removed.is_empty() {
             debug!("Building BM25 index...");
-            if let Err(e) = self.build_bm25_index(&db, storage).await {
+            if let Err(e) = self
+                .build_derived_indexes(db.as_ref(), storage, config.indexer.counterparts)
+                .await
+            {
                 warn!("Failed to build BM25 index: {}", e);
                 // Continue without BM25 - vector search will still work
             }
@@ -320,14 +416,184 @@ impl AutoIndexService {
         })
     }
 
-    /// Build the BM25 index from all chunks in storage.
-    async fn build_bm25_index(
+    /// Index a source archive into the index of the project containing `cwd`.
+    ///
+    /// Files are indexed under `<archive>!/<path>` member paths, replacing
+    /// anything previously indexed from the same archive.
+    pub async fn index_archive(
+        &self,
+        cwd: &Path,
+        archive_path: &Path,
+    ) -> Result<AutoIndexResult, AutoIndexError> {
+        let start = Instant::now();
+        let project = self.detector.detect(cwd)?;
+        let storage = StorageResolver::resolve(&project)?;
+        let config = self.load_config(&project)?;
+
+        let archive_path = archive_path
+            .canonicalize()
+            .with_context(|| format!("Archive not found: {:?}", archive_path))?;
+        let mtime = std::fs::metadata(&archive_path)
+            .and_then(|m| m.modified())
+            .ok()
+            .and_then(|t| t.duration_since(std::time::UNIX_EPOCH).ok())
+            .map_or(0, |d| d.as_secs() as i64);
+
+        let files = archive::read_archive(&archive_path, &config.indexer)?;
+        info!(
+            "Read {} files from archive {:?}",
+            files.len(),
+            archive_path
+        );
+
+        // Replace chunks from an earlier run over the same archive
+        let embedder = EmbeddingGenerator::new_async(&config.embeddings).await?;
+        let db = open_configured_store(
+            &config.storage,
+            &embedder.index_path(storage.db_path()),
+            Some(embedder.embedding_dimension()),
+        )
+        .await?;
+        let existing = db.file_mtimes().await?;
+        let was_incremental = !existing.is_empty();
+        for path in existing.keys() {
+            if archive::archive_of(path).as_deref() == Some(archive_path.as_path()) {
+                db.delete_file(path).await?;
+            }
+        }
+
+        let contents = files
+            .into_iter()
+            .map(|file| FileContent {
+                path: file.path,
+                content: file.content,
+                mtime,
+            })
+            .collect();
+
+        let indexer = ParallelIndexer::with_storage_path(
+            project.root.clone(),
+            config.clone(),
+            Some(storage.db_path().to_path_buf()),
+        )
+        .await?;
+        let result = indexer.index_contents(contents).await?;
+
+        if let Err(e) = self
+            .build_derived_indexes(db.as_ref(), &storage, config.indexer.counterparts)
+            .await
+        {
+            warn!("Failed to build BM25 index: {}", e);
+        }
+
+        Ok(AutoIndexResult {
+            storage,
+            files_indexed: result.files_processed,
+            chunks_created: result.chunks_created,
+            was_incremental,
+            duration_secs: start.elapsed().as_secs_f64(),
+        })
+    }
+
+    /// Index the tree of a git ref into the index of the project containing
+    /// `cwd`.
+    ///
+    /// Files are indexed under `<ref>:<path>` and tagged with the ref name,
+    /// replacing anything previously indexed from the same ref. Chunks whose
+    /// content is already stored reuse its embedding.
+    pub async fn index_ref(
         &self,
-        storage: &Storage,
+        cwd: &Path,
+        name: &str,
+    ) -> Result<AutoIndexResult, AutoIndexError> {
+        let start = Instant::now();
+        let project = self.detector.detect(cwd)?;
+        let storage = StorageResolver::resolve(&project)?;
+        let config = self.load_config(&project)?;
+
+        let git_ref = git_ref::resolve(&project.root, name)?;
+        let files = git_ref::read_ref(&project.root, &git_ref, &config.indexer)?;
+        info!(
+            "Read {} files from ref {} ({})",
+            files.len(),
+            git_ref.name,
+            git_ref.commit
+        );
+
+        let embedder = EmbeddingGenerator::new_async(&config.embeddings).await?;
+        let db = open_configured_store(
+            &config.storage,
+            &embedder.index_path(storage.db_path()),
+            Some(embedder.embedding_dimension()),
+        )
+        .await?;
+        let was_incremental = db.stats().await?.chunks > 0;
+        db.delete_branch(&git_ref.name).await?;
+
+        let contents = files
+            .into_iter()
+            .map(|file| FileContent {
+                path: file.path,
+                content: file.content,
+                mtime: git_ref.timestamp,
+            })
+            .collect();
+
+        let indexer = ParallelIndexer::with_storage_path(
+            project.root.clone(),
+            config.clone(),
+            Some(storage.db_path().to_path_buf()),
+        )
+        .await?;
+        let result = indexer
+            .index_contents_at_ref(contents, &git_ref.name)
+            .await?;
+
+        if let Err(e) = self
+            .build_derived_indexes(db.as_ref(), &storage, config.indexer.counterparts)
+            .await
+        {
+            warn!("Failed to build BM25 index: {}", e);
+        }
+
+        Ok(AutoIndexResult {
+            storage,
+            files_indexed: result.files_processed,
+            chunks_created: result.chunks_created,
+            was_incremental,
+            duration_secs: start.elapsed().as_secs_f64(),
+        })
+    }
+
+    /// Rebuild the indexes derived from all chunks in storage: the BM25
+    /// index and the symbol graph, with exported types linked across
+    /// languages under `counterparts`.
+    pub async fn build_derived_indexes(
+        &self,
+        storage: &dyn VectorStore,
         location: &StorageLocation,
+        counterparts: CounterpartMatch,
     ) -> Result<(), AutoIndexError> {
-        let chunks = storage.get_all_chunks().await?;
+        let chunks = storage.all_chunks().await?;
+
+        if let Some(dir) = location.storage_dir() {
+            let mut graph = SymbolGraph::build(&chunks);
+            graph.link_counterparts(counterparts);
+            debug!("Built symbol graph with {} edges", graph.len());
+            if let Err(e) = graph.save(dir) {
+                warn!("Failed to save symbol graph: {}", e);
+            }
+        }
+
+        self.build_bm25_index(&chunks, location)
+    }
 
+    /// Build the BM25 index from the given chunks.
+    fn build_bm25_index(
+        &self,
+        chunks: &[IndexedChunk],
+        location: &StorageLocation,
+    ) -> Result<(), AutoIndexError> {
         if chunks.is_empty() {
             debug!("No chunks to index in BM25");
             return Ok(());
@@ -343,7 +609,7 @@ impl AutoIndexService {
         {
             let mut index = bm25.index_mut();
             index.clear()?;
-            index.add_chunks(&chunks)?;
+            index.add_chunks(chunks)?;
             index.commit()?;
         }
 
@@ -361,6 +627,7 @@ impl Default for AutoIndexService {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::storage::Storage;
     use tempfile::tempdir;
 
     #[test]
@@ -416,4 +683,50 @@ mod tests {
         assert!(result.is_err());
         assert!(matches!(result, Err(AutoIndexError::Detection(_))));
     }
+
+    #[tokio::test]
+    async fn test_check_freshness_with_non_default_dimension() {
+        let dir = tempdir().unwrap();
+        std::fs::create_dir(dir.path().join(".coderag")).unwrap();
+        std::fs::write(dir.path().join(".coderag").join("config.toml"), "").unwrap();
+        std::fs::write(dir.path().join("main.rs"), "fn main() {}\n").unwrap();
+
+        let service = AutoIndexService::new();
+        let location = service.resolve_storage(dir.path()).unwrap();
+        let files =
+            Walker::new(dir.path().to_path_buf(), &Config::default().indexer).collect_files();
+        assert!(!files.is_empty());
+
+        // Index every file with a 768-dim vector and an mtime in the future
+        let storage = Storage::new(location.db_path(), 768).await.unwrap();
+        let chunks = files
+            .iter()
+            .enumerate()
+            .map(|(i, path)| IndexedChunk {
+                id: format!("chunk{}", i),
+                content: "fn main() {}".to_string(),
+                file_path: path.to_string_lossy().to_string(),
+                start_line: 1,
+                end_line: 1,
+                language: Some("rust".to_string()),
+                vector: vec![0.1; 768],
+                mtime: i64::MAX,
+                file_header: None,
+                semantic_kind: None,
+                symbol_name: None,
+                signature: None,
+                doc: None,
+                parent: None,
+                visibility: None,
+                qualified_name: None,
+                tags: Vec::new(),
+                branch: None,
+                duplicate_of: None,
+            })
+            .collect();
+        storage.insert_chunks(chunks).await.unwrap();
+
+        let report = service.check_freshness(dir.path()).await.unwrap().unwrap();
+        assert!(!report.is_stale(), "unexpected stale files: {:?}", report);
+    }
 }
@@ -1,10 +1,25 @@
 use clap::{Parser, Subcommand};
+use std::path::PathBuf;
 
 #[derive(Parser)]
 #[command(name = "coderag")]
 #[command(author, version, about = "Semantic code search CLI and MCP server")]
 #[command(propagate_version = true)]
 pub struct Cli {
+    /// Safe mode: refuse any backend that sends code over the network
+    #[arg(long, global = true)]
+    pub offline: bool,
+
+    /// Embedding throughput preset: cheap, balanced or fast. Values in the
+    /// config file override the preset's
+    #[arg(long, global = true)]
+    pub profile: Option<String>,
+
+    /// Seed for ordering equally-ranked results, for reproducible runs
+    /// (default 0; see CODERAG_SEED)
+    #[arg(long, global = true)]
+    pub seed: Option<u64>,
+
     #[command(subcommand)]
     pub command: Commands,
 }
@@ -20,6 +35,16 @@ pub enum Commands {
 
     /// Index the codebase (optional - happens automatically on search)
     Index {
+        /// Source archive (.tar, .tar.gz, .tgz or .zip) to index into the
+        /// current project's index
+        #[arg(conflicts_with = "branch")]
+        archive: Option<PathBuf>,
+
+        /// Index the tree of a git branch, tag or commit alongside the
+        /// working tree (repeatable)
+        #[arg(long)]
+        branch: Vec<String>,
+
         /// Force full re-index, ignoring incremental updates
         #[arg(long)]
         force: bool,
@@ -46,6 +71,10 @@ pub enum Commands {
         /// Debounce delay in milliseconds for file watcher (default: 500)
         #[arg(long, default_value = "500")]
         debounce_ms: u64,
+
+        /// Refuse every operation that modifies the index (no auto-indexing, no watcher)
+        #[arg(long, conflicts_with = "watch")]
+        read_only: bool,
     },
 
     /// Search the codebase (auto-indexes if needed)
@@ -53,13 +82,134 @@ pub enum Commands {
         /// Search query
         query: String,
 
-        /// Maximum number of results to return
-        #[arg(short, long, default_value = "10")]
+        /// Maximum number of results to return (default: search.default_limit)
+        #[arg(short, long)]
         limit: Option<usize>,
 
+        /// Apply the options saved in [search.profiles.<NAME>]; flags given
+        /// explicitly override the profile's values
+        #[arg(long, value_name = "NAME")]
+        search_profile: Option<String>,
+
         /// Skip auto-indexing before search
         #[arg(long)]
         no_auto_index: bool,
+
+        /// Reindex changed files before searching instead of warning
+        #[arg(long, conflicts_with = "no_auto_index")]
+        auto_refresh: bool,
+
+        /// Skip the check for files changed since the last index
+        #[arg(long)]
+        no_freshness_check: bool,
+
+        /// Group results into topic clusters
+        #[arg(long)]
+        cluster: bool,
+
+        /// Number of clusters (implies --cluster; chosen automatically if omitted)
+        #[arg(long)]
+        clusters: Option<usize>,
+
+        /// Only return chunks of this kind (e.g. function, struct, client-method, config, section)
+        #[arg(long)]
+        kind: Option<String>,
+
+        /// Only return chunks with this analysis tag (e.g. concurrency)
+        #[arg(long)]
+        tag: Option<String>,
+
+        /// With --tag-value: only return Go structs with a field tagged
+        /// `<key>:"<value>"` (e.g. --tag-key db --tag-value user_id), or
+        /// SQL tables defining the column with --tag-key db
+        #[arg(long, requires = "tag_value", conflicts_with = "tag")]
+        tag_key: Option<String>,
+
+        /// Serialized field name to look for under --tag-key
+        #[arg(long, requires = "tag_key")]
+        tag_value: Option<String>,
+
+        /// Only return chunks of files whose header comment sets KEY to
+        /// VALUE, or KEY to anything when given alone (repeatable; keys
+        /// come from indexer.header_keys)
+        #[arg(long, value_name = "KEY=VALUE")]
+        meta: Vec<String>,
+
+        /// Only return chunks indexed from this git ref (see `index --branch`)
+        #[arg(long)]
+        branch: Option<String>,
+
+        /// Rank these kinds first among similarly-scored results, most
+        /// preferred first (e.g. function,method; "none" disables)
+        #[arg(long, value_delimiter = ',')]
+        prefer_kind: Vec<String>,
+
+        /// Embed the query with this model instead of the index's model.
+        /// Must be a model of the configured provider with the same
+        /// dimension as the index
+        #[arg(long)]
+        query_model: Option<String>,
+
+        /// Re-rank by weighted doc/body/structure similarity
+        /// (e.g. doc=0.7,body=0.3; "default" uses search.field_weights;
+        /// needs indexer.embed_weighted_fields)
+        #[arg(long)]
+        weights: Option<String>,
+
+        /// Search only this field: "name" matches symbol names, "comments"
+        /// the comments in symbol bodies, "summary" the LLM-written chunk
+        /// summaries, "doc", "body" and "structure" the fields --weights
+        /// scores (see indexer.embed_name_variants,
+        /// indexer.embed_comment_field, indexer.summaries and
+        /// indexer.embed_weighted_fields)
+        #[arg(long, conflicts_with = "weights")]
+        field: Option<String>,
+
+        /// List the sibling symbols (same parent type) of each result
+        #[arg(long)]
+        with_siblings: bool,
+
+        /// Drop results scoring below this (0.0 - 1.0), overriding
+        /// search.min_score
+        #[arg(long)]
+        min_score: Option<f32>,
+
+        /// Candidates to retrieve before re-ranking, overriding
+        /// search.candidates (default: max(50, 5 × limit), at most
+        /// search.max_candidates)
+        #[arg(long, value_name = "N")]
+        candidates: Option<usize>,
+
+        /// Show how each result was retrieved (vector, lexical, expanded
+        /// query), and hints and index details when explaining an empty search
+        #[arg(short, long)]
+        verbose: bool,
+
+        /// Start previews at the symbol (default) or at the line matching the
+        /// query best, labeled with its symbol; overrides
+        /// search.snippet_anchor
+        #[arg(long, value_name = "symbol|match")]
+        anchor: Option<String>,
+
+        /// Lines shown around the match line in previews; implies
+        /// --anchor match
+        #[arg(long, value_name = "N")]
+        context: Option<usize>,
+
+        /// Highlight result snippets by language: ANSI colors on a
+        /// terminal (never with NO_COLOR), `<span>` markup with --format html
+        #[arg(long)]
+        highlight: bool,
+
+        /// Output format: text, quickfix for `path:line:col: signature`
+        /// lines that editors load as a jump list, json for results with
+        /// their retrieval sources, or html for an HTML fragment
+        #[arg(
+            long,
+            default_value = "text",
+            conflicts_with_all = ["cluster", "clusters", "with_siblings"]
+        )]
+        format: String,
     },
 
     /// Watch for file changes and automatically re-index
@@ -87,11 +237,120 @@ pub enum Commands {
         /// Port to listen on
         #[arg(short, long, default_value = "8080")]
         port: u16,
+
+        /// Disable endpoints that modify the index
+        #[arg(long)]
+        read_only: bool,
     },
 
     /// Show project and index status
     Status,
 
+    /// List indexed symbols with filters and sorting
+    Symbols {
+        /// Only list symbols in this language (e.g. go, rust)
+        #[arg(long)]
+        lang: Option<String>,
+
+        /// Only list symbols of this kind (e.g. function, method, struct)
+        #[arg(long)]
+        kind: Option<String>,
+
+        /// Sort order: name, size or complexity
+        #[arg(long, default_value = "name")]
+        sort: String,
+
+        /// Maximum number of symbols to list
+        #[arg(short, long)]
+        limit: Option<usize>,
+
+        /// Number of symbols to skip, for paging
+        #[arg(long, default_value = "0")]
+        offset: usize,
+
+        /// Output as JSON
+        #[arg(long)]
+        json: bool,
+    },
+
+    /// Compare the symbols of two indexed git refs
+    Diff {
+        /// The two refs to compare, each indexed with `index --branch`
+        #[arg(long, required = true)]
+        branch: Vec<String>,
+
+        /// Output as JSON
+        #[arg(long)]
+        json: bool,
+    },
+
+    /// Export the index as Parquet files partitioned by language, for analytics
+    Export {
+        /// Directory to write to; each export appends new files
+        out: PathBuf,
+    },
+
+    /// List where feature flags are checked
+    Flags {
+        /// Only list checks of this flag
+        flag: Option<String>,
+
+        /// Output as JSON
+        #[arg(long)]
+        json: bool,
+    },
+
+    /// List the same-named exported types linked across languages (e.g. a
+    /// Go struct and its TypeScript interface)
+    Counterparts {
+        /// Exported type name (e.g. Task)
+        symbol: String,
+
+        /// Name matching rule, overriding indexer.counterparts: exact or
+        /// normalized (ignoring case, `_` and `-`)
+        #[arg(long = "match", value_name = "RULE")]
+        rule: Option<String>,
+
+        /// Output as JSON
+        #[arg(long)]
+        json: bool,
+    },
+
+    /// List exported functions and methods that no test calls. A heuristic:
+    /// only direct calls from tests count, matched by name, so code reached
+    /// through helpers or dynamic dispatch is listed too
+    Untested {
+        /// Only list symbols in files under this path
+        #[arg(long)]
+        path: Option<String>,
+
+        /// Only list symbols in this language (e.g. go, rust)
+        #[arg(long)]
+        lang: Option<String>,
+
+        /// Output as JSON
+        #[arg(long)]
+        json: bool,
+    },
+
+    /// Work with the symbol graph of calls, references and cross-language
+    /// links
+    Graph {
+        #[command(subcommand)]
+        command: GraphCommand,
+    },
+
+    /// Check the index for corruption and inconsistencies
+    Validate {
+        /// Repair the issues that can be fixed safely
+        #[arg(long)]
+        fix: bool,
+
+        /// Output issues as JSON
+        #[arg(long)]
+        json: bool,
+    },
+
     /// Migrate local .coderag/ storage to global storage
     Migrate {
         /// Keep local .coderag/ directory after migration (only removes index files)
@@ -104,6 +363,35 @@ pub enum Commands {
     },
 }
 
+/// Subcommands for the symbol graph.
+#[derive(Subcommand)]
+pub enum GraphCommand {
+    /// Export the symbol graph: nodes with their metadata, edges with their
+    /// kind
+    Export {
+        /// Output format: dot (Graphviz), graphml (Gephi, yEd) or json
+        #[arg(long, default_value = "json")]
+        format: String,
+
+        /// Only export symbols in files under this path
+        #[arg(long)]
+        path: Option<String>,
+
+        /// Only export symbols in this language (e.g. go, typescript)
+        #[arg(long)]
+        lang: Option<String>,
+
+        /// Only export edges of these kinds: calls, tests, references,
+        /// corresponds, declares
+        #[arg(long, value_delimiter = ',')]
+        edge_kind: Vec<String>,
+
+        /// Write to this file instead of stdout
+        #[arg(short, long)]
+        output: Option<PathBuf>,
+    },
+}
+
 /// Subcommands for project management.
 #[derive(Subcommand)]
 pub enum ProjectsCommand {
@@ -0,0 +1,95 @@
+//! Counterparts command implementation.
+//!
+//! Lists the exported types that share a name across languages, such as a
+//! Go struct and the TypeScript interface mirroring it, from the symbol
+//! graph. Like `symbols`, this reads index metadata only, so no embeddings
+//! are needed.
+
+use anyhow::{anyhow, bail, Result};
+use std::env;
+
+use crate::auto_index::AutoIndexService;
+use crate::symbol::{CounterpartMatch, SymbolGraph, SymbolNode};
+use crate::Config;
+
+/// Run the counterparts command.
+///
+/// # Arguments
+///
+/// * `symbol` - Name of an exported type
+/// * `rule` - Name matching rule (`exact` or `normalized`), overriding
+///   `indexer.counterparts`
+/// * `json` - Print JSON instead of a list
+pub async fn run(symbol: &str, rule: Option<&str>, json: bool) -> Result<()> {
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+    let location = service.resolve_storage(&cwd)?;
+    if !service.index_exists(&cwd).await? {
+        bail!("No index found. Run 'coderag index' first.");
+    }
+    let config = if location.is_local() {
+        Config::load(location.root())?
+    } else {
+        Config::default()
+    };
+
+    let rule = match rule {
+        Some(rule) => CounterpartMatch::parse(rule)
+            .ok_or_else(|| anyhow!("Unknown matching rule '{}'. Use exact or normalized", rule))?,
+        None => config.indexer.counterparts,
+    };
+    if rule == CounterpartMatch::Off {
+        bail!(
+            "Cross-language linking is off. Set indexer.counterparts to \"exact\" or \
+             \"normalized\" in the config, or pass --match."
+        );
+    }
+
+    let mut graph = match location.storage_dir() {
+        Some(dir) => SymbolGraph::load(dir)?,
+        None => SymbolGraph::default(),
+    };
+    // Types are stored whatever the rule, so a different rule needs no reindex
+    graph.link_counterparts(rule);
+    let counterparts = graph.counterparts(symbol);
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&counterparts)?);
+    } else {
+        print_counterparts(symbol, &counterparts, graph.types().next().is_none());
+    }
+
+    Ok(())
+}
+
+/// Print the linked definitions, one `<language>  <path>:<line>  <name>`
+/// line each
+fn print_counterparts(symbol: &str, counterparts: &[&SymbolNode], no_types: bool) {
+    if counterparts.is_empty() {
+        println!("No counterparts found for '{}'", symbol);
+        if no_types {
+            println!(
+                "\nThe symbol graph records no exported types; re-run \
+                 'coderag index --force' to rebuild it"
+            );
+        }
+        return;
+    }
+
+    let width = counterparts
+        .iter()
+        .map(|t| t.language.as_deref().map_or(0, str::len))
+        .max()
+        .unwrap_or(0);
+    println!("{} ({} definitions)", symbol, counterparts.len());
+    for ty in counterparts {
+        println!(
+            "  {:<width$}  {}:{}  {}",
+            ty.language.as_deref().unwrap_or_default(),
+            ty.file,
+            ty.line,
+            ty.name,
+            width = width
+        );
+    }
+}
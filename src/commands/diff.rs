@@ -0,0 +1,170 @@
+//! Diff command implementation.
+//!
+//! Compares the symbol sets of two git refs indexed with
+//! `coderag index --branch`. Like `symbols`, this reads index metadata only,
+//! so no embeddings are needed.
+
+use anyhow::{bail, Result};
+use std::env;
+use std::path::Path;
+
+use crate::auto_index::AutoIndexService;
+use crate::config::StorageConfig;
+use crate::storage::open_existing_store;
+use crate::symbol::{diff_branches, ChangeKind, SymbolChange};
+
+/// Run the diff command.
+///
+/// # Arguments
+///
+/// * `branches` - Exactly two refs: the base and the ref compared against it
+/// * `json` - Print JSON instead of a list
+pub async fn run(branches: &[String], json: bool) -> Result<()> {
+    let [from, to] = branches else {
+        bail!("diff needs exactly two refs: --branch <a> --branch <b>");
+    };
+
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+    let location = service.resolve_storage(&cwd)?;
+    let config = service.project_config(&cwd)?;
+
+    let changes = branch_changes(&config.storage, location.db_path(), from, to).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&changes)?);
+    } else {
+        print_changes(&changes, from, to);
+    }
+
+    Ok(())
+}
+
+/// Symbol changes between two refs indexed at `db_path` in the configured
+/// store
+///
+/// The index is opened with its stored dimension, since only metadata is
+/// read and the embedding model is irrelevant.
+async fn branch_changes(
+    config: &StorageConfig,
+    db_path: &Path,
+    from: &str,
+    to: &str,
+) -> Result<Vec<SymbolChange>> {
+    let Some(storage) = open_existing_store(config, db_path).await? else {
+        bail!(
+            "No index found. Run 'coderag index --branch {}' first.",
+            from
+        );
+    };
+    let chunks = storage.all_chunks().await?;
+
+    for branch in [from, to] {
+        if !chunks.iter().any(|c| c.branch.as_deref() == Some(branch)) {
+            bail!(
+                "Ref '{}' is not indexed. Run 'coderag index --branch {}' first.",
+                branch,
+                branch
+            );
+        }
+    }
+
+    Ok(diff_branches(&chunks, from, to))
+}
+
+/// Print changes as `<marker> <path> <name> (<kind>)` lines
+fn print_changes(changes: &[SymbolChange], from: &str, to: &str) {
+    if changes.is_empty() {
+        println!("No symbol differences between {} and {}", from, to);
+        return;
+    }
+
+    println!("Symbols changed from {} to {}:\n", from, to);
+    for change in changes {
+        let kind = change
+            .kind
+            .as_deref()
+            .map(|k| format!(" ({})", k))
+            .unwrap_or_default();
+        println!(
+            "{} {}  {}{}",
+            change.change.marker(),
+            change.file_path,
+            change.name,
+            kind
+        );
+    }
+
+    let count = |kind| changes.iter().filter(|c| c.change == kind).count();
+    println!(
+        "\n{} added, {} removed, {} modified",
+        count(ChangeKind::Added),
+        count(ChangeKind::Removed),
+        count(ChangeKind::Modified)
+    );
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::storage::{IndexedChunk, Storage};
+    use tempfile::tempdir;
+
+    fn chunk(branch: &str, name: &str) -> IndexedChunk {
+        IndexedChunk {
+            id: format!("{}:{}", branch, name),
+            content: format!("fn {}() {{}}", name),
+            file_path: "lib.rs".to_string(),
+            start_line: 1,
+            end_line: 1,
+            language: Some("rust".to_string()),
+            vector: vec![0.1; 768],
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: Some(branch.to_string()),
+            duplicate_of: None,
+        }
+    }
+
+    #[tokio::test]
+    async fn test_branch_changes_with_non_default_dimension() {
+        let dir = tempdir().unwrap();
+        let db_path = dir.path().join("index.lance");
+        let storage = Storage::new(&db_path, 768).await.unwrap();
+        storage
+            .insert_chunks(vec![
+                chunk("main", "old_name"),
+                chunk("feature", "new_name"),
+            ])
+            .await
+            .unwrap();
+
+        let config = StorageConfig::default();
+        let changes = branch_changes(&config, &db_path, "main", "feature")
+            .await
+            .unwrap();
+        let found: Vec<(ChangeKind, &str)> = changes
+            .iter()
+            .map(|c| (c.change, c.name.as_str()))
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (ChangeKind::Added, "new_name"),
+                (ChangeKind::Removed, "old_name")
+            ]
+        );
+
+        assert!(branch_changes(&config, &db_path, "main", "missing")
+            .await
+            .is_err());
+    }
+}
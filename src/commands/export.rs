@@ -0,0 +1,49 @@
+//! Export command implementation.
+//!
+//! Writes the index, vectors included, as language-partitioned Parquet files
+//! for analytics in DuckDB, Spark or a lakehouse. See
+//! [`crate::storage::parquet`] for the layout and schema.
+
+use anyhow::{bail, Result};
+use std::env;
+use std::path::Path;
+
+use crate::auto_index::AutoIndexService;
+use crate::storage::{open_existing_store, ParquetExport};
+
+/// Run the export command.
+///
+/// # Arguments
+///
+/// * `out` - Directory to append the Parquet files to
+pub async fn run(out: &Path) -> Result<()> {
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+    let location = service.resolve_storage(&cwd)?;
+    let config = service.project_config(&cwd)?;
+
+    // Open with the stored dimension to read vectors
+    let Some(storage) = open_existing_store(&config.storage, location.db_path()).await? else {
+        bail!("No index found. Run 'coderag index' first.");
+    };
+    let dimension = storage.vector_dimension();
+
+    let mut chunks = storage.all_chunks().await?;
+    let mut vectors = storage.vectors_by_id().await?;
+    for chunk in &mut chunks {
+        chunk.vector = vectors.remove(&chunk.id).unwrap_or_default();
+    }
+
+    let files = ParquetExport::new(out, dimension).write(&chunks)?;
+    for file in &files {
+        println!("{:>8} rows  {}", file.rows, file.path.display());
+    }
+    println!(
+        "\nExported {} chunks to {} file(s) in {}",
+        chunks.len(),
+        files.len(),
+        out.display()
+    );
+
+    Ok(())
+}
@@ -0,0 +1,161 @@
+//! Flags command implementation.
+//!
+//! Lists where feature flags are checked, using the flag accessors
+//! configured in `indexer.flag_accessors`. Like `symbols`, this reads index
+//! metadata only, so no embeddings are needed.
+
+use anyhow::{bail, Result};
+use std::env;
+use std::path::Path;
+
+use crate::auto_index::AutoIndexService;
+use crate::config::StorageConfig;
+use crate::indexer::FlagDetector;
+use crate::storage::open_existing_store;
+use crate::symbol::{flag_locations, FlagLocation};
+use crate::Config;
+
+/// Run the flags command.
+///
+/// # Arguments
+///
+/// * `flag` - Only list checks of this flag
+/// * `json` - Print JSON instead of a list
+pub async fn run(flag: Option<&str>, json: bool) -> Result<()> {
+    let cwd = env::current_dir()?;
+    let location = AutoIndexService::new().resolve_storage(&cwd)?;
+    let config = if location.is_local() {
+        Config::load(location.root())?
+    } else {
+        Config::default()
+    };
+
+    let detector = FlagDetector::new(&config.indexer.flag_accessors);
+    if detector.is_empty() {
+        bail!("No flag accessors configured. Set indexer.flag_accessors in the config.");
+    }
+
+    let locations = find_locations(&config.storage, location.db_path(), &detector, flag).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&locations)?);
+    } else {
+        print_locations(&locations);
+    }
+
+    Ok(())
+}
+
+/// Find flag checks in the index at `db_path` in the configured store
+///
+/// The index is opened with its stored dimension, since only metadata is
+/// read and the embedding model is irrelevant.
+async fn find_locations(
+    config: &StorageConfig,
+    db_path: &Path,
+    detector: &FlagDetector,
+    flag: Option<&str>,
+) -> Result<Vec<FlagLocation>> {
+    let Some(storage) = open_existing_store(config, db_path).await? else {
+        bail!("No index found. Run 'coderag index' first.");
+    };
+    let chunks = storage.all_chunks().await?;
+    Ok(flag_locations(&chunks, detector, flag))
+}
+
+/// Print locations grouped by flag, one `<path>:<line>  <symbol>` line each
+fn print_locations(locations: &[FlagLocation]) {
+    if locations.is_empty() {
+        println!("No feature flag checks found");
+        println!(
+            "\nFlags are detected at index time; re-run 'coderag index --force' \
+             after changing indexer.flag_accessors"
+        );
+        return;
+    }
+
+    let mut flags = 0;
+    for (i, location) in locations.iter().enumerate() {
+        if i == 0 || locations[i - 1].flag != location.flag {
+            let count = locations.iter().filter(|l| l.flag == location.flag).count();
+            if i > 0 {
+                println!();
+            }
+            println!(
+                "{} ({} check{})",
+                location.flag,
+                count,
+                if count == 1 { "" } else { "s" }
+            );
+            flags += 1;
+        }
+        println!(
+            "  {}:{}  {}",
+            location.file_path,
+            location.line,
+            location.symbol.as_deref().unwrap_or("")
+        );
+    }
+    println!("\n{} flag(s), {} check(s)", flags, locations.len());
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::indexer::feature_flags::flag_tag;
+    use crate::storage::{IndexedChunk, Storage};
+    use tempfile::tempdir;
+
+    #[tokio::test]
+    async fn test_find_locations_with_non_default_dimension() {
+        let dir = tempdir().unwrap();
+        let db_path = dir.path().join("index.lance");
+        let storage = Storage::new(&db_path, 768).await.unwrap();
+        storage
+            .insert_chunks(vec![IndexedChunk {
+                id: "checkout.go:10".to_string(),
+                content: "if flags.Enabled(\"new-checkout\") {}".to_string(),
+                file_path: "checkout.go".to_string(),
+                start_line: 10,
+                end_line: 10,
+                language: Some("go".to_string()),
+                vector: vec![0.1; 768],
+                mtime: 0,
+                file_header: None,
+                semantic_kind: Some("function".to_string()),
+                symbol_name: Some("Checkout".to_string()),
+                signature: None,
+                doc: None,
+                parent: None,
+                visibility: None,
+                qualified_name: None,
+                tags: vec![flag_tag("new-checkout")],
+                branch: None,
+                duplicate_of: None,
+            }])
+            .await
+            .unwrap();
+
+        let detector = FlagDetector::new(&["flags.Enabled".to_string()]);
+        let locations = find_locations(&StorageConfig::default(), &db_path, &detector, None)
+            .await
+            .unwrap();
+        assert_eq!(locations.len(), 1);
+        assert_eq!(locations[0].flag, "new-checkout");
+        assert_eq!(locations[0].line, 10);
+    }
+
+    #[tokio::test]
+    async fn test_find_locations_without_index() {
+        let dir = tempdir().unwrap();
+        let detector = FlagDetector::new(&["flags.Enabled".to_string()]);
+        let result = find_locations(
+            &StorageConfig::default(),
+            &dir.path().join("index.lance"),
+            &detector,
+            None,
+        )
+        .await;
+        assert!(result.is_err());
+    }
+}
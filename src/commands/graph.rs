@@ -0,0 +1,81 @@
+//! Graph command implementation.
+//!
+//! Exports the symbol graph built at index time as DOT, GraphML or JSON for
+//! graph tools. Like `symbols`, this reads index metadata only, so no
+//! embeddings are needed.
+
+use anyhow::{anyhow, bail, Result};
+use std::env;
+use std::fs;
+use std::path::Path;
+
+use crate::auto_index::AutoIndexService;
+use crate::symbol::{EdgeKind, GraphExport, GraphFilter, GraphFormat, SymbolGraph};
+
+/// Run the graph export command.
+///
+/// # Arguments
+///
+/// * `format` - Output format: `dot`, `graphml` or `json`
+/// * `path` - Only export symbols in files under this path
+/// * `lang` - Only export symbols in this language
+/// * `edge_kinds` - Only export edges of these kinds (all when empty)
+/// * `output` - File to write instead of stdout
+pub async fn export(
+    format: &str,
+    path: Option<String>,
+    lang: Option<String>,
+    edge_kinds: &[String],
+    output: Option<&Path>,
+) -> Result<()> {
+    let format = GraphFormat::parse(format)
+        .ok_or_else(|| anyhow!("Unknown format '{}'. Use dot, graphml or json", format))?;
+    let kinds = edge_kinds
+        .iter()
+        .map(|kind| {
+            EdgeKind::parse(kind).ok_or_else(|| {
+                anyhow!(
+                    "Unknown edge kind '{}'. Use calls, tests, references, corresponds or declares",
+                    kind
+                )
+            })
+        })
+        .collect::<Result<Vec<_>>>()?;
+
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+    let location = service.resolve_storage(&cwd)?;
+    if !service.index_exists(&cwd).await? {
+        bail!("No index found. Run 'coderag index' first.");
+    }
+    let graph = match location.storage_dir() {
+        Some(dir) => SymbolGraph::load(dir)?,
+        None => SymbolGraph::default(),
+    };
+    if graph.nodes().next().is_none() {
+        bail!("The symbol graph records no symbols; re-run 'coderag index --force' to rebuild it");
+    }
+
+    let filter = GraphFilter {
+        path,
+        language: lang,
+        kinds,
+    };
+    let export = GraphExport::new(&graph, &filter);
+    let rendered = export.render(format)?;
+
+    match output {
+        Some(output) => {
+            fs::write(output, rendered)?;
+            println!(
+                "Wrote {} nodes and {} edges to {}",
+                export.nodes.len(),
+                export.edges.len(),
+                output.display()
+            );
+        }
+        None => print!("{}", rendered),
+    }
+
+    Ok(())
+}
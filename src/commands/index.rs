@@ -5,10 +5,9 @@
 
 use anyhow::Result;
 use std::env;
+use std::path::Path;
 
-use crate::auto_index::{AutoIndexPolicy, AutoIndexService, StorageResolver};
-use crate::project_detection::ProjectDetector;
-use crate::storage::Storage;
+use crate::auto_index::{AutoIndexPolicy, AutoIndexService};
 
 /// Run the index command.
 ///
@@ -21,21 +20,6 @@ use crate::storage::Storage;
 pub async fn run(force: bool) -> Result<()> {
     let cwd = env::current_dir()?;
 
-    // If force flag is set, clear existing index first
-    if force {
-        let detector = ProjectDetector::default();
-        if let Ok(project) = detector.detect(&cwd) {
-            if let Ok(storage_location) = StorageResolver::resolve(&project) {
-                if storage_location.index_exists() {
-                    eprintln!("Clearing existing index for full re-index...");
-                    // Use default dimension since we're just clearing
-                    let storage = Storage::new_with_default_dimension(storage_location.db_path()).await?;
-                    storage.clear().await?;
-                }
-            }
-        }
-    }
-
     // Use AutoIndexService for consistent storage resolution
     // When force is true, we use OnMissing policy since we just cleared the index
     let policy = if force {
@@ -44,6 +28,12 @@ pub async fn run(force: bool) -> Result<()> {
         AutoIndexPolicy::OnMissingOrStale
     };
     let service = AutoIndexService::with_policy(policy);
+
+    // If force flag is set, clear existing index first
+    if force && service.clear_index(&cwd).await? {
+        eprintln!("Cleared existing index for full re-index.");
+    }
+
     let result = service.ensure_indexed(&cwd).await?;
 
     // Print storage location info
@@ -72,3 +62,44 @@ pub async fn run(force: bool) -> Result<()> {
 
     Ok(())
 }
+
+/// Index a source archive into the current project's index.
+///
+/// The archive is extracted to a temporary directory that is removed once
+/// its files are read. Files are indexed as `<archive>!/<path>`.
+pub async fn run_archive(archive: &Path) -> Result<()> {
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+    let result = service.index_archive(&cwd, archive).await?;
+
+    println!("Project root: {}", result.storage.root().display());
+    println!(
+        "Indexed {} files ({} chunks) from {} in {:.2}s",
+        result.files_indexed,
+        result.chunks_created,
+        archive.display(),
+        result.duration_secs
+    );
+
+    Ok(())
+}
+
+/// Index the trees of git refs into the current project's index.
+///
+/// Each ref is exported with `git archive`, so the working tree is left
+/// alone. Files are indexed as `<ref>:<path>` and tagged with the ref, which
+/// `search --branch` and `diff` use.
+pub async fn run_branches(branches: &[String]) -> Result<()> {
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+
+    for branch in branches {
+        let result = service.index_ref(&cwd, branch).await?;
+        println!(
+            "Indexed {} files ({} chunks) from {} in {:.2}s",
+            result.files_indexed, result.chunks_created, branch, result.duration_secs
+        );
+    }
+
+    Ok(())
+}
@@ -1,3 +1,8 @@
+pub mod counterparts;
+pub mod diff;
+pub mod export;
+pub mod flags;
+pub mod graph;
 pub mod index;
 pub mod init;
 pub mod migrate;
@@ -6,5 +11,8 @@ pub mod search;
 pub mod serve;
 pub mod stats;
 pub mod status;
+pub mod symbols;
+pub mod untested;
+pub mod validate;
 pub mod watch;
 pub mod web;
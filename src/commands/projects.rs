@@ -5,7 +5,7 @@ use std::env;
 use std::path::{Path, PathBuf};
 
 use crate::registry::{GlobalRegistry, ProjectInfo, ProjectStats};
-use crate::storage::Storage;
+use crate::storage::open_existing_store;
 use crate::Config;
 
 /// List all registered projects.
@@ -255,21 +255,20 @@ async fn get_project_stats(project_path: &Path) -> Result<ProjectStats> {
     let config = Config::load(project_path)?;
     let db_path = config.db_path(project_path);
 
-    if !db_path.exists() {
+    // Use the stored dimension since we're only reading metadata
+    let Some(storage) = open_existing_store(&config.storage, &db_path).await? else {
         bail!("Index not found at {:?}", db_path);
-    }
-
-    // Use default dimension since we're only reading metadata
-    let storage = Storage::new_with_default_dimension(&db_path).await?;
-
-    let chunk_count = storage.count_chunks().await?;
-    let files = storage.list_files(None).await?;
-    let file_count = files.len();
+    };
+    let stats = storage.stats().await?;
 
-    // Calculate index size
+    // Calculate index size; server backends keep nothing on disk
     let index_size_bytes = calculate_dir_size(&db_path)?;
 
-    Ok(ProjectStats::new(file_count, chunk_count, index_size_bytes))
+    Ok(ProjectStats::new(
+        stats.files,
+        stats.chunks,
+        index_size_bytes,
+    ))
 }
 
 /// Calculate the total size of a directory.
@@ -1,12 +1,26 @@
-use anyhow::Result;
+use anyhow::{anyhow, bail, Result};
+use std::collections::BTreeMap;
 use std::env;
+use std::path::Path;
 use std::sync::Arc;
 
 use crate::auto_index::{AutoIndexPolicy, AutoIndexService};
-use crate::embeddings::EmbeddingGenerator;
-use crate::search::traits::Search;
-use crate::search::SearchEngine;
-use crate::storage::Storage;
+use crate::config::SearchProfile;
+use crate::embeddings::{query_model_config, EmbeddingGenerator};
+use crate::indexer::header_meta::meta_filter;
+use crate::indexer::struct_tags::struct_tag;
+use crate::indexer::{SemanticKind, SymlinkAliases};
+use crate::search::{
+    ansi_enabled, cluster_results, describe_sources, escape_html, explain_no_results,
+    language_for_path, quickfix_line, CandidatePool, EmptySearch, FieldWeights, Highlighter,
+    KindPreference, NoResultsCause, OutputFormat, ProcessorChain, SearchEngine, SearchResult,
+    Snippet, SnippetAnchor, SynonymMap,
+};
+use crate::storage::{
+    open_configured_store, open_existing_store, IndexedChunk, SearchFilter, VectorField,
+    VectorStore,
+};
+use crate::symbol::{CounterpartMatch, SymbolGraph, SymbolIndex};
 use crate::Config;
 
 /// Run the search command
@@ -14,22 +28,86 @@ use crate::Config;
 /// With zero-ceremony support, this command:
 /// 1. Auto-detects the project root
 /// 2. Resolves storage location (local or global)
-/// 3. Auto-indexes if needed (unless `no_auto_index` is set)
-/// 4. Performs semantic search
+/// 3. Auto-indexes if missing (unless `no_auto_index` is set)
+/// 4. Warns if files changed since the last index, or reindexes them when
+///    `auto_refresh` is set
+/// 5. Performs semantic search, optionally grouping results into clusters
+/// 6. Explains likely causes when nothing is found
 ///
 /// # Arguments
 ///
 /// * `query` - The search query
 /// * `limit` - Maximum number of results to return
+/// * `search_profile` - Name of a `[search.profiles]` entry supplying the
+///   options not given explicitly
 /// * `no_auto_index` - Skip auto-indexing before search
-pub async fn run(query: &str, limit: Option<usize>, no_auto_index: bool) -> Result<()> {
+/// * `auto_refresh` - Reindex stale files instead of warning about them
+/// * `no_freshness_check` - Skip the staleness check entirely
+/// * `cluster` - Group results into topic clusters
+/// * `cluster_count` - Number of clusters, overriding the config (auto when neither is set)
+/// * `kind` - Only return chunks of this semantic kind
+/// * `tag` - Only return chunks with this analysis tag (e.g. `concurrency`)
+/// * `tag_key`, `tag_value` - Only return Go structs with a field serialized
+///   as `tag_value` under `tag_key` (e.g. `db` and `user_id`)
+/// * `meta` - Only return chunks whose file header has all of these
+///   `key=value` pairs (a bare `key` matches any value)
+/// * `branch` - Only return chunks indexed from this git ref
+/// * `prefer_kind` - Kind preference order, overriding the config (`none` disables)
+/// * `query_model` - Embed the query with this model; it must match the index dimension
+/// * `weights` - Field weights (`doc=0.7,body=0.3` or `default`) for multi-field re-ranking
+/// * `field` - Search only this field: `name` (symbol name variants) or `comments`
+/// * `with_siblings` - List the symbols sharing each result's parent type
+/// * `min_score` - Drop results scoring below this, overriding the config
+/// * `candidates` - Candidates to retrieve before re-ranking, overriding the config
+/// * `verbose` - Show each result's retrieval sources, and add hints and
+///   index details to the empty-result explanation
+/// * `anchor` - Where previews start: `symbol` or `match`, overriding the config
+/// * `context` - Lines around the match line in previews; implies `match`
+/// * `highlight` - Highlight snippets by language (also `search.highlight`)
+/// * `format` - Output format: `text`, `quickfix`, `json` or `html`
+pub async fn run(
+    query: &str,
+    limit: Option<usize>,
+    search_profile: Option<&str>,
+    no_auto_index: bool,
+    auto_refresh: bool,
+    no_freshness_check: bool,
+    cluster: bool,
+    cluster_count: Option<usize>,
+    kind: Option<&str>,
+    tag: Option<&str>,
+    tag_key: Option<&str>,
+    tag_value: Option<&str>,
+    meta: &[String],
+    branch: Option<&str>,
+    prefer_kind: &[String],
+    query_model: Option<&str>,
+    weights: Option<&str>,
+    field: Option<&str>,
+    with_siblings: bool,
+    min_score: Option<f32>,
+    candidates: Option<usize>,
+    verbose: bool,
+    anchor: Option<&str>,
+    context: Option<usize>,
+    highlight: bool,
+    format: &str,
+) -> Result<()> {
+    let format = OutputFormat::parse(format).ok_or_else(|| {
+        anyhow!(
+            "Unknown output format '{}'. Use text, quickfix, json or html",
+            format
+        )
+    })?;
     let cwd = env::current_dir()?;
 
     // Set up auto-index service with appropriate policy
     let policy = if no_auto_index {
         AutoIndexPolicy::Never
-    } else {
+    } else if auto_refresh {
         AutoIndexPolicy::OnMissingOrStale
+    } else {
+        AutoIndexPolicy::OnMissing
     };
     let service = AutoIndexService::with_policy(policy);
     let result = service.ensure_indexed(&cwd).await?;
@@ -48,64 +126,521 @@ pub async fn run(query: &str, limit: Option<usize>, no_auto_index: bool) -> Resu
         Config::default()
     };
 
-    let limit = limit.unwrap_or(config.search.default_limit);
+    let mut stale_files = None;
+    if !auto_refresh && !no_freshness_check && config.search.freshness_check {
+        match service.check_freshness(&cwd).await {
+            Ok(Some(report)) if report.is_stale() => {
+                eprintln!("{}\n", report.warning(result.storage.root()));
+                stale_files = Some(report.stale_count());
+            }
+            Ok(_) => {}
+            Err(e) => tracing::debug!("Freshness check failed: {}", e),
+        }
+    }
+
+    // Explicit flags take precedence over the selected profile
+    let field_tag = tag_key.zip(tag_value).map(|(k, v)| struct_tag(k, v));
+    let flags = SearchProfile {
+        kind: kind.map(str::to_string),
+        tag: field_tag.or(tag.map(str::to_string)),
+        meta: (!meta.is_empty()).then(|| meta.to_vec()),
+        branch: branch.map(str::to_string),
+        prefer_kind: (!prefer_kind.is_empty()).then(|| prefer_kind.to_vec()),
+        weights: weights.map(str::to_string),
+        field: field.map(str::to_string),
+        min_score,
+        limit,
+        candidates,
+    };
+    let options = match search_profile {
+        Some(name) => config.search.profile(name)?.clone().overridden_by(flags),
+        None => flags,
+    };
+    let field = options
+        .field
+        .as_deref()
+        .map(|f| {
+            VectorField::parse(f).ok_or_else(|| {
+                anyhow!(
+                    "Unknown search field '{}'. Use name, comments, summary, doc, body or structure",
+                    f
+                )
+            })
+        })
+        .transpose()?;
+
+    let limit = options.limit.unwrap_or(config.search.default_limit);
+    let anchor = match anchor {
+        Some(a) => SnippetAnchor::parse(a)
+            .ok_or_else(|| anyhow!("Unknown snippet anchor '{}'. Use symbol or match", a))?,
+        None if context.is_some() => SnippetAnchor::Match,
+        None => config.search.snippet_anchor,
+    };
+
+    // A query model override must produce vectors of the stored dimension
+    let embeddings = match query_model {
+        Some(model) => {
+            let stored = open_existing_store(&config.storage, result.storage.db_path()).await?;
+            let Some(index) = stored else {
+                bail!("No index found. Run 'coderag index' first.");
+            };
+            query_model_config(&config.embeddings, model, index.vector_dimension())?
+        }
+        None => config.embeddings.clone(),
+    };
 
     // Initialize embedder first to get vector dimension
-    let embedder = Arc::new(EmbeddingGenerator::new_async(&config.embeddings).await?);
+    let embedder = Arc::new(EmbeddingGenerator::new_async(&embeddings).await?);
     let vector_dimension = embedder.embedding_dimension();
 
     // Initialize storage with vector dimension from embedder
-    let storage = Arc::new(Storage::new(result.storage.db_path(), vector_dimension).await?);
-    let search_engine = SearchEngine::new(storage, embedder);
+    let db_path = embedder.index_path(result.storage.db_path());
+    let storage = open_configured_store(&config.storage, &db_path, Some(vector_dimension)).await?;
+    // Quickfix messages are symbol signatures
+    let symbols = if with_siblings || format == OutputFormat::Quickfix {
+        Some(SymbolIndex::build_from_chunks(&storage.all_chunks().await?))
+    } else {
+        None
+    };
+    let mut search_engine =
+        SearchEngine::new(storage, embedder).with_summary_weight(config.search.summary_weight);
+    if config.search.expand_embedding_query {
+        search_engine = search_engine.with_synonyms(SynonymMap::new(&config.search.synonyms));
+    }
+
+    // Perform search, accepting kind aliases such as `client_method`
+    let filter = SearchFilter {
+        kind: options
+            .kind
+            .as_deref()
+            .map(|k| SemanticKind::parse(k).map_or(k, |k| k.as_str()).to_string()),
+        tag: options.tag.as_deref().map(|t| t.trim().to_lowercase()),
+        meta: options
+            .meta
+            .iter()
+            .flatten()
+            .map(|m| meta_filter(m))
+            .collect(),
+        branch: options.branch.clone(),
+    };
+    let weights = match options.weights.as_deref() {
+        Some("default") => Some(FieldWeights::from_map(&config.search.field_weights)?),
+        Some(spec) => Some(FieldWeights::parse(spec)?),
+        None => None,
+    };
+    // Re-ranking reorders a larger pool of candidates, cut to `limit` below
+    let pool = CandidatePool::from_config(&config.search).with_size(options.candidates);
+    let results = if let Some(field) = field {
+        require_field_vectors(search_engine.storage().as_ref(), field).await?;
+        search_engine
+            .search_field(field, query, limit, &filter, &pool)
+            .await?
+    } else if let Some(weights) = &weights {
+        // Every chunk with code has a body, while a project can lack docs
+        require_field_vectors(search_engine.storage().as_ref(), VectorField::Body).await?;
+        search_engine
+            .search_weighted(query, limit, &filter, weights, &pool)
+            .await?
+    } else {
+        search_engine
+            .search_candidates(query, limit, &filter, &pool)
+            .await?
+    };
+
+    let preference = match options.prefer_kind.as_deref().unwrap_or_default() {
+        [] => config.search.kind_preference.clone(),
+        [none] if none.eq_ignore_ascii_case("none") => Vec::new(),
+        kinds => kinds.to_vec(),
+    };
+    let processors =
+        ProcessorChain::new().with(KindPreference::new(preference, config.search.kind_boost));
+    let mut results = processors.apply(query, results);
+    results.truncate(limit);
+
+    // Drop weak matches, keeping the best score to explain an empty result
+    let min_score = options.min_score.unwrap_or(config.search.min_score);
+    let best_score = results.iter().map(|r| r.score).reduce(f32::max);
+    let results: Vec<SearchResult> = results
+        .into_iter()
+        .filter(|r| r.score >= min_score)
+        .collect();
+
+    if format == OutputFormat::Quickfix {
+        for result in &results {
+            let signature = symbols
+                .as_ref()
+                .and_then(|s| s.symbol_at(&result.file_path, result.start_line, result.end_line))
+                .and_then(|s| s.signature);
+            println!("{}", quickfix_line(result, signature.as_deref()));
+        }
+        return Ok(());
+    }
+
+    if format == OutputFormat::Json {
+        println!("{}", serde_json::to_string_pretty(&json_results(&results))?);
+        return Ok(());
+    }
 
-    // Perform search
-    let results = search_engine.search(query, limit).await?;
+    // Highlighting parses every snippet again, so it is opt-in; terminal
+    // colors also need a terminal and no NO_COLOR
+    let highlight =
+        (highlight || config.search.highlight) && (format == OutputFormat::Html || ansi_enabled());
+    let mut preview = Preview {
+        query,
+        anchor,
+        context: context.unwrap_or(config.search.snippet_context),
+        highlighter: highlight.then(Highlighter::new),
+    };
+
+    if format == OutputFormat::Html {
+        println!("{}", html_results(&results, preview.highlighter.as_mut()));
+        return Ok(());
+    }
 
     if results.is_empty() {
         println!("No results found for: {}", query);
-        println!("\nMake sure you have indexed the codebase with 'coderag index'");
+        if !config.search.explain_empty {
+            println!("\nMake sure you have indexed the codebase with 'coderag index'");
+            return Ok(());
+        }
+
+        let stale_files = match stale_files {
+            Some(count) => count,
+            None => match service.check_freshness(&cwd).await {
+                Ok(report) => report.map_or(0, |r| r.stale_count()),
+                Err(_) => 0,
+            },
+        };
+        let chunks = search_engine.storage().all_chunks().await?;
+        let search = EmptySearch {
+            min_score,
+            best_score,
+            stale_files,
+            ..EmptySearch::new(query, &filter)
+        };
+        print_explanation(&explain_no_results(&chunks, &search), &chunks, verbose);
         return Ok(());
     }
 
     println!("Found {} results for: \"{}\"\n", results.len(), query);
 
+    let aliases = result
+        .storage
+        .storage_dir()
+        .and_then(|dir| SymlinkAliases::load(dir).ok())
+        .unwrap_or_default();
+    // Types linked across languages are listed under their results
+    let graph = result
+        .storage
+        .storage_dir()
+        .filter(|_| config.indexer.counterparts != CounterpartMatch::Off)
+        .and_then(|dir| SymbolGraph::load(dir).ok());
+
+    if cluster {
+        // Results carry no vectors, so embed their contents for clustering
+        let contents: Vec<String> = results.iter().map(|r| r.content.clone()).collect();
+        let vectors = search_engine.embedder().embed_async(&contents).await?;
+        let clusters = cluster_results(
+            results,
+            &vectors,
+            cluster_count.or(config.search.cluster_count),
+        );
+
+        for (c, group) in clusters.iter().enumerate() {
+            println!(
+                "== Cluster {} ({} results): {} ==",
+                c + 1,
+                group.members.len(),
+                group.label
+            );
+            print_result(
+                "*",
+                &group.representative,
+                &aliases,
+                symbols.as_ref(),
+                graph.as_ref(),
+                &mut preview,
+                verbose,
+            );
+            for (i, member) in group
+                .members
+                .iter()
+                .filter(|m| !is_same_result(m, &group.representative))
+                .enumerate()
+            {
+                print_result(
+                    &(i + 1).to_string(),
+                    member,
+                    &aliases,
+                    symbols.as_ref(),
+                    graph.as_ref(),
+                    &mut preview,
+                    verbose,
+                );
+            }
+        }
+        return Ok(());
+    }
+
     for (i, result) in results.iter().enumerate() {
-        // Format score as percentage
-        let score_pct = (result.score * 100.0).round() as i32;
+        print_result(
+            &(i + 1).to_string(),
+            result,
+            &aliases,
+            symbols.as_ref(),
+            graph.as_ref(),
+            &mut preview,
+            verbose,
+        );
+    }
 
-        // Print result header
-        println!(
-            "{}. {}:{}-{} (score: {}%)",
-            i + 1,
-            result.file_path,
+    Ok(())
+}
+
+/// How result previews are shown
+struct Preview<'a> {
+    query: &'a str,
+    anchor: SnippetAnchor,
+    /// Lines around the match line when anchored at the match
+    context: usize,
+    /// Highlights previews when set
+    highlighter: Option<Highlighter>,
+}
+
+/// Print a result header, symlink aliases and duplicates, content preview and, when a
+/// symbol index is given, the
+/// result's sibling symbols, and when a symbol graph is given, the
+/// cross-language counterparts of its types; `verbose` adds the paths that
+/// retrieved the result
+/// Fail with the setting to enable when no vectors of `field` are stored
+async fn require_field_vectors(storage: &dyn VectorStore, field: VectorField) -> Result<()> {
+    if storage.has_field_vectors(field).await? {
+        return Ok(());
+    }
+    let setting = match field {
+        VectorField::Name => "embed_name_variants",
+        VectorField::Comments => "embed_comment_field",
+        VectorField::Summary => "summaries.enabled",
+        VectorField::Doc | VectorField::Body | VectorField::Structure => "embed_weighted_fields",
+    };
+    bail!(
+        "No {} vectors in the index. Set indexer.{} = true and run 'coderag index --force'",
+        field.as_str(),
+        setting
+    );
+}
+
+fn print_result(
+    marker: &str,
+    result: &SearchResult,
+    aliases: &SymlinkAliases,
+    symbols: Option<&SymbolIndex>,
+    graph: Option<&SymbolGraph>,
+    preview: &mut Preview,
+    verbose: bool,
+) {
+    // Format score as percentage
+    let score_pct = (result.score * 100.0).round() as i32;
+
+    println!(
+        "{}. {}:{}-{} (score: {}%)",
+        marker, result.file_path, result.start_line, result.end_line, score_pct
+    );
+    for alias in aliases.aliases(Path::new(&result.file_path)) {
+        println!("   (also at {})", alias.display());
+    }
+    for duplicate in &result.duplicates {
+        println!("   (also at {})", duplicate);
+    }
+    if verbose && !result.sources.is_empty() {
+        println!("   via {}", describe_sources(&result.sources));
+    }
+
+    // Print content preview: the symbol's first lines or those around the match
+    let snippet = Snippet::new(
+        &result.content,
+        preview.query,
+        preview.anchor,
+        preview.context,
+    );
+    let language = language_for_path(&result.file_path);
+    let content = match (preview.highlighter.as_mut(), language) {
+        (Some(highlighter), Some(language)) => highlighter.ansi(language, &result.content),
+        _ => result.content.clone(),
+    };
+    println!("{}", format_preview(&content, &snippet, result.start_line));
+    if let Some(symbols) = symbols {
+        print_siblings(result, symbols);
+    }
+    if let Some(graph) = graph {
+        print_counterparts(result, graph);
+    }
+    println!();
+}
+
+/// Results as a JSON array, with the sources that retrieved each and the
+/// locations of its duplicates
+fn json_results(results: &[SearchResult]) -> serde_json::Value {
+    results
+        .iter()
+        .map(|result| {
+            serde_json::json!({
+                "file_path": result.file_path,
+                "start_line": result.start_line,
+                "end_line": result.end_line,
+                "score": result.score,
+                "semantic_kind": result.semantic_kind,
+                "content": result.content,
+                "sources": result.sources,
+                "duplicates": result.duplicates,
+            })
+        })
+        .collect()
+}
+
+/// Results as an HTML fragment: one `<div class="result">` per result, with
+/// its location and score and its code in `<pre><code>`, highlighted when a
+/// highlighter is given
+fn html_results(results: &[SearchResult], mut highlighter: Option<&mut Highlighter>) -> String {
+    let mut html = String::new();
+    for result in results {
+        let language = language_for_path(&result.file_path);
+        let code = match (highlighter.as_deref_mut(), language) {
+            (Some(highlighter), Some(language)) => highlighter.html(language, &result.content),
+            _ => escape_html(&result.content),
+        };
+        let class = language
+            .map(|l| format!(" class=\"language-{}\"", l))
+            .unwrap_or_default();
+        html.push_str("<div class=\"result\">\n");
+        html.push_str(&format!(
+            "<div class=\"location\">{}:{}-{} (score: {}%)</div>\n",
+            escape_html(&result.file_path),
             result.start_line,
             result.end_line,
-            score_pct
-        );
+            (result.score * 100.0).round() as i32
+        ));
+        html.push_str(&format!(
+            "<pre><code{}>{}</code></pre>\n</div>\n",
+            class, code
+        ));
+    }
+    html
+}
 
-        // Print content preview (first few lines)
-        let preview = format_preview(&result.content, 5);
-        println!("{}", preview);
-        println!();
+/// Print the other symbols of the result's parent type, one signature per line
+fn print_siblings(result: &SearchResult, symbols: &SymbolIndex) {
+    let Some(symbol) = symbols.symbol_at(&result.file_path, result.start_line, result.end_line)
+    else {
+        return;
+    };
+    let siblings = symbols.siblings(&symbol);
+    if siblings.is_empty() {
+        return;
     }
 
-    Ok(())
+    println!(
+        "   Siblings in {}:",
+        symbol.parent.as_deref().unwrap_or_default()
+    );
+    for sibling in siblings {
+        println!(
+            "     {}",
+            sibling.signature.as_deref().unwrap_or(&sibling.name)
+        );
+    }
 }
 
-/// Format a preview of the content, limiting to max_lines
-fn format_preview(content: &str, max_lines: usize) -> String {
-    let lines: Vec<&str> = content.lines().collect();
-    let preview_lines = if lines.len() > max_lines {
-        let mut preview: Vec<&str> = lines.iter().take(max_lines).copied().collect();
-        preview.push("   ...");
-        preview
+/// Print where each type the result defines is also defined in another
+/// language
+fn print_counterparts(result: &SearchResult, graph: &SymbolGraph) {
+    let types = graph
+        .file_nodes(&result.file_path)
+        .iter()
+        .filter(|n| n.is_exported_type())
+        .filter(|n| (result.start_line..=result.end_line).contains(&n.line));
+    for ty in types {
+        let others: Vec<String> = graph
+            .counterparts(&ty.name)
+            .into_iter()
+            .filter(|c| c.language != ty.language)
+            .map(|c| {
+                let language = c.language.as_deref().unwrap_or_default();
+                format!("{}:{} ({})", c.file, c.line, language)
+            })
+            .collect();
+        if !others.is_empty() {
+            println!("   {} is also defined in {}", ty.name, others.join(", "));
+        }
+    }
+}
+
+/// Print the likely causes of an empty search; `verbose` adds what to do
+/// about each and a summary of the index
+fn print_explanation(causes: &[NoResultsCause], chunks: &[IndexedChunk], verbose: bool) {
+    if causes.is_empty() {
+        println!("\nThe index and filters look fine; try rephrasing the query");
     } else {
-        lines
-    };
+        println!("\nLikely causes:");
+        for cause in causes {
+            println!("  - {}", cause.message());
+            if verbose {
+                println!("    {}", cause.hint());
+            }
+        }
+    }
+
+    if !verbose {
+        if !causes.is_empty() {
+            println!("\nRun with --verbose for suggestions");
+        }
+        return;
+    }
 
-    preview_lines
+    let mut languages: BTreeMap<&str, usize> = BTreeMap::new();
+    for chunk in chunks {
+        *languages
+            .entry(chunk.language.as_deref().unwrap_or("unknown"))
+            .or_default() += 1;
+    }
+    let languages: Vec<String> = languages
         .iter()
-        .map(|line| format!("   {}", line))
-        .collect::<Vec<_>>()
-        .join("\n")
+        .map(|(language, count)| format!("{} {}", language, count))
+        .collect();
+    println!(
+        "\nIndex: {} chunks ({})",
+        chunks.len(),
+        languages.join(", ")
+    );
+}
+
+fn is_same_result(a: &SearchResult, b: &SearchResult) -> bool {
+    a.file_path == b.file_path && a.start_line == b.start_line && a.end_line == b.end_line
+}
+
+/// Format the snippet's lines of the content, marking left-out lines; a
+/// snippet below the symbol start is labeled with its line and symbol
+fn format_preview(content: &str, snippet: &Snippet, start_line: usize) -> String {
+    let mut preview = Vec::new();
+    if snippet.skips_before() {
+        match &snippet.symbol {
+            Some(symbol) => preview.push(format!(
+                "   ... line {} in {}",
+                start_line + snippet.first,
+                symbol
+            )),
+            None => preview.push("   ...".to_string()),
+        }
+    }
+    preview.extend(
+        content
+            .lines()
+            .skip(snippet.first)
+            .take(snippet.end - snippet.first)
+            .map(|line| format!("   {}", line)),
+    );
+    if snippet.skips_after() {
+        preview.push("   ...".to_string());
+    }
+    preview.join("\n")
 }
@@ -6,7 +6,7 @@
 //! With zero-ceremony mode, the server can auto-detect the project
 //! and auto-index on startup if needed.
 
-use anyhow::Result;
+use anyhow::{bail, Result};
 use std::env;
 use std::sync::Arc;
 use tokio::sync::oneshot;
@@ -17,9 +17,10 @@ use crate::auto_index::{AutoIndexPolicy, AutoIndexService};
 use crate::config::Config;
 use crate::embeddings::EmbeddingGenerator;
 use crate::mcp::{run_http_server, CodeRagServer, Transport};
-use crate::search::SearchEngine;
-use crate::storage::Storage;
+use crate::search::{SearchEngine, SynonymMap};
+use crate::storage::open_configured_store;
 use crate::symbol::SymbolIndex;
+use crate::web::SecurityPolicy;
 use crate::watcher::{FileWatcher, ProcessingStats, WatcherConfig};
 
 /// Default port for HTTP transport
@@ -44,17 +45,23 @@ const DEFAULT_HTTP_PORT: u16 = 3000;
 /// * `no_auto_index` - Skip auto-indexing on startup
 /// * `watch` - Start file watcher in parallel with MCP server
 /// * `debounce_ms` - Debounce delay in milliseconds for the file watcher
+/// * `read_only` - Never modify the index (implies `no_auto_index`, forbids `watch`)
 pub async fn run(
     http: bool,
     port: Option<u16>,
     no_auto_index: bool,
     watch: bool,
     debounce_ms: u64,
+    read_only: bool,
 ) -> Result<()> {
     let cwd = env::current_dir()?;
 
+    if read_only && watch {
+        bail!("--watch cannot be combined with --read-only");
+    }
+
     // Set up auto-index service with appropriate policy
-    let policy = if no_auto_index {
+    let policy = if no_auto_index || read_only {
         AutoIndexPolicy::Never
     } else {
         AutoIndexPolicy::OnMissing
@@ -71,11 +78,18 @@ pub async fn run(
     }
 
     // Load config from resolved storage location
-    let config = if result.storage.is_local() {
+    let mut config = if result.storage.is_local() {
         Config::load(result.storage.root())?
     } else {
         Config::default()
     };
+    if read_only {
+        config.server.security.read_only = true;
+    }
+    if config.server.security.read_only && watch {
+        bail!("--watch cannot be used when server.security.read_only is set");
+    }
+    let security = SecurityPolicy::from_config(&config.server.security)?;
 
     // Determine transport type based on --http flag
     let transport_type = if http {
@@ -93,18 +107,22 @@ pub async fn run(
 
     // Initialize storage using resolved path and embedding dimension
     let vector_dimension = embedder.embedding_dimension();
-    let storage = Arc::new(
-        Storage::new(result.storage.db_path(), vector_dimension)
-            .await
-            .map_err(|e| anyhow::anyhow!("Failed to initialize storage: {}", e))?,
-    );
+    let db_path = embedder.index_path(result.storage.db_path());
+    let storage = open_configured_store(&config.storage, &db_path, Some(vector_dimension))
+        .await
+        .map_err(|e| anyhow::anyhow!("Failed to initialize storage: {}", e))?;
 
     // Initialize search engine
-    let search_engine = Arc::new(SearchEngine::new(storage.clone(), embedder.clone()));
+    let mut search_engine = SearchEngine::new(storage.clone(), embedder.clone())
+        .with_summary_weight(config.search.summary_weight);
+    if config.search.expand_embedding_query {
+        search_engine = search_engine.with_synonyms(SynonymMap::new(&config.search.synonyms));
+    }
+    let search_engine = Arc::new(search_engine);
 
     // Build symbol index from stored chunks
     info!("Building symbol index from stored chunks...");
-    let chunks = storage.get_all_chunks().await?;
+    let chunks = storage.all_chunks().await?;
     let symbol_index = Arc::new(SymbolIndex::build_from_chunks(&chunks));
     info!("Symbol index ready with {} symbols", symbol_index.symbol_count());
 
@@ -119,6 +137,7 @@ pub async fn run(
                 project_root.clone(),
                 watcher_config,
                 storage.clone(),
+                db_path.clone(),
                 embedder.clone(),
                 config.clone(),
             );
@@ -140,13 +159,15 @@ pub async fn run(
     match transport_type {
         Transport::Stdio => {
             info!("Starting MCP server with stdio transport");
-            let server = CodeRagServer::new(search_engine, storage, symbol_index, project_root);
+            let server = CodeRagServer::new(search_engine, storage, symbol_index, project_root)
+                .with_max_limit(security.max_limit);
             server.run().await?;
         }
         Transport::Http => {
             let port = port.unwrap_or(DEFAULT_HTTP_PORT);
             info!("Starting MCP server with HTTP/SSE transport on port {}", port);
-            run_http_server(search_engine, storage, symbol_index, project_root, port).await?;
+            run_http_server(search_engine, storage, symbol_index, project_root, port, security)
+                .await?;
         }
     }
 
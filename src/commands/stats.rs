@@ -2,9 +2,10 @@
 
 use anyhow::{bail, Result};
 use std::env;
+use std::path::Path;
 
 use crate::metrics::{gather_metrics, MetricSnapshot, INDEXED_CHUNKS, INDEXED_FILES};
-use crate::storage::Storage;
+use crate::storage::open_existing_store;
 use crate::Config;
 
 /// Run the stats command
@@ -30,14 +31,8 @@ async fn run_human_readable() -> Result<()> {
 
     let config = Config::load(&root)?;
 
-    // Load storage to get current index statistics
-    // Note: For stats, we don't need the exact vector dimension as we're only reading metadata
-    let storage = Storage::new_with_default_dimension(&config.db_path(&root)).await?;
-
     // Get current index stats from storage
-    let total_chunks = storage.count_chunks().await?;
-    let files = storage.list_files(None).await?;
-    let total_files = files.len();
+    let (total_files, total_chunks) = index_counts(&config, &root).await?;
 
     // Update gauge metrics with current values from storage
     INDEXED_FILES.set(total_files as f64);
@@ -91,6 +86,20 @@ async fn run_human_readable() -> Result<()> {
     Ok(())
 }
 
+/// Files and chunks in the configured store, zero when nothing is indexed
+///
+/// The store is opened at its stored dimension, as we're only reading
+/// metadata.
+async fn index_counts(config: &Config, root: &Path) -> Result<(usize, usize)> {
+    match open_existing_store(&config.storage, &config.db_path(root)).await? {
+        Some(storage) => {
+            let stats = storage.stats().await?;
+            Ok((stats.files, stats.chunks))
+        }
+        None => Ok((0, 0)),
+    }
+}
+
 /// Run the stats command with Prometheus format output
 ///
 /// Outputs all metrics in Prometheus text exposition format,
@@ -105,10 +114,7 @@ pub async fn run_prometheus() -> Result<()> {
     let config = Config::load(&root)?;
 
     // Load storage to update gauge metrics with current values
-    // Note: For stats, we don't need the exact vector dimension as we're only reading metadata
-    let storage = Storage::new_with_default_dimension(&config.db_path(&root)).await?;
-    let total_chunks = storage.count_chunks().await?;
-    let total_files = storage.list_files(None).await?.len();
+    let (total_files, total_chunks) = index_counts(&config, &root).await?;
 
     // Update gauge metrics
     INDEXED_FILES.set(total_files as f64);
@@ -5,10 +5,11 @@
 
 use anyhow::Result;
 use std::env;
+use std::path::Path;
 
-use crate::auto_index::StorageResolver;
+use crate::auto_index::{AutoIndexService, StorageLocation, StorageResolver};
 use crate::project_detection::ProjectDetector;
-use crate::storage::Storage;
+use crate::storage::{open_existing_store, StoreStats};
 
 /// Run the status command.
 ///
@@ -39,25 +40,21 @@ pub async fn run() -> Result<()> {
                         }
                     );
                     println!("Index path: {}", storage.db_path().display());
-                    println!("Index exists: {}", storage.index_exists());
 
                     // If index exists, show statistics
-                    if storage.index_exists() {
-                        // Use default dimension since we're only reading metadata
-                        match Storage::new_with_default_dimension(storage.db_path()).await {
-                            Ok(db) => {
-                                let chunk_count = db.count_chunks().await.unwrap_or(0);
-                                let files = db.list_files(None).await.unwrap_or_default();
-
-                                println!();
-                                println!("Index statistics:");
-                                println!("  Files indexed: {}", files.len());
-                                println!("  Total chunks: {}", chunk_count);
-                            }
-                            Err(e) => {
-                                println!();
-                                println!("Could not read index statistics: {}", e);
-                            }
+                    match index_stats(&cwd, &storage).await {
+                        Ok(Some(stats)) => {
+                            println!("Index exists: true");
+                            println!();
+                            println!("Index statistics:");
+                            println!("  Files indexed: {}", stats.files);
+                            println!("  Total chunks: {}", stats.chunks);
+                        }
+                        Ok(None) => println!("Index exists: false"),
+                        Err(e) => {
+                            println!("Index exists: {}", storage.index_exists());
+                            println!();
+                            println!("Could not read index statistics: {}", e);
                         }
                     }
                 }
@@ -80,3 +77,14 @@ pub async fn run() -> Result<()> {
 
     Ok(())
 }
+
+/// Statistics of the project's index in the configured store; None when
+/// nothing has been indexed
+async fn index_stats(cwd: &Path, storage: &StorageLocation) -> Result<Option<StoreStats>> {
+    let config = AutoIndexService::new().project_config(cwd)?;
+    // Use the stored dimension since we're only reading metadata
+    match open_existing_store(&config.storage, storage.db_path()).await? {
+        Some(db) => Ok(Some(db.stats().await?)),
+        None => Ok(None),
+    }
+}
@@ -0,0 +1,175 @@
+//! Symbols command implementation.
+//!
+//! Lists indexed symbols with filters and sorting. This is a browse interface
+//! over the index metadata rather than a search, so no embeddings are needed.
+
+use anyhow::{anyhow, bail, Result};
+use std::env;
+use std::path::Path;
+
+use crate::auto_index::AutoIndexService;
+use crate::config::StorageConfig;
+use crate::storage::open_existing_store;
+use crate::symbol::{list_symbols, SymbolFilter, SymbolListing, SymbolSort};
+
+/// Run the symbols command.
+///
+/// # Arguments
+///
+/// * `lang` - Only list symbols in this language
+/// * `kind` - Only list symbols of this semantic kind
+/// * `sort` - Sort order: `name`, `size` or `complexity`
+/// * `limit` - Maximum number of symbols to list
+/// * `offset` - Number of symbols to skip
+/// * `json` - Print JSON instead of a table
+pub async fn run(
+    lang: Option<String>,
+    kind: Option<String>,
+    sort: &str,
+    limit: Option<usize>,
+    offset: usize,
+    json: bool,
+) -> Result<()> {
+    let sort = SymbolSort::parse(sort).ok_or_else(|| {
+        anyhow!(
+            "Unknown sort order '{}'. Use name, size or complexity",
+            sort
+        )
+    })?;
+
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+    let location = service.resolve_storage(&cwd)?;
+    let config = service.project_config(&cwd)?;
+
+    let filter = SymbolFilter {
+        language: lang,
+        kind,
+        sort,
+        offset,
+        limit,
+    };
+    let listing = load_listing(&config.storage, location.db_path(), &filter).await?;
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&listing)?);
+    } else {
+        print_table(&listing);
+    }
+
+    Ok(())
+}
+
+/// List the symbols of the index at `db_path` in the configured store
+///
+/// The index is opened with its stored dimension, since only metadata is
+/// read and the embedding model is irrelevant.
+async fn load_listing(
+    config: &StorageConfig,
+    db_path: &Path,
+    filter: &SymbolFilter,
+) -> Result<SymbolListing> {
+    let Some(storage) = open_existing_store(config, db_path).await? else {
+        bail!("No index found. Run 'coderag index' first.");
+    };
+    let chunks = storage.all_chunks().await?;
+    Ok(list_symbols(&chunks, filter))
+}
+
+/// Print a listing as an aligned table
+fn print_table(listing: &SymbolListing) {
+    if listing.symbols.is_empty() {
+        println!("No symbols found ({} total)", listing.total);
+        return;
+    }
+
+    let name_width = listing
+        .symbols
+        .iter()
+        .map(|s| s.name.len())
+        .max()
+        .unwrap_or(0)
+        .max("NAME".len());
+    let kind_width = listing
+        .symbols
+        .iter()
+        .map(|s| s.kind.len())
+        .max()
+        .unwrap_or(0)
+        .max("KIND".len());
+
+    println!(
+        "{:<name_width$}  {:<kind_width$}  {:<10}  {:>5}  {:>4}  LOCATION",
+        "NAME", "KIND", "LANGUAGE", "LINES", "CX"
+    );
+    for symbol in &listing.symbols {
+        println!(
+            "{:<name_width$}  {:<kind_width$}  {:<10}  {:>5}  {:>4}  {}:{}-{}",
+            symbol.name,
+            symbol.kind,
+            symbol.language.as_deref().unwrap_or("-"),
+            symbol.size,
+            symbol.complexity,
+            symbol.file_path,
+            symbol.start_line,
+            symbol.end_line
+        );
+    }
+
+    let shown_end = listing.offset + listing.symbols.len();
+    println!(
+        "\nShowing {}-{} of {} symbols",
+        listing.offset + 1,
+        shown_end,
+        listing.total
+    );
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::storage::{IndexedChunk, Storage};
+    use tempfile::tempdir;
+
+    #[tokio::test]
+    async fn test_load_listing_with_non_default_dimension() {
+        let dir = tempdir().unwrap();
+        let db_path = dir.path().join("index.lance");
+        let storage = Storage::new(&db_path, 768).await.unwrap();
+        storage
+            .insert_chunks(vec![IndexedChunk {
+                id: "lib.rs:1".to_string(),
+                content: "fn parse() {}".to_string(),
+                file_path: "lib.rs".to_string(),
+                start_line: 1,
+                end_line: 1,
+                language: Some("rust".to_string()),
+                vector: vec![0.1; 768],
+                mtime: 0,
+                file_header: None,
+                semantic_kind: Some("function".to_string()),
+                symbol_name: Some("parse".to_string()),
+                signature: None,
+                doc: None,
+                parent: None,
+                visibility: None,
+                qualified_name: None,
+                tags: Vec::new(),
+                branch: None,
+                duplicate_of: None,
+            }])
+            .await
+            .unwrap();
+
+        let listing = load_listing(
+            &StorageConfig::default(),
+            &db_path,
+            &SymbolFilter::default(),
+        )
+        .await
+        .unwrap();
+        assert_eq!(listing.total, 1);
+        assert_eq!(listing.symbols[0].name, "parse");
+        assert_eq!(listing.symbols[0].kind, "function");
+    }
+}
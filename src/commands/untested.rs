@@ -0,0 +1,69 @@
+//! Untested command implementation.
+//!
+//! Lists the exported functions and methods that no test calls, from the
+//! `tests` edges of the symbol graph, to prioritize test writing. Like
+//! `symbols`, this reads index metadata only, so no embeddings are needed.
+
+use anyhow::{bail, Result};
+use std::env;
+
+use crate::auto_index::AutoIndexService;
+use crate::symbol::{untested, GraphFilter, SymbolGraph, SymbolNode};
+
+/// Run the untested command.
+///
+/// # Arguments
+///
+/// * `path` - Only list symbols in files under this path
+/// * `lang` - Only list symbols in this language
+/// * `json` - Print JSON instead of a list
+pub async fn run(path: Option<String>, lang: Option<String>, json: bool) -> Result<()> {
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+    let location = service.resolve_storage(&cwd)?;
+    if !service.index_exists(&cwd).await? {
+        bail!("No index found. Run 'coderag index' first.");
+    }
+    let graph = match location.storage_dir() {
+        Some(dir) => SymbolGraph::load(dir)?,
+        None => SymbolGraph::default(),
+    };
+    if graph.nodes().next().is_none() {
+        bail!("The symbol graph records no symbols; re-run 'coderag index --force' to rebuild it");
+    }
+
+    let filter = GraphFilter {
+        path,
+        language: lang,
+        ..Default::default()
+    };
+    let symbols = untested(&graph, &filter);
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&symbols)?);
+    } else {
+        print_untested(&symbols);
+    }
+
+    Ok(())
+}
+
+/// Print the symbols, one `<path>:<line>  <kind>  <name>` line each
+fn print_untested(symbols: &[&SymbolNode]) {
+    if symbols.is_empty() {
+        println!("Every exported function is called by a test");
+        return;
+    }
+
+    println!("{} untested exported functions", symbols.len());
+    for symbol in symbols {
+        println!(
+            "  {}:{}  {}  {}",
+            symbol.file,
+            symbol.line,
+            symbol.kind.as_deref().unwrap_or_default(),
+            symbol.qualified_name.as_ref().unwrap_or(&symbol.name)
+        );
+    }
+    println!("\nOnly direct calls from tests count; see 'coderag untested --help'");
+}
@@ -0,0 +1,200 @@
+//! Validate command implementation.
+//!
+//! Checks the integrity of the current project's index (see
+//! [`crate::storage::integrity`]) and, with `--fix`, applies the repairs
+//! that are safe: dropping the chunks of inconsistent files so they are
+//! re-indexed, rebuilding the BM25 index and symbol graph from the store,
+//! and dropping graph edges to symbols that are no longer stored.
+
+use anyhow::{bail, Result};
+use std::env;
+use std::path::Path;
+
+use crate::auto_index::{AutoIndexService, StorageLocation};
+use crate::embeddings::model_dimension;
+use crate::search::bm25::Bm25Index;
+use crate::storage::integrity::{self, Issue, Repair, Severity};
+use crate::storage::{open_existing_store, VectorStore};
+use crate::symbol::graph::GRAPH_FILE;
+use crate::symbol::{CounterpartMatch, SymbolGraph};
+use crate::Config;
+
+/// Run the validate command.
+///
+/// Fails when errors remain after any repairs, so it can gate CI.
+///
+/// # Arguments
+///
+/// * `fix` - Apply the safe repairs
+/// * `json` - Print the issues as JSON
+pub async fn run(fix: bool, json: bool) -> Result<()> {
+    let cwd = env::current_dir()?;
+    let service = AutoIndexService::new();
+    let location = service.resolve_storage(&cwd)?;
+    let config = if location.is_local() {
+        Config::load(location.root())?
+    } else {
+        Config::default()
+    };
+
+    // Open the store with the dimension it was written with, so a mismatch
+    // with the configured model is reported instead of failing to open
+    let Some(storage) = open_existing_store(&config.storage, location.db_path()).await? else {
+        bail!("No index found. Run 'coderag index' first.");
+    };
+    let dimension = storage.vector_dimension();
+
+    let chunks = storage.all_chunks().await?;
+    let vectors = storage.vectors_by_id().await?;
+    let bm25_dir = location
+        .bm25_path()
+        .parent()
+        .unwrap_or(location.bm25_path());
+    let bm25_ids = if Bm25Index::exists(bm25_dir) {
+        Some(Bm25Index::new(bm25_dir)?.document_ids()?)
+    } else {
+        None
+    };
+
+    // The saved graph is compared with one built from the stored chunks
+    let stored_graph = SymbolGraph::build(&chunks);
+    let saved_graph = match location.storage_dir() {
+        Some(dir) if dir.join(GRAPH_FILE).exists() => Some(SymbolGraph::load(dir)?),
+        _ => None,
+    };
+
+    let model = config.embeddings.model_name();
+    let mut issues =
+        integrity::check_dimension(dimension, model, model_dimension(&config.embeddings, model));
+    issues.extend(integrity::check_chunks(&chunks, &vectors, dimension));
+    issues.extend(integrity::check_bm25(&chunks, bm25_ids.as_deref()));
+    if location.storage_dir().is_some() {
+        issues.extend(integrity::check_graph(&stored_graph, saved_graph.as_ref()));
+    }
+
+    if json {
+        println!("{}", serde_json::to_string_pretty(&issues)?);
+    } else {
+        print_issues(&issues, chunks.len(), fix);
+    }
+
+    let mut remaining: Vec<&Issue> = issues.iter().collect();
+    if fix {
+        let repairs = integrity::repairs(&issues);
+        apply_repairs(
+            &service,
+            storage.as_ref(),
+            &location,
+            &repairs,
+            &stored_graph,
+            config.indexer.counterparts,
+        )
+        .await?;
+        remaining.retain(|issue| issue.repair.is_none());
+    }
+
+    let errors = remaining
+        .iter()
+        .filter(|i| i.severity == Severity::Error)
+        .count();
+    if errors > 0 {
+        bail!("Index validation failed with {} error(s)", errors);
+    }
+    Ok(())
+}
+
+/// Apply repairs, dropping files before rebuilding the BM25 index and the
+/// symbol graph from the resulting store
+///
+/// Orphaned edges are dropped from the saved graph only when it is not
+/// rebuilt anyway; `stored_graph` is the graph of the stored chunks.
+async fn apply_repairs(
+    service: &AutoIndexService,
+    storage: &dyn VectorStore,
+    location: &StorageLocation,
+    repairs: &[Repair],
+    stored_graph: &SymbolGraph,
+    counterparts: CounterpartMatch,
+) -> Result<()> {
+    let mut dropped = 0;
+    for repair in repairs {
+        if let Repair::DropFile(path) = repair {
+            storage.delete_file(Path::new(path)).await?;
+            dropped += 1;
+        }
+    }
+
+    let rebuild = dropped > 0
+        || repairs.contains(&Repair::RebuildBm25)
+        || repairs.contains(&Repair::RebuildGraph);
+    if rebuild {
+        service
+            .build_derived_indexes(storage, location, counterparts)
+            .await?;
+        // Dropping every chunk leaves nothing to rebuild from
+        if storage.stats().await?.chunks == 0 {
+            let bm25_dir = location
+                .bm25_path()
+                .parent()
+                .unwrap_or(location.bm25_path());
+            Bm25Index::new(bm25_dir)?.clear()?;
+        }
+        eprintln!("Rebuilt the BM25 index and the symbol graph");
+    } else if repairs.contains(&Repair::DropOrphanedEdges) {
+        if let Some(dir) = location.storage_dir() {
+            let mut graph = SymbolGraph::load(dir)?;
+            let edges = integrity::drop_orphaned_edges(&mut graph, stored_graph);
+            graph.save(dir)?;
+            eprintln!("Dropped {} orphaned symbol graph edge(s)", edges);
+        }
+    }
+    if dropped > 0 {
+        eprintln!(
+            "Dropped the chunks of {} file(s); run 'coderag index' to re-index them \
+             ('coderag index --branch <ref>' for files of a git ref)",
+            dropped
+        );
+    }
+    Ok(())
+}
+
+/// Print issues as `<severity> [<check>] <message>` lines with a summary
+fn print_issues(issues: &[Issue], chunk_count: usize, fix: bool) {
+    println!("Checked {} chunks", chunk_count);
+    if issues.is_empty() {
+        println!("No issues found");
+        return;
+    }
+
+    println!();
+    for issue in issues {
+        let fixable = if issue.repair.is_some() {
+            " (fixable)"
+        } else {
+            ""
+        };
+        println!(
+            "{:<7} [{}] {}{}",
+            issue.severity.as_str().to_uppercase(),
+            issue.check,
+            issue.message,
+            fixable
+        );
+    }
+
+    let errors = issues
+        .iter()
+        .filter(|i| i.severity == Severity::Error)
+        .count();
+    let fixable = issues.iter().filter(|i| i.repair.is_some()).count();
+    println!(
+        "\n{} issue(s): {} error(s), {} warning(s); {} fixable",
+        issues.len(),
+        errors,
+        issues.len() - errors,
+        fixable
+    );
+    if fixable > 0 && !fix {
+        println!("Run 'coderag validate --fix' to repair them");
+    }
+}
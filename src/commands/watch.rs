@@ -10,7 +10,7 @@ use tokio::sync::oneshot;
 use tracing::info;
 
 use crate::embeddings::EmbeddingGenerator;
-use crate::storage::Storage;
+use crate::storage::open_configured_store;
 use crate::watcher::{FileWatcher, WatcherConfig};
 use crate::Config;
 
@@ -38,7 +38,8 @@ pub async fn run(debounce_ms: u64) -> Result<()> {
     // Initialize components
     let embedder = Arc::new(EmbeddingGenerator::new_async(&config.embeddings).await?);
     let vector_dimension = embedder.embedding_dimension();
-    let storage = Arc::new(Storage::new(&config.db_path(&root), vector_dimension).await?);
+    let db_path = embedder.index_path(&config.db_path(&root));
+    let storage = open_configured_store(&config.storage, &db_path, Some(vector_dimension)).await?;
 
     // Create watcher config
     let watcher_config = WatcherConfig::from_config(&config, debounce_ms);
@@ -48,6 +49,7 @@ pub async fn run(debounce_ms: u64) -> Result<()> {
         root,
         watcher_config,
         storage,
+        db_path,
         embedder,
         config,
     );
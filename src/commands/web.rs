@@ -9,8 +9,11 @@ use std::sync::Arc;
 
 use crate::config::SearchMode;
 use crate::embeddings::EmbeddingGenerator;
-use crate::search::{HybridSearch, SearchEngine};
-use crate::storage::Storage;
+use crate::search::{
+    CandidatePool, HybridSearch, KindPreference, ProcessedSearch, SearchEngine, SynonymMap,
+    TermBoosts,
+};
+use crate::storage::open_configured_store;
 use crate::web::{AppState, WebServer};
 use crate::Config;
 
@@ -18,33 +21,49 @@ use crate::Config;
 ///
 /// # Arguments
 /// * `port` - The port to listen on (default: 8080)
-pub async fn run(port: u16) -> Result<()> {
+/// * `read_only` - Disable endpoints that modify the index
+pub async fn run(port: u16, read_only: bool) -> Result<()> {
     let root = env::current_dir()?;
 
     if !Config::is_initialized(&root) {
         bail!("CodeRAG is not initialized. Run 'coderag init' first.");
     }
 
-    let config = Config::load(&root)?;
+    let mut config = Config::load(&root)?;
+    if read_only {
+        config.server.security.read_only = true;
+    }
 
     // Initialize the embedding generator first to get vector dimension
     let embedder = Arc::new(EmbeddingGenerator::new_async(&config.embeddings).await?);
     let vector_dimension = embedder.embedding_dimension();
 
     // Check if there's any indexed data
-    let storage = Arc::new(Storage::new(&config.db_path(&root), vector_dimension).await?);
-    let chunk_count = storage.count_chunks().await?;
+    let db_path = embedder.index_path(&config.db_path(&root));
+    let storage = open_configured_store(&config.storage, &db_path, Some(vector_dimension)).await?;
+    let chunk_count = storage.stats().await?.chunks;
 
     if chunk_count == 0 {
         println!("Warning: No indexed data found. Run 'coderag index' first.");
         println!("The web UI will still start, but search will return no results.\n");
     }
 
+    // Vector-only engines use the synonym map only for the embedded text
+    let synonyms = SynonymMap::new(&config.search.synonyms);
+    let term_boosts = TermBoosts::new(&config.search.term_boosts)?;
+    let vector_engine = || {
+        let engine = SearchEngine::new(Arc::clone(&storage), Arc::clone(&embedder))
+            .with_summary_weight(config.search.summary_weight);
+        if config.search.expand_embedding_query {
+            engine.with_synonyms(synonyms.clone())
+        } else {
+            engine
+        }
+    };
+
     // Create the search engine based on configured mode
     let search_engine: Arc<dyn crate::search::traits::Search> = match config.search.mode {
-        SearchMode::Vector => {
-            Arc::new(SearchEngine::new(Arc::clone(&storage), Arc::clone(&embedder)))
-        }
+        SearchMode::Vector => Arc::new(vector_engine()),
         SearchMode::Hybrid | SearchMode::Bm25 => {
             // For hybrid or BM25 mode, use HybridSearch
             let coderag_dir = Config::coderag_dir(&root);
@@ -55,27 +74,35 @@ pub async fn run(port: u16) -> Result<()> {
                 config.search.vector_weight,
                 config.search.bm25_weight,
             ) {
-                Ok(hybrid) => Arc::new(hybrid.with_rrf_k(config.search.rrf_k)),
+                Ok(hybrid) => Arc::new(
+                    hybrid
+                        .with_rrf_k(config.search.rrf_k)
+                        .with_synonyms(synonyms.clone(), config.search.expand_embedding_query)
+                        .with_term_boosts(term_boosts)
+                        .with_candidates(CandidatePool::from_config(&config.search)),
+                ),
                 Err(e) => {
                     // Fall back to vector search if hybrid fails
                     tracing::warn!(
                         "Failed to initialize hybrid search, falling back to vector: {}",
                         e
                     );
-                    Arc::new(SearchEngine::new(Arc::clone(&storage), Arc::clone(&embedder)))
+                    Arc::new(vector_engine())
                 }
             }
         }
     };
 
+    // Rank preferred kinds first before results are returned
+    let search_engine = Arc::new(ProcessedSearch::new(search_engine).with_processor(
+        KindPreference::new(
+            config.search.kind_preference.clone(),
+            config.search.kind_boost,
+        ),
+    ));
+
     // Create the application state
-    let state = AppState::new(
-        search_engine,
-        storage,
-        embedder,
-        config,
-        root,
-    );
+    let state = AppState::new(search_engine, storage, embedder, config, root)?;
 
     // Start the web server
     let server = WebServer::new(state);
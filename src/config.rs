@@ -1,8 +1,17 @@
 use anyhow::{Context, Result};
 use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
 use std::path::{Path, PathBuf};
 
-use crate::indexer::ChunkerStrategy;
+use crate::indexer::context_header::HEADER_TOKEN_RESERVE;
+use crate::indexer::imports::ImportContext;
+use crate::indexer::{
+    ChunkerStrategy, Granularity, SymbolCapPolicy, SymlinkPolicy, DEFAULT_MAX_MERGED_TOKENS,
+    DEFAULT_MAX_SYMBOLS_PER_FILE,
+};
+use crate::search::candidates::{CandidateStrategy, DEFAULT_MAX_CANDIDATES, DEFAULT_SCORE_GAP};
+use crate::search::snippet::{SnippetAnchor, DEFAULT_SNIPPET_CONTEXT};
+use crate::symbol::CounterpartMatch;
 
 const CONFIG_DIR: &str = ".coderag";
 const CONFIG_FILE: &str = "config.toml";
@@ -26,6 +35,18 @@ pub struct Config {
 
     #[serde(default)]
     pub logging: LoggingConfig,
+
+    #[serde(default)]
+    pub watcher: WatchConfig,
+
+    /// Offline mode: refuse every backend that sends code over the network
+    #[serde(default)]
+    pub offline: bool,
+
+    /// Throughput preset applied under this config: "cheap", "balanced" or
+    /// "fast" (see [`crate::profile`])
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub profile: Option<String>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -54,6 +75,10 @@ pub struct IndexerConfig {
     #[serde(default = "default_max_chunk_tokens")]
     pub max_chunk_tokens: usize,
 
+    /// Size in tokens that consecutive small units are merged up to
+    #[serde(default = "default_max_merged_tokens")]
+    pub max_merged_tokens: usize,
+
     /// Number of parallel threads for indexing (None = auto-detect)
     #[serde(default)]
     pub parallel_threads: Option<usize>,
@@ -65,6 +90,104 @@ pub struct IndexerConfig {
     /// Maximum number of concurrent file operations
     #[serde(default = "default_max_concurrent_files")]
     pub max_concurrent_files: usize,
+
+    /// Include comments in the text sent to the embedding model.
+    /// When false, comments are stripped from the embedding input only;
+    /// stored chunks keep them and BM25 still indexes them.
+    #[serde(default = "default_embed_comments")]
+    pub embed_comments: bool,
+
+    /// Start the text embedded for each chunk with a header naming its
+    /// file, package, enclosing type and, for later parts of a split
+    /// function, its signature
+    #[serde(default = "default_context_headers")]
+    pub context_headers: bool,
+
+    /// Import statements to end the text embedded for each chunk with:
+    /// "off" (default), "referenced" (those binding a name the chunk uses)
+    /// or "all"
+    #[serde(default)]
+    pub import_context: ImportContext,
+
+    /// Also embed each symbol's name variants (qualified, bare and
+    /// subword-split) as short vectors for `search --field name`
+    #[serde(default)]
+    pub embed_name_variants: bool,
+
+    /// Also embed the comments inside each symbol's body as a separate
+    /// field for `search --field comments`
+    #[serde(default)]
+    pub embed_comment_field: bool,
+
+    /// Also embed each chunk's doc, body and structure fields as separate
+    /// vectors for `search --weights`
+    #[serde(default)]
+    pub embed_weighted_fields: bool,
+
+    /// Symlink handling: "skip" (default), "follow" or "dedup-by-realpath"
+    #[serde(default)]
+    pub symlinks: SymlinkPolicy,
+
+    /// Directories nested deeper than this below the root are skipped
+    #[serde(default = "default_max_depth")]
+    pub max_depth: usize,
+
+    /// Paths longer than this many bytes are skipped
+    #[serde(default = "default_max_path_length")]
+    pub max_path_length: usize,
+
+    /// Feature-flag accessors whose string-literal argument is recorded as
+    /// a `flag:<name>` tag (e.g. `flags.Enabled`)
+    #[serde(default = "default_flag_accessors")]
+    pub flag_accessors: Vec<String>,
+
+    /// Keys of `Key: Value` lines in a file's leading comment block that
+    /// are recorded as `meta:<key>=<value>` tags on all its chunks
+    #[serde(default = "default_header_keys")]
+    pub header_keys: Vec<String>,
+
+    /// Link same-named exported types across languages in the symbol graph:
+    /// "off", "exact" or "normalized" (ignoring case, `_` and `-`)
+    #[serde(default)]
+    pub counterparts: CounterpartMatch,
+
+    /// Index only exported symbols' signatures and docs (API-surface mode).
+    /// Implies AST chunking; files without an extractor are skipped.
+    #[serde(default)]
+    pub api_surface: bool,
+
+    /// Files with more symbols than this (typically machine-generated) are
+    /// handled by `symbol_cap_policy` instead of being fully indexed
+    #[serde(default = "default_max_symbols_per_file")]
+    pub max_symbols_per_file: usize,
+
+    /// What to do with files over the symbol cap: "summarize" (index only
+    /// top-level exported signatures) or "skip"
+    #[serde(default)]
+    pub symbol_cap_policy: SymbolCapPolicy,
+
+    /// Buffer sizes between the indexing stages
+    #[serde(default)]
+    pub pipeline: PipelineConfig,
+
+    /// Overlapping windows that files without a parser are chunked into
+    #[serde(default)]
+    pub window: WindowConfig,
+
+    /// Natural-language summaries of chunks, written by an LLM and embedded
+    /// as a separate field
+    #[serde(default)]
+    pub summaries: SummaryConfig,
+
+    /// Chunk granularity by language and path; the first matching rule
+    /// applies, and files no rule matches are chunked by function
+    #[serde(default)]
+    pub granularity: Vec<GranularityRule>,
+
+    /// Tree-sitter grammars loaded at runtime, for languages without
+    /// built-in support
+    #[serde(default)]
+    pub grammars: Vec<GrammarConfig>,
 }
 
 impl Default for IndexerConfig {
@@ -76,10 +199,47 @@ impl Default for IndexerConfig {
             chunker_strategy: ChunkerStrategy::default(),
             min_chunk_tokens: default_min_chunk_tokens(),
             max_chunk_tokens: default_max_chunk_tokens(),
+            max_merged_tokens: default_max_merged_tokens(),
             parallel_threads: None,
             file_batch_size: default_file_batch_size(),
             max_concurrent_files: default_max_concurrent_files(),
+            embed_comments: default_embed_comments(),
+            context_headers: default_context_headers(),
+            import_context: ImportContext::default(),
+            embed_name_variants: false,
+            embed_comment_field: false,
+            embed_weighted_fields: false,
+            symlinks: SymlinkPolicy::default(),
+            max_depth: default_max_depth(),
+            max_path_length: default_max_path_length(),
+            flag_accessors: default_flag_accessors(),
+            header_keys: default_header_keys(),
+            counterparts: CounterpartMatch::default(),
+            api_surface: false,
+            max_symbols_per_file: default_max_symbols_per_file(),
+            symbol_cap_policy: SymbolCapPolicy::default(),
+            pipeline: PipelineConfig::default(),
+            window: WindowConfig::default(),
+            summaries: SummaryConfig::default(),
+            granularity: Vec::new(),
+            grammars: Vec::new(),
+        }
+    }
+}
+
+impl IndexerConfig {
+    /// Extensions of the files to index: `extensions` and those of the
+    /// runtime grammars.
+    pub fn indexed_extensions(&self) -> Vec<String> {
+        let mut extensions = self.extensions.clone();
+        for grammar in &self.grammars {
+            for extension in &grammar.extensions {
+                if !extensions.contains(extension) {
+                    extensions.push(extension.clone());
+                }
+            }
         }
+        extensions
     }
 }
 
@@ -91,6 +251,10 @@ fn default_max_chunk_tokens() -> usize {
     1500
 }
 
+fn default_max_merged_tokens() -> usize {
+    DEFAULT_MAX_MERGED_TOKENS
+}
+
 fn default_extensions() -> Vec<String> {
     vec![
         "rs".to_string(),
@@ -108,6 +272,26 @@ fn default_extensions() -> Vec<String> {
         "h".to_string(),
         "hpp".to_string(),
         "hxx".to_string(),
+        "cs".to_string(),
+        "rb".to_string(),
+        "php".to_string(),
+        "kt".to_string(),
+        "swift".to_string(),
+        "scala".to_string(),
+        "sql".to_string(),
+        "tf".to_string(),
+        "proto".to_string(),
+        "graphql".to_string(),
+        "gql".to_string(),
+        "md".to_string(),
+        "yaml".to_string(),
+        "yml".to_string(),
+        "dockerfile".to_string(),
+        "sh".to_string(),
+        "bash".to_string(),
+        "vue".to_string(),
+        "svelte".to_string(),
+        "ipynb".to_string(),
     ]
 }
 
@@ -136,6 +320,290 @@ fn default_max_concurrent_files() -> usize {
     50
 }
 
+fn default_embed_comments() -> bool {
+    true
+}
+
+fn default_context_headers() -> bool {
+    true
+}
+
+fn default_flag_accessors() -> Vec<String> {
+    vec![
+        "flags.Enabled".to_string(),
+        "flags.IsEnabled".to_string(),
+        "featureflag.Get".to_string(),
+        "isFeatureEnabled".to_string(),
+        "unleash.isEnabled".to_string(),
+    ]
+}
+
+fn default_header_keys() -> Vec<String> {
+    vec![
+        "SPDX-License-Identifier".to_string(),
+        "Owner".to_string(),
+        "Module".to_string(),
+    ]
+}
+
+fn default_max_symbols_per_file() -> usize {
+    DEFAULT_MAX_SYMBOLS_PER_FILE
+}
+
+fn default_max_depth() -> usize {
+    64
+}
+
+fn default_max_path_length() -> usize {
+    4096
+}
+
+/// Channel capacities between the indexing stages, in batches.
+///
+/// A stage whose output channel is full waits for the next stage, so these
+/// bound how much read, chunked or embedded data is held in memory at once.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PipelineConfig {
+    /// Batches of file contents (`file_batch_size` files each) waiting to be
+    /// chunked
+    #[serde(default = "default_stage_buffer")]
+    pub read_buffer: usize,
+
+    /// Batches of chunks waiting to be embedded
+    #[serde(default = "default_stage_buffer")]
+    pub chunk_buffer: usize,
+
+    /// Batches of embedded chunks waiting to be stored
+    #[serde(default = "default_stage_buffer")]
+    pub embed_buffer: usize,
+}
+
+impl Default for PipelineConfig {
+    fn default() -> Self {
+        Self {
+            read_buffer: default_stage_buffer(),
+            chunk_buffer: default_stage_buffer(),
+            embed_buffer: default_stage_buffer(),
+        }
+    }
+}
+
+fn default_stage_buffer() -> usize {
+    2
+}
+
+/// Sliding windows for files in formats without a parser, such as logs,
+/// templates or unknown config formats, in the AST strategy.
+///
+/// Each window holds whole lines, up to `tokens` tokens, and starts with
+/// the last `overlap` tokens of the previous window, so text near a window
+/// edge is retrievable with its context.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct WindowConfig {
+    /// Size of a window, capped at the embedding model's input limit
+    #[serde(default = "default_window_tokens")]
+    pub tokens: usize,
+
+    /// Tokens repeated from the previous window, at most half a window
+    #[serde(default = "default_window_overlap")]
+    pub overlap: usize,
+}
+
+impl Default for WindowConfig {
+    fn default() -> Self {
+        Self {
+            tokens: default_window_tokens(),
+            overlap: default_window_overlap(),
+        }
+    }
+}
+
+fn default_window_tokens() -> usize {
+    512
+}
+
+fn default_window_overlap() -> usize {
+    64
+}
+
+/// Chunk summaries written by an LLM behind an OpenAI-compatible chat
+/// completions API, embedded as the `summary` field for
+/// `search --field summary` and `search.summary_weight`.
+///
+/// ```toml
+/// [indexer.summaries]
+/// enabled = true
+/// model = "gpt-4o-mini"
+/// # base_url = "http://localhost:11434/v1"  # e.g. Ollama
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SummaryConfig {
+    /// Summarize chunks while indexing
+    #[serde(default)]
+    pub enabled: bool,
+
+    /// Chat model writing the summaries
+    #[serde(default = "default_summary_model")]
+    pub model: String,
+
+    /// API key, or a `${VAR}` reference; `OPENAI_API_KEY` when empty
+    #[serde(default)]
+    pub api_key: String,
+
+    /// Base URL of an OpenAI-compatible API; OpenAI when unset
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub base_url: Option<String>,
+
+    /// Upper bound on the length of a summary, in tokens
+    #[serde(default = "default_summary_max_tokens")]
+    pub max_tokens: u16,
+
+    /// Chunks with fewer lines are not summarized
+    #[serde(default = "default_summary_min_lines")]
+    pub min_lines: usize,
+
+    /// Summary requests in flight at once
+    #[serde(default = "default_summary_concurrency")]
+    pub concurrency: usize,
+}
+
+impl Default for SummaryConfig {
+    fn default() -> Self {
+        Self {
+            enabled: false,
+            model: default_summary_model(),
+            api_key: String::new(),
+            base_url: None,
+            max_tokens: default_summary_max_tokens(),
+            min_lines: default_summary_min_lines(),
+            concurrency: default_summary_concurrency(),
+        }
+    }
+}
+
+impl SummaryConfig {
+    /// The configured API key, resolving `${VAR}` references and falling
+    /// back to `OPENAI_API_KEY`
+    pub fn load_api_key(&self) -> Result<String> {
+        if let Some(var) = self
+            .api_key
+            .strip_prefix("${")
+            .and_then(|rest| rest.strip_suffix('}'))
+        {
+            return std::env::var(var)
+                .with_context(|| format!("Environment variable {} not set", var));
+        }
+        if !self.api_key.is_empty() {
+            return Ok(self.api_key.clone());
+        }
+        std::env::var("OPENAI_API_KEY")
+            .context("No summary API key configured and OPENAI_API_KEY is not set")
+    }
+}
+
+fn default_summary_model() -> String {
+    "gpt-4o-mini".to_string()
+}
+
+fn default_summary_max_tokens() -> u16 {
+    120
+}
+
+fn default_summary_min_lines() -> usize {
+    3
+}
+
+fn default_summary_concurrency() -> usize {
+    4
+}
+
+/// Chunk granularity of the files matching a rule.
+///
+/// ```toml
+/// [[indexer.granularity]]
+/// paths = ["internal/util/**"]
+/// level = "file"
+///
+/// [[indexer.granularity]]
+/// languages = ["java", "csharp"]
+/// level = "type"
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct GranularityRule {
+    /// Languages the rule applies to, e.g. "go"; all when empty
+    #[serde(default)]
+    pub languages: Vec<String>,
+
+    /// Globs of the paths, relative to the root, the rule applies to; all
+    /// when empty
+    #[serde(default)]
+    pub paths: Vec<String>,
+
+    /// "function", "type" (methods stay in their class) or "file" (one
+    /// chunk per file that fits in one)
+    pub level: Granularity,
+}
+
+/// A Tree-sitter grammar loaded from a compiled shared library, and the
+/// node types of its syntax tree that become chunks.
+///
+/// ```toml
+/// [[indexer.grammars]]
+/// name = "zig"
+/// extensions = ["zig"]
+/// library = "/usr/local/lib/libtree-sitter-zig.so"
+///
+/// [indexer.grammars.nodes]
+/// function_declaration = "function"
+/// test_declaration = "test"
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct GrammarConfig {
+    /// Language identifier given to the chunks, e.g. "zig"
+    pub name: String,
+
+    /// Extensions of the language's files, without the dot. They are
+    /// indexed without being listed in `extensions`.
+    pub extensions: Vec<String>,
+
+    /// Shared library (`.so`, `.dylib` or `.dll`) built from the grammar
+    pub library: PathBuf,
+
+    /// Function the library exports the language from, by default
+    /// `tree_sitter_<name>`
+    #[serde(default)]
+    pub symbol: Option<String>,
+
+    /// Semantic kind ("function", "struct", "module", ...) of each node type
+    /// that becomes a chunk
+    pub nodes: BTreeMap<String, String>,
+
+    /// Field of a node holding its name
+    #[serde(default = "default_grammar_name_field")]
+    pub name_field: String,
+
+    /// Separator between the parts of qualified names
+    #[serde(default = "default_grammar_separator")]
+    pub separator: String,
+}
+
+impl GrammarConfig {
+    /// Name of the function exporting the language
+    pub fn symbol(&self) -> String {
+        self.symbol
+            .clone()
+            .unwrap_or_else(|| format!("tree_sitter_{}", self.name.replace('-', "_")))
+    }
+}
+
+fn default_grammar_name_field() -> String {
+    "name".to_string()
+}
+
+fn default_grammar_separator() -> String {
+    ".".to_string()
+}
+
 /// Embedding provider type
 #[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize, Default)]
 #[serde(rename_all = "lowercase")]
@@ -145,6 +613,40 @@ pub enum EmbeddingProvider {
     FastEmbed,
     /// OpenAI API embeddings
     OpenAI,
+    /// Embeddings from an Ollama server, usually on this machine
+    Ollama,
+    /// Azure OpenAI embeddings from a deployment
+    Azure,
+    /// AWS Bedrock embeddings (Amazon Titan or Cohere models)
+    Bedrock,
+    /// Google Gemini embeddings, from the Gemini API or Vertex AI
+    Gemini,
+    /// Voyage AI embeddings, e.g. the code-tuned voyage-code-3
+    Voyage,
+    /// Cohere embeddings, e.g. embed-v4.0
+    Cohere,
+    /// A local ONNX model run in-process, from a model directory
+    Onnx,
+    /// A Hugging Face Text Embeddings Inference server
+    Tei,
+}
+
+impl EmbeddingProvider {
+    /// Name of the provider as written in the config
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::FastEmbed => "fastembed",
+            Self::OpenAI => "openai",
+            Self::Ollama => "ollama",
+            Self::Azure => "azure",
+            Self::Bedrock => "bedrock",
+            Self::Gemini => "gemini",
+            Self::Voyage => "voyage",
+            Self::Cohere => "cohere",
+            Self::Onnx => "onnx",
+            Self::Tei => "tei",
+        }
+    }
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -153,6 +655,12 @@ pub struct EmbeddingsConfig {
     #[serde(default)]
     pub provider: EmbeddingProvider,
 
+    /// Providers to fall back to, in order, when `provider` cannot be
+    /// reached at startup or a request to it fails. Each uses its own
+    /// settings in this section and keeps its vectors in a separate index.
+    #[serde(default)]
+    pub fallback_providers: Vec<EmbeddingProvider>,
+
     /// Embedding model name
     #[serde(default = "default_model")]
     pub model: String,
@@ -161,7 +669,8 @@ pub struct EmbeddingsConfig {
     #[serde(default = "default_batch_size")]
     pub batch_size: usize,
 
-    /// OpenAI API key (can use ${OPENAI_API_KEY} for env var)
+    /// OpenAI API key (can use ${OPENAI_API_KEY} for env var); optional with
+    /// `openai_base_url`
     #[serde(default)]
     pub openai_api_key: Option<String>,
 
@@ -169,20 +678,370 @@ pub struct EmbeddingsConfig {
     #[serde(default = "default_openai_model")]
     pub openai_model: String,
 
-    /// OpenAI API base URL (for proxies like aitunnel, azure, etc.)
+    /// Base URL of an OpenAI-compatible API (vLLM, LM Studio, a LiteLLM
+    /// proxy, Azure, etc.)
     #[serde(default)]
     pub openai_base_url: Option<String>,
+
+    /// Ollama server URL (default: http://localhost:11434)
+    #[serde(default = "default_ollama_host")]
+    pub ollama_host: String,
+
+    /// Ollama embedding model (default: nomic-embed-text)
+    #[serde(default = "default_ollama_model")]
+    pub ollama_model: String,
+
+    /// How long Ollama keeps the model loaded between requests, e.g. "30m",
+    /// or "-1" for as long as the server runs (default: the server's)
+    #[serde(default)]
+    pub ollama_keep_alive: Option<String>,
+
+    /// Azure OpenAI resource endpoint, e.g. https://my-resource.openai.azure.com
+    #[serde(default)]
+    pub azure_endpoint: Option<String>,
+
+    /// Azure OpenAI deployment of the embedding model
+    #[serde(default)]
+    pub azure_deployment: Option<String>,
+
+    /// Azure OpenAI REST API version (default: 2024-02-01)
+    #[serde(default = "default_azure_api_version")]
+    pub azure_api_version: String,
+
+    /// How requests to Azure OpenAI authenticate (default: api-key)
+    #[serde(default)]
+    pub azure_auth: AzureAuth,
+
+    /// Azure OpenAI API key (can use ${VAR}; default: $AZURE_OPENAI_API_KEY)
+    #[serde(default)]
+    pub azure_api_key: Option<String>,
+
+    /// Bedrock model id, cross-region inference profile id or inference
+    /// profile ARN (default: amazon.titan-embed-text-v2:0)
+    #[serde(default = "default_bedrock_model")]
+    pub bedrock_model: String,
+
+    /// AWS region of the Bedrock endpoint (default: from the AWS config
+    /// chain, e.g. AWS_REGION)
+    #[serde(default)]
+    pub bedrock_region: Option<String>,
+
+    /// Named AWS profile whose credentials are used (default: AWS_PROFILE
+    /// or "default")
+    #[serde(default)]
+    pub bedrock_profile: Option<String>,
+
+    /// Gemini embedding model (default: gemini-embedding-001)
+    #[serde(default = "default_gemini_model")]
+    pub gemini_model: String,
+
+    /// Gemini API key (can use ${VAR}; default: $GEMINI_API_KEY or
+    /// $GOOGLE_API_KEY); unused with Vertex AI
+    #[serde(default)]
+    pub gemini_api_key: Option<String>,
+
+    /// Reduced Gemini output dimensionality, e.g. 768 (default: the model's)
+    #[serde(default)]
+    pub gemini_dimensions: Option<u32>,
+
+    /// Task type Gemini embeds queries with (default: RETRIEVAL_QUERY);
+    /// chunks are embedded as RETRIEVAL_DOCUMENT
+    #[serde(default = "default_gemini_query_task_type")]
+    pub gemini_query_task_type: String,
+
+    /// Google Cloud project to embed with through Vertex AI instead of the
+    /// Gemini API
+    #[serde(default)]
+    pub gemini_vertex_project: Option<String>,
+
+    /// Vertex AI location (default: us-central1)
+    #[serde(default = "default_gemini_vertex_location")]
+    pub gemini_vertex_location: String,
+
+    /// Voyage embedding model (default: voyage-code-3)
+    #[serde(default = "default_voyage_model")]
+    pub voyage_model: String,
+
+    /// Voyage API key (can use ${VAR}; default: $VOYAGE_API_KEY)
+    #[serde(default)]
+    pub voyage_api_key: Option<String>,
+
+    /// Voyage output dimension, e.g. 256 or 2048 (default: the model's)
+    #[serde(default)]
+    pub voyage_dimensions: Option<u32>,
+
+    /// Cohere embedding model (default: embed-v4.0)
+    #[serde(default = "default_cohere_model")]
+    pub cohere_model: String,
+
+    /// Cohere API key (can use ${VAR}; default: $CO_API_KEY or
+    /// $COHERE_API_KEY)
+    #[serde(default)]
+    pub cohere_api_key: Option<String>,
+
+    /// Cohere output dimension, e.g. 512 (default: the model's)
+    #[serde(default)]
+    pub cohere_dimensions: Option<u32>,
+
+    /// Directory of the local ONNX model: `model.onnx` (or
+    /// `onnx/model.onnx`) and its Hugging Face tokenizer files
+    #[serde(default)]
+    pub onnx_model_dir: Option<PathBuf>,
+
+    /// How the ONNX model's token vectors are pooled (default: mean)
+    #[serde(default)]
+    pub onnx_pooling: OnnxPooling,
+
+    /// Text Embeddings Inference server URL (default: http://localhost:8080)
+    #[serde(default = "default_tei_url")]
+    pub tei_url: String,
+
+    /// Bearer token for the TEI server, e.g. a Hugging Face Inference
+    /// Endpoint (can use ${VAR}; default: none)
+    #[serde(default)]
+    pub tei_api_key: Option<String>,
+
+    /// Let the TEI server cut inputs longer than the model's limit instead
+    /// of rejecting them (default: true)
+    #[serde(default = "default_tei_truncate")]
+    pub tei_truncate: bool,
+
+    /// Let the TEI server normalize embeddings to unit length (default: true)
+    #[serde(default = "default_tei_normalize")]
+    pub tei_normalize: bool,
+
+    /// Name of the model's sentence-transformers prompt that TEI applies to
+    /// queries, e.g. "query" (default: none)
+    #[serde(default)]
+    pub tei_query_prompt_name: Option<String>,
+
+    /// Retries of a failed API request before giving up
+    #[serde(default = "default_max_retries")]
+    pub max_retries: usize,
+
+    /// Client-side API rate limit, in requests per minute
+    #[serde(default = "default_requests_per_minute")]
+    pub requests_per_minute: u32,
+
+    /// Client-side API rate limit, in tokens per minute (default: none)
+    #[serde(default)]
+    pub tokens_per_minute: Option<u32>,
+
+    /// Failed requests in a row after which the provider is not called for
+    /// `circuit_breaker_cooldown_secs`; 0 disables the circuit breaker
+    #[serde(default = "default_circuit_breaker_threshold")]
+    pub circuit_breaker_threshold: u32,
+
+    /// How long requests fail at once after the circuit breaker opens
+    #[serde(default = "default_circuit_breaker_cooldown_secs")]
+    pub circuit_breaker_cooldown_secs: u64,
+
+    /// Tokenizer chunk sizes are counted with (default: the one matching
+    /// the provider and model)
+    #[serde(default)]
+    pub tokenizer: TokenizerKind,
+
+    /// Longest input the model embeds without truncating it, in tokens
+    /// (default: 512 for FastEmbed models, 8191 for OpenAI and Azure, 2048
+    /// for Ollama and Gemini, 8192 for Titan and 512 for Cohere on Bedrock,
+    /// 32000 for Voyage, 128000 for Cohere embed-v4, 512 for older Cohere
+    /// models, ONNX models and TEI). Chunks are kept within it, and ONNX
+    /// models truncate inputs to it.
+    #[serde(default)]
+    pub max_input_tokens: Option<usize>,
+
+    /// Most tokens sent in one embedding request (default: 300000 for
+    /// OpenAI and Azure, 120000 for Voyage, no limit otherwise). Requests
+    /// are filled up to `batch_size` texts within it.
+    #[serde(default)]
+    pub max_batch_tokens: Option<usize>,
+
+    /// Keep only the first N dimensions of every embedding, rescaled to unit
+    /// length (default: all). Suits Matryoshka-trained models such as
+    /// OpenAI's text-embedding-3 and nomic-embed-text-v1.5: a much smaller
+    /// index and faster search for slightly lower recall.
+    #[serde(default)]
+    pub truncate_dimensions: Option<usize>,
+
+    /// Keep embeddings on disk keyed by model and text, so unchanged chunks
+    /// are never embedded twice
+    #[serde(default = "default_embeddings_cache")]
+    pub cache: bool,
+
+    /// Directory of the embedding cache (default: `embedding-cache` in the
+    /// global coderag data directory, shared by all projects)
+    #[serde(default)]
+    pub cache_dir: Option<PathBuf>,
+}
+
+/// How requests to Azure OpenAI authenticate
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize, Default)]
+#[serde(rename_all = "kebab-case")]
+pub enum AzureAuth {
+    /// The resource's API key in the `api-key` header (default)
+    #[default]
+    ApiKey,
+    /// A Microsoft Entra ID (Azure AD) bearer token: `AZURE_OPENAI_AD_TOKEN`,
+    /// or one requested with the service principal in `AZURE_TENANT_ID`,
+    /// `AZURE_CLIENT_ID` and `AZURE_CLIENT_SECRET`
+    AzureAd,
+}
+
+/// How a local ONNX model's token vectors are pooled into one embedding
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize, Default)]
+#[serde(rename_all = "lowercase")]
+pub enum OnnxPooling {
+    /// Mean of the token vectors, as for sentence-transformers models
+    /// (default)
+    #[default]
+    Mean,
+    /// The first (`[CLS]`) token's vector, as for BGE models
+    Cls,
+}
+
+/// Tokenizer that chunk token limits are counted with
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize, Default)]
+#[serde(rename_all = "lowercase")]
+pub enum TokenizerKind {
+    /// The tokenizer matching the embedding provider and model (default)
+    #[default]
+    Auto,
+    /// OpenAI `cl100k_base` BPE
+    Cl100k,
+    /// OpenAI `o200k_base` BPE
+    O200k,
+    /// Whitespace/punctuation splitter
+    Whitespace,
+    /// Conservative subword estimate of BERT-style WordPiece tokenizers
+    Subword,
+}
+
+impl EmbeddingsConfig {
+    /// Longest input the model embeds in full, in tokens: `max_input_tokens`,
+    /// or the limit of the provider's models.
+    pub fn input_token_limit(&self) -> usize {
+        self.max_input_tokens.unwrap_or(match self.provider {
+            // FastEmbed truncates inputs to 512 tokens
+            EmbeddingProvider::FastEmbed => 512,
+            EmbeddingProvider::OpenAI | EmbeddingProvider::Azure => 8191,
+            // Ollama's default context size
+            EmbeddingProvider::Ollama => 2048,
+            EmbeddingProvider::Bedrock if self.bedrock_model.contains("cohere.embed") => 512,
+            EmbeddingProvider::Bedrock => 8192,
+            EmbeddingProvider::Gemini => 2048,
+            EmbeddingProvider::Voyage => 32000,
+            EmbeddingProvider::Cohere if self.cohere_model.starts_with("embed-v4") => 128_000,
+            EmbeddingProvider::Cohere => 512,
+            // The usual limit of BERT-style models
+            EmbeddingProvider::Onnx => 512,
+            // TEI's own limit is the model's, which is usually 512 tokens
+            EmbeddingProvider::Tei => 512,
+        })
+    }
+
+    /// Most tokens sent in one embedding request: `max_batch_tokens`, or
+    /// the provider's per-request limit, or `usize::MAX` for none.
+    pub fn batch_token_limit(&self) -> usize {
+        self.max_batch_tokens.unwrap_or(match self.provider {
+            EmbeddingProvider::OpenAI | EmbeddingProvider::Azure => 300_000,
+            // voyage-code-3's limit; other Voyage models allow more
+            EmbeddingProvider::Voyage => 120_000,
+            _ => usize::MAX,
+        })
+    }
+
+    /// Settings of each provider to try, in order: `provider`, then each of
+    /// `fallback_providers` that is not already in the chain
+    pub fn provider_chain(&self) -> Vec<EmbeddingsConfig> {
+        let mut providers = vec![self.provider];
+        for provider in &self.fallback_providers {
+            if !providers.contains(provider) {
+                providers.push(*provider);
+            }
+        }
+        providers
+            .into_iter()
+            .map(|provider| EmbeddingsConfig {
+                provider,
+                fallback_providers: Vec::new(),
+                ..self.clone()
+            })
+            .collect()
+    }
+
+    /// Name of the configured model: the model, Azure deployment, ONNX
+    /// model directory or TEI server URL
+    pub fn model_name(&self) -> &str {
+        match self.provider {
+            EmbeddingProvider::FastEmbed => &self.model,
+            EmbeddingProvider::OpenAI => &self.openai_model,
+            EmbeddingProvider::Ollama => &self.ollama_model,
+            EmbeddingProvider::Azure => self.azure_deployment.as_deref().unwrap_or_default(),
+            EmbeddingProvider::Bedrock => &self.bedrock_model,
+            EmbeddingProvider::Gemini => &self.gemini_model,
+            EmbeddingProvider::Voyage => &self.voyage_model,
+            EmbeddingProvider::Cohere => &self.cohere_model,
+            EmbeddingProvider::Onnx => self
+                .onnx_model_dir
+                .as_deref()
+                .and_then(|dir| dir.to_str())
+                .unwrap_or_default(),
+            EmbeddingProvider::Tei => &self.tei_url,
+        }
+    }
 }
 
 impl Default for EmbeddingsConfig {
     fn default() -> Self {
         Self {
             provider: EmbeddingProvider::default(),
+            fallback_providers: Vec::new(),
             model: default_model(),
             batch_size: default_batch_size(),
             openai_api_key: None,
             openai_model: default_openai_model(),
             openai_base_url: None,
+            ollama_host: default_ollama_host(),
+            ollama_model: default_ollama_model(),
+            ollama_keep_alive: None,
+            azure_endpoint: None,
+            azure_deployment: None,
+            azure_api_version: default_azure_api_version(),
+            azure_auth: AzureAuth::default(),
+            azure_api_key: None,
+            bedrock_model: default_bedrock_model(),
+            bedrock_region: None,
+            bedrock_profile: None,
+            gemini_model: default_gemini_model(),
+            gemini_api_key: None,
+            gemini_dimensions: None,
+            gemini_query_task_type: default_gemini_query_task_type(),
+            gemini_vertex_project: None,
+            gemini_vertex_location: default_gemini_vertex_location(),
+            voyage_model: default_voyage_model(),
+            voyage_api_key: None,
+            voyage_dimensions: None,
+            cohere_model: default_cohere_model(),
+            cohere_api_key: None,
+            cohere_dimensions: None,
+            onnx_model_dir: None,
+            onnx_pooling: OnnxPooling::default(),
+            tei_url: default_tei_url(),
+            tei_api_key: None,
+            tei_truncate: default_tei_truncate(),
+            tei_normalize: default_tei_normalize(),
+            tei_query_prompt_name: None,
+            max_retries: default_max_retries(),
+            requests_per_minute: default_requests_per_minute(),
+            tokens_per_minute: None,
+            circuit_breaker_threshold: default_circuit_breaker_threshold(),
+            circuit_breaker_cooldown_secs: default_circuit_breaker_cooldown_secs(),
+            tokenizer: TokenizerKind::default(),
+            max_input_tokens: None,
+            max_batch_tokens: None,
+            truncate_dimensions: None,
+            cache: default_embeddings_cache(),
+            cache_dir: None,
         }
     }
 }
@@ -199,21 +1058,150 @@ fn default_openai_model() -> String {
     "text-embedding-3-small".to_string()
 }
 
+fn default_ollama_host() -> String {
+    "http://localhost:11434".to_string()
+}
+
+fn default_ollama_model() -> String {
+    "nomic-embed-text".to_string()
+}
+
+fn default_azure_api_version() -> String {
+    "2024-02-01".to_string()
+}
+
+fn default_bedrock_model() -> String {
+    "amazon.titan-embed-text-v2:0".to_string()
+}
+
+fn default_gemini_model() -> String {
+    "gemini-embedding-001".to_string()
+}
+
+fn default_gemini_query_task_type() -> String {
+    "RETRIEVAL_QUERY".to_string()
+}
+
+fn default_gemini_vertex_location() -> String {
+    "us-central1".to_string()
+}
+
+fn default_voyage_model() -> String {
+    "voyage-code-3".to_string()
+}
+
+fn default_cohere_model() -> String {
+    "embed-v4.0".to_string()
+}
+
+fn default_tei_url() -> String {
+    "http://localhost:8080".to_string()
+}
+
+fn default_tei_truncate() -> bool {
+    true
+}
+
+fn default_tei_normalize() -> bool {
+    true
+}
+
+fn default_max_retries() -> usize {
+    3
+}
+
+fn default_requests_per_minute() -> u32 {
+    3500
+}
+
+fn default_circuit_breaker_threshold() -> u32 {
+    5
+}
+
+fn default_circuit_breaker_cooldown_secs() -> u64 {
+    30
+}
+
+fn default_embeddings_cache() -> bool {
+    true
+}
+
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct StorageConfig {
-    /// Path to the LanceDB database (relative to .coderag/)
+    /// Vector store backend: "lancedb", "sqlite", "pgvector", "qdrant",
+    /// "milvus", "weaviate" or one registered with
+    /// `storage::register_backend`
+    #[serde(default = "default_storage_backend")]
+    pub backend: String,
+    /// Path to the database (relative to .coderag/); server backends name
+    /// their tables after its file stem
     #[serde(default = "default_db_path")]
     pub db_path: String,
+    /// Connection URL of server backends such as pgvector (can use
+    /// ${VAR}; default: $CODERAG_DATABASE_URL)
+    #[serde(default)]
+    pub url: Option<String>,
+    /// API key of server backends such as Qdrant, Milvus and Weaviate (can
+    /// use ${VAR}; default: $QDRANT_API_KEY, $MILVUS_TOKEN for Milvus or
+    /// $WEAVIATE_API_KEY for Weaviate)
+    #[serde(default)]
+    pub api_key: Option<String>,
 }
 
 impl Default for StorageConfig {
     fn default() -> Self {
         Self {
+            backend: default_storage_backend(),
             db_path: default_db_path(),
+            url: None,
+            api_key: None,
         }
     }
 }
 
+impl StorageConfig {
+    /// The configured connection URL, resolving `${VAR}` references and
+    /// falling back to `CODERAG_DATABASE_URL`
+    pub fn load_url(&self) -> Result<Option<String>> {
+        match self.url.as_deref() {
+            Some(url) if url.starts_with("${") && url.ends_with('}') => {
+                let var = &url[2..url.len() - 1];
+                std::env::var(var)
+                    .map(Some)
+                    .with_context(|| format!("Environment variable {} not set", var))
+            }
+            Some(url) if !url.is_empty() => Ok(Some(url.to_string())),
+            _ => Ok(std::env::var("CODERAG_DATABASE_URL").ok()),
+        }
+    }
+
+    /// The configured API key, resolving `${VAR}` references and falling
+    /// back to `MILVUS_TOKEN` for Milvus, `WEAVIATE_API_KEY` for Weaviate and
+    /// `QDRANT_API_KEY` otherwise
+    pub fn load_api_key(&self) -> Result<Option<String>> {
+        match self.api_key.as_deref() {
+            Some(key) if key.starts_with("${") && key.ends_with('}') => {
+                let var = &key[2..key.len() - 1];
+                std::env::var(var)
+                    .map(Some)
+                    .with_context(|| format!("Environment variable {} not set", var))
+            }
+            Some(key) if !key.is_empty() => Ok(Some(key.to_string())),
+            _ if self.backend == crate::storage::MILVUS_BACKEND => {
+                Ok(std::env::var("MILVUS_TOKEN").ok())
+            }
+            _ if self.backend == crate::storage::WEAVIATE_BACKEND => {
+                Ok(std::env::var("WEAVIATE_API_KEY").ok())
+            }
+            _ => Ok(std::env::var("QDRANT_API_KEY").ok()),
+        }
+    }
+}
+
+fn default_storage_backend() -> String {
+    crate::storage::LANCEDB_BACKEND.to_string()
+}
+
 fn default_db_path() -> String {
     "index.lance".to_string()
 }
@@ -253,6 +1241,50 @@ pub struct ServerConfig {
     /// HTTP transport configuration
     #[serde(default)]
     pub http: HttpServerConfig,
+
+    /// Access restrictions for exposed servers
+    #[serde(default)]
+    pub security: SecurityConfig,
+}
+
+/// Security settings for the MCP HTTP transport and web UI
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SecurityConfig {
+    /// Disable every endpoint and code path that mutates the index
+    #[serde(default)]
+    pub read_only: bool,
+
+    /// Bearer token required on every request (can use ${ENV_VAR}).
+    /// Falls back to the CODERAG_AUTH_TOKEN environment variable.
+    #[serde(default)]
+    pub auth_token: Option<String>,
+
+    /// Upper bound for the number of results a single request may ask for
+    #[serde(default = "default_max_limit")]
+    pub max_limit: usize,
+
+    /// Maximum accepted request body size in bytes
+    #[serde(default = "default_max_request_bytes")]
+    pub max_request_bytes: usize,
+}
+
+impl Default for SecurityConfig {
+    fn default() -> Self {
+        Self {
+            read_only: false,
+            auth_token: None,
+            max_limit: default_max_limit(),
+            max_request_bytes: default_max_request_bytes(),
+        }
+    }
+}
+
+fn default_max_limit() -> usize {
+    100
+}
+
+fn default_max_request_bytes() -> usize {
+    64 * 1024
 }
 
 /// HTTP server configuration for MCP
@@ -352,6 +1384,182 @@ pub struct SearchConfig {
     /// Default number of results to return
     #[serde(default = "default_search_limit")]
     pub default_limit: usize,
+
+    /// Warn before searching when files changed since the last index
+    #[serde(default = "default_freshness_check")]
+    pub freshness_check: bool,
+
+    /// Number of clusters for `search --cluster` (chosen automatically when unset)
+    #[serde(default)]
+    pub cluster_count: Option<usize>,
+
+    /// Semantic kinds to rank first among similarly-scored results
+    /// (most preferred first; empty disables the preference)
+    #[serde(default = "default_kind_preference")]
+    pub kind_preference: Vec<String>,
+
+    /// Relative score boost given to the most preferred kind
+    #[serde(default = "default_kind_boost")]
+    pub kind_boost: f32,
+
+    /// Acronyms and synonyms (`term = ["alternative", ...]`), applied in both
+    /// directions to lexical queries
+    #[serde(default)]
+    pub synonyms: BTreeMap<String, Vec<String>>,
+
+    /// Per-language lexical boosts by token role (`[search.term_boosts.go]`
+    /// with `type = 2.0`), added to matches on identifiers of that role
+    #[serde(default)]
+    pub term_boosts: BTreeMap<String, BTreeMap<String, f32>>,
+
+    /// Also append synonym expansions to the text embedded for vector search
+    #[serde(default)]
+    pub expand_embedding_query: bool,
+
+    /// Weight of the chunk summaries (`indexer.summaries`) fused into vector
+    /// search, from 0 (off) to 1; the code vectors get the rest
+    #[serde(default)]
+    pub summary_weight: f32,
+
+    /// Field weights (`doc`, `body`, `structure`) used by
+    /// `search --weights default`
+    #[serde(default = "default_field_weights")]
+    pub field_weights: BTreeMap<String, f32>,
+
+    /// Drop results scoring below this (0.0 - 1.0; 0 keeps every result)
+    #[serde(default)]
+    pub min_score: f32,
+
+    /// Candidates retrieved before re-ranking (`search --candidates`);
+    /// `max(50, 5 × limit)` when unset
+    #[serde(default)]
+    pub candidates: Option<usize>,
+
+    /// `fixed`, or `dynamic` to grow the pool until a score gap appears
+    #[serde(default)]
+    pub candidate_strategy: CandidateStrategy,
+
+    /// Upper bound on the candidate pool, including dynamic growth
+    #[serde(default = "default_max_candidates")]
+    pub max_candidates: usize,
+
+    /// Score gap below the last result at which a dynamic pool stops growing
+    #[serde(default = "default_candidate_score_gap")]
+    pub candidate_score_gap: f32,
+
+    /// Explain likely causes when a search finds nothing
+    #[serde(default = "default_explain_empty")]
+    pub explain_empty: bool,
+
+    /// Highlight result snippets by language (`search --highlight`): ANSI
+    /// colors on a terminal, `<span>` markup in HTML output and the web UI
+    #[serde(default)]
+    pub highlight: bool,
+
+    /// Where result previews start: "symbol" (the symbol's first lines) or
+    /// "match" (the line matching the query best, labeled with its symbol)
+    #[serde(default)]
+    pub snippet_anchor: SnippetAnchor,
+
+    /// Lines shown on each side of the match line with `snippet_anchor =
+    /// "match"`
+    #[serde(default = "default_snippet_context")]
+    pub snippet_context: usize,
+
+    /// Named sets of search options, selected with `search --search-profile`
+    #[serde(default)]
+    pub profiles: BTreeMap<String, SearchProfile>,
+}
+
+impl SearchConfig {
+    /// Look up a search profile, listing the defined names on error
+    pub fn profile(&self, name: &str) -> Result<&SearchProfile> {
+        self.profiles.get(name).ok_or_else(|| {
+            let defined: Vec<&str> = self.profiles.keys().map(String::as_str).collect();
+            anyhow::anyhow!(
+                "Unknown search profile '{}'. Defined in [search.profiles]: {}",
+                name,
+                if defined.is_empty() {
+                    "none".to_string()
+                } else {
+                    defined.join(", ")
+                }
+            )
+        })
+    }
+}
+
+/// Search options saved under a name in `[search.profiles.<name>]`.
+///
+/// Every option mirrors the `search` flag of the same name. Unset options
+/// leave the flag's default in place.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct SearchProfile {
+    /// Only return chunks of this kind
+    #[serde(default)]
+    pub kind: Option<String>,
+
+    /// Only return chunks with this analysis tag
+    #[serde(default)]
+    pub tag: Option<String>,
+
+    /// Only return chunks whose file header has these `key=value` pairs
+    #[serde(default)]
+    pub meta: Option<Vec<String>>,
+
+    /// Only return chunks indexed from this git ref
+    #[serde(default)]
+    pub branch: Option<String>,
+
+    /// Kinds to rank first (`["none"]` disables the preference)
+    #[serde(default)]
+    pub prefer_kind: Option<Vec<String>>,
+
+    /// Field weights (`doc=0.7,body=0.3` or `default`)
+    #[serde(default)]
+    pub weights: Option<String>,
+
+    /// Search only this field: `name` or `comments`
+    #[serde(default)]
+    pub field: Option<String>,
+
+    /// Drop results scoring below this
+    #[serde(default)]
+    pub min_score: Option<f32>,
+
+    /// Maximum number of results
+    #[serde(default)]
+    pub limit: Option<usize>,
+
+    /// Candidates retrieved before re-ranking
+    #[serde(default)]
+    pub candidates: Option<usize>,
+}
+
+impl SearchProfile {
+    /// This profile with every option set in `flags` replaced by the
+    /// flag's value.
+    ///
+    /// `weights` and `field` exclude each other, so a flag setting either one
+    /// also drops the other from the profile.
+    pub fn overridden_by(mut self, flags: SearchProfile) -> SearchProfile {
+        if flags.weights.is_some() || flags.field.is_some() {
+            self.weights = None;
+            self.field = None;
+        }
+        SearchProfile {
+            kind: flags.kind.or(self.kind),
+            tag: flags.tag.or(self.tag),
+            meta: flags.meta.or(self.meta),
+            branch: flags.branch.or(self.branch),
+            prefer_kind: flags.prefer_kind.or(self.prefer_kind),
+            weights: flags.weights.or(self.weights),
+            field: flags.field.or(self.field),
+            min_score: flags.min_score.or(self.min_score),
+            limit: flags.limit.or(self.limit),
+            candidates: flags.candidates.or(self.candidates),
+        }
+    }
 }
 
 impl Default for SearchConfig {
@@ -362,6 +1570,25 @@ impl Default for SearchConfig {
             bm25_weight: default_bm25_weight(),
             rrf_k: default_rrf_k(),
             default_limit: default_search_limit(),
+            freshness_check: default_freshness_check(),
+            cluster_count: None,
+            kind_preference: default_kind_preference(),
+            kind_boost: default_kind_boost(),
+            synonyms: BTreeMap::new(),
+            term_boosts: BTreeMap::new(),
+            expand_embedding_query: false,
+            summary_weight: 0.0,
+            field_weights: default_field_weights(),
+            min_score: 0.0,
+            candidates: None,
+            candidate_strategy: CandidateStrategy::default(),
+            max_candidates: default_max_candidates(),
+            candidate_score_gap: default_candidate_score_gap(),
+            explain_empty: default_explain_empty(),
+            highlight: false,
+            snippet_anchor: SnippetAnchor::default(),
+            snippet_context: default_snippet_context(),
+            profiles: BTreeMap::new(),
         }
     }
 }
@@ -378,10 +1605,91 @@ fn default_rrf_k() -> f32 {
     60.0
 }
 
+fn default_max_candidates() -> usize {
+    DEFAULT_MAX_CANDIDATES
+}
+
+fn default_candidate_score_gap() -> f32 {
+    DEFAULT_SCORE_GAP
+}
+
 fn default_search_limit() -> usize {
     10
 }
 
+fn default_freshness_check() -> bool {
+    true
+}
+
+fn default_kind_preference() -> Vec<String> {
+    vec![
+        "function".to_string(),
+        "method".to_string(),
+        "client-method".to_string(),
+    ]
+}
+
+fn default_kind_boost() -> f32 {
+    0.05
+}
+
+fn default_field_weights() -> BTreeMap<String, f32> {
+    BTreeMap::from([
+        ("doc".to_string(), 0.3),
+        ("body".to_string(), 0.5),
+        ("structure".to_string(), 0.2),
+    ])
+}
+
+fn default_explain_empty() -> bool {
+    true
+}
+
+fn default_snippet_context() -> usize {
+    DEFAULT_SNIPPET_CONTEXT
+}
+
+/// Settings for `coderag watch`
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct WatchConfig {
+    /// Webhook notified after each reindex cycle; none when unset
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub webhook: Option<WebhookConfig>,
+}
+
+/// Webhook that receives a JSON payload after each watch-mode reindex
+/// (see [`crate::watcher::webhook`])
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct WebhookConfig {
+    /// URL the payload is POSTed to
+    pub url: String,
+
+    /// Attempts after a failed delivery
+    #[serde(default = "default_webhook_retries")]
+    pub retries: u32,
+
+    /// Delay before the first retry in milliseconds, doubled for each
+    /// further retry
+    #[serde(default = "default_webhook_retry_delay_ms")]
+    pub retry_delay_ms: u64,
+
+    /// Timeout of each attempt in milliseconds
+    #[serde(default = "default_webhook_timeout_ms")]
+    pub timeout_ms: u64,
+}
+
+fn default_webhook_retries() -> u32 {
+    3
+}
+
+fn default_webhook_retry_delay_ms() -> u64 {
+    1000
+}
+
+fn default_webhook_timeout_ms() -> u64 {
+    5000
+}
+
 /// Configuration for logging subsystem
 #[derive(Debug, Clone, Serialize, Deserialize)]
 #[serde(default)]
@@ -442,7 +1750,17 @@ impl Config {
     /// 1. Project-local config (.coderag/config.toml in project root)
     /// 2. Global config (~/.coderag/config.toml)
     /// 3. Default config
+    ///
+    /// A config with `offline = true` enables offline mode for the process.
     pub fn load(root: &Path) -> Result<Self> {
+        let config = Self::load_file(root)?;
+        if config.offline {
+            crate::offline::enable();
+        }
+        Ok(config)
+    }
+
+    fn load_file(root: &Path) -> Result<Self> {
         let local_config_path = root.join(CONFIG_DIR).join(CONFIG_FILE);
 
         // Try local config first
@@ -450,7 +1768,7 @@ impl Config {
             let content = std::fs::read_to_string(&local_config_path)
                 .with_context(|| format!("Failed to read config from {:?}", local_config_path))?;
 
-            return toml::from_str(&content)
+            return Self::from_toml(&content)
                 .with_context(|| format!("Failed to parse config from {:?}", local_config_path));
         }
 
@@ -460,13 +1778,19 @@ impl Config {
                 let content = std::fs::read_to_string(&global_config_path)
                     .with_context(|| format!("Failed to read global config from {:?}", global_config_path))?;
 
-                return toml::from_str(&content)
+                return Self::from_toml(&content)
                     .with_context(|| format!("Failed to parse global config from {:?}", global_config_path));
             }
         }
 
-        // Return default config
-        Ok(Config::default())
+        // Return default config, with the selected profile if any
+        Self::from_toml("")
+    }
+
+    /// Parse a config file, layered over the profile it selects
+    fn from_toml(content: &str) -> Result<Self> {
+        let table: toml::Table = toml::from_str(content)?;
+        Ok(crate::profile::apply(table)?.try_into()?)
     }
 
     /// Save configuration to the .coderag directory
@@ -500,6 +1824,17 @@ impl Config {
     pub fn is_initialized(root: &Path) -> bool {
         Self::coderag_dir(root).exists()
     }
+
+    /// Largest chunk, in tokens, the embedding model embeds in full: its
+    /// input limit, less the room context headers take
+    pub fn chunk_token_limit(&self) -> usize {
+        let limit = self.embeddings.input_token_limit();
+        if self.indexer.context_headers {
+            limit.saturating_sub(HEADER_TOKEN_RESERVE)
+        } else {
+            limit
+        }
+    }
 }
 
 #[cfg(test)]
@@ -520,6 +1855,7 @@ mod tests {
         assert!((config.search.bm25_weight - 0.3).abs() < 0.001);
         assert!((config.search.rrf_k - 60.0).abs() < 0.001);
         assert_eq!(config.search.default_limit, 10);
+        assert_eq!(config.search.kind_preference[0], "function");
     }
 
     #[test]
@@ -534,6 +1870,558 @@ mod tests {
         assert_eq!(config.embeddings.model, loaded.embeddings.model);
     }
 
+    #[test]
+    fn test_search_synonyms() {
+        let config: Config = toml::from_str(
+            r#"
+[search.synonyms]
+wp = ["worker pool"]
+ctx = ["context"]
+"#,
+        )
+        .unwrap();
+
+        assert_eq!(config.search.synonyms["wp"], vec!["worker pool"]);
+        assert_eq!(config.search.synonyms.len(), 2);
+        assert!(!config.search.expand_embedding_query);
+    }
+
+    #[test]
+    fn test_watch_webhook() {
+        assert!(Config::default().watcher.webhook.is_none());
+
+        let config: Config = toml::from_str(
+            r#"
+[watcher.webhook]
+url = "http://localhost:9000/coderag"
+retries = 5
+"#,
+        )
+        .unwrap();
+
+        let webhook = config.watcher.webhook.unwrap();
+        assert_eq!(webhook.url, "http://localhost:9000/coderag");
+        assert_eq!(webhook.retries, 5);
+        assert_eq!(webhook.retry_delay_ms, 1000);
+        assert_eq!(webhook.timeout_ms, 5000);
+    }
+
+    #[test]
+    fn test_search_term_boosts() {
+        let config: Config = toml::from_str(
+            r#"
+[search.term_boosts.go]
+type = 2.0
+local = 0.5
+"#,
+        )
+        .unwrap();
+
+        assert_eq!(config.search.term_boosts["go"]["type"], 2.0);
+        assert_eq!(config.search.term_boosts["go"].len(), 2);
+        assert!(SearchConfig::default().term_boosts.is_empty());
+    }
+
+    #[test]
+    fn test_indexer_grammars() {
+        let config: Config = toml::from_str(
+            r#"
+[[indexer.grammars]]
+name = "elixir"
+extensions = ["ex", "exs"]
+library = "grammars/libtree-sitter-elixir.so"
+
+[indexer.grammars.nodes]
+call = "function"
+"#,
+        )
+        .unwrap();
+
+        let grammar = &config.indexer.grammars[0];
+        assert_eq!(grammar.symbol(), "tree_sitter_elixir");
+        assert_eq!(grammar.nodes["call"], "function");
+        assert_eq!(grammar.name_field, "name");
+        assert_eq!(grammar.separator, ".");
+
+        let extensions = config.indexer.indexed_extensions();
+        assert!(extensions.contains(&"rs".to_string()));
+        assert!(extensions.contains(&"exs".to_string()));
+    }
+
+    #[test]
+    fn test_indexer_granularity() {
+        let config: Config = toml::from_str(
+            r#"
+[[indexer.granularity]]
+paths = ["internal/util/**"]
+level = "file"
+
+[[indexer.granularity]]
+languages = ["java"]
+level = "type"
+"#,
+        )
+        .unwrap();
+
+        let rules = &config.indexer.granularity;
+        assert_eq!(rules.len(), 2);
+        assert_eq!(rules[0].level, Granularity::File);
+        assert!(rules[0].languages.is_empty());
+        assert_eq!(rules[1].languages, vec!["java"]);
+        assert!(IndexerConfig::default().granularity.is_empty());
+    }
+
+    #[test]
+    fn test_indexer_summaries() {
+        assert!(!IndexerConfig::default().summaries.enabled);
+
+        let config: Config = toml::from_str(
+            r#"
+[indexer.summaries]
+enabled = true
+base_url = "http://localhost:11434/v1"
+
+[search]
+summary_weight = 0.3
+"#,
+        )
+        .unwrap();
+        let summaries = &config.indexer.summaries;
+        assert!(summaries.enabled);
+        assert_eq!(summaries.model, "gpt-4o-mini");
+        assert_eq!(
+            summaries.base_url.as_deref(),
+            Some("http://localhost:11434/v1")
+        );
+        assert_eq!(summaries.min_lines, 3);
+        assert_eq!(config.search.summary_weight, 0.3);
+    }
+
+    #[test]
+    fn test_ollama_embeddings() {
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "ollama"
+ollama_model = "mxbai-embed-large"
+ollama_keep_alive = "30m"
+"#,
+        )
+        .unwrap();
+
+        let embeddings = &config.embeddings;
+        assert_eq!(embeddings.provider, EmbeddingProvider::Ollama);
+        assert_eq!(embeddings.ollama_host, "http://localhost:11434");
+        assert_eq!(embeddings.ollama_model, "mxbai-embed-large");
+        assert_eq!(embeddings.ollama_keep_alive.as_deref(), Some("30m"));
+        assert_eq!(embeddings.input_token_limit(), 2048);
+    }
+
+    #[test]
+    fn test_azure_embeddings() {
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "azure"
+azure_endpoint = "https://contoso.openai.azure.com"
+azure_deployment = "embeddings-small"
+azure_auth = "azure-ad"
+"#,
+        )
+        .unwrap();
+
+        let embeddings = &config.embeddings;
+        assert_eq!(embeddings.provider, EmbeddingProvider::Azure);
+        assert_eq!(
+            embeddings.azure_deployment.as_deref(),
+            Some("embeddings-small")
+        );
+        assert_eq!(embeddings.azure_api_version, "2024-02-01");
+        assert_eq!(embeddings.azure_auth, AzureAuth::AzureAd);
+        assert_eq!(embeddings.input_token_limit(), 8191);
+    }
+
+    #[test]
+    fn test_bedrock_embeddings() {
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "bedrock"
+bedrock_model = "us.cohere.embed-english-v3"
+bedrock_region = "us-east-1"
+"#,
+        )
+        .unwrap();
+
+        let embeddings = &config.embeddings;
+        assert_eq!(embeddings.provider, EmbeddingProvider::Bedrock);
+        assert_eq!(embeddings.bedrock_region.as_deref(), Some("us-east-1"));
+        assert!(embeddings.bedrock_profile.is_none());
+        assert_eq!(embeddings.input_token_limit(), 512);
+    }
+
+    #[test]
+    fn test_gemini_embeddings() {
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "gemini"
+gemini_dimensions = 768
+gemini_vertex_project = "acme-search"
+"#,
+        )
+        .unwrap();
+
+        let embeddings = &config.embeddings;
+        assert_eq!(embeddings.provider, EmbeddingProvider::Gemini);
+        assert_eq!(embeddings.gemini_model, "gemini-embedding-001");
+        assert_eq!(embeddings.gemini_dimensions, Some(768));
+        assert_eq!(embeddings.gemini_query_task_type, "RETRIEVAL_QUERY");
+        assert_eq!(embeddings.gemini_vertex_location, "us-central1");
+    }
+
+    #[test]
+    fn test_voyage_and_cohere_embeddings() {
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "voyage"
+voyage_dimensions = 2048
+"#,
+        )
+        .unwrap();
+
+        let embeddings = &config.embeddings;
+        assert_eq!(embeddings.provider, EmbeddingProvider::Voyage);
+        assert_eq!(embeddings.voyage_model, "voyage-code-3");
+        assert_eq!(embeddings.voyage_dimensions, Some(2048));
+        assert_eq!(embeddings.input_token_limit(), 32000);
+
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "cohere"
+cohere_model = "embed-english-v3.0"
+"#,
+        )
+        .unwrap();
+
+        let embeddings = &config.embeddings;
+        assert_eq!(embeddings.provider, EmbeddingProvider::Cohere);
+        assert!(embeddings.cohere_dimensions.is_none());
+        assert_eq!(embeddings.input_token_limit(), 512);
+    }
+
+    #[test]
+    fn test_onnx_embeddings() {
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "onnx"
+onnx_model_dir = "models/bge-small-en-v1.5"
+onnx_pooling = "cls"
+"#,
+        )
+        .unwrap();
+
+        let embeddings = &config.embeddings;
+        assert_eq!(embeddings.provider, EmbeddingProvider::Onnx);
+        assert_eq!(
+            embeddings.onnx_model_dir.as_deref(),
+            Some(Path::new("models/bge-small-en-v1.5"))
+        );
+        assert_eq!(embeddings.onnx_pooling, OnnxPooling::Cls);
+        assert_eq!(embeddings.input_token_limit(), 512);
+        assert_eq!(EmbeddingsConfig::default().onnx_pooling, OnnxPooling::Mean);
+    }
+
+    #[test]
+    fn test_tei_embeddings() {
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "tei"
+tei_url = "http://gpu-box.internal:8080"
+tei_normalize = false
+tei_query_prompt_name = "query"
+"#,
+        )
+        .unwrap();
+
+        let embeddings = &config.embeddings;
+        assert_eq!(embeddings.provider, EmbeddingProvider::Tei);
+        assert_eq!(embeddings.tei_url, "http://gpu-box.internal:8080");
+        assert!(embeddings.tei_truncate);
+        assert!(!embeddings.tei_normalize);
+        assert_eq!(embeddings.tei_query_prompt_name.as_deref(), Some("query"));
+        assert!(embeddings.tei_api_key.is_none());
+    }
+
+    #[test]
+    fn test_embeddings_resilience_settings() {
+        let defaults = EmbeddingsConfig::default();
+        assert_eq!(defaults.tokens_per_minute, None);
+        assert_eq!(defaults.circuit_breaker_threshold, 5);
+        assert_eq!(defaults.circuit_breaker_cooldown_secs, 30);
+
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+tokens_per_minute = 1000000
+circuit_breaker_threshold = 0
+circuit_breaker_cooldown_secs = 120
+"#,
+        )
+        .unwrap();
+        assert_eq!(config.embeddings.tokens_per_minute, Some(1_000_000));
+        assert_eq!(config.embeddings.circuit_breaker_threshold, 0);
+        assert_eq!(config.embeddings.circuit_breaker_cooldown_secs, 120);
+    }
+
+    #[test]
+    fn test_fallback_providers() {
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "openai"
+fallback_providers = ["ollama", "openai", "fastembed"]
+ollama_model = "nomic-embed-text"
+"#,
+        )
+        .unwrap();
+
+        let chain = config.embeddings.provider_chain();
+        let providers: Vec<_> = chain.iter().map(|c| c.provider).collect();
+        assert_eq!(
+            providers,
+            vec![
+                EmbeddingProvider::OpenAI,
+                EmbeddingProvider::Ollama,
+                EmbeddingProvider::FastEmbed
+            ]
+        );
+        assert!(chain.iter().all(|c| c.fallback_providers.is_empty()));
+        assert_eq!(chain[1].ollama_model, "nomic-embed-text");
+        assert_eq!(EmbeddingsConfig::default().provider_chain().len(), 1);
+    }
+
+    #[test]
+    fn test_truncate_dimensions() {
+        assert!(EmbeddingsConfig::default().truncate_dimensions.is_none());
+
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+provider = "openai"
+openai_model = "text-embedding-3-large"
+truncate_dimensions = 512
+"#,
+        )
+        .unwrap();
+        assert_eq!(config.embeddings.truncate_dimensions, Some(512));
+    }
+
+    #[test]
+    fn test_embeddings_cache_settings() {
+        let defaults = EmbeddingsConfig::default();
+        assert!(defaults.cache);
+        assert!(defaults.cache_dir.is_none());
+
+        let config: Config = toml::from_str(
+            r#"
+[embeddings]
+cache = false
+cache_dir = "/tmp/coderag-cache"
+"#,
+        )
+        .unwrap();
+        assert!(!config.embeddings.cache);
+        assert_eq!(
+            config.embeddings.cache_dir,
+            Some(PathBuf::from("/tmp/coderag-cache"))
+        );
+    }
+
+    #[test]
+    fn test_storage_backend() {
+        assert_eq!(StorageConfig::default().backend, "lancedb");
+
+        let config: Config = toml::from_str(
+            r#"
+[storage]
+backend = "qdrant"
+"#,
+        )
+        .unwrap();
+        assert_eq!(config.storage.backend, "qdrant");
+        assert_eq!(config.storage.db_path, "index.lance");
+    }
+
+    #[test]
+    fn test_storage_url() {
+        let config: Config = toml::from_str(
+            r#"
+[storage]
+backend = "pgvector"
+url = "${CODERAG_TEST_STORAGE_URL}"
+"#,
+        )
+        .unwrap();
+        std::env::set_var("CODERAG_TEST_STORAGE_URL", "postgres://localhost/coderag");
+        assert_eq!(
+            config.storage.load_url().unwrap().as_deref(),
+            Some("postgres://localhost/coderag")
+        );
+        std::env::remove_var("CODERAG_TEST_STORAGE_URL");
+        assert!(config.storage.load_url().is_err());
+    }
+
+    #[test]
+    fn test_storage_api_key() {
+        let config: Config = toml::from_str(
+            r#"
+[storage]
+backend = "qdrant"
+url = "http://localhost:6333"
+api_key = "secret"
+"#,
+        )
+        .unwrap();
+        assert_eq!(
+            config.storage.load_url().unwrap().as_deref(),
+            Some("http://localhost:6333")
+        );
+        assert_eq!(
+            config.storage.load_api_key().unwrap().as_deref(),
+            Some("secret")
+        );
+    }
+
+    #[test]
+    fn test_search_field_weights() {
+        assert_eq!(SearchConfig::default().field_weights["body"], 0.5);
+
+        let config: Config = toml::from_str(
+            r#"
+[search.field_weights]
+doc = 0.8
+body = 0.2
+"#,
+        )
+        .unwrap();
+        assert_eq!(config.search.field_weights.len(), 2);
+        assert_eq!(config.search.field_weights["doc"], 0.8);
+    }
+
+    #[test]
+    fn test_pipeline_buffers() {
+        assert_eq!(IndexerConfig::default().pipeline.chunk_buffer, 2);
+
+        let config: Config = toml::from_str(
+            r#"
+[indexer.pipeline]
+chunk_buffer = 8
+"#,
+        )
+        .unwrap();
+        assert_eq!(config.indexer.pipeline.chunk_buffer, 8);
+        assert_eq!(config.indexer.pipeline.embed_buffer, 2);
+    }
+
+    #[test]
+    fn test_search_profile_with_flag_override() {
+        let config: Config = toml::from_str(
+            r#"
+[search.profiles.backend]
+kind = "function"
+tag = "concurrency"
+min_score = 0.4
+"#,
+        )
+        .unwrap();
+        let profile = config.search.profile("backend").unwrap().clone();
+        assert_eq!(profile.kind.as_deref(), Some("function"));
+        assert!(config.search.profile("frontend").is_err());
+
+        // Explicit flags win; options without a flag keep the profile's value
+        let flags = SearchProfile {
+            kind: Some("method".to_string()),
+            limit: Some(5),
+            ..Default::default()
+        };
+        let merged = profile.overridden_by(flags);
+        assert_eq!(merged.kind.as_deref(), Some("method"));
+        assert_eq!(merged.tag.as_deref(), Some("concurrency"));
+        assert_eq!(merged.min_score, Some(0.4));
+        assert_eq!(merged.limit, Some(5));
+        assert_eq!(merged.branch, None);
+
+        let weighted = SearchProfile {
+            weights: Some("default".to_string()),
+            ..Default::default()
+        };
+        let flags = SearchProfile {
+            field: Some("name".to_string()),
+            ..Default::default()
+        };
+        let merged = weighted.overridden_by(flags);
+        assert_eq!(merged.weights, None);
+        assert_eq!(merged.field.as_deref(), Some("name"));
+    }
+
+    #[test]
+    fn test_search_candidates() {
+        let search = Config::default().search;
+        assert_eq!(search.candidates, None);
+        assert_eq!(search.candidate_strategy, CandidateStrategy::Fixed);
+        assert_eq!(search.max_candidates, 500);
+
+        let config: Config = toml::from_str(
+            r#"
+[search]
+candidates = 80
+candidate_strategy = "dynamic"
+max_candidates = 200
+"#,
+        )
+        .unwrap();
+        assert_eq!(config.search.candidates, Some(80));
+        assert_eq!(config.search.candidate_strategy, CandidateStrategy::Dynamic);
+        assert_eq!(config.search.max_candidates, 200);
+        assert!((config.search.candidate_score_gap - 0.1).abs() < 0.001);
+    }
+
+    #[test]
+    fn test_offline_setting() {
+        assert!(!Config::default().offline);
+
+        let config: Config = toml::from_str("offline = true").unwrap();
+        assert!(config.offline);
+    }
+
+    #[test]
+    fn test_profile_sets_underlying_values() {
+        let config = Config::from_toml(
+            r#"
+profile = "cheap"
+
+[embeddings]
+batch_size = 24
+"#,
+        )
+        .unwrap();
+
+        assert_eq!(config.embeddings.max_retries, 2);
+        assert_eq!(config.embeddings.requests_per_minute, 500);
+        assert_eq!(config.indexer.max_concurrent_files, 8);
+        assert_eq!(config.indexer.file_batch_size, 50);
+        assert_eq!(config.indexer.parallel_threads, Some(2));
+        // Values in the file override the profile
+        assert_eq!(config.embeddings.batch_size, 24);
+
+        assert!(Config::from_toml("profile = \"turbo\"").is_err());
+    }
+
     #[test]
     fn test_load_missing_config_returns_default() {
         let dir = tempdir().unwrap();
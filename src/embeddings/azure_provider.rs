@@ -0,0 +1,441 @@
+//! Azure OpenAI embedding provider
+//!
+//! Azure serves OpenAI models from deployments of a resource rather than
+//! the public endpoint: requests go to
+//! `{endpoint}/openai/deployments/{deployment}/embeddings?api-version=...`
+//! and authenticate with the resource's API key or a Microsoft Entra ID
+//! (Azure AD) bearer token.
+
+use anyhow::{anyhow, bail, Context, Result};
+use async_trait::async_trait;
+use serde::{Deserialize, Serialize};
+use std::time::{Duration, Instant};
+use tokio::sync::Mutex;
+use tracing::{info, warn};
+
+use super::config::{AzureAuth, AzureOpenAIConfig};
+use super::openai_provider::RateLimiter;
+use super::provider::{
+    status_error, EmbeddingProvider, HealthStatus, ProviderCapabilities, Rejected,
+};
+use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+
+/// Text embedded once at startup to check the deployment and learn its
+/// dimension
+const DIMENSION_PROBE: &str = "dimension probe";
+
+/// Most inputs Azure accepts in one request
+const MAX_BATCH_SIZE: usize = 2048;
+
+/// Scope of Entra ID tokens for Azure OpenAI
+const TOKEN_SCOPE: &str = "https://cognitiveservices.azure.com/.default";
+
+/// Entra ID tokens are refreshed this long before they expire
+const TOKEN_REFRESH_MARGIN: Duration = Duration::from_secs(300);
+
+const INITIAL_BACKOFF: Duration = Duration::from_millis(1000);
+const MAX_BACKOFF: Duration = Duration::from_secs(60);
+
+#[derive(Serialize)]
+struct EmbedRequest<'a> {
+    input: &'a [String],
+}
+
+#[derive(Deserialize)]
+struct EmbedResponse {
+    data: Vec<EmbeddingData>,
+}
+
+#[derive(Deserialize)]
+struct EmbeddingData {
+    index: usize,
+    embedding: Vec<f32>,
+}
+
+#[derive(Deserialize)]
+struct TokenResponse {
+    access_token: String,
+    expires_in: u64,
+}
+
+/// An Entra ID token and when it must be refreshed
+struct CachedToken {
+    value: String,
+    refresh_at: Instant,
+}
+
+/// A service principal that requests Entra ID tokens with its client secret
+struct ServicePrincipal {
+    authority: String,
+    tenant_id: String,
+    client_id: String,
+    client_secret: String,
+}
+
+impl ServicePrincipal {
+    /// Request a token for Azure OpenAI
+    async fn request_token(&self, client: &reqwest::Client) -> Result<CachedToken> {
+        let url = format!(
+            "{}/{}/oauth2/v2.0/token",
+            self.authority.trim_end_matches('/'),
+            self.tenant_id
+        );
+        let response = client
+            .post(&url)
+            .form(&[
+                ("grant_type", "client_credentials"),
+                ("client_id", self.client_id.as_str()),
+                ("client_secret", self.client_secret.as_str()),
+                ("scope", TOKEN_SCOPE),
+            ])
+            .send()
+            .await
+            .context("Azure AD token request failed")?;
+        let status = response.status();
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            bail!("Azure AD returned {}: {}", status, body.trim());
+        }
+
+        let issued: TokenResponse = response
+            .json()
+            .await
+            .context("Failed to parse Azure AD token response")?;
+        let lifetime = Duration::from_secs(issued.expires_in).saturating_sub(TOKEN_REFRESH_MARGIN);
+        Ok(CachedToken {
+            value: issued.access_token,
+            refresh_at: Instant::now() + lifetime,
+        })
+    }
+}
+
+/// How requests authenticate
+enum Credential {
+    /// The resource's API key, sent in the `api-key` header
+    ApiKey(String),
+    /// A bearer token from `AZURE_OPENAI_AD_TOKEN`, used as given
+    Token(String),
+    /// Tokens of a service principal, cached until shortly before they
+    /// expire
+    ServicePrincipal(ServicePrincipal, Mutex<Option<CachedToken>>),
+}
+
+impl Credential {
+    /// Credential for `config`, read from the config and the environment
+    fn from_config(config: &AzureOpenAIConfig) -> Result<Self> {
+        match config.auth {
+            AzureAuth::ApiKey => Ok(Self::ApiKey(
+                config
+                    .load_api_key()
+                    .context("Failed to load Azure OpenAI API key")?,
+            )),
+            AzureAuth::AzureAd => {
+                if let Ok(token) = std::env::var("AZURE_OPENAI_AD_TOKEN") {
+                    return Ok(Self::Token(token));
+                }
+                let var = |name: &str| {
+                    std::env::var(name).with_context(|| {
+                        format!(
+                            "Azure AD auth needs AZURE_OPENAI_AD_TOKEN, or {} with \
+                             AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET",
+                            name
+                        )
+                    })
+                };
+                let principal = ServicePrincipal {
+                    authority: std::env::var("AZURE_AUTHORITY_HOST")
+                        .unwrap_or_else(|_| "https://login.microsoftonline.com".to_string()),
+                    tenant_id: var("AZURE_TENANT_ID")?,
+                    client_id: var("AZURE_CLIENT_ID")?,
+                    client_secret: var("AZURE_CLIENT_SECRET")?,
+                };
+                Ok(Self::ServicePrincipal(principal, Mutex::new(None)))
+            }
+        }
+    }
+
+    /// Add the credential to `request`, requesting a new token first when
+    /// the cached one is about to expire
+    async fn authorize(
+        &self,
+        client: &reqwest::Client,
+        request: reqwest::RequestBuilder,
+    ) -> Result<reqwest::RequestBuilder> {
+        match self {
+            Self::ApiKey(key) => Ok(request.header("api-key", key)),
+            Self::Token(token) => Ok(request.bearer_auth(token)),
+            Self::ServicePrincipal(principal, cached) => {
+                let mut cached = cached.lock().await;
+                let token = match cached.take() {
+                    Some(token) if Instant::now() < token.refresh_at => token,
+                    _ => principal.request_token(client).await?,
+                };
+                let request = request.bearer_auth(&token.value);
+                *cached = Some(token);
+                Ok(request)
+            }
+        }
+    }
+}
+
+/// Azure OpenAI embedding provider implementation
+pub struct AzureOpenAIProvider {
+    client: reqwest::Client,
+    config: AzureOpenAIConfig,
+    credential: Credential,
+    rate_limiter: RateLimiter,
+    dimension: usize,
+}
+
+impl AzureOpenAIProvider {
+    /// Connect to the deployment and embed a probe text to learn its
+    /// dimension.
+    ///
+    /// Fails in offline mode, when no credential is configured, and when
+    /// the deployment cannot be reached.
+    pub async fn new(config: &AzureOpenAIConfig) -> Result<Self> {
+        crate::offline::ensure_network_allowed("embeddings", "azure")?;
+
+        let client = reqwest::Client::builder()
+            .timeout(Duration::from_secs(config.timeout_secs))
+            .build()
+            .context("Failed to create HTTP client")?;
+        let per_minute = config.requests_per_minute.max(1) as f64;
+        let mut provider = Self {
+            client,
+            config: config.clone(),
+            credential: Credential::from_config(config)?,
+            rate_limiter: RateLimiter::new(per_minute, per_minute / 60.0),
+            dimension: 0,
+        };
+
+        let probe = provider
+            .request(&[DIMENSION_PROBE.to_string()])
+            .await
+            .with_context(|| {
+                format!(
+                    "Failed to embed with Azure OpenAI deployment '{}' at {}",
+                    config.deployment, config.endpoint
+                )
+            })?;
+        provider.dimension = probe.first().map_or(0, Vec::len);
+        if provider.dimension == 0 {
+            bail!(
+                "Azure OpenAI deployment '{}' returned an empty embedding",
+                config.deployment
+            );
+        }
+
+        info!(
+            "Initialized Azure OpenAI provider with deployment: {} ({} dimensions)",
+            config.deployment, provider.dimension
+        );
+        Ok(provider)
+    }
+
+    /// Embeddings URL of the configured deployment
+    fn url(&self) -> String {
+        format!(
+            "{}/openai/deployments/{}/embeddings?api-version={}",
+            self.config.endpoint.trim_end_matches('/'),
+            self.config.deployment,
+            self.config.api_version
+        )
+    }
+
+    /// Embed one batch of texts, retrying failed requests with exponential
+    /// backoff
+    async fn request(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        let mut attempt = 0;
+        let mut backoff = INITIAL_BACKOFF;
+        loop {
+            self.rate_limiter.acquire(1).await?;
+            match self.send(texts).await {
+                Ok(embeddings) => return Ok(embeddings),
+                Err(e) if attempt >= self.config.max_retries => {
+                    return Err(e).context("Max retries exceeded");
+                }
+                Err(e) => {
+                    warn!(
+                        "Azure OpenAI request failed (attempt {}): {:#}",
+                        attempt + 1,
+                        e
+                    );
+                    tokio::time::sleep(backoff).await;
+                    backoff = (backoff * 2).min(MAX_BACKOFF);
+                    attempt += 1;
+                }
+            }
+        }
+    }
+
+    /// Send one embeddings request
+    async fn send(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        let url = self.url();
+        let request = self.client.post(&url).json(&EmbedRequest { input: texts });
+        let response = self
+            .credential
+            .authorize(&self.client, request)
+            .await?
+            .send()
+            .await
+            .with_context(|| format!("Azure OpenAI request to {} failed", url))?;
+        let status = response.status();
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            return Err(status_error("Azure OpenAI", status, &body));
+        }
+
+        let body: EmbedResponse = response
+            .json()
+            .await
+            .context("Failed to parse Azure OpenAI embeddings response")?;
+        into_embeddings(body, texts.len())
+    }
+}
+
+/// The embeddings of a response in input order
+fn into_embeddings(response: EmbedResponse, expected: usize) -> Result<Vec<Vec<f32>>> {
+    let mut data = response.data;
+    if data.len() != expected {
+        bail!(
+            "Azure OpenAI returned {} embeddings for {} texts",
+            data.len(),
+            expected
+        );
+    }
+    data.sort_by_key(|d| d.index);
+    Ok(data.into_iter().map(|d| d.embedding).collect())
+}
+
+#[async_trait]
+impl EmbeddingProvider for AzureOpenAIProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        if texts.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let mut all_embeddings = Vec::with_capacity(texts.len());
+        for batch in texts.chunks(self.max_batch_size()) {
+            all_embeddings.extend(self.request(batch).await?);
+        }
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        Ok(all_embeddings)
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let embedding = self
+            .request(&[query.to_string()])
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No embedding returned"));
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        embedding
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        self.dimension
+    }
+
+    fn provider_name(&self) -> &'static str {
+        "azure"
+    }
+
+    fn max_batch_size(&self) -> usize {
+        self.config.batch_size.clamp(1, MAX_BATCH_SIZE)
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        match self.embed_query(DIMENSION_PROBE).await {
+            Ok(_) => Ok(HealthStatus::Healthy),
+            Err(e) if matches!(Rejected::of(&e), Some(Rejected::RateLimited(_))) => {
+                Ok(HealthStatus::Degraded {
+                    reason: "Rate limited".to_string(),
+                })
+            }
+            Err(e) => Ok(HealthStatus::Unhealthy {
+                error: e.to_string(),
+            }),
+        }
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        ProviderCapabilities {
+            supports_batching: true,
+            supports_async: true,
+            requires_api_key: self.config.auth == AzureAuth::ApiKey,
+            is_local: false,
+            max_text_length: 8191,
+            // Pricing depends on the resource's agreement
+            cost_per_token: None,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn config() -> AzureOpenAIConfig {
+        AzureOpenAIConfig {
+            endpoint: "https://contoso.openai.azure.com/".to_string(),
+            deployment: "embeddings-small".to_string(),
+            api_version: "2024-02-01".to_string(),
+            auth: AzureAuth::ApiKey,
+            api_key: "secret".to_string(),
+            max_retries: 0,
+            timeout_secs: 30,
+            batch_size: 16,
+            requests_per_minute: 60,
+        }
+    }
+
+    #[test]
+    fn test_deployment_url() {
+        let provider = AzureOpenAIProvider {
+            client: reqwest::Client::new(),
+            config: config(),
+            credential: Credential::ApiKey("secret".to_string()),
+            rate_limiter: RateLimiter::new(1.0, 1.0),
+            dimension: 1536,
+        };
+        assert_eq!(
+            provider.url(),
+            "https://contoso.openai.azure.com/openai/deployments/embeddings-small/\
+             embeddings?api-version=2024-02-01"
+        );
+    }
+
+    #[test]
+    fn test_configured_api_key() {
+        match Credential::from_config(&config()).unwrap() {
+            Credential::ApiKey(key) => assert_eq!(key, "secret"),
+            _ => panic!("expected an API key credential"),
+        }
+    }
+
+    #[test]
+    fn test_embeddings_follow_input_order() {
+        let response: EmbedResponse = serde_json::from_value(serde_json::json!({
+            "object": "list",
+            "data": [
+                {"object": "embedding", "index": 1, "embedding": [0.0, 1.0]},
+                {"object": "embedding", "index": 0, "embedding": [1.0, 0.0]},
+            ],
+            "model": "text-embedding-3-small",
+        }))
+        .unwrap();
+
+        let embeddings = into_embeddings(response, 2).unwrap();
+        assert_eq!(embeddings, vec![vec![1.0, 0.0], vec![0.0, 1.0]]);
+    }
+}
@@ -0,0 +1,256 @@
+//! Adaptive embedding request batching
+//!
+//! Texts are grouped into requests bounded by both an item count and a
+//! total token budget, so a batch of long chunks does not exceed a
+//! provider's per-request token limit while short chunks still fill a
+//! request. When the provider rejects a request as too large
+//! ([`Rejected::TooLarge`]), both limits are halved and the rejected batch
+//! is split and retried; after a run of accepted requests they grow back.
+//!
+//! Rate-limited requests are not retried here: the batcher sends through
+//! the [`ResilientProvider`](super::resilience::ResilientProvider), which
+//! owns rate-limit retries, and fails with the error it gives up with.
+
+use anyhow::{bail, Result};
+use std::future::Future;
+use std::sync::atomic::{AtomicU32, AtomicUsize, Ordering};
+use std::sync::Arc;
+use tracing::{debug, warn};
+
+use super::provider::Rejected;
+use super::tokenizer::Tokenizer;
+
+/// Accepted requests in a row after which the limits are doubled again
+const GROW_AFTER: usize = 8;
+
+/// Most halvings of the limits
+const MAX_SHRINK: u32 = 16;
+
+/// Splits texts into requests and adapts their size to the backend
+pub struct AdaptiveBatcher {
+    max_items: usize,
+    max_tokens: usize,
+    tokenizer: Arc<dyn Tokenizer>,
+    /// How many times the limits are currently halved
+    shrink: AtomicU32,
+    /// Requests accepted since the limits last changed
+    accepted: AtomicUsize,
+}
+
+impl AdaptiveBatcher {
+    /// Batcher sending at most `max_items` texts and `max_tokens` tokens,
+    /// counted with `tokenizer`, per request
+    pub fn new(max_items: usize, max_tokens: usize, tokenizer: Arc<dyn Tokenizer>) -> Self {
+        Self {
+            max_items: max_items.max(1),
+            max_tokens: max_tokens.max(1),
+            tokenizer,
+            shrink: AtomicU32::new(0),
+            accepted: AtomicUsize::new(0),
+        }
+    }
+
+    /// Current item and token limits of a request
+    fn limits(&self) -> (usize, usize) {
+        let shrink = self.shrink.load(Ordering::Relaxed);
+        (
+            (self.max_items >> shrink).max(1),
+            (self.max_tokens >> shrink).max(1),
+        )
+    }
+
+    /// End of the batch starting at `start`: as many texts as fit both
+    /// limits, and always at least one
+    fn batch_end(&self, tokens: &[usize], start: usize) -> usize {
+        let (max_items, max_tokens) = self.limits();
+        let mut end = start;
+        let mut total = 0;
+        while end < tokens.len() && end - start < max_items {
+            if end > start && total + tokens[end] > max_tokens {
+                break;
+            }
+            total += tokens[end];
+            end += 1;
+        }
+        end
+    }
+
+    /// Halve the limits, unless they are already at their smallest
+    fn shrink(&self) {
+        self.accepted.store(0, Ordering::Relaxed);
+        let shrink = self.shrink.load(Ordering::Relaxed);
+        if shrink < MAX_SHRINK {
+            self.shrink.store(shrink + 1, Ordering::Relaxed);
+        }
+        let (items, tokens) = self.limits();
+        debug!(
+            "Embedding batches reduced to {} texts / {} tokens",
+            items, tokens
+        );
+    }
+
+    /// Record an accepted request, doubling the limits after a run of them
+    fn accept(&self) {
+        if self.accepted.fetch_add(1, Ordering::Relaxed) + 1 < GROW_AFTER {
+            return;
+        }
+        self.accepted.store(0, Ordering::Relaxed);
+        let shrink = self.shrink.load(Ordering::Relaxed);
+        if shrink > 0 {
+            self.shrink.store(shrink - 1, Ordering::Relaxed);
+        }
+    }
+
+    /// Embed `texts` with `embed`, one request per batch, in order
+    pub async fn embed<F, Fut>(&self, texts: &[String], mut embed: F) -> Result<Vec<Vec<f32>>>
+    where
+        F: FnMut(Vec<String>) -> Fut,
+        Fut: Future<Output = Result<Vec<Vec<f32>>>>,
+    {
+        // Without a token budget counting is wasted work
+        let tokens: Vec<usize> = if self.max_tokens == usize::MAX {
+            vec![0; texts.len()]
+        } else {
+            texts
+                .iter()
+                .map(|t| self.tokenizer.count_tokens(t))
+                .collect()
+        };
+
+        let mut embeddings = Vec::with_capacity(texts.len());
+        let mut start = 0;
+        while start < texts.len() {
+            let end = self.batch_end(&tokens, start);
+            let error = match embed(texts[start..end].to_vec()).await {
+                Ok(batch) => {
+                    embeddings.extend(batch);
+                    start = end;
+                    self.accept();
+                    continue;
+                }
+                Err(e) => e,
+            };
+
+            match Rejected::of(&error) {
+                Some(Rejected::TooLarge(_)) if end - start > 1 => {
+                    warn!(
+                        "Embedding request of {} texts was too large, splitting it",
+                        end - start
+                    );
+                    self.shrink();
+                }
+                _ => return Err(error),
+            }
+        }
+
+        if embeddings.len() != texts.len() {
+            bail!("Embedded {} of {} texts", embeddings.len(), texts.len());
+        }
+        Ok(embeddings)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::embeddings::WhitespaceTokenizer;
+    use std::sync::Mutex;
+
+    fn too_large() -> anyhow::Error {
+        Rejected::TooLarge("TEI returned 413 Payload Too Large: batch too big".to_string()).into()
+    }
+
+    fn texts(words: &[usize]) -> Vec<String> {
+        words.iter().map(|n| vec!["word"; *n].join(" ")).collect()
+    }
+
+    #[test]
+    fn test_batches_respect_items_and_tokens() {
+        let batcher = AdaptiveBatcher::new(3, 10, Arc::new(WhitespaceTokenizer::new()));
+        let tokens = [4, 4, 4, 1, 1, 1, 1, 20, 2];
+
+        let mut ends = Vec::new();
+        let mut start = 0;
+        while start < tokens.len() {
+            start = batcher.batch_end(&tokens, start);
+            ends.push(start);
+        }
+        // A text over the token budget is sent on its own
+        assert_eq!(ends, vec![2, 5, 7, 8, 9]);
+    }
+
+    #[test]
+    fn test_limits_shrink_and_grow() {
+        let batcher = AdaptiveBatcher::new(64, 1000, Arc::new(WhitespaceTokenizer::new()));
+        batcher.shrink();
+        batcher.shrink();
+        assert_eq!(batcher.limits(), (16, 250));
+
+        for _ in 0..GROW_AFTER {
+            batcher.accept();
+        }
+        assert_eq!(batcher.limits(), (32, 500));
+    }
+
+    #[tokio::test]
+    async fn test_too_large_requests_are_split() {
+        let batcher = AdaptiveBatcher::new(8, usize::MAX, Arc::new(WhitespaceTokenizer::new()));
+        let sizes = Mutex::new(Vec::new());
+
+        let embeddings = batcher
+            .embed(&texts(&[1; 8]), |batch| {
+                sizes.lock().unwrap().push(batch.len());
+                async move {
+                    if batch.len() > 2 {
+                        return Err(too_large());
+                    }
+                    Ok(batch.iter().map(|_| vec![1.0]).collect())
+                }
+            })
+            .await
+            .unwrap();
+
+        assert_eq!(embeddings.len(), 8);
+        assert_eq!(*sizes.lock().unwrap(), vec![8, 4, 2, 2, 2, 2]);
+    }
+
+    #[tokio::test]
+    async fn test_single_text_too_large_fails() {
+        let batcher = AdaptiveBatcher::new(8, usize::MAX, Arc::new(WhitespaceTokenizer::new()));
+        let result = batcher
+            .embed(&texts(&[1]), |_| async { Err(too_large()) })
+            .await;
+        assert!(result.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_rate_limited_requests_are_not_retried() {
+        let batcher = AdaptiveBatcher::new(8, usize::MAX, Arc::new(WhitespaceTokenizer::new()));
+        let calls = Mutex::new(0);
+        let result = batcher
+            .embed(&texts(&[1; 8]), |_| {
+                *calls.lock().unwrap() += 1;
+                async { Err(Rejected::RateLimited("429 Too Many Requests".to_string()).into()) }
+            })
+            .await;
+
+        assert!(matches!(
+            Rejected::of(&result.unwrap_err()),
+            Some(Rejected::RateLimited(_))
+        ));
+        assert_eq!(*calls.lock().unwrap(), 1);
+        assert_eq!(batcher.limits(), (8, usize::MAX));
+    }
+
+    #[tokio::test]
+    async fn test_other_errors_are_not_split() {
+        let batcher = AdaptiveBatcher::new(8, usize::MAX, Arc::new(WhitespaceTokenizer::new()));
+        let result = batcher
+            .embed(&texts(&[1; 8]), |_| async {
+                bail!("TEI request failed: connection reset")
+            })
+            .await;
+        assert!(result.is_err());
+        assert_eq!(batcher.limits(), (8, usize::MAX));
+    }
+}
@@ -0,0 +1,390 @@
+//! AWS Bedrock embedding provider
+//!
+//! Embeds with Amazon Titan or Cohere models through Bedrock's
+//! `InvokeModel` API. Credentials and region come from the standard AWS
+//! chain (environment, shared config and credential files, SSO, web
+//! identity, container and instance metadata), optionally pinned to a named
+//! profile and region.
+//!
+//! The model may be a foundation model id (`amazon.titan-embed-text-v2:0`),
+//! a cross-region inference profile id (`us.cohere.embed-english-v3`) or an
+//! inference profile ARN; the request format is chosen from the model
+//! family in it.
+
+use anyhow::{anyhow, bail, Context, Result};
+use async_trait::async_trait;
+use aws_config::retry::RetryConfig;
+use aws_config::{BehaviorVersion, Region};
+use aws_sdk_bedrockruntime::error::DisplayErrorContext;
+use aws_sdk_bedrockruntime::primitives::Blob;
+use aws_sdk_bedrockruntime::Client;
+use futures::stream::{self, StreamExt, TryStreamExt};
+use serde::de::DeserializeOwned;
+use serde::{Deserialize, Serialize};
+use std::time::Instant;
+use tracing::info;
+
+use super::config::BedrockConfig;
+use super::provider::{EmbeddingProvider, HealthStatus, ProviderCapabilities, Rejected};
+use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+
+/// Text embedded once at startup to check access and learn the dimension
+const DIMENSION_PROBE: &str = "dimension probe";
+
+/// Most texts Cohere accepts in one request
+const COHERE_MAX_BATCH: usize = 96;
+
+/// Longest text Cohere accepts, in characters
+const COHERE_MAX_CHARS: usize = 2048;
+
+/// Titan embeds one text per request; this many run at once
+const TITAN_CONCURRENCY: usize = 8;
+
+/// Request format of a Bedrock embedding model
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum ModelFamily {
+    Titan,
+    Cohere,
+}
+
+impl ModelFamily {
+    /// Family of a model id, inference profile id or ARN
+    fn of(model: &str) -> Option<Self> {
+        let id = model.rsplit('/').next().unwrap_or(model);
+        if id.contains("amazon.titan-embed") {
+            Some(Self::Titan)
+        } else if id.contains("cohere.embed") {
+            Some(Self::Cohere)
+        } else {
+            None
+        }
+    }
+}
+
+#[derive(Serialize)]
+#[serde(rename_all = "camelCase")]
+struct TitanRequest<'a> {
+    input_text: &'a str,
+}
+
+#[derive(Deserialize)]
+struct TitanResponse {
+    embedding: Vec<f32>,
+}
+
+#[derive(Serialize)]
+struct CohereRequest<'a> {
+    texts: Vec<&'a str>,
+    input_type: &'static str,
+    /// Cut inputs longer than the model's context instead of failing
+    truncate: &'static str,
+}
+
+#[derive(Deserialize)]
+struct CohereResponse {
+    embeddings: Vec<Vec<f32>>,
+}
+
+/// AWS Bedrock embedding provider implementation
+pub struct BedrockProvider {
+    client: Client,
+    config: BedrockConfig,
+    family: ModelFamily,
+    dimension: usize,
+}
+
+impl BedrockProvider {
+    /// Load AWS credentials and embed a probe text to learn the model's
+    /// dimension.
+    ///
+    /// Fails in offline mode, for models of an unsupported family, without
+    /// a region, and when the model cannot be invoked.
+    pub async fn new(config: &BedrockConfig) -> Result<Self> {
+        crate::offline::ensure_network_allowed("embeddings", "bedrock")?;
+
+        let family = ModelFamily::of(&config.model).ok_or_else(|| {
+            anyhow!(
+                "Unsupported Bedrock embedding model '{}': use an Amazon Titan or Cohere \
+                 embedding model",
+                config.model
+            )
+        })?;
+
+        let mut loader = aws_config::defaults(BehaviorVersion::latest())
+            .retry_config(RetryConfig::standard().with_max_attempts(config.max_retries as u32 + 1));
+        if let Some(region) = &config.region {
+            loader = loader.region(Region::new(region.clone()));
+        }
+        if let Some(profile) = &config.profile {
+            loader = loader.profile_name(profile);
+        }
+        let sdk_config = loader.load().await;
+        if sdk_config.region().is_none() {
+            bail!("No AWS region configured: set embeddings.bedrock_region or AWS_REGION");
+        }
+
+        let mut provider = Self {
+            client: Client::new(&sdk_config),
+            config: config.clone(),
+            family,
+            dimension: 0,
+        };
+        let probe = provider
+            .embed_query(DIMENSION_PROBE)
+            .await
+            .with_context(|| format!("Failed to embed with Bedrock model '{}'", config.model))?;
+        if probe.is_empty() {
+            bail!(
+                "Bedrock model '{}' returned an empty embedding",
+                config.model
+            );
+        }
+        provider.dimension = probe.len();
+
+        info!(
+            "Initialized Bedrock provider with model: {} ({} dimensions)",
+            config.model, provider.dimension
+        );
+        Ok(provider)
+    }
+
+    /// Embedding dimension of a known model, or None for unknown names.
+    ///
+    /// Inference profile ids and ARNs resolve to their model.
+    pub(crate) fn known_model_dimension(model: &str) -> Option<usize> {
+        let id = model.rsplit('/').next().unwrap_or(model);
+        [
+            ("amazon.titan-embed-text-v2:0", 1024),
+            ("amazon.titan-embed-text-v1", 1536),
+            ("amazon.titan-embed-image-v1", 1024),
+            ("cohere.embed-english-v3", 1024),
+            ("cohere.embed-multilingual-v3", 1024),
+        ]
+        .into_iter()
+        .find(|(name, _)| id == *name || id.ends_with(&format!(".{}", name)))
+        .map(|(_, dimension)| dimension)
+    }
+
+    /// Invoke the model with a JSON `body`
+    async fn invoke<T: DeserializeOwned>(&self, body: &impl Serialize) -> Result<T> {
+        let output = self
+            .client
+            .invoke_model()
+            .model_id(&self.config.model)
+            .content_type("application/json")
+            .accept("application/json")
+            .body(Blob::new(serde_json::to_vec(body)?))
+            .send()
+            .await
+            .map_err(|e| {
+                let message = format!("Bedrock request failed: {}", DisplayErrorContext(&e));
+                if e.as_service_error()
+                    .is_some_and(|error| error.is_throttling_exception())
+                {
+                    Rejected::RateLimited(message).into()
+                } else {
+                    anyhow!(message)
+                }
+            })?;
+        serde_json::from_slice(output.body().as_ref())
+            .context("Failed to parse Bedrock embedding response")
+    }
+
+    /// Embed `texts` as documents or, with `query`, as search queries
+    async fn embed_texts(&self, texts: &[String], query: bool) -> Result<Vec<Vec<f32>>> {
+        match self.family {
+            ModelFamily::Titan => {
+                stream::iter(texts)
+                    .map(|text| async move {
+                        let response: TitanResponse =
+                            self.invoke(&TitanRequest { input_text: text }).await?;
+                        Ok::<_, anyhow::Error>(response.embedding)
+                    })
+                    .buffered(TITAN_CONCURRENCY)
+                    .try_collect()
+                    .await
+            }
+            ModelFamily::Cohere => {
+                let mut all_embeddings = Vec::with_capacity(texts.len());
+                for batch in texts.chunks(self.max_batch_size()) {
+                    let request = CohereRequest {
+                        texts: batch.iter().map(|t| cohere_input(t)).collect(),
+                        input_type: if query {
+                            "search_query"
+                        } else {
+                            "search_document"
+                        },
+                        truncate: "END",
+                    };
+                    let response: CohereResponse = self.invoke(&request).await?;
+                    if response.embeddings.len() != batch.len() {
+                        bail!(
+                            "Bedrock returned {} embeddings for {} texts",
+                            response.embeddings.len(),
+                            batch.len()
+                        );
+                    }
+                    all_embeddings.extend(response.embeddings);
+                }
+                Ok(all_embeddings)
+            }
+        }
+    }
+}
+
+/// `text` cut to the characters Cohere accepts
+fn cohere_input(text: &str) -> &str {
+    match text.char_indices().nth(COHERE_MAX_CHARS) {
+        Some((end, _)) => &text[..end],
+        None => text,
+    }
+}
+
+#[async_trait]
+impl EmbeddingProvider for BedrockProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        if texts.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+        let embeddings = self.embed_texts(texts, false).await;
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        embeddings
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let embedding = self
+            .embed_texts(&[query.to_string()], true)
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No embedding returned"));
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        embedding
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        self.dimension
+    }
+
+    fn provider_name(&self) -> &'static str {
+        "bedrock"
+    }
+
+    fn max_batch_size(&self) -> usize {
+        match self.family {
+            ModelFamily::Titan => self.config.batch_size.max(1),
+            ModelFamily::Cohere => self.config.batch_size.clamp(1, COHERE_MAX_BATCH),
+        }
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        match self.embed_query(DIMENSION_PROBE).await {
+            Ok(_) => Ok(HealthStatus::Healthy),
+            Err(e) if matches!(Rejected::of(&e), Some(Rejected::RateLimited(_))) => {
+                Ok(HealthStatus::Degraded {
+                    reason: "Rate limited".to_string(),
+                })
+            }
+            Err(e) => Ok(HealthStatus::Unhealthy {
+                error: e.to_string(),
+            }),
+        }
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        ProviderCapabilities {
+            supports_batching: self.family == ModelFamily::Cohere,
+            supports_async: true,
+            // AWS credentials rather than an API key
+            requires_api_key: false,
+            is_local: false,
+            max_text_length: match self.family {
+                ModelFamily::Titan => 8192,
+                ModelFamily::Cohere => 512,
+            },
+            cost_per_token: None,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_model_family() {
+        assert_eq!(
+            ModelFamily::of("amazon.titan-embed-text-v2:0"),
+            Some(ModelFamily::Titan)
+        );
+        assert_eq!(
+            ModelFamily::of("eu.cohere.embed-multilingual-v3"),
+            Some(ModelFamily::Cohere)
+        );
+        assert_eq!(
+            ModelFamily::of(
+                "arn:aws:bedrock:us-east-1:123456789012:inference-profile/\
+                 us.amazon.titan-embed-text-v2:0"
+            ),
+            Some(ModelFamily::Titan)
+        );
+        assert_eq!(ModelFamily::of("anthropic.claude-v2"), None);
+    }
+
+    #[test]
+    fn test_model_dimension() {
+        assert_eq!(
+            BedrockProvider::known_model_dimension("amazon.titan-embed-text-v1"),
+            Some(1536)
+        );
+        assert_eq!(
+            BedrockProvider::known_model_dimension("us.cohere.embed-english-v3"),
+            Some(1024)
+        );
+        assert_eq!(
+            BedrockProvider::known_model_dimension(
+                "arn:aws:bedrock:us-east-1:123456789012:inference-profile/\
+                 us.amazon.titan-embed-text-v2:0"
+            ),
+            Some(1024)
+        );
+        assert_eq!(
+            BedrockProvider::known_model_dimension("cohere.embed-v9"),
+            None
+        );
+    }
+
+    #[test]
+    fn test_request_bodies() {
+        assert_eq!(
+            serde_json::to_value(TitanRequest {
+                input_text: "fn main() {}"
+            })
+            .unwrap(),
+            serde_json::json!({"inputText": "fn main() {}"})
+        );
+        assert_eq!(
+            serde_json::to_value(CohereRequest {
+                texts: vec!["fn main() {}"],
+                input_type: "search_document",
+                truncate: "END",
+            })
+            .unwrap(),
+            serde_json::json!({
+                "texts": ["fn main() {}"],
+                "input_type": "search_document",
+                "truncate": "END",
+            })
+        );
+
+        let long = "é".repeat(COHERE_MAX_CHARS + 5);
+        assert_eq!(cohere_input(&long).chars().count(), COHERE_MAX_CHARS);
+    }
+}
@@ -0,0 +1,248 @@
+//! Persistent embedding cache
+//!
+//! Embeddings are kept on disk keyed by the model and a hash of the embedded
+//! text, so re-indexing after a refactor only embeds chunks whose text
+//! changed, and rebuilding an index in another vector store does not call
+//! the embedding API again.
+//!
+//! Each model has one append-only file: a header naming the model and its
+//! dimension, then fixed-size records of a text hash and its vector. Only
+//! the record offsets are held in memory; vectors are read back on a hit.
+
+use anyhow::{Context, Result};
+use std::collections::HashMap;
+use std::fs::{File, OpenOptions};
+use std::io::{BufReader, Read, Seek, SeekFrom, Write};
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+use tracing::{debug, warn};
+
+/// Start of every cache file, versioning the format
+const MAGIC: &[u8; 8] = b"CRAGEMB1";
+
+/// Embeddings of one model, stored in a file in the cache directory
+pub struct EmbeddingCache {
+    path: PathBuf,
+    dimension: usize,
+    state: Mutex<State>,
+}
+
+struct State {
+    file: File,
+    /// Offset of the vector of each cached text hash
+    offsets: HashMap<u64, u64>,
+}
+
+/// Hash of an embedded text, the cache key within a model. It must not
+/// change between builds, or every cached embedding would miss.
+fn text_hash(text: &str) -> u64 {
+    crate::seed::stable_hash(text)
+}
+
+/// Header of the cache file of `model`
+fn header(model: &str, dimension: usize) -> Vec<u8> {
+    let mut header = MAGIC.to_vec();
+    header.extend((dimension as u32).to_le_bytes());
+    header.extend((model.len() as u32).to_le_bytes());
+    header.extend(model.as_bytes());
+    header
+}
+
+impl EmbeddingCache {
+    /// Open the cache of `model`, which embeds into `dimension` dimensions,
+    /// in `dir`.
+    ///
+    /// A missing file is created; one written for another dimension or
+    /// damaged is started over, and a record cut short by an interrupted
+    /// write is dropped.
+    pub fn open(dir: &Path, model: &str, dimension: usize) -> Result<Self> {
+        std::fs::create_dir_all(dir)
+            .with_context(|| format!("Failed to create {}", dir.display()))?;
+        let path = dir.join(format!("{:016x}.bin", text_hash(model)));
+        let mut file = OpenOptions::new()
+            .read(true)
+            .append(true)
+            .create(true)
+            .open(&path)
+            .with_context(|| format!("Failed to open {}", path.display()))?;
+
+        let header = header(model, dimension);
+        let mut existing = vec![0; header.len()];
+        let matches = file.read_exact(&mut existing).is_ok() && existing == header;
+        if !matches {
+            if file.metadata()?.len() > 0 {
+                warn!(
+                    "Starting over the embedding cache {}: it does not match {}",
+                    path.display(),
+                    model
+                );
+            }
+            file.set_len(0)?;
+            file.write_all(&header)?;
+        }
+
+        let record_len = 8 + dimension as u64 * 4;
+        let header_len = header.len() as u64;
+        let records = (file.metadata()?.len() - header_len) / record_len;
+        let end = header_len + records * record_len;
+        if file.metadata()?.len() > end {
+            file.set_len(end)?;
+        }
+
+        let mut offsets = HashMap::with_capacity(records as usize);
+        let mut reader = BufReader::new(&file);
+        reader.seek(SeekFrom::Start(header_len))?;
+        let mut hash = [0; 8];
+        for record in 0..records {
+            reader.read_exact(&mut hash)?;
+            reader.seek_relative(dimension as i64 * 4)?;
+            let offset = header_len + record * record_len + 8;
+            offsets.insert(u64::from_le_bytes(hash), offset);
+        }
+        debug!(
+            "Opened embedding cache {} with {} embeddings",
+            path.display(),
+            offsets.len()
+        );
+
+        Ok(Self {
+            path,
+            dimension,
+            state: Mutex::new(State { file, offsets }),
+        })
+    }
+
+    /// Cached embedding of each of `texts`, or None for texts not cached
+    pub fn get(&self, texts: &[String]) -> Result<Vec<Option<Vec<f32>>>> {
+        let mut state = self.state.lock().unwrap();
+        let mut bytes = vec![0; self.dimension * 4];
+        let mut embeddings = Vec::with_capacity(texts.len());
+        for text in texts {
+            let Some(&offset) = state.offsets.get(&text_hash(text)) else {
+                embeddings.push(None);
+                continue;
+            };
+            state.file.seek(SeekFrom::Start(offset))?;
+            state
+                .file
+                .read_exact(&mut bytes)
+                .with_context(|| format!("Failed to read {}", self.path.display()))?;
+            let embedding = bytes
+                .chunks_exact(4)
+                .map(|b| f32::from_le_bytes([b[0], b[1], b[2], b[3]]))
+                .collect();
+            embeddings.push(Some(embedding));
+        }
+        Ok(embeddings)
+    }
+
+    /// Add the embeddings of `texts`; ones of the wrong dimension are
+    /// skipped
+    pub fn insert(&self, texts: &[String], embeddings: &[Vec<f32>]) -> Result<()> {
+        let mut buffer = Vec::new();
+        let mut hashes = Vec::new();
+        for (text, embedding) in texts.iter().zip(embeddings) {
+            if embedding.len() != self.dimension {
+                continue;
+            }
+            let hash = text_hash(text);
+            buffer.extend(hash.to_le_bytes());
+            buffer.extend(embedding.iter().flat_map(|x| x.to_le_bytes()));
+            hashes.push(hash);
+        }
+        if hashes.is_empty() {
+            return Ok(());
+        }
+
+        let mut state = self.state.lock().unwrap();
+        state
+            .file
+            .write_all(&buffer)
+            .with_context(|| format!("Failed to write {}", self.path.display()))?;
+        // Appends land at the end of the file, wherever another process
+        // left it, so the records' offsets follow from where this one ended
+        let start = state.file.stream_position()? - buffer.len() as u64;
+        let record_len = 8 + self.dimension as u64 * 4;
+        for (i, hash) in hashes.into_iter().enumerate() {
+            state
+                .offsets
+                .insert(hash, start + i as u64 * record_len + 8);
+        }
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn texts(texts: &[&str]) -> Vec<String> {
+        texts.iter().map(|t| t.to_string()).collect()
+    }
+
+    #[test]
+    fn test_text_hash_is_fixed() {
+        // Changing these orphans every cache already on disk
+        assert_eq!(text_hash("fn main() {}"), 0xcd8d_73ce_6d11_e223);
+        assert_eq!(text_hash("fastembed/bge"), 0xd665_d430_82e7_b3e9);
+    }
+
+    #[test]
+    fn test_embeddings_survive_reopening() {
+        let dir = tempfile::tempdir().unwrap();
+        let cache = EmbeddingCache::open(dir.path(), "fastembed/bge", 2).unwrap();
+        assert_eq!(cache.get(&texts(&["a"])).unwrap(), vec![None]);
+
+        cache
+            .insert(&texts(&["a", "b"]), &[vec![1.0, 2.0], vec![3.0, 4.0]])
+            .unwrap();
+        drop(cache);
+
+        let cache = EmbeddingCache::open(dir.path(), "fastembed/bge", 2).unwrap();
+        assert_eq!(
+            cache.get(&texts(&["b", "c", "a"])).unwrap(),
+            vec![Some(vec![3.0, 4.0]), None, Some(vec![1.0, 2.0])]
+        );
+    }
+
+    #[test]
+    fn test_models_do_not_share_embeddings() {
+        let dir = tempfile::tempdir().unwrap();
+        let cache = EmbeddingCache::open(dir.path(), "openai/small", 2).unwrap();
+        cache.insert(&texts(&["a"]), &[vec![1.0, 2.0]]).unwrap();
+
+        let other = EmbeddingCache::open(dir.path(), "openai/large", 2).unwrap();
+        assert_eq!(other.get(&texts(&["a"])).unwrap(), vec![None]);
+    }
+
+    #[test]
+    fn test_damaged_files_are_repaired() {
+        let dir = tempfile::tempdir().unwrap();
+        let cache = EmbeddingCache::open(dir.path(), "m", 2).unwrap();
+        cache
+            .insert(&texts(&["a", "b"]), &[vec![1.0, 2.0], vec![3.0, 4.0]])
+            .unwrap();
+        let path = cache.path.clone();
+        drop(cache);
+
+        // A record cut short is dropped, the complete one kept
+        let len = std::fs::metadata(&path).unwrap().len();
+        File::options()
+            .write(true)
+            .open(&path)
+            .unwrap()
+            .set_len(len - 3)
+            .unwrap();
+        let cache = EmbeddingCache::open(dir.path(), "m", 2).unwrap();
+        cache.insert(&texts(&["c"]), &[vec![5.0, 6.0]]).unwrap();
+        assert_eq!(
+            cache.get(&texts(&["a", "b", "c"])).unwrap(),
+            vec![Some(vec![1.0, 2.0]), None, Some(vec![5.0, 6.0])]
+        );
+        drop(cache);
+
+        // A file of another dimension starts over
+        let cache = EmbeddingCache::open(dir.path(), "m", 3).unwrap();
+        assert_eq!(cache.get(&texts(&["a"])).unwrap(), vec![None]);
+    }
+}
@@ -0,0 +1,295 @@
+//! Cohere embedding provider
+//!
+//! Embeds with Cohere models such as `embed-v4.0` through the v2 `/embed`
+//! API. Chunks are sent with `input_type = "search_document"` and queries
+//! with `"search_query"`, as Cohere's retrieval models expect.
+
+use anyhow::{anyhow, bail, Context, Result};
+use async_trait::async_trait;
+use serde::{Deserialize, Serialize};
+use std::time::{Duration, Instant};
+use tracing::{info, warn};
+
+use super::config::CohereConfig;
+use super::provider::{
+    status_error, EmbeddingProvider, HealthStatus, ProviderCapabilities, Rejected,
+};
+use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+
+const EMBED_URL: &str = "https://api.cohere.com/v2/embed";
+
+/// Most texts Cohere embeds in one request
+const MAX_BATCH_SIZE: usize = 96;
+
+const INITIAL_BACKOFF: Duration = Duration::from_millis(1000);
+const MAX_BACKOFF: Duration = Duration::from_secs(60);
+
+#[derive(Serialize)]
+struct EmbedRequest<'a> {
+    model: &'a str,
+    texts: &'a [String],
+    input_type: &'static str,
+    embedding_types: [&'static str; 1],
+    /// Cut inputs longer than the model's context instead of failing
+    truncate: &'static str,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    output_dimension: Option<u32>,
+}
+
+#[derive(Deserialize)]
+struct EmbedResponse {
+    embeddings: Embeddings,
+}
+
+#[derive(Deserialize)]
+struct Embeddings {
+    float: Vec<Vec<f32>>,
+}
+
+/// Cohere embedding provider implementation
+pub struct CohereProvider {
+    client: reqwest::Client,
+    config: CohereConfig,
+    api_key: String,
+}
+
+impl CohereProvider {
+    /// Create a new Cohere provider.
+    ///
+    /// Fails in offline mode, without an API key, and for models whose
+    /// dimension is unknown.
+    pub async fn new(config: &CohereConfig) -> Result<Self> {
+        crate::offline::ensure_network_allowed("embeddings", "cohere")?;
+
+        if Self::dimension_of(config).is_none() {
+            bail!(
+                "Unknown Cohere model '{}': set embeddings.cohere_dimensions",
+                config.model
+            );
+        }
+        let api_key = config
+            .load_api_key()
+            .context("Failed to load Cohere API key")?;
+        let client = reqwest::Client::builder()
+            .timeout(Duration::from_secs(config.timeout_secs))
+            .build()
+            .context("Failed to create HTTP client")?;
+
+        info!("Initialized Cohere provider with model: {}", config.model);
+        Ok(Self {
+            client,
+            config: config.clone(),
+            api_key,
+        })
+    }
+
+    /// Default embedding dimension of a known model, or None for unknown
+    /// names
+    pub(crate) fn known_model_dimension(model: &str) -> Option<usize> {
+        match model {
+            "embed-v4.0" => Some(1536),
+            "embed-english-v3.0" | "embed-multilingual-v3.0" => Some(1024),
+            "embed-english-light-v3.0" | "embed-multilingual-light-v3.0" => Some(384),
+            _ => None,
+        }
+    }
+
+    /// Dimension of the configured model and output dimension
+    fn dimension_of(config: &CohereConfig) -> Option<usize> {
+        config
+            .dimensions
+            .map(|d| d as usize)
+            .or_else(|| Self::known_model_dimension(&config.model))
+    }
+
+    /// Embed one batch as `input_type`, retrying failed requests with
+    /// exponential backoff
+    async fn request(&self, texts: &[String], input_type: &'static str) -> Result<Vec<Vec<f32>>> {
+        let mut attempt = 0;
+        let mut backoff = INITIAL_BACKOFF;
+        loop {
+            match self.send(texts, input_type).await {
+                Ok(embeddings) => return Ok(embeddings),
+                Err(e) if attempt >= self.config.max_retries => {
+                    return Err(e).context("Max retries exceeded");
+                }
+                Err(e) => {
+                    warn!("Cohere request failed (attempt {}): {:#}", attempt + 1, e);
+                    tokio::time::sleep(backoff).await;
+                    backoff = (backoff * 2).min(MAX_BACKOFF);
+                    attempt += 1;
+                }
+            }
+        }
+    }
+
+    /// Send one embed request
+    async fn send(&self, texts: &[String], input_type: &'static str) -> Result<Vec<Vec<f32>>> {
+        let request = EmbedRequest {
+            model: &self.config.model,
+            texts,
+            input_type,
+            embedding_types: ["float"],
+            truncate: "END",
+            output_dimension: self.config.dimensions,
+        };
+        let response = self
+            .client
+            .post(EMBED_URL)
+            .bearer_auth(&self.api_key)
+            .json(&request)
+            .send()
+            .await
+            .context("Cohere request failed")?;
+        let status = response.status();
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            return Err(status_error("Cohere", status, &body));
+        }
+
+        let embeddings = response
+            .json::<EmbedResponse>()
+            .await
+            .context("Failed to parse Cohere embed response")?
+            .embeddings
+            .float;
+        if embeddings.len() != texts.len() {
+            bail!(
+                "Cohere returned {} embeddings for {} texts",
+                embeddings.len(),
+                texts.len()
+            );
+        }
+        Ok(embeddings)
+    }
+}
+
+#[async_trait]
+impl EmbeddingProvider for CohereProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        if texts.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let mut all_embeddings = Vec::with_capacity(texts.len());
+        for batch in texts.chunks(self.max_batch_size()) {
+            all_embeddings.extend(self.request(batch, "search_document").await?);
+        }
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        Ok(all_embeddings)
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let embedding = self
+            .request(&[query.to_string()], "search_query")
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No embedding returned"));
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        embedding
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        Self::dimension_of(&self.config).unwrap_or_default()
+    }
+
+    fn provider_name(&self) -> &'static str {
+        "cohere"
+    }
+
+    fn max_batch_size(&self) -> usize {
+        self.config.batch_size.clamp(1, MAX_BATCH_SIZE)
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        match self.embed_query("test").await {
+            Ok(_) => Ok(HealthStatus::Healthy),
+            Err(e) if matches!(Rejected::of(&e), Some(Rejected::RateLimited(_))) => {
+                Ok(HealthStatus::Degraded {
+                    reason: "Rate limited".to_string(),
+                })
+            }
+            Err(e) => Ok(HealthStatus::Unhealthy {
+                error: e.to_string(),
+            }),
+        }
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        ProviderCapabilities {
+            supports_batching: true,
+            supports_async: true,
+            requires_api_key: true,
+            is_local: false,
+            max_text_length: if self.config.model.starts_with("embed-v4") {
+                128_000
+            } else {
+                512
+            },
+            cost_per_token: match self.config.model.as_str() {
+                "embed-v4.0" => Some(0.00012), // $0.12 per 1M tokens
+                _ => Some(0.0001),             // $0.10 per 1M tokens
+            },
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_model_dimension() {
+        let mut config = CohereConfig::default();
+        assert_eq!(config.model, "embed-v4.0");
+        assert_eq!(CohereProvider::dimension_of(&config), Some(1536));
+
+        config.dimensions = Some(512);
+        assert_eq!(CohereProvider::dimension_of(&config), Some(512));
+
+        config.model = "embed-v9".to_string();
+        config.dimensions = None;
+        assert_eq!(CohereProvider::dimension_of(&config), None);
+    }
+
+    #[test]
+    fn test_request_body_and_response() {
+        let texts = vec!["fn main() {}".to_string()];
+        let request = EmbedRequest {
+            model: "embed-v4.0",
+            texts: &texts,
+            input_type: "search_query",
+            embedding_types: ["float"],
+            truncate: "END",
+            output_dimension: Some(1024),
+        };
+        assert_eq!(
+            serde_json::to_value(&request).unwrap(),
+            serde_json::json!({
+                "model": "embed-v4.0",
+                "texts": ["fn main() {}"],
+                "input_type": "search_query",
+                "embedding_types": ["float"],
+                "truncate": "END",
+                "output_dimension": 1024,
+            })
+        );
+
+        let response: EmbedResponse = serde_json::from_value(serde_json::json!({
+            "id": "a1",
+            "embeddings": {"float": [[0.5, 0.25]]},
+            "texts": ["fn main() {}"],
+        }))
+        .unwrap();
+        assert_eq!(response.embeddings.float, vec![vec![0.5, 0.25]]);
+    }
+}
@@ -1,12 +1,22 @@
 use serde::{Deserialize, Serialize};
 use std::path::PathBuf;
 
+use crate::config::{AzureAuth, OnnxPooling};
+
 /// Provider type enumeration
 #[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
 #[serde(rename_all = "lowercase")]
 pub enum ProviderType {
     FastEmbed,
     OpenAI,
+    Ollama,
+    Azure,
+    Bedrock,
+    Gemini,
+    Voyage,
+    Cohere,
+    Onnx,
+    Tei,
 }
 
 impl Default for ProviderType {
@@ -20,6 +30,14 @@ impl std::fmt::Display for ProviderType {
         match self {
             Self::FastEmbed => write!(f, "fastembed"),
             Self::OpenAI => write!(f, "openai"),
+            Self::Ollama => write!(f, "ollama"),
+            Self::Azure => write!(f, "azure"),
+            Self::Bedrock => write!(f, "bedrock"),
+            Self::Gemini => write!(f, "gemini"),
+            Self::Voyage => write!(f, "voyage"),
+            Self::Cohere => write!(f, "cohere"),
+            Self::Onnx => write!(f, "onnx"),
+            Self::Tei => write!(f, "tei"),
         }
     }
 }
@@ -62,6 +80,30 @@ pub struct ProvidersConfig {
 
     #[serde(skip_serializing_if = "Option::is_none")]
     pub openai: Option<OpenAIConfig>,
+
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub ollama: Option<OllamaConfig>,
+
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub azure: Option<AzureOpenAIConfig>,
+
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub bedrock: Option<BedrockConfig>,
+
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub gemini: Option<GeminiConfig>,
+
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub voyage: Option<VoyageConfig>,
+
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub cohere: Option<CohereConfig>,
+
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub onnx: Option<OnnxConfig>,
+
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub tei: Option<TeiConfig>,
 }
 
 /// FastEmbed provider configuration
@@ -95,6 +137,39 @@ fn default_batch_size() -> usize {
     32
 }
 
+/// Local ONNX provider configuration
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct OnnxConfig {
+    /// Directory holding `model.onnx` (or `onnx/model.onnx`) and the
+    /// tokenizer files
+    pub model_dir: PathBuf,
+
+    #[serde(default)]
+    pub pooling: OnnxPooling,
+
+    /// Inputs are truncated to this many tokens
+    #[serde(default = "default_onnx_max_length")]
+    pub max_length: usize,
+
+    #[serde(default = "default_batch_size")]
+    pub batch_size: usize,
+}
+
+impl Default for OnnxConfig {
+    fn default() -> Self {
+        Self {
+            model_dir: PathBuf::new(),
+            pooling: OnnxPooling::default(),
+            max_length: default_onnx_max_length(),
+            batch_size: default_batch_size(),
+        }
+    }
+}
+
+fn default_onnx_max_length() -> usize {
+    512
+}
+
 /// OpenAI provider configuration
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct OpenAIConfig {
@@ -128,6 +203,10 @@ pub struct OpenAIConfig {
 
     #[serde(default = "default_exponential_base")]
     pub exponential_base: f64,
+
+    /// Client-side rate limit, in requests per minute
+    #[serde(default = "default_requests_per_minute")]
+    pub requests_per_minute: u32,
 }
 
 impl Default for OpenAIConfig {
@@ -143,6 +222,7 @@ impl Default for OpenAIConfig {
             initial_backoff_ms: default_initial_backoff_ms(),
             max_backoff_ms: default_max_backoff_ms(),
             exponential_base: default_exponential_base(),
+            requests_per_minute: default_requests_per_minute(),
         }
     }
 }
@@ -163,6 +243,438 @@ fn default_openai_batch_size() -> usize {
     100
 }
 
+fn default_requests_per_minute() -> u32 {
+    3500
+}
+
+/// Ollama provider configuration
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct OllamaConfig {
+    /// Base URL of the Ollama server
+    #[serde(default = "default_ollama_host")]
+    pub host: String,
+
+    #[serde(default = "default_ollama_model")]
+    pub model: String,
+
+    /// How long the server keeps the model loaded after a request, e.g.
+    /// "10m", or "-1" to keep it loaded (server default when unset)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub keep_alive: Option<String>,
+
+    #[serde(default = "default_batch_size")]
+    pub batch_size: usize,
+
+    /// Local models on a CPU can take a while per batch
+    #[serde(default = "default_ollama_timeout_secs")]
+    pub timeout_secs: u64,
+}
+
+impl Default for OllamaConfig {
+    fn default() -> Self {
+        Self {
+            host: default_ollama_host(),
+            model: default_ollama_model(),
+            keep_alive: None,
+            batch_size: default_batch_size(),
+            timeout_secs: default_ollama_timeout_secs(),
+        }
+    }
+}
+
+fn default_ollama_host() -> String {
+    "http://localhost:11434".to_string()
+}
+
+fn default_ollama_model() -> String {
+    "nomic-embed-text".to_string()
+}
+
+fn default_ollama_timeout_secs() -> u64 {
+    120
+}
+
+/// Hugging Face Text Embeddings Inference provider configuration
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct TeiConfig {
+    /// Base URL of the TEI server
+    #[serde(default = "default_tei_url")]
+    pub url: String,
+
+    /// Bearer token, e.g. for a Hugging Face Inference Endpoint (can be
+    /// environment variable reference like ${HF_TOKEN}); none when empty
+    #[serde(default)]
+    pub api_key: String,
+
+    /// Let the server cut inputs longer than the model's limit
+    #[serde(default = "default_tei_truncate")]
+    pub truncate: bool,
+
+    /// Let the server normalize embeddings to unit length
+    #[serde(default = "default_tei_normalize")]
+    pub normalize: bool,
+
+    /// Name of the model's sentence-transformers prompt applied to queries,
+    /// e.g. "query"
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub query_prompt_name: Option<String>,
+
+    /// Capped at the server's `max_client_batch_size`
+    #[serde(default = "default_batch_size")]
+    pub batch_size: usize,
+
+    #[serde(default = "default_ollama_timeout_secs")]
+    pub timeout_secs: u64,
+}
+
+impl Default for TeiConfig {
+    fn default() -> Self {
+        Self {
+            url: default_tei_url(),
+            api_key: String::new(),
+            truncate: default_tei_truncate(),
+            normalize: default_tei_normalize(),
+            query_prompt_name: None,
+            batch_size: default_batch_size(),
+            timeout_secs: default_ollama_timeout_secs(),
+        }
+    }
+}
+
+fn default_tei_url() -> String {
+    "http://localhost:8080".to_string()
+}
+
+fn default_tei_truncate() -> bool {
+    true
+}
+
+fn default_tei_normalize() -> bool {
+    true
+}
+
+impl TeiConfig {
+    /// Load the bearer token from configuration, if one is set
+    pub fn load_api_key(&self) -> anyhow::Result<Option<String>> {
+        if self.api_key.is_empty() {
+            return Ok(None);
+        }
+        load_key(&self.api_key, &[]).map(Some)
+    }
+}
+
+/// Azure OpenAI provider configuration
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct AzureOpenAIConfig {
+    /// Resource endpoint, e.g. https://my-resource.openai.azure.com
+    pub endpoint: String,
+
+    /// Name of the embedding model's deployment
+    pub deployment: String,
+
+    #[serde(default = "default_azure_api_version")]
+    pub api_version: String,
+
+    #[serde(default)]
+    pub auth: AzureAuth,
+
+    /// API key (can be environment variable reference like
+    /// ${AZURE_OPENAI_API_KEY}); unused with Azure AD auth
+    #[serde(default)]
+    pub api_key: String,
+
+    #[serde(default = "default_max_retries")]
+    pub max_retries: usize,
+
+    #[serde(default = "default_timeout_secs")]
+    pub timeout_secs: u64,
+
+    #[serde(default = "default_openai_batch_size")]
+    pub batch_size: usize,
+
+    /// Client-side rate limit, in requests per minute
+    #[serde(default = "default_requests_per_minute")]
+    pub requests_per_minute: u32,
+}
+
+fn default_azure_api_version() -> String {
+    "2024-02-01".to_string()
+}
+
+impl AzureOpenAIConfig {
+    /// Load the API key from configuration or the `AZURE_OPENAI_API_KEY`
+    /// environment variable
+    pub fn load_api_key(&self) -> anyhow::Result<String> {
+        use anyhow::Context;
+
+        if !self.api_key.is_empty() && !self.api_key.starts_with("${") {
+            return Ok(self.api_key.clone());
+        }
+
+        if self.api_key.starts_with("${") && self.api_key.ends_with('}') {
+            let var_name = &self.api_key[2..self.api_key.len() - 1];
+            return std::env::var(var_name)
+                .with_context(|| format!("Environment variable {} not set", var_name));
+        }
+
+        std::env::var("AZURE_OPENAI_API_KEY")
+            .context("No API key configured and AZURE_OPENAI_API_KEY environment variable not set")
+    }
+}
+
+/// AWS Bedrock provider configuration
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BedrockConfig {
+    /// Model id, cross-region inference profile id or inference profile ARN
+    #[serde(default = "default_bedrock_model")]
+    pub model: String,
+
+    /// AWS region (default: from the AWS config chain)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub region: Option<String>,
+
+    /// Named profile of the shared AWS config (default: `AWS_PROFILE` or
+    /// "default")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub profile: Option<String>,
+
+    #[serde(default = "default_batch_size")]
+    pub batch_size: usize,
+
+    #[serde(default = "default_max_retries")]
+    pub max_retries: usize,
+}
+
+impl Default for BedrockConfig {
+    fn default() -> Self {
+        Self {
+            model: default_bedrock_model(),
+            region: None,
+            profile: None,
+            batch_size: default_batch_size(),
+            max_retries: default_max_retries(),
+        }
+    }
+}
+
+fn default_bedrock_model() -> String {
+    "amazon.titan-embed-text-v2:0".to_string()
+}
+
+/// Google Gemini / Vertex AI provider configuration
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct GeminiConfig {
+    #[serde(default = "default_gemini_model")]
+    pub model: String,
+
+    /// Gemini API key (can be environment variable reference like
+    /// ${GEMINI_API_KEY}); unused with Vertex AI
+    #[serde(default)]
+    pub api_key: String,
+
+    /// Reduced output dimensionality, for models that support it
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub dimensions: Option<u32>,
+
+    /// Task type of queries; chunks are always `RETRIEVAL_DOCUMENT`
+    #[serde(default = "default_gemini_query_task_type")]
+    pub query_task_type: String,
+
+    /// Google Cloud project; when set, requests go to Vertex AI
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub vertex_project: Option<String>,
+
+    #[serde(default = "default_vertex_location")]
+    pub vertex_location: String,
+
+    #[serde(default = "default_openai_batch_size")]
+    pub batch_size: usize,
+
+    #[serde(default = "default_max_retries")]
+    pub max_retries: usize,
+
+    #[serde(default = "default_timeout_secs")]
+    pub timeout_secs: u64,
+}
+
+impl Default for GeminiConfig {
+    fn default() -> Self {
+        Self {
+            model: default_gemini_model(),
+            api_key: String::new(),
+            dimensions: None,
+            query_task_type: default_gemini_query_task_type(),
+            vertex_project: None,
+            vertex_location: default_vertex_location(),
+            batch_size: default_openai_batch_size(),
+            max_retries: default_max_retries(),
+            timeout_secs: default_timeout_secs(),
+        }
+    }
+}
+
+fn default_gemini_model() -> String {
+    "gemini-embedding-001".to_string()
+}
+
+fn default_gemini_query_task_type() -> String {
+    "RETRIEVAL_QUERY".to_string()
+}
+
+fn default_vertex_location() -> String {
+    "us-central1".to_string()
+}
+
+impl GeminiConfig {
+    /// Load the API key from configuration or the `GEMINI_API_KEY` or
+    /// `GOOGLE_API_KEY` environment variable
+    pub fn load_api_key(&self) -> anyhow::Result<String> {
+        use anyhow::Context;
+
+        if !self.api_key.is_empty() && !self.api_key.starts_with("${") {
+            return Ok(self.api_key.clone());
+        }
+
+        if self.api_key.starts_with("${") && self.api_key.ends_with('}') {
+            let var_name = &self.api_key[2..self.api_key.len() - 1];
+            return std::env::var(var_name)
+                .with_context(|| format!("Environment variable {} not set", var_name));
+        }
+
+        std::env::var("GEMINI_API_KEY")
+            .or_else(|_| std::env::var("GOOGLE_API_KEY"))
+            .context("No API key configured and neither GEMINI_API_KEY nor GOOGLE_API_KEY is set")
+    }
+}
+
+/// Voyage AI provider configuration
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct VoyageConfig {
+    #[serde(default = "default_voyage_model")]
+    pub model: String,
+
+    /// API key (can be environment variable reference like ${VOYAGE_API_KEY})
+    #[serde(default)]
+    pub api_key: String,
+
+    /// Output dimension, for models that support several
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub dimensions: Option<u32>,
+
+    #[serde(default = "default_openai_batch_size")]
+    pub batch_size: usize,
+
+    #[serde(default = "default_max_retries")]
+    pub max_retries: usize,
+
+    #[serde(default = "default_timeout_secs")]
+    pub timeout_secs: u64,
+}
+
+impl Default for VoyageConfig {
+    fn default() -> Self {
+        Self {
+            model: default_voyage_model(),
+            api_key: String::new(),
+            dimensions: None,
+            batch_size: default_openai_batch_size(),
+            max_retries: default_max_retries(),
+            timeout_secs: default_timeout_secs(),
+        }
+    }
+}
+
+fn default_voyage_model() -> String {
+    "voyage-code-3".to_string()
+}
+
+impl VoyageConfig {
+    /// Load the API key from configuration or the `VOYAGE_API_KEY`
+    /// environment variable
+    pub fn load_api_key(&self) -> anyhow::Result<String> {
+        load_key(&self.api_key, &["VOYAGE_API_KEY"])
+    }
+}
+
+/// Cohere provider configuration
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CohereConfig {
+    #[serde(default = "default_cohere_model")]
+    pub model: String,
+
+    /// API key (can be environment variable reference like ${CO_API_KEY})
+    #[serde(default)]
+    pub api_key: String,
+
+    /// Output dimension, for models that support several
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub dimensions: Option<u32>,
+
+    #[serde(default = "default_cohere_batch_size")]
+    pub batch_size: usize,
+
+    #[serde(default = "default_max_retries")]
+    pub max_retries: usize,
+
+    #[serde(default = "default_timeout_secs")]
+    pub timeout_secs: u64,
+}
+
+impl Default for CohereConfig {
+    fn default() -> Self {
+        Self {
+            model: default_cohere_model(),
+            api_key: String::new(),
+            dimensions: None,
+            batch_size: default_cohere_batch_size(),
+            max_retries: default_max_retries(),
+            timeout_secs: default_timeout_secs(),
+        }
+    }
+}
+
+fn default_cohere_model() -> String {
+    "embed-v4.0".to_string()
+}
+
+fn default_cohere_batch_size() -> usize {
+    96
+}
+
+impl CohereConfig {
+    /// Load the API key from configuration or the `CO_API_KEY` or
+    /// `COHERE_API_KEY` environment variable
+    pub fn load_api_key(&self) -> anyhow::Result<String> {
+        load_key(&self.api_key, &["CO_API_KEY", "COHERE_API_KEY"])
+    }
+}
+
+/// Resolve a configured API key: a literal, a `${VAR}` reference, or else
+/// the first of `env_vars` that is set
+fn load_key(api_key: &str, env_vars: &[&str]) -> anyhow::Result<String> {
+    use anyhow::Context;
+
+    if !api_key.is_empty() && !api_key.starts_with("${") {
+        return Ok(api_key.to_string());
+    }
+
+    if api_key.starts_with("${") && api_key.ends_with('}') {
+        let var_name = &api_key[2..api_key.len() - 1];
+        return std::env::var(var_name)
+            .with_context(|| format!("Environment variable {} not set", var_name));
+    }
+
+    env_vars
+        .iter()
+        .find_map(|var| std::env::var(var).ok())
+        .with_context(|| {
+            format!(
+                "No API key configured and {} is not set",
+                env_vars.join(" or ")
+            )
+        })
+}
+
 /// Cache configuration
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct CacheConfig {
@@ -308,6 +820,26 @@ impl EnhancedEmbeddingsConfig {
                     .ok_or_else(|| anyhow::anyhow!("OpenAI configuration not provided"))?;
                 Ok(Box::new(config))
             }
+            ProviderType::Ollama => Ok(Box::new(self.providers.ollama.clone().unwrap_or_default())),
+            ProviderType::Azure => {
+                let config = self.providers.azure
+                    .clone()
+                    .ok_or_else(|| anyhow::anyhow!("Azure OpenAI configuration not provided"))?;
+                Ok(Box::new(config))
+            }
+            ProviderType::Bedrock => {
+                Ok(Box::new(self.providers.bedrock.clone().unwrap_or_default()))
+            }
+            ProviderType::Gemini => Ok(Box::new(self.providers.gemini.clone().unwrap_or_default())),
+            ProviderType::Voyage => Ok(Box::new(self.providers.voyage.clone().unwrap_or_default())),
+            ProviderType::Cohere => Ok(Box::new(self.providers.cohere.clone().unwrap_or_default())),
+            ProviderType::Tei => Ok(Box::new(self.providers.tei.clone().unwrap_or_default())),
+            ProviderType::Onnx => {
+                let config = self.providers.onnx
+                    .clone()
+                    .ok_or_else(|| anyhow::anyhow!("ONNX configuration not provided"))?;
+                Ok(Box::new(config))
+            }
         }
     }
 }
\ No newline at end of file
@@ -3,11 +3,19 @@ use async_trait::async_trait;
 use fastembed::{EmbeddingModel, InitOptions, TextEmbedding};
 use std::sync::Arc;
 use std::time::Instant;
-use tracing::{info, warn};
+use tracing::{debug, info, warn};
 
 use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+use super::batching::AdaptiveBatcher;
+use super::cache::EmbeddingCache;
 use super::config::FastEmbedConfig;
 use super::provider::{EmbeddingProvider, HealthStatus, ProviderCapabilities};
+use super::resilience::{ResiliencePolicy, ResilientProvider};
+
+/// Retries made by the providers the generator creates: none, since the
+/// generator's [`ResilientProvider`] retries with jitter and counts
+/// failures for its circuit breaker
+const PROVIDER_RETRIES: usize = 0;
 
 /// FastEmbed provider implementation
 pub struct FastEmbedProvider {
@@ -37,25 +45,44 @@ impl FastEmbedProvider {
 
     /// Parse model name string to fastembed EmbeddingModel enum
     fn parse_model_name(name: &str) -> Result<EmbeddingModel> {
+        match Self::known_model(name) {
+            Some(model) => Ok(model),
+            None => {
+                // Default to nomic if unknown
+                warn!("Unknown model '{}', falling back to nomic-embed-text-v1.5", name);
+                Ok(EmbeddingModel::NomicEmbedTextV15)
+            }
+        }
+    }
+
+    /// Look up a supported model by name or alias
+    fn known_model(name: &str) -> Option<EmbeddingModel> {
         match name {
             "nomic-embed-text-v1.5" | "nomic-embed-text" | "nomic-ai/nomic-embed-text-v1.5" => {
-                Ok(EmbeddingModel::NomicEmbedTextV15)
+                Some(EmbeddingModel::NomicEmbedTextV15)
             }
-            "all-MiniLM-L6-v2" | "all-minilm-l6-v2" => Ok(EmbeddingModel::AllMiniLML6V2),
+            "all-MiniLM-L6-v2" | "all-minilm-l6-v2" => Some(EmbeddingModel::AllMiniLML6V2),
             "bge-small-en-v1.5" | "bge-small" | "BAAI/bge-small-en-v1.5" => {
-                Ok(EmbeddingModel::BGESmallENV15)
+                Some(EmbeddingModel::BGESmallENV15)
             }
             "bge-base-en-v1.5" | "bge-base" | "BAAI/bge-base-en-v1.5" => {
-                Ok(EmbeddingModel::BGEBaseENV15)
+                Some(EmbeddingModel::BGEBaseENV15)
             }
             "bge-large-en-v1.5" | "bge-large" | "BAAI/bge-large-en-v1.5" => {
-                Ok(EmbeddingModel::BGELargeENV15)
-            }
-            _ => {
-                // Default to nomic if unknown
-                warn!("Unknown model '{}', falling back to nomic-embed-text-v1.5", name);
-                Ok(EmbeddingModel::NomicEmbedTextV15)
+                Some(EmbeddingModel::BGELargeENV15)
             }
+            _ => None,
+        }
+    }
+
+    /// Embedding dimension of a supported model, or None for unknown names
+    ///
+    /// Unlike the provider, this does not fall back to a default model.
+    pub(crate) fn known_model_dimension(name: &str) -> Option<usize> {
+        match Self::known_model(name)? {
+            EmbeddingModel::AllMiniLML6V2 | EmbeddingModel::BGESmallENV15 => Some(384),
+            EmbeddingModel::BGELargeENV15 => Some(1024),
+            _ => Some(768),
         }
     }
 
@@ -154,9 +181,143 @@ impl EmbeddingProvider for FastEmbedProvider {
     }
 }
 
+/// Ollama provider settings from the `ollama_*` embeddings settings
+fn ollama_config(config: &crate::config::EmbeddingsConfig) -> super::config::OllamaConfig {
+    super::config::OllamaConfig {
+        host: config.ollama_host.clone(),
+        model: config.ollama_model.clone(),
+        keep_alive: config.ollama_keep_alive.clone(),
+        batch_size: config.batch_size,
+        ..Default::default()
+    }
+}
+
+/// Azure OpenAI provider settings from the `azure_*` embeddings settings
+fn azure_config(config: &crate::config::EmbeddingsConfig) -> Result<super::config::AzureOpenAIConfig> {
+    let required = |value: &Option<String>, key: &str| {
+        value
+            .clone()
+            .ok_or_else(|| anyhow::anyhow!("The azure provider requires embeddings.{}", key))
+    };
+    Ok(super::config::AzureOpenAIConfig {
+        endpoint: required(&config.azure_endpoint, "azure_endpoint")?,
+        deployment: required(&config.azure_deployment, "azure_deployment")?,
+        api_version: config.azure_api_version.clone(),
+        auth: config.azure_auth,
+        api_key: config.azure_api_key.clone().unwrap_or_default(),
+        max_retries: PROVIDER_RETRIES,
+        timeout_secs: 30,
+        batch_size: config.batch_size,
+        requests_per_minute: config.requests_per_minute,
+    })
+}
+
+/// Bedrock provider settings from the `bedrock_*` embeddings settings
+fn bedrock_config(config: &crate::config::EmbeddingsConfig) -> super::config::BedrockConfig {
+    super::config::BedrockConfig {
+        model: config.bedrock_model.clone(),
+        region: config.bedrock_region.clone(),
+        profile: config.bedrock_profile.clone(),
+        batch_size: config.batch_size,
+        max_retries: PROVIDER_RETRIES,
+    }
+}
+
+/// Gemini provider settings from the `gemini_*` embeddings settings
+fn gemini_config(config: &crate::config::EmbeddingsConfig) -> super::config::GeminiConfig {
+    super::config::GeminiConfig {
+        model: config.gemini_model.clone(),
+        api_key: config.gemini_api_key.clone().unwrap_or_default(),
+        dimensions: config.gemini_dimensions,
+        query_task_type: config.gemini_query_task_type.clone(),
+        vertex_project: config.gemini_vertex_project.clone(),
+        vertex_location: config.gemini_vertex_location.clone(),
+        batch_size: config.batch_size,
+        max_retries: PROVIDER_RETRIES,
+        ..Default::default()
+    }
+}
+
+/// Voyage provider settings from the `voyage_*` embeddings settings
+fn voyage_config(config: &crate::config::EmbeddingsConfig) -> super::config::VoyageConfig {
+    super::config::VoyageConfig {
+        model: config.voyage_model.clone(),
+        api_key: config.voyage_api_key.clone().unwrap_or_default(),
+        dimensions: config.voyage_dimensions,
+        batch_size: config.batch_size,
+        max_retries: PROVIDER_RETRIES,
+        ..Default::default()
+    }
+}
+
+/// Cohere provider settings from the `cohere_*` embeddings settings
+fn cohere_config(config: &crate::config::EmbeddingsConfig) -> super::config::CohereConfig {
+    super::config::CohereConfig {
+        model: config.cohere_model.clone(),
+        api_key: config.cohere_api_key.clone().unwrap_or_default(),
+        dimensions: config.cohere_dimensions,
+        batch_size: config.batch_size,
+        max_retries: PROVIDER_RETRIES,
+        ..Default::default()
+    }
+}
+
+/// TEI provider settings from the `tei_*` embeddings settings
+fn tei_config(config: &crate::config::EmbeddingsConfig) -> super::config::TeiConfig {
+    super::config::TeiConfig {
+        url: config.tei_url.clone(),
+        api_key: config.tei_api_key.clone().unwrap_or_default(),
+        truncate: config.tei_truncate,
+        normalize: config.tei_normalize,
+        query_prompt_name: config.tei_query_prompt_name.clone(),
+        batch_size: config.batch_size,
+        ..Default::default()
+    }
+}
+
+/// Local ONNX provider settings from the `onnx_*` embeddings settings
+fn onnx_config(config: &crate::config::EmbeddingsConfig) -> Result<super::config::OnnxConfig> {
+    let model_dir = config
+        .onnx_model_dir
+        .clone()
+        .ok_or_else(|| anyhow::anyhow!("The onnx provider requires embeddings.onnx_model_dir"))?;
+    Ok(super::config::OnnxConfig {
+        model_dir,
+        pooling: config.onnx_pooling,
+        max_length: config.input_token_limit(),
+        batch_size: config.batch_size,
+    })
+}
+
 /// Legacy EmbeddingGenerator for backward compatibility
 pub struct EmbeddingGenerator {
+    /// Provider embeddings are made with, replaced by the next of
+    /// `fallbacks` when a call to it fails
+    active: std::sync::RwLock<Arc<Backend>>,
+    /// Providers of the fallback chain not used yet, in order
+    fallbacks: tokio::sync::Mutex<Vec<Fallback>>,
+    /// Leading dimensions embeddings are truncated to; None keeps them whole
+    truncate_dimensions: Option<usize>,
+    /// Provider the configuration names, whose index is the default one
+    configured: crate::config::EmbeddingProvider,
+}
+
+/// A provider in use, with its batching and cache
+struct Backend {
+    /// Which configured provider this is
+    kind: crate::config::EmbeddingProvider,
     provider: Arc<dyn EmbeddingProvider>,
+    batcher: AdaptiveBatcher,
+    /// Embeddings of texts embedded before; None when disabled
+    cache: Option<EmbeddingCache>,
+}
+
+/// A provider to fall back to
+struct Fallback {
+    config: crate::config::EmbeddingsConfig,
+    /// The provider if already created, else it is created from `config`
+    /// when needed
+    provider: Option<Arc<dyn EmbeddingProvider>>,
 }
 
 impl EmbeddingGenerator {
@@ -164,7 +325,299 @@ impl EmbeddingGenerator {
     ///
     /// This maintains backward compatibility with the existing API.
     /// NOTE: For OpenAI provider, use `new_async` when in an async context.
+    /// Starts with the first provider of the chain that can be created;
+    /// the rest of `fallback_providers` are kept for calls that fail.
     pub fn new(config: &crate::config::EmbeddingsConfig) -> Result<Self> {
+        let mut chain = config.provider_chain();
+        let mut errors = Vec::new();
+        while !chain.is_empty() {
+            let candidate = chain.remove(0);
+            let name = candidate.provider.as_str();
+            match Self::create_provider(&candidate) {
+                Ok(provider) => return Self::with_chain(provider, &candidate, config, chain),
+                Err(e) if errors.is_empty() && chain.is_empty() => return Err(e),
+                Err(e) => {
+                    warn!("Embedding provider {} unavailable: {:#}", name, e);
+                    errors.push(format!("{}: {:#}", name, e));
+                }
+            }
+        }
+        anyhow::bail!("No embedding provider is available:\n{}", errors.join("\n"))
+    }
+
+    /// Create a new EmbeddingGenerator asynchronously
+    ///
+    /// Use this when already in an async context to avoid runtime nesting issues.
+    /// With `fallback_providers`, each provider in turn is created and must
+    /// pass a health check; the first that does is used, and the rest are
+    /// kept for calls that fail.
+    pub async fn new_async(config: &crate::config::EmbeddingsConfig) -> Result<Self> {
+        let mut chain = config.provider_chain();
+        if chain.len() == 1 {
+            let provider = Self::create_provider_async(config).await?;
+            return Self::with_chain(provider, config, config, Vec::new());
+        }
+
+        let mut errors = Vec::new();
+        while !chain.is_empty() {
+            let candidate = chain.remove(0);
+            let name = candidate.provider.as_str();
+            match Self::create_healthy_provider(&candidate).await {
+                Ok(provider) => return Self::with_chain(provider, &candidate, config, chain),
+                Err(e) => {
+                    warn!("Embedding provider {} unavailable: {:#}", name, e);
+                    errors.push(format!("{}: {:#}", name, e));
+                }
+            }
+        }
+        anyhow::bail!("No embedding provider is available:\n{}", errors.join("\n"))
+    }
+
+    /// Create the provider of `config` and check that it embeds
+    async fn create_healthy_provider(
+        config: &crate::config::EmbeddingsConfig,
+    ) -> Result<Arc<dyn EmbeddingProvider>> {
+        let provider = Self::create_provider_async(config).await?;
+        match provider.health_check().await? {
+            // A degraded provider is rate limited, which retries handle
+            HealthStatus::Healthy | HealthStatus::Degraded { .. } => Ok(provider),
+            HealthStatus::Unhealthy { error } => Err(anyhow::anyhow!(error)),
+        }
+    }
+
+    /// Generator using `provider`, created from `candidate`, one of the
+    /// providers of `config`, and falling back to the providers of `rest`
+    fn with_chain(
+        provider: Arc<dyn EmbeddingProvider>,
+        candidate: &crate::config::EmbeddingsConfig,
+        config: &crate::config::EmbeddingsConfig,
+        rest: Vec<crate::config::EmbeddingsConfig>,
+    ) -> Result<Self> {
+        let generator = Self::with_fallbacks(
+            provider,
+            candidate,
+            config.provider,
+            rest.into_iter()
+                .map(|config| Fallback {
+                    config,
+                    provider: None,
+                })
+                .collect(),
+        )?;
+        if candidate.provider != config.provider {
+            warn!(
+                "Falling back to the {} embedding provider; its vectors are kept in a separate index",
+                candidate.provider.as_str()
+            );
+        }
+        Ok(generator)
+    }
+
+    /// Generator embedding through `provider`, a provider created by the
+    /// caller, with the batching, limits and cache of `config`
+    pub fn with_provider(
+        provider: Arc<dyn EmbeddingProvider>,
+        config: &crate::config::EmbeddingsConfig,
+    ) -> Result<Self> {
+        Self::with_fallbacks(provider, config, config.provider, Vec::new())
+    }
+
+    /// Generator embedding through `provider`, created from `config`, and
+    /// then through each of `fallbacks` in turn as calls fail
+    fn with_fallbacks(
+        provider: Arc<dyn EmbeddingProvider>,
+        config: &crate::config::EmbeddingsConfig,
+        configured: crate::config::EmbeddingProvider,
+        fallbacks: Vec<Fallback>,
+    ) -> Result<Self> {
+        Ok(Self {
+            active: std::sync::RwLock::new(Arc::new(Self::backend(provider, config)?)),
+            fallbacks: tokio::sync::Mutex::new(fallbacks),
+            truncate_dimensions: config.truncate_dimensions,
+            configured,
+        })
+    }
+
+    /// Path of the vector index for the provider in use: `db_path` for the
+    /// configured provider, and a sibling named after a fallback provider,
+    /// e.g. `index-ollama.lance`, so vector spaces are never mixed
+    pub fn index_path(&self, db_path: &std::path::Path) -> std::path::PathBuf {
+        let provider = self.provider();
+        if provider == self.configured {
+            return db_path.to_path_buf();
+        }
+        let stem = db_path
+            .file_stem()
+            .and_then(|s| s.to_str())
+            .unwrap_or("index");
+        let name = match db_path.extension().and_then(|e| e.to_str()) {
+            Some(extension) => format!("{}-{}.{}", stem, provider.as_str(), extension),
+            None => format!("{}-{}", stem, provider.as_str()),
+        };
+        db_path.with_file_name(name)
+    }
+
+    /// Provider embeddings are currently made with
+    pub fn provider(&self) -> crate::config::EmbeddingProvider {
+        self.active().kind
+    }
+
+    fn active(&self) -> Arc<Backend> {
+        self.active
+            .read()
+            .unwrap_or_else(|e| e.into_inner())
+            .clone()
+    }
+
+    /// Run `call` with the provider in use, moving on to the next fallback
+    /// provider each time it fails. The error of the last provider is
+    /// returned when none is left.
+    async fn with_fallback<T, F, Fut>(&self, call: F) -> Result<T>
+    where
+        F: Fn(Arc<Backend>) -> Fut,
+        Fut: std::future::Future<Output = Result<T>>,
+    {
+        loop {
+            let backend = self.active();
+            let error = match call(backend.clone()).await {
+                Ok(value) => return Ok(value),
+                Err(e) => e,
+            };
+            if !self.fall_back(&backend, &error).await {
+                return Err(error);
+            }
+        }
+    }
+
+    /// Replace `failed` with the first fallback provider that can be
+    /// created, unless another call already did. False when none is left.
+    async fn fall_back(&self, failed: &Arc<Backend>, error: &anyhow::Error) -> bool {
+        let mut fallbacks = self.fallbacks.lock().await;
+        if !Arc::ptr_eq(failed, &self.active()) {
+            return true;
+        }
+        while !fallbacks.is_empty() {
+            let fallback = fallbacks.remove(0);
+            let name = fallback.config.provider.as_str();
+            let provider = match fallback.provider {
+                Some(provider) => Ok(provider),
+                None => Self::create_healthy_provider(&fallback.config).await,
+            };
+            match provider.and_then(|provider| Self::backend(provider, &fallback.config)) {
+                Ok(backend) => {
+                    warn!(
+                        "Embedding provider {} failed ({:#}); falling back to {}",
+                        failed.kind.as_str(),
+                        error,
+                        name
+                    );
+                    *self.active.write().unwrap_or_else(|e| e.into_inner()) = Arc::new(backend);
+                    return true;
+                }
+                Err(e) => warn!("Embedding provider {} unavailable: {:#}", name, e),
+            }
+        }
+        false
+    }
+
+    /// Backend embedding through `provider` in requests of at most its
+    /// batch size and the configured token budget, with the configured rate
+    /// limits, retries and circuit breaker.
+    ///
+    /// Fails when `truncate_dimensions` exceeds the model's dimension.
+    fn backend(
+        provider: Arc<dyn EmbeddingProvider>,
+        config: &crate::config::EmbeddingsConfig,
+    ) -> Result<Backend> {
+        if let Some(dimensions) = config.truncate_dimensions {
+            let full = provider.embedding_dimension();
+            if dimensions == 0 || dimensions > full {
+                anyhow::bail!(
+                    "embeddings.truncate_dimensions is {}, but must be between 1 and \
+                     the model's {} dimensions",
+                    dimensions,
+                    full
+                );
+            }
+        }
+
+        let tokenizer = super::tokenizer_for_config(config);
+        let policy = ResiliencePolicy {
+            max_retries: config.max_retries,
+            requests_per_minute: Some(config.requests_per_minute),
+            tokens_per_minute: config.tokens_per_minute,
+            failure_threshold: config.circuit_breaker_threshold,
+            cooldown: std::time::Duration::from_secs(config.circuit_breaker_cooldown_secs),
+        };
+        let provider: Arc<dyn EmbeddingProvider> =
+            Arc::new(ResilientProvider::new(provider, &policy, tokenizer.clone()));
+        let batcher = AdaptiveBatcher::new(
+            provider.max_batch_size(),
+            config.batch_token_limit(),
+            tokenizer,
+        );
+        let cache = if config.cache {
+            Self::open_cache(config, provider.as_ref())
+        } else {
+            None
+        };
+        Ok(Backend {
+            kind: config.provider,
+            provider,
+            batcher,
+            cache,
+        })
+    }
+
+    /// Truncate `embedding` to the configured leading dimensions and scale
+    /// it back to unit length, as Matryoshka models are trained for
+    fn truncate(&self, mut embedding: Vec<f32>) -> Vec<f32> {
+        if let Some(dimensions) = self.truncate_dimensions {
+            truncate_and_normalize(&mut embedding, dimensions);
+        }
+        embedding
+    }
+
+    /// Open the embedding cache of the configured model, or None when it
+    /// cannot be opened, in which case texts are always embedded
+    fn open_cache(
+        config: &crate::config::EmbeddingsConfig,
+        provider: &dyn EmbeddingProvider,
+    ) -> Option<EmbeddingCache> {
+        use crate::config::EmbeddingProvider as ConfigProvider;
+
+        let dir = match &config.cache_dir {
+            Some(dir) => dir.clone(),
+            None => crate::registry::GlobalRegistry::global_dir()
+                .ok()?
+                .join("embedding-cache"),
+        };
+        // Settings that change the vectors beyond the model and dimension
+        let variant = match config.provider {
+            ConfigProvider::Onnx => format!("{:?}", config.onnx_pooling),
+            ConfigProvider::Tei => format!("normalize={}", config.tei_normalize),
+            _ => String::new(),
+        };
+        let model = format!(
+            "{}/{}/{}",
+            provider.provider_name(),
+            config.model_name(),
+            variant
+        );
+
+        match EmbeddingCache::open(&dir, &model, provider.embedding_dimension()) {
+            Ok(cache) => Some(cache),
+            Err(e) => {
+                warn!("Embedding cache disabled: {:#}", e);
+                None
+            }
+        }
+    }
+
+    /// Create the configured provider (sync version)
+    fn create_provider(
+        config: &crate::config::EmbeddingsConfig,
+    ) -> Result<Arc<dyn EmbeddingProvider>> {
         use crate::config::EmbeddingProvider as ConfigProvider;
 
         match config.provider {
@@ -174,8 +627,11 @@ impl EmbeddingGenerator {
                     batch_size: config.batch_size,
                     cache_dir: None,
                 };
-                let provider = Arc::new(FastEmbedProvider::new(&fastembed_config)?);
-                Ok(Self { provider })
+                Ok(Arc::new(FastEmbedProvider::new(&fastembed_config)?))
+            }
+            ConfigProvider::Onnx => {
+                let provider = super::onnx_provider::OnnxProvider::new(&onnx_config(config)?)?;
+                Ok(Arc::new(provider))
             }
             ConfigProvider::OpenAI => {
                 // For OpenAI in sync context, try to use tokio's current handle
@@ -185,12 +641,13 @@ impl EmbeddingGenerator {
                     model: config.openai_model.clone(),
                     organization: None,
                     base_url: config.openai_base_url.clone(),
-                    max_retries: 3,
+                    max_retries: PROVIDER_RETRIES,
                     timeout_secs: 30,
                     batch_size: config.batch_size,
                     initial_backoff_ms: 1000,
                     max_backoff_ms: 60000,
                     exponential_base: 2.0,
+                    requests_per_minute: config.requests_per_minute,
                 };
 
                 // Try to use existing runtime handle first
@@ -204,7 +661,7 @@ impl EmbeddingGenerator {
                     }).join()
                         .map_err(|_| anyhow::anyhow!("Thread panicked during OpenAI initialization"))??;
 
-                    Ok(Self { provider: Arc::new(provider) })
+                    Ok(Arc::new(provider))
                 } else {
                     // No runtime, create a new one
                     let rt = tokio::runtime::Runtime::new()
@@ -214,16 +671,143 @@ impl EmbeddingGenerator {
                         super::openai_provider::OpenAIProvider::new(&openai_config).await
                     })?;
 
-                    Ok(Self { provider: Arc::new(provider) })
+                    Ok(Arc::new(provider))
                 }
             }
+            ConfigProvider::Ollama => {
+                // Creating the provider makes a request, so it needs a runtime
+                // of its own, as for OpenAI
+                let ollama_config = ollama_config(config);
+                let provider = if tokio::runtime::Handle::try_current().is_ok() {
+                    std::thread::spawn(move || {
+                        let rt = tokio::runtime::Runtime::new()
+                            .expect("Failed to create runtime");
+                        rt.block_on(super::ollama_provider::OllamaProvider::new(&ollama_config))
+                    }).join()
+                        .map_err(|_| anyhow::anyhow!("Thread panicked during Ollama initialization"))??
+                } else {
+                    let rt = tokio::runtime::Runtime::new()
+                        .context("Failed to create tokio runtime for Ollama initialization")?;
+                    rt.block_on(super::ollama_provider::OllamaProvider::new(&ollama_config))?
+                };
+
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Azure => {
+                // Also makes a request on creation, as for Ollama
+                let azure_config = azure_config(config)?;
+                let provider = if tokio::runtime::Handle::try_current().is_ok() {
+                    std::thread::spawn(move || {
+                        let rt = tokio::runtime::Runtime::new()
+                            .expect("Failed to create runtime");
+                        rt.block_on(super::azure_provider::AzureOpenAIProvider::new(&azure_config))
+                    }).join()
+                        .map_err(|_| anyhow::anyhow!("Thread panicked during Azure OpenAI initialization"))??
+                } else {
+                    let rt = tokio::runtime::Runtime::new()
+                        .context("Failed to create tokio runtime for Azure OpenAI initialization")?;
+                    rt.block_on(super::azure_provider::AzureOpenAIProvider::new(&azure_config))?
+                };
+
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Bedrock => {
+                // Also makes a request on creation, as for Ollama
+                let bedrock_config = bedrock_config(config);
+                let provider = if tokio::runtime::Handle::try_current().is_ok() {
+                    std::thread::spawn(move || {
+                        let rt = tokio::runtime::Runtime::new()
+                            .expect("Failed to create runtime");
+                        rt.block_on(super::bedrock_provider::BedrockProvider::new(&bedrock_config))
+                    }).join()
+                        .map_err(|_| anyhow::anyhow!("Thread panicked during Bedrock initialization"))??
+                } else {
+                    let rt = tokio::runtime::Runtime::new()
+                        .context("Failed to create tokio runtime for Bedrock initialization")?;
+                    rt.block_on(super::bedrock_provider::BedrockProvider::new(&bedrock_config))?
+                };
+
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Gemini => {
+                // Also makes a request on creation, as for Ollama
+                let gemini_config = gemini_config(config);
+                let provider = if tokio::runtime::Handle::try_current().is_ok() {
+                    std::thread::spawn(move || {
+                        let rt = tokio::runtime::Runtime::new()
+                            .expect("Failed to create runtime");
+                        rt.block_on(super::gemini_provider::GeminiProvider::new(&gemini_config))
+                    }).join()
+                        .map_err(|_| anyhow::anyhow!("Thread panicked during Gemini initialization"))??
+                } else {
+                    let rt = tokio::runtime::Runtime::new()
+                        .context("Failed to create tokio runtime for Gemini initialization")?;
+                    rt.block_on(super::gemini_provider::GeminiProvider::new(&gemini_config))?
+                };
+
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Voyage => {
+                // The constructor is async, so it needs a runtime as for Ollama
+                let voyage_config = voyage_config(config);
+                let provider = if tokio::runtime::Handle::try_current().is_ok() {
+                    std::thread::spawn(move || {
+                        let rt = tokio::runtime::Runtime::new()
+                            .expect("Failed to create runtime");
+                        rt.block_on(super::voyage_provider::VoyageProvider::new(&voyage_config))
+                    }).join()
+                        .map_err(|_| anyhow::anyhow!("Thread panicked during Voyage initialization"))??
+                } else {
+                    let rt = tokio::runtime::Runtime::new()
+                        .context("Failed to create tokio runtime for Voyage initialization")?;
+                    rt.block_on(super::voyage_provider::VoyageProvider::new(&voyage_config))?
+                };
+
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Cohere => {
+                // The constructor is async, so it needs a runtime as for Ollama
+                let cohere_config = cohere_config(config);
+                let provider = if tokio::runtime::Handle::try_current().is_ok() {
+                    std::thread::spawn(move || {
+                        let rt = tokio::runtime::Runtime::new()
+                            .expect("Failed to create runtime");
+                        rt.block_on(super::cohere_provider::CohereProvider::new(&cohere_config))
+                    }).join()
+                        .map_err(|_| anyhow::anyhow!("Thread panicked during Cohere initialization"))??
+                } else {
+                    let rt = tokio::runtime::Runtime::new()
+                        .context("Failed to create tokio runtime for Cohere initialization")?;
+                    rt.block_on(super::cohere_provider::CohereProvider::new(&cohere_config))?
+                };
+
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Tei => {
+                // Also makes a request on creation, as for Ollama
+                let tei_config = tei_config(config);
+                let provider = if tokio::runtime::Handle::try_current().is_ok() {
+                    std::thread::spawn(move || {
+                        let rt = tokio::runtime::Runtime::new()
+                            .expect("Failed to create runtime");
+                        rt.block_on(super::tei_provider::TeiProvider::new(&tei_config))
+                    }).join()
+                        .map_err(|_| anyhow::anyhow!("Thread panicked during TEI initialization"))??
+                } else {
+                    let rt = tokio::runtime::Runtime::new()
+                        .context("Failed to create tokio runtime for TEI initialization")?;
+                    rt.block_on(super::tei_provider::TeiProvider::new(&tei_config))?
+                };
+
+                Ok(Arc::new(provider))
+            }
         }
     }
 
-    /// Create a new EmbeddingGenerator asynchronously
-    ///
-    /// Use this when already in an async context to avoid runtime nesting issues.
-    pub async fn new_async(config: &crate::config::EmbeddingsConfig) -> Result<Self> {
+    /// Create the configured provider asynchronously
+    async fn create_provider_async(
+        config: &crate::config::EmbeddingsConfig,
+    ) -> Result<Arc<dyn EmbeddingProvider>> {
         use crate::config::EmbeddingProvider as ConfigProvider;
 
         match config.provider {
@@ -233,8 +817,11 @@ impl EmbeddingGenerator {
                     batch_size: config.batch_size,
                     cache_dir: None,
                 };
-                let provider = Arc::new(FastEmbedProvider::new(&fastembed_config)?);
-                Ok(Self { provider })
+                Ok(Arc::new(FastEmbedProvider::new(&fastembed_config)?))
+            }
+            ConfigProvider::Onnx => {
+                let provider = super::onnx_provider::OnnxProvider::new(&onnx_config(config)?)?;
+                Ok(Arc::new(provider))
             }
             ConfigProvider::OpenAI => {
                 let openai_config = super::config::OpenAIConfig {
@@ -242,16 +829,51 @@ impl EmbeddingGenerator {
                     model: config.openai_model.clone(),
                     organization: None,
                     base_url: config.openai_base_url.clone(),
-                    max_retries: 3,
+                    max_retries: PROVIDER_RETRIES,
                     timeout_secs: 30,
                     batch_size: config.batch_size,
                     initial_backoff_ms: 1000,
                     max_backoff_ms: 60000,
                     exponential_base: 2.0,
+                    requests_per_minute: config.requests_per_minute,
                 };
 
                 let provider = super::openai_provider::OpenAIProvider::new(&openai_config).await?;
-                Ok(Self { provider: Arc::new(provider) })
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Ollama => {
+                let provider =
+                    super::ollama_provider::OllamaProvider::new(&ollama_config(config)).await?;
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Azure => {
+                let provider =
+                    super::azure_provider::AzureOpenAIProvider::new(&azure_config(config)?).await?;
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Bedrock => {
+                let provider =
+                    super::bedrock_provider::BedrockProvider::new(&bedrock_config(config)).await?;
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Gemini => {
+                let provider =
+                    super::gemini_provider::GeminiProvider::new(&gemini_config(config)).await?;
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Voyage => {
+                let provider =
+                    super::voyage_provider::VoyageProvider::new(&voyage_config(config)).await?;
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Cohere => {
+                let provider =
+                    super::cohere_provider::CohereProvider::new(&cohere_config(config)).await?;
+                Ok(Arc::new(provider))
+            }
+            ConfigProvider::Tei => {
+                let provider = super::tei_provider::TeiProvider::new(&tei_config(config)).await?;
+                Ok(Arc::new(provider))
             }
         }
     }
@@ -259,15 +881,23 @@ impl EmbeddingGenerator {
     /// Generate embeddings for a batch of texts (async version)
     ///
     /// Use this method when calling from an async context to avoid runtime nesting issues.
+    /// All the embeddings of a call come from one provider: when it fails,
+    /// the whole call is made again with the next fallback provider.
     pub async fn embed_async(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
-        self.provider.embed(texts).await
+        let embeddings = self
+            .with_fallback(|backend| async move { backend.embed_cached(texts).await })
+            .await?;
+        Ok(embeddings.into_iter().map(|e| self.truncate(e)).collect())
     }
 
     /// Generate embedding for a single query string (async version)
     ///
     /// Use this method when calling from an async context to avoid runtime nesting issues.
     pub async fn embed_query_async(&self, query: &str) -> Result<Vec<f32>> {
-        self.provider.embed_query(query).await
+        let embedding = self
+            .with_fallback(|backend| async move { backend.provider.embed_query(query).await })
+            .await?;
+        Ok(self.truncate(embedding))
     }
 
     /// Generate embeddings for a batch of texts (sync version)
@@ -281,7 +911,7 @@ impl EmbeddingGenerator {
         // Create a new runtime for synchronous contexts only
         let rt = tokio::runtime::Runtime::new()
             .context("Failed to create tokio runtime for embedding")?;
-        rt.block_on(self.provider.embed(texts))
+        rt.block_on(self.embed_async(texts))
     }
 
     /// Generate embedding for a single query string (sync version)
@@ -295,17 +925,76 @@ impl EmbeddingGenerator {
         // Create a new runtime for synchronous contexts only
         let rt = tokio::runtime::Runtime::new()
             .context("Failed to create tokio runtime for embedding")?;
-        rt.block_on(self.provider.embed_query(query))
+        rt.block_on(self.embed_query_async(query))
     }
 
-    /// Get the embedding dimension for the current model
+    /// Get the embedding dimension for the current model, after truncation
     pub fn embedding_dimension(&self) -> usize {
-        self.provider.embedding_dimension()
+        self.truncate_dimensions
+            .unwrap_or_else(|| self.active().provider.embedding_dimension())
     }
 
     /// Get the batch size
     pub fn batch_size(&self) -> usize {
-        self.provider.max_batch_size()
+        self.active().provider.max_batch_size()
+    }
+}
+
+impl Backend {
+    /// Embed `texts` at the model's full dimension, reading and updating
+    /// the cache when enabled
+    async fn embed_cached(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        let Some(cache) = &self.cache else {
+            return self.embed_uncached(texts).await;
+        };
+
+        let mut embeddings = cache.get(texts).unwrap_or_else(|e| {
+            warn!("Failed to read the embedding cache: {:#}", e);
+            vec![None; texts.len()]
+        });
+        let missing: Vec<usize> = (0..texts.len())
+            .filter(|&i| embeddings[i].is_none())
+            .collect();
+        debug!(
+            "Embedding cache hit {} of {} texts",
+            texts.len() - missing.len(),
+            texts.len()
+        );
+
+        if !missing.is_empty() {
+            let misses: Vec<String> = missing.iter().map(|&i| texts[i].clone()).collect();
+            let embedded = self.embed_uncached(&misses).await?;
+            if let Err(e) = cache.insert(&misses, &embedded) {
+                warn!("Failed to update the embedding cache: {:#}", e);
+            }
+            for (i, embedding) in missing.into_iter().zip(embedded) {
+                embeddings[i] = Some(embedding);
+            }
+        }
+        Ok(embeddings
+            .into_iter()
+            .map(Option::unwrap_or_default)
+            .collect())
+    }
+
+    /// Embed `texts` with the provider, in batches
+    async fn embed_uncached(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        self.batcher
+            .embed(
+                texts,
+                |batch| async move { self.provider.embed(&batch).await },
+            )
+            .await
+    }
+}
+
+/// Keep the first `dimensions` values of `embedding` and rescale them to
+/// unit length; a zero vector stays zero
+fn truncate_and_normalize(embedding: &mut Vec<f32>, dimensions: usize) {
+    embedding.truncate(dimensions);
+    let norm = embedding.iter().map(|x| x * x).sum::<f32>().sqrt();
+    if norm > 0.0 {
+        embedding.iter_mut().for_each(|x| *x /= norm);
     }
 }
 
@@ -313,6 +1002,144 @@ impl EmbeddingGenerator {
 mod tests {
     use super::*;
 
+    #[test]
+    fn test_truncate_and_normalize() {
+        let mut embedding = vec![2.0, 0.0, 5.0, 0.0];
+        truncate_and_normalize(&mut embedding, 2);
+        assert_eq!(embedding, vec![1.0, 0.0]);
+
+        let mut embedding = vec![3.0, 4.0, 12.0];
+        truncate_and_normalize(&mut embedding, 2);
+        assert_eq!(embedding, vec![0.6, 0.8]);
+
+        let mut zero = vec![0.0; 4];
+        truncate_and_normalize(&mut zero, 3);
+        assert_eq!(zero, vec![0.0; 3]);
+    }
+
+    /// Provider answering `ok_calls` calls with `value` vectors, then failing
+    struct Failing {
+        ok_calls: usize,
+        calls: std::sync::atomic::AtomicUsize,
+        value: f32,
+    }
+
+    impl Failing {
+        fn new(ok_calls: usize, value: f32) -> Arc<dyn EmbeddingProvider> {
+            Arc::new(Self {
+                ok_calls,
+                calls: Default::default(),
+                value,
+            })
+        }
+    }
+
+    #[async_trait]
+    impl EmbeddingProvider for Failing {
+        async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+            use std::sync::atomic::Ordering;
+            if self.calls.fetch_add(1, Ordering::SeqCst) >= self.ok_calls {
+                anyhow::bail!("connection refused");
+            }
+            Ok(texts.iter().map(|_| vec![self.value, 0.0]).collect())
+        }
+
+        async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+            Ok(self.embed(&[query.to_string()]).await?.remove(0))
+        }
+
+        fn embedding_dimension(&self) -> usize {
+            2
+        }
+
+        fn provider_name(&self) -> &'static str {
+            "failing"
+        }
+
+        fn max_batch_size(&self) -> usize {
+            8
+        }
+
+        async fn health_check(&self) -> Result<HealthStatus> {
+            Ok(HealthStatus::Healthy)
+        }
+
+        fn capabilities(&self) -> ProviderCapabilities {
+            ProviderCapabilities {
+                supports_batching: true,
+                supports_async: true,
+                requires_api_key: false,
+                is_local: true,
+                max_text_length: 1000,
+                cost_per_token: None,
+            }
+        }
+    }
+
+    fn chain_config(provider: crate::config::EmbeddingProvider) -> crate::config::EmbeddingsConfig {
+        crate::config::EmbeddingsConfig {
+            provider,
+            cache: false,
+            max_retries: 0,
+            ..Default::default()
+        }
+    }
+
+    #[tokio::test]
+    async fn test_falls_back_when_the_provider_fails_mid_run() {
+        use crate::config::EmbeddingProvider as ConfigProvider;
+
+        let config = chain_config(ConfigProvider::OpenAI);
+        let generator = EmbeddingGenerator::with_fallbacks(
+            Failing::new(1, 1.0),
+            &config,
+            config.provider,
+            vec![Fallback {
+                config: chain_config(ConfigProvider::Ollama),
+                provider: Some(Failing::new(usize::MAX, 2.0)),
+            }],
+        )
+        .unwrap();
+        let texts = vec!["a".to_string(), "b".to_string()];
+
+        let first = generator.embed_async(&texts).await.unwrap();
+        assert_eq!(first, vec![vec![1.0, 0.0]; 2]);
+        assert_eq!(generator.provider(), ConfigProvider::OpenAI);
+
+        // The primary fails from here on; the call is answered by Ollama
+        let second = generator.embed_async(&texts).await.unwrap();
+        assert_eq!(second, vec![vec![2.0, 0.0]; 2]);
+        assert_eq!(generator.provider(), ConfigProvider::Ollama);
+        assert_eq!(
+            generator.index_path(std::path::Path::new("/x/index.lance")),
+            std::path::Path::new("/x/index-ollama.lance")
+        );
+        assert_eq!(
+            generator.embed_query_async("q").await.unwrap(),
+            vec![2.0, 0.0]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_error_when_no_fallback_is_left() {
+        use crate::config::EmbeddingProvider as ConfigProvider;
+
+        let config = chain_config(ConfigProvider::OpenAI);
+        let generator = EmbeddingGenerator::with_fallbacks(
+            Failing::new(0, 1.0),
+            &config,
+            config.provider,
+            vec![Fallback {
+                config: chain_config(ConfigProvider::Ollama),
+                provider: Some(Failing::new(0, 2.0)),
+            }],
+        )
+        .unwrap();
+
+        let error = generator.embed_async(&["a".to_string()]).await.unwrap_err();
+        assert!(format!("{:#}", error).contains("connection refused"));
+    }
+
     fn test_config() -> FastEmbedConfig {
         FastEmbedConfig {
             model: "all-MiniLM-L6-v2".to_string(), // Smaller, faster for tests
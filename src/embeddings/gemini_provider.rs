@@ -0,0 +1,515 @@
+//! Google Gemini and Vertex AI embedding provider
+//!
+//! Embeds with Gemini embedding models through the Gemini API (API key) or,
+//! with `vertex_project` set, through Vertex AI. Chunks are embedded with
+//! the `RETRIEVAL_DOCUMENT` task type and queries with `RETRIEVAL_QUERY` (or
+//! the configured query task type), so the model places both for retrieval
+//! without any setting on the caller's side.
+
+use anyhow::{anyhow, bail, Context, Result};
+use async_trait::async_trait;
+use serde::{Deserialize, Serialize};
+use std::time::{Duration, Instant};
+use tokio::sync::Mutex;
+use tracing::{info, warn};
+
+use super::config::GeminiConfig;
+use super::provider::{
+    status_error, EmbeddingProvider, HealthStatus, ProviderCapabilities, Rejected,
+};
+use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+
+/// Text embedded once at startup to check access and learn the dimension
+const DIMENSION_PROBE: &str = "dimension probe";
+
+/// Task type of indexed chunks
+const DOCUMENT_TASK: &str = "RETRIEVAL_DOCUMENT";
+
+/// Most texts the Gemini API embeds in one batch request
+const GEMINI_MAX_BATCH: usize = 100;
+
+/// Most texts Vertex AI embeds in one request; Gemini models there take one
+const VERTEX_MAX_BATCH: usize = 250;
+
+/// Access tokens printed by gcloud are reused for this long (they last an
+/// hour)
+const TOKEN_LIFETIME: Duration = Duration::from_secs(45 * 60);
+
+const INITIAL_BACKOFF: Duration = Duration::from_millis(1000);
+const MAX_BACKOFF: Duration = Duration::from_secs(60);
+
+#[derive(Serialize)]
+struct BatchEmbedRequest<'a> {
+    requests: Vec<EmbedContentRequest<'a>>,
+}
+
+#[derive(Serialize)]
+#[serde(rename_all = "camelCase")]
+struct EmbedContentRequest<'a> {
+    model: String,
+    content: Content<'a>,
+    task_type: &'a str,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    output_dimensionality: Option<u32>,
+}
+
+#[derive(Serialize)]
+struct Content<'a> {
+    parts: [Part<'a>; 1],
+}
+
+#[derive(Serialize)]
+struct Part<'a> {
+    text: &'a str,
+}
+
+#[derive(Deserialize)]
+struct BatchEmbedResponse {
+    embeddings: Vec<ContentEmbedding>,
+}
+
+#[derive(Deserialize)]
+struct ContentEmbedding {
+    values: Vec<f32>,
+}
+
+#[derive(Serialize)]
+struct PredictRequest<'a> {
+    instances: Vec<Instance<'a>>,
+    parameters: PredictParameters,
+}
+
+#[derive(Serialize)]
+struct Instance<'a> {
+    content: &'a str,
+    task_type: &'a str,
+}
+
+#[derive(Serialize)]
+#[serde(rename_all = "camelCase")]
+struct PredictParameters {
+    auto_truncate: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    output_dimensionality: Option<u32>,
+}
+
+#[derive(Deserialize)]
+struct PredictResponse {
+    predictions: Vec<Prediction>,
+}
+
+#[derive(Deserialize)]
+struct Prediction {
+    embeddings: ContentEmbedding,
+}
+
+/// A Vertex AI access token and when it was obtained
+struct CachedToken {
+    value: String,
+    obtained: Instant,
+}
+
+/// Where requests go and how they authenticate
+enum Endpoint {
+    /// The Gemini API with an API key
+    Gemini { api_key: String },
+    /// Vertex AI with an OAuth access token: `GOOGLE_OAUTH_ACCESS_TOKEN`, or
+    /// one printed by `gcloud auth print-access-token` and cached
+    Vertex {
+        project: String,
+        location: String,
+        token: Mutex<Option<CachedToken>>,
+    },
+}
+
+/// Google Gemini and Vertex AI embedding provider implementation
+pub struct GeminiProvider {
+    client: reqwest::Client,
+    config: GeminiConfig,
+    endpoint: Endpoint,
+    dimension: usize,
+}
+
+impl GeminiProvider {
+    /// Create the provider and embed a probe text to learn the dimension.
+    ///
+    /// Fails in offline mode, without credentials, and when the model
+    /// cannot be reached.
+    pub async fn new(config: &GeminiConfig) -> Result<Self> {
+        crate::offline::ensure_network_allowed("embeddings", "gemini")?;
+
+        let endpoint = match &config.vertex_project {
+            Some(project) => Endpoint::Vertex {
+                project: project.clone(),
+                location: config.vertex_location.clone(),
+                token: Mutex::new(None),
+            },
+            None => Endpoint::Gemini {
+                api_key: config
+                    .load_api_key()
+                    .context("Failed to load Gemini API key")?,
+            },
+        };
+        let client = reqwest::Client::builder()
+            .timeout(Duration::from_secs(config.timeout_secs))
+            .build()
+            .context("Failed to create HTTP client")?;
+        let mut provider = Self {
+            client,
+            config: config.clone(),
+            endpoint,
+            dimension: 0,
+        };
+
+        let probe = provider
+            .embed_query(DIMENSION_PROBE)
+            .await
+            .with_context(|| format!("Failed to embed with Gemini model '{}'", config.model))?;
+        if probe.is_empty() {
+            bail!(
+                "Gemini model '{}' returned an empty embedding",
+                config.model
+            );
+        }
+        provider.dimension = probe.len();
+
+        info!(
+            "Initialized Gemini provider with model: {} ({} dimensions)",
+            config.model, provider.dimension
+        );
+        Ok(provider)
+    }
+
+    /// Embedding dimension of a known model at its full size, or None for
+    /// unknown names
+    pub(crate) fn known_model_dimension(model: &str) -> Option<usize> {
+        match model {
+            "gemini-embedding-001" => Some(3072),
+            "text-embedding-004" | "text-embedding-005" => Some(768),
+            "text-multilingual-embedding-002" => Some(768),
+            _ => None,
+        }
+    }
+
+    /// Embed one batch with `task_type`, retrying failed requests with
+    /// exponential backoff
+    async fn request(&self, texts: &[String], task_type: &str) -> Result<Vec<Vec<f32>>> {
+        let mut attempt = 0;
+        let mut backoff = INITIAL_BACKOFF;
+        loop {
+            match self.send(texts, task_type).await {
+                Ok(embeddings) => return Ok(embeddings),
+                Err(e) if attempt >= self.config.max_retries => {
+                    return Err(e).context("Max retries exceeded");
+                }
+                Err(e) => {
+                    warn!("Gemini request failed (attempt {}): {:#}", attempt + 1, e);
+                    tokio::time::sleep(backoff).await;
+                    backoff = (backoff * 2).min(MAX_BACKOFF);
+                    attempt += 1;
+                }
+            }
+        }
+    }
+
+    /// Send one embedding request
+    async fn send(&self, texts: &[String], task_type: &str) -> Result<Vec<Vec<f32>>> {
+        let dimensions = self.config.dimensions;
+        let embeddings = match &self.endpoint {
+            Endpoint::Gemini { api_key } => {
+                let url = format!(
+                    "https://generativelanguage.googleapis.com/v1beta/models/{}:batchEmbedContents",
+                    self.config.model
+                );
+                let body = BatchEmbedRequest {
+                    requests: texts
+                        .iter()
+                        .map(|text| EmbedContentRequest {
+                            model: format!("models/{}", self.config.model),
+                            content: Content {
+                                parts: [Part { text }],
+                            },
+                            task_type,
+                            output_dimensionality: dimensions,
+                        })
+                        .collect(),
+                };
+                let request = self
+                    .client
+                    .post(&url)
+                    .header("x-goog-api-key", api_key)
+                    .json(&body);
+                let response: BatchEmbedResponse = send_json(request, &url).await?;
+                response.embeddings
+            }
+            Endpoint::Vertex {
+                project,
+                location,
+                token,
+            } => {
+                let url = vertex_url(project, location, &self.config.model);
+                let body = PredictRequest {
+                    instances: texts
+                        .iter()
+                        .map(|text| Instance {
+                            content: text,
+                            task_type,
+                        })
+                        .collect(),
+                    parameters: PredictParameters {
+                        auto_truncate: true,
+                        output_dimensionality: dimensions,
+                    },
+                };
+                let request = self
+                    .client
+                    .post(&url)
+                    .bearer_auth(access_token(token).await?)
+                    .json(&body);
+                let response: PredictResponse = send_json(request, &url).await?;
+                response
+                    .predictions
+                    .into_iter()
+                    .map(|p| p.embeddings)
+                    .collect()
+            }
+        };
+
+        if embeddings.len() != texts.len() {
+            bail!(
+                "Gemini returned {} embeddings for {} texts",
+                embeddings.len(),
+                texts.len()
+            );
+        }
+        Ok(embeddings
+            .into_iter()
+            .map(|e| normalize(e.values))
+            .collect())
+    }
+}
+
+/// Vertex AI predict URL of a Google model
+fn vertex_url(project: &str, location: &str, model: &str) -> String {
+    let host = match location {
+        "global" => "aiplatform.googleapis.com".to_string(),
+        _ => format!("{}-aiplatform.googleapis.com", location),
+    };
+    format!(
+        "https://{}/v1/projects/{}/locations/{}/publishers/google/models/{}:predict",
+        host, project, location, model
+    )
+}
+
+/// Send `request` and parse its JSON response
+async fn send_json<T: serde::de::DeserializeOwned>(
+    request: reqwest::RequestBuilder,
+    url: &str,
+) -> Result<T> {
+    let response = request
+        .send()
+        .await
+        .with_context(|| format!("Gemini request to {} failed", url))?;
+    let status = response.status();
+    if !status.is_success() {
+        let body = response.text().await.unwrap_or_default();
+        return Err(status_error("Gemini", status, &body));
+    }
+    response
+        .json()
+        .await
+        .context("Failed to parse Gemini embedding response")
+}
+
+/// A Vertex AI access token, obtained from gcloud when the cached one is
+/// old
+async fn access_token(cached: &Mutex<Option<CachedToken>>) -> Result<String> {
+    if let Ok(token) = std::env::var("GOOGLE_OAUTH_ACCESS_TOKEN") {
+        return Ok(token);
+    }
+
+    let mut cached = cached.lock().await;
+    if let Some(token) = cached
+        .as_ref()
+        .filter(|t| t.obtained.elapsed() < TOKEN_LIFETIME)
+    {
+        return Ok(token.value.clone());
+    }
+
+    let output = tokio::process::Command::new("gcloud")
+        .args(["auth", "print-access-token"])
+        .output()
+        .await
+        .context(
+            "Vertex AI needs GOOGLE_OAUTH_ACCESS_TOKEN or the gcloud CLI to get an access token",
+        )?;
+    if !output.status.success() {
+        bail!(
+            "gcloud auth print-access-token failed: {}",
+            String::from_utf8_lossy(&output.stderr).trim()
+        );
+    }
+    let value = String::from_utf8(output.stdout)
+        .context("gcloud printed an invalid access token")?
+        .trim()
+        .to_string();
+    *cached = Some(CachedToken {
+        value: value.clone(),
+        obtained: Instant::now(),
+    });
+    Ok(value)
+}
+
+/// Scale `vector` to unit length; embeddings of reduced dimensionality are
+/// not normalized by the API
+fn normalize(mut vector: Vec<f32>) -> Vec<f32> {
+    let norm = vector.iter().map(|x| x * x).sum::<f32>().sqrt();
+    if norm > 0.0 {
+        vector.iter_mut().for_each(|x| *x /= norm);
+    }
+    vector
+}
+
+#[async_trait]
+impl EmbeddingProvider for GeminiProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        if texts.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let mut all_embeddings = Vec::with_capacity(texts.len());
+        for batch in texts.chunks(self.max_batch_size()) {
+            all_embeddings.extend(self.request(batch, DOCUMENT_TASK).await?);
+        }
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        Ok(all_embeddings)
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let embedding = self
+            .request(&[query.to_string()], &self.config.query_task_type)
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No embedding returned"));
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        embedding
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        self.dimension
+    }
+
+    fn provider_name(&self) -> &'static str {
+        "gemini"
+    }
+
+    fn max_batch_size(&self) -> usize {
+        let limit = match self.endpoint {
+            Endpoint::Gemini { .. } => GEMINI_MAX_BATCH,
+            Endpoint::Vertex { .. } if self.config.model.starts_with("gemini-") => 1,
+            Endpoint::Vertex { .. } => VERTEX_MAX_BATCH,
+        };
+        self.config.batch_size.clamp(1, limit)
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        match self.embed_query(DIMENSION_PROBE).await {
+            Ok(_) => Ok(HealthStatus::Healthy),
+            Err(e) if matches!(Rejected::of(&e), Some(Rejected::RateLimited(_))) => {
+                Ok(HealthStatus::Degraded {
+                    reason: "Rate limited".to_string(),
+                })
+            }
+            Err(e) => Ok(HealthStatus::Unhealthy {
+                error: e.to_string(),
+            }),
+        }
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        ProviderCapabilities {
+            supports_batching: true,
+            supports_async: true,
+            requires_api_key: matches!(self.endpoint, Endpoint::Gemini { .. }),
+            is_local: false,
+            max_text_length: 2048,
+            cost_per_token: None,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_gemini_request_body() {
+        let body = BatchEmbedRequest {
+            requests: vec![EmbedContentRequest {
+                model: "models/gemini-embedding-001".to_string(),
+                content: Content {
+                    parts: [Part {
+                        text: "fn main() {}",
+                    }],
+                },
+                task_type: DOCUMENT_TASK,
+                output_dimensionality: Some(768),
+            }],
+        };
+        assert_eq!(
+            serde_json::to_value(&body).unwrap(),
+            serde_json::json!({
+                "requests": [{
+                    "model": "models/gemini-embedding-001",
+                    "content": {"parts": [{"text": "fn main() {}"}]},
+                    "taskType": "RETRIEVAL_DOCUMENT",
+                    "outputDimensionality": 768,
+                }]
+            })
+        );
+    }
+
+    #[test]
+    fn test_vertex_request() {
+        assert_eq!(
+            vertex_url("acme", "europe-west4", "text-embedding-005"),
+            "https://europe-west4-aiplatform.googleapis.com/v1/projects/acme/\
+             locations/europe-west4/publishers/google/models/text-embedding-005:predict"
+        );
+        assert!(vertex_url("acme", "global", "gemini-embedding-001")
+            .starts_with("https://aiplatform.googleapis.com/"));
+
+        let body = PredictRequest {
+            instances: vec![Instance {
+                content: "retry charge",
+                task_type: "RETRIEVAL_QUERY",
+            }],
+            parameters: PredictParameters {
+                auto_truncate: true,
+                output_dimensionality: None,
+            },
+        };
+        assert_eq!(
+            serde_json::to_value(&body).unwrap(),
+            serde_json::json!({
+                "instances": [{"content": "retry charge", "task_type": "RETRIEVAL_QUERY"}],
+                "parameters": {"autoTruncate": true},
+            })
+        );
+    }
+
+    #[test]
+    fn test_normalize() {
+        assert_eq!(normalize(vec![3.0, 4.0]), vec![0.6, 0.8]);
+        assert_eq!(normalize(vec![0.0, 0.0]), vec![0.0, 0.0]);
+    }
+}
@@ -1,20 +1,42 @@
 mod provider;
 mod config;
+mod azure_provider;
+mod batching;
+mod cache;
+mod bedrock_provider;
+mod cohere_provider;
 mod fastembed_provider;
+mod gemini_provider;
+#[cfg(test)]
+pub(crate) mod mock;
+mod ollama_provider;
+mod onnx_provider;
 mod openai_provider;
+mod query_model;
 mod registry;
+mod resilience;
+mod tei_provider;
+mod tokenizer;
+mod voyage_provider;
 
 // Re-export public interfaces
-pub use provider::{EmbeddingProvider, ProviderCapabilities, HealthStatus, ProviderInfo};
+pub use provider::{EmbeddingProvider, ProviderCapabilities, HealthStatus, ProviderInfo, Rejected};
 pub use config::{
     EnhancedEmbeddingsConfig, ProviderType, ProvidersConfig,
-    FastEmbedConfig, OpenAIConfig, CacheConfig, RetryConfig
+    FastEmbedConfig, OpenAIConfig, OllamaConfig, AzureOpenAIConfig, BedrockConfig, GeminiConfig, VoyageConfig, CohereConfig, OnnxConfig, TeiConfig, CacheConfig, RetryConfig
 };
+pub use query_model::{model_dimension, query_model_config};
 pub use registry::{ProviderRegistry, ProviderFactory};
+pub use tokenizer::{
+    tokenizer_for_config, tokenizer_for_model, BpeTokenizer, SubwordTokenizer, Tokenizer,
+    WhitespaceTokenizer,
+};
 
 // Re-export legacy EmbeddingGenerator for backward compatibility
 pub use fastembed_provider::EmbeddingGenerator;
 
+pub(crate) use ollama_provider::is_loopback;
+
 use anyhow::Result;
 use std::sync::Arc;
 
@@ -0,0 +1,277 @@
+//! Ollama embedding provider
+//!
+//! Embeds through the `/api/embed` endpoint of an Ollama server. With the
+//! server on this machine (the default host), code never leaves it, and
+//! offline mode allows the provider; a remote host counts as a network
+//! backend.
+
+use anyhow::{anyhow, bail, Context, Result};
+use async_trait::async_trait;
+use serde::{Deserialize, Serialize};
+use std::net::IpAddr;
+use std::time::{Duration, Instant};
+use tracing::info;
+
+use super::config::OllamaConfig;
+use super::provider::{status_error, EmbeddingProvider, HealthStatus, ProviderCapabilities};
+use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+
+/// Text embedded once at startup to learn the model's dimension
+const DIMENSION_PROBE: &str = "dimension probe";
+
+#[derive(Serialize)]
+struct EmbedRequest<'a> {
+    model: &'a str,
+    input: &'a [String],
+    #[serde(skip_serializing_if = "Option::is_none")]
+    keep_alive: Option<&'a str>,
+    /// Cut inputs longer than the model's context instead of failing
+    truncate: bool,
+}
+
+#[derive(Deserialize)]
+struct EmbedResponse {
+    embeddings: Vec<Vec<f32>>,
+}
+
+/// Ollama embedding provider implementation
+pub struct OllamaProvider {
+    client: reqwest::Client,
+    config: OllamaConfig,
+    dimension: usize,
+}
+
+impl OllamaProvider {
+    /// Connect to the server and embed a probe text to learn the model's
+    /// dimension.
+    ///
+    /// Fails when the server is unreachable or the model is not pulled, and
+    /// in offline mode when the host is not this machine.
+    pub async fn new(config: &OllamaConfig) -> Result<Self> {
+        if !is_loopback(&config.host) {
+            crate::offline::ensure_network_allowed("embeddings", "ollama")?;
+        }
+
+        let client = reqwest::Client::builder()
+            .timeout(Duration::from_secs(config.timeout_secs))
+            .build()
+            .context("Failed to create HTTP client")?;
+        let mut provider = Self {
+            client,
+            config: config.clone(),
+            dimension: 0,
+        };
+
+        let probe = provider
+            .request(&[DIMENSION_PROBE.to_string()])
+            .await
+            .with_context(|| {
+                format!(
+                    "Failed to embed with Ollama model '{}' at {}. Is the server running \
+                     and the model pulled (`ollama pull {}`)?",
+                    config.model, config.host, config.model
+                )
+            })?;
+        provider.dimension = probe.first().map_or(0, Vec::len);
+        if provider.dimension == 0 {
+            bail!(
+                "Ollama model '{}' returned an empty embedding",
+                config.model
+            );
+        }
+
+        info!(
+            "Initialized Ollama provider with model: {} ({} dimensions)",
+            config.model, provider.dimension
+        );
+        Ok(provider)
+    }
+
+    /// Embedding dimension of a known model, or None for unknown names.
+    ///
+    /// Only untagged names and `:latest` are known, since other tags may
+    /// be different sizes of a model.
+    pub(crate) fn known_model_dimension(model: &str) -> Option<usize> {
+        match model.strip_suffix(":latest").unwrap_or(model) {
+            "nomic-embed-text" => Some(768),
+            "mxbai-embed-large" => Some(1024),
+            "snowflake-arctic-embed" => Some(1024),
+            "bge-m3" => Some(1024),
+            "bge-large" => Some(1024),
+            "all-minilm" => Some(384),
+            _ => None,
+        }
+    }
+
+    /// Embed one batch of texts
+    async fn request(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        let url = format!("{}/api/embed", self.config.host.trim_end_matches('/'));
+        let request = EmbedRequest {
+            model: &self.config.model,
+            input: texts,
+            keep_alive: self.config.keep_alive.as_deref(),
+            truncate: true,
+        };
+
+        let response = self
+            .client
+            .post(&url)
+            .json(&request)
+            .send()
+            .await
+            .with_context(|| format!("Ollama request to {} failed", url))?;
+        let status = response.status();
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            return Err(status_error("Ollama", status, &body));
+        }
+
+        let body: EmbedResponse = response
+            .json()
+            .await
+            .context("Failed to parse Ollama embed response")?;
+        if body.embeddings.len() != texts.len() {
+            bail!(
+                "Ollama returned {} embeddings for {} texts",
+                body.embeddings.len(),
+                texts.len()
+            );
+        }
+        Ok(body.embeddings)
+    }
+}
+
+/// Whether the URL `host` points at this machine
+pub(crate) fn is_loopback(host: &str) -> bool {
+    let Ok(url) = reqwest::Url::parse(host) else {
+        return false;
+    };
+    match url.host_str() {
+        Some("localhost") => true,
+        Some(name) => name
+            .trim_start_matches('[')
+            .trim_end_matches(']')
+            .parse::<IpAddr>()
+            .is_ok_and(|ip| ip.is_loopback()),
+        None => false,
+    }
+}
+
+#[async_trait]
+impl EmbeddingProvider for OllamaProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        if texts.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let mut all_embeddings = Vec::with_capacity(texts.len());
+        for batch in texts.chunks(self.max_batch_size()) {
+            all_embeddings.extend(self.request(batch).await?);
+        }
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        Ok(all_embeddings)
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let embedding = self
+            .request(&[query.to_string()])
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No embedding returned"));
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        embedding
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        self.dimension
+    }
+
+    fn provider_name(&self) -> &'static str {
+        "ollama"
+    }
+
+    fn max_batch_size(&self) -> usize {
+        self.config.batch_size.max(1)
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        match self.embed_query(DIMENSION_PROBE).await {
+            Ok(_) => Ok(HealthStatus::Healthy),
+            Err(e) => Ok(HealthStatus::Unhealthy {
+                error: e.to_string(),
+            }),
+        }
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        ProviderCapabilities {
+            supports_batching: true,
+            supports_async: true,
+            requires_api_key: false,
+            is_local: is_loopback(&self.config.host),
+            max_text_length: 2048,
+            cost_per_token: None,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_model_dimension() {
+        assert_eq!(
+            OllamaProvider::known_model_dimension("nomic-embed-text"),
+            Some(768)
+        );
+        assert_eq!(
+            OllamaProvider::known_model_dimension("mxbai-embed-large:latest"),
+            Some(1024)
+        );
+        // Other tags may be another size of the model
+        assert_eq!(
+            OllamaProvider::known_model_dimension("snowflake-arctic-embed:22m"),
+            None
+        );
+    }
+
+    #[test]
+    fn test_is_loopback() {
+        assert!(is_loopback("http://localhost:11434"));
+        assert!(is_loopback("http://127.0.0.1:11434/"));
+        assert!(is_loopback("http://[::1]:11434"));
+        assert!(!is_loopback("http://gpu-box.internal:11434"));
+        assert!(!is_loopback("http://10.0.0.5:11434"));
+        assert!(!is_loopback("localhost:11434"));
+    }
+
+    #[test]
+    fn test_request_body() {
+        let input = vec!["fn main() {}".to_string()];
+        let request = EmbedRequest {
+            model: "nomic-embed-text",
+            input: &input,
+            keep_alive: Some("30m"),
+            truncate: true,
+        };
+        assert_eq!(
+            serde_json::to_value(&request).unwrap(),
+            serde_json::json!({
+                "model": "nomic-embed-text",
+                "input": ["fn main() {}"],
+                "keep_alive": "30m",
+                "truncate": true,
+            })
+        );
+    }
+}
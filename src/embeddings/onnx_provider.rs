@@ -0,0 +1,205 @@
+//! Local ONNX embedding provider
+//!
+//! Runs an embedding model exported to ONNX entirely in-process with ONNX
+//! Runtime. The model is read from a local directory in the Hugging Face
+//! export layout, so nothing is downloaded and no server is needed:
+//!
+//! - `model.onnx` (or `onnx/model.onnx`)
+//! - `tokenizer.json`, `config.json`, `special_tokens_map.json` and
+//!   `tokenizer_config.json`
+
+use anyhow::{anyhow, bail, Context, Result};
+use async_trait::async_trait;
+use fastembed::{
+    InitOptionsUserDefined, Pooling, TextEmbedding, TokenizerFiles, UserDefinedEmbeddingModel,
+};
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+use std::time::Instant;
+use tracing::info;
+
+use super::config::OnnxConfig;
+use super::provider::{EmbeddingProvider, HealthStatus, ProviderCapabilities};
+use crate::config::OnnxPooling;
+use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+
+/// Text embedded once at startup to check the model and learn the dimension
+const DIMENSION_PROBE: &str = "dimension probe";
+
+/// Where the ONNX graph may sit in a model directory, in order
+const MODEL_FILES: [&str; 2] = ["model.onnx", "onnx/model.onnx"];
+
+/// Local ONNX embedding provider implementation
+pub struct OnnxProvider {
+    model: Arc<TextEmbedding>,
+    config: OnnxConfig,
+    dimension: usize,
+}
+
+impl OnnxProvider {
+    /// Load the model in `config.model_dir` and embed a probe text to learn
+    /// its dimension.
+    ///
+    /// Fails when a model or tokenizer file is missing or the model cannot
+    /// be run.
+    pub fn new(config: &OnnxConfig) -> Result<Self> {
+        let dir = &config.model_dir;
+        info!("Loading ONNX embedding model from {}", dir.display());
+
+        let onnx_file = std::fs::read(model_file(dir)?)
+            .with_context(|| format!("Failed to read the ONNX model in {}", dir.display()))?;
+        let read = |name: &str| {
+            std::fs::read(dir.join(name))
+                .with_context(|| format!("Failed to read {} in {}", name, dir.display()))
+        };
+        let tokenizer_files = TokenizerFiles {
+            tokenizer_file: read("tokenizer.json")?,
+            config_file: read("config.json")?,
+            special_tokens_map_file: read("special_tokens_map.json")?,
+            tokenizer_config_file: read("tokenizer_config.json")?,
+        };
+        let pooling = match config.pooling {
+            OnnxPooling::Mean => Pooling::Mean,
+            OnnxPooling::Cls => Pooling::Cls,
+        };
+
+        let model = TextEmbedding::try_new_from_user_defined(
+            UserDefinedEmbeddingModel::new(onnx_file, tokenizer_files).with_pooling(pooling),
+            InitOptionsUserDefined::new().with_max_length(config.max_length),
+        )
+        .with_context(|| format!("Failed to load the ONNX model in {}", dir.display()))?;
+
+        let probe = model
+            .embed(vec![DIMENSION_PROBE], None)
+            .context("Failed to run the ONNX model")?
+            .into_iter()
+            .next()
+            .unwrap_or_default();
+        if probe.is_empty() {
+            bail!(
+                "The ONNX model in {} returned an empty embedding",
+                dir.display()
+            );
+        }
+
+        info!("ONNX embedding model loaded ({} dimensions)", probe.len());
+        Ok(Self {
+            model: Arc::new(model),
+            config: config.clone(),
+            dimension: probe.len(),
+        })
+    }
+}
+
+/// Path of the ONNX graph in a model directory
+fn model_file(dir: &Path) -> Result<PathBuf> {
+    MODEL_FILES
+        .iter()
+        .map(|name| dir.join(name))
+        .find(|path| path.is_file())
+        .ok_or_else(|| anyhow!("No model.onnx or onnx/model.onnx in {}", dir.display()))
+}
+
+#[async_trait]
+impl EmbeddingProvider for OnnxProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        if texts.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        // Inference is CPU-bound, so keep it off the async workers
+        let model = self.model.clone();
+        let texts = texts.to_vec();
+        let batch_size = self.max_batch_size();
+        let embeddings = tokio::task::spawn_blocking(move || {
+            model
+                .embed(texts, Some(batch_size))
+                .context("Failed to generate embeddings")
+        })
+        .await
+        .context("ONNX processing task failed")??;
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        Ok(embeddings)
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        self.embed(&[query.to_string()])
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No embedding generated for query"))
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        self.dimension
+    }
+
+    fn provider_name(&self) -> &'static str {
+        "onnx"
+    }
+
+    fn max_batch_size(&self) -> usize {
+        self.config.batch_size.max(1)
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        match self.embed_query(DIMENSION_PROBE).await {
+            Ok(_) => Ok(HealthStatus::Healthy),
+            Err(e) => Ok(HealthStatus::Unhealthy {
+                error: e.to_string(),
+            }),
+        }
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        ProviderCapabilities {
+            supports_batching: true,
+            supports_async: false, // Wrapped sync
+            requires_api_key: false,
+            is_local: true,
+            max_text_length: self.config.max_length,
+            cost_per_token: None,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_model_file_lookup() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(model_file(dir.path()).is_err());
+
+        std::fs::create_dir(dir.path().join("onnx")).unwrap();
+        std::fs::write(dir.path().join("onnx/model.onnx"), b"").unwrap();
+        assert_eq!(
+            model_file(dir.path()).unwrap(),
+            dir.path().join("onnx/model.onnx")
+        );
+
+        std::fs::write(dir.path().join("model.onnx"), b"").unwrap();
+        assert_eq!(
+            model_file(dir.path()).unwrap(),
+            dir.path().join("model.onnx")
+        );
+    }
+
+    #[test]
+    fn test_missing_tokenizer_is_reported() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("model.onnx"), b"").unwrap();
+
+        let config = OnnxConfig {
+            model_dir: dir.path().to_path_buf(),
+            ..Default::default()
+        };
+        let error = OnnxProvider::new(&config).err().unwrap();
+        assert!(error.to_string().contains("tokenizer.json"));
+    }
+}
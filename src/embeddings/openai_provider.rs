@@ -1,7 +1,8 @@
-use anyhow::{anyhow, Context, Result};
+use anyhow::{anyhow, bail, Context, Result};
 use async_openai::{
     Client,
     config::OpenAIConfig as AsyncOpenAIConfig,
+    error::OpenAIError,
     types::CreateEmbeddingRequestArgs,
 };
 use async_trait::async_trait;
@@ -12,10 +13,10 @@ use tracing::{info, warn};
 
 use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
 use super::config::OpenAIConfig;
-use super::provider::{EmbeddingProvider, HealthStatus, ProviderCapabilities};
+use super::provider::{EmbeddingProvider, HealthStatus, ProviderCapabilities, Rejected};
 
 /// Rate limiter for API calls
-struct RateLimiter {
+pub(super) struct RateLimiter {
     tokens: Arc<RwLock<f64>>,
     max_tokens: f64,
     refill_rate: f64,
@@ -23,7 +24,7 @@ struct RateLimiter {
 }
 
 impl RateLimiter {
-    fn new(max_tokens: f64, refill_rate: f64) -> Self {
+    pub(super) fn new(max_tokens: f64, refill_rate: f64) -> Self {
         Self {
             tokens: Arc::new(RwLock::new(max_tokens)),
             max_tokens,
@@ -32,7 +33,7 @@ impl RateLimiter {
         }
     }
 
-    async fn acquire(&self, count: usize) -> Result<()> {
+    pub(super) async fn acquire(&self, count: usize) -> Result<()> {
         loop {
             let mut tokens = self.tokens.write().await;
             let mut last_refill = self.last_refill.write().await;
@@ -57,18 +58,38 @@ impl RateLimiter {
     }
 }
 
+/// Text embedded once at startup to learn the dimension of a model not
+/// in the known-model table
+const DIMENSION_PROBE: &str = "dimension probe";
+
 /// OpenAI embedding provider implementation
+///
+/// Also serves any OpenAI-compatible `/v1/embeddings` server (vLLM, LM
+/// Studio, a LiteLLM proxy) when `base_url` is set.
 pub struct OpenAIProvider {
     client: Client<AsyncOpenAIConfig>,
     config: OpenAIConfig,
     rate_limiter: Arc<RateLimiter>,
+    dimension: usize,
 }
 
 impl OpenAIProvider {
     /// Create a new OpenAI provider
+    ///
+    /// With a `base_url`, the API key may be omitted (local servers usually
+    /// need none), and a model missing from the known-model table has its
+    /// dimension learned by embedding a probe text.
     pub async fn new(config: &OpenAIConfig) -> Result<Self> {
-        let api_key = config.load_api_key()
-            .context("Failed to load OpenAI API key")?;
+        let custom_endpoint = config.base_url.is_some();
+        if !config.base_url.as_deref().is_some_and(super::is_loopback) {
+            crate::offline::ensure_network_allowed("embeddings", "openai")?;
+        }
+
+        let api_key = match config.load_api_key() {
+            Ok(key) => key,
+            Err(_) if custom_endpoint => String::new(),
+            Err(e) => return Err(e).context("Failed to load OpenAI API key"),
+        };
 
         let mut openai_config = AsyncOpenAIConfig::new()
             .with_api_key(api_key);
@@ -83,16 +104,39 @@ impl OpenAIProvider {
 
         let client = Client::with_config(openai_config);
 
-        // Rate limiter: 3500 requests per minute
-        let rate_limiter = Arc::new(RateLimiter::new(3500.0, 3500.0 / 60.0));
-
-        info!("Initialized OpenAI provider with model: {}", config.model);
+        // Rate limiter: a minute of requests, refilled continuously
+        let per_minute = config.requests_per_minute.max(1) as f64;
+        let rate_limiter = Arc::new(RateLimiter::new(per_minute, per_minute / 60.0));
 
-        Ok(Self {
+        let mut provider = Self {
             client,
             config: config.clone(),
             rate_limiter,
-        })
+            dimension: Self::get_model_dimension(&config.model),
+        };
+
+        if custom_endpoint && Self::known_model_dimension(&config.model).is_none() {
+            let probe = provider
+                .embed_query(DIMENSION_PROBE)
+                .await
+                .with_context(|| {
+                    format!(
+                        "Failed to embed with model '{}' at {}",
+                        config.model,
+                        config.base_url.as_deref().unwrap_or_default()
+                    )
+                })?;
+            if probe.is_empty() {
+                bail!("Model '{}' returned an empty embedding", config.model);
+            }
+            provider.dimension = probe.len();
+        }
+
+        info!(
+            "Initialized OpenAI provider with model: {} ({} dimensions)",
+            config.model, provider.dimension
+        );
+        Ok(provider)
     }
 
     /// Get model string for OpenAI API
@@ -104,11 +148,16 @@ impl OpenAIProvider {
 
     /// Get embedding dimension for specific model
     fn get_model_dimension(model_name: &str) -> usize {
+        Self::known_model_dimension(model_name).unwrap_or(1536)  // Default
+    }
+
+    /// Embedding dimension of a known model, or None for unknown names
+    pub(crate) fn known_model_dimension(model_name: &str) -> Option<usize> {
         match model_name {
-            "text-embedding-3-small" => 1536,
-            "text-embedding-3-large" => 3072,
-            "text-embedding-ada-002" => 1536,
-            _ => 1536,  // Default
+            "text-embedding-3-small" => Some(1536),
+            "text-embedding-3-large" => Some(3072),
+            "text-embedding-ada-002" => Some(1536),
+            _ => None,
         }
     }
 
@@ -145,6 +194,17 @@ impl OpenAIProvider {
     }
 }
 
+/// A failed API request as an error, [`Rejected::TooLarge`] when it was
+/// over the per-request token limit
+fn request_error(error: OpenAIError) -> anyhow::Error {
+    match &error {
+        OpenAIError::ApiError(api) if api.r#type.as_deref() == Some("max_tokens_per_request") => {
+            Rejected::TooLarge(format!("OpenAI API request failed: {}", api.message)).into()
+        }
+        _ => anyhow::Error::new(error).context("OpenAI API request failed"),
+    }
+}
+
 #[async_trait]
 impl EmbeddingProvider for OpenAIProvider {
     async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
@@ -175,7 +235,7 @@ impl EmbeddingProvider for OpenAIProvider {
                     .embeddings()
                     .create(request.clone())
                     .await
-                    .context("OpenAI API request failed")
+                    .map_err(request_error)
             }).await?;
 
             for embedding_data in response.data {
@@ -211,7 +271,7 @@ impl EmbeddingProvider for OpenAIProvider {
                 .embeddings()
                 .create(request.clone())
                 .await
-                .context("OpenAI API request failed")
+                .map_err(request_error)
         }).await?;
 
         // Record embedding latency metric
@@ -225,7 +285,7 @@ impl EmbeddingProvider for OpenAIProvider {
     }
 
     fn embedding_dimension(&self) -> usize {
-        Self::get_model_dimension(&self.config.model)
+        self.dimension
     }
 
     fn provider_name(&self) -> &'static str {
@@ -252,17 +312,21 @@ impl EmbeddingProvider for OpenAIProvider {
     }
 
     fn capabilities(&self) -> ProviderCapabilities {
+        let base_url = self.config.base_url.as_deref();
         ProviderCapabilities {
             supports_batching: true,
             supports_async: true,
-            requires_api_key: true,
-            is_local: false,
+            requires_api_key: base_url.is_none(),
+            is_local: base_url.is_some_and(super::is_loopback),
             max_text_length: 8191,  // Tokens
-            cost_per_token: Some(match self.config.model.as_str() {
-                "text-embedding-3-small" => 0.00002,  // $0.02 per 1M tokens
-                "text-embedding-3-large" => 0.00013,  // $0.13 per 1M tokens
-                _ => 0.00010,
-            }),
+            // Other servers' pricing is unknown
+            cost_per_token: base_url
+                .is_none()
+                .then(|| match self.config.model.as_str() {
+                    "text-embedding-3-small" => 0.00002,  // $0.02 per 1M tokens
+                    "text-embedding-3-large" => 0.00013,  // $0.13 per 1M tokens
+                    _ => 0.00010,
+                }),
         }
     }
 }
@@ -284,6 +348,7 @@ mod tests {
             initial_backoff_ms: 1000,
             max_backoff_ms: 60000,
             exponential_base: 2.0,
+            requests_per_minute: 3500,
         }
     }
 
@@ -328,6 +393,7 @@ mod tests {
             initial_backoff_ms: 1000,
             max_backoff_ms: 60000,
             exponential_base: 2.0,
+            requests_per_minute: 3500,
         };
 
         let provider = OpenAIProvider::new(&config).await.unwrap();
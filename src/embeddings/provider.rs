@@ -1,5 +1,6 @@
-use anyhow::Result;
+use anyhow::{anyhow, Result};
 use async_trait::async_trait;
+use reqwest::StatusCode;
 
 /// Core trait for embedding providers
 #[async_trait]
@@ -37,6 +38,38 @@ pub struct ProviderCapabilities {
     pub cost_per_token: Option<f64>,
 }
 
+/// Error of a request the backend refused for its size or rate rather
+/// than for the texts in it
+#[derive(Debug, thiserror::Error)]
+pub enum Rejected {
+    /// The request was too large (413, or over a per-request token limit)
+    #[error("{0}")]
+    TooLarge(String),
+    /// Too many requests or tokens per minute (429)
+    #[error("{0}")]
+    RateLimited(String),
+}
+
+impl Rejected {
+    /// The rejection somewhere in `error`'s chain, if any
+    pub fn of(error: &anyhow::Error) -> Option<&Rejected> {
+        error
+            .chain()
+            .find_map(|cause| cause.downcast_ref::<Rejected>())
+    }
+}
+
+/// Error of an unsuccessful HTTP `status` from `provider`: [`Rejected`]
+/// for 413 and 429, a plain error otherwise
+pub(super) fn status_error(provider: &str, status: StatusCode, body: &str) -> anyhow::Error {
+    let message = format!("{} returned {}: {}", provider, status, body.trim());
+    match status {
+        StatusCode::PAYLOAD_TOO_LARGE => Rejected::TooLarge(message).into(),
+        StatusCode::TOO_MANY_REQUESTS => Rejected::RateLimited(message).into(),
+        _ => anyhow!(message),
+    }
+}
+
 /// Health status for provider monitoring
 #[derive(Debug, Clone)]
 pub enum HealthStatus {
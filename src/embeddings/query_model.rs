@@ -0,0 +1,182 @@
+//! Per-request query model override
+//!
+//! `coderag search --query-model <model>` embeds the query with a different
+//! model than the one the index was built with, for experimenting with
+//! query-side model swaps. The stored chunk vectors are not re-embedded, so
+//! this only works when the override:
+//!
+//! - belongs to the configured provider (FastEmbed, OpenAI, Ollama, Bedrock,
+//!   Gemini, Voyage, Cohere or Azure, whose deployments are checked as
+//!   OpenAI model names),
+//! - is a model coderag knows, and
+//! - produces vectors of the same dimension as the index.
+//!
+//! Matching dimensions make the vectors comparable, not meaningful: scores
+//! are only useful when both models share an embedding space (e.g. one
+//! model family). No projection between dimensions is attempted.
+
+use anyhow::{bail, Result};
+
+use super::bedrock_provider::BedrockProvider;
+use super::cohere_provider::CohereProvider;
+use super::fastembed_provider::FastEmbedProvider;
+use super::gemini_provider::GeminiProvider;
+use super::ollama_provider::OllamaProvider;
+use super::openai_provider::OpenAIProvider;
+use super::voyage_provider::VoyageProvider;
+use crate::config::{EmbeddingProvider, EmbeddingsConfig};
+
+/// Embedding dimension of `model` under the provider of `config`, after
+/// any `truncate_dimensions`, or None when the model is unknown.
+pub fn model_dimension(config: &EmbeddingsConfig, model: &str) -> Option<usize> {
+    let full = match config.provider {
+        EmbeddingProvider::FastEmbed => FastEmbedProvider::known_model_dimension(model),
+        EmbeddingProvider::OpenAI | EmbeddingProvider::Azure => {
+            OpenAIProvider::known_model_dimension(model)
+        }
+        EmbeddingProvider::Ollama => OllamaProvider::known_model_dimension(model),
+        EmbeddingProvider::Bedrock => BedrockProvider::known_model_dimension(model),
+        // A reduced dimensionality applies to every model
+        EmbeddingProvider::Gemini => GeminiProvider::known_model_dimension(model)
+            .map(|full| config.gemini_dimensions.map_or(full, |d| d as usize)),
+        EmbeddingProvider::Voyage => VoyageProvider::known_model_dimension(model)
+            .map(|full| config.voyage_dimensions.map_or(full, |d| d as usize)),
+        EmbeddingProvider::Cohere => CohereProvider::known_model_dimension(model)
+            .map(|full| config.cohere_dimensions.map_or(full, |d| d as usize)),
+        // A model directory or TEI server has no dimension known up front
+        EmbeddingProvider::Onnx | EmbeddingProvider::Tei => None,
+    };
+    full.map(|full| config.truncate_dimensions.map_or(full, |d| d.min(full)))
+}
+
+/// Embeddings config that embeds queries with `model` instead of the
+/// configured model.
+///
+/// Errors when the model is unknown or its dimension differs from
+/// `index_dimension`, the vector dimension of the index being searched.
+pub fn query_model_config(
+    config: &EmbeddingsConfig,
+    model: &str,
+    index_dimension: usize,
+) -> Result<EmbeddingsConfig> {
+    let Some(dimension) = model_dimension(config, model) else {
+        bail!(
+            "Unknown query model '{}' for the {} provider",
+            model,
+            config.provider.as_str()
+        );
+    };
+    if dimension != index_dimension {
+        bail!(
+            "Query model '{}' produces {}-dimensional vectors, but the index stores \
+             {}-dimensional vectors. A query model override must match the index \
+             dimension; to switch models, change embeddings.model and run \
+             `coderag index --force`.",
+            model,
+            dimension,
+            index_dimension
+        );
+    }
+
+    let mut config = config.clone();
+    match config.provider {
+        EmbeddingProvider::FastEmbed => config.model = model.to_string(),
+        EmbeddingProvider::OpenAI => config.openai_model = model.to_string(),
+        EmbeddingProvider::Ollama => config.ollama_model = model.to_string(),
+        EmbeddingProvider::Azure => config.azure_deployment = Some(model.to_string()),
+        EmbeddingProvider::Bedrock => config.bedrock_model = model.to_string(),
+        EmbeddingProvider::Gemini => config.gemini_model = model.to_string(),
+        EmbeddingProvider::Voyage => config.voyage_model = model.to_string(),
+        EmbeddingProvider::Cohere => config.cohere_model = model.to_string(),
+        EmbeddingProvider::Onnx => config.onnx_model_dir = Some(model.into()),
+        // A TEI server serves a single model
+        EmbeddingProvider::Tei => {}
+    }
+    Ok(config)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_compatible_override_replaces_model() {
+        // nomic-embed-text-v1.5 (default) and bge-base both produce 768 dimensions
+        let config = EmbeddingsConfig::default();
+        let overridden = query_model_config(&config, "bge-base-en-v1.5", 768).unwrap();
+
+        assert_eq!(overridden.model, "bge-base-en-v1.5");
+        assert_eq!(overridden.provider, config.provider);
+    }
+
+    #[test]
+    fn test_incompatible_override_is_rejected() {
+        let config = EmbeddingsConfig::default();
+
+        let err = query_model_config(&config, "bge-small-en-v1.5", 768).unwrap_err();
+        assert!(err.to_string().contains("384-dimensional"));
+        assert!(err.to_string().contains("768-dimensional"));
+    }
+
+    #[test]
+    fn test_unknown_override_is_rejected() {
+        // The provider falls back to nomic for unknown names; an override must not
+        let config = EmbeddingsConfig::default();
+        assert!(query_model_config(&config, "no-such-model", 768).is_err());
+    }
+
+    #[test]
+    fn test_openai_override() {
+        let config = EmbeddingsConfig {
+            provider: EmbeddingProvider::OpenAI,
+            ..Default::default()
+        };
+
+        let overridden = query_model_config(&config, "text-embedding-ada-002", 1536).unwrap();
+        assert_eq!(overridden.openai_model, "text-embedding-ada-002");
+        assert!(query_model_config(&config, "text-embedding-3-large", 1536).is_err());
+        // FastEmbed models are not valid for the OpenAI provider
+        assert!(query_model_config(&config, "bge-base-en-v1.5", 768).is_err());
+    }
+
+    #[test]
+    fn test_ollama_override() {
+        let config = EmbeddingsConfig {
+            provider: EmbeddingProvider::Ollama,
+            ..Default::default()
+        };
+
+        let overridden = query_model_config(&config, "mxbai-embed-large", 1024).unwrap();
+        assert_eq!(overridden.ollama_model, "mxbai-embed-large");
+        assert!(query_model_config(&config, "nomic-embed-text", 1024).is_err());
+    }
+
+    #[test]
+    fn test_gemini_reduced_dimension() {
+        let config = EmbeddingsConfig {
+            provider: EmbeddingProvider::Gemini,
+            gemini_dimensions: Some(768),
+            ..Default::default()
+        };
+
+        assert_eq!(model_dimension(&config, "gemini-embedding-001"), Some(768));
+        assert!(query_model_config(&config, "text-embedding-005", 768).is_ok());
+    }
+
+    #[test]
+    fn test_truncated_dimension() {
+        let config = EmbeddingsConfig {
+            provider: EmbeddingProvider::OpenAI,
+            openai_model: "text-embedding-3-large".to_string(),
+            truncate_dimensions: Some(512),
+            ..Default::default()
+        };
+
+        assert_eq!(
+            model_dimension(&config, "text-embedding-3-large"),
+            Some(512)
+        );
+        // Both models truncate to the index dimension
+        assert!(query_model_config(&config, "text-embedding-3-small", 512).is_ok());
+    }
+}
@@ -6,8 +6,16 @@ use tracing::{error, info, warn};
 
 use super::config::{EnhancedEmbeddingsConfig, ProviderType};
 use super::provider::{EmbeddingProvider, HealthStatus, ProviderInfo};
+use super::azure_provider::AzureOpenAIProvider;
+use super::bedrock_provider::BedrockProvider;
+use super::cohere_provider::CohereProvider;
 use super::fastembed_provider::FastEmbedProvider;
+use super::gemini_provider::GeminiProvider;
+use super::ollama_provider::OllamaProvider;
+use super::onnx_provider::OnnxProvider;
 use super::openai_provider::OpenAIProvider;
+use super::tei_provider::TeiProvider;
+use super::voyage_provider::VoyageProvider;
 
 /// Registry for managing embedding providers
 pub struct ProviderRegistry {
@@ -66,6 +74,66 @@ impl ProviderRegistry {
                 self.register("openai".to_string(), provider).await?;
                 *self.active_provider.write().await = "openai".to_string();
             }
+            ProviderType::Ollama => {
+                let provider_config = self.config.providers.ollama.clone().unwrap_or_default();
+
+                let provider = Arc::new(OllamaProvider::new(&provider_config).await?);
+                self.register("ollama".to_string(), provider).await?;
+                *self.active_provider.write().await = "ollama".to_string();
+            }
+            ProviderType::Azure => {
+                let provider_config = self.config.providers.azure
+                    .clone()
+                    .ok_or_else(|| anyhow!("Azure OpenAI configuration not provided"))?;
+
+                let provider = Arc::new(AzureOpenAIProvider::new(&provider_config).await?);
+                self.register("azure".to_string(), provider).await?;
+                *self.active_provider.write().await = "azure".to_string();
+            }
+            ProviderType::Bedrock => {
+                let provider_config = self.config.providers.bedrock.clone().unwrap_or_default();
+
+                let provider = Arc::new(BedrockProvider::new(&provider_config).await?);
+                self.register("bedrock".to_string(), provider).await?;
+                *self.active_provider.write().await = "bedrock".to_string();
+            }
+            ProviderType::Gemini => {
+                let provider_config = self.config.providers.gemini.clone().unwrap_or_default();
+
+                let provider = Arc::new(GeminiProvider::new(&provider_config).await?);
+                self.register("gemini".to_string(), provider).await?;
+                *self.active_provider.write().await = "gemini".to_string();
+            }
+            ProviderType::Voyage => {
+                let provider_config = self.config.providers.voyage.clone().unwrap_or_default();
+
+                let provider = Arc::new(VoyageProvider::new(&provider_config).await?);
+                self.register("voyage".to_string(), provider).await?;
+                *self.active_provider.write().await = "voyage".to_string();
+            }
+            ProviderType::Cohere => {
+                let provider_config = self.config.providers.cohere.clone().unwrap_or_default();
+
+                let provider = Arc::new(CohereProvider::new(&provider_config).await?);
+                self.register("cohere".to_string(), provider).await?;
+                *self.active_provider.write().await = "cohere".to_string();
+            }
+            ProviderType::Onnx => {
+                let provider_config = self.config.providers.onnx
+                    .clone()
+                    .ok_or_else(|| anyhow!("ONNX configuration not provided"))?;
+
+                let provider = Arc::new(OnnxProvider::new(&provider_config)?);
+                self.register("onnx".to_string(), provider).await?;
+                *self.active_provider.write().await = "onnx".to_string();
+            }
+            ProviderType::Tei => {
+                let provider_config = self.config.providers.tei.clone().unwrap_or_default();
+
+                let provider = Arc::new(TeiProvider::new(&provider_config).await?);
+                self.register("tei".to_string(), provider).await?;
+                *self.active_provider.write().await = "tei".to_string();
+            }
         }
 
         // Initialize fallback providers
@@ -87,6 +155,54 @@ impl ProviderRegistry {
                         self.register(fallback_name.clone(), provider).await?;
                     }
                 }
+                "ollama" => {
+                    if let Some(config) = &self.config.providers.ollama {
+                        let provider = Arc::new(OllamaProvider::new(config).await?);
+                        self.register(fallback_name.clone(), provider).await?;
+                    }
+                }
+                "azure" => {
+                    if let Some(config) = &self.config.providers.azure {
+                        let provider = Arc::new(AzureOpenAIProvider::new(config).await?);
+                        self.register(fallback_name.clone(), provider).await?;
+                    }
+                }
+                "bedrock" => {
+                    if let Some(config) = &self.config.providers.bedrock {
+                        let provider = Arc::new(BedrockProvider::new(config).await?);
+                        self.register(fallback_name.clone(), provider).await?;
+                    }
+                }
+                "gemini" => {
+                    if let Some(config) = &self.config.providers.gemini {
+                        let provider = Arc::new(GeminiProvider::new(config).await?);
+                        self.register(fallback_name.clone(), provider).await?;
+                    }
+                }
+                "voyage" => {
+                    if let Some(config) = &self.config.providers.voyage {
+                        let provider = Arc::new(VoyageProvider::new(config).await?);
+                        self.register(fallback_name.clone(), provider).await?;
+                    }
+                }
+                "cohere" => {
+                    if let Some(config) = &self.config.providers.cohere {
+                        let provider = Arc::new(CohereProvider::new(config).await?);
+                        self.register(fallback_name.clone(), provider).await?;
+                    }
+                }
+                "onnx" => {
+                    if let Some(config) = &self.config.providers.onnx {
+                        let provider = Arc::new(OnnxProvider::new(config)?);
+                        self.register(fallback_name.clone(), provider).await?;
+                    }
+                }
+                "tei" => {
+                    if let Some(config) = &self.config.providers.tei {
+                        let provider = Arc::new(TeiProvider::new(config).await?);
+                        self.register(fallback_name.clone(), provider).await?;
+                    }
+                }
                 _ => {
                     warn!("Unknown fallback provider: {}", fallback_name);
                 }
@@ -271,6 +387,50 @@ impl ProviderFactory {
 
                 Ok(Arc::new(OpenAIProvider::new(&provider_config).await?))
             }
+            ProviderType::Ollama => {
+                let provider_config = config.providers.ollama.clone().unwrap_or_default();
+
+                Ok(Arc::new(OllamaProvider::new(&provider_config).await?))
+            }
+            ProviderType::Azure => {
+                let provider_config = config.providers.azure
+                    .clone()
+                    .ok_or_else(|| anyhow!("Azure OpenAI configuration not provided"))?;
+
+                Ok(Arc::new(AzureOpenAIProvider::new(&provider_config).await?))
+            }
+            ProviderType::Bedrock => {
+                let provider_config = config.providers.bedrock.clone().unwrap_or_default();
+
+                Ok(Arc::new(BedrockProvider::new(&provider_config).await?))
+            }
+            ProviderType::Gemini => {
+                let provider_config = config.providers.gemini.clone().unwrap_or_default();
+
+                Ok(Arc::new(GeminiProvider::new(&provider_config).await?))
+            }
+            ProviderType::Voyage => {
+                let provider_config = config.providers.voyage.clone().unwrap_or_default();
+
+                Ok(Arc::new(VoyageProvider::new(&provider_config).await?))
+            }
+            ProviderType::Cohere => {
+                let provider_config = config.providers.cohere.clone().unwrap_or_default();
+
+                Ok(Arc::new(CohereProvider::new(&provider_config).await?))
+            }
+            ProviderType::Onnx => {
+                let provider_config = config.providers.onnx
+                    .clone()
+                    .ok_or_else(|| anyhow!("ONNX configuration not provided"))?;
+
+                Ok(Arc::new(OnnxProvider::new(&provider_config)?))
+            }
+            ProviderType::Tei => {
+                let provider_config = config.providers.tei.clone().unwrap_or_default();
+
+                Ok(Arc::new(TeiProvider::new(&provider_config).await?))
+            }
         }
     }
 
@@ -282,6 +442,14 @@ impl ProviderFactory {
         let provider_type = match name.to_lowercase().as_str() {
             "fastembed" => ProviderType::FastEmbed,
             "openai" => ProviderType::OpenAI,
+            "ollama" => ProviderType::Ollama,
+            "azure" => ProviderType::Azure,
+            "bedrock" => ProviderType::Bedrock,
+            "gemini" => ProviderType::Gemini,
+            "voyage" => ProviderType::Voyage,
+            "cohere" => ProviderType::Cohere,
+            "onnx" => ProviderType::Onnx,
+            "tei" => ProviderType::Tei,
             _ => return Err(anyhow!("Unknown provider type: {}", name)),
         };
 
@@ -305,6 +473,14 @@ mod tests {
                     cache_dir: None,
                 }),
                 openai: None,
+                ollama: None,
+                azure: None,
+                bedrock: None,
+                gemini: None,
+                voyage: None,
+                cohere: None,
+                onnx: None,
+                tei: None,
             },
             cache: Default::default(),
             retry: Default::default(),
@@ -351,6 +527,7 @@ mod tests {
             initial_backoff_ms: 1000,
             max_backoff_ms: 60000,
             exponential_base: 2.0,
+            requests_per_minute: 3500,
         });
 
         config.fallback_chain = vec!["openai".to_string()];
@@ -0,0 +1,437 @@
+//! Rate limiting, retries and circuit breaking for embedding providers
+//!
+//! [`ResilientProvider`] wraps a provider so that a long index run rides
+//! out transient API failures:
+//!
+//! - requests wait for client-side requests-per-minute and tokens-per-minute
+//!   budgets before they are sent,
+//! - requests failing with a rate limit (429), a server error (5xx) or a
+//!   network error are retried with exponential backoff and jitter, and
+//! - after a run of failed requests the circuit opens: further requests
+//!   fail at once for a cooldown, then a single trial request decides
+//!   whether it closes again.
+
+use anyhow::{anyhow, Result};
+use async_trait::async_trait;
+use std::collections::hash_map::RandomState;
+use std::hash::{BuildHasher, Hasher};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+use tracing::warn;
+
+use super::openai_provider::RateLimiter;
+use super::provider::{EmbeddingProvider, HealthStatus, ProviderCapabilities, Rejected};
+use super::tokenizer::Tokenizer;
+
+const INITIAL_BACKOFF: Duration = Duration::from_millis(1000);
+const MAX_BACKOFF: Duration = Duration::from_secs(60);
+
+/// How a wrapped provider is rate limited, retried and circuit broken
+#[derive(Debug, Clone)]
+pub struct ResiliencePolicy {
+    /// Retries of a failed request before giving up
+    pub max_retries: usize,
+    /// Client-side request budget; None for no limit
+    pub requests_per_minute: Option<u32>,
+    /// Client-side token budget; None for no limit
+    pub tokens_per_minute: Option<u32>,
+    /// Failed requests in a row that open the circuit; 0 never opens it
+    pub failure_threshold: u32,
+    /// How long an open circuit rejects requests
+    pub cooldown: Duration,
+}
+
+impl Default for ResiliencePolicy {
+    fn default() -> Self {
+        Self {
+            max_retries: 3,
+            requests_per_minute: None,
+            tokens_per_minute: None,
+            failure_threshold: 5,
+            cooldown: Duration::from_secs(30),
+        }
+    }
+}
+
+/// Whether a failed request is worth retrying: rate limits, server errors
+/// and network failures are, bad requests and authentication errors not
+pub(super) fn is_retryable(error: &anyhow::Error) -> bool {
+    match Rejected::of(error) {
+        Some(Rejected::RateLimited(_)) => return true,
+        Some(Rejected::TooLarge(_)) => return false,
+        None => {}
+    }
+    let message = format!("{:#}", error);
+    let lower = message.to_lowercase();
+    const STATUSES: [&str; 4] = [
+        "500 Internal Server Error",
+        "502 Bad Gateway",
+        "503 Service Unavailable",
+        "504 Gateway Timeout",
+    ];
+    STATUSES.iter().any(|status| message.contains(status))
+        || message.contains("ServiceUnavailableException")
+        || [
+            "rate limit",
+            "timed out",
+            "timeout",
+            "connection",
+            "overloaded",
+        ]
+        .iter()
+        .any(|needle| lower.contains(needle))
+}
+
+/// Delay before retry `attempt` (from 0): exponential, capped, with half
+/// of it randomized so that concurrent clients do not retry in lockstep
+pub(super) fn backoff_delay(attempt: u32) -> Duration {
+    let exponential = INITIAL_BACKOFF
+        .saturating_mul(2u32.saturating_pow(attempt))
+        .min(MAX_BACKOFF);
+    // RandomState is seeded randomly, which is enough for jitter
+    let random = RandomState::new().build_hasher().finish();
+    let fraction = (random % 1000) as f64 / 1000.0;
+    exponential / 2 + exponential.mul_f64(fraction / 2.0)
+}
+
+/// Circuit breaker state
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum Circuit {
+    /// Requests flow; counts failed requests in a row
+    Closed { failures: u32 },
+    /// Requests are rejected until the instant
+    Open { until: Instant },
+    /// One trial request is in flight after the cooldown
+    HalfOpen,
+}
+
+/// Stops requests to a provider that keeps failing
+#[derive(Debug)]
+pub struct CircuitBreaker {
+    threshold: u32,
+    cooldown: Duration,
+    state: Mutex<Circuit>,
+}
+
+impl CircuitBreaker {
+    pub fn new(threshold: u32, cooldown: Duration) -> Self {
+        Self {
+            threshold,
+            cooldown,
+            state: Mutex::new(Circuit::Closed { failures: 0 }),
+        }
+    }
+
+    /// Check that a request may be sent; after the cooldown the first
+    /// caller gets the trial request
+    fn allow(&self) -> Result<(), Duration> {
+        let mut state = self.state.lock().unwrap();
+        match *state {
+            Circuit::Closed { .. } => Ok(()),
+            Circuit::Open { until } => {
+                let now = Instant::now();
+                if now < until {
+                    return Err(until - now);
+                }
+                *state = Circuit::HalfOpen;
+                Ok(())
+            }
+            // Other callers wait for the trial's outcome
+            Circuit::HalfOpen => Err(Duration::ZERO),
+        }
+    }
+
+    fn record_success(&self) {
+        *self.state.lock().unwrap() = Circuit::Closed { failures: 0 };
+    }
+
+    fn record_failure(&self) {
+        if self.threshold == 0 {
+            return;
+        }
+        let mut state = self.state.lock().unwrap();
+        let failures = match *state {
+            Circuit::Closed { failures } => failures + 1,
+            // A failed trial reopens the circuit at once
+            _ => self.threshold,
+        };
+        *state = if failures >= self.threshold {
+            Circuit::Open {
+                until: Instant::now() + self.cooldown,
+            }
+        } else {
+            Circuit::Closed { failures }
+        };
+    }
+
+    /// Whether requests are currently rejected
+    pub fn is_open(&self) -> bool {
+        !matches!(*self.state.lock().unwrap(), Circuit::Closed { .. })
+    }
+}
+
+/// Provider wrapper applying a [`ResiliencePolicy`]
+pub struct ResilientProvider {
+    inner: Arc<dyn EmbeddingProvider>,
+    max_retries: usize,
+    requests: Option<RateLimiter>,
+    /// Token budget, its size, and the tokenizer requests are counted with
+    tokens: Option<(RateLimiter, usize, Arc<dyn Tokenizer>)>,
+    breaker: CircuitBreaker,
+}
+
+impl ResilientProvider {
+    /// Wrap `inner`; `tokenizer` counts tokens against the
+    /// tokens-per-minute budget.
+    ///
+    /// Rate limits only apply to network providers, since local ones have
+    /// no API quota.
+    pub fn new(
+        inner: Arc<dyn EmbeddingProvider>,
+        policy: &ResiliencePolicy,
+        tokenizer: Arc<dyn Tokenizer>,
+    ) -> Self {
+        let remote = !inner.capabilities().is_local;
+        let per_minute = |limit: u32| RateLimiter::new(limit as f64, limit as f64 / 60.0);
+        Self {
+            max_retries: policy.max_retries,
+            requests: policy
+                .requests_per_minute
+                .filter(|&rpm| remote && rpm > 0)
+                .map(per_minute),
+            tokens: policy
+                .tokens_per_minute
+                .filter(|&tpm| remote && tpm > 0)
+                .map(|tpm| (per_minute(tpm), tpm as usize, tokenizer)),
+            breaker: CircuitBreaker::new(policy.failure_threshold, policy.cooldown),
+            inner,
+        }
+    }
+
+    /// Send one request through the limits, retries and circuit breaker
+    async fn call<T, F, Fut>(&self, texts: &[&str], mut request: F) -> Result<T>
+    where
+        F: FnMut() -> Fut,
+        Fut: std::future::Future<Output = Result<T>>,
+    {
+        let mut attempt = 0;
+        loop {
+            if let Err(remaining) = self.breaker.allow() {
+                return Err(anyhow!(
+                    "Embedding provider '{}' is failing repeatedly; circuit open for {}s more",
+                    self.inner.provider_name(),
+                    remaining.as_secs()
+                ));
+            }
+
+            if let Some(requests) = &self.requests {
+                requests.acquire(1).await?;
+            }
+            if let Some((tokens, budget, tokenizer)) = &self.tokens {
+                let count: usize = texts.iter().map(|t| tokenizer.count_tokens(t)).sum();
+                // A request over the whole budget waits for all of it
+                tokens.acquire(count.min(*budget)).await?;
+            }
+
+            let error = match request().await {
+                Ok(value) => {
+                    self.breaker.record_success();
+                    return Ok(value);
+                }
+                Err(e) => e,
+            };
+            if attempt >= self.max_retries || !is_retryable(&error) {
+                self.breaker.record_failure();
+                return Err(error);
+            }
+
+            let delay = backoff_delay(attempt as u32);
+            warn!(
+                "{} request failed (attempt {}), retrying in {:?}: {:#}",
+                self.inner.provider_name(),
+                attempt + 1,
+                delay,
+                error
+            );
+            tokio::time::sleep(delay).await;
+            attempt += 1;
+        }
+    }
+}
+
+#[async_trait]
+impl EmbeddingProvider for ResilientProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        let refs: Vec<&str> = texts.iter().map(String::as_str).collect();
+        self.call(&refs, || self.inner.embed(texts)).await
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        self.call(&[query], || self.inner.embed_query(query)).await
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        self.inner.embedding_dimension()
+    }
+
+    fn provider_name(&self) -> &'static str {
+        self.inner.provider_name()
+    }
+
+    fn max_batch_size(&self) -> usize {
+        self.inner.max_batch_size()
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        if self.breaker.is_open() {
+            return Ok(HealthStatus::Unhealthy {
+                error: "Circuit open after repeated failures".to_string(),
+            });
+        }
+        self.inner.health_check().await
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        self.inner.capabilities()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::embeddings::provider::status_error;
+    use crate::embeddings::WhitespaceTokenizer;
+    use reqwest::StatusCode;
+    use std::sync::atomic::{AtomicUsize, Ordering};
+
+    /// Provider failing its first `failures` requests with `error`
+    struct Flaky {
+        failures: usize,
+        error: &'static str,
+        calls: AtomicUsize,
+    }
+
+    #[async_trait]
+    impl EmbeddingProvider for Flaky {
+        async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+            if self.calls.fetch_add(1, Ordering::SeqCst) < self.failures {
+                return Err(anyhow!(self.error));
+            }
+            Ok(texts.iter().map(|_| vec![1.0]).collect())
+        }
+
+        async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+            Ok(self.embed(&[query.to_string()]).await?.remove(0))
+        }
+
+        fn embedding_dimension(&self) -> usize {
+            1
+        }
+
+        fn provider_name(&self) -> &'static str {
+            "flaky"
+        }
+
+        fn max_batch_size(&self) -> usize {
+            8
+        }
+
+        async fn health_check(&self) -> Result<HealthStatus> {
+            Ok(HealthStatus::Healthy)
+        }
+
+        fn capabilities(&self) -> ProviderCapabilities {
+            ProviderCapabilities {
+                supports_batching: true,
+                supports_async: true,
+                requires_api_key: false,
+                is_local: true,
+                max_text_length: 512,
+                cost_per_token: None,
+            }
+        }
+    }
+
+    fn wrap(failures: usize, error: &'static str, policy: ResiliencePolicy) -> ResilientProvider {
+        let inner = Arc::new(Flaky {
+            failures,
+            error,
+            calls: AtomicUsize::new(0),
+        });
+        ResilientProvider::new(inner, &policy, Arc::new(WhitespaceTokenizer::new()))
+    }
+
+    #[test]
+    fn test_is_retryable() {
+        assert!(is_retryable(&status_error(
+            "Voyage",
+            StatusCode::TOO_MANY_REQUESTS,
+            "slow down"
+        )));
+        assert!(!is_retryable(&status_error(
+            "TEI",
+            StatusCode::PAYLOAD_TOO_LARGE,
+            "batch too big"
+        )));
+        assert!(is_retryable(&anyhow!(
+            "Cohere returned 503 Service Unavailable: "
+        )));
+        assert!(is_retryable(&anyhow!("operation timed out")));
+        assert!(!is_retryable(&anyhow!(
+            "Voyage returned 401 Unauthorized: invalid key"
+        )));
+    }
+
+    #[test]
+    fn test_backoff_delay_is_jittered_and_capped() {
+        for attempt in 0..10 {
+            let delay = backoff_delay(attempt);
+            let full = INITIAL_BACKOFF * 2u32.pow(attempt);
+            let full = full.min(MAX_BACKOFF);
+            assert!(delay >= full / 2 && delay <= full, "{:?}", delay);
+        }
+    }
+
+    #[test]
+    fn test_circuit_opens_and_recovers() {
+        let breaker = CircuitBreaker::new(2, Duration::ZERO);
+        breaker.record_failure();
+        assert!(!breaker.is_open());
+        breaker.record_failure();
+        assert!(breaker.is_open());
+
+        // After the cooldown one trial request is let through
+        assert!(breaker.allow().is_ok());
+        assert!(breaker.allow().is_err());
+        breaker.record_success();
+        assert!(!breaker.is_open());
+    }
+
+    #[tokio::test]
+    async fn test_transient_failures_are_retried() {
+        let provider = wrap(
+            1,
+            "TEI returned 503 Service Unavailable: loading",
+            ResiliencePolicy::default(),
+        );
+        let embeddings = provider.embed(&["a".to_string()]).await.unwrap();
+        assert_eq!(embeddings, vec![vec![1.0]]);
+    }
+
+    #[tokio::test]
+    async fn test_permanent_failures_open_the_circuit() {
+        let policy = ResiliencePolicy {
+            failure_threshold: 2,
+            cooldown: Duration::from_secs(60),
+            ..Default::default()
+        };
+        let provider = wrap(usize::MAX, "401 Unauthorized", policy);
+
+        for _ in 0..2 {
+            let error = provider.embed_query("a").await.unwrap_err();
+            assert!(error.to_string().contains("401"));
+        }
+        let error = provider.embed_query("a").await.unwrap_err();
+        assert!(error.to_string().contains("circuit open"));
+    }
+}
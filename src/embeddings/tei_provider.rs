@@ -0,0 +1,278 @@
+//! Hugging Face Text Embeddings Inference (TEI) provider
+//!
+//! Embeds through the native `/embed` endpoint of a TEI server, which
+//! serves one model. On startup the server's `/info` gives the model, its
+//! input limit and the largest batch it accepts. As for Ollama, a server on
+//! this machine keeps code local and offline mode allows it; a remote URL
+//! counts as a network backend.
+
+use anyhow::{anyhow, bail, Context, Result};
+use async_trait::async_trait;
+use serde::{Deserialize, Serialize};
+use std::time::{Duration, Instant};
+use tracing::info;
+
+use super::config::TeiConfig;
+use super::ollama_provider::is_loopback;
+use super::provider::{
+    status_error, EmbeddingProvider, HealthStatus, ProviderCapabilities, Rejected,
+};
+use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+
+/// Text embedded once at startup to learn the model's dimension
+const DIMENSION_PROBE: &str = "dimension probe";
+
+#[derive(Serialize)]
+struct EmbedRequest<'a> {
+    inputs: &'a [String],
+    /// Cut inputs longer than the model's limit instead of failing
+    truncate: bool,
+    truncation_direction: &'static str,
+    normalize: bool,
+    /// Prompt from the model's sentence-transformers config prepended to
+    /// the inputs
+    #[serde(skip_serializing_if = "Option::is_none")]
+    prompt_name: Option<&'a str>,
+}
+
+/// Fields of the server's `/info` response that are used
+#[derive(Deserialize)]
+struct ServerInfo {
+    model_id: String,
+    max_input_length: usize,
+    max_client_batch_size: usize,
+}
+
+/// TEI embedding provider implementation
+pub struct TeiProvider {
+    client: reqwest::Client,
+    config: TeiConfig,
+    api_key: Option<String>,
+    info: ServerInfo,
+    dimension: usize,
+}
+
+impl TeiProvider {
+    /// Connect to the server, read its limits and embed a probe text to
+    /// learn the model's dimension.
+    ///
+    /// Fails when the server is unreachable, and in offline mode when the
+    /// URL is not this machine.
+    pub async fn new(config: &TeiConfig) -> Result<Self> {
+        if !is_loopback(&config.url) {
+            crate::offline::ensure_network_allowed("embeddings", "tei")?;
+        }
+
+        let client = reqwest::Client::builder()
+            .timeout(Duration::from_secs(config.timeout_secs))
+            .build()
+            .context("Failed to create HTTP client")?;
+        let api_key = config.load_api_key()?;
+
+        let url = format!("{}/info", config.url.trim_end_matches('/'));
+        let mut request = client.get(&url);
+        if let Some(key) = &api_key {
+            request = request.bearer_auth(key);
+        }
+        let info: ServerInfo = request
+            .send()
+            .await
+            .and_then(|response| response.error_for_status())
+            .with_context(|| {
+                format!(
+                    "Failed to reach the TEI server at {}. Is it running?",
+                    config.url
+                )
+            })?
+            .json()
+            .await
+            .context("Failed to parse TEI server info")?;
+
+        let mut provider = Self {
+            client,
+            config: config.clone(),
+            api_key,
+            info,
+            dimension: 0,
+        };
+        let probe = provider
+            .request(&[DIMENSION_PROBE.to_string()], None)
+            .await
+            .with_context(|| format!("Failed to embed with the TEI server at {}", config.url))?;
+        provider.dimension = probe.first().map_or(0, Vec::len);
+        if provider.dimension == 0 {
+            bail!(
+                "TEI model '{}' returned an empty embedding",
+                provider.info.model_id
+            );
+        }
+
+        info!(
+            "Initialized TEI provider with model: {} ({} dimensions)",
+            provider.info.model_id, provider.dimension
+        );
+        Ok(provider)
+    }
+
+    /// Embed one batch of texts, with `prompt_name` when given
+    async fn request(&self, texts: &[String], prompt_name: Option<&str>) -> Result<Vec<Vec<f32>>> {
+        let url = format!("{}/embed", self.config.url.trim_end_matches('/'));
+        let request = EmbedRequest {
+            inputs: texts,
+            truncate: self.config.truncate,
+            truncation_direction: "Right",
+            normalize: self.config.normalize,
+            prompt_name,
+        };
+
+        let mut builder = self.client.post(&url).json(&request);
+        if let Some(key) = &self.api_key {
+            builder = builder.bearer_auth(key);
+        }
+        let response = builder
+            .send()
+            .await
+            .with_context(|| format!("TEI request to {} failed", url))?;
+        let status = response.status();
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            return Err(status_error("TEI", status, &body));
+        }
+
+        let embeddings: Vec<Vec<f32>> = response
+            .json()
+            .await
+            .context("Failed to parse TEI embed response")?;
+        if embeddings.len() != texts.len() {
+            bail!(
+                "TEI returned {} embeddings for {} texts",
+                embeddings.len(),
+                texts.len()
+            );
+        }
+        Ok(embeddings)
+    }
+}
+
+#[async_trait]
+impl EmbeddingProvider for TeiProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        if texts.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let mut all_embeddings = Vec::with_capacity(texts.len());
+        for batch in texts.chunks(self.max_batch_size()) {
+            all_embeddings.extend(self.request(batch, None).await?);
+        }
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        Ok(all_embeddings)
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let embedding = self
+            .request(
+                &[query.to_string()],
+                self.config.query_prompt_name.as_deref(),
+            )
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No embedding returned"));
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        embedding
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        self.dimension
+    }
+
+    fn provider_name(&self) -> &'static str {
+        "tei"
+    }
+
+    fn max_batch_size(&self) -> usize {
+        self.config
+            .batch_size
+            .clamp(1, self.info.max_client_batch_size.max(1))
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        match self.embed_query(DIMENSION_PROBE).await {
+            Ok(_) => Ok(HealthStatus::Healthy),
+            Err(e) if matches!(Rejected::of(&e), Some(Rejected::RateLimited(_))) => {
+                Ok(HealthStatus::Degraded {
+                    reason: "Server overloaded".to_string(),
+                })
+            }
+            Err(e) => Ok(HealthStatus::Unhealthy {
+                error: e.to_string(),
+            }),
+        }
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        ProviderCapabilities {
+            supports_batching: true,
+            supports_async: true,
+            requires_api_key: false,
+            is_local: is_loopback(&self.config.url),
+            max_text_length: self.info.max_input_length,
+            cost_per_token: None,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_request_body() {
+        let inputs = vec!["where is the config parsed".to_string()];
+        let request = EmbedRequest {
+            inputs: &inputs,
+            truncate: true,
+            truncation_direction: "Right",
+            normalize: true,
+            prompt_name: Some("query"),
+        };
+        assert_eq!(
+            serde_json::to_value(&request).unwrap(),
+            serde_json::json!({
+                "inputs": ["where is the config parsed"],
+                "truncate": true,
+                "truncation_direction": "Right",
+                "normalize": true,
+                "prompt_name": "query",
+            })
+        );
+    }
+
+    #[test]
+    fn test_server_info() {
+        let info: ServerInfo = serde_json::from_value(serde_json::json!({
+            "model_id": "BAAI/bge-base-en-v1.5",
+            "model_dtype": "float16",
+            "model_type": {"embedding": {"pooling": "cls"}},
+            "max_concurrent_requests": 512,
+            "max_input_length": 512,
+            "max_batch_tokens": 16384,
+            "max_client_batch_size": 32,
+            "tokenization_workers": 8,
+            "version": "1.5.0",
+        }))
+        .unwrap();
+        assert_eq!(info.model_id, "BAAI/bge-base-en-v1.5");
+        assert_eq!(info.max_input_length, 512);
+        assert_eq!(info.max_client_batch_size, 32);
+    }
+}
@@ -0,0 +1,447 @@
+//! Tokenizers matched to embedding models
+//!
+//! Chunk size limits are expressed in tokens, and a token budget is only
+//! meaningful when it is counted with the same tokenizer the embedding model
+//! uses. OpenAI models use tiktoken BPE encodings. Local BERT-style models
+//! use WordPiece, whose vocabulary is not available without the model files,
+//! so their tokens are overestimated by a subword splitter; other models fall
+//! back to a whitespace/punctuation splitter.
+
+use anyhow::{anyhow, Result};
+use std::collections::HashMap;
+use std::sync::{Arc, RwLock};
+use tiktoken_rs::CoreBPE;
+
+use crate::config::{EmbeddingProvider, EmbeddingsConfig, TokenizerKind};
+
+/// Token counting and encoding for a model family
+pub trait Tokenizer: Send + Sync {
+    /// Name of the encoding (e.g. `cl100k_base`)
+    fn name(&self) -> &'static str;
+
+    /// Number of tokens in `text`
+    fn count_tokens(&self, text: &str) -> usize {
+        self.encode(text).len()
+    }
+
+    /// Encode text into token ids
+    fn encode(&self, text: &str) -> Vec<u32>;
+
+    /// Decode token ids back into text
+    fn decode(&self, tokens: &[u32]) -> Result<String>;
+}
+
+/// Byte-pair encoding used by OpenAI models
+pub struct BpeTokenizer {
+    name: &'static str,
+    bpe: CoreBPE,
+}
+
+impl BpeTokenizer {
+    /// `cl100k_base`, used by `text-embedding-3-*` and `text-embedding-ada-002`
+    pub fn cl100k() -> Result<Self> {
+        Ok(Self {
+            name: "cl100k_base",
+            bpe: tiktoken_rs::cl100k_base()?,
+        })
+    }
+
+    /// `o200k_base`, used by GPT-4o and newer models
+    pub fn o200k() -> Result<Self> {
+        Ok(Self {
+            name: "o200k_base",
+            bpe: tiktoken_rs::o200k_base()?,
+        })
+    }
+}
+
+impl Tokenizer for BpeTokenizer {
+    fn name(&self) -> &'static str {
+        self.name
+    }
+
+    fn encode(&self, text: &str) -> Vec<u32> {
+        self.bpe.encode_ordinary(text)
+    }
+
+    fn decode(&self, tokens: &[u32]) -> Result<String> {
+        self.bpe.decode(tokens.to_vec())
+    }
+}
+
+/// Longest piece the subword splitter emits, in characters
+///
+/// WordPiece vocabularies of BERT-style models keep most common words whole,
+/// but split identifiers, numbers and rare words into pieces of a few
+/// characters; three-character pieces keep the estimate above the real count.
+const MAX_SUBWORD_CHARS: usize = 3;
+
+/// Fallback tokenizer that splits on whitespace and punctuation.
+///
+/// Each run of letters/digits/underscores is one token and every other
+/// non-whitespace character is a token of its own. Token ids are assigned on
+/// first use, and decoding joins tokens with single spaces, so the original
+/// spacing is not preserved.
+#[derive(Default)]
+pub struct WhitespaceTokenizer {
+    vocab: RwLock<Vocab>,
+}
+
+#[derive(Default)]
+struct Vocab {
+    ids: HashMap<String, u32>,
+    pieces: Vec<String>,
+}
+
+impl Vocab {
+    /// Id of `piece`, assigned on first use
+    fn id(&mut self, piece: &str) -> u32 {
+        if let Some(&id) = self.ids.get(piece) {
+            return id;
+        }
+        let id = self.pieces.len() as u32;
+        self.pieces.push(piece.to_string());
+        self.ids.insert(piece.to_string(), id);
+        id
+    }
+
+    /// Pieces of `tokens` joined with single spaces
+    fn decode(&self, tokens: &[u32]) -> Result<String> {
+        let pieces = tokens
+            .iter()
+            .map(|&id| {
+                self.pieces
+                    .get(id as usize)
+                    .map(String::as_str)
+                    .ok_or_else(|| anyhow!("Unknown token id {}", id))
+            })
+            .collect::<Result<Vec<_>>>()?;
+        Ok(pieces.join(" "))
+    }
+}
+
+impl WhitespaceTokenizer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    fn pieces(text: &str) -> impl Iterator<Item = &str> {
+        let mut rest = text;
+        std::iter::from_fn(move || {
+            rest = rest.trim_start();
+            let first = rest.chars().next()?;
+            let len = if is_word_char(first) {
+                rest.find(|c: char| !is_word_char(c)).unwrap_or(rest.len())
+            } else {
+                first.len_utf8()
+            };
+            let (piece, tail) = rest.split_at(len);
+            rest = tail;
+            Some(piece)
+        })
+    }
+}
+
+fn is_word_char(c: char) -> bool {
+    c.is_alphanumeric() || c == '_'
+}
+
+impl Tokenizer for WhitespaceTokenizer {
+    fn name(&self) -> &'static str {
+        "whitespace"
+    }
+
+    fn count_tokens(&self, text: &str) -> usize {
+        Self::pieces(text).count()
+    }
+
+    fn encode(&self, text: &str) -> Vec<u32> {
+        let mut vocab = self.vocab.write().unwrap_or_else(|e| e.into_inner());
+        Self::pieces(text).map(|piece| vocab.id(piece)).collect()
+    }
+
+    fn decode(&self, tokens: &[u32]) -> Result<String> {
+        let vocab = self.vocab.read().unwrap_or_else(|e| e.into_inner());
+        vocab.decode(tokens)
+    }
+}
+
+/// Conservative stand-in for the WordPiece tokenizers of BERT-style models.
+///
+/// Splits like [`WhitespaceTokenizer`], then breaks every word at
+/// underscores, letter/digit changes, lower-to-upper case changes and
+/// non-ASCII characters, and cuts the resulting runs into pieces of at most
+/// three characters. This counts at least as many tokens as WordPiece does
+/// for code, so chunks sized with it are not truncated by the model.
+#[derive(Default)]
+pub struct SubwordTokenizer {
+    vocab: RwLock<Vocab>,
+}
+
+impl SubwordTokenizer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    fn pieces(text: &str) -> Vec<&str> {
+        let mut pieces = Vec::new();
+        for word in WhitespaceTokenizer::pieces(text) {
+            let mut start = 0;
+            let mut prev = None;
+            for (i, c) in word.char_indices() {
+                if prev.is_some_and(|p| starts_subword(p, c)) {
+                    split_run(&word[start..i], &mut pieces);
+                    start = i;
+                }
+                prev = Some(c);
+            }
+            split_run(&word[start..], &mut pieces);
+        }
+        pieces
+    }
+}
+
+/// Whether WordPiece is likely to start a new piece at `c` after `prev`
+fn starts_subword(prev: char, c: char) -> bool {
+    !prev.is_ascii()
+        || !c.is_ascii()
+        || prev == '_'
+        || c == '_'
+        || prev.is_ascii_digit() != c.is_ascii_digit()
+        || (prev.is_ascii_lowercase() && c.is_ascii_uppercase())
+}
+
+/// Cut a run of one character class into pieces of at most
+/// `MAX_SUBWORD_CHARS` characters; non-ASCII runs are single characters
+fn split_run<'a>(run: &'a str, pieces: &mut Vec<&'a str>) {
+    let mut rest = run;
+    while rest.is_ascii() && rest.len() > MAX_SUBWORD_CHARS {
+        let (head, tail) = rest.split_at(MAX_SUBWORD_CHARS);
+        pieces.push(head);
+        rest = tail;
+    }
+    if !rest.is_empty() {
+        pieces.push(rest);
+    }
+}
+
+impl Tokenizer for SubwordTokenizer {
+    fn name(&self) -> &'static str {
+        "subword"
+    }
+
+    fn count_tokens(&self, text: &str) -> usize {
+        Self::pieces(text).len()
+    }
+
+    fn encode(&self, text: &str) -> Vec<u32> {
+        let mut vocab = self.vocab.write().unwrap_or_else(|e| e.into_inner());
+        Self::pieces(text)
+            .into_iter()
+            .map(|piece| vocab.id(piece))
+            .collect()
+    }
+
+    fn decode(&self, tokens: &[u32]) -> Result<String> {
+        let vocab = self.vocab.read().unwrap_or_else(|e| e.into_inner());
+        vocab.decode(tokens)
+    }
+}
+
+/// Select the tokenizer matching the configured embedding model.
+///
+/// OpenAI models get their BPE encoding and the local FastEmbed and ONNX
+/// models the subword estimate; other models and anything the BPE tables
+/// fail to load for use the whitespace fallback. A `tokenizer` set in the
+/// config takes precedence.
+pub fn tokenizer_for_config(config: &EmbeddingsConfig) -> Arc<dyn Tokenizer> {
+    let tokenizer = match (config.tokenizer, config.provider) {
+        (TokenizerKind::Cl100k, _) => {
+            BpeTokenizer::cl100k().map(|t| Arc::new(t) as Arc<dyn Tokenizer>)
+        }
+        (TokenizerKind::O200k, _) => {
+            BpeTokenizer::o200k().map(|t| Arc::new(t) as Arc<dyn Tokenizer>)
+        }
+        (TokenizerKind::Subword, _)
+        | (TokenizerKind::Auto, EmbeddingProvider::FastEmbed | EmbeddingProvider::Onnx) => {
+            Ok(Arc::new(SubwordTokenizer::new()) as Arc<dyn Tokenizer>)
+        }
+        (TokenizerKind::Whitespace, _)
+        | (
+            TokenizerKind::Auto,
+            EmbeddingProvider::Ollama
+            | EmbeddingProvider::Bedrock
+            | EmbeddingProvider::Gemini
+            | EmbeddingProvider::Voyage
+            | EmbeddingProvider::Cohere
+            | EmbeddingProvider::Tei,
+        ) => Ok(Arc::new(WhitespaceTokenizer::new()) as Arc<dyn Tokenizer>),
+        (TokenizerKind::Auto, EmbeddingProvider::OpenAI) => {
+            tokenizer_for_model(&config.openai_model)
+        }
+        (TokenizerKind::Auto, EmbeddingProvider::Azure) => {
+            tokenizer_for_model(config.azure_deployment.as_deref().unwrap_or_default())
+        }
+    };
+    tokenizer.unwrap_or_else(|e| {
+        tracing::warn!("Failed to load tokenizer, using whitespace fallback: {}", e);
+        Arc::new(WhitespaceTokenizer::new())
+    })
+}
+
+/// Select a tokenizer by OpenAI model name.
+pub fn tokenizer_for_model(model: &str) -> Result<Arc<dyn Tokenizer>> {
+    let model = model.to_lowercase();
+    if model.starts_with("gpt-4o")
+        || model.starts_with("gpt-4.1")
+        || model.starts_with("o1")
+        || model.starts_with("o3")
+        || model.starts_with("o4")
+    {
+        return Ok(Arc::new(BpeTokenizer::o200k()?));
+    }
+    // text-embedding-3-*, text-embedding-ada-002 and OpenAI-compatible
+    // proxies default to cl100k
+    Ok(Arc::new(BpeTokenizer::cl100k()?))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_cl100k_counts_match_reference() {
+        let tokenizer = BpeTokenizer::cl100k().unwrap();
+        // Reference values from tiktoken
+        assert_eq!(tokenizer.count_tokens("hello world"), 2);
+        assert_eq!(tokenizer.count_tokens("tiktoken is great!"), 6);
+        assert_eq!(
+            tokenizer.encode("tiktoken is great!"),
+            vec![83, 1609, 5963, 374, 2294, 0]
+        );
+        assert_eq!(tokenizer.count_tokens(""), 0);
+    }
+
+    #[test]
+    fn test_o200k_counts_match_reference() {
+        let tokenizer = BpeTokenizer::o200k().unwrap();
+        assert_eq!(tokenizer.count_tokens("hello world"), 2);
+        assert_eq!(tokenizer.name(), "o200k_base");
+    }
+
+    #[test]
+    fn test_bpe_roundtrip() {
+        let tokenizer = BpeTokenizer::cl100k().unwrap();
+        let text = "fn main() {\n    println!(\"hi\");\n}";
+        let tokens = tokenizer.encode(text);
+        assert_eq!(tokenizer.decode(&tokens).unwrap(), text);
+    }
+
+    #[test]
+    fn test_whitespace_tokenizer() {
+        let tokenizer = WhitespaceTokenizer::new();
+        assert_eq!(tokenizer.count_tokens("hello world"), 2);
+        assert_eq!(tokenizer.count_tokens("fn add(a: i32) -> i32"), 10);
+        assert_eq!(tokenizer.count_tokens("  \n\t "), 0);
+
+        let tokens = tokenizer.encode("let x = x + 1;");
+        assert_eq!(tokens.len(), 7);
+        // Repeated pieces share an id
+        assert_eq!(tokens[1], tokens[3]);
+        assert_eq!(tokenizer.decode(&tokens).unwrap(), "let x = x + 1 ;");
+        assert!(tokenizer.decode(&[9999]).is_err());
+    }
+
+    #[test]
+    fn test_subword_tokenizer() {
+        let tokenizer = SubwordTokenizer::new();
+        assert_eq!(tokenizer.count_tokens("hello world"), 4);
+        // fn add ( a : i 32 ) - > i 32
+        assert_eq!(tokenizer.count_tokens("fn add(a: i32) -> i32"), 12);
+        // get Use r By Id
+        assert_eq!(tokenizer.count_tokens("getUserById"), 5);
+        assert_eq!(tokenizer.count_tokens("snake_case"), 5);
+        assert_eq!(tokenizer.count_tokens("名前"), 2);
+        assert_eq!(tokenizer.count_tokens("  \n\t "), 0);
+
+        let tokens = tokenizer.encode("getUserById(42)");
+        assert_eq!(tokens.len(), 8);
+        assert_eq!(tokenizer.decode(&tokens).unwrap(), "get Use r By Id ( 42 )");
+    }
+
+    #[test]
+    fn test_subword_counts_at_least_whitespace() {
+        let subword = SubwordTokenizer::new();
+        let whitespace = WhitespaceTokenizer::new();
+        let text = include_str!("tokenizer.rs");
+        assert!(subword.count_tokens(text) >= whitespace.count_tokens(text));
+    }
+
+    /// A chunk sized to the limit with the subword estimate must fit the
+    /// real WordPiece tokenizer of the default FastEmbed model
+    #[test]
+    #[ignore] // Requires model download
+    fn test_subword_chunk_fits_model_tokenizer() {
+        use fastembed::{EmbeddingModel, InitOptions, TextEmbedding};
+
+        let limit = EmbeddingsConfig::default().input_token_limit();
+        let estimate = SubwordTokenizer::new();
+        let mut chunk = String::new();
+        for line in include_str!("tokenizer.rs").lines() {
+            let candidate = format!("{}{}\n", chunk, line);
+            if estimate.count_tokens(&candidate) > limit {
+                break;
+            }
+            chunk = candidate;
+        }
+
+        let model =
+            TextEmbedding::try_new(InitOptions::new(EmbeddingModel::NomicEmbedTextV15)).unwrap();
+        let mut wordpiece = model.tokenizer.clone();
+        wordpiece.with_truncation(None).unwrap();
+        let encoding = wordpiece.encode(chunk.as_str(), true).unwrap();
+        assert!(
+            encoding.get_ids().len() <= limit,
+            "{} WordPiece tokens for a chunk estimated at {}",
+            encoding.get_ids().len(),
+            estimate.count_tokens(&chunk)
+        );
+    }
+
+    #[test]
+    fn test_selection_by_model() {
+        let mut config = EmbeddingsConfig::default();
+        assert_eq!(tokenizer_for_config(&config).name(), "subword");
+
+        config.provider = EmbeddingProvider::Onnx;
+        assert_eq!(tokenizer_for_config(&config).name(), "subword");
+
+        config.provider = EmbeddingProvider::Ollama;
+        assert_eq!(tokenizer_for_config(&config).name(), "whitespace");
+
+        config.provider = EmbeddingProvider::OpenAI;
+        config.openai_model = "text-embedding-3-small".to_string();
+        assert_eq!(tokenizer_for_config(&config).name(), "cl100k_base");
+
+        config.openai_model = "gpt-4o-mini".to_string();
+        assert_eq!(tokenizer_for_config(&config).name(), "o200k_base");
+    }
+
+    #[test]
+    fn test_configured_tokenizer_overrides_model() {
+        let mut config = EmbeddingsConfig {
+            tokenizer: TokenizerKind::Cl100k,
+            ..Default::default()
+        };
+        assert_eq!(tokenizer_for_config(&config).name(), "cl100k_base");
+
+        config.provider = EmbeddingProvider::OpenAI;
+        config.tokenizer = TokenizerKind::Whitespace;
+        assert_eq!(tokenizer_for_config(&config).name(), "whitespace");
+
+        assert_eq!(config.input_token_limit(), 8191);
+        config.max_input_tokens = Some(2048);
+        assert_eq!(config.input_token_limit(), 2048);
+        assert_eq!(EmbeddingsConfig::default().input_token_limit(), 512);
+    }
+}
@@ -0,0 +1,280 @@
+//! Voyage AI embedding provider
+//!
+//! Embeds with Voyage models such as the code-tuned `voyage-code-3` through
+//! the `/v1/embeddings` API. Chunks are sent with `input_type = "document"`
+//! and queries with `"query"`, which Voyage prefixes with retrieval
+//! instructions.
+
+use anyhow::{anyhow, bail, Context, Result};
+use async_trait::async_trait;
+use serde::{Deserialize, Serialize};
+use std::time::{Duration, Instant};
+use tracing::{info, warn};
+
+use super::config::VoyageConfig;
+use super::provider::{
+    status_error, EmbeddingProvider, HealthStatus, ProviderCapabilities, Rejected,
+};
+use crate::metrics::{EMBEDDING_LATENCY, EMBEDDING_REQUESTS};
+
+const EMBEDDINGS_URL: &str = "https://api.voyageai.com/v1/embeddings";
+
+/// Most texts Voyage embeds in one request
+const MAX_BATCH_SIZE: usize = 1000;
+
+const INITIAL_BACKOFF: Duration = Duration::from_millis(1000);
+const MAX_BACKOFF: Duration = Duration::from_secs(60);
+
+#[derive(Serialize)]
+struct EmbedRequest<'a> {
+    input: &'a [String],
+    model: &'a str,
+    input_type: &'static str,
+    /// Cut inputs longer than the model's context instead of failing
+    truncation: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    output_dimension: Option<u32>,
+}
+
+#[derive(Deserialize)]
+struct EmbedResponse {
+    data: Vec<EmbeddingData>,
+}
+
+#[derive(Deserialize)]
+struct EmbeddingData {
+    index: usize,
+    embedding: Vec<f32>,
+}
+
+/// Voyage AI embedding provider implementation
+pub struct VoyageProvider {
+    client: reqwest::Client,
+    config: VoyageConfig,
+    api_key: String,
+}
+
+impl VoyageProvider {
+    /// Create a new Voyage provider.
+    ///
+    /// Fails in offline mode, without an API key, and for models whose
+    /// dimension is unknown.
+    pub async fn new(config: &VoyageConfig) -> Result<Self> {
+        crate::offline::ensure_network_allowed("embeddings", "voyage")?;
+
+        if Self::dimension_of(config).is_none() {
+            bail!(
+                "Unknown Voyage model '{}': set embeddings.voyage_dimensions",
+                config.model
+            );
+        }
+        let api_key = config
+            .load_api_key()
+            .context("Failed to load Voyage API key")?;
+        let client = reqwest::Client::builder()
+            .timeout(Duration::from_secs(config.timeout_secs))
+            .build()
+            .context("Failed to create HTTP client")?;
+
+        info!("Initialized Voyage provider with model: {}", config.model);
+        Ok(Self {
+            client,
+            config: config.clone(),
+            api_key,
+        })
+    }
+
+    /// Default embedding dimension of a known model, or None for unknown
+    /// names
+    pub(crate) fn known_model_dimension(model: &str) -> Option<usize> {
+        match model {
+            "voyage-code-3" | "voyage-3.5" | "voyage-3-large" | "voyage-3" => Some(1024),
+            "voyage-code-2" => Some(1536),
+            "voyage-3.5-lite" | "voyage-3-lite" => Some(512),
+            _ => None,
+        }
+    }
+
+    /// Dimension of the configured model and output dimension
+    fn dimension_of(config: &VoyageConfig) -> Option<usize> {
+        config
+            .dimensions
+            .map(|d| d as usize)
+            .or_else(|| Self::known_model_dimension(&config.model))
+    }
+
+    /// Embed one batch as `input_type`, retrying failed requests with
+    /// exponential backoff
+    async fn request(&self, texts: &[String], input_type: &'static str) -> Result<Vec<Vec<f32>>> {
+        let mut attempt = 0;
+        let mut backoff = INITIAL_BACKOFF;
+        loop {
+            match self.send(texts, input_type).await {
+                Ok(embeddings) => return Ok(embeddings),
+                Err(e) if attempt >= self.config.max_retries => {
+                    return Err(e).context("Max retries exceeded");
+                }
+                Err(e) => {
+                    warn!("Voyage request failed (attempt {}): {:#}", attempt + 1, e);
+                    tokio::time::sleep(backoff).await;
+                    backoff = (backoff * 2).min(MAX_BACKOFF);
+                    attempt += 1;
+                }
+            }
+        }
+    }
+
+    /// Send one embeddings request
+    async fn send(&self, texts: &[String], input_type: &'static str) -> Result<Vec<Vec<f32>>> {
+        let request = EmbedRequest {
+            input: texts,
+            model: &self.config.model,
+            input_type,
+            truncation: true,
+            output_dimension: self.config.dimensions,
+        };
+        let response = self
+            .client
+            .post(EMBEDDINGS_URL)
+            .bearer_auth(&self.api_key)
+            .json(&request)
+            .send()
+            .await
+            .context("Voyage request failed")?;
+        let status = response.status();
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            return Err(status_error("Voyage", status, &body));
+        }
+
+        let mut data = response
+            .json::<EmbedResponse>()
+            .await
+            .context("Failed to parse Voyage embeddings response")?
+            .data;
+        if data.len() != texts.len() {
+            bail!(
+                "Voyage returned {} embeddings for {} texts",
+                data.len(),
+                texts.len()
+            );
+        }
+        data.sort_by_key(|d| d.index);
+        Ok(data.into_iter().map(|d| d.embedding).collect())
+    }
+}
+
+#[async_trait]
+impl EmbeddingProvider for VoyageProvider {
+    async fn embed(&self, texts: &[String]) -> Result<Vec<Vec<f32>>> {
+        if texts.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let mut all_embeddings = Vec::with_capacity(texts.len());
+        for batch in texts.chunks(self.max_batch_size()) {
+            all_embeddings.extend(self.request(batch, "document").await?);
+        }
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        Ok(all_embeddings)
+    }
+
+    async fn embed_query(&self, query: &str) -> Result<Vec<f32>> {
+        EMBEDDING_REQUESTS.inc();
+        let start = Instant::now();
+
+        let embedding = self
+            .request(&[query.to_string()], "query")
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No embedding returned"));
+
+        EMBEDDING_LATENCY.observe(start.elapsed().as_secs_f64());
+        embedding
+    }
+
+    fn embedding_dimension(&self) -> usize {
+        Self::dimension_of(&self.config).unwrap_or_default()
+    }
+
+    fn provider_name(&self) -> &'static str {
+        "voyage"
+    }
+
+    fn max_batch_size(&self) -> usize {
+        self.config.batch_size.clamp(1, MAX_BATCH_SIZE)
+    }
+
+    async fn health_check(&self) -> Result<HealthStatus> {
+        match self.embed_query("test").await {
+            Ok(_) => Ok(HealthStatus::Healthy),
+            Err(e) if matches!(Rejected::of(&e), Some(Rejected::RateLimited(_))) => {
+                Ok(HealthStatus::Degraded {
+                    reason: "Rate limited".to_string(),
+                })
+            }
+            Err(e) => Ok(HealthStatus::Unhealthy {
+                error: e.to_string(),
+            }),
+        }
+    }
+
+    fn capabilities(&self) -> ProviderCapabilities {
+        ProviderCapabilities {
+            supports_batching: true,
+            supports_async: true,
+            requires_api_key: true,
+            is_local: false,
+            max_text_length: 32000,
+            cost_per_token: match self.config.model.as_str() {
+                "voyage-code-3" | "voyage-code-2" => Some(0.00018), // $0.18 per 1M tokens
+                _ => None,
+            },
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_model_dimension() {
+        let mut config = VoyageConfig::default();
+        assert_eq!(config.model, "voyage-code-3");
+        assert_eq!(VoyageProvider::dimension_of(&config), Some(1024));
+
+        config.dimensions = Some(256);
+        assert_eq!(VoyageProvider::dimension_of(&config), Some(256));
+
+        config.model = "voyage-code-9".to_string();
+        config.dimensions = None;
+        assert_eq!(VoyageProvider::dimension_of(&config), None);
+    }
+
+    #[test]
+    fn test_request_body() {
+        let input = vec!["fn main() {}".to_string()];
+        let request = EmbedRequest {
+            input: &input,
+            model: "voyage-code-3",
+            input_type: "document",
+            truncation: true,
+            output_dimension: None,
+        };
+        assert_eq!(
+            serde_json::to_value(&request).unwrap(),
+            serde_json::json!({
+                "input": ["fn main() {}"],
+                "model": "voyage-code-3",
+                "input_type": "document",
+                "truncation": true,
+            })
+        );
+    }
+}
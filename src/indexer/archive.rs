@@ -0,0 +1,348 @@
+//! Source archives
+//!
+//! `coderag index <archive>` indexes a `.tar`, `.tar.gz`/`.tgz` or `.zip`
+//! bundle without extracting it by hand. The archive is unpacked into a
+//! temporary directory, walked with the normal ignore rules, and removed
+//! again once the files are read.
+//!
+//! Files from an archive are indexed under member paths of the form
+//! `<archive path>!/<path inside archive>`, so every chunk and symbol is
+//! tagged with the archive it came from.
+
+use anyhow::{bail, Context, Result};
+use flate2::read::GzDecoder;
+use std::fs::{self, File};
+use std::io::{self, Read};
+use std::path::{Path, PathBuf};
+use tracing::{debug, warn};
+
+use super::Walker;
+use crate::config::IndexerConfig;
+
+/// Separator between the archive path and the path inside it
+pub const MEMBER_SEPARATOR: &str = "!/";
+
+/// Largest zip entry extracted; bigger ones are not source files
+const MAX_ENTRY_SIZE: u64 = 16 * 1024 * 1024;
+
+/// Most bytes extracted from one zip archive, so that a zip bomb cannot
+/// fill the disk
+const MAX_EXTRACTED_SIZE: u64 = 1024 * 1024 * 1024;
+
+/// Supported archive formats
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ArchiveFormat {
+    Tar,
+    TarGz,
+    Zip,
+}
+
+impl ArchiveFormat {
+    /// Detect the format from the file name.
+    pub fn detect(path: &Path) -> Option<Self> {
+        let name = path.file_name()?.to_string_lossy().to_lowercase();
+        if name.ends_with(".tar.gz") || name.ends_with(".tgz") {
+            Some(Self::TarGz)
+        } else if name.ends_with(".tar") {
+            Some(Self::Tar)
+        } else if name.ends_with(".zip") {
+            Some(Self::Zip)
+        } else {
+            None
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Tar => "tar",
+            Self::TarGz => "tar.gz",
+            Self::Zip => "zip",
+        }
+    }
+}
+
+/// A source file read from an archive
+#[derive(Debug, Clone)]
+pub struct ArchiveFile {
+    /// Member path (`<archive>!/<path inside archive>`)
+    pub path: PathBuf,
+    pub content: String,
+}
+
+/// Path under which a file inside `archive` is indexed.
+pub fn member_path(archive: &Path, member: &Path) -> PathBuf {
+    let member = member.to_string_lossy().replace('\\', "/");
+    PathBuf::from(format!(
+        "{}{}{}",
+        archive.display(),
+        MEMBER_SEPARATOR,
+        member
+    ))
+}
+
+/// The archive a member path belongs to, if it is one.
+pub fn archive_of(path: &Path) -> Option<PathBuf> {
+    let path = path.to_string_lossy();
+    let (archive, _) = path.split_once(MEMBER_SEPARATOR)?;
+    Some(PathBuf::from(archive))
+}
+
+/// Unpack an archive into `dest`.
+///
+/// Entries that would land outside `dest` (absolute paths or `..`
+/// components) are skipped, and so are zip entries over
+/// [`MAX_ENTRY_SIZE`] or past [`MAX_EXTRACTED_SIZE`] in total.
+pub fn extract(archive: &Path, format: ArchiveFormat, dest: &Path) -> Result<()> {
+    let file =
+        File::open(archive).with_context(|| format!("Failed to open archive {:?}", archive))?;
+
+    match format {
+        ArchiveFormat::Tar => tar::Archive::new(file).unpack(dest),
+        ArchiveFormat::TarGz => tar::Archive::new(GzDecoder::new(file)).unpack(dest),
+        ArchiveFormat::Zip => return extract_zip(file, dest, MAX_ENTRY_SIZE, MAX_EXTRACTED_SIZE),
+    }
+    .with_context(|| format!("Failed to extract {:?}", archive))
+}
+
+/// Unpack a zip archive into `dest`, skipping entries over `max_entry`
+/// bytes and those that would take the total past `max_total`.
+///
+/// Sizes are counted while decompressing, since the sizes an entry
+/// declares may lie.
+fn extract_zip(file: File, dest: &Path, max_entry: u64, max_total: u64) -> Result<()> {
+    let mut zip = zip::ZipArchive::new(file).context("Failed to read zip archive")?;
+
+    let mut remaining = max_total;
+    for i in 0..zip.len() {
+        let mut entry = zip.by_index(i)?;
+        let Some(relative) = entry.enclosed_name() else {
+            debug!("Skipping unsafe zip entry {}", entry.name());
+            continue;
+        };
+        let target = dest.join(relative);
+
+        if entry.is_dir() {
+            fs::create_dir_all(&target)?;
+            continue;
+        }
+        let limit = max_entry.min(remaining);
+        if entry.size() > limit {
+            warn!(
+                "Skipping zip entry {} of {} bytes: over the extraction limit",
+                entry.name(),
+                entry.size()
+            );
+            continue;
+        }
+        if let Some(parent) = target.parent() {
+            fs::create_dir_all(parent)?;
+        }
+
+        // One byte more than the limit tells an entry that is over it
+        let written = io::copy(
+            &mut entry.by_ref().take(limit + 1),
+            &mut File::create(&target)?,
+        )?;
+        if written > limit {
+            warn!(
+                "Skipping zip entry {}: it decompresses past the extraction limit",
+                entry.name()
+            );
+            fs::remove_file(&target)?;
+            continue;
+        }
+        remaining -= written;
+    }
+
+    Ok(())
+}
+
+/// Read the indexable source files of an archive.
+///
+/// The archive is unpacked into a temporary directory that is removed before
+/// returning. Files are selected with the same extension and ignore rules as
+/// a normal walk; files that are not valid UTF-8 are skipped.
+pub fn read_archive(archive: &Path, config: &IndexerConfig) -> Result<Vec<ArchiveFile>> {
+    let Some(format) = ArchiveFormat::detect(archive) else {
+        bail!(
+            "Unsupported archive {:?}: expected .tar, .tar.gz, .tgz or .zip",
+            archive
+        );
+    };
+
+    let temp = tempfile::Builder::new()
+        .prefix("coderag-archive-")
+        .tempdir()
+        .context("Failed to create extraction directory")?;
+    extract(archive, format, temp.path())?;
+
+    let files = read_tree(temp.path(), config, |relative| {
+        member_path(archive, relative)
+    });
+
+    debug!(
+        "Read {} files from {} archive {:?}",
+        files.len(),
+        format.as_str(),
+        archive
+    );
+    Ok(files)
+}
+
+/// Read the indexable files of an unpacked tree, naming each with
+/// `to_path(path relative to dir)`.
+pub(crate) fn read_tree(
+    dir: &Path,
+    config: &IndexerConfig,
+    to_path: impl Fn(&Path) -> PathBuf,
+) -> Vec<ArchiveFile> {
+    let mut files = Vec::new();
+    for path in Walker::new(dir.to_path_buf(), config).collect_files() {
+        let Ok(content) = fs::read_to_string(&path) else {
+            debug!("Skipping non-UTF-8 file {:?}", path);
+            continue;
+        };
+        let relative = path.strip_prefix(dir).unwrap_or(&path);
+        files.push(ArchiveFile {
+            path: to_path(relative),
+            content,
+        });
+    }
+    files.sort_by(|a, b| a.path.cmp(&b.path));
+    files
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::io::Write;
+    use tempfile::tempdir;
+
+    const GO_FIXTURE: &str = include_str!("../../tests/fixtures/languages/go/sample_go.go");
+
+    fn config() -> IndexerConfig {
+        IndexerConfig {
+            extensions: vec!["go".to_string()],
+            ..Default::default()
+        }
+    }
+
+    /// Zip the Go fixture along with a file that should be ignored
+    fn fixture_zip(dir: &Path) -> PathBuf {
+        let path = dir.join("bundle.zip");
+        let mut zip = zip::ZipWriter::new(File::create(&path).unwrap());
+        let options = zip::write::SimpleFileOptions::default();
+        zip.start_file("pool/sample_go.go", options).unwrap();
+        zip.write_all(GO_FIXTURE.as_bytes()).unwrap();
+        zip.start_file("README.md", options).unwrap();
+        zip.write_all(b"# Bundle").unwrap();
+        zip.start_file("vendor/dep/dep.go", options).unwrap();
+        zip.write_all(b"package dep").unwrap();
+        zip.finish().unwrap();
+        path
+    }
+
+    #[test]
+    fn test_detect_format() {
+        assert_eq!(
+            ArchiveFormat::detect(Path::new("a.tar.gz")),
+            Some(ArchiveFormat::TarGz)
+        );
+        assert_eq!(
+            ArchiveFormat::detect(Path::new("a.TGZ")),
+            Some(ArchiveFormat::TarGz)
+        );
+        assert_eq!(
+            ArchiveFormat::detect(Path::new("a.tar")),
+            Some(ArchiveFormat::Tar)
+        );
+        assert_eq!(
+            ArchiveFormat::detect(Path::new("a.zip")),
+            Some(ArchiveFormat::Zip)
+        );
+        assert_eq!(ArchiveFormat::detect(Path::new("a.rs")), None);
+    }
+
+    #[test]
+    fn test_member_paths() {
+        let path = member_path(Path::new("/tmp/bundle.zip"), Path::new("src/lib.rs"));
+        assert_eq!(path, PathBuf::from("/tmp/bundle.zip!/src/lib.rs"));
+        assert_eq!(archive_of(&path), Some(PathBuf::from("/tmp/bundle.zip")));
+        assert_eq!(archive_of(Path::new("/tmp/src/lib.rs")), None);
+    }
+
+    #[test]
+    fn test_read_zip_of_fixture() {
+        let dir = tempdir().unwrap();
+        let archive = fixture_zip(dir.path());
+
+        let files = read_archive(&archive, &config()).unwrap();
+
+        // Extension filter and the default `vendor` ignore pattern apply
+        assert_eq!(files.len(), 1);
+        assert_eq!(
+            files[0].path,
+            member_path(&archive, Path::new("pool/sample_go.go"))
+        );
+        assert_eq!(files[0].content, GO_FIXTURE);
+
+        // Symbols are tagged with the archive through their file path
+        let mut chunker = crate::indexer::AstChunker::with_limits(0, 1500);
+        let chunks = chunker.chunk_file(&files[0].path, &files[0].content);
+        assert!(chunks.iter().any(|c| c.name.as_deref() == Some("Submit")));
+        assert!(chunks
+            .iter()
+            .all(|c| c.file_path.to_string_lossy().contains("bundle.zip!/")));
+    }
+
+    #[test]
+    fn test_zip_entries_over_the_limits_are_skipped() {
+        let dir = tempdir().unwrap();
+        let path = dir.path().join("bomb.zip");
+        let mut zip = zip::ZipWriter::new(File::create(&path).unwrap());
+        let options = zip::write::SimpleFileOptions::default();
+        for (name, size) in [("a.go", 40), ("big.go", 200), ("b.go", 40), ("c.go", 40)] {
+            zip.start_file(name, options).unwrap();
+            zip.write_all(&vec![b'x'; size]).unwrap();
+        }
+        zip.finish().unwrap();
+
+        let dest = dir.path().join("out");
+        extract_zip(File::open(&path).unwrap(), &dest, 100, 100).unwrap();
+
+        // `big.go` is over the entry limit and `c.go` past the total
+        assert!(dest.join("a.go").exists());
+        assert!(!dest.join("big.go").exists());
+        assert!(dest.join("b.go").exists());
+        assert!(!dest.join("c.go").exists());
+    }
+
+    #[test]
+    fn test_read_tar_gz() {
+        let dir = tempdir().unwrap();
+        let archive = dir.path().join("bundle.tar.gz");
+        let encoder = flate2::write::GzEncoder::new(
+            File::create(&archive).unwrap(),
+            flate2::Compression::default(),
+        );
+        let mut builder = tar::Builder::new(encoder);
+        let mut header = tar::Header::new_gnu();
+        header.set_size(GO_FIXTURE.len() as u64);
+        header.set_mode(0o644);
+        header.set_cksum();
+        builder
+            .append_data(&mut header, "sample_go.go", GO_FIXTURE.as_bytes())
+            .unwrap();
+        builder.into_inner().unwrap().finish().unwrap();
+
+        let files = read_archive(&archive, &config()).unwrap();
+        assert_eq!(files.len(), 1);
+        assert!(files[0].path.ends_with("bundle.tar.gz!/sample_go.go"));
+    }
+
+    #[test]
+    fn test_unsupported_archive() {
+        assert!(read_archive(Path::new("bundle.rar"), &config()).is_err());
+    }
+}
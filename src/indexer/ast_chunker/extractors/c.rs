@@ -1,7 +1,12 @@
 //! C-specific semantic extractor.
 //!
 //! Extracts: function_definition, struct_specifier, union_specifier, enum_specifier,
-//! type_definition
+//! type_definition, and function prototypes (`declaration`s of a function,
+//! as in headers)
+//!
+//! Definitions inside `#if`/`#ifdef` blocks and include guards are
+//! extracted like any other. Struct, union and enum specifiers without a
+//! body only name a type (`struct point *p`) and are skipped.
 
 use tree_sitter::{Node, Tree, TreeCursor};
 
@@ -30,7 +35,7 @@ impl SemanticExtractor for CExtractor {
     fn target_node_types(&self) -> &[&'static str] {
         &[
             "function_definition",
-            "declaration",        // For static inline functions
+            "declaration",        // Function prototypes
             "struct_specifier",
             "union_specifier",
             "enum_specifier",
@@ -74,6 +79,12 @@ impl CExtractor {
     ) -> Option<SemanticUnit> {
         let kind = match node.kind() {
             "function_definition" => SemanticKind::Function,
+            "declaration" if prototype_declarator(node).is_some() => SemanticKind::Function,
+            "struct_specifier" | "union_specifier" | "enum_specifier"
+                if node.child_by_field_name("body").is_none() =>
+            {
+                return None
+            }
             "struct_specifier" => SemanticKind::Struct,
             "union_specifier" => SemanticKind::Struct, // Treat unions similarly to structs
             "enum_specifier" => SemanticKind::Enum,
@@ -122,6 +133,8 @@ impl CExtractor {
                 }
                 None
             }
+            "declaration" => prototype_declarator(node)
+                .and_then(|d| self.find_identifier_in_declarator(&d, source)),
             "struct_specifier" | "union_specifier" | "enum_specifier" => {
                 // These have a name field
                 node.child_by_field_name("name")
@@ -166,6 +179,11 @@ impl CExtractor {
 
     /// Get function signature.
     fn get_signature(&self, node: &Node, source: &[u8]) -> Option<String> {
+        if node.kind() == "declaration" {
+            // A prototype is its own signature
+            let text = node_text(node, source);
+            return Some(text.trim_end_matches(';').trim().to_string());
+        }
         if node.kind() != "function_definition" {
             return None;
         }
@@ -256,6 +274,20 @@ impl CExtractor {
     }
 }
 
+/// The function declarator of a prototype such as `char *name(void);`.
+///
+/// Declarations of variables, including function pointers
+/// (`int (*cb)(int);`), have none.
+fn prototype_declarator<'t>(node: &Node<'t>) -> Option<Node<'t>> {
+    let mut declarator = node.child_by_field_name("declarator")?;
+    while declarator.kind() == "pointer_declarator" {
+        declarator = declarator.child_by_field_name("declarator")?;
+    }
+    let name = declarator.child_by_field_name("declarator")?;
+    (declarator.kind() == "function_declarator" && name.kind() == "identifier")
+        .then_some(declarator)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -399,4 +431,38 @@ char* get_string(void) {
         assert_eq!(units[0].kind, SemanticKind::Function);
         assert_eq!(units[0].name, Some("get_string".to_string()));
     }
-}
\ No newline at end of file
+    #[test]
+    fn test_extract_header_prototypes() {
+        let source = r#"
+#ifndef POOL_H
+#define POOL_H
+
+struct pool;
+
+/* Create a pool of `size` connections */
+struct pool *pool_create(int size);
+void pool_release(struct pool *pool, int conn);
+extern int (*pool_hook)(int conn);
+
+#ifdef POOL_STATS
+int pool_stats(const struct pool *pool);
+#endif
+
+#endif
+"#;
+        let tree = parse_c(source);
+        let extractor = CExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let names: Vec<_> = units.iter().filter_map(|u| u.name.as_deref()).collect();
+
+        // Forward declarations, type references and function pointers are
+        // not units
+        assert_eq!(names, vec!["pool_create", "pool_release", "pool_stats"]);
+        assert!(units.iter().all(|u| u.kind == SemanticKind::Function));
+        assert_eq!(
+            units[0].signature.as_deref(),
+            Some("struct pool *pool_create(int size)")
+        );
+        assert!(units[0].docs.is_some());
+    }
+}
@@ -1,7 +1,14 @@
 //! C++-specific semantic extractor.
 //!
 //! Extracts: function_definition, class_specifier, struct_specifier,
-//! enum_specifier, template_declaration, namespace_definition
+//! enum_specifier, template_declaration, namespace_definition, and function
+//! and method declarations without a body (as in headers)
+//!
+//! Functions declared or defined in a class body are methods of that class.
+//! Out-of-line definitions such as `double Point::norm() const { ... }` are
+//! methods named `norm` with parent `Point`, so they qualify like the
+//! declaration in the class. Class, struct and enum specifiers without a
+//! body are forward declarations or type references and are skipped.
 
 use tree_sitter::{Node, Tree, TreeCursor};
 
@@ -34,10 +41,16 @@ impl SemanticExtractor for CppExtractor {
             "enum_specifier",
             "template_declaration",
             "namespace_definition",
+            "declaration",        // Function declarations
+            "field_declaration",  // Method declarations
             "type_definition",
             "alias_declaration",  // C++ using alias
         ]
     }
+
+    fn qualified_name_separator(&self) -> &'static str {
+        "::"
+    }
 }
 
 impl CppExtractor {
@@ -84,15 +97,23 @@ impl CppExtractor {
         source: &[u8],
         parent_context: Option<&str>,
     ) -> Option<SemanticUnit> {
-        let kind = match node.kind() {
-            "function_definition" => {
+        let mut kind = match node.kind() {
+            "declaration" | "field_declaration" if prototype_declarator(node).is_none() => {
+                return None
+            }
+            "function_definition" | "declaration" | "field_declaration" => {
                 // Check if it's a method (inside a class)
-                if parent_context.is_some() {
+                if is_member(node) {
                     SemanticKind::Method
                 } else {
                     SemanticKind::Function
                 }
             }
+            "class_specifier" | "struct_specifier" | "enum_specifier"
+                if node.child_by_field_name("body").is_none() =>
+            {
+                return None
+            }
             "class_specifier" => SemanticKind::Class,
             "struct_specifier" => SemanticKind::Struct,
             "enum_specifier" | "enum_class" => SemanticKind::Enum,
@@ -114,7 +135,20 @@ impl CppExtractor {
             _ => return None,
         };
 
-        let name = self.get_name(node, source);
+        let mut name = self.get_name(node, source);
+        let mut parent = parent_context.map(|s| s.to_string());
+        // An out-of-line definition names its class: `Point::norm` is the
+        // method `norm` of `Point`
+        let scoped = name.as_deref().and_then(|n| n.rsplit_once("::"));
+        if let (Some((scope, bare)), SemanticKind::Function | SemanticKind::Method) = (scoped, kind)
+        {
+            parent = Some(match &parent {
+                Some(context) => format!("{}::{}", context, scope),
+                None => scope.to_string(),
+            });
+            name = Some(bare.to_string());
+            kind = SemanticKind::Method;
+        }
         let signature = self.get_signature(node, source);
         let docs = self.get_docs(node, source);
         let content = node_text(node, source).to_string();
@@ -129,7 +163,7 @@ impl CppExtractor {
             start_byte: node.start_byte(),
             end_byte: node.end_byte(),
             signature,
-            parent: parent_context.map(|s| s.to_string()),
+            parent,
         })
     }
 
@@ -153,6 +187,8 @@ impl CppExtractor {
                 }
                 None
             }
+            "declaration" | "field_declaration" => prototype_declarator(node)
+                .and_then(|d| self.find_identifier_in_declarator(&d, source)),
             "class_specifier" | "struct_specifier" => {
                 node.child_by_field_name("name")
                     .map(|n| node_text(&n, source).to_string())
@@ -234,6 +270,11 @@ impl CppExtractor {
 
     fn get_signature(&self, node: &Node, source: &[u8]) -> Option<String> {
         match node.kind() {
+            "declaration" | "field_declaration" => {
+                // A declaration is its own signature
+                let text = node_text(node, source);
+                Some(text.trim_end_matches(';').trim().to_string())
+            }
             "function_definition" => {
                 // Extract return type, name, and parameters
                 let mut parts = Vec::new();
@@ -369,6 +410,41 @@ impl CppExtractor {
     }
 }
 
+/// The function declarator of a declaration without a body, such as
+/// `double norm() const;` in a class or `int parse(const char *s);`.
+///
+/// Declarations of variables and fields, including function pointers
+/// (`int (*cb)(int);`), have none.
+fn prototype_declarator<'t>(node: &Node<'t>) -> Option<Node<'t>> {
+    let mut declarator = node.child_by_field_name("declarator")?;
+    while declarator.kind() == "pointer_declarator" || declarator.kind() == "reference_declarator" {
+        // A reference declarator has no `declarator` field
+        declarator = match declarator.child_by_field_name("declarator") {
+            Some(inner) => inner,
+            None => declarator.named_children(&mut declarator.walk()).last()?,
+        };
+    }
+    let name = declarator.child_by_field_name("declarator")?;
+    let is_name = matches!(
+        name.kind(),
+        "identifier" | "field_identifier" | "qualified_identifier" | "destructor_name"
+    );
+    (declarator.kind() == "function_declarator" && is_name).then_some(declarator)
+}
+
+/// Whether a function is declared or defined in a class body, possibly as
+/// a template or within a preprocessor conditional
+fn is_member(node: &Node) -> bool {
+    let mut parent = node.parent();
+    while let Some(p) = parent {
+        if p.kind() != "template_declaration" && !p.kind().starts_with("preproc_") {
+            break;
+        }
+        parent = p.parent();
+    }
+    parent.is_some_and(|p| p.kind() == "field_declaration_list")
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -382,6 +458,30 @@ mod tests {
         parser.parse(source, None).expect("Failed to parse")
     }
 
+    #[test]
+    fn test_qualified_method_name() {
+        let source = r#"
+class Point {
+public:
+    double norm() const {
+        return 0.0;
+    }
+};
+"#;
+        let tree = parse_cpp(source);
+        let extractor = CppExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let method = units
+            .iter()
+            .find(|u| u.name.as_deref() == Some("norm"))
+            .unwrap();
+
+        assert_eq!(
+            extractor.qualified_name(None, method.parent.as_deref(), "norm"),
+            "Point::norm"
+        );
+    }
+
     #[test]
     fn test_extract_class() {
         let source = r#"
@@ -544,4 +644,59 @@ struct Vector3D {
         assert!(units.iter().any(|u| u.kind == SemanticKind::Struct && u.name == Some("Vector3D".to_string())));
         assert!(units.iter().any(|u| u.kind == SemanticKind::Method && u.name == Some("magnitude".to_string())));
     }
-}
\ No newline at end of file
+    #[test]
+    fn test_declarations_and_out_of_line_definitions_qualify_alike() {
+        let header = r#"
+#pragma once
+class Shape;
+
+class Point {
+public:
+    Point(double x, double y);
+    double norm() const;
+#ifdef POINT_DEBUG
+    void dump() const;
+#endif
+private:
+    int (*on_move)(int);
+};
+
+double distance(const Point &a, const Point &b);
+"#;
+        let source = r#"
+double Point::norm() const {
+    return 0.0;
+}
+"#;
+        let extractor = CppExtractor;
+        let qualified = |source: &str| -> Vec<(SemanticKind, String)> {
+            let tree = parse_cpp(source);
+            extractor
+                .extract(&tree, source.as_bytes())
+                .iter()
+                .filter_map(|u| {
+                    let name = u.name.as_deref()?;
+                    Some((
+                        u.kind,
+                        extractor.qualified_name(None, u.parent.as_deref(), name),
+                    ))
+                })
+                .collect()
+        };
+
+        assert_eq!(
+            qualified(header),
+            vec![
+                (SemanticKind::Class, "Point".to_string()),
+                (SemanticKind::Method, "Point::Point".to_string()),
+                (SemanticKind::Method, "Point::norm".to_string()),
+                (SemanticKind::Method, "Point::dump".to_string()),
+                (SemanticKind::Function, "distance".to_string()),
+            ]
+        );
+        assert_eq!(
+            qualified(source),
+            vec![(SemanticKind::Method, "Point::norm".to_string())]
+        );
+    }
+}
@@ -0,0 +1,402 @@
+//! C#-specific semantic extractor.
+//!
+//! Extracts: namespace_declaration, class_declaration, record_declaration,
+//! struct_declaration, interface_declaration, enum_declaration,
+//! method_declaration, constructor_declaration
+//!
+//! XML doc comments (`///`) become the docs of the declaration below them.
+//! Members of nested types have the enclosing types as parent
+//! (`Outer.Inner`), and the file's namespace is the module, so qualified
+//! names read like C#'s (`Contoso.Billing.PaymentService.Refund`).
+
+use std::path::Path;
+
+use tree_sitter::{Node, Tree, TreeCursor};
+
+use super::{declaration_modifiers, node_text, SemanticExtractor, SemanticKind, SemanticUnit};
+
+/// Attributes marking a test method (xUnit, NUnit, MSTest)
+const TEST_ATTRIBUTES: &[&str] = &["Fact", "Theory", "Test", "TestCase", "TestMethod"];
+
+/// C# language semantic extractor.
+pub struct CSharpExtractor;
+
+impl SemanticExtractor for CSharpExtractor {
+    fn language_id(&self) -> &'static str {
+        "csharp"
+    }
+
+    fn extract(&self, tree: &Tree, source: &[u8]) -> Vec<SemanticUnit> {
+        let mut units = Vec::new();
+        let mut cursor = tree.walk();
+
+        self.extract_from_node(&mut cursor, source, &mut units, None);
+
+        // Sort by start position
+        units.sort_by_key(|u| (u.start_line, u.start_byte));
+
+        units
+    }
+
+    fn target_node_types(&self) -> &[&'static str] {
+        &[
+            "namespace_declaration",
+            "file_scoped_namespace_declaration",
+            "class_declaration",
+            "record_declaration",
+            "struct_declaration",
+            "interface_declaration",
+            "enum_declaration",
+            "method_declaration",
+            "constructor_declaration",
+        ]
+    }
+
+    /// The file's first namespace, e.g. `Contoso.Billing`, whether
+    /// file-scoped (`namespace Contoso.Billing;`) or a block.
+    fn module_path(&self, tree: &Tree, source: &[u8], _path: &Path) -> Option<String> {
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let namespace = root.children(&mut cursor).find(|child| {
+            matches!(
+                child.kind(),
+                "namespace_declaration" | "file_scoped_namespace_declaration"
+            )
+        })?;
+        self.get_name(&namespace, source)
+    }
+
+    /// Declarations with the `public` modifier.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        // Skip doc comments and attributes, which come before the modifiers
+        let declaration: Vec<&str> = unit
+            .content
+            .lines()
+            .skip_while(|line| {
+                let line = line.trim_start();
+                line.starts_with("//") || line.starts_with('[')
+            })
+            .collect();
+        declaration_modifiers(&declaration.join("\n")).any(|word| word == "public")
+    }
+}
+
+impl CSharpExtractor {
+    /// Recursively extract semantic units from the AST.
+    fn extract_from_node(
+        &self,
+        cursor: &mut TreeCursor,
+        source: &[u8],
+        units: &mut Vec<SemanticUnit>,
+        parent_context: Option<&str>,
+    ) {
+        let node = cursor.node();
+
+        // Try to extract a semantic unit from this node
+        if let Some(unit) = self.extract_unit(&node, source, parent_context) {
+            units.push(unit);
+        }
+
+        // For type definitions, pass the name, qualified by any enclosing
+        // types, as context. Namespaces are the module, not a parent.
+        let new_context = match node.kind() {
+            "class_declaration"
+            | "record_declaration"
+            | "struct_declaration"
+            | "interface_declaration"
+            | "enum_declaration" => {
+                let name = self.get_name(&node, source);
+                match (parent_context, name) {
+                    (Some(outer), Some(name)) => Some(format!("{}.{}", outer, name)),
+                    (_, name) => name,
+                }
+            }
+            _ => parent_context.map(|s| s.to_string()),
+        };
+
+        // Recurse into children
+        if cursor.goto_first_child() {
+            loop {
+                self.extract_from_node(cursor, source, units, new_context.as_deref());
+                if !cursor.goto_next_sibling() {
+                    break;
+                }
+            }
+            cursor.goto_parent();
+        }
+    }
+
+    /// Extract a semantic unit from a node if it's a target type.
+    fn extract_unit(
+        &self,
+        node: &Node,
+        source: &[u8],
+        parent_context: Option<&str>,
+    ) -> Option<SemanticUnit> {
+        let kind = match node.kind() {
+            "namespace_declaration" | "file_scoped_namespace_declaration" => SemanticKind::Module,
+            "class_declaration" | "record_declaration" => SemanticKind::Class,
+            "struct_declaration" => SemanticKind::Struct,
+            "interface_declaration" => SemanticKind::Interface,
+            "enum_declaration" => SemanticKind::Enum,
+            "method_declaration" if self.is_test_method(node, source) => SemanticKind::Test,
+            "method_declaration" | "constructor_declaration" => SemanticKind::Method,
+            _ => return None,
+        };
+
+        let name = self.get_name(node, source);
+        let signature = self.get_signature(node, source);
+        let docs = self.get_xml_docs(node, source);
+        let content = node_text(node, source).to_string();
+
+        Some(SemanticUnit {
+            kind,
+            name,
+            content,
+            docs,
+            start_line: node.start_position().row + 1,
+            end_line: node.end_position().row + 1,
+            start_byte: node.start_byte(),
+            end_byte: node.end_byte(),
+            signature,
+            parent: parent_context.map(|s| s.to_string()),
+        })
+    }
+
+    /// Get the name/identifier from a node.
+    fn get_name(&self, node: &Node, source: &[u8]) -> Option<String> {
+        node.child_by_field_name("name")
+            .map(|n| node_text(&n, source).to_string())
+    }
+
+    /// Get method/constructor signature: the declaration up to its body,
+    /// without attributes.
+    fn get_signature(&self, node: &Node, source: &[u8]) -> Option<String> {
+        if !matches!(
+            node.kind(),
+            "method_declaration" | "constructor_declaration"
+        ) {
+            return None;
+        }
+
+        let mut cursor = node.walk();
+        let children: Vec<Node> = node.children(&mut cursor).collect();
+        let start = children
+            .iter()
+            .find(|child| child.kind() != "attribute_list")?
+            .start_byte();
+        let end = children
+            .iter()
+            .find(|child| matches!(child.kind(), "block" | "arrow_expression_clause" | ";"))
+            .map_or(node.end_byte(), |body| body.start_byte());
+        let declaration = std::str::from_utf8(&source[start..end]).ok()?;
+        Some(declaration.split_whitespace().collect::<Vec<_>>().join(" "))
+    }
+
+    /// Get the XML doc comment (consecutive `///` lines) above a node,
+    /// without the comment markers.
+    fn get_xml_docs(&self, node: &Node, source: &[u8]) -> Option<String> {
+        let mut lines = Vec::new();
+        let mut current = node.prev_sibling();
+        while let Some(prev) = current {
+            let text = node_text(&prev, source);
+            if prev.kind() != "comment" || !text.starts_with("///") {
+                break;
+            }
+            lines.insert(0, text.trim_start_matches('/').trim().to_string());
+            current = prev.prev_sibling();
+        }
+
+        if lines.is_empty() {
+            None
+        } else {
+            Some(lines.join("\n"))
+        }
+    }
+
+    /// Check if a method has a test attribute such as `[Fact]` or
+    /// `[TestCase(1)]`.
+    fn is_test_method(&self, node: &Node, source: &[u8]) -> bool {
+        let mut cursor = node.walk();
+        for list in node.children(&mut cursor) {
+            if list.kind() != "attribute_list" {
+                continue;
+            }
+            let mut list_cursor = list.walk();
+            for attribute in list.named_children(&mut list_cursor) {
+                let Some(name) = attribute.child_by_field_name("name") else {
+                    continue;
+                };
+                // `Xunit.FactAttribute` is `[Fact]`
+                let name = node_text(&name, source);
+                let name = name.rsplit('.').next().unwrap_or(name);
+                if TEST_ATTRIBUTES.contains(&name.trim_end_matches("Attribute")) {
+                    return true;
+                }
+            }
+        }
+        false
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tree_sitter::Parser;
+
+    fn parse_csharp(source: &str) -> Tree {
+        let mut parser = Parser::new();
+        parser
+            .set_language(&tree_sitter_c_sharp::LANGUAGE.into())
+            .expect("Failed to set C# language");
+        parser.parse(source, None).expect("Failed to parse")
+    }
+
+    #[test]
+    fn test_qualified_method_name() {
+        let source = r#"
+namespace Contoso.Billing;
+
+public class PaymentService
+{
+    public class Receipt
+    {
+        public bool IsEmpty() => true;
+    }
+
+    public PaymentService(ILogger logger) { }
+
+    public void Refund(string orderId) { }
+}
+"#;
+        let tree = parse_csharp(source);
+        let extractor = CSharpExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let module = extractor.module_path(&tree, source.as_bytes(), Path::new("Payment.cs"));
+        assert_eq!(module.as_deref(), Some("Contoso.Billing"));
+
+        let qualified: Vec<String> = units
+            .iter()
+            .filter(|u| u.kind != SemanticKind::Module)
+            .map(|u| {
+                let name = u.name.as_deref().unwrap();
+                extractor.qualified_name(module.as_deref(), u.parent.as_deref(), name)
+            })
+            .collect();
+        assert_eq!(
+            qualified,
+            vec![
+                "Contoso.Billing.PaymentService",
+                "Contoso.Billing.PaymentService.Receipt",
+                "Contoso.Billing.PaymentService.Receipt.IsEmpty",
+                "Contoso.Billing.PaymentService.PaymentService",
+                "Contoso.Billing.PaymentService.Refund",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_extract_types_in_namespace_block() {
+        let source = r#"
+namespace Contoso.Orders
+{
+    public interface IOrderStore { }
+
+    public struct OrderId { }
+
+    public enum OrderState { Open, Closed }
+
+    public record Order(OrderId Id, OrderState State);
+}
+"#;
+        let tree = parse_csharp(source);
+        let extractor = CSharpExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let kinds: Vec<_> = units
+            .iter()
+            .map(|u| (u.kind, u.name.as_deref().unwrap()))
+            .collect();
+
+        assert_eq!(
+            kinds,
+            vec![
+                (SemanticKind::Module, "Contoso.Orders"),
+                (SemanticKind::Interface, "IOrderStore"),
+                (SemanticKind::Struct, "OrderId"),
+                (SemanticKind::Enum, "OrderState"),
+                (SemanticKind::Class, "Order"),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_xml_docs_and_signature() {
+        let source = r#"
+class PaymentService
+{
+    // Not a doc comment
+
+    /// <summary>Refunds an order.</summary>
+    /// <param name="orderId">The order to refund.</param>
+    [Obsolete]
+    public async Task<bool> Refund(string orderId)
+    {
+        return true;
+    }
+}
+"#;
+        let tree = parse_csharp(source);
+        let extractor = CSharpExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let method = units
+            .iter()
+            .find(|u| u.kind == SemanticKind::Method)
+            .unwrap();
+
+        assert_eq!(
+            method.docs.as_deref(),
+            Some(
+                "<summary>Refunds an order.</summary>\n<param name=\"orderId\">The order to refund.</param>"
+            )
+        );
+        assert_eq!(
+            method.signature.as_deref(),
+            Some("public async Task<bool> Refund(string orderId)")
+        );
+        assert!(extractor.is_exported(method));
+        assert!(!extractor.is_exported(&units[0]));
+    }
+
+    #[test]
+    fn test_extract_test_methods() {
+        let source = r#"
+public class PaymentServiceTests
+{
+    [Fact]
+    public void RefundsPaidOrder() { }
+
+    [Xunit.Theory]
+    [InlineData(1)]
+    public void RejectsAmount(int amount) { }
+
+    [TestMethodAttribute]
+    public void KeepsReceipt() { }
+
+    private void Arrange() { }
+}
+"#;
+        let tree = parse_csharp(source);
+        let extractor = CSharpExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let tests: Vec<_> = units
+            .iter()
+            .filter(|u| u.kind == SemanticKind::Test)
+            .filter_map(|u| u.name.as_deref())
+            .collect();
+
+        assert_eq!(
+            tests,
+            vec!["RefundsPaidOrder", "RejectsAmount", "KeepsReceipt"]
+        );
+    }
+}
@@ -0,0 +1,270 @@
+//! Semantic extractor for grammars configured at runtime.
+//!
+//! A [`GrammarConfig`] maps node types of its grammar to semantic kinds;
+//! every node of a mapped type becomes a unit. A unit is named by the
+//! node's name field (`name` unless configured otherwise), or its first
+//! identifier child when the grammar has no such field.
+//!
+//! Units nested in a class, struct, module, trait, interface, enum or impl
+//! unit have it as parent, and functions nested that way are methods. The
+//! comment nodes directly above a unit are its docs.
+
+use std::collections::HashMap;
+
+use anyhow::{anyhow, Result};
+use tree_sitter::{Node, Tree};
+
+use super::{node_text, SemanticExtractor, SemanticKind, SemanticUnit};
+use crate::config::GrammarConfig;
+
+/// Extractor driven by a [`GrammarConfig`]'s node mapping.
+pub struct GenericExtractor {
+    language: &'static str,
+    separator: &'static str,
+    /// Semantic kind of each mapped node type
+    nodes: HashMap<String, SemanticKind>,
+    node_types: Vec<&'static str>,
+    name_field: String,
+}
+
+impl GenericExtractor {
+    /// Build the extractor of a configured grammar.
+    ///
+    /// Fails when a node type is mapped to an unknown semantic kind.
+    pub fn new(config: &GrammarConfig) -> Result<Self> {
+        let mut nodes = HashMap::new();
+        for (node_type, kind) in &config.nodes {
+            let kind = SemanticKind::parse(kind).ok_or_else(|| {
+                anyhow!(
+                    "Grammar '{}' maps node type '{}' to unknown kind '{}'",
+                    config.name,
+                    node_type,
+                    kind
+                )
+            })?;
+            nodes.insert(node_type.clone(), kind);
+        }
+
+        Ok(Self {
+            language: leak(&config.name),
+            separator: leak(&config.separator),
+            node_types: config.nodes.keys().map(|t| leak(t)).collect(),
+            nodes,
+            name_field: config.name_field.clone(),
+        })
+    }
+
+    /// Push the unit of `node`, if its type is mapped, and those of its
+    /// descendants.
+    fn visit(
+        &self,
+        node: Node,
+        source: &[u8],
+        parent: Option<&str>,
+        units: &mut Vec<SemanticUnit>,
+    ) {
+        let mut context = parent.map(str::to_string);
+
+        if let Some(&kind) = self.nodes.get(node.kind()) {
+            let name = self.get_name(&node, source);
+            let kind = if kind == SemanticKind::Function && parent.is_some() {
+                SemanticKind::Method
+            } else {
+                kind
+            };
+
+            if is_container(kind) {
+                if let Some(name) = &name {
+                    context = Some(match parent {
+                        Some(outer) => format!("{}{}{}", outer, self.separator, name),
+                        None => name.clone(),
+                    });
+                }
+            }
+
+            units.push(SemanticUnit {
+                kind,
+                name,
+                content: node_text(&node, source).to_string(),
+                docs: get_docs(&node, source),
+                start_line: node.start_position().row + 1,
+                end_line: node.end_position().row + 1,
+                start_byte: node.start_byte(),
+                end_byte: node.end_byte(),
+                signature: get_signature(&node, source),
+                parent: parent.map(str::to_string),
+            });
+        }
+
+        let mut cursor = node.walk();
+        for child in node.children(&mut cursor) {
+            self.visit(child, source, context.as_deref(), units);
+        }
+    }
+
+    /// Name of a node: its name field, or its first identifier child.
+    fn get_name(&self, node: &Node, source: &[u8]) -> Option<String> {
+        if let Some(name) = node.child_by_field_name(&self.name_field) {
+            return Some(node_text(&name, source).to_string());
+        }
+        let mut cursor = node.walk();
+        let identifier = node
+            .named_children(&mut cursor)
+            .find(|c| c.kind().ends_with("identifier") || c.kind() == "name")?;
+        Some(node_text(&identifier, source).to_string())
+    }
+}
+
+impl SemanticExtractor for GenericExtractor {
+    fn language_id(&self) -> &'static str {
+        self.language
+    }
+
+    fn extract(&self, tree: &Tree, source: &[u8]) -> Vec<SemanticUnit> {
+        let mut units = Vec::new();
+        self.visit(tree.root_node(), source, None, &mut units);
+        units.sort_by_key(|u| (u.start_line, u.start_byte));
+        units
+    }
+
+    fn target_node_types(&self) -> &[&'static str] {
+        &self.node_types
+    }
+
+    fn qualified_name_separator(&self) -> &'static str {
+        self.separator
+    }
+}
+
+/// Whether units of this kind are the parent of units nested in them.
+fn is_container(kind: SemanticKind) -> bool {
+    matches!(
+        kind,
+        SemanticKind::Class
+            | SemanticKind::Struct
+            | SemanticKind::Module
+            | SemanticKind::Trait
+            | SemanticKind::Interface
+            | SemanticKind::Enum
+            | SemanticKind::Impl
+    )
+}
+
+/// First line of a node, without a trailing `{`.
+fn get_signature(node: &Node, source: &[u8]) -> Option<String> {
+    let first_line = node_text(node, source).lines().next()?.trim();
+    let first_line = first_line
+        .strip_suffix('{')
+        .unwrap_or(first_line)
+        .trim_end();
+    Some(first_line.to_string())
+}
+
+/// The comment nodes right above a node, without comment markers.
+fn get_docs(node: &Node, source: &[u8]) -> Option<String> {
+    let mut docs = Vec::new();
+    let mut current = node.prev_sibling();
+    while let Some(prev) = current {
+        if !prev.kind().contains("comment") {
+            break;
+        }
+        for line in node_text(&prev, source).lines().rev() {
+            let line = line
+                .trim()
+                .trim_start_matches(['/', '#', '-', ';', '*', '!'])
+                .trim_end_matches("*/")
+                .trim();
+            docs.insert(0, line.to_string());
+        }
+        current = prev.prev_sibling();
+    }
+
+    let docs = docs.join("\n").trim().to_string();
+    (!docs.is_empty()).then_some(docs)
+}
+
+/// A configured string that lives as long as the process.
+///
+/// Extractors identify their language with a `&'static str`; runtime
+/// grammars are loaded once, when the chunker is built.
+fn leak(s: &str) -> &'static str {
+    Box::leak(s.to_string().into_boxed_str())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::collections::BTreeMap;
+    use std::path::PathBuf;
+    use tree_sitter::Parser;
+
+    /// A grammar mapping Python's nodes, standing in for a loaded one
+    fn config(nodes: &[(&str, &str)]) -> GrammarConfig {
+        GrammarConfig {
+            name: "snake".to_string(),
+            extensions: vec!["snake".to_string()],
+            library: PathBuf::from("libtree-sitter-snake.so"),
+            symbol: None,
+            nodes: nodes
+                .iter()
+                .map(|(node, kind)| (node.to_string(), kind.to_string()))
+                .collect::<BTreeMap<_, _>>(),
+            name_field: "name".to_string(),
+            separator: ".".to_string(),
+        }
+    }
+
+    fn units_of(extractor: &GenericExtractor, source: &str) -> Vec<SemanticUnit> {
+        let mut parser = Parser::new();
+        parser
+            .set_language(&tree_sitter_python::LANGUAGE.into())
+            .expect("Failed to set Python language");
+        let tree = parser.parse(source, None).expect("Failed to parse");
+        extractor.extract(&tree, source.as_bytes())
+    }
+
+    #[test]
+    fn test_mapped_nodes_become_units() {
+        let extractor = GenericExtractor::new(&config(&[
+            ("class_definition", "class"),
+            ("function_definition", "function"),
+        ]))
+        .unwrap();
+        let source = r#"# Parse a config file.
+def load(path):
+    return open(path).read()
+
+class Loader:
+    def read(self):
+        return load(self.path)
+"#;
+        let units = units_of(&extractor, source);
+        let summary: Vec<_> = units
+            .iter()
+            .map(|u| (u.kind, u.name.as_deref(), u.parent.as_deref(), u.start_line))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                (SemanticKind::Function, Some("load"), None, 2),
+                (SemanticKind::Class, Some("Loader"), None, 5),
+                (SemanticKind::Method, Some("read"), Some("Loader"), 6),
+            ]
+        );
+        assert_eq!(units[0].signature.as_deref(), Some("def load(path):"));
+        assert_eq!(units[0].docs.as_deref(), Some("Parse a config file."));
+        assert_eq!(extractor.language_id(), "snake");
+        assert_eq!(
+            extractor.qualified_name(None, Some("Loader"), "read"),
+            "Loader.read"
+        );
+    }
+
+    #[test]
+    fn test_unknown_kind_is_rejected() {
+        let error = GenericExtractor::new(&config(&[("function_definition", "procedure")]))
+            .err()
+            .unwrap();
+        assert!(error.to_string().contains("unknown kind 'procedure'"));
+    }
+}
@@ -2,6 +2,8 @@
 //!
 //! Extracts: function_declaration, method_declaration, type_declaration (struct, interface)
 
+use std::path::Path;
+
 use tree_sitter::{Node, Tree, TreeCursor};
 
 use super::{node_text, SemanticExtractor, SemanticKind, SemanticUnit};
@@ -35,6 +37,30 @@ impl SemanticExtractor for GoExtractor {
             "var_declaration",
         ]
     }
+
+    /// The file's `package` clause, e.g. `auth`. Methods are qualified by
+    /// their receiver type without the pointer: `auth.User.Login`.
+    fn module_path(&self, tree: &Tree, source: &[u8], _path: &Path) -> Option<String> {
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let clause = root
+            .children(&mut cursor)
+            .find(|child| child.kind() == "package_clause")?;
+
+        let mut cursor = clause.walk();
+        let name = clause
+            .named_children(&mut cursor)
+            .find(|child| child.kind() == "package_identifier")?;
+        Some(node_text(&name, source).to_string())
+    }
+
+    /// Identifiers starting with an upper-case letter are exported.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        unit.name
+            .as_deref()
+            .and_then(|name| name.chars().next())
+            .is_some_and(char::is_uppercase)
+    }
 }
 
 impl GoExtractor {
@@ -291,17 +317,22 @@ impl GoExtractor {
     }
 
     /// Get doc comment (Go uses // comments before declarations).
+    ///
+    /// Only comments directly above the declaration are docs; a blank line
+    /// ends the doc comment.
     fn get_doc_comment(&self, node: &Node, source: &[u8]) -> Option<String> {
         let mut docs = Vec::new();
         let mut prev = node.prev_sibling();
+        let mut next_row = node.start_position().row;
 
         while let Some(sibling) = prev {
-            if sibling.kind() == "comment" {
+            if sibling.kind() == "comment" && sibling.end_position().row + 1 >= next_row {
                 let text = node_text(&sibling, source);
                 docs.insert(0, text.to_string());
             } else {
                 break;
             }
+            next_row = sibling.start_position().row;
             prev = sibling.prev_sibling();
         }
 
@@ -337,6 +368,28 @@ mod tests {
         parser.parse(source, None).expect("Failed to parse")
     }
 
+    #[test]
+    fn test_qualified_method_name() {
+        let source = r#"
+package auth
+
+func (u *User) Login(password string) error {
+    return nil
+}
+"#;
+        let tree = parse_go(source);
+        let extractor = GoExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let method = units.iter().find(|u| u.kind == SemanticKind::Method).unwrap();
+
+        let module = extractor.module_path(&tree, source.as_bytes(), Path::new("user.go"));
+        assert_eq!(module.as_deref(), Some("auth"));
+        assert_eq!(
+            extractor.qualified_name(module.as_deref(), method.parent.as_deref(), "Login"),
+            "auth.User.Login"
+        );
+    }
+
     #[test]
     fn test_extract_function() {
         let source = r#"
@@ -1,11 +1,20 @@
 //! Java-specific semantic extractor.
 //!
 //! Extracts: method_declaration, class_declaration, interface_declaration,
-//! enum_declaration, constructor_declaration
+//! enum_declaration, record_declaration, constructor_declaration
+//!
+//! Members of nested types have the enclosing types as parent
+//! (`Outer.Inner`), so their qualified names follow Java's
+//! (`com.example.Outer.Inner.method`).
+
+use std::path::Path;
 
 use tree_sitter::{Node, Tree, TreeCursor};
 
-use super::{node_text, SemanticExtractor, SemanticKind, SemanticUnit};
+use super::{
+    declaration_modifiers, node_text, skip_leading_comments, SemanticExtractor, SemanticKind,
+    SemanticUnit,
+};
 
 /// Java language semantic extractor.
 pub struct JavaExtractor;
@@ -32,11 +41,38 @@ impl SemanticExtractor for JavaExtractor {
             "class_declaration",
             "interface_declaration",
             "enum_declaration",
+            "record_declaration",
             "method_declaration",
             "constructor_declaration",
             "field_declaration",
         ]
     }
+
+    /// The file's `package` declaration, e.g. `com.example.auth`.
+    fn module_path(&self, tree: &Tree, source: &[u8], _path: &Path) -> Option<String> {
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let package = root
+            .children(&mut cursor)
+            .find(|child| child.kind() == "package_declaration")?;
+
+        let mut cursor = package.walk();
+        let name = package
+            .named_children(&mut cursor)
+            .find(|child| matches!(child.kind(), "scoped_identifier" | "identifier"))?;
+        Some(node_text(&name, source).to_string())
+    }
+
+    /// Declarations with the `public` modifier.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        // Skip Javadoc and annotations, whose arguments would end the
+        // modifier list
+        let declaration: Vec<&str> = skip_leading_comments(&unit.content)
+            .lines()
+            .skip_while(|line| line.trim_start().starts_with('@'))
+            .collect();
+        declaration_modifiers(&declaration.join("\n")).any(|word| word == "public")
+    }
 }
 
 impl JavaExtractor {
@@ -55,10 +91,18 @@ impl JavaExtractor {
             units.push(unit);
         }
 
-        // For class/interface definitions, pass the name as context
+        // For type definitions, pass the name, qualified by any enclosing
+        // types, as context
         let new_context = match node.kind() {
-            "class_declaration" | "interface_declaration" | "enum_declaration" => {
-                self.get_name(&node, source)
+            "class_declaration"
+            | "interface_declaration"
+            | "enum_declaration"
+            | "record_declaration" => {
+                let name = self.get_name(&node, source);
+                match (parent_context, name) {
+                    (Some(outer), Some(name)) => Some(format!("{}.{}", outer, name)),
+                    (_, name) => name,
+                }
             }
             _ => parent_context.map(|s| s.to_string()),
         };
@@ -83,7 +127,7 @@ impl JavaExtractor {
         parent_context: Option<&str>,
     ) -> Option<SemanticUnit> {
         let kind = match node.kind() {
-            "class_declaration" => SemanticKind::Class,
+            "class_declaration" | "record_declaration" => SemanticKind::Class,
             "interface_declaration" => SemanticKind::Interface,
             "enum_declaration" => SemanticKind::Enum,
             "method_declaration" => {
@@ -335,6 +379,30 @@ mod tests {
         parser.parse(source, None).expect("Failed to parse")
     }
 
+    #[test]
+    fn test_qualified_method_name() {
+        let source = r#"
+package com.example.auth;
+
+public class User {
+    public String getName() {
+        return name;
+    }
+}
+"#;
+        let tree = parse_java(source);
+        let extractor = JavaExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let method = units.iter().find(|u| u.kind == SemanticKind::Method).unwrap();
+
+        let module = extractor.module_path(&tree, source.as_bytes(), Path::new("User.java"));
+        assert_eq!(module.as_deref(), Some("com.example.auth"));
+        assert_eq!(
+            extractor.qualified_name(module.as_deref(), method.parent.as_deref(), "getName"),
+            "com.example.auth.User.getName"
+        );
+    }
+
     #[test]
     fn test_extract_class() {
         let source = r#"
@@ -456,6 +524,63 @@ public class Outer {
         assert_eq!(method.unwrap().parent, Some("Outer".to_string()));
     }
 
+    #[test]
+    fn test_nested_class_members_qualify_under_outer_class() {
+        let source = r#"
+package com.example.billing;
+
+public class PaymentService {
+    public Receipt refund(String paymentId) {
+        return gateway.refund(paymentId);
+    }
+
+    public static class Builder {
+        public PaymentService build() {
+            return new PaymentService();
+        }
+    }
+
+    public record Receipt(String id, long cents) {
+        public boolean isEmpty() {
+            return cents == 0;
+        }
+    }
+}
+"#;
+        let tree = parse_java(source);
+        let extractor = JavaExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let module =
+            extractor.module_path(&tree, source.as_bytes(), Path::new("PaymentService.java"));
+        let qualified: Vec<String> = units
+            .iter()
+            .map(|u| {
+                extractor.qualified_name(
+                    module.as_deref(),
+                    u.parent.as_deref(),
+                    u.name.as_deref().unwrap(),
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            qualified,
+            vec![
+                "com.example.billing.PaymentService",
+                "com.example.billing.PaymentService.refund",
+                "com.example.billing.PaymentService.Builder",
+                "com.example.billing.PaymentService.Builder.build",
+                "com.example.billing.PaymentService.Receipt",
+                "com.example.billing.PaymentService.Receipt.isEmpty",
+            ]
+        );
+        let receipt = units
+            .iter()
+            .find(|u| u.name.as_deref() == Some("Receipt"))
+            .unwrap();
+        assert_eq!(receipt.kind, SemanticKind::Class);
+    }
+
     #[test]
     fn test_extract_constant() {
         let source = r#"
@@ -0,0 +1,525 @@
+//! Kotlin-specific semantic extractor.
+//!
+//! Extracts: class_declaration (classes, interfaces, enum classes),
+//! object_declaration, function_declaration
+//!
+//! Extension functions (`fun String.isEmail()`) are methods whose parent is
+//! the receiver type, like Go methods and their receiver, so they qualify
+//! as `com.acme.String.isEmail` and are found by `String.isEmail`. Members
+//! of nested classes and objects have the enclosing types as parent
+//! (`Outer.Inner`); members of a companion object belong to its class.
+//!
+//! A KDoc comment (`/** ... */`) right above a declaration starts its unit
+//! and becomes its docs.
+
+use std::path::Path;
+
+use tree_sitter::{Node, Tree, TreeCursor};
+
+use super::{declaration_modifiers, node_text, SemanticExtractor, SemanticKind, SemanticUnit};
+
+/// Annotations marking a test function (JUnit 4 and 5, kotlin.test)
+const TEST_ANNOTATIONS: &[&str] = &["Test", "ParameterizedTest"];
+
+/// Kotlin language semantic extractor.
+pub struct KotlinExtractor;
+
+impl SemanticExtractor for KotlinExtractor {
+    fn language_id(&self) -> &'static str {
+        "kotlin"
+    }
+
+    fn extract(&self, tree: &Tree, source: &[u8]) -> Vec<SemanticUnit> {
+        let mut units = Vec::new();
+        let mut cursor = tree.walk();
+
+        self.extract_from_node(&mut cursor, source, &mut units, None);
+
+        // Sort by start position
+        units.sort_by_key(|u| (u.start_line, u.start_byte));
+
+        units
+    }
+
+    fn target_node_types(&self) -> &[&'static str] {
+        &[
+            "class_declaration",
+            "object_declaration",
+            "function_declaration",
+        ]
+    }
+
+    /// The file's `package` header, e.g. `com.acme.billing`.
+    fn module_path(&self, tree: &Tree, source: &[u8], _path: &Path) -> Option<String> {
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let header = root
+            .children(&mut cursor)
+            .find(|child| child.kind() == "package_header")?;
+        let package = node_text(&header, source)
+            .trim()
+            .trim_start_matches("package")
+            .trim()
+            .trim_end_matches(';');
+        (!package.is_empty()).then(|| package.to_string())
+    }
+
+    /// Declarations without the `private`, `protected` or `internal`
+    /// modifier; Kotlin declarations are public by default.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        unit.signature.as_deref().map_or(true, |signature| {
+            !declaration_modifiers(signature)
+                .any(|word| matches!(word, "private" | "protected" | "internal"))
+        })
+    }
+}
+
+impl KotlinExtractor {
+    /// Recursively extract semantic units from the AST.
+    fn extract_from_node(
+        &self,
+        cursor: &mut TreeCursor,
+        source: &[u8],
+        units: &mut Vec<SemanticUnit>,
+        parent_context: Option<&str>,
+    ) {
+        let node = cursor.node();
+
+        // Try to extract a semantic unit from this node
+        if let Some(unit) = self.extract_unit(&node, source, parent_context) {
+            units.push(unit);
+        }
+
+        // Classes and objects nest their names; a companion object's
+        // members belong to the enclosing class
+        let new_context = match node.kind() {
+            "class_declaration" | "object_declaration" => {
+                let name = self.get_type_name(&node, source);
+                match (parent_context, name) {
+                    (Some(outer), Some(name)) => Some(format!("{}.{}", outer, name)),
+                    (_, name) => name,
+                }
+            }
+            _ => parent_context.map(|s| s.to_string()),
+        };
+
+        // Recurse into children
+        if cursor.goto_first_child() {
+            loop {
+                self.extract_from_node(cursor, source, units, new_context.as_deref());
+                if !cursor.goto_next_sibling() {
+                    break;
+                }
+            }
+            cursor.goto_parent();
+        }
+    }
+
+    /// Extract a semantic unit from a node if it's a target type.
+    fn extract_unit(
+        &self,
+        node: &Node,
+        source: &[u8],
+        parent_context: Option<&str>,
+    ) -> Option<SemanticUnit> {
+        let (kind, name, parent) = match node.kind() {
+            "class_declaration" => {
+                let kind = if self.child_of_kind(node, &["interface"]).is_some() {
+                    SemanticKind::Interface
+                } else if self.has_modifier(node, source, "enum") {
+                    SemanticKind::Enum
+                } else {
+                    SemanticKind::Class
+                };
+                (kind, self.get_type_name(node, source), parent_context)
+            }
+            "object_declaration" => (
+                SemanticKind::Class,
+                self.get_type_name(node, source),
+                parent_context,
+            ),
+            "function_declaration" => {
+                let (receiver, name) = self.function_header(node, source)?;
+                let kind = if self.is_test_function(node, source) {
+                    SemanticKind::Test
+                } else if receiver.is_some() || parent_context.is_some() {
+                    SemanticKind::Method
+                } else {
+                    SemanticKind::Function
+                };
+                // An extension function belongs to its receiver type
+                let parent = receiver.or(parent_context.map(|s| s.to_string()));
+                return Some(self.unit(node, source, kind, Some(name), parent));
+            }
+            _ => return None,
+        };
+
+        Some(self.unit(node, source, kind, name, parent.map(|s| s.to_string())))
+    }
+
+    /// Build a unit for `node`, started at its KDoc comment if it has one.
+    fn unit(
+        &self,
+        node: &Node,
+        source: &[u8],
+        kind: SemanticKind,
+        name: Option<String>,
+        parent: Option<String>,
+    ) -> SemanticUnit {
+        let kdoc = self.get_kdoc(node, source);
+        let start = kdoc.unwrap_or(*node);
+        let content = std::str::from_utf8(&source[start.start_byte()..node.end_byte()])
+            .unwrap_or("")
+            .to_string();
+
+        SemanticUnit {
+            kind,
+            name,
+            content,
+            docs: kdoc.map(|comment| clean_kdoc(node_text(&comment, source))),
+            start_line: start.start_position().row + 1,
+            end_line: node.end_position().row + 1,
+            start_byte: start.start_byte(),
+            end_byte: node.end_byte(),
+            signature: self.get_signature(node, source),
+            parent,
+        }
+    }
+
+    /// Get the name of a class or object declaration.
+    fn get_type_name(&self, node: &Node, source: &[u8]) -> Option<String> {
+        let name = node.child_by_field_name("name").or_else(|| {
+            self.child_of_kind(
+                node,
+                &["identifier", "type_identifier", "simple_identifier"],
+            )
+        })?;
+        Some(node_text(&name, source).trim_matches('`').to_string())
+    }
+
+    /// Receiver type and name of a function, from the text between `fun`
+    /// and its parameters: `<T> List<T>.secondOrNull` is
+    /// (`Some("List<T>")`, `secondOrNull`).
+    fn function_header(&self, node: &Node, source: &[u8]) -> Option<(Option<String>, String)> {
+        let mut cursor = node.walk();
+        let children: Vec<Node> = node.children(&mut cursor).collect();
+        let fun = children.iter().find(|child| child.kind() == "fun")?;
+        let parameters = children
+            .iter()
+            .find(|child| child.kind() == "function_value_parameters")?;
+        let header = std::str::from_utf8(&source[fun.end_byte()..parameters.start_byte()]).ok()?;
+        let header = skip_type_parameters(header.trim());
+
+        // The receiver ends at the last `.` outside of type arguments
+        let mut depth = 0i32;
+        let mut last_dot = None;
+        for (i, c) in header.char_indices() {
+            match c {
+                '<' | '(' => depth += 1,
+                '>' | ')' => depth -= 1,
+                '.' if depth == 0 => last_dot = Some(i),
+                _ => {}
+            }
+        }
+
+        let (receiver, name) = match last_dot {
+            Some(dot) => {
+                let receiver = header[..dot].trim().trim_end_matches('?');
+                (Some(receiver.to_string()), &header[dot + 1..])
+            }
+            None => (None, header),
+        };
+        let name = name.trim().trim_matches('`');
+        (!name.is_empty()).then(|| (receiver, name.to_string()))
+    }
+
+    /// Get the declaration up to its body, without annotations,
+    /// whitespace collapsed: `suspend fun <T> List<T>.secondOrNull(): T?`,
+    /// `data class Invoice(val id: String) : Payable`.
+    fn get_signature(&self, node: &Node, source: &[u8]) -> Option<String> {
+        let mut cursor = node.walk();
+        let end = node
+            .children(&mut cursor)
+            .find(|child| {
+                matches!(
+                    child.kind(),
+                    "function_body" | "class_body" | "enum_class_body"
+                )
+            })
+            .map_or(node.end_byte(), |body| body.start_byte());
+        let declaration = std::str::from_utf8(&source[node.start_byte()..end]).ok()?;
+        let declaration = skip_annotations(declaration.trim());
+        Some(declaration.split_whitespace().collect::<Vec<_>>().join(" "))
+    }
+
+    /// Get the `/** ... */` comment ending on the line above a node.
+    fn get_kdoc<'a>(&self, node: &Node<'a>, source: &[u8]) -> Option<Node<'a>> {
+        let prev = node.prev_sibling()?;
+        let adjacent = prev.end_position().row + 1 >= node.start_position().row;
+        (prev.kind().contains("comment") && adjacent && node_text(&prev, source).starts_with("/**"))
+            .then_some(prev)
+    }
+
+    /// Check if a function has a test annotation such as `@Test` or
+    /// `@org.junit.jupiter.api.Test`.
+    fn is_test_function(&self, node: &Node, source: &[u8]) -> bool {
+        let Some(modifiers) = self.child_of_kind(node, &["modifiers"]) else {
+            return false;
+        };
+        node_text(&modifiers, source)
+            .split_whitespace()
+            .filter_map(|word| word.strip_prefix('@'))
+            .map(|annotation| annotation.split('(').next().unwrap_or(annotation))
+            .any(|annotation| {
+                let name = annotation.rsplit('.').next().unwrap_or(annotation);
+                TEST_ANNOTATIONS.contains(&name)
+            })
+    }
+
+    /// Whether `node`'s modifiers include `modifier`, e.g. `enum`.
+    fn has_modifier(&self, node: &Node, source: &[u8], modifier: &str) -> bool {
+        self.child_of_kind(node, &["modifiers"])
+            .is_some_and(|modifiers| {
+                node_text(&modifiers, source)
+                    .split_whitespace()
+                    .any(|word| word == modifier)
+            })
+    }
+
+    /// The first child of `node` of one of `kinds`, e.g. its `modifiers`
+    /// or the `interface` keyword.
+    fn child_of_kind<'a>(&self, node: &Node<'a>, kinds: &[&str]) -> Option<Node<'a>> {
+        let mut cursor = node.walk();
+        for child in node.children(&mut cursor) {
+            if kinds.contains(&child.kind()) {
+                return Some(child);
+            }
+        }
+        None
+    }
+}
+
+/// `text` without a leading type parameter list (`<T : Any>`).
+fn skip_type_parameters(text: &str) -> &str {
+    if !text.starts_with('<') {
+        return text;
+    }
+    let mut depth = 0;
+    for (i, c) in text.char_indices() {
+        match c {
+            '<' => depth += 1,
+            '>' => {
+                depth -= 1;
+                if depth == 0 {
+                    return text[i + 1..].trim_start();
+                }
+            }
+            _ => {}
+        }
+    }
+    text
+}
+
+/// `text` without leading annotations (`@Test`, `@Suppress("unused")`).
+fn skip_annotations(mut text: &str) -> &str {
+    while let Some(rest) = text.strip_prefix('@') {
+        let name_end = rest
+            .find(|c: char| !(c.is_alphanumeric() || matches!(c, '_' | '.' | ':')))
+            .unwrap_or(rest.len());
+        let mut rest = &rest[name_end..];
+        if rest.starts_with('(') {
+            let mut depth = 0;
+            let mut end = rest.len();
+            for (i, c) in rest.char_indices() {
+                match c {
+                    '(' => depth += 1,
+                    ')' => {
+                        depth -= 1;
+                        if depth == 0 {
+                            end = i + 1;
+                            break;
+                        }
+                    }
+                    _ => {}
+                }
+            }
+            rest = &rest[end..];
+        }
+        text = rest.trim_start();
+    }
+    text
+}
+
+/// Text of a KDoc comment without the `/**`, `*` and `*/` markers.
+fn clean_kdoc(comment: &str) -> String {
+    comment
+        .trim_start_matches("/**")
+        .trim_end_matches("*/")
+        .lines()
+        .map(|line| line.trim().trim_start_matches('*').trim())
+        .filter(|line| !line.is_empty())
+        .collect::<Vec<_>>()
+        .join("\n")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tree_sitter::Parser;
+
+    fn parse_kotlin(source: &str) -> Tree {
+        let mut parser = Parser::new();
+        parser
+            .set_language(&tree_sitter_kotlin_ng::LANGUAGE.into())
+            .expect("Failed to set Kotlin language");
+        parser.parse(source, None).expect("Failed to parse")
+    }
+
+    #[test]
+    fn test_classes_objects_and_functions() {
+        let source = r#"
+package com.acme.billing
+
+interface Payable
+
+enum class Status { OPEN, PAID }
+
+data class Invoice(val id: String) : Payable {
+    fun total(): Long = 0
+
+    companion object {
+        fun empty(): Invoice = Invoice("")
+    }
+}
+
+object InvoiceRegistry {
+    fun find(id: String): Invoice? = null
+}
+
+fun formatCents(cents: Long): String = "$cents"
+"#;
+        let tree = parse_kotlin(source);
+        let extractor = KotlinExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let module = extractor.module_path(&tree, source.as_bytes(), Path::new("Invoice.kt"));
+        assert_eq!(module.as_deref(), Some("com.acme.billing"));
+
+        let qualified: Vec<_> = units
+            .iter()
+            .map(|u| {
+                let name = u.name.as_deref().unwrap();
+                (
+                    u.kind,
+                    extractor.qualified_name(module.as_deref(), u.parent.as_deref(), name),
+                )
+            })
+            .collect();
+        assert_eq!(
+            qualified,
+            vec![
+                (
+                    SemanticKind::Interface,
+                    "com.acme.billing.Payable".to_string()
+                ),
+                (SemanticKind::Enum, "com.acme.billing.Status".to_string()),
+                (SemanticKind::Class, "com.acme.billing.Invoice".to_string()),
+                (
+                    SemanticKind::Method,
+                    "com.acme.billing.Invoice.total".to_string()
+                ),
+                (
+                    SemanticKind::Method,
+                    "com.acme.billing.Invoice.empty".to_string()
+                ),
+                (
+                    SemanticKind::Class,
+                    "com.acme.billing.InvoiceRegistry".to_string()
+                ),
+                (
+                    SemanticKind::Method,
+                    "com.acme.billing.InvoiceRegistry.find".to_string()
+                ),
+                (
+                    SemanticKind::Function,
+                    "com.acme.billing.formatCents".to_string()
+                ),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_extension_functions_belong_to_receiver() {
+        let source = r#"
+/** Whether this is a valid email address. */
+fun String.isEmail(): Boolean = contains("@")
+
+fun <T> List<T>.secondOrNull(): T? = getOrNull(1)
+
+internal fun String?.orBlank(): String = this ?: ""
+"#;
+        let tree = parse_kotlin(source);
+        let extractor = KotlinExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let extensions: Vec<_> = units
+            .iter()
+            .map(|u| {
+                (
+                    u.kind,
+                    u.name.as_deref().unwrap(),
+                    u.parent.as_deref().unwrap(),
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            extensions,
+            vec![
+                (SemanticKind::Method, "isEmail", "String"),
+                (SemanticKind::Method, "secondOrNull", "List<T>"),
+                (SemanticKind::Method, "orBlank", "String"),
+            ]
+        );
+        assert_eq!(
+            extractor.qualified_name(None, units[1].parent.as_deref(), "secondOrNull"),
+            "List.secondOrNull"
+        );
+        assert_eq!(
+            units[0].docs.as_deref(),
+            Some("Whether this is a valid email address.")
+        );
+        assert!(units[0].content.starts_with("/**"));
+        assert_eq!(
+            units[1].signature.as_deref(),
+            Some("fun <T> List<T>.secondOrNull(): T?")
+        );
+        assert!(extractor.is_exported(&units[0]));
+        assert!(!extractor.is_exported(&units[2]));
+    }
+
+    #[test]
+    fn test_extract_test_functions() {
+        let source = r#"
+class InvoiceTest {
+    @Test
+    fun totalsLines() {}
+
+    @org.junit.jupiter.params.ParameterizedTest
+    @ValueSource(ints = [1, 2])
+    fun rejectsAmount(amount: Int) {}
+
+    private fun invoice() = Invoice("1")
+}
+"#;
+        let tree = parse_kotlin(source);
+        let units = KotlinExtractor.extract(&tree, source.as_bytes());
+        let tests: Vec<_> = units
+            .iter()
+            .filter(|u| u.kind == SemanticKind::Test)
+            .filter_map(|u| u.name.as_deref())
+            .collect();
+
+        assert_eq!(tests, vec!["totalsLines", "rejectsAmount"]);
+        let test = units.iter().find(|u| u.kind == SemanticKind::Test).unwrap();
+        assert_eq!(test.signature.as_deref(), Some("fun totalsLines()"));
+    }
+}
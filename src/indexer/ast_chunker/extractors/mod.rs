@@ -5,22 +5,37 @@
 
 pub mod c;
 pub mod cpp;
+pub mod csharp;
+pub mod generic;
 pub mod go;
 pub mod java;
+pub mod kotlin;
+pub mod php;
 pub mod python;
+pub mod ruby;
 pub mod rust;
+pub mod scala;
+pub mod swift;
 pub mod typescript;
 
 use std::collections::HashMap;
+use std::path::Path;
 
 use tree_sitter::Tree;
 
 pub use c::CExtractor;
 pub use cpp::CppExtractor;
+pub use csharp::CSharpExtractor;
+pub use generic::GenericExtractor;
 pub use go::GoExtractor;
 pub use java::JavaExtractor;
+pub use kotlin::KotlinExtractor;
+pub use php::PhpExtractor;
 pub use python::PythonExtractor;
+pub use ruby::RubyExtractor;
 pub use rust::RustExtractor;
+pub use scala::ScalaExtractor;
+pub use swift::SwiftExtractor;
 pub use typescript::TypeScriptExtractor;
 
 /// Types of semantic units we can extract from code.
@@ -52,6 +67,16 @@ pub enum SemanticKind {
     Macro,
     /// A test function
     Test,
+    /// An API operation (OpenAPI `METHOD /route`)
+    Endpoint,
+    /// A function or method of a generated API client
+    ClientMethod,
+    /// A configuration key (dotenv `KEY=value`)
+    Config,
+    /// A field of a data schema (OpenAPI schema property)
+    Field,
+    /// A documentation section (Markdown heading)
+    Section,
     /// Fallback for unrecognized but complete blocks
     Block,
 }
@@ -73,6 +98,11 @@ impl SemanticKind {
             SemanticKind::TypeAlias => "type_alias",
             SemanticKind::Macro => "macro",
             SemanticKind::Test => "test",
+            SemanticKind::Endpoint => "endpoint",
+            SemanticKind::ClientMethod => "client-method",
+            SemanticKind::Config => "config",
+            SemanticKind::Field => "field",
+            SemanticKind::Section => "section",
             SemanticKind::Block => "block",
         }
     }
@@ -93,6 +123,11 @@ impl SemanticKind {
             "type_alias" => Some(SemanticKind::TypeAlias),
             "macro" => Some(SemanticKind::Macro),
             "test" => Some(SemanticKind::Test),
+            "endpoint" => Some(SemanticKind::Endpoint),
+            "client-method" | "client_method" => Some(SemanticKind::ClientMethod),
+            "config" => Some(SemanticKind::Config),
+            "field" => Some(SemanticKind::Field),
+            "section" => Some(SemanticKind::Section),
             "block" => Some(SemanticKind::Block),
             _ => None,
         }
@@ -146,6 +181,101 @@ pub trait SemanticExtractor: Send + Sync {
     ///
     /// This is informational and can be used for debugging.
     fn target_node_types(&self) -> &[&'static str];
+
+    /// Separator placed between the parts of a qualified name.
+    ///
+    /// | Language | Separator | Example |
+    /// |----------|-----------|---------|
+    /// | Rust, C++, Ruby | `::` | `crate::auth::User::new`, `geo::Point::norm` |
+    /// | Java, Kotlin, Scala, Swift, Go, Python, JS/TS, C, C# | `.` | `com.acme.User.getName`, `auth.User.Login` |
+    /// | PHP | `\` (`::` before members) | `App\Billing\Invoice::total` |
+    fn qualified_name_separator(&self) -> &'static str {
+        "."
+    }
+
+    /// Module or package prefix shared by every symbol in a file.
+    ///
+    /// Returns `None` for languages without a file-level namespace
+    /// (JavaScript/TypeScript modules, C).
+    fn module_path(&self, _tree: &Tree, _source: &[u8], _path: &Path) -> Option<String> {
+        None
+    }
+
+    /// Build the fully qualified name of a symbol: `module`, `parent` and
+    /// `name` joined with [`qualified_name_separator`](Self::qualified_name_separator).
+    fn qualified_name(&self, module: Option<&str>, parent: Option<&str>, name: &str) -> String {
+        join_qualified_name(self.qualified_name_separator(), module, parent, name)
+    }
+
+    /// Whether a unit is part of the public API of its file.
+    ///
+    /// Used by API-surface indexing to drop private helpers. Languages
+    /// without visibility rules treat every unit as exported.
+    fn is_exported(&self, _unit: &SemanticUnit) -> bool {
+        true
+    }
+}
+
+/// Words of a declaration before its parameter list or body, e.g.
+/// `public static int` for `public static int size() {`.
+pub fn declaration_modifiers(content: &str) -> impl Iterator<Item = &str> {
+    let end = content.find(['(', '{', '=', ';']).unwrap_or(content.len());
+    content[..end].split_whitespace()
+}
+
+/// `content` from its first line of code, after the doc comments and
+/// leading comments a symbol's chunk starts with.
+pub fn skip_leading_comments(content: &str) -> &str {
+    let mut in_block = false;
+    let mut offset = 0;
+    for line in content.split_inclusive('\n') {
+        let trimmed = line.trim();
+        if in_block {
+            in_block = !trimmed.contains("*/");
+        } else if let Some(rest) = trimmed.strip_prefix("/*") {
+            match rest.find("*/") {
+                // Code after a one-line comment
+                Some(end) if !rest[end + 2..].trim().is_empty() => break,
+                Some(_) => {}
+                None => in_block = true,
+            }
+        } else if !trimmed.is_empty() && !trimmed.starts_with("//") {
+            break;
+        }
+        offset += line.len();
+    }
+    &content[offset..]
+}
+
+/// Join the parts of a qualified name, skipping empty parts.
+///
+/// The parent is normalized to a bare type name: generic arguments
+/// (`Vec<T>`, `List[T]`) and pointer/reference markers (`*T`, `&T`) are
+/// dropped so that `impl<T> Stack<T>` and `func (s *Stack[T])` both qualify
+/// methods under `Stack`.
+pub fn join_qualified_name(
+    separator: &str,
+    module: Option<&str>,
+    parent: Option<&str>,
+    name: &str,
+) -> String {
+    let parent = parent.map(normalize_parent);
+    [module, parent.as_deref(), Some(name)]
+        .into_iter()
+        .flatten()
+        .filter(|part| !part.is_empty())
+        .collect::<Vec<_>>()
+        .join(separator)
+}
+
+pub(crate) fn normalize_parent(parent: &str) -> String {
+    let trimmed = parent
+        .trim()
+        .trim_start_matches(['&', '*'])
+        .trim_start_matches("mut ")
+        .trim();
+    let end = trimmed.find(['<', '[']).unwrap_or(trimmed.len());
+    trimmed[..end].trim().to_string()
 }
 
 /// Registry of language-specific extractors.
@@ -168,6 +298,12 @@ impl ExtractorRegistry {
         registry.register(Box::new(JavaExtractor));
         registry.register(Box::new(CExtractor));
         registry.register(Box::new(CppExtractor));
+        registry.register(Box::new(CSharpExtractor));
+        registry.register(Box::new(RubyExtractor));
+        registry.register(Box::new(PhpExtractor));
+        registry.register(Box::new(KotlinExtractor));
+        registry.register(Box::new(SwiftExtractor));
+        registry.register(Box::new(ScalaExtractor));
 
         // Also register JavaScript separately (uses same extractor)
         registry.extractors.insert(
@@ -215,6 +351,40 @@ pub fn node_text<'a>(node: &tree_sitter::Node, source: &'a [u8]) -> &'a str {
 mod tests {
     use super::*;
 
+    #[test]
+    fn test_join_qualified_name() {
+        assert_eq!(
+            join_qualified_name(".", Some("com.acme"), Some("User"), "getName"),
+            "com.acme.User.getName"
+        );
+        assert_eq!(join_qualified_name(".", None, None, "main"), "main");
+        assert_eq!(
+            join_qualified_name("::", Some("crate"), Some("Stack<T>"), "push"),
+            "crate::Stack::push"
+        );
+        assert_eq!(
+            join_qualified_name(".", Some("list"), Some("*List[T]"), "Len"),
+            "list.List.Len"
+        );
+    }
+
+    #[test]
+    fn test_skip_leading_comments() {
+        assert_eq!(
+            skip_leading_comments("/**\n * Lists tasks.\n */\nexport const TaskList = 1;"),
+            "export const TaskList = 1;"
+        );
+        assert_eq!(
+            skip_leading_comments("// Sum of amounts.\n\npublic int sum() {}"),
+            "public int sum() {}"
+        );
+        assert_eq!(
+            skip_leading_comments("/* inline */ export function f() {}"),
+            "/* inline */ export function f() {}"
+        );
+        assert_eq!(skip_leading_comments("fn f() {}"), "fn f() {}");
+    }
+
     #[test]
     fn test_semantic_kind_as_str() {
         assert_eq!(SemanticKind::Function.as_str(), "function");
@@ -227,6 +397,12 @@ mod tests {
         assert_eq!(SemanticKind::parse("function"), Some(SemanticKind::Function));
         assert_eq!(SemanticKind::parse("class"), Some(SemanticKind::Class));
         assert_eq!(SemanticKind::parse("unknown"), None);
+        assert_eq!(
+            SemanticKind::parse(SemanticKind::ClientMethod.as_str()),
+            Some(SemanticKind::ClientMethod)
+        );
+        assert_eq!(SemanticKind::parse("config"), Some(SemanticKind::Config));
+        assert_eq!(SemanticKind::parse("field"), Some(SemanticKind::Field));
     }
 
     #[test]
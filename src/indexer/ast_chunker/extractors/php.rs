@@ -0,0 +1,428 @@
+//! PHP-specific semantic extractor.
+//!
+//! Extracts: class_declaration, interface_declaration, trait_declaration,
+//! enum_declaration, function_definition, method_declaration
+//!
+//! Files are parsed with the HTML-aware grammar, so functions and classes
+//! inside `<?php ... ?>` blocks of a template are found like those of a
+//! pure PHP file; the HTML between blocks is not a unit.
+//!
+//! A docblock (`/** ... */`) right above a declaration starts its unit and
+//! becomes its docs. Qualified names read like PHP's: the namespace and
+//! types are joined with `\` and members with `::`
+//! (`App\Billing\Invoice::total`).
+
+use std::path::Path;
+
+use tree_sitter::{Node, Tree, TreeCursor};
+
+use super::{
+    declaration_modifiers, join_qualified_name, node_text, SemanticExtractor, SemanticKind,
+    SemanticUnit,
+};
+
+/// PHP language semantic extractor.
+pub struct PhpExtractor;
+
+impl SemanticExtractor for PhpExtractor {
+    fn language_id(&self) -> &'static str {
+        "php"
+    }
+
+    fn extract(&self, tree: &Tree, source: &[u8]) -> Vec<SemanticUnit> {
+        let mut units = Vec::new();
+        let mut cursor = tree.walk();
+
+        self.extract_from_node(&mut cursor, source, &mut units, None);
+
+        // Sort by start position
+        units.sort_by_key(|u| (u.start_line, u.start_byte));
+
+        units
+    }
+
+    fn target_node_types(&self) -> &[&'static str] {
+        &[
+            "class_declaration",
+            "interface_declaration",
+            "trait_declaration",
+            "enum_declaration",
+            "function_definition",
+            "method_declaration",
+        ]
+    }
+
+    fn qualified_name_separator(&self) -> &'static str {
+        "\\"
+    }
+
+    /// The file's first `namespace`, e.g. `App\Billing`.
+    fn module_path(&self, tree: &Tree, source: &[u8], _path: &Path) -> Option<String> {
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let namespace = root
+            .children(&mut cursor)
+            .find(|child| child.kind() == "namespace_definition")?;
+        self.get_name(&namespace, source)
+    }
+
+    /// Members join their type with `::`, everything else with `\`:
+    /// `App\Billing\Invoice::total`, `App\Billing\format_money`.
+    fn qualified_name(&self, module: Option<&str>, parent: Option<&str>, name: &str) -> String {
+        match parent {
+            Some(parent) => format!(
+                "{}::{}",
+                join_qualified_name("\\", module, None, parent),
+                name
+            ),
+            None => join_qualified_name("\\", module, None, name),
+        }
+    }
+
+    /// Declarations without the `private` or `protected` modifier; PHP
+    /// members are public by default.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        unit.signature.as_deref().map_or(true, |signature| {
+            !declaration_modifiers(signature).any(|word| word == "private" || word == "protected")
+        })
+    }
+}
+
+impl PhpExtractor {
+    /// Recursively extract semantic units from the AST.
+    fn extract_from_node(
+        &self,
+        cursor: &mut TreeCursor,
+        source: &[u8],
+        units: &mut Vec<SemanticUnit>,
+        parent_context: Option<&str>,
+    ) {
+        let node = cursor.node();
+
+        // Try to extract a semantic unit from this node
+        if let Some(unit) = self.extract_unit(&node, source, parent_context) {
+            units.push(unit);
+        }
+
+        // For type definitions, pass the name as context
+        let new_context = match node.kind() {
+            "class_declaration"
+            | "interface_declaration"
+            | "trait_declaration"
+            | "enum_declaration" => self.get_name(&node, source),
+            _ => parent_context.map(|s| s.to_string()),
+        };
+
+        // Recurse into children
+        if cursor.goto_first_child() {
+            loop {
+                self.extract_from_node(cursor, source, units, new_context.as_deref());
+                if !cursor.goto_next_sibling() {
+                    break;
+                }
+            }
+            cursor.goto_parent();
+        }
+    }
+
+    /// Extract a semantic unit from a node if it's a target type.
+    fn extract_unit(
+        &self,
+        node: &Node,
+        source: &[u8],
+        parent_context: Option<&str>,
+    ) -> Option<SemanticUnit> {
+        let docblock = self.get_docblock(node, source);
+        let docs = docblock.map(|comment| clean_docblock(node_text(&comment, source)));
+
+        let kind = match node.kind() {
+            "class_declaration" => SemanticKind::Class,
+            "interface_declaration" => SemanticKind::Interface,
+            "trait_declaration" => SemanticKind::Trait,
+            "enum_declaration" => SemanticKind::Enum,
+            "function_definition" => SemanticKind::Function,
+            "method_declaration" if self.is_test_method(node, source, docs.as_deref()) => {
+                SemanticKind::Test
+            }
+            "method_declaration" => SemanticKind::Method,
+            _ => return None,
+        };
+
+        let name = self.get_name(node, source);
+        let signature = self.get_signature(node, source);
+
+        // The docblock starts the unit so it is embedded with the code
+        let start = docblock.unwrap_or(*node);
+        let content = std::str::from_utf8(&source[start.start_byte()..node.end_byte()])
+            .unwrap_or("")
+            .to_string();
+
+        Some(SemanticUnit {
+            kind,
+            name,
+            content,
+            docs,
+            start_line: start.start_position().row + 1,
+            end_line: node.end_position().row + 1,
+            start_byte: start.start_byte(),
+            end_byte: node.end_byte(),
+            signature,
+            parent: parent_context.map(|s| s.to_string()),
+        })
+    }
+
+    /// Get the name/identifier from a node.
+    fn get_name(&self, node: &Node, source: &[u8]) -> Option<String> {
+        node.child_by_field_name("name")
+            .map(|n| node_text(&n, source).to_string())
+    }
+
+    /// Get the declaration up to its body, without attributes,
+    /// whitespace collapsed: `public function total(int $tax = 0): Money`,
+    /// `final class Invoice extends Model implements Payable`.
+    fn get_signature(&self, node: &Node, source: &[u8]) -> Option<String> {
+        let mut cursor = node.walk();
+        let start = node
+            .children(&mut cursor)
+            .find(|child| child.kind() != "attribute_list")?
+            .start_byte();
+        let end = node
+            .child_by_field_name("body")
+            .map_or(node.end_byte(), |body| body.start_byte());
+        let declaration = std::str::from_utf8(&source[start..end]).ok()?;
+        let declaration = declaration.trim().trim_end_matches(';');
+        Some(declaration.split_whitespace().collect::<Vec<_>>().join(" "))
+    }
+
+    /// Get the `/** ... */` comment ending on the line above a node.
+    fn get_docblock<'a>(&self, node: &Node<'a>, source: &[u8]) -> Option<Node<'a>> {
+        let prev = node.prev_sibling()?;
+        let adjacent = prev.end_position().row + 1 >= node.start_position().row;
+        (prev.kind() == "comment" && adjacent && node_text(&prev, source).starts_with("/**"))
+            .then_some(prev)
+    }
+
+    /// Check if a method is a PHPUnit test: named `test*`, tagged `@test`,
+    /// or marked `#[Test]`.
+    fn is_test_method(&self, node: &Node, source: &[u8], docs: Option<&str>) -> bool {
+        if self
+            .get_name(node, source)
+            .is_some_and(|name| name.starts_with("test"))
+        {
+            return true;
+        }
+        if docs.is_some_and(|docs| docs.lines().any(|line| line.trim() == "@test")) {
+            return true;
+        }
+
+        let mut cursor = node.walk();
+        for attributes in node.children(&mut cursor) {
+            if attributes.kind() != "attribute_list" {
+                continue;
+            }
+            // `#[Test]` or `#[\PHPUnit\Framework\Attributes\Test]`, possibly
+            // grouped with others as `#[Test, Group('slow')]`
+            let text = node_text(&attributes, source);
+            for group in text.split("#[").skip(1) {
+                let names = group
+                    .split(',')
+                    .map(|attribute| attribute.split(['(', ']']).next().unwrap_or("").trim());
+                for name in names {
+                    if name.rsplit('\\').next() == Some("Test") {
+                        return true;
+                    }
+                }
+            }
+        }
+        false
+    }
+}
+
+/// Text of a docblock without the `/**`, `*` and `*/` markers.
+fn clean_docblock(comment: &str) -> String {
+    comment
+        .trim_start_matches("/**")
+        .trim_end_matches("*/")
+        .lines()
+        .map(|line| line.trim().trim_start_matches('*').trim())
+        .filter(|line| !line.is_empty())
+        .collect::<Vec<_>>()
+        .join("\n")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tree_sitter::Parser;
+
+    fn parse_php(source: &str) -> Tree {
+        let mut parser = Parser::new();
+        parser
+            .set_language(&tree_sitter_php::LANGUAGE_PHP.into())
+            .expect("Failed to set PHP language");
+        parser.parse(source, None).expect("Failed to parse")
+    }
+
+    #[test]
+    fn test_qualified_names() {
+        let source = r#"<?php
+namespace App\Billing;
+
+interface Payable {}
+
+trait HasMoney
+{
+    public function format(int $cents): string { return ''; }
+}
+
+final class Invoice extends Model implements Payable
+{
+    use HasMoney;
+
+    public function total(): int { return 0; }
+}
+
+function format_money(int $cents): string { return ''; }
+"#;
+        let tree = parse_php(source);
+        let extractor = PhpExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let module = extractor.module_path(&tree, source.as_bytes(), Path::new("Invoice.php"));
+        assert_eq!(module.as_deref(), Some("App\\Billing"));
+
+        let qualified: Vec<_> = units
+            .iter()
+            .map(|u| {
+                let name = u.name.as_deref().unwrap();
+                (
+                    u.kind,
+                    extractor.qualified_name(module.as_deref(), u.parent.as_deref(), name),
+                )
+            })
+            .collect();
+        assert_eq!(
+            qualified,
+            vec![
+                (SemanticKind::Interface, "App\\Billing\\Payable".to_string()),
+                (SemanticKind::Trait, "App\\Billing\\HasMoney".to_string()),
+                (
+                    SemanticKind::Method,
+                    "App\\Billing\\HasMoney::format".to_string()
+                ),
+                (SemanticKind::Class, "App\\Billing\\Invoice".to_string()),
+                (
+                    SemanticKind::Method,
+                    "App\\Billing\\Invoice::total".to_string()
+                ),
+                (
+                    SemanticKind::Function,
+                    "App\\Billing\\format_money".to_string()
+                ),
+            ]
+        );
+        assert_eq!(
+            units[3].signature.as_deref(),
+            Some("final class Invoice extends Model implements Payable")
+        );
+    }
+
+    #[test]
+    fn test_docblock_starts_unit() {
+        let source = r#"<?php
+class Invoice
+{
+    /**
+     * Total in cents, tax included.
+     *
+     * @return int
+     */
+    #[Pure]
+    public function total(): int
+    {
+        return 0;
+    }
+
+    /* Not a docblock */
+    private function round(int $cents): int
+    {
+        return $cents;
+    }
+}
+"#;
+        let tree = parse_php(source);
+        let extractor = PhpExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+
+        let total = &units[1];
+        assert_eq!(
+            total.docs.as_deref(),
+            Some("Total in cents, tax included.\n@return int")
+        );
+        assert_eq!(total.start_line, 4);
+        assert!(total.content.starts_with("/**"));
+        assert_eq!(
+            total.signature.as_deref(),
+            Some("public function total(): int")
+        );
+        assert!(extractor.is_exported(total));
+
+        let round = &units[2];
+        assert_eq!(round.docs, None);
+        assert!(round.content.starts_with("private function round"));
+        assert!(!extractor.is_exported(round));
+    }
+
+    #[test]
+    fn test_mixed_html_template() {
+        let source = r#"<html>
+<body>
+<?php
+function render_row(array $row): void
+{
+    ?>
+    <tr><td><?= htmlspecialchars($row['name']) ?></td></tr>
+    <?php
+}
+?>
+<table>
+<?php foreach ($rows as $row) { render_row($row); } ?>
+</table>
+</body>
+</html>
+"#;
+        let tree = parse_php(source);
+        let units = PhpExtractor.extract(&tree, source.as_bytes());
+
+        assert_eq!(units.len(), 1);
+        assert_eq!(units[0].kind, SemanticKind::Function);
+        assert_eq!(units[0].name.as_deref(), Some("render_row"));
+        assert_eq!((units[0].start_line, units[0].end_line), (4, 9));
+    }
+
+    #[test]
+    fn test_extract_test_methods() {
+        let source = r#"<?php
+class InvoiceTest extends TestCase
+{
+    public function testTotal(): void {}
+
+    /** @test */
+    public function it_rounds_totals(): void {}
+
+    #[Test]
+    public function refunds(): void {}
+
+    private function makeInvoice(): Invoice {}
+}
+"#;
+        let tree = parse_php(source);
+        let units = PhpExtractor.extract(&tree, source.as_bytes());
+        let tests: Vec<_> = units
+            .iter()
+            .filter(|u| u.kind == SemanticKind::Test)
+            .filter_map(|u| u.name.as_deref())
+            .collect();
+
+        assert_eq!(tests, vec!["testTotal", "it_rounds_totals", "refunds"]);
+    }
+}
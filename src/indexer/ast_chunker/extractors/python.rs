@@ -1,6 +1,9 @@
 //! Python-specific semantic extractor.
 //!
-//! Extracts: function_definition, class_definition, decorated functions/classes
+//! Extracts: function_definition, class_definition, decorated functions/classes,
+//! and the module docstring
+
+use std::path::Path;
 
 use tree_sitter::{Node, Tree, TreeCursor};
 
@@ -19,6 +22,7 @@ impl SemanticExtractor for PythonExtractor {
         let mut cursor = tree.walk();
 
         self.extract_from_node(&mut cursor, source, &mut units, None);
+        units.extend(self.module_docstring(&tree.root_node(), source));
 
         // Sort by start position
         units.sort_by_key(|u| (u.start_line, u.start_byte));
@@ -33,6 +37,43 @@ impl SemanticExtractor for PythonExtractor {
             "decorated_definition",
         ]
     }
+
+    /// Dotted module path: the file stem prefixed by every enclosing
+    /// package directory (one containing `__init__.py`). `pkg/__init__.py`
+    /// is the module `pkg` itself.
+    fn module_path(&self, _tree: &Tree, _source: &[u8], path: &Path) -> Option<String> {
+        let stem = path.file_stem()?.to_str()?;
+        let mut parts = Vec::new();
+        if stem != "__init__" {
+            parts.push(stem.to_string());
+        }
+
+        let mut dir = path.parent();
+        while let Some(d) = dir {
+            if !d.join("__init__.py").is_file() {
+                break;
+            }
+            match d.file_name().and_then(|n| n.to_str()) {
+                Some(name) => parts.push(name.to_string()),
+                None => break,
+            }
+            dir = d.parent();
+        }
+
+        if parts.is_empty() {
+            return None;
+        }
+        parts.reverse();
+        Some(parts.join("."))
+    }
+
+    /// Names without a leading underscore are public by convention;
+    /// dunder methods such as `__init__` are part of a class's API.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        unit.name.as_deref().is_some_and(|name| {
+            !name.starts_with('_') || (name.starts_with("__") && name.ends_with("__"))
+        })
+    }
 }
 
 impl PythonExtractor {
@@ -253,6 +294,36 @@ impl PythonExtractor {
         }
     }
 
+    /// The module docstring: a string literal as the first statement of the
+    /// file, before any code (a shebang or comments may precede it).
+    fn module_docstring(&self, root: &Node, source: &[u8]) -> Option<SemanticUnit> {
+        let mut cursor = root.walk();
+        let statement = root
+            .named_children(&mut cursor)
+            .find(|n| n.kind() != "comment")?;
+        if statement.kind() != "expression_statement" || statement.named_child_count() != 1 {
+            return None;
+        }
+        let string = statement.named_child(0)?;
+        if string.kind() != "string" {
+            return None;
+        }
+
+        let docs = node_text(&string, source).to_string();
+        Some(SemanticUnit {
+            kind: SemanticKind::Module,
+            name: None,
+            content: node_text(&statement, source).to_string(),
+            docs: Some(docs),
+            start_line: statement.start_position().row + 1,
+            end_line: statement.end_position().row + 1,
+            start_byte: statement.start_byte(),
+            end_byte: statement.end_byte(),
+            signature: None,
+            parent: None,
+        })
+    }
+
     /// Get docstring from a function or class.
     fn get_docstring(&self, node: &Node, source: &[u8]) -> Option<String> {
         // Look for expression_statement containing a string as first child of body
@@ -289,6 +360,32 @@ mod tests {
         parser.parse(source, None).expect("Failed to parse")
     }
 
+    #[test]
+    fn test_qualified_method_name() {
+        let dir = tempfile::tempdir().unwrap();
+        let package = dir.path().join("app").join("models");
+        std::fs::create_dir_all(&package).unwrap();
+        std::fs::write(dir.path().join("app").join("__init__.py"), "").unwrap();
+        std::fs::write(package.join("__init__.py"), "").unwrap();
+
+        let source = r#"
+class User:
+    def full_name(self):
+        return self.first + " " + self.last
+"#;
+        let tree = parse_python(source);
+        let extractor = PythonExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let method = units.iter().find(|u| u.kind == SemanticKind::Method).unwrap();
+
+        let module = extractor.module_path(&tree, source.as_bytes(), &package.join("user.py"));
+        assert_eq!(module.as_deref(), Some("app.models.user"));
+        assert_eq!(
+            extractor.qualified_name(module.as_deref(), method.parent.as_deref(), "full_name"),
+            "app.models.user.User.full_name"
+        );
+    }
+
     #[test]
     fn test_extract_function() {
         let source = r#"
@@ -396,6 +493,40 @@ def documented():
         assert!(units[0].docs.as_ref().unwrap().contains("docstring"));
     }
 
+    #[test]
+    fn test_extract_module_docstring() {
+        let source = r#"#!/usr/bin/env python3
+# Copyright notice
+"""Task scheduling.
+
+Runs tasks by priority.
+"""
+
+import heapq
+
+
+def schedule(tasks):
+    """Order tasks by priority."""
+    return heapq.nsmallest(len(tasks), tasks)
+"#;
+        let tree = parse_python(source);
+        let extractor = PythonExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+
+        assert_eq!(units.len(), 2);
+        assert_eq!(units[0].kind, SemanticKind::Module);
+        assert_eq!((units[0].start_line, units[0].end_line), (3, 6));
+        assert!(units[0].docs.as_ref().unwrap().contains("Runs tasks by priority."));
+        assert_eq!(units[1].kind, SemanticKind::Function);
+
+        // A string after code is not a docstring
+        let source = r#"import os
+"""Not a docstring."""
+"#;
+        let tree = parse_python(source);
+        assert!(extractor.extract(&tree, source.as_bytes()).is_empty());
+    }
+
     #[test]
     fn test_function_with_type_hints() {
         let source = r#"
@@ -0,0 +1,411 @@
+//! Ruby-specific semantic extractor.
+//!
+//! Extracts: module, class, method, singleton_method, and the Rails and
+//! test DSL calls that define code:
+//!
+//! - association and scope macros (`has_many :orders`, `scope :paid, ...`)
+//!   are methods named after the method they define (`orders`, `paid`)
+//! - route blocks (`Rails.application.routes.draw do`, `resources :users do`,
+//!   `namespace :admin do`) are blocks named by the call before the block
+//! - `test "..." do` and RSpec `it "..." do` blocks are tests named by their
+//!   description; `describe` and `context` blocks are blocks
+//!
+//! Nested modules and classes are joined into the parent (`Billing::Invoice`),
+//! so qualified names read like Ruby constants (`Billing::Invoice::total`).
+
+use tree_sitter::{Node, Tree, TreeCursor};
+
+use super::{node_text, SemanticExtractor, SemanticKind, SemanticUnit};
+
+/// Class macros defining a method named by their first argument
+const DEFINING_MACROS: &[&str] = &[
+    "has_many",
+    "has_one",
+    "belongs_to",
+    "has_and_belongs_to_many",
+    "scope",
+];
+
+/// Calls whose block groups routes or specs
+const BLOCK_CALLS: &[&str] = &[
+    "draw",
+    "namespace",
+    "resources",
+    "resource",
+    "member",
+    "collection",
+    "concern",
+    "constraints",
+    "describe",
+    "context",
+];
+
+/// Calls whose block is a single test
+const TEST_CALLS: &[&str] = &["test", "it", "specify"];
+
+/// Ruby language semantic extractor.
+pub struct RubyExtractor;
+
+impl SemanticExtractor for RubyExtractor {
+    fn language_id(&self) -> &'static str {
+        "ruby"
+    }
+
+    fn extract(&self, tree: &Tree, source: &[u8]) -> Vec<SemanticUnit> {
+        let mut units = Vec::new();
+        let mut cursor = tree.walk();
+
+        self.extract_from_node(&mut cursor, source, &mut units, None);
+
+        // Sort by start position
+        units.sort_by_key(|u| (u.start_line, u.start_byte));
+
+        units
+    }
+
+    fn target_node_types(&self) -> &[&'static str] {
+        &["module", "class", "method", "singleton_method", "call"]
+    }
+
+    fn qualified_name_separator(&self) -> &'static str {
+        "::"
+    }
+}
+
+impl RubyExtractor {
+    /// Recursively extract semantic units from the AST.
+    fn extract_from_node(
+        &self,
+        cursor: &mut TreeCursor,
+        source: &[u8],
+        units: &mut Vec<SemanticUnit>,
+        parent_context: Option<&str>,
+    ) {
+        let node = cursor.node();
+
+        // Try to extract a semantic unit from this node
+        if let Some(unit) = self.extract_unit(&node, source, parent_context) {
+            units.push(unit);
+        }
+
+        // Modules and classes nest their names
+        let new_context = match node.kind() {
+            "module" | "class" => {
+                let name = self.get_name(&node, source);
+                match (parent_context, name) {
+                    (Some(outer), Some(name)) => Some(format!("{}::{}", outer, name)),
+                    (_, name) => name,
+                }
+            }
+            _ => parent_context.map(|s| s.to_string()),
+        };
+
+        // Recurse into children
+        if cursor.goto_first_child() {
+            loop {
+                self.extract_from_node(cursor, source, units, new_context.as_deref());
+                if !cursor.goto_next_sibling() {
+                    break;
+                }
+            }
+            cursor.goto_parent();
+        }
+    }
+
+    /// Extract a semantic unit from a node if it's a target type.
+    fn extract_unit(
+        &self,
+        node: &Node,
+        source: &[u8],
+        parent_context: Option<&str>,
+    ) -> Option<SemanticUnit> {
+        let (kind, name) = match node.kind() {
+            "module" => (SemanticKind::Module, self.get_name(node, source)),
+            "class" => (SemanticKind::Class, self.get_name(node, source)),
+            "method" | "singleton_method" => {
+                let name = self.get_name(node, source);
+                let kind = if name.as_deref().is_some_and(|n| n.starts_with("test_")) {
+                    SemanticKind::Test
+                } else if parent_context.is_some() || node.kind() == "singleton_method" {
+                    SemanticKind::Method
+                } else {
+                    SemanticKind::Function
+                };
+                (kind, name)
+            }
+            "call" => self.dsl_call(node, source)?,
+            _ => return None,
+        };
+
+        let signature = self.get_signature(node, source);
+        let docs = self.get_docs(node, source);
+        let content = node_text(node, source).to_string();
+
+        Some(SemanticUnit {
+            kind,
+            name,
+            content,
+            docs,
+            start_line: node.start_position().row + 1,
+            end_line: node.end_position().row + 1,
+            start_byte: node.start_byte(),
+            end_byte: node.end_byte(),
+            signature,
+            parent: parent_context.map(|s| s.to_string()),
+        })
+    }
+
+    /// Kind and name of a DSL call, or `None` for other calls.
+    fn dsl_call(&self, node: &Node, source: &[u8]) -> Option<(SemanticKind, Option<String>)> {
+        let method = node_text(&node.child_by_field_name("method")?, source);
+        let has_block = node.child_by_field_name("block").is_some();
+
+        if DEFINING_MACROS.contains(&method) {
+            // `has_many :orders` defines `orders`
+            let name = self
+                .first_argument(node, source)?
+                .trim_start_matches(':')
+                .to_string();
+            Some((SemanticKind::Method, Some(name)))
+        } else if has_block && TEST_CALLS.contains(&method) {
+            let name = self
+                .first_argument(node, source)
+                .map(|description| description.trim_matches(['"', '\'']).to_string());
+            Some((SemanticKind::Test, name))
+        } else if has_block && BLOCK_CALLS.contains(&method) {
+            Some((SemanticKind::Block, Some(self.call_head(node, source))))
+        } else {
+            None
+        }
+    }
+
+    /// Text of a call's first argument, e.g. `:orders`.
+    fn first_argument<'a>(&self, node: &Node, source: &'a [u8]) -> Option<&'a str> {
+        let arguments = node.child_by_field_name("arguments")?;
+        let mut cursor = arguments.walk();
+        let first = arguments.named_children(&mut cursor).next()?;
+        Some(node_text(&first, source))
+    }
+
+    /// A call without its block, whitespace collapsed, e.g.
+    /// `resources :users, only: [:index]`.
+    fn call_head(&self, node: &Node, source: &[u8]) -> String {
+        let end = node
+            .child_by_field_name("block")
+            .map_or(node.end_byte(), |block| block.start_byte());
+        let head = std::str::from_utf8(&source[node.start_byte()..end]).unwrap_or("");
+        head.split_whitespace().collect::<Vec<_>>().join(" ")
+    }
+
+    /// Get the name/identifier from a node.
+    fn get_name(&self, node: &Node, source: &[u8]) -> Option<String> {
+        node.child_by_field_name("name")
+            .map(|n| node_text(&n, source).to_string())
+    }
+
+    /// Get the first line of a declaration or call: `def total(tax: 0)`,
+    /// `class Invoice < ApplicationRecord`, `has_many :orders, dependent: :destroy`.
+    fn get_signature(&self, node: &Node, source: &[u8]) -> Option<String> {
+        let text = node_text(node, source);
+        let first_line = text.lines().next()?.trim();
+        let first_line = first_line
+            .strip_suffix(" do")
+            .or_else(|| first_line.strip_suffix(" {"))
+            .unwrap_or(first_line);
+        Some(first_line.to_string())
+    }
+
+    /// Get the `#` comment lines right above a node, without markers.
+    fn get_docs(&self, node: &Node, source: &[u8]) -> Option<String> {
+        let mut docs = Vec::new();
+        let mut current = node.prev_sibling();
+        while let Some(prev) = current {
+            if prev.kind() != "comment" {
+                break;
+            }
+            docs.insert(
+                0,
+                node_text(&prev, source)
+                    .trim_start_matches('#')
+                    .trim()
+                    .to_string(),
+            );
+            current = prev.prev_sibling();
+        }
+
+        if docs.is_empty() {
+            None
+        } else {
+            Some(docs.join("\n"))
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tree_sitter::Parser;
+
+    fn parse_ruby(source: &str) -> Tree {
+        let mut parser = Parser::new();
+        parser
+            .set_language(&tree_sitter_ruby::LANGUAGE.into())
+            .expect("Failed to set Ruby language");
+        parser.parse(source, None).expect("Failed to parse")
+    }
+
+    fn units_of(source: &str) -> Vec<SemanticUnit> {
+        RubyExtractor.extract(&parse_ruby(source), source.as_bytes())
+    }
+
+    #[test]
+    fn test_nested_modules_and_methods() {
+        let source = r#"
+module Billing
+  # An issued invoice
+  class Invoice < ApplicationRecord
+    def total(tax: 0)
+      subtotal + tax
+    end
+
+    def self.overdue
+      where(paid: false)
+    end
+  end
+end
+
+def helper
+end
+"#;
+        let extractor = RubyExtractor;
+        let qualified: Vec<_> = units_of(source)
+            .iter()
+            .map(|u| {
+                let name = u.name.as_deref().unwrap();
+                (
+                    u.kind,
+                    extractor.qualified_name(None, u.parent.as_deref(), name),
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            qualified,
+            vec![
+                (SemanticKind::Module, "Billing".to_string()),
+                (SemanticKind::Class, "Billing::Invoice".to_string()),
+                (SemanticKind::Method, "Billing::Invoice::total".to_string()),
+                (
+                    SemanticKind::Method,
+                    "Billing::Invoice::overdue".to_string()
+                ),
+                (SemanticKind::Function, "helper".to_string()),
+            ]
+        );
+
+        let units = units_of(source);
+        assert_eq!(units[1].docs.as_deref(), Some("An issued invoice"));
+        assert_eq!(
+            units[1].signature.as_deref(),
+            Some("class Invoice < ApplicationRecord")
+        );
+    }
+
+    #[test]
+    fn test_association_and_scope_macros_define_methods() {
+        let source = r#"
+class Customer < ApplicationRecord
+  has_many :orders, dependent: :destroy
+  belongs_to :account
+  scope :active, -> { where(active: true) }
+  validates :email, presence: true
+end
+"#;
+        let units = units_of(source);
+        let methods: Vec<_> = units
+            .iter()
+            .filter(|u| u.kind == SemanticKind::Method)
+            .map(|u| (u.name.as_deref().unwrap(), u.parent.as_deref().unwrap()))
+            .collect();
+
+        assert_eq!(
+            methods,
+            vec![
+                ("orders", "Customer"),
+                ("account", "Customer"),
+                ("active", "Customer"),
+            ]
+        );
+        assert_eq!(
+            units[1].signature.as_deref(),
+            Some("has_many :orders, dependent: :destroy")
+        );
+    }
+
+    #[test]
+    fn test_route_blocks() {
+        let source = r#"
+Rails.application.routes.draw do
+  namespace :admin do
+    resources :users, only: [:index, :show] do
+      member do
+        post :lock
+      end
+    end
+  end
+  get "/health", to: "health#show"
+end
+"#;
+        let units = units_of(source);
+        let blocks: Vec<_> = units
+            .iter()
+            .filter(|u| u.kind == SemanticKind::Block)
+            .filter_map(|u| u.name.as_deref())
+            .collect();
+
+        assert_eq!(
+            blocks,
+            vec![
+                "Rails.application.routes.draw",
+                "namespace :admin",
+                "resources :users, only: [:index, :show]",
+                "member",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_spec_and_minitest_blocks() {
+        let source = r#"
+RSpec.describe Invoice do
+  context "when overdue" do
+    it "charges a late fee" do
+      expect(invoice.fee).to eq(5)
+    end
+  end
+end
+
+class InvoiceTest < ActiveSupport::TestCase
+  test "totals lines" do
+    assert_equal 3, invoice.total
+  end
+
+  def test_tax
+  end
+end
+"#;
+        let units = units_of(source);
+        let tests: Vec<_> = units
+            .iter()
+            .filter(|u| u.kind == SemanticKind::Test)
+            .filter_map(|u| u.name.as_deref())
+            .collect();
+
+        assert_eq!(
+            tests,
+            vec!["charges a late fee", "totals lines", "test_tax"]
+        );
+        assert!(units.iter().any(|u| u.kind == SemanticKind::Block
+            && u.name.as_deref() == Some("RSpec.describe Invoice")));
+    }
+}
@@ -3,6 +3,8 @@
 //! Extracts: function_item, impl_item, struct_item, enum_item, trait_item,
 //! mod_item, const_item, type_item, macro_definition
 
+use std::path::Path;
+
 use tree_sitter::{Node, Tree, TreeCursor};
 
 use super::{node_text, SemanticExtractor, SemanticKind, SemanticUnit};
@@ -41,6 +43,57 @@ impl SemanticExtractor for RustExtractor {
             "macro_definition",
         ]
     }
+
+    fn qualified_name_separator(&self) -> &'static str {
+        "::"
+    }
+
+    /// Module path derived from the file location below `src/`:
+    /// `src/auth/user.rs` is `crate::auth::user`, and `lib.rs`, `main.rs`
+    /// and `mod.rs` name their enclosing module.
+    fn module_path(&self, _tree: &Tree, _source: &[u8], path: &Path) -> Option<String> {
+        let components: Vec<&str> = path
+            .components()
+            .filter_map(|c| c.as_os_str().to_str())
+            .collect();
+        let start = components
+            .iter()
+            .rposition(|c| *c == "src")
+            .map(|i| i + 1)
+            .unwrap_or(components.len().saturating_sub(1));
+
+        let mut parts = vec!["crate"];
+        for (i, component) in components[start..].iter().enumerate() {
+            let is_file = start + i == components.len() - 1;
+            let part = if is_file {
+                component.strip_suffix(".rs").unwrap_or(component)
+            } else {
+                component
+            };
+            if is_file && matches!(part, "lib" | "main" | "mod") {
+                continue;
+            }
+            parts.push(part);
+        }
+        Some(parts.join("::"))
+    }
+
+    /// Items declared `pub`; restricted visibility such as `pub(crate)` is
+    /// not part of the public API. Chunk content may start with the item's
+    /// doc comments and attributes, which are skipped.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        let declaration = unit
+            .content
+            .lines()
+            .map(str::trim_start)
+            .find(|line| {
+                !line.is_empty() && !["//", "/*", "#["].iter().any(|p| line.starts_with(p))
+            })
+            .unwrap_or_default();
+        declaration
+            .strip_prefix("pub")
+            .is_some_and(|rest| rest.starts_with(char::is_whitespace))
+    }
 }
 
 impl RustExtractor {
@@ -150,6 +203,10 @@ impl RustExtractor {
     fn get_impl_target(&self, node: &Node, source: &[u8]) -> Option<String> {
         // Look for the type being implemented
         // impl Trait for Type or impl Type
+        if let Some(type_node) = node.child_by_field_name("type") {
+            return Some(node_text(&type_node, source).to_string());
+        }
+
         let mut cursor = node.walk();
         if cursor.goto_first_child() {
             loop {
@@ -313,6 +370,33 @@ mod tests {
         parser.parse(source, None).expect("Failed to parse")
     }
 
+    #[test]
+    fn test_qualified_method_name() {
+        let source = r#"
+impl fmt::Display for Stack<T> {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        Ok(())
+    }
+}
+"#;
+        let tree = parse_rust(source);
+        let extractor = RustExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let method = units.iter().find(|u| u.kind == SemanticKind::Method).unwrap();
+
+        let module = extractor.module_path(&tree, source.as_bytes(), Path::new("/repo/src/collections/stack.rs"));
+        assert_eq!(module.as_deref(), Some("crate::collections::stack"));
+        assert_eq!(
+            extractor.qualified_name(module.as_deref(), method.parent.as_deref(), "fmt"),
+            "crate::collections::stack::Stack::fmt"
+        );
+
+        let root = extractor.module_path(&tree, source.as_bytes(), Path::new("src/lib.rs"));
+        assert_eq!(root.as_deref(), Some("crate"));
+        let nested = extractor.module_path(&tree, source.as_bytes(), Path::new("src/auth/mod.rs"));
+        assert_eq!(nested.as_deref(), Some("crate::auth"));
+    }
+
     #[test]
     fn test_extract_function() {
         let source = r#"
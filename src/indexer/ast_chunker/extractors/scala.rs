@@ -0,0 +1,401 @@
+//! Scala-specific semantic extractor.
+//!
+//! Extracts: object_definition, class_definition (including case classes),
+//! trait_definition, enum_definition, function_definition, and the
+//! implicit or given values that supply context (`implicit val ec`,
+//! `given Ordering[Invoice]`)
+//!
+//! Implicit classes and defs chunk like other classes and defs, with
+//! `implicit` kept in their signature. Members of nested objects and
+//! classes have the enclosing types as parent (`Outer.Inner`), and the
+//! package clauses are the module, so qualified names read like Scala's
+//! (`com.acme.billing.Invoice.total`). A Scaladoc comment (`/** ... */`)
+//! right above a definition starts its unit and becomes its docs.
+
+use std::path::Path;
+
+use tree_sitter::{Node, Tree, TreeCursor};
+
+use super::{declaration_modifiers, node_text, SemanticExtractor, SemanticKind, SemanticUnit};
+
+/// Scala language semantic extractor.
+pub struct ScalaExtractor;
+
+impl SemanticExtractor for ScalaExtractor {
+    fn language_id(&self) -> &'static str {
+        "scala"
+    }
+
+    fn extract(&self, tree: &Tree, source: &[u8]) -> Vec<SemanticUnit> {
+        let mut units = Vec::new();
+        let mut cursor = tree.walk();
+
+        self.extract_from_node(&mut cursor, source, &mut units, None);
+
+        // Sort by start position
+        units.sort_by_key(|u| (u.start_line, u.start_byte));
+
+        units
+    }
+
+    fn target_node_types(&self) -> &[&'static str] {
+        &[
+            "object_definition",
+            "class_definition",
+            "trait_definition",
+            "enum_definition",
+            "function_definition",
+            "val_definition",
+            "given_definition",
+        ]
+    }
+
+    /// The file's package clauses joined, e.g. `com.acme.billing` for
+    /// `package com.acme` followed by `package billing`.
+    fn module_path(&self, tree: &Tree, source: &[u8], _path: &Path) -> Option<String> {
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let packages: Vec<&str> = root
+            .children(&mut cursor)
+            .filter(|child| child.kind() == "package_clause")
+            .filter_map(|clause| clause.child_by_field_name("name"))
+            .map(|name| node_text(&name, source))
+            .collect();
+        (!packages.is_empty()).then(|| packages.join("."))
+    }
+
+    /// Definitions without a `private` or `protected` modifier (including
+    /// qualified ones like `private[billing]`).
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        unit.signature.as_deref().map_or(true, |signature| {
+            !declaration_modifiers(signature)
+                .any(|word| word.starts_with("private") || word.starts_with("protected"))
+        })
+    }
+}
+
+impl ScalaExtractor {
+    /// Recursively extract semantic units from the AST.
+    fn extract_from_node(
+        &self,
+        cursor: &mut TreeCursor,
+        source: &[u8],
+        units: &mut Vec<SemanticUnit>,
+        parent_context: Option<&str>,
+    ) {
+        let node = cursor.node();
+
+        // Try to extract a semantic unit from this node
+        if let Some(unit) = self.extract_unit(&node, source, parent_context) {
+            units.push(unit);
+        }
+
+        // Objects, classes and traits nest their names
+        let new_context = match node.kind() {
+            "object_definition" | "class_definition" | "trait_definition" | "enum_definition" => {
+                let name = self.get_name(&node, source);
+                match (parent_context, name) {
+                    (Some(outer), Some(name)) => Some(format!("{}.{}", outer, name)),
+                    (_, name) => name,
+                }
+            }
+            _ => parent_context.map(|s| s.to_string()),
+        };
+
+        // Recurse into children
+        if cursor.goto_first_child() {
+            loop {
+                self.extract_from_node(cursor, source, units, new_context.as_deref());
+                if !cursor.goto_next_sibling() {
+                    break;
+                }
+            }
+            cursor.goto_parent();
+        }
+    }
+
+    /// Extract a semantic unit from a node if it's a target type.
+    fn extract_unit(
+        &self,
+        node: &Node,
+        source: &[u8],
+        parent_context: Option<&str>,
+    ) -> Option<SemanticUnit> {
+        let (kind, name) = match node.kind() {
+            "object_definition" | "class_definition" => {
+                (SemanticKind::Class, self.get_name(node, source))
+            }
+            "trait_definition" => (SemanticKind::Trait, self.get_name(node, source)),
+            "enum_definition" => (SemanticKind::Enum, self.get_name(node, source)),
+            "function_definition" => {
+                let kind = if self.is_test_function(node, source) {
+                    SemanticKind::Test
+                } else if parent_context.is_some() {
+                    SemanticKind::Method
+                } else {
+                    SemanticKind::Function
+                };
+                (kind, self.get_name(node, source))
+            }
+            // Only implicit values are chunked; other vals stay in their
+            // enclosing chunk
+            "val_definition" if self.is_implicit(node, source) => {
+                let pattern = node.child_by_field_name("pattern")?;
+                (
+                    SemanticKind::Constant,
+                    Some(node_text(&pattern, source).to_string()),
+                )
+            }
+            // An anonymous given is named by its type, e.g. `Ordering[Invoice]`
+            "given_definition" => {
+                let name = node
+                    .child_by_field_name("name")
+                    .or_else(|| node.child_by_field_name("return_type"))
+                    .map(|name| node_text(&name, source).to_string());
+                (SemanticKind::Constant, name)
+            }
+            _ => return None,
+        };
+
+        let signature = self.get_signature(node, source);
+        let scaladoc = self.get_scaladoc(node, source);
+        let start = scaladoc.unwrap_or(*node);
+        let content = std::str::from_utf8(&source[start.start_byte()..node.end_byte()])
+            .unwrap_or("")
+            .to_string();
+
+        Some(SemanticUnit {
+            kind,
+            name,
+            content,
+            docs: scaladoc.map(|comment| clean_scaladoc(node_text(&comment, source))),
+            start_line: start.start_position().row + 1,
+            end_line: node.end_position().row + 1,
+            start_byte: start.start_byte(),
+            end_byte: node.end_byte(),
+            signature,
+            parent: parent_context.map(|s| s.to_string()),
+        })
+    }
+
+    /// Get the name/identifier from a node.
+    fn get_name(&self, node: &Node, source: &[u8]) -> Option<String> {
+        node.child_by_field_name("name")
+            .map(|n| node_text(&n, source).to_string())
+    }
+
+    /// Get the definition up to its body, without annotations, whitespace
+    /// collapsed: `case class Point(x: Int, y: Int) extends Shape`,
+    /// `implicit def toRich(i: Int): RichInt`.
+    fn get_signature(&self, node: &Node, source: &[u8]) -> Option<String> {
+        let mut cursor = node.walk();
+        let start = node
+            .children(&mut cursor)
+            .find(|child| child.kind() != "annotation")?
+            .start_byte();
+        let end = node
+            .child_by_field_name("body")
+            .or_else(|| node.child_by_field_name("value"))
+            .map_or(node.end_byte(), |body| body.start_byte());
+        let declaration = std::str::from_utf8(&source[start..end]).ok()?;
+        let declaration = declaration.trim().trim_end_matches('=').trim_end();
+        Some(declaration.split_whitespace().collect::<Vec<_>>().join(" "))
+    }
+
+    /// Get the `/** ... */` comment ending on the line above a node.
+    fn get_scaladoc<'a>(&self, node: &Node<'a>, source: &[u8]) -> Option<Node<'a>> {
+        let prev = node.prev_sibling()?;
+        let adjacent = prev.end_position().row + 1 >= node.start_position().row;
+        (prev.kind().contains("comment") && adjacent && node_text(&prev, source).starts_with("/**"))
+            .then_some(prev)
+    }
+
+    /// Check if a definition has the `implicit` modifier.
+    fn is_implicit(&self, node: &Node, source: &[u8]) -> bool {
+        let mut cursor = node.walk();
+        for modifiers in node.children(&mut cursor) {
+            if modifiers.kind() == "modifiers"
+                && node_text(&modifiers, source)
+                    .split_whitespace()
+                    .any(|word| word == "implicit")
+            {
+                return true;
+            }
+        }
+        false
+    }
+
+    /// Check if a def is a JUnit test, annotated `@Test`.
+    fn is_test_function(&self, node: &Node, source: &[u8]) -> bool {
+        let mut cursor = node.walk();
+        for annotation in node.children(&mut cursor) {
+            if annotation.kind() != "annotation" {
+                continue;
+            }
+            let name = node_text(&annotation, source).trim_start_matches('@');
+            let name = name.split('(').next().unwrap_or(name);
+            if name.rsplit('.').next() == Some("Test") {
+                return true;
+            }
+        }
+        false
+    }
+}
+
+/// Text of a Scaladoc comment without the `/**`, `*` and `*/` markers.
+fn clean_scaladoc(comment: &str) -> String {
+    comment
+        .trim_start_matches("/**")
+        .trim_end_matches("*/")
+        .lines()
+        .map(|line| line.trim().trim_start_matches('*').trim())
+        .filter(|line| !line.is_empty())
+        .collect::<Vec<_>>()
+        .join("\n")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tree_sitter::Parser;
+
+    fn parse_scala(source: &str) -> Tree {
+        let mut parser = Parser::new();
+        parser
+            .set_language(&tree_sitter_scala::LANGUAGE.into())
+            .expect("Failed to set Scala language");
+        parser.parse(source, None).expect("Failed to parse")
+    }
+
+    #[test]
+    fn test_objects_traits_and_case_classes() {
+        let source = r#"
+package com.acme
+package billing
+
+sealed trait Shape {
+  def area: Double
+}
+
+/** A point in the plane. */
+case class Point(x: Double, y: Double) extends Shape {
+  def area: Double = 0.0
+}
+
+object Geometry {
+  def origin(): Point = Point(0, 0)
+
+  private def scale(p: Point, k: Double): Point = Point(p.x * k, p.y * k)
+}
+"#;
+        let tree = parse_scala(source);
+        let extractor = ScalaExtractor;
+        let units = extractor.extract(&tree, source.as_bytes());
+        let module = extractor.module_path(&tree, source.as_bytes(), Path::new("Shapes.scala"));
+        assert_eq!(module.as_deref(), Some("com.acme.billing"));
+
+        let qualified: Vec<_> = units
+            .iter()
+            .map(|u| {
+                let name = u.name.as_deref().unwrap();
+                (
+                    u.kind,
+                    extractor.qualified_name(module.as_deref(), u.parent.as_deref(), name),
+                )
+            })
+            .collect();
+        assert_eq!(
+            qualified,
+            vec![
+                (SemanticKind::Trait, "com.acme.billing.Shape".to_string()),
+                (SemanticKind::Class, "com.acme.billing.Point".to_string()),
+                (
+                    SemanticKind::Method,
+                    "com.acme.billing.Point.area".to_string()
+                ),
+                (SemanticKind::Class, "com.acme.billing.Geometry".to_string()),
+                (
+                    SemanticKind::Method,
+                    "com.acme.billing.Geometry.origin".to_string()
+                ),
+                (
+                    SemanticKind::Method,
+                    "com.acme.billing.Geometry.scale".to_string()
+                ),
+            ]
+        );
+
+        let point = &units[1];
+        assert_eq!(point.docs.as_deref(), Some("A point in the plane."));
+        assert!(point.content.starts_with("/**"));
+        assert_eq!(
+            point.signature.as_deref(),
+            Some("case class Point(x: Double, y: Double) extends Shape")
+        );
+        assert!(extractor.is_exported(&units[4]));
+        assert!(!extractor.is_exported(&units[5]));
+    }
+
+    #[test]
+    fn test_implicit_definitions() {
+        let source = r#"
+object Implicits {
+  implicit val ec: ExecutionContext = ExecutionContext.global
+
+  val timeout = 5
+
+  implicit class RichInvoice(val invoice: Invoice) {
+    def isOverdue: Boolean = false
+  }
+
+  implicit def toCents(amount: BigDecimal): Long = (amount * 100).toLong
+}
+"#;
+        let tree = parse_scala(source);
+        let units = ScalaExtractor.extract(&tree, source.as_bytes());
+        let named: Vec<_> = units
+            .iter()
+            .map(|u| (u.kind, u.name.as_deref().unwrap()))
+            .collect();
+
+        assert_eq!(
+            named,
+            vec![
+                (SemanticKind::Class, "Implicits"),
+                (SemanticKind::Constant, "ec"),
+                (SemanticKind::Class, "RichInvoice"),
+                (SemanticKind::Method, "isOverdue"),
+                (SemanticKind::Method, "toCents"),
+            ]
+        );
+        assert_eq!(
+            units[1].signature.as_deref(),
+            Some("implicit val ec: ExecutionContext")
+        );
+        assert_eq!(
+            units[4].signature.as_deref(),
+            Some("implicit def toCents(amount: BigDecimal): Long")
+        );
+    }
+
+    #[test]
+    fn test_extract_junit_tests() {
+        let source = r#"
+class InvoiceSpec {
+  @Test
+  def totalsLines(): Unit = {}
+
+  def helper(): Invoice = Invoice()
+}
+"#;
+        let tree = parse_scala(source);
+        let units = ScalaExtractor.extract(&tree, source.as_bytes());
+        let tests: Vec<_> = units
+            .iter()
+            .filter(|u| u.kind == SemanticKind::Test)
+            .filter_map(|u| u.name.as_deref())
+            .collect();
+
+        assert_eq!(tests, vec!["totalsLines"]);
+    }
+}
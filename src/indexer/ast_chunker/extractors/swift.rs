@@ -0,0 +1,437 @@
+//! Swift-specific semantic extractor.
+//!
+//! Extracts: class_declaration (classes, structs, enums, actors and
+//! extensions), protocol_declaration, function_declaration, init_declaration
+//!
+//! Extensions are impls of the type they extend, and their members have
+//! that type as parent, so `extension Invoice { func total() }` qualifies
+//! `total` like a method declared in `Invoice`. Property wrappers are the
+//! structs or classes marked `@propertyWrapper` and chunk like any other
+//! type. Doc comments (`///` lines or a `/** ... */` block) right above a
+//! declaration start its unit and become its docs.
+
+use std::path::Path;
+
+use tree_sitter::{Node, Tree, TreeCursor};
+
+use super::{declaration_modifiers, node_text, SemanticExtractor, SemanticKind, SemanticUnit};
+
+/// Swift language semantic extractor.
+pub struct SwiftExtractor;
+
+impl SemanticExtractor for SwiftExtractor {
+    fn language_id(&self) -> &'static str {
+        "swift"
+    }
+
+    fn extract(&self, tree: &Tree, source: &[u8]) -> Vec<SemanticUnit> {
+        let mut units = Vec::new();
+        let mut cursor = tree.walk();
+
+        self.extract_from_node(&mut cursor, source, &mut units, None);
+
+        // Sort by start position
+        units.sort_by_key(|u| (u.start_line, u.start_byte));
+
+        units
+    }
+
+    fn target_node_types(&self) -> &[&'static str] {
+        &[
+            "class_declaration",
+            "protocol_declaration",
+            "function_declaration",
+            "init_declaration",
+        ]
+    }
+
+    /// The Swift package target a file belongs to: the directory below
+    /// `Sources/` or `Tests/` (`Sources/Billing/Invoice.swift` is in
+    /// `Billing`).
+    fn module_path(&self, _tree: &Tree, _source: &[u8], path: &Path) -> Option<String> {
+        let components: Vec<&str> = path
+            .components()
+            .filter_map(|component| component.as_os_str().to_str())
+            .collect();
+        let root = components
+            .iter()
+            .rposition(|&component| component == "Sources" || component == "Tests")?;
+        // The target directory, not the file itself
+        (root + 2 < components.len()).then(|| components[root + 1].to_string())
+    }
+
+    /// Declarations with the `public` or `open` modifier; Swift
+    /// declarations are internal by default.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        unit.signature.as_deref().is_some_and(|signature| {
+            declaration_modifiers(signature).any(|word| word == "public" || word == "open")
+        })
+    }
+}
+
+impl SwiftExtractor {
+    /// Recursively extract semantic units from the AST.
+    fn extract_from_node(
+        &self,
+        cursor: &mut TreeCursor,
+        source: &[u8],
+        units: &mut Vec<SemanticUnit>,
+        parent_context: Option<&str>,
+    ) {
+        let node = cursor.node();
+
+        // Try to extract a semantic unit from this node
+        if let Some(unit) = self.extract_unit(&node, source, parent_context) {
+            units.push(unit);
+        }
+
+        // Types nest their names; an extension's members belong to the
+        // extended type
+        let new_context = match node.kind() {
+            "class_declaration" if self.declaration_kind(&node, source) == Some("extension") => {
+                self.get_name(&node, source)
+            }
+            "class_declaration" | "protocol_declaration" => {
+                let name = self.get_name(&node, source);
+                match (parent_context, name) {
+                    (Some(outer), Some(name)) => Some(format!("{}.{}", outer, name)),
+                    (_, name) => name,
+                }
+            }
+            _ => parent_context.map(|s| s.to_string()),
+        };
+
+        // Recurse into children
+        if cursor.goto_first_child() {
+            loop {
+                self.extract_from_node(cursor, source, units, new_context.as_deref());
+                if !cursor.goto_next_sibling() {
+                    break;
+                }
+            }
+            cursor.goto_parent();
+        }
+    }
+
+    /// Extract a semantic unit from a node if it's a target type.
+    fn extract_unit(
+        &self,
+        node: &Node,
+        source: &[u8],
+        parent_context: Option<&str>,
+    ) -> Option<SemanticUnit> {
+        let name = match node.kind() {
+            "init_declaration" => Some("init".to_string()),
+            _ => self.get_name(node, source),
+        };
+
+        let (kind, parent) = match node.kind() {
+            "class_declaration" => match self.declaration_kind(node, source)? {
+                // An extension is not nested in the type it extends
+                "extension" => (SemanticKind::Impl, None),
+                "struct" => (SemanticKind::Struct, parent_context),
+                "enum" => (SemanticKind::Enum, parent_context),
+                _ => (SemanticKind::Class, parent_context),
+            },
+            "protocol_declaration" => (SemanticKind::Interface, parent_context),
+            "function_declaration"
+                if self.is_test_function(node, source, &name, parent_context) =>
+            {
+                (SemanticKind::Test, parent_context)
+            }
+            "function_declaration" if parent_context.is_none() => (SemanticKind::Function, None),
+            "function_declaration" | "init_declaration" => (SemanticKind::Method, parent_context),
+            _ => return None,
+        };
+
+        let docs = self.get_doc_comments(node, source);
+        let start = docs.first().copied().unwrap_or(*node);
+        let content = std::str::from_utf8(&source[start.start_byte()..node.end_byte()])
+            .unwrap_or("")
+            .to_string();
+        let docs = (!docs.is_empty()).then(|| {
+            docs.iter()
+                .map(|comment| clean_doc_comment(node_text(comment, source)))
+                .collect::<Vec<_>>()
+                .join("\n")
+        });
+
+        Some(SemanticUnit {
+            kind,
+            name,
+            content,
+            docs,
+            start_line: start.start_position().row + 1,
+            end_line: node.end_position().row + 1,
+            start_byte: start.start_byte(),
+            end_byte: node.end_byte(),
+            signature: self.get_signature(node, source),
+            parent: parent.map(|s| s.to_string()),
+        })
+    }
+
+    /// Get the name/identifier from a node.
+    fn get_name(&self, node: &Node, source: &[u8]) -> Option<String> {
+        node.child_by_field_name("name")
+            .map(|n| node_text(&n, source).to_string())
+    }
+
+    /// The keyword a `class_declaration` starts with: `class`, `struct`,
+    /// `enum`, `actor` or `extension`.
+    fn declaration_kind<'a>(&self, node: &Node, source: &'a [u8]) -> Option<&'a str> {
+        node.child_by_field_name("declaration_kind")
+            .map(|keyword| node_text(&keyword, source))
+    }
+
+    /// Get the declaration up to its body, without attributes,
+    /// whitespace collapsed: `public func total(tax: Decimal) -> Decimal`,
+    /// `extension Invoice: Payable`.
+    fn get_signature(&self, node: &Node, source: &[u8]) -> Option<String> {
+        let end = node
+            .child_by_field_name("body")
+            .map_or(node.end_byte(), |body| body.start_byte());
+        let declaration = std::str::from_utf8(&source[node.start_byte()..end]).ok()?;
+        let declaration = skip_attributes(declaration.trim());
+        Some(declaration.split_whitespace().collect::<Vec<_>>().join(" "))
+    }
+
+    /// Get the doc comments right above a node: consecutive `///` lines or
+    /// one `/** ... */` block.
+    fn get_doc_comments<'a>(&self, node: &Node<'a>, source: &[u8]) -> Vec<Node<'a>> {
+        let mut comments = Vec::new();
+        let mut next_row = node.start_position().row;
+        let mut current = node.prev_sibling();
+        while let Some(prev) = current {
+            let text = node_text(&prev, source);
+            let is_doc = match prev.kind() {
+                "comment" => text.starts_with("///"),
+                "multiline_comment" => comments.is_empty() && text.starts_with("/**"),
+                _ => false,
+            };
+            if !is_doc || prev.end_position().row + 1 < next_row {
+                break;
+            }
+            comments.insert(0, prev);
+            if prev.kind() == "multiline_comment" {
+                break;
+            }
+            next_row = prev.start_position().row;
+            current = prev.prev_sibling();
+        }
+        comments
+    }
+
+    /// Check if a function is a test: an XCTest method (`func test...()`
+    /// in a test case class) or marked with swift-testing's `@Test`.
+    fn is_test_function(
+        &self,
+        node: &Node,
+        source: &[u8],
+        name: &Option<String>,
+        parent_context: Option<&str>,
+    ) -> bool {
+        let xctest = parent_context.is_some()
+            && name.as_deref().is_some_and(|name| name.starts_with("test"));
+        xctest
+            || node_text(node, source)
+                .split_whitespace()
+                .take_while(|word| *word != "func")
+                .any(|word| word == "@Test" || word.starts_with("@Test("))
+    }
+}
+
+/// `text` without leading attributes (`@MainActor`, `@available(iOS 15, *)`).
+fn skip_attributes(mut text: &str) -> &str {
+    while let Some(rest) = text.strip_prefix('@') {
+        let name_end = rest
+            .find(|c: char| !(c.is_alphanumeric() || c == '_' || c == '.'))
+            .unwrap_or(rest.len());
+        let mut rest = &rest[name_end..];
+        if rest.starts_with('(') {
+            let mut depth = 0;
+            let mut end = rest.len();
+            for (i, c) in rest.char_indices() {
+                match c {
+                    '(' => depth += 1,
+                    ')' => {
+                        depth -= 1;
+                        if depth == 0 {
+                            end = i + 1;
+                            break;
+                        }
+                    }
+                    _ => {}
+                }
+            }
+            rest = &rest[end..];
+        }
+        text = rest.trim_start();
+    }
+    text
+}
+
+/// Text of a `///` line or `/** ... */` block without the comment markers.
+fn clean_doc_comment(comment: &str) -> String {
+    if let Some(line) = comment.strip_prefix("///") {
+        return line.trim().to_string();
+    }
+    comment
+        .trim_start_matches("/**")
+        .trim_end_matches("*/")
+        .lines()
+        .map(|line| line.trim().trim_start_matches('*').trim())
+        .filter(|line| !line.is_empty())
+        .collect::<Vec<_>>()
+        .join("\n")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tree_sitter::Parser;
+
+    fn parse_swift(source: &str) -> Tree {
+        let mut parser = Parser::new();
+        parser
+            .set_language(&tree_sitter_swift::LANGUAGE.into())
+            .expect("Failed to set Swift language");
+        parser.parse(source, None).expect("Failed to parse")
+    }
+
+    fn units_of(source: &str) -> Vec<SemanticUnit> {
+        SwiftExtractor.extract(&parse_swift(source), source.as_bytes())
+    }
+
+    #[test]
+    fn test_types_and_extensions() {
+        let source = r#"
+protocol Payable {
+    func pay() async throws
+}
+
+public struct Invoice {
+    enum Status { case open, paid }
+
+    public init(id: String) {}
+}
+
+extension Invoice: Payable {
+    func pay() async throws {}
+}
+
+final class InvoiceStore {}
+
+func formatCents(_ cents: Int) -> String { "" }
+"#;
+        let units = units_of(source);
+        let extractor = SwiftExtractor;
+        let kinds: Vec<_> = units
+            .iter()
+            .map(|u| {
+                let name = u.name.as_deref().unwrap();
+                (
+                    u.kind,
+                    extractor.qualified_name(None, u.parent.as_deref(), name),
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            kinds,
+            vec![
+                (SemanticKind::Interface, "Payable".to_string()),
+                (SemanticKind::Struct, "Invoice".to_string()),
+                (SemanticKind::Enum, "Invoice.Status".to_string()),
+                (SemanticKind::Method, "Invoice.init".to_string()),
+                (SemanticKind::Impl, "Invoice".to_string()),
+                (SemanticKind::Method, "Invoice.pay".to_string()),
+                (SemanticKind::Class, "InvoiceStore".to_string()),
+                (SemanticKind::Function, "formatCents".to_string()),
+            ]
+        );
+        assert_eq!(
+            units[4].signature.as_deref(),
+            Some("extension Invoice: Payable")
+        );
+        assert!(extractor.is_exported(&units[1]));
+        assert!(!extractor.is_exported(&units[6]));
+    }
+
+    #[test]
+    fn test_doc_comments_start_units() {
+        let source = r#"
+// Not a doc comment
+
+/// Clamps a value to a range.
+/// Used for sliders.
+@propertyWrapper
+struct Clamped<Value: Comparable> {
+    var wrappedValue: Value
+
+    /**
+     * The allowed range.
+     */
+    @available(iOS 15, *)
+    func range() -> ClosedRange<Value> { wrappedValue...wrappedValue }
+}
+"#;
+        let units = units_of(source);
+
+        let clamped = &units[0];
+        assert_eq!(clamped.kind, SemanticKind::Struct);
+        assert_eq!(
+            clamped.docs.as_deref(),
+            Some("Clamps a value to a range.\nUsed for sliders.")
+        );
+        assert_eq!(clamped.start_line, 4);
+        assert_eq!(
+            clamped.signature.as_deref(),
+            Some("struct Clamped<Value: Comparable>")
+        );
+
+        let range = &units[1];
+        assert_eq!(range.docs.as_deref(), Some("The allowed range."));
+        assert!(range.content.starts_with("/**"));
+        assert_eq!(
+            range.signature.as_deref(),
+            Some("func range() -> ClosedRange<Value>")
+        );
+    }
+
+    #[test]
+    fn test_extract_test_functions() {
+        let source = r#"
+final class InvoiceTests: XCTestCase {
+    func testTotal() {}
+
+    func makeInvoice() -> Invoice { Invoice(id: "1") }
+}
+
+@Test("rounds totals")
+func roundsTotals() {}
+"#;
+        let tests: Vec<_> = units_of(source)
+            .into_iter()
+            .filter(|u| u.kind == SemanticKind::Test)
+            .filter_map(|u| u.name)
+            .collect();
+
+        assert_eq!(tests, vec!["testTotal", "roundsTotals"]);
+    }
+
+    #[test]
+    fn test_module_is_package_target() {
+        let tree = parse_swift("struct Invoice {}\n");
+        let module = |path: &str| SwiftExtractor.module_path(&tree, b"", Path::new(path));
+
+        assert_eq!(
+            module("Sources/Billing/Invoice.swift").as_deref(),
+            Some("Billing")
+        );
+        assert_eq!(
+            module("/repo/Tests/BillingTests/InvoiceTests.swift").as_deref(),
+            Some("BillingTests")
+        );
+        assert_eq!(module("App/Invoice.swift"), None);
+    }
+}
@@ -5,7 +5,10 @@
 
 use tree_sitter::{Node, Tree, TreeCursor};
 
-use super::{node_text, SemanticExtractor, SemanticKind, SemanticUnit};
+use super::{
+    declaration_modifiers, node_text, skip_leading_comments, SemanticExtractor, SemanticKind,
+    SemanticUnit,
+};
 
 /// TypeScript/JavaScript language semantic extractor.
 pub struct TypeScriptExtractor {
@@ -58,6 +61,23 @@ impl SemanticExtractor for TypeScriptExtractor {
             "export_statement",
         ]
     }
+
+    /// Top-level declarations under `export`, and class members that are
+    /// not `private`, `protected` or `#private`.
+    fn is_exported(&self, unit: &SemanticUnit) -> bool {
+        // Chunks of documented symbols start at their comments
+        let declaration = skip_leading_comments(&unit.content);
+        if unit.parent.is_none() {
+            return declaration.starts_with("export");
+        }
+        let private =
+            declaration_modifiers(declaration).any(|word| matches!(word, "private" | "protected"));
+        let hash_private = unit
+            .name
+            .as_deref()
+            .is_some_and(|name| name.starts_with('#'));
+        !private && !hash_private
+    }
 }
 
 impl TypeScriptExtractor {
@@ -74,9 +94,27 @@ impl TypeScriptExtractor {
         // Handle export statements specially
         if node.kind() == "export_statement" {
             if let Some(unit) = self.extract_export(&node, source, parent_context) {
+                let class = match unit.kind {
+                    SemanticKind::Class => unit.name.clone(),
+                    _ => None,
+                };
                 units.push(unit);
+                // Members of an exported class are units of their own
+                if let (Some(class), Some(declaration)) =
+                    (class, node.child_by_field_name("declaration"))
+                {
+                    let mut class_cursor = declaration.walk();
+                    if class_cursor.goto_first_child() {
+                        loop {
+                            self.extract_from_node(&mut class_cursor, source, units, Some(&class));
+                            if !class_cursor.goto_next_sibling() {
+                                break;
+                            }
+                        }
+                    }
+                }
             }
-            // Don't recurse, we've handled it
+            // Don't recurse further, we've handled it
             return;
         }
 
@@ -224,7 +262,8 @@ impl TypeScriptExtractor {
         source: &[u8],
         parent_context: Option<&str>,
     ) -> Option<SemanticUnit> {
-        // Look for: const name = () => {} or const name = function() {}
+        // Look for: const name = () => {} or const name = function() {}, also
+        // wrapped in a call such as memo(() => ...) or forwardRef(...)
         let mut cursor = node.walk();
         if cursor.goto_first_child() {
             loop {
@@ -232,34 +271,32 @@ impl TypeScriptExtractor {
                 if child.kind() == "variable_declarator" {
                     // Check if the value is an arrow function or function expression
                     let name = child.child_by_field_name("name");
-                    let value = child.child_by_field_name("value");
-
-                    if let (Some(name_node), Some(value_node)) = (name, value) {
-                        if value_node.kind() == "arrow_function"
-                            || value_node.kind() == "function"
-                        {
-                            let func_name = node_text(&name_node, source).to_string();
-                            let kind = if self.is_test_function(&Some(func_name.clone())) {
-                                SemanticKind::Test
-                            } else if parent_context.is_some() {
-                                SemanticKind::Method
-                            } else {
-                                SemanticKind::Function
-                            };
-
-                            return Some(SemanticUnit {
-                                kind,
-                                name: Some(func_name),
-                                content: node_text(node, source).to_string(),
-                                docs: self.get_jsdoc(node, source),
-                                start_line: node.start_position().row + 1,
-                                end_line: node.end_position().row + 1,
-                                start_byte: node.start_byte(),
-                                end_byte: node.end_byte(),
-                                signature: self.get_arrow_signature(&name_node, &value_node, source),
-                                parent: parent_context.map(|s| s.to_string()),
-                            });
-                        }
+                    let function = child
+                        .child_by_field_name("value")
+                        .and_then(|value| self.function_value(&value));
+
+                    if let (Some(name_node), Some(value_node)) = (name, function) {
+                        let func_name = node_text(&name_node, source).to_string();
+                        let kind = if self.is_test_function(&Some(func_name.clone())) {
+                            SemanticKind::Test
+                        } else if parent_context.is_some() {
+                            SemanticKind::Method
+                        } else {
+                            SemanticKind::Function
+                        };
+
+                        return Some(SemanticUnit {
+                            kind,
+                            name: Some(func_name),
+                            content: node_text(node, source).to_string(),
+                            docs: self.get_jsdoc(node, source),
+                            start_line: node.start_position().row + 1,
+                            end_line: node.end_position().row + 1,
+                            start_byte: node.start_byte(),
+                            end_byte: node.end_byte(),
+                            signature: self.get_arrow_signature(&name_node, &value_node, source),
+                            parent: parent_context.map(|s| s.to_string()),
+                        });
                     }
                 }
                 if !cursor.goto_next_sibling() {
@@ -270,6 +307,25 @@ impl TypeScriptExtractor {
         None
     }
 
+    /// The function a variable is initialized with: an arrow function or
+    /// function expression, or one passed first to a wrapping call
+    /// (`memo(() => ...)`, `forwardRef(function Input(props, ref) {...})`).
+    fn function_value<'a>(&self, value: &Node<'a>) -> Option<Node<'a>> {
+        match value.kind() {
+            "arrow_function" | "function" | "function_expression" => Some(*value),
+            "call_expression" => {
+                let arguments = value.child_by_field_name("arguments")?;
+                let first = arguments.named_child(0)?;
+                matches!(
+                    first.kind(),
+                    "arrow_function" | "function" | "function_expression"
+                )
+                .then_some(first)
+            }
+            _ => None,
+        }
+    }
+
     /// Get the name/identifier from a node.
     fn get_name(&self, node: &Node, source: &[u8]) -> Option<String> {
         match node.kind() {
@@ -399,6 +455,14 @@ mod tests {
         parser.parse(source, None).expect("Failed to parse")
     }
 
+    fn parse_tsx(source: &str) -> Tree {
+        let mut parser = Parser::new();
+        parser
+            .set_language(&tree_sitter_typescript::LANGUAGE_TSX.into())
+            .expect("Failed to set TSX language");
+        parser.parse(source, None).expect("Failed to parse")
+    }
+
     fn parse_javascript(source: &str) -> Tree {
         let mut parser = Parser::new();
         parser
@@ -495,6 +559,36 @@ const greet = (name: string): string => {
         assert_eq!(units[0].name, Some("greet".to_string()));
     }
 
+    #[test]
+    fn test_extract_react_components() {
+        let source = r#"
+const Badge = memo(({ label }: { label: string }) => <span>{label}</span>);
+
+export const Input = forwardRef<HTMLInputElement, Props>(function Input(props, ref) {
+    return <input ref={ref} {...props} />;
+});
+
+export const Card = ({ title }: { title: string }) => {
+    return <div className="card"><Badge label={title} /></div>;
+};
+
+const handlers = [onClick, onHover];
+"#;
+        let tree = parse_tsx(source);
+        assert!(!tree.root_node().has_error());
+        let extractor = TypeScriptExtractor::new(false);
+        let units = extractor.extract(&tree, source.as_bytes());
+
+        let names: Vec<_> = units.iter().filter_map(|u| u.name.as_deref()).collect();
+        assert_eq!(names, vec!["Badge", "Input", "Card"]);
+        assert!(units.iter().all(|u| u.kind == SemanticKind::Function));
+        assert_eq!(
+            units[0].signature.as_deref(),
+            Some("const Badge = ({ label }: { label: string }) =>")
+        );
+        assert!(units[1].content.starts_with("export const Input"));
+    }
+
     #[test]
     fn test_extract_exported_function() {
         let source = r#"
@@ -0,0 +1,164 @@
+//! Chunk granularity by language and path
+//!
+//! `[[indexer.granularity]]` rules choose how coarse the chunks of a file
+//! are. The first rule whose languages and path globs match a file decides;
+//! files no rule matches are chunked by function.
+
+use std::path::{Path, PathBuf};
+
+use anyhow::{Context, Result};
+use glob::{MatchOptions, Pattern};
+use serde::{Deserialize, Serialize};
+
+use crate::config::GranularityRule;
+
+/// How coarse the chunks of a file are
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Granularity {
+    /// One chunk per function, method, type and other symbol
+    #[default]
+    Function,
+    /// One chunk per top-level symbol: methods stay in their class or impl
+    /// block
+    Type,
+    /// One chunk per file, for files that fit in one chunk; larger files are
+    /// chunked by function
+    File,
+}
+
+impl Granularity {
+    /// Parse granularity from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "function" => Some(Self::Function),
+            "type" => Some(Self::Type),
+            "file" => Some(Self::File),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Function => "function",
+            Self::Type => "type",
+            Self::File => "file",
+        }
+    }
+}
+
+/// Globs match paths relative to the root, with `*` staying within a
+/// directory and `**` crossing them
+const MATCH_OPTIONS: MatchOptions = MatchOptions {
+    case_sensitive: true,
+    require_literal_separator: true,
+    require_literal_leading_dot: false,
+};
+
+/// A rule with its path globs compiled
+#[derive(Debug, Clone)]
+struct Rule {
+    languages: Vec<String>,
+    paths: Vec<Pattern>,
+    level: Granularity,
+}
+
+/// Granularity rules of an indexed root
+#[derive(Debug, Clone, Default)]
+pub struct GranularityRules {
+    root: PathBuf,
+    rules: Vec<Rule>,
+}
+
+impl GranularityRules {
+    /// Compile `rules` for files under `root`.
+    pub fn new(root: &Path, rules: &[GranularityRule]) -> Result<Self> {
+        let rules = rules
+            .iter()
+            .map(|rule| {
+                let paths = rule
+                    .paths
+                    .iter()
+                    .map(|glob| {
+                        Pattern::new(glob)
+                            .with_context(|| format!("Invalid granularity path glob: {}", glob))
+                    })
+                    .collect::<Result<Vec<_>>>()?;
+                Ok(Rule {
+                    languages: rule.languages.iter().map(|l| l.to_lowercase()).collect(),
+                    paths,
+                    level: rule.level,
+                })
+            })
+            .collect::<Result<Vec<_>>>()?;
+        Ok(Self {
+            root: root.to_path_buf(),
+            rules,
+        })
+    }
+
+    /// Granularity of the file at `path` in `language`: that of the first
+    /// rule matching it, or [`Granularity::Function`].
+    pub fn level(&self, path: &Path, language: &str) -> Granularity {
+        let relative = path.strip_prefix(&self.root).unwrap_or(path);
+        self.rules
+            .iter()
+            .find(|rule| {
+                (rule.languages.is_empty() || rule.languages.iter().any(|l| l == language))
+                    && (rule.paths.is_empty()
+                        || rule
+                            .paths
+                            .iter()
+                            .any(|glob| glob.matches_path_with(relative, MATCH_OPTIONS)))
+            })
+            .map_or(Granularity::Function, |rule| rule.level)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn rule(languages: &[&str], paths: &[&str], level: Granularity) -> GranularityRule {
+        GranularityRule {
+            languages: languages.iter().map(|s| s.to_string()).collect(),
+            paths: paths.iter().map(|s| s.to_string()).collect(),
+            level,
+        }
+    }
+
+    #[test]
+    fn test_first_matching_rule_decides() {
+        let rules = GranularityRules::new(
+            Path::new("/repo"),
+            &[
+                rule(&[], &["internal/util/**"], Granularity::File),
+                rule(&["Java"], &[], Granularity::Type),
+                rule(&["go"], &["*.go"], Granularity::File),
+            ],
+        )
+        .unwrap();
+
+        let level = |path: &str, language: &str| rules.level(Path::new(path), language);
+        assert_eq!(
+            level("/repo/internal/util/strings/trim.go", "go"),
+            Granularity::File
+        );
+        assert_eq!(level("/repo/src/Main.java", "java"), Granularity::Type);
+        // `*` does not cross directories
+        assert_eq!(level("/repo/main.go", "go"), Granularity::File);
+        assert_eq!(level("/repo/cmd/main.go", "go"), Granularity::Function);
+        assert_eq!(level("/repo/src/lib.rs", "rust"), Granularity::Function);
+    }
+
+    #[test]
+    fn test_invalid_glob_is_an_error() {
+        let error = GranularityRules::new(
+            Path::new("/repo"),
+            &[rule(&[], &["src/[a"], Granularity::File)],
+        )
+        .unwrap_err();
+        assert!(error.to_string().contains("src/[a"));
+    }
+}
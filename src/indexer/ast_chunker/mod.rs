@@ -5,18 +5,49 @@
 //! (functions, classes, structs, etc.) rather than arbitrary line-based chunks.
 
 pub mod extractors;
+pub mod grammars;
+pub mod granularity;
 pub mod parser_pool;
+pub mod token_roles;
 
+use std::collections::{HashMap, HashSet};
 use std::path::Path;
+use std::sync::Arc;
 
+use anyhow::Result;
+use serde::{Deserialize, Serialize};
 use tracing::{debug, warn};
+use tree_sitter::{Node, Tree};
 
+use crate::config::{GrammarConfig, GranularityRule};
+use crate::embeddings::Tokenizer;
 use crate::indexer::chunker::Chunker;
-use crate::indexer::Chunk;
+use crate::indexer::{
+    concurrency, config_files, dockerfile, dotenv, generated, graphql, markdown, notebook, openapi,
+    protobuf, sfc, shell, sql, struct_tags, terraform, Chunk,
+};
 
+use extractors::normalize_parent;
 pub use extractors::{ExtractorRegistry, SemanticExtractor, SemanticKind, SemanticUnit};
+pub use granularity::{Granularity, GranularityRules};
 pub use parser_pool::ParserPool;
 
+/// Chunkers of structured documents, tried in order before source code
+/// chunking. Each returns `None` for files it does not handle.
+const DOCUMENT_CHUNKERS: &[fn(&Path, &str) -> Option<Vec<Chunk>>] = &[
+    openapi::chunk_spec,
+    config_files::chunk_file,
+    dotenv::chunk_file,
+    sql::chunk_file,
+    terraform::chunk_file,
+    protobuf::chunk_file,
+    graphql::chunk_file,
+    markdown::chunk_file,
+    notebook::chunk_file,
+    dockerfile::chunk_file,
+    shell::chunk_file,
+];
+
 /// Statistics from a chunking operation
 #[derive(Debug, Default, Clone)]
 pub struct ChunkingStats {
@@ -28,6 +59,45 @@ pub struct ChunkingStats {
     pub units_merged: usize,
     /// Number of fallback (line-based) chunks created
     pub fallback_chunks: usize,
+    /// Whether the file had more symbols than the per-file cap
+    pub symbol_cap_exceeded: bool,
+}
+
+/// Default maximum number of symbols indexed per file
+pub const DEFAULT_MAX_SYMBOLS_PER_FILE: usize = 5000;
+
+/// Default size small units are merged up to, in tokens
+pub const DEFAULT_MAX_MERGED_TOKENS: usize = 256;
+
+/// What to do with a file that has more symbols than the per-file cap.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "kebab-case")]
+pub enum SymbolCapPolicy {
+    /// Index only the file's top-level exported symbols, as docs plus
+    /// signature, up to the cap
+    #[default]
+    Summarize,
+    /// Skip the file entirely
+    Skip,
+}
+
+impl SymbolCapPolicy {
+    /// Parse policy from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "summarize" => Some(Self::Summarize),
+            "skip" => Some(Self::Skip),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Summarize => "summarize",
+            Self::Skip => "skip",
+        }
+    }
 }
 
 /// The method used for chunking a file
@@ -53,12 +123,27 @@ pub struct AstChunker {
     parser_pool: ParserPool,
     /// Registry of language-specific extractors
     extractors: ExtractorRegistry,
-    /// Fallback line-based chunker
+    /// Fallback line-based chunker, for files without a parser and code
+    /// without statements to split between
     fallback: Chunker,
     /// Minimum chunk size in approximate tokens (smaller units get merged)
     min_chunk_tokens: usize,
     /// Maximum chunk size in approximate tokens (larger units use line chunking)
     max_chunk_tokens: usize,
+    /// Size in tokens that consecutive small units are merged up to
+    max_merged_tokens: usize,
+    /// Tokenizer matching the embedding model, if configured
+    tokenizer: Option<Arc<dyn Tokenizer>>,
+    /// Emit only the signatures and docs of exported symbols
+    api_surface: bool,
+    /// Files with more symbols than this are summarized or skipped
+    max_symbols: usize,
+    /// What to do with files over `max_symbols`
+    symbol_cap_policy: SymbolCapPolicy,
+    /// Languages of runtime grammars, by file extension
+    grammar_extensions: HashMap<String, String>,
+    /// Chunk granularity by language and path
+    granularity: GranularityRules,
     /// Statistics from last chunking operation
     last_stats: ChunkingStats,
 }
@@ -85,10 +170,92 @@ impl AstChunker {
             fallback: Chunker::new(max_tokens),
             min_chunk_tokens: min_tokens,
             max_chunk_tokens: max_tokens,
+            max_merged_tokens: DEFAULT_MAX_MERGED_TOKENS.min(max_tokens),
+            tokenizer: None,
+            api_surface: false,
+            max_symbols: DEFAULT_MAX_SYMBOLS_PER_FILE,
+            symbol_cap_policy: SymbolCapPolicy::default(),
+            grammar_extensions: HashMap::new(),
+            granularity: GranularityRules::default(),
             last_stats: ChunkingStats::default(),
         }
     }
 
+    /// Measure units with the embedding model's tokenizer instead of the
+    /// 4-characters-per-token approximation.
+    pub fn with_tokenizer(mut self, tokenizer: Arc<dyn Tokenizer>) -> Self {
+        self.fallback = self.fallback.with_tokenizer(tokenizer.clone());
+        self.tokenizer = Some(tokenizer);
+        self
+    }
+
+    /// Chunk files without a parser into overlapping windows of
+    /// `window_tokens`, each repeating the last `overlap_tokens` of the
+    /// previous one. The overlap is capped at half a window.
+    pub fn with_fallback_window(mut self, window_tokens: usize, overlap_tokens: usize) -> Self {
+        let fallback = Chunker::with_overlap(window_tokens, overlap_tokens.min(window_tokens / 2));
+        self.fallback = match &self.tokenizer {
+            Some(tokenizer) => fallback.with_tokenizer(tokenizer.clone()),
+            None => fallback,
+        };
+        self
+    }
+
+    /// Merge consecutive units smaller than the minimum into chunks of up
+    /// to `max_tokens`, capped at the maximum chunk size.
+    pub fn with_merge_limit(mut self, max_tokens: usize) -> Self {
+        self.max_merged_tokens = max_tokens.min(self.max_chunk_tokens);
+        self
+    }
+
+    /// Index only the API surface: one chunk per exported symbol holding
+    /// its docs and signature.
+    ///
+    /// Bodies and unexported symbols are skipped, units are neither merged
+    /// nor split, and files without an extractor produce no chunks.
+    pub fn with_api_surface(mut self, enabled: bool) -> Self {
+        self.api_surface = enabled;
+        self
+    }
+
+    /// Limit the number of symbols indexed per file.
+    ///
+    /// Files with more symbols than `max_symbols` (typically
+    /// machine-generated) are summarized or skipped according to `policy`.
+    pub fn with_symbol_cap(mut self, max_symbols: usize, policy: SymbolCapPolicy) -> Self {
+        self.max_symbols = max_symbols;
+        self.symbol_cap_policy = policy;
+        self
+    }
+
+    /// Load the grammars configured in `indexer.grammars`, so files with
+    /// their extensions are parsed and chunked like built-in languages.
+    ///
+    /// A grammar for an extension with built-in support takes its place.
+    pub fn with_grammars(mut self, grammars: &[GrammarConfig]) -> Result<Self> {
+        for config in grammars {
+            let grammar = grammars::load(config)?;
+            self.parser_pool
+                .register_language(&grammar.name, grammar.language);
+            self.extractors.register(Box::new(grammar.extractor));
+            for extension in grammar.extensions {
+                self.grammar_extensions
+                    .insert(extension, grammar.name.clone());
+            }
+        }
+        Ok(self)
+    }
+
+    /// Choose the granularity of files under `root` with the rules
+    /// configured in `indexer.granularity`.
+    ///
+    /// A file at `file` granularity that does not fit in one chunk is
+    /// chunked by function.
+    pub fn with_granularity(mut self, root: &Path, rules: &[GranularityRule]) -> Result<Self> {
+        self.granularity = GranularityRules::new(root, rules)?;
+        Ok(self)
+    }
+
     /// Chunk a file using AST extraction.
     ///
     /// Falls back to line-based chunking if:
@@ -106,8 +273,30 @@ impl AstChunker {
         // Reset stats
         self.last_stats = ChunkingStats::default();
 
+        // OpenAPI specs, YAML and JSON config, dotenv files, SQL, Terraform,
+        // Protocol Buffers, GraphQL, Markdown, notebooks, Dockerfiles and
+        // shell scripts are chunked by their own definitions rather than
+        // with a Tree-sitter grammar
+        for chunk_document in DOCUMENT_CHUNKERS {
+            if let Some(chunks) = chunk_document(path, content) {
+                if !chunks.is_empty() {
+                    self.last_stats.method_used = ChunkingMethod::Ast;
+                    self.last_stats.semantic_units_extracted = chunks.len();
+                    return chunks;
+                }
+            }
+        }
+
+        if sfc::is_component(path) {
+            return self.chunk_component(path, content);
+        }
+
+        if self.api_surface {
+            return self.chunk_api_surface(path, content);
+        }
+
         // Detect language from file extension
-        let language = match Self::detect_language(path) {
+        let language = match self.language_of(path, content) {
             Some(lang) => lang,
             None => {
                 debug!("Unknown language for {:?}, using line-based chunking", path);
@@ -134,7 +323,7 @@ impl AstChunker {
         };
 
         // Get or create parser for this language
-        let parser = match self.parser_pool.get_parser(&language) {
+        let parser = match self.parser_pool.get_parser(ParserPool::grammar(path, &language)) {
             Some(p) => p,
             None => {
                 warn!(
@@ -162,6 +351,7 @@ impl AstChunker {
 
         // Extract semantic units
         let units = extractor.extract(&tree, content.as_bytes());
+        let module = extractor.module_path(&tree, content.as_bytes(), path);
 
         if units.is_empty() {
             debug!(
@@ -176,8 +366,68 @@ impl AstChunker {
 
         self.last_stats.semantic_units_extracted = units.len();
 
-        // Convert semantic units to chunks, handling merging and splitting
-        let chunks = self.process_semantic_units(path, content, units, &language);
+        if units.len() > self.max_symbols {
+            self.last_stats.symbol_cap_exceeded = true;
+            self.last_stats.method_used = ChunkingMethod::Ast;
+            return self.chunk_over_symbol_cap(
+                extractor,
+                path,
+                content,
+                &language,
+                module.as_deref(),
+                &units,
+            );
+        }
+
+        let granularity = self.granularity.level(path, &language);
+        let line_count = content.lines().count();
+        let mut chunks = if granularity == Granularity::File && self.fits(content, 1, line_count)
+        {
+            vec![file_chunk(path, content, &language, module.as_deref())]
+        } else {
+            // Methods and other nested symbols stay in their type's chunk
+            let units = match granularity {
+                Granularity::Type => outermost_units(units),
+                _ => units,
+            };
+
+            // Doc comments and leading comments are siblings of their symbol
+            // in most grammars, not part of it; start units at them so the
+            // prose is embedded with the code
+            let units = with_leading_comments(content, &language, units);
+
+            // Convert semantic units to chunks, handling merging and splitting
+            self.process_semantic_units(path, content, &tree, units, &language)
+        };
+
+        // Qualify symbol names using the language's conventions
+        if let Some(extractor) = self.extractors.get(&language) {
+            for chunk in chunks.iter_mut().filter(|c| c.qualified_name.is_none()) {
+                chunk.qualified_name = chunk.name.as_deref().map(|name| {
+                    extractor.qualified_name(module.as_deref(), chunk.parent.as_deref(), name)
+                });
+            }
+        }
+
+        // Functions of generated API clients are what SDK users look for
+        if generated::is_generated_client(path, content) {
+            tag_client_methods(&mut chunks);
+        }
+
+        // Tag Go code using goroutines, channels, sync or context, and Go
+        // structs with the serialized names of their fields
+        if language == "go" {
+            for chunk in &mut chunks {
+                if concurrency::uses_go_concurrency(&chunk.content) {
+                    chunk.tags.push(concurrency::CONCURRENCY_TAG.to_string());
+                }
+                if chunk.semantic_kind == Some(SemanticKind::Struct) {
+                    chunk
+                        .tags
+                        .extend(struct_tags::struct_tag_labels(&chunk.content));
+                }
+            }
+        }
 
         // Determine method used
         if self.last_stats.fallback_chunks > 0 && self.last_stats.semantic_units_extracted > 0 {
@@ -191,6 +441,152 @@ impl AstChunker {
         chunks
     }
 
+    /// Chunk a Vue or Svelte component section by section.
+    ///
+    /// Scripts are chunked as JavaScript or TypeScript files, with their
+    /// lines mapped back into the component; the markup and styles are
+    /// block chunks named by the component. Every chunk is tagged
+    /// `component:<name>`.
+    fn chunk_component(&mut self, path: &Path, content: &str) -> Vec<Chunk> {
+        let sections = sfc::split(path, content);
+        let component = sfc::component_name(path, &sections);
+        let language = sfc::language(path).unwrap_or_default();
+        let lines: Vec<&str> = content.lines().collect();
+        let mut stats = ChunkingStats::default();
+        let mut chunks = Vec::new();
+
+        for section in &sections {
+            if section.kind == sfc::SectionKind::Script {
+                let extension = match section.script_language() {
+                    "typescript" => "ts",
+                    _ => "js",
+                };
+                let mut script = self.chunk_file(&path.with_extension(extension), &section.body);
+                stats.semantic_units_extracted += self.last_stats.semantic_units_extracted;
+                stats.units_merged += self.last_stats.units_merged;
+                stats.fallback_chunks += self.last_stats.fallback_chunks;
+                stats.symbol_cap_exceeded |= self.last_stats.symbol_cap_exceeded;
+                for chunk in &mut script {
+                    chunk.file_path = path.to_path_buf();
+                    chunk.start_line += section.body_start_line;
+                    chunk.end_line += section.body_start_line;
+                }
+                chunks.extend(script);
+            } else if !self.api_surface {
+                // Markup and styles, split by lines only when too large
+                let text = lines[section.start_line..=section.end_line].join("\n");
+                for part in self.fallback.chunk_file(path, &text) {
+                    chunks.push(Chunk {
+                        start_line: part.start_line + section.start_line,
+                        end_line: part.end_line + section.start_line,
+                        language: Some(language.to_string()),
+                        semantic_kind: Some(SemanticKind::Block),
+                        name: Some(component.clone()),
+                        signature: (!section.tag.is_empty()).then(|| section.tag.clone()),
+                        ..part
+                    });
+                }
+            }
+        }
+
+        let tag = struct_tags::struct_tag(sfc::COMPONENT_TAG, &component);
+        for chunk in &mut chunks {
+            chunk.tags.push(tag.clone());
+        }
+        chunks.sort_by_key(|c| c.start_line);
+
+        stats.method_used = if stats.semantic_units_extracted == 0 {
+            ChunkingMethod::LineBased
+        } else if stats.fallback_chunks > 0 {
+            ChunkingMethod::Mixed
+        } else {
+            ChunkingMethod::Ast
+        };
+        self.last_stats = stats;
+        chunks
+    }
+
+    /// Chunk the exported symbols of a file as docs plus signature.
+    fn chunk_api_surface(&mut self, path: &Path, content: &str) -> Vec<Chunk> {
+        let Some(language) = self.language_of(path, content) else {
+            return Vec::new();
+        };
+        let (Some(extractor), Some(parser)) = (
+            self.extractors.get(&language),
+            self.parser_pool.get_parser(ParserPool::grammar(path, &language)),
+        ) else {
+            return Vec::new();
+        };
+        let Some(tree) = parser.parse(content.as_bytes(), None) else {
+            warn!("Failed to parse {:?}, skipping its API surface", path);
+            return Vec::new();
+        };
+
+        let units = extractor.extract(&tree, content.as_bytes());
+        let module = extractor.module_path(&tree, content.as_bytes(), path);
+        self.last_stats.semantic_units_extracted = units.len();
+
+        if units.len() > self.max_symbols {
+            self.last_stats.symbol_cap_exceeded = true;
+            self.last_stats.method_used = ChunkingMethod::Ast;
+            return self.chunk_over_symbol_cap(
+                extractor,
+                path,
+                content,
+                &language,
+                module.as_deref(),
+                &units,
+            );
+        }
+
+        let mut chunks = surface_chunks(extractor, path, &language, module.as_deref(), &units);
+        if generated::is_generated_client(path, content) {
+            tag_client_methods(&mut chunks);
+        }
+        self.last_stats.method_used = ChunkingMethod::Ast;
+        chunks
+    }
+
+    /// Chunk a file with more symbols than the cap: its top-level API
+    /// surface, up to the cap, or nothing.
+    fn chunk_over_symbol_cap(
+        &self,
+        extractor: &dyn SemanticExtractor,
+        path: &Path,
+        content: &str,
+        language: &str,
+        module: Option<&str>,
+        units: &[SemanticUnit],
+    ) -> Vec<Chunk> {
+        if self.symbol_cap_policy == SymbolCapPolicy::Skip {
+            warn!(
+                "Skipping {:?}: {} symbols exceed indexer.max_symbols_per_file ({})",
+                path,
+                units.len(),
+                self.max_symbols
+            );
+            return Vec::new();
+        }
+
+        let top_level: Vec<SemanticUnit> =
+            units.iter().filter(|u| u.parent.is_none()).cloned().collect();
+        let mut chunks = surface_chunks(extractor, path, language, module, &top_level);
+        warn!(
+            "Summarizing {:?}: {} symbols exceed indexer.max_symbols_per_file ({}), \
+             indexing {} top-level exported symbols",
+            path,
+            units.len(),
+            self.max_symbols,
+            chunks.len().min(self.max_symbols)
+        );
+        chunks.truncate(self.max_symbols);
+
+        if generated::is_generated_client(path, content) {
+            tag_client_methods(&mut chunks);
+        }
+        chunks
+    }
+
     /// Get statistics about the last chunking operation.
     pub fn last_stats(&self) -> &ChunkingStats {
         &self.last_stats
@@ -201,6 +597,7 @@ impl AstChunker {
         &mut self,
         path: &Path,
         content: &str,
+        tree: &Tree,
         units: Vec<SemanticUnit>,
         language: &str,
     ) -> Vec<Chunk> {
@@ -208,61 +605,94 @@ impl AstChunker {
         let mut pending_small_units: Vec<SemanticUnit> = Vec::new();
 
         for unit in units {
-            let token_estimate = Self::estimate_tokens(&unit.content);
+            let token_estimate = self.count_tokens(&unit.content);
 
             if token_estimate > self.max_chunk_tokens {
-                // Unit is too large, use line-based chunking for it
+                // Unit is too large, split it between its statements
                 debug!(
-                    "Semantic unit '{}' is too large ({} tokens), using line-based chunking",
+                    "Semantic unit '{}' is too large ({} tokens), splitting it",
                     unit.name.as_deref().unwrap_or("unnamed"),
                     token_estimate
                 );
 
                 // First, flush any pending small units
                 if !pending_small_units.is_empty() {
-                    chunks.push(self.merge_small_units(path, &pending_small_units, language));
+                    chunks.push(self.merge_small_units(
+                        path,
+                        content,
+                        &pending_small_units,
+                        language,
+                    ));
                     pending_small_units.clear();
                 }
 
-                // Chunk the large unit with line-based chunker
-                let unit_chunks = self.fallback.chunk_file(path, &unit.content);
-                self.last_stats.fallback_chunks += unit_chunks.len();
-
-                // Adjust line numbers for the sub-chunks
-                for mut chunk in unit_chunks {
-                    chunk.start_line = unit.start_line + chunk.start_line - 1;
-                    chunk.end_line = unit.start_line + chunk.end_line - 1;
-                    // Preserve semantic info in the first chunk
-                    if chunks.is_empty()
-                        || chunks.last().map(|c| c.end_line).unwrap_or(0) < unit.start_line
-                    {
-                        chunk.semantic_kind = Some(unit.kind);
-                        chunk.name = unit.name.clone();
-                        chunk.signature = unit.signature.clone();
-                        chunk.parent = unit.parent.clone();
-                    }
-                    chunks.push(chunk);
+                // Parts hold the unit's full source lines rather than the node
+                // text, which starts mid-line for nested items. This keeps
+                // each part's content identical to the lines it reports.
+                let mut ranges = Vec::new();
+                match unit_node(tree.root_node(), unit.start_byte, unit.end_byte) {
+                    Some(node) => self.split_node(
+                        path,
+                        content,
+                        node,
+                        unit.start_line,
+                        unit.end_line,
+                        &mut ranges,
+                    ),
+                    None => self.split_lines(
+                        path,
+                        content,
+                        unit.start_line,
+                        unit.end_line,
+                        &mut ranges,
+                    ),
                 }
-            } else if token_estimate < self.min_chunk_tokens {
-                // Unit is small, accumulate for merging
-                pending_small_units.push(unit);
 
-                // Check if accumulated units are large enough
-                let total_tokens: usize = pending_small_units
-                    .iter()
-                    .map(|u| Self::estimate_tokens(&u.content))
-                    .sum();
-
-                if total_tokens >= self.min_chunk_tokens {
-                    chunks.push(self.merge_small_units(path, &pending_small_units, language));
+                // Every part carries the unit's signature, so a part from the
+                // middle of a function still says which function it is in
+                for (start_line, end_line) in ranges {
+                    chunks.push(Chunk {
+                        content: source_lines(content, start_line, end_line),
+                        file_path: path.to_path_buf(),
+                        start_line,
+                        end_line,
+                        language: Some(language.to_string()),
+                        semantic_kind: Some(unit.kind),
+                        name: unit.name.clone(),
+                        signature: unit.signature.clone(),
+                        doc: unit.docs.as_deref().and_then(doc_text),
+                        parent: unit.parent.clone(),
+                        qualified_name: None,
+                        tags: Vec::new(),
+                    });
+                }
+            } else if token_estimate < self.min_chunk_tokens {
+                // Unit is small: coalesce it with the small units before it,
+                // unless that takes them over the merge limit or it belongs
+                // to another type
+                if !pending_small_units.is_empty()
+                    && !self.joins(content, &pending_small_units, &unit)
+                {
+                    chunks.push(self.merge_small_units(
+                        path,
+                        content,
+                        &pending_small_units,
+                        language,
+                    ));
                     self.last_stats.units_merged += pending_small_units.len();
                     pending_small_units.clear();
                 }
+                pending_small_units.push(unit);
             } else {
                 // Unit is within acceptable size range
                 // First, flush any pending small units
                 if !pending_small_units.is_empty() {
-                    chunks.push(self.merge_small_units(path, &pending_small_units, language));
+                    chunks.push(self.merge_small_units(
+                        path,
+                        content,
+                        &pending_small_units,
+                        language,
+                    ));
                     self.last_stats.units_merged += pending_small_units.len();
                     pending_small_units.clear();
                 }
@@ -276,14 +706,17 @@ impl AstChunker {
                     semantic_kind: Some(unit.kind),
                     name: unit.name,
                     signature: unit.signature,
+                    doc: unit.docs.as_deref().and_then(doc_text),
                     parent: unit.parent,
+                    qualified_name: None,
+                    tags: Vec::new(),
                 });
             }
         }
 
         // Handle any remaining small units
         if !pending_small_units.is_empty() {
-            chunks.push(self.merge_small_units(path, &pending_small_units, language));
+            chunks.push(self.merge_small_units(path, content, &pending_small_units, language));
             self.last_stats.units_merged += pending_small_units.len();
         }
 
@@ -298,21 +731,127 @@ impl AstChunker {
         chunks
     }
 
+    /// Push line ranges splitting `node`, spanning lines `start_line` to
+    /// `end_line`, into parts within the token limit.
+    ///
+    /// Parts end between the statements of the node's body, so no statement,
+    /// nested function or type is cut unless it is too large on its own; it
+    /// is then split between its own statements in turn. Code without
+    /// statements to split between, such as a long literal, is split by
+    /// lines.
+    fn split_node(
+        &mut self,
+        path: &Path,
+        content: &str,
+        node: Node,
+        start_line: usize,
+        end_line: usize,
+        ranges: &mut Vec<(usize, usize)>,
+    ) {
+        if self.fits(content, start_line, end_line) {
+            ranges.push((start_line, end_line));
+            return;
+        }
+        let statements = body_statements(node);
+        if statements.is_empty() {
+            self.split_lines(path, content, start_line, end_line, ranges);
+            return;
+        }
+
+        let mut part_start = start_line;
+        // Last line of the statements that fit in the current part
+        let mut part_end: Option<usize> = None;
+        for (i, statement) in statements.iter().enumerate() {
+            // The last statement's part runs on to the unit's closing lines
+            let statement_end = match statements.get(i + 1) {
+                Some(next) => {
+                    // Statements sharing a line stay in one part
+                    if next.start_position().row <= statement.end_position().row {
+                        continue;
+                    }
+                    (statement.end_position().row + 1)
+                        .max(part_start)
+                        .min(end_line)
+                }
+                None => end_line,
+            };
+            if self.fits(content, part_start, statement_end) {
+                part_end = Some(statement_end);
+                continue;
+            }
+            if let Some(end) = part_end.take() {
+                ranges.push((part_start, end));
+                part_start = end + 1;
+            }
+            if self.fits(content, part_start, statement_end) {
+                part_end = Some(statement_end);
+            } else {
+                self.split_node(path, content, *statement, part_start, statement_end, ranges);
+                part_start = statement_end + 1;
+            }
+        }
+        if part_start <= end_line {
+            ranges.push((part_start, end_line));
+        }
+    }
+
+    /// Push line ranges splitting lines `start_line` to `end_line` with the
+    /// line-based chunker.
+    fn split_lines(
+        &mut self,
+        path: &Path,
+        content: &str,
+        start_line: usize,
+        end_line: usize,
+        ranges: &mut Vec<(usize, usize)>,
+    ) {
+        let source = source_lines(content, start_line, end_line);
+        let chunks = self.fallback.chunk_file(path, &source);
+        self.last_stats.fallback_chunks += chunks.len();
+        ranges.extend(chunks.iter().map(|chunk| {
+            (
+                start_line + chunk.start_line - 1,
+                start_line + chunk.end_line - 1,
+            )
+        }));
+    }
+
+    /// Whether lines `start_line` to `end_line` fit in one chunk.
+    fn fits(&self, content: &str, start_line: usize, end_line: usize) -> bool {
+        self.count_tokens(&source_lines(content, start_line, end_line)) <= self.max_chunk_tokens
+    }
+
+    /// Whether small `unit` can be merged into the chunk of `pending`: it
+    /// is nested in their lines, such as a method of a small class, or it
+    /// has the same parent as they do and their merged lines stay within
+    /// the merge limit.
+    fn joins(&self, content: &str, pending: &[SemanticUnit], unit: &SemanticUnit) -> bool {
+        let Some(first) = pending.first() else {
+            return true;
+        };
+        let start_line = pending.iter().map(|u| u.start_line).min().unwrap_or(1);
+        let end_line = pending.iter().map(|u| u.end_line).max().unwrap_or(1);
+        if start_line <= unit.start_line && unit.end_line <= end_line {
+            return true;
+        }
+        let merged = source_lines(content, start_line, end_line.max(unit.end_line));
+        first.parent == unit.parent && self.count_tokens(&merged) <= self.max_merged_tokens
+    }
+
     /// Merge multiple small semantic units into a single chunk.
+    ///
+    /// The merged content is the source text spanning all units, including
+    /// anything between them, so it matches the reported line range.
     fn merge_small_units(
         &self,
         path: &Path,
+        source: &str,
         units: &[SemanticUnit],
         language: &str,
     ) -> Chunk {
-        let content = units
-            .iter()
-            .map(|u| u.content.as_str())
-            .collect::<Vec<_>>()
-            .join("\n\n");
-
         let start_line = units.iter().map(|u| u.start_line).min().unwrap_or(1);
         let end_line = units.iter().map(|u| u.end_line).max().unwrap_or(1);
+        let content = source_lines(source, start_line, end_line);
 
         // Use the first unit's semantic info if it's meaningful
         let first = units.first();
@@ -326,7 +865,18 @@ impl AstChunker {
             semantic_kind: first.map(|u| u.kind),
             name: first.and_then(|u| u.name.clone()),
             signature: first.and_then(|u| u.signature.clone()),
+            doc: first.and_then(|u| u.docs.as_deref()).and_then(doc_text),
             parent: first.and_then(|u| u.parent.clone()),
+            qualified_name: None,
+            tags: Vec::new(),
+        }
+    }
+
+    /// Count tokens with the configured tokenizer, or estimate them.
+    fn count_tokens(&self, s: &str) -> usize {
+        match &self.tokenizer {
+            Some(tokenizer) => tokenizer.count_tokens(s),
+            None => Self::estimate_tokens(s),
         }
     }
 
@@ -348,10 +898,278 @@ impl AstChunker {
                 "tsx" => Some("typescript"),
                 "go" => Some("go"),
                 "java" => Some("java"),
+                "c" | "h" => Some("c"),
+                "cc" | "cpp" | "cxx" | "c++" | "hh" | "hpp" | "hxx" | "h++" => Some("cpp"),
+                "cs" => Some("csharp"),
+                "rb" | "rake" => Some("ruby"),
+                "php" | "phtml" => Some("php"),
+                "kt" | "kts" => Some("kotlin"),
+                "swift" => Some("swift"),
+                "scala" | "sc" => Some("scala"),
                 _ => None,
             })
             .map(String::from)
     }
+
+    /// Language of a file's content: that of its extension, except that
+    /// `.h` headers using C++-only syntax are parsed as C++.
+    fn source_language(path: &Path, content: &str) -> Option<String> {
+        let language = Self::detect_language(path)?;
+        if language == "c" && ParserPool::is_header(path) && uses_cpp_syntax(content) {
+            return Some("cpp".to_string());
+        }
+        Some(language)
+    }
+
+    /// Language of a source file: that of a runtime grammar registered for
+    /// its extension, else the built-in one.
+    fn language_of(&self, path: &Path, content: &str) -> Option<String> {
+        let extension = path.extension().and_then(|ext| ext.to_str());
+        match extension.and_then(|ext| self.grammar_extensions.get(ext)) {
+            Some(language) => Some(language.clone()),
+            None => Self::source_language(path, content),
+        }
+    }
+}
+
+/// Whether C-family code has a line only C++ allows, such as a class,
+/// namespace or template declaration.
+fn uses_cpp_syntax(content: &str) -> bool {
+    const CPP_ONLY: &[&str] = &[
+        "class ",
+        "namespace ",
+        "template",
+        "using ",
+        "public:",
+        "protected:",
+        "private:",
+    ];
+    content.lines().any(|line| {
+        let line = line.trim_start();
+        CPP_ONLY.iter().any(|prefix| line.starts_with(prefix)) || line.contains("std::")
+    })
+}
+
+/// Mark the functions and methods of a generated API client.
+fn tag_client_methods(chunks: &mut [Chunk]) {
+    for chunk in chunks {
+        if matches!(
+            chunk.semantic_kind,
+            Some(SemanticKind::Function | SemanticKind::Method)
+        ) {
+            chunk.semantic_kind = Some(SemanticKind::ClientMethod);
+        }
+    }
+}
+
+/// The API surface of a unit: its docs followed by its declaration.
+///
+/// Functions are declared by their signature and types by their header
+/// line; traits and interfaces consist of signatures, so they are kept
+/// whole.
+fn api_surface_text(unit: &SemanticUnit) -> String {
+    let declaration = match unit.kind {
+        SemanticKind::Trait | SemanticKind::Interface => unit.content.trim().to_string(),
+        _ => unit
+            .signature
+            .clone()
+            .unwrap_or_else(|| declaration_line(&unit.content)),
+    };
+    match unit.docs.as_deref().map(str::trim) {
+        Some(docs) if !docs.is_empty() => format!("{}\n{}", docs, declaration),
+        _ => declaration,
+    }
+}
+
+/// First line of a declaration, after attributes and decorators, without
+/// its opening brace.
+fn declaration_line(content: &str) -> String {
+    content
+        .lines()
+        .map(str::trim)
+        .find(|line| !line.is_empty() && !line.starts_with("#[") && !line.starts_with('@'))
+        .unwrap_or_default()
+        .trim_end_matches('{')
+        .trim_end()
+        .to_string()
+}
+
+/// `units` extended up over the comment block on the lines right above
+/// them, up to a blank line or the end of the previous unit. A unit whose
+/// extractor found no docs gets the comment block as its docs.
+///
+/// Rust and C# have a syntax for doc comments, so only their outer doc
+/// comments (`///`, `/** */`) and Rust attributes (`#[...]`) are taken; a
+/// plain `//` comment above an item is often commented-out code. Only
+/// one-line attributes and comments are recognized there; an attribute
+/// spanning lines stops the extension at its last line.
+fn with_leading_comments(
+    source: &str,
+    language: &str,
+    units: Vec<SemanticUnit>,
+) -> Vec<SemanticUnit> {
+    let lines: Vec<&str> = source.lines().collect();
+    let line_starts: Vec<usize> = std::iter::once(0)
+        .chain(source.match_indices('\n').map(|(i, _)| i + 1))
+        .collect();
+    let prefixes = comment_prefixes(language);
+    let is_leading = |line: &str| {
+        let line = line.trim();
+        match language {
+            "rust" | "csharp" => {
+                (line.starts_with("///") && !line.starts_with("////"))
+                    || (line.starts_with("/**") && line.ends_with("*/"))
+                    || (line.starts_with("#[") && line.ends_with(']'))
+            }
+            // A shebang is not a comment on the code below it
+            _ => !line.starts_with("#!") && prefixes.iter().any(|prefix| line.starts_with(prefix)),
+        }
+    };
+    // Comments cannot reach into a unit ending above, such as a trailing
+    // comment at the end of the previous function
+    let floors: Vec<usize> = units
+        .iter()
+        .map(|unit| {
+            units
+                .iter()
+                .map(|u| u.end_line)
+                .filter(|&end| end < unit.start_line)
+                .max()
+                .unwrap_or(0)
+        })
+        .collect();
+
+    units
+        .into_iter()
+        .zip(floors)
+        .map(|(mut unit, floor)| {
+            let mut start = unit.start_line;
+            while start > floor + 1 && lines.get(start - 2).is_some_and(|line| is_leading(line)) {
+                start -= 1;
+            }
+            if start < unit.start_line {
+                if unit.docs.is_none() {
+                    unit.docs = Some(lines[start - 1..unit.start_line - 1].join("\n"));
+                }
+                unit.content = source_lines(source, start, unit.end_line);
+                unit.start_line = start;
+                unit.start_byte = line_starts[start - 1];
+            }
+            unit
+        })
+        .collect()
+}
+
+/// Prefixes of the lines of a comment block in `language`: line comments,
+/// and the lines of `/* */` blocks. Grammars loaded at runtime get the
+/// common comment syntaxes.
+fn comment_prefixes(language: &str) -> &'static [&'static str] {
+    match language {
+        "python" | "ruby" => &["#"],
+        "php" => &["//", "#", "/*", "*"],
+        "javascript" | "typescript" | "go" | "java" | "c" | "cpp" | "kotlin" | "swift"
+        | "scala" => &["//", "/*", "*"],
+        _ => &["//", "#", "--", ";", "/*", "*"],
+    }
+}
+
+/// Prose of a doc comment, comment block or Python docstring: its lines
+/// without comment markers, quotes and attributes, or `None` when nothing
+/// is left.
+fn doc_text(comment: &str) -> Option<String> {
+    let comment = comment.trim();
+    let comment = if comment.starts_with(['"', '\'']) {
+        comment.trim_matches(['"', '\''])
+    } else {
+        comment
+    };
+    let text = comment
+        .lines()
+        .map(str::trim)
+        .filter(|line| !line.starts_with("#["))
+        .map(|line| {
+            line.trim_start_matches(['/', '*', '#', '!', '-', ';'])
+                .trim_end_matches("*/")
+                .trim()
+        })
+        .collect::<Vec<_>>()
+        .join("\n");
+    let text = text.trim();
+    (!text.is_empty()).then(|| text.to_string())
+}
+
+/// The outermost node below `root` starting at or after `start_byte` and
+/// ending at `end_byte`: the node of a unit, whose start may have been moved
+/// up to its docs.
+fn unit_node(root: Node, start_byte: usize, end_byte: usize) -> Option<Node> {
+    let mut cursor = root.walk();
+    let child = root
+        .children(&mut cursor)
+        .find(|c| c.start_byte() < end_byte && c.end_byte() >= end_byte)?;
+    if child.start_byte() >= start_byte && child.end_byte() == end_byte {
+        Some(child)
+    } else {
+        unit_node(child, start_byte, end_byte)
+    }
+}
+
+/// The statements a node can be split between: the named children of its
+/// `body` field, or of the node itself when it has none (a block, or an
+/// `if` whose branches are blocks).
+fn body_statements(node: Node) -> Vec<Node> {
+    let body = node.child_by_field_name("body").unwrap_or(node);
+    let mut cursor = body.walk();
+    body.named_children(&mut cursor).collect()
+}
+
+/// Units not nested in another unit, such as a class without its methods,
+/// which its chunk holds anyway.
+fn outermost_units(units: Vec<SemanticUnit>) -> Vec<SemanticUnit> {
+    let ranges: Vec<(usize, usize)> = units.iter().map(|u| (u.start_byte, u.end_byte)).collect();
+    units
+        .into_iter()
+        .filter(|unit| {
+            !ranges.iter().any(|&(start, end)| {
+                start <= unit.start_byte
+                    && unit.end_byte <= end
+                    && (start, end) != (unit.start_byte, unit.end_byte)
+            })
+        })
+        .collect()
+}
+
+/// The chunk holding a whole file, named after the file and qualified by
+/// its module when the language has one.
+fn file_chunk(path: &Path, content: &str, language: &str, module: Option<&str>) -> Chunk {
+    let name = path
+        .file_stem()
+        .map(|stem| stem.to_string_lossy().into_owned());
+    let end_line = content.lines().count().max(1);
+    Chunk {
+        content: source_lines(content, 1, end_line),
+        file_path: path.to_path_buf(),
+        start_line: 1,
+        end_line,
+        language: Some(language.to_string()),
+        semantic_kind: Some(SemanticKind::Module),
+        qualified_name: module.map(str::to_string).or_else(|| name.clone()),
+        name,
+        signature: None,
+        doc: None,
+        parent: None,
+        tags: Vec::new(),
+    }
+}
+
+/// Source text of a 1-indexed, inclusive line range.
+fn source_lines(content: &str, start_line: usize, end_line: usize) -> String {
+    let start = start_line.max(1) - 1;
+    content
+        .lines()
+        .skip(start)
+        .take(end_line.saturating_sub(start))
+        .collect::<Vec<_>>()
+        .join("\n")
 }
 
 impl Default for AstChunker {
@@ -360,6 +1178,70 @@ impl Default for AstChunker {
     }
 }
 
+/// API-surface chunks of `units`: the docs and signature of each exported
+/// symbol that is reachable from outside the file.
+fn surface_chunks(
+    extractor: &dyn SemanticExtractor,
+    path: &Path,
+    language: &str,
+    module: Option<&str>,
+    units: &[SemanticUnit],
+) -> Vec<Chunk> {
+    // Members of private types are not reachable, even when public
+    let private_types: HashSet<&str> = units
+        .iter()
+        .filter(|u| {
+            matches!(
+                u.kind,
+                SemanticKind::Struct
+                    | SemanticKind::Class
+                    | SemanticKind::Trait
+                    | SemanticKind::Interface
+                    | SemanticKind::Enum
+            ) && !extractor.is_exported(u)
+        })
+        .filter_map(|u| u.name.as_deref())
+        .collect();
+
+    units
+        .iter()
+        .filter(|u| {
+            // Containers and tests are not API; members are emitted on their own
+            !matches!(
+                u.kind,
+                SemanticKind::Impl | SemanticKind::Module | SemanticKind::Test | SemanticKind::Block
+            )
+        })
+        .filter(|u| extractor.is_exported(u))
+        .filter(|u| {
+            // Java nests parents as `Outer.Inner`; a private type at any level
+            // hides the member
+            u.parent.as_deref().map_or(true, |p| {
+                !normalize_parent(p)
+                    .split('.')
+                    .any(|t| private_types.contains(t))
+            })
+        })
+        .map(|u| Chunk {
+            content: api_surface_text(u),
+            file_path: path.to_path_buf(),
+            start_line: u.start_line,
+            end_line: u.end_line,
+            language: Some(language.to_string()),
+            semantic_kind: Some(u.kind),
+            name: u.name.clone(),
+            signature: u.signature.clone(),
+            doc: u.docs.as_deref().and_then(doc_text),
+            parent: u.parent.clone(),
+            qualified_name: u
+                .name
+                .as_deref()
+                .map(|name| extractor.qualified_name(module, u.parent.as_deref(), name)),
+            tags: Vec::new(),
+        })
+        .collect()
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -381,6 +1263,26 @@ mod tests {
         assert_eq!(AstChunker::detect_language(Path::new("file.txt")), None);
     }
 
+    #[test]
+    fn test_headers_with_cpp_syntax_are_cpp() {
+        let c_header = "#ifndef POOL_H\n#define POOL_H\nint pool_size(void);\n#endif\n";
+        let cpp_header = "#pragma once\nclass Pool {\npublic:\n    int size() const;\n};\n";
+        let header = Path::new("include/pool.h");
+
+        assert_eq!(
+            AstChunker::source_language(header, c_header),
+            Some("c".to_string())
+        );
+        assert_eq!(
+            AstChunker::source_language(header, cpp_header),
+            Some("cpp".to_string())
+        );
+        assert_eq!(
+            AstChunker::source_language(Path::new("src/pool.cc"), c_header),
+            Some("cpp".to_string())
+        );
+    }
+
     #[test]
     fn test_estimate_tokens() {
         assert_eq!(AstChunker::estimate_tokens(""), 0);
@@ -393,4 +1295,514 @@ mod tests {
         let method = ChunkingMethod::default();
         assert_eq!(method, ChunkingMethod::LineBased);
     }
+
+    #[test]
+    fn test_split_chunk_lines_match_source() {
+        let mut source =
+            String::from("use std::fmt;\n\n/// Long function\npub fn long_function() -> u32 {\n");
+        for i in 0..60 {
+            source.push_str(&format!(
+                "    let step_{i} = compute_value({i}) + offset_for_step({i});\n"
+            ));
+        }
+        source.push_str("    step_59\n}\n");
+        let lines: Vec<&str> = source.lines().collect();
+
+        let mut chunker = AstChunker::with_limits(10, 200);
+        let chunks = chunker.chunk_file(Path::new("long.rs"), &source);
+        assert!(chunks.len() > 1, "long function should be split");
+
+        for chunk in &chunks {
+            let expected = lines[chunk.start_line - 1..chunk.end_line].join("\n");
+            assert_eq!(
+                chunk.content, expected,
+                "lines {}-{}",
+                chunk.start_line, chunk.end_line
+            );
+        }
+
+        // A chunk from the middle of the function reports the lines it holds
+        let mid = chunks
+            .iter()
+            .find(|c| c.start_line > 5 && c.end_line < lines.len() - 2)
+            .expect("mid-symbol chunk");
+        let first_line = mid.content.lines().next().unwrap();
+        assert_eq!(lines[mid.start_line - 1], first_line);
+        let step = lines
+            .iter()
+            .position(|l| l.contains("let step_30 "))
+            .unwrap()
+            + 1;
+        let holder = chunks
+            .iter()
+            .find(|c| c.start_line <= step && step <= c.end_line)
+            .unwrap();
+        let offset = holder
+            .content
+            .lines()
+            .position(|l| l.contains("let step_30 "))
+            .unwrap();
+        assert_eq!(holder.start_line + offset, step);
+    }
+
+    #[test]
+    fn test_split_parts_end_between_statements() {
+        let mut source = String::from("/// Route an event.\npub fn route(kind: u32) -> u32 {\n");
+        for i in 0..12 {
+            source.push_str(&format!(
+                "    if kind == {i} {{\n        let value = lookup_handler({i});\n        return value * {i};\n    }}\n"
+            ));
+        }
+        source.push_str("    0\n}\n");
+        let lines: Vec<&str> = source.lines().collect();
+
+        let mut chunker = AstChunker::with_limits(10, 120);
+        let chunks = chunker.chunk_file(Path::new("route.rs"), &source);
+        assert!(chunks.len() > 2, "long function should be split");
+        assert_eq!(chunker.last_stats().fallback_chunks, 0);
+
+        for chunk in &chunks {
+            // Each part ends after an `if` block or with the function
+            assert_eq!(lines[chunk.end_line - 1].trim(), "}");
+            assert_eq!(chunk.name.as_deref(), Some("route"));
+            assert_eq!(chunk.signature, chunks[0].signature);
+        }
+        for pair in chunks.windows(2) {
+            assert_eq!(pair[0].end_line + 1, pair[1].start_line);
+            assert!(lines[pair[1].start_line - 1].starts_with("    if kind"));
+        }
+        assert_eq!(chunks.last().unwrap().end_line, lines.len());
+    }
+
+    #[test]
+    fn test_unknown_files_are_chunked_in_overlapping_windows() {
+        use crate::embeddings::WhitespaceTokenizer;
+
+        let content = (0..200)
+            .map(|i| format!("key_{i} = value number {i}"))
+            .collect::<Vec<_>>()
+            .join("\n");
+
+        let mut chunker = AstChunker::with_limits(10, 1500)
+            .with_tokenizer(Arc::new(WhitespaceTokenizer::new()))
+            .with_fallback_window(50, 20);
+        let chunks = chunker.chunk_file(Path::new("settings.ini"), &content);
+
+        assert_eq!(chunker.last_stats().method_used, ChunkingMethod::LineBased);
+        assert!(chunks.len() > 2);
+        for pair in chunks.windows(2) {
+            // Neighbouring windows share lines
+            assert!(pair[1].start_line <= pair[0].end_line);
+            assert!(pair[1].start_line > pair[0].start_line);
+        }
+        assert_eq!(chunks.last().unwrap().end_line, 200);
+    }
+
+    #[test]
+    fn test_merged_chunk_lines_match_source() {
+        let source = "const A: u32 = 1;\n\n// spacer\nconst B: u32 = 2;\n";
+        let mut chunker = AstChunker::with_limits(100, 1500);
+        let chunks = chunker.chunk_file(Path::new("consts.rs"), source);
+
+        assert_eq!(chunks.len(), 1);
+        assert_eq!(chunks[0].start_line, 1);
+        assert_eq!(chunks[0].end_line, 4);
+        assert_eq!(
+            chunks[0].content,
+            "const A: u32 = 1;\n\n// spacer\nconst B: u32 = 2;"
+        );
+    }
+
+    #[test]
+    fn test_rust_chunks_start_at_doc_comments() {
+        let source = r#"use std::fmt;
+
+/// A bounded queue.
+///
+/// Pushing to a full queue drops the oldest item.
+#[derive(Debug, Clone)]
+pub struct Queue {
+    items: Vec<u32>,
+}
+
+impl Queue {
+    /// Add an item, evicting the oldest when full.
+    #[inline]
+    pub fn push(&mut self, item: u32) {
+        self.items.push(item);
+    }
+}
+
+// Not a doc comment
+macro_rules! queue {
+    () => {
+        Queue { items: Vec::new() }
+    };
+}
+"#;
+        let lines: Vec<&str> = source.lines().collect();
+        let mut chunker = AstChunker::with_limits(0, 1500);
+        let chunks = chunker.chunk_file(Path::new("src/queue.rs"), source);
+        let chunk = |name: &str| {
+            chunks
+                .iter()
+                .find(|c| c.name.as_deref() == Some(name))
+                .unwrap()
+        };
+
+        let queue = chunk("Queue");
+        assert_eq!(queue.semantic_kind, Some(SemanticKind::Struct));
+        assert_eq!((queue.start_line, queue.end_line), (3, 9));
+        assert!(queue.content.starts_with("/// A bounded queue."));
+
+        // Nested items keep their indentation, so content matches the lines
+        let push = chunk("push");
+        assert_eq!((push.start_line, push.end_line), (12, 16));
+        assert_eq!(push.content, lines[11..16].join("\n"));
+
+        let queue_macro = chunk("queue");
+        assert_eq!(queue_macro.semantic_kind, Some(SemanticKind::Macro));
+        assert_eq!(queue_macro.start_line, 20);
+        assert_eq!(queue_macro.doc, None);
+
+        // Docs do not hide the visibility
+        let extractor = extractors::RustExtractor;
+        let unit = |content: &str| SemanticUnit {
+            kind: SemanticKind::Function,
+            name: Some("push".to_string()),
+            content: content.to_string(),
+            docs: None,
+            start_line: 1,
+            end_line: 1,
+            start_byte: 0,
+            end_byte: content.len(),
+            signature: None,
+            parent: None,
+        };
+        assert!(extractor.is_exported(&unit(&push.content)));
+        assert!(!extractor.is_exported(&unit("/// Helper.\n#[inline]\nfn helper() {}")));
+    }
+
+    #[test]
+    fn test_leading_comments_are_attached_as_docs() {
+        let source = r#"package billing
+
+// Total sums the line amounts.
+//
+// Tax is added on top.
+func Total(amounts []int) int {
+	sum := 0
+	for _, a := range amounts {
+		sum += a
+	}
+	return sum
+}
+
+// --- Helpers ---
+
+func round(x int) int {
+	return x
+}
+"#;
+        let mut chunker = AstChunker::with_limits(0, 1500);
+        let chunks = chunker.chunk_file(Path::new("billing.go"), source);
+        let chunk = |name: &str| {
+            chunks
+                .iter()
+                .find(|c| c.name.as_deref() == Some(name))
+                .unwrap()
+        };
+
+        let total = chunk("Total");
+        assert_eq!((total.start_line, total.end_line), (3, 12));
+        assert!(total.content.starts_with("// Total sums the line amounts."));
+        assert_eq!(
+            total.doc.as_deref(),
+            Some("Total sums the line amounts.\n\nTax is added on top.")
+        );
+
+        // A comment separated by a blank line is not the function's
+        let round = chunk("round");
+        assert_eq!(round.start_line, 16);
+        assert_eq!(round.doc, None);
+
+        let source = "import os\n\n# Read the settings file,\n# or None when it is missing.\ndef load(path):\n    return None\n";
+        let chunks = chunker.chunk_file(Path::new("settings.py"), source);
+        let load = chunks
+            .iter()
+            .find(|c| c.name.as_deref() == Some("load"))
+            .unwrap();
+        assert_eq!(load.start_line, 3);
+        assert_eq!(
+            load.doc.as_deref(),
+            Some("Read the settings file,\nor None when it is missing.")
+        );
+    }
+
+    #[test]
+    fn test_small_units_merge_up_to_limit() {
+        let source = r#"package shapes
+
+const Pi = 3.14
+
+func Zero() int { return 0 }
+
+func (c *Circle) Area() float64 { return Pi * c.r * c.r }
+
+func (c *Circle) Radius() float64 { return c.r }
+
+func (s *Square) Area() float64 { return s.side * s.side }
+"#;
+        let mut chunker = AstChunker::with_limits(100, 1500);
+        let chunks = chunker.chunk_file(Path::new("shapes.go"), source);
+
+        // Consecutive small symbols share a chunk, but not across types
+        let lines: Vec<(usize, usize)> = chunks.iter().map(|c| (c.start_line, c.end_line)).collect();
+        assert_eq!(lines, vec![(3, 5), (7, 9), (11, 11)]);
+        assert_eq!(chunks[1].parent.as_deref(), Some("Circle"));
+        assert_eq!(chunker.last_stats().units_merged, 5);
+
+        // No merged chunk grows past the limit
+        let mut chunker = AstChunker::with_limits(100, 1500).with_merge_limit(10);
+        let chunks = chunker.chunk_file(Path::new("shapes.go"), source);
+        assert_eq!(chunks.len(), 5);
+    }
+
+    #[test]
+    fn test_tokenizer_controls_unit_size() {
+        use crate::embeddings::WhitespaceTokenizer;
+
+        // 41 characters but 7 whitespace tokens: oversized by the estimate,
+        // within limits when counted with the tokenizer
+        let source = "const LONG_NAME_VALUE: u32 = 1234567890;\n";
+
+        let mut estimated = AstChunker::with_limits(0, 5);
+        let chunks = estimated.chunk_file(Path::new("a.rs"), source);
+        assert!(estimated.last_stats().fallback_chunks > 0);
+        assert!(!chunks.is_empty());
+
+        let mut tokenized =
+            AstChunker::with_limits(0, 10).with_tokenizer(Arc::new(WhitespaceTokenizer::new()));
+        tokenized.chunk_file(Path::new("a.rs"), source);
+        assert_eq!(tokenized.last_stats().fallback_chunks, 0);
+        assert_eq!(tokenized.last_stats().method_used, ChunkingMethod::Ast);
+    }
+
+    fn names(chunks: &[Chunk]) -> Vec<&str> {
+        chunks.iter().filter_map(|c| c.name.as_deref()).collect()
+    }
+
+    #[test]
+    fn test_api_surface_indexes_only_exported_signatures() {
+        let source = r#"/// Connection pool.
+pub struct Pool {
+    conns: Vec<Conn>,
+}
+
+impl Pool {
+    /// Borrow a connection, waiting up to `timeout`.
+    pub fn acquire(&self, timeout: Duration) -> Conn {
+        let deadline = Instant::now() + timeout;
+        self.wait_until(deadline)
+    }
+
+    fn wait_until(&self, deadline: Instant) -> Conn {
+        loop {}
+    }
+}
+
+pub(crate) fn crate_helper() {}
+
+struct Internal;
+
+impl Internal {
+    pub fn unreachable(&self) {}
+}
+
+#[test]
+fn pool_works() {}
+"#;
+        let mut chunker = AstChunker::new().with_api_surface(true);
+        let chunks = chunker.chunk_file(Path::new("pool.rs"), source);
+
+        assert_eq!(names(&chunks), vec!["Pool", "acquire"]);
+        assert_eq!(chunks[0].content, "/// Connection pool.\npub struct Pool");
+        let acquire = &chunks[1];
+        assert!(acquire.content.starts_with("/// Borrow a connection"));
+        assert!(acquire.content.contains("pub fn acquire"));
+        assert!(!acquire.content.contains("deadline ="), "body was indexed");
+        assert_eq!((acquire.start_line, acquire.end_line), (8, 11));
+        assert_eq!(acquire.qualified_name.as_deref(), Some("crate::pool::Pool::acquire"));
+
+        // Files without an extractor have no API surface
+        let text = chunker.chunk_file(Path::new("notes.txt"), "pub fn looks_like_code() {}");
+        assert!(text.is_empty());
+    }
+
+    #[test]
+    fn test_api_surface_follows_python_conventions() {
+        let source = r#"def connect(url):
+    """Open a client for url."""
+    return Client(url)
+
+def _parse(url):
+    return url.split(":")
+
+class Client:
+    def __init__(self, url):
+        self.url = url
+
+    def send(self, payload):
+        return self._retry(payload)
+
+    def _retry(self, payload):
+        pass
+
+class _Hidden:
+    def run(self):
+        pass
+"#;
+        let mut chunker = AstChunker::new().with_api_surface(true);
+        let chunks = chunker.chunk_file(Path::new("client.py"), source);
+
+        assert_eq!(names(&chunks), vec!["connect", "Client", "__init__", "send"]);
+        assert!(chunks[0].content.contains("Open a client for url."));
+        assert!(!chunks[0].content.contains("return Client"));
+    }
+
+    /// A generated-looking file: `count` public functions, the same number
+    /// of private helpers, and a type with one method
+    fn generated_source(count: usize) -> String {
+        let mut source = String::from("pub struct Registry;\n\nimpl Registry {\n    pub fn get(&self) {}\n}\n");
+        for i in 0..count {
+            source.push_str(&format!("\npub fn op_{i}() -> u32 {{\n    helper_{i}()\n}}\n"));
+            source.push_str(&format!("\nfn helper_{i}() -> u32 {{\n    {i}\n}}\n"));
+        }
+        source
+    }
+
+    #[test]
+    fn test_symbol_cap_summarizes_large_file() {
+        let source = generated_source(20);
+        let mut chunker =
+            AstChunker::with_limits(0, 1500).with_symbol_cap(10, SymbolCapPolicy::Summarize);
+        let chunks = chunker.chunk_file(Path::new("ops.rs"), &source);
+
+        assert!(chunker.last_stats().symbol_cap_exceeded);
+        assert_eq!(chunks.len(), 10);
+        // Top-level exported symbols only, as signatures
+        assert_eq!(names(&chunks)[..3].to_vec(), vec!["Registry", "op_0", "op_1"]);
+        assert!(chunks.iter().all(|c| c.parent.is_none()));
+        assert!(!names(&chunks).iter().any(|n| n.starts_with("helper_")));
+        assert!(!chunks[1].content.contains("helper_0()"), "body was indexed");
+
+        // Files within the cap are chunked as usual
+        let small = generated_source(2);
+        let chunks = chunker.chunk_file(Path::new("ops.rs"), &small);
+        assert!(!chunker.last_stats().symbol_cap_exceeded);
+        assert!(names(&chunks).contains(&"helper_0"));
+    }
+
+    #[test]
+    fn test_symbol_cap_skips_large_file() {
+        let source = generated_source(20);
+        let mut chunker =
+            AstChunker::with_limits(0, 1500).with_symbol_cap(10, SymbolCapPolicy::Skip);
+        let chunks = chunker.chunk_file(Path::new("ops.rs"), &source);
+
+        assert!(chunks.is_empty());
+        assert!(chunker.last_stats().symbol_cap_exceeded);
+        assert_eq!(SymbolCapPolicy::parse("Skip"), Some(SymbolCapPolicy::Skip));
+        assert_eq!(SymbolCapPolicy::Summarize.as_str(), "summarize");
+    }
+
+    #[test]
+    fn test_granularity_rules_choose_chunk_size() {
+        let source = r#"class Cart:
+    def add(self, item):
+        self.items.append(item)
+
+    def total(self):
+        return sum(item.price for item in self.items)
+
+
+def empty_cart():
+    return Cart()
+"#;
+        let rules = [
+            GranularityRule {
+                languages: Vec::new(),
+                paths: vec!["util/**".to_string()],
+                level: Granularity::File,
+            },
+            GranularityRule {
+                languages: vec!["python".to_string()],
+                paths: Vec::new(),
+                level: Granularity::Type,
+            },
+        ];
+        let chunker = |max_tokens: usize| {
+            AstChunker::with_limits(0, max_tokens)
+                .with_granularity(Path::new("/repo"), &rules)
+                .unwrap()
+        };
+
+        // Methods stay in their class
+        let chunks = chunker(1500).chunk_file(Path::new("/repo/shop/cart.py"), source);
+        assert_eq!(names(&chunks), vec!["Cart", "empty_cart"]);
+        assert!(chunks[0].content.contains("def total(self):"));
+
+        // Small files are one chunk
+        let chunks = chunker(1500).chunk_file(Path::new("/repo/util/cart.py"), source);
+        assert_eq!(chunks.len(), 1);
+        assert_eq!(chunks[0].name.as_deref(), Some("cart"));
+        assert_eq!(chunks[0].semantic_kind, Some(SemanticKind::Module));
+        assert_eq!((chunks[0].start_line, chunks[0].end_line), (1, 10));
+
+        // Files too large for one chunk are chunked by function
+        let chunks = chunker(40).chunk_file(Path::new("/repo/util/cart.py"), source);
+        assert!(names(&chunks).contains(&"total"));
+
+        // Without rules, every symbol is a chunk
+        let mut chunker = AstChunker::with_limits(0, 1500);
+        let chunks = chunker.chunk_file(Path::new("/repo/shop/cart.py"), source);
+        assert!(names(&chunks).contains(&"add"));
+    }
+
+    #[test]
+    fn test_vue_component_script_is_parsed() {
+        let source = r#"<template>
+  <button @click="save">Save</button>
+</template>
+
+<script lang="ts">
+export default {
+  name: 'SaveButton',
+}
+
+export function save(invoice: Invoice): Promise<void> {
+  return api.post('/invoices', invoice)
+}
+</script>
+"#;
+        let mut chunker = AstChunker::with_limits(0, 1500);
+        let chunks = chunker.chunk_file(Path::new("SaveButton.vue"), source);
+
+        let template = &chunks[0];
+        assert_eq!(template.language.as_deref(), Some("vue"));
+        assert_eq!(template.semantic_kind, Some(SemanticKind::Block));
+        assert_eq!(template.signature.as_deref(), Some("<template>"));
+        assert_eq!((template.start_line, template.end_line), (1, 3));
+
+        let save = chunks
+            .iter()
+            .find(|c| c.name.as_deref() == Some("save"))
+            .expect("script function was not chunked");
+        assert_eq!(save.language.as_deref(), Some("typescript"));
+        assert_eq!(save.file_path, Path::new("SaveButton.vue"));
+        assert_eq!((save.start_line, save.end_line), (10, 12));
+        assert!(chunks
+            .iter()
+            .all(|c| c.tags.contains(&"component:savebutton".to_string())));
+    }
 }
@@ -3,6 +3,7 @@
 //! Provides parser management with language detection and grammar initialization.
 
 use std::collections::HashMap;
+use std::path::Path;
 
 use tree_sitter::{Language, Parser};
 use tracing::debug;
@@ -36,12 +37,19 @@ impl ParserPool {
         pool.register_language("java", tree_sitter_java::LANGUAGE.into());
         pool.register_language("c", tree_sitter_c::LANGUAGE.into());
         pool.register_language("cpp", tree_sitter_cpp::LANGUAGE.into());
+        pool.register_language("csharp", tree_sitter_c_sharp::LANGUAGE.into());
+        pool.register_language("ruby", tree_sitter_ruby::LANGUAGE.into());
+        // The HTML-aware grammar also parses pure PHP files
+        pool.register_language("php", tree_sitter_php::LANGUAGE_PHP.into());
+        pool.register_language("kotlin", tree_sitter_kotlin_ng::LANGUAGE.into());
+        pool.register_language("swift", tree_sitter_swift::LANGUAGE.into());
+        pool.register_language("scala", tree_sitter_scala::LANGUAGE.into());
 
         pool
     }
 
     /// Register a language with its tree-sitter grammar.
-    fn register_language(&mut self, id: &str, language: Language) {
+    pub fn register_language(&mut self, id: &str, language: Language) {
         self.languages.insert(id.to_string(), language);
     }
 
@@ -83,6 +91,25 @@ impl ParserPool {
         self.languages.get(language)
     }
 
+    /// Grammar for parsing a file indexed as `language`.
+    ///
+    /// `.tsx` files are indexed as TypeScript but need the TSX grammar,
+    /// which also parses their JSX.
+    pub fn grammar<'a>(path: &Path, language: &'a str) -> &'a str {
+        if language == "typescript" && path.extension().is_some_and(|ext| ext == "tsx") {
+            "tsx"
+        } else {
+            language
+        }
+    }
+
+    /// Whether `path` is a C or C++ header (`.h`, `.hpp`, ...)
+    pub fn is_header(path: &Path) -> bool {
+        path.extension()
+            .and_then(|ext| ext.to_str())
+            .is_some_and(|ext| matches!(ext, "h" | "hh" | "hpp" | "hxx" | "h++"))
+    }
+
     /// Detect language from file extension and return the appropriate language ID.
     pub fn detect_language_from_extension(ext: &str) -> Option<&'static str> {
         match ext {
@@ -97,6 +124,12 @@ impl ParserPool {
             "cc" | "cxx" | "cpp" | "c++" => Some("cpp"),
             "h" => Some("c"),  // Default .h to C
             "hpp" | "hxx" | "h++" | "hh" => Some("cpp"),
+            "cs" => Some("csharp"),
+            "rb" | "rake" => Some("ruby"),
+            "php" | "phtml" => Some("php"),
+            "kt" | "kts" => Some("kotlin"),
+            "swift" => Some("swift"),
+            "scala" | "sc" => Some("scala"),
             _ => None,
         }
     }
@@ -181,4 +214,25 @@ mod tests {
             None
         );
     }
+
+    #[test]
+    fn test_grammar() {
+        assert_eq!(
+            ParserPool::grammar(Path::new("web/App.tsx"), "typescript"),
+            "tsx"
+        );
+        assert_eq!(
+            ParserPool::grammar(Path::new("web/api.ts"), "typescript"),
+            "typescript"
+        );
+        assert_eq!(ParserPool::grammar(Path::new("main.go"), "go"), "go");
+    }
+
+    #[test]
+    fn test_is_header() {
+        assert!(ParserPool::is_header(Path::new("include/pool.h")));
+        assert!(ParserPool::is_header(Path::new("src/point.hpp")));
+        assert!(!ParserPool::is_header(Path::new("src/pool.c")));
+        assert!(!ParserPool::is_header(Path::new("Makefile")));
+    }
 }
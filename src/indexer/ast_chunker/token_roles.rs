@@ -0,0 +1,280 @@
+//! Roles of identifier tokens
+//!
+//! The same word can name a type in one chunk and a local variable in
+//! another; for a query like `Pool` the chunk declaring the type is usually
+//! the better match. [`token_roles`] parses a piece of code and classifies
+//! each identifier by what it names, so the lexical index can weight matches
+//! by role (`[search.term_boosts]`).
+//!
+//! Roles come from the syntax tree: type identifiers (and Ruby constants)
+//! are types, the names of function and method declarations and the
+//! callees of calls (including the method of a method call, as in
+//! `pool.Close()` or Ruby's `pool.close`) are functions, field and property identifiers are fields, and any other
+//! identifier (locals, parameters, receivers) is a local. Keywords and
+//! literals get no role.
+
+use tree_sitter::Node;
+
+use super::parser_pool::ParserPool;
+
+/// What an identifier names
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum TokenRole {
+    /// Type, struct, class, interface or enum name
+    Type,
+    /// Function or method name, declared or called
+    Function,
+    /// Field or property name
+    Field,
+    /// Local variable, parameter or other plain identifier
+    Local,
+}
+
+impl TokenRole {
+    /// All roles, in index field order
+    pub const ALL: [TokenRole; 4] = [Self::Type, Self::Function, Self::Field, Self::Local];
+
+    /// Parse role from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "type" => Some(Self::Type),
+            "function" => Some(Self::Function),
+            "field" => Some(Self::Field),
+            "local" => Some(Self::Local),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Type => "type",
+            Self::Function => "function",
+            Self::Field => "field",
+            Self::Local => "local",
+        }
+    }
+}
+
+/// Declarations whose `name` is a function
+const FUNCTION_DECLARATIONS: &[&str] = &[
+    "function_item",
+    "function_signature_item",
+    "function_declaration",
+    "method_declaration",
+    "method_elem",
+    "function_definition",
+    "method_definition",
+    "method_invocation",
+    "method",
+    "singleton_method",
+];
+
+/// Declarations whose `name` is a type, in grammars that name types with
+/// plain identifiers
+const TYPE_DECLARATIONS: &[&str] = &[
+    "class_definition",
+    "class_declaration",
+    "interface_declaration",
+    "enum_declaration",
+    "record_declaration",
+    "trait_declaration",
+];
+
+/// Call expressions whose `function` is the callee
+const CALLS: &[&str] = &[
+    "call_expression",
+    "call",
+    "invocation_expression",
+    "function_call_expression",
+];
+
+/// Method calls and the field naming the called method, as `capture` in
+/// Ruby's `payment.capture(total)` or PHP's `$payment->capture($total)`
+const METHOD_CALLS: &[(&str, &str)] = &[
+    ("call", "method"),
+    ("member_call_expression", "name"),
+    ("nullsafe_member_call_expression", "name"),
+    ("scoped_call_expression", "name"),
+];
+
+/// Member accesses and the field naming the member, as `Close` in
+/// `pool.Close`
+const MEMBERS: &[(&str, &str)] = &[
+    ("selector_expression", "field"),
+    ("member_expression", "property"),
+    ("attribute", "attribute"),
+    ("field_expression", "field"),
+    ("scoped_identifier", "name"),
+    ("member_access_expression", "name"),
+];
+
+/// Identifiers of `code` with their roles, in source order.
+///
+/// Empty when `language` has no grammar in `parsers` or the code cannot be
+/// parsed.
+pub fn token_roles(
+    parsers: &mut ParserPool,
+    language: &str,
+    code: &str,
+) -> Vec<(TokenRole, String)> {
+    let Some(tree) = parsers
+        .get_parser(language)
+        .and_then(|parser| parser.parse(code.as_bytes(), None))
+    else {
+        return Vec::new();
+    };
+
+    let mut tokens = Vec::new();
+    let mut stack = vec![tree.root_node()];
+    while let Some(node) = stack.pop() {
+        if node.child_count() == 0 {
+            // Nodes inserted by error recovery have no text
+            if let (Some(role), Ok(text)) = (role_of(node), node.utf8_text(code.as_bytes())) {
+                if !text.is_empty() {
+                    tokens.push((role, text.to_string()));
+                }
+            }
+            continue;
+        }
+        // Reversed so tokens come out in source order
+        let children: Vec<Node> = node.children(&mut node.walk()).collect();
+        stack.extend(children.into_iter().rev());
+    }
+    tokens
+}
+
+/// Role of a leaf node, if it is an identifier
+pub fn role_of(node: Node) -> Option<TokenRole> {
+    let role = match node.kind() {
+        "type_identifier" | "constant" => TokenRole::Type,
+        "field_identifier" | "property_identifier" | "shorthand_property_identifier" => {
+            TokenRole::Field
+        }
+        // PHP names functions, types and variables (`$total`) with `name`
+        "identifier" | "name" => TokenRole::Local,
+        _ => return None,
+    };
+    let Some(parent) = node.parent() else {
+        return Some(role);
+    };
+
+    let is_field = |field: &str| {
+        parent
+            .child_by_field_name(field)
+            .is_some_and(|child| child.id() == node.id())
+    };
+    let kind = parent.kind();
+    if is_field("name") && FUNCTION_DECLARATIONS.contains(&kind) {
+        Some(TokenRole::Function)
+    } else if is_field("name") && TYPE_DECLARATIONS.contains(&kind) {
+        Some(TokenRole::Type)
+    } else if is_field("function") && CALLS.contains(&kind) {
+        Some(TokenRole::Function)
+    } else if METHOD_CALLS
+        .iter()
+        .any(|&(call, field)| kind == call && is_field(field))
+    {
+        Some(TokenRole::Function)
+    } else if MEMBERS
+        .iter()
+        .any(|&(member, field)| kind == member && is_field(field))
+        && is_callee(parent)
+    {
+        Some(TokenRole::Function)
+    } else {
+        Some(role)
+    }
+}
+
+/// Whether `node` is the `function` of a call
+fn is_callee(node: Node) -> bool {
+    node.parent().is_some_and(|call| {
+        CALLS.contains(&call.kind())
+            && call
+                .child_by_field_name("function")
+                .is_some_and(|function| function.id() == node.id())
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn roles_of(language: &str, code: &str, name: &str) -> Vec<TokenRole> {
+        token_roles(&mut ParserPool::new(), language, code)
+            .into_iter()
+            .filter(|(_, text)| text == name)
+            .map(|(role, _)| role)
+            .collect()
+    }
+
+    #[test]
+    fn test_go_roles() {
+        let code = "type Pool struct {\n\tsize int\n}\n\nfunc (p *Pool) Drain() {\n\tpool := acquire(p.size)\n\tpool.Close()\n}\n";
+        assert_eq!(roles_of("go", code, "Pool"), vec![TokenRole::Type; 2]);
+        assert_eq!(roles_of("go", code, "size"), vec![TokenRole::Field; 2]);
+        assert_eq!(roles_of("go", code, "Drain"), vec![TokenRole::Function]);
+        assert_eq!(roles_of("go", code, "acquire"), vec![TokenRole::Function]);
+        // The method of a method call
+        assert_eq!(roles_of("go", code, "Close"), vec![TokenRole::Function]);
+        assert_eq!(roles_of("go", code, "pool"), vec![TokenRole::Local; 2]);
+        // Keywords have no role
+        assert!(roles_of("go", code, "func").is_empty());
+    }
+
+    #[test]
+    fn test_python_class_names_are_types() {
+        let code = "class Pool:\n    def drain(self):\n        pool = self.size\n";
+        assert_eq!(roles_of("python", code, "Pool"), vec![TokenRole::Type]);
+        assert_eq!(roles_of("python", code, "drain"), vec![TokenRole::Function]);
+        assert_eq!(roles_of("python", code, "pool"), vec![TokenRole::Local]);
+        assert!(token_roles(&mut ParserPool::new(), "cobol", code).is_empty());
+    }
+
+    #[test]
+    fn test_csharp_calls_are_functions() {
+        let code = "class Job {\n    void Run() {\n        store.Save(order);\n        Validate(order);\n    }\n}\n";
+        assert_eq!(roles_of("csharp", code, "Job"), vec![TokenRole::Type]);
+        assert_eq!(roles_of("csharp", code, "Run"), vec![TokenRole::Function]);
+        assert_eq!(roles_of("csharp", code, "Save"), vec![TokenRole::Function]);
+        assert_eq!(
+            roles_of("csharp", code, "Validate"),
+            vec![TokenRole::Function]
+        );
+        assert_eq!(roles_of("csharp", code, "order"), vec![TokenRole::Local; 2]);
+    }
+
+    #[test]
+    fn test_ruby_constants_and_method_calls() {
+        let code = "class Invoice\n  def settle\n    payment.capture(total)\n    Receipt.create(self)\n  end\nend\n";
+        assert_eq!(roles_of("ruby", code, "Invoice"), vec![TokenRole::Type]);
+        assert_eq!(roles_of("ruby", code, "Receipt"), vec![TokenRole::Type]);
+        assert_eq!(roles_of("ruby", code, "settle"), vec![TokenRole::Function]);
+        assert_eq!(roles_of("ruby", code, "capture"), vec![TokenRole::Function]);
+        assert_eq!(roles_of("ruby", code, "create"), vec![TokenRole::Function]);
+        assert_eq!(roles_of("ruby", code, "total"), vec![TokenRole::Local]);
+    }
+
+    #[test]
+    fn test_php_roles() {
+        let code = "<?php\nclass Invoice\n{\n    public function settle($payment)\n    {\n        $payment->capture($this->total);\n        log_event('settled');\n    }\n}\n";
+        assert_eq!(roles_of("php", code, "Invoice"), vec![TokenRole::Type]);
+        assert_eq!(roles_of("php", code, "settle"), vec![TokenRole::Function]);
+        assert_eq!(roles_of("php", code, "capture"), vec![TokenRole::Function]);
+        assert_eq!(
+            roles_of("php", code, "log_event"),
+            vec![TokenRole::Function]
+        );
+        assert_eq!(roles_of("php", code, "payment"), vec![TokenRole::Local; 2]);
+    }
+
+    #[test]
+    fn test_role_names() {
+        for role in TokenRole::ALL {
+            assert_eq!(TokenRole::parse(role.as_str()), Some(role));
+        }
+        assert_eq!(TokenRole::parse("Type"), Some(TokenRole::Type));
+        assert_eq!(TokenRole::parse("keyword"), None);
+    }
+}
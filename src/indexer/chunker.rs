@@ -1,8 +1,11 @@
 use std::path::{Path, PathBuf};
+use std::sync::Arc;
 
 use serde::{Deserialize, Serialize};
+use tracing::warn;
 
 use super::ast_chunker::extractors::SemanticKind;
+use crate::embeddings::Tokenizer;
 
 /// Strategy for chunking code files.
 #[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
@@ -49,16 +52,27 @@ pub struct Chunk {
     pub name: Option<String>,
     /// Signature for functions/methods
     pub signature: Option<String>,
+    /// Doc comment or leading comment block of the symbol, without comment
+    /// markers
+    pub doc: Option<String>,
     /// Parent context (class name for methods, impl target for Rust)
     pub parent: Option<String>,
+    /// Fully qualified name using the language's conventions (e.g. `pkg.Class.method`)
+    pub qualified_name: Option<String>,
+    /// Static analysis tags (e.g. `concurrency`)
+    pub tags: Vec<String>,
 }
 
 /// Splits source code files into chunks suitable for embedding
 pub struct Chunker {
-    /// Target chunk size in tokens (approximately 4 chars per token)
+    /// Target chunk size in tokens
     chunk_size: usize,
     /// Overlap between chunks in tokens
     overlap: usize,
+    /// Most lines repeated from the previous chunk, if limited
+    max_overlap_lines: Option<usize>,
+    /// Tokenizer used to measure lines (approximately 4 chars per token if unset)
+    tokenizer: Option<Arc<dyn Tokenizer>>,
 }
 
 impl Chunker {
@@ -66,14 +80,47 @@ impl Chunker {
     pub fn new(chunk_size: usize) -> Self {
         Self {
             chunk_size,
-            // 10% overlap by default
+            // 10% overlap by default, at most 5 lines
             overlap: chunk_size / 10,
+            max_overlap_lines: Some(5),
+            tokenizer: None,
         }
     }
 
-    /// Create a chunker with custom overlap
+    /// Create a chunker with custom overlap: a window of `chunk_size`
+    /// tokens, each repeating the last `overlap` tokens of the previous
+    /// one, rounded to whole lines
     pub fn with_overlap(chunk_size: usize, overlap: usize) -> Self {
-        Self { chunk_size, overlap }
+        Self {
+            chunk_size,
+            overlap,
+            max_overlap_lines: None,
+            tokenizer: None,
+        }
+    }
+
+    /// Count tokens with the embedding model's tokenizer instead of
+    /// approximating from character counts
+    pub fn with_tokenizer(mut self, tokenizer: Arc<dyn Tokenizer>) -> Self {
+        self.tokenizer = Some(tokenizer);
+        self
+    }
+
+    /// Size of a line in the units budgets are measured in: tokens when a
+    /// tokenizer is set, characters (including the newline) otherwise
+    fn line_size(&self, line: &str) -> usize {
+        match &self.tokenizer {
+            Some(tokenizer) => tokenizer.count_tokens(line),
+            None => line.len() + 1,
+        }
+    }
+
+    /// Convert a token budget into the units returned by [`Self::line_size`]
+    fn budget(&self, tokens: usize) -> usize {
+        match self.tokenizer {
+            Some(_) => tokens,
+            None => tokens * 4,
+        }
     }
 
     /// Chunk a file's content into smaller pieces
@@ -88,16 +135,22 @@ impl Chunker {
             return Vec::new();
         }
 
-        // Target characters per chunk (approx 4 chars per token)
-        let target_chars = self.chunk_size * 4;
-        let overlap_chars = self.overlap * 4;
+        let target_size = self.budget(self.chunk_size);
+        let overlap_size = self.budget(self.overlap);
 
         let mut chunks = Vec::new();
         let mut current_start = 0;
 
         while current_start < lines.len() {
             let (end_line, chunk_content) =
-                self.find_chunk_boundary(&lines, current_start, target_chars);
+                self.find_chunk_boundary(&lines, current_start, target_size);
+            if end_line == current_start && self.line_size(lines[end_line]) > target_size {
+                warn!(
+                    "Line {} of {:?} is over the chunk token limit; the embedding model may truncate it",
+                    end_line + 1,
+                    path
+                );
+            }
 
             if !chunk_content.trim().is_empty() {
                 chunks.push(Chunk {
@@ -110,12 +163,15 @@ impl Chunker {
                     semantic_kind: None,
                     name: None,
                     signature: None,
+                    doc: None,
                     parent: None,
+                    qualified_name: None,
+                    tags: Vec::new(),
                 });
             }
 
             // Move start forward, accounting for overlap
-            let overlap_lines = self.estimate_overlap_lines(&lines, end_line, overlap_chars);
+            let overlap_lines = self.estimate_overlap_lines(&lines, end_line, overlap_size);
             current_start = if end_line >= lines.len() - 1 {
                 lines.len()
             } else {
@@ -136,30 +192,36 @@ impl Chunker {
         &self,
         lines: &[&str],
         start_line: usize,
-        target_chars: usize,
+        target_size: usize,
     ) -> (usize, String) {
-        let mut char_count = 0;
+        let mut size = 0;
         let mut end_line = start_line;
         let mut last_good_break = start_line;
 
         for (i, line) in lines.iter().enumerate().skip(start_line) {
-            char_count += line.len() + 1; // +1 for newline
-
-            // Track good break points (blank lines, function boundaries)
-            if Self::is_good_break_point(line, lines.get(i + 1).copied()) {
-                last_good_break = i;
+            let line_size = self.line_size(line);
+            // A line that would take the chunk over the target starts the
+            // next chunk, unless it is this chunk's first line
+            let overflows = i > start_line && size + line_size > target_size;
+            if !overflows {
+                size += line_size;
+                end_line = i;
+
+                // Track good break points (blank lines, function boundaries)
+                if Self::is_good_break_point(line, lines.get(i + 1).copied()) {
+                    last_good_break = i;
+                }
             }
 
-            if char_count >= target_chars {
+            if overflows || size >= target_size {
                 // Try to break at a good boundary if within 20% of target
-                if last_good_break > start_line && last_good_break >= (i.saturating_sub(i / 5)) {
+                if last_good_break > start_line
+                    && last_good_break >= (end_line.saturating_sub(end_line / 5))
+                {
                     end_line = last_good_break;
-                } else {
-                    end_line = i;
                 }
                 break;
             }
-            end_line = i;
         }
 
         let content = lines[start_line..=end_line].join("\n");
@@ -211,20 +273,23 @@ impl Chunker {
         false
     }
 
-    /// Estimate how many lines to overlap based on target overlap characters
-    fn estimate_overlap_lines(&self, lines: &[&str], end_line: usize, overlap_chars: usize) -> usize {
-        let mut chars = 0;
+    /// Estimate how many lines to overlap based on the target overlap size
+    fn estimate_overlap_lines(&self, lines: &[&str], end_line: usize, overlap_size: usize) -> usize {
+        let mut size = 0;
         let mut count = 0;
 
         for i in (0..=end_line).rev() {
-            chars += lines[i].len() + 1;
+            size += self.line_size(lines[i]);
             count += 1;
-            if chars >= overlap_chars {
+            if size >= overlap_size {
                 break;
             }
         }
 
-        count.min(5) // Cap overlap at 5 lines
+        match self.max_overlap_lines {
+            Some(max) => count.min(max),
+            None => count,
+        }
     }
 
     /// Detect programming language from file extension
@@ -258,6 +323,7 @@ impl Chunker {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::embeddings::WhitespaceTokenizer;
 
     #[test]
     fn test_chunk_small_file() {
@@ -294,6 +360,40 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_chunks_stay_within_token_budget() {
+        let chunker = Chunker::new(20).with_tokenizer(Arc::new(WhitespaceTokenizer::new()));
+        let content = (0..30)
+            .map(|i| format!("let value_{} = compute({});", i, i))
+            .collect::<Vec<_>>()
+            .join("\n");
+
+        let chunks = chunker.chunk_file(Path::new("test.rs"), &content);
+
+        assert!(chunks.len() > 1);
+        for chunk in &chunks {
+            assert!(WhitespaceTokenizer::new().count_tokens(&chunk.content) <= 20);
+        }
+    }
+
+    #[test]
+    fn test_overlapping_windows() {
+        let chunker =
+            Chunker::with_overlap(40, 16).with_tokenizer(Arc::new(WhitespaceTokenizer::new()));
+        // 4 tokens per line: windows of 10 lines, 4 of them repeated
+        let content = (0..30)
+            .map(|i| format!("entry {} = {}", i, i))
+            .collect::<Vec<_>>()
+            .join("\n");
+
+        let chunks = chunker.chunk_file(Path::new("data.conf"), &content);
+
+        assert_eq!(chunks[0].start_line, 1);
+        assert_eq!(chunks[0].end_line, 10);
+        assert_eq!(chunks[1].start_line, 7);
+        assert_eq!(chunks.last().unwrap().end_line, 30);
+    }
+
     #[test]
     fn test_detect_language() {
         assert_eq!(
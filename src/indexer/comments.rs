@@ -0,0 +1,395 @@
+//! Comment stripping for embedding input
+//!
+//! Some codebases carry stale or misleading comments that skew semantic
+//! search. When `indexer.embed_comments` is disabled, chunk bodies are passed
+//! through [`strip_comments`] before embedding so the vector reflects the code
+//! itself. The stored chunk content is left untouched, so comments are still
+//! returned in results and remain searchable through BM25.
+//!
+//! The opposite case is code whose intent lives in inline comments. With
+//! `indexer.embed_comment_field`, [`body_comments`] collects the comments
+//! inside each symbol's body, and they are embedded on their own for
+//! `coderag search --field comments`.
+
+/// Comment syntax for a language
+struct CommentSyntax {
+    line: &'static [&'static str],
+    block: Option<(&'static str, &'static str)>,
+    /// Characters that open a string literal
+    quotes: &'static [char],
+    /// Whether `'x'` and `'\n'` are char literals while a single quote
+    /// elsewhere is not a string, as with Rust's lifetimes
+    char_literals: bool,
+}
+
+const C_STYLE: CommentSyntax = CommentSyntax {
+    line: &["//"],
+    block: Some(("/*", "*/")),
+    quotes: &['"', '\''],
+    char_literals: false,
+};
+
+const JS_STYLE: CommentSyntax = CommentSyntax {
+    line: &["//"],
+    block: Some(("/*", "*/")),
+    quotes: &['"', '\'', '`'],
+    char_literals: false,
+};
+
+const RUST_STYLE: CommentSyntax = CommentSyntax {
+    line: &["//"],
+    block: Some(("/*", "*/")),
+    // Single quotes are lifetimes as often as char literals, so they are
+    // only skipped when they close a char literal
+    quotes: &['"'],
+    char_literals: true,
+};
+
+const GO_STYLE: CommentSyntax = CommentSyntax {
+    line: &["//"],
+    block: Some(("/*", "*/")),
+    quotes: &['"', '\'', '`'],
+    char_literals: false,
+};
+
+const HASH_STYLE: CommentSyntax = CommentSyntax {
+    line: &["#"],
+    block: None,
+    quotes: &['"', '\''],
+    char_literals: false,
+};
+
+const PHP_STYLE: CommentSyntax = CommentSyntax {
+    line: &["//", "#"],
+    block: Some(("/*", "*/")),
+    quotes: &['"', '\''],
+    char_literals: false,
+};
+
+fn syntax_for(language: &str) -> Option<&'static CommentSyntax> {
+    match language {
+        "rust" => Some(&RUST_STYLE),
+        "javascript" | "typescript" => Some(&JS_STYLE),
+        "go" => Some(&GO_STYLE),
+        "java" | "c" | "cpp" | "csharp" | "kotlin" | "swift" | "scala" => Some(&C_STYLE),
+        "python" | "ruby" | "shell" => Some(&HASH_STYLE),
+        "php" => Some(&PHP_STYLE),
+        _ => None,
+    }
+}
+
+/// Build the text that is sent to the embedding model for a chunk.
+///
+/// Returns the content unchanged when `embed_comments` is true or the
+/// language has no known comment syntax.
+pub fn embedding_text(content: &str, language: Option<&str>, embed_comments: bool) -> String {
+    if embed_comments {
+        return content.to_string();
+    }
+    match language {
+        Some(lang) => strip_comments(content, lang),
+        None => content.to_string(),
+    }
+}
+
+/// Remove comments from source code.
+///
+/// This is a lexical pass rather than a full parse: string literals are
+/// skipped so comment markers inside them survive, and lines that only held
+/// a comment are dropped entirely so that adding or removing a comment line
+/// does not change the result.
+pub fn strip_comments(content: &str, language: &str) -> String {
+    match syntax_for(language) {
+        Some(syntax) => lex(content, syntax).code,
+        None => content.to_string(),
+    }
+}
+
+/// Comments inside a chunk's body, without their markers, one per line.
+///
+/// Comments before the first line of code are the symbol's documentation
+/// and are left out, so this is the intent written next to the code itself:
+/// inline `//` and `#` comments and block comments in the body. Returns
+/// `None` when there are none or the language is unknown.
+pub fn body_comments(content: &str, language: &str) -> Option<String> {
+    let lexed = lex(content, syntax_for(language)?);
+    let first_code_line = lexed.first_code_line?;
+    let comments: Vec<String> = lexed
+        .comments
+        .into_iter()
+        .filter(|(line, _)| *line >= first_code_line)
+        .map(|(_, text)| comment_text(&text))
+        .filter(|text| !text.is_empty())
+        .collect();
+    (!comments.is_empty()).then(|| comments.join("\n"))
+}
+
+/// Comment text without markers, decoration and line breaks
+fn comment_text(raw: &str) -> String {
+    raw.lines()
+        .map(|line| {
+            line.trim()
+                .trim_start_matches(['/', '!', '#', '*'])
+                .trim_end_matches('*')
+                .trim()
+        })
+        .filter(|line| !line.is_empty())
+        .collect::<Vec<_>>()
+        .join(" ")
+}
+
+/// Source split into code and comments
+struct Lexed {
+    /// The source without comments (see [`strip_comments`])
+    code: String,
+    /// Each comment's text between its markers, with its 0-based start line
+    comments: Vec<(usize, String)>,
+    /// 0-based line of the first code outside comments
+    first_code_line: Option<usize>,
+}
+
+fn lex(content: &str, syntax: &CommentSyntax) -> Lexed {
+    let chars: Vec<char> = content.chars().collect();
+    let mut out = String::with_capacity(content.len());
+    let mut comments = Vec::new();
+    let mut first_code_line = None;
+    // Current 0-based line in the input
+    let mut line = 0;
+    // Tracks whether the current output line had a comment removed from it
+    let mut line_had_comment = false;
+    let mut i = 0;
+
+    let starts_with = |i: usize, pat: &str| -> bool {
+        let mut j = i;
+        for pc in pat.chars() {
+            if j >= chars.len() || chars[j] != pc {
+                return false;
+            }
+            j += 1;
+        }
+        true
+    };
+
+    while i < chars.len() {
+        let c = chars[i];
+
+        // String literal: copy through to the closing quote
+        if syntax.quotes.contains(&c) {
+            first_code_line.get_or_insert(line);
+            out.push(c);
+            i += 1;
+            while i < chars.len() {
+                let sc = chars[i];
+                out.push(sc);
+                i += 1;
+                if sc == '\n' {
+                    line += 1;
+                }
+                if sc == '\\' && i < chars.len() {
+                    out.push(chars[i]);
+                    i += 1;
+                } else if sc == c {
+                    break;
+                }
+            }
+            continue;
+        }
+
+        if syntax.char_literals {
+            if let Some(len) = char_literal_len(&chars[i..]) {
+                first_code_line.get_or_insert(line);
+                out.extend(&chars[i..i + len]);
+                i += len;
+                continue;
+            }
+        }
+
+        if let Some((open, close)) = syntax.block {
+            if starts_with(i, open) {
+                i += open.chars().count();
+                line_had_comment = true;
+                let start = (i, line);
+                while i < chars.len() && !starts_with(i, close) {
+                    // Keep line structure so following code stays on its own line
+                    if chars[i] == '\n' {
+                        finish_line(&mut out, true);
+                        line += 1;
+                    }
+                    i += 1;
+                }
+                comments.push((start.1, chars[start.0..i].iter().collect()));
+                i = (i + close.chars().count()).min(chars.len());
+                continue;
+            }
+        }
+
+        if let Some(marker) = syntax.line.iter().find(|marker| starts_with(i, marker)) {
+            i += marker.chars().count();
+            let start = i;
+            while i < chars.len() && chars[i] != '\n' {
+                i += 1;
+            }
+            comments.push((line, chars[start..i].iter().collect()));
+            line_had_comment = true;
+            continue;
+        }
+
+        if c == '\n' {
+            finish_line(&mut out, line_had_comment);
+            line_had_comment = false;
+            line += 1;
+            i += 1;
+            continue;
+        }
+
+        if !c.is_whitespace() {
+            first_code_line.get_or_insert(line);
+        }
+        out.push(c);
+        i += 1;
+    }
+    let line_start = out.rfind('\n').map(|p| p + 1).unwrap_or(0);
+    if line_had_comment || line_start < out.len() {
+        finish_line(&mut out, line_had_comment);
+        // finish_line terminates the line; match the input's lack of a trailing newline
+        if !content.ends_with('\n') && out.ends_with('\n') {
+            out.pop();
+        }
+    }
+    Lexed {
+        code: out,
+        comments,
+        first_code_line,
+    }
+}
+
+/// Length of the char literal at the start of `chars`, such as `'"'`,
+/// `'\''` or `'\u{1F600}'`; None for anything else, including lifetimes
+fn char_literal_len(chars: &[char]) -> Option<usize> {
+    if chars.first() != Some(&'\'') {
+        return None;
+    }
+    let end = match chars.get(1)? {
+        // An escape runs to the next quote after the escaped character
+        '\\' => 3 + chars.get(3..)?.iter().take(8).position(|&c| c == '\'')?,
+        '\'' | '\n' => return None,
+        _ => 2,
+    };
+    (chars.get(end) == Some(&'\'')).then_some(end + 1)
+}
+
+/// Terminate the current output line, dropping it if only a comment was on it
+fn finish_line(out: &mut String, had_comment: bool) {
+    let line_start = out.rfind('\n').map(|p| p + 1).unwrap_or(0);
+    if had_comment {
+        let trimmed_len = out[line_start..].trim_end().len();
+        out.truncate(line_start + trimmed_len);
+        if trimmed_len == 0 || out[line_start..].trim().is_empty() {
+            out.truncate(line_start);
+            return;
+        }
+    }
+    out.push('\n');
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_strip_rust_comments() {
+        let code = "/// Adds one\nfn add(x: i32) -> i32 {\n    // increment\n    x + 1 /* inline */\n}\n";
+        let stripped = strip_comments(code, "rust");
+        assert_eq!(stripped, "fn add(x: i32) -> i32 {\n    x + 1\n}\n");
+    }
+
+    #[test]
+    fn test_strip_python_comments() {
+        let code = "def f():\n    # note\n    return 1  # trailing\n";
+        let stripped = strip_comments(code, "python");
+        assert_eq!(stripped, "def f():\n    return 1\n");
+    }
+
+    #[test]
+    fn test_comment_markers_in_strings_are_kept() {
+        let code = "let url = \"http://example.com\"; // site\n";
+        let stripped = strip_comments(code, "rust");
+        assert_eq!(stripped, "let url = \"http://example.com\";\n");
+
+        let code = "s = '# not a comment'\n";
+        assert_eq!(strip_comments(code, "python"), code);
+    }
+
+    #[test]
+    fn test_rust_char_literals_and_lifetimes() {
+        let code = "let q = '\"'; // quote\nlet c = b'/'; // slash\n";
+        assert_eq!(
+            strip_comments(code, "rust"),
+            "let q = '\"';\nlet c = b'/';\n"
+        );
+
+        let code = "let e = ['\\'', '\\\\', '\\u{22}']; // escapes\n";
+        assert_eq!(
+            strip_comments(code, "rust"),
+            "let e = ['\\'', '\\\\', '\\u{22}'];\n"
+        );
+
+        let code = "fn f<'a>(s: &'a str) -> &'a str { // lifetime\n    s // \"\n}\n";
+        assert_eq!(
+            strip_comments(code, "rust"),
+            "fn f<'a>(s: &'a str) -> &'a str {\n    s\n}\n"
+        );
+    }
+
+    #[test]
+    fn test_multiline_block_comment() {
+        let code = "int a;\n/*\n * long\n * description\n */\nint b;";
+        let stripped = strip_comments(code, "c");
+        assert_eq!(stripped, "int a;\nint b;");
+    }
+
+    #[test]
+    fn test_unknown_language_is_unchanged() {
+        let code = "# heading\nsome text";
+        assert_eq!(strip_comments(code, "markdown"), code);
+    }
+
+    #[test]
+    fn test_comments_do_not_influence_embedding_input() {
+        let a = "fn total(items: &[u32]) -> u32 {\n    // Sum all the prices\n    items.iter().sum()\n}";
+        let b = "fn total(items: &[u32]) -> u32 {\n    // TODO: this is outdated, it used to compute the average\n    // and nobody updated the comment\n    items.iter().sum()\n}";
+        let c = "fn total(items: &[u32]) -> u32 {\n    items.iter().sum()\n}";
+
+        let ea = embedding_text(a, Some("rust"), false);
+        let eb = embedding_text(b, Some("rust"), false);
+        let ec = embedding_text(c, Some("rust"), false);
+        assert_eq!(ea, eb);
+        assert_eq!(ea, ec);
+
+        // With comments enabled the input is the raw content
+        assert_eq!(embedding_text(b, Some("rust"), true), b);
+    }
+
+    #[test]
+    fn test_body_comments_exclude_docs() {
+        let code = "/// Charge the customer.\n\
+                    pub fn charge(order: &Order) -> Result<()> {\n    \
+                    // Retry at most three times so a flaky gateway cannot double-charge\n    \
+                    let attempts = 3; // see billing runbook\n    \
+                    /* capture only after\n     * the fraud check */\n    \
+                    capture(order, attempts)\n\
+                    }";
+        assert_eq!(
+            body_comments(code, "rust").unwrap(),
+            "Retry at most three times so a flaky gateway cannot double-charge\n\
+             see billing runbook\n\
+             capture only after the fraud check"
+        );
+
+        let python = "def f():\n    \"\"\"Docstring # not a comment.\"\"\"\n    return 1  # fallback value\n";
+        assert_eq!(body_comments(python, "python").unwrap(), "fallback value");
+
+        assert!(body_comments("// only a doc\nfn f() {}", "rust").is_none());
+        assert!(body_comments("fn f() { // x\n}", "markdown").is_none());
+    }
+}
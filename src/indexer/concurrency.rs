@@ -0,0 +1,212 @@
+//! Go concurrency primitive detection
+//!
+//! A targeted static check, not a full analysis: a Go chunk is tagged
+//! [`CONCURRENCY_TAG`] when its code (outside comments and string literals)
+//! uses any of
+//!
+//! - `go` statements (goroutines)
+//! - channels: `chan` types, the `<-` send/receive operator, `select`
+//! - the `sync` and `sync/atomic` packages (`sync.Mutex`, `atomic.AddInt64`, ...)
+//! - the `context` package (`context.Context`, `context.WithCancel`, ...)
+//!
+//! The tag enables `coderag search --tag concurrency "worker pool"`.
+
+/// Tag given to chunks that use concurrency primitives
+pub const CONCURRENCY_TAG: &str = "concurrency";
+
+/// A concurrency primitive found in Go code
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum GoPrimitive {
+    /// A `go` statement
+    Goroutine,
+    /// A `chan` type or `<-` operation
+    Channel,
+    /// A `select` statement
+    Select,
+    /// The `sync` or `sync/atomic` package
+    Sync,
+    /// The `context` package
+    Context,
+}
+
+impl GoPrimitive {
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Goroutine => "goroutine",
+            Self::Channel => "channel",
+            Self::Select => "select",
+            Self::Sync => "sync",
+            Self::Context => "context",
+        }
+    }
+}
+
+/// Concurrency primitives used by a piece of Go code, in detection order
+/// without duplicates.
+pub fn go_primitives(code: &str) -> Vec<GoPrimitive> {
+    let code = strip_comments_and_strings(code);
+    let mut found = Vec::new();
+    let mut add = |p: GoPrimitive| {
+        if !found.contains(&p) {
+            found.push(p);
+        }
+    };
+
+    if code.contains("<-") {
+        add(GoPrimitive::Channel);
+    }
+
+    let tokens = tokens(&code);
+    for (i, token) in tokens.iter().enumerate() {
+        let next = tokens.get(i + 1).map(String::as_str);
+        match token.as_str() {
+            "go" => add(GoPrimitive::Goroutine),
+            "chan" => add(GoPrimitive::Channel),
+            "select" => add(GoPrimitive::Select),
+            "sync" | "atomic" if next == Some(".") => add(GoPrimitive::Sync),
+            "context" if next == Some(".") => add(GoPrimitive::Context),
+            _ => {}
+        }
+    }
+
+    found
+}
+
+/// Whether a piece of Go code uses any concurrency primitive.
+pub fn uses_go_concurrency(code: &str) -> bool {
+    !go_primitives(code).is_empty()
+}
+
+/// Identifiers and `.` separators of the code
+fn tokens(code: &str) -> Vec<String> {
+    let mut tokens = Vec::new();
+    let mut current = String::new();
+    for c in code.chars() {
+        if c.is_alphanumeric() || c == '_' {
+            current.push(c);
+            continue;
+        }
+        if !current.is_empty() {
+            tokens.push(std::mem::take(&mut current));
+        }
+        if c == '.' {
+            tokens.push(".".to_string());
+        } else if !c.is_whitespace() {
+            tokens.push(String::new());
+        }
+    }
+    if !current.is_empty() {
+        tokens.push(current);
+    }
+    tokens
+}
+
+/// Replace comments and string/rune literals with spaces
+fn strip_comments_and_strings(code: &str) -> String {
+    let chars: Vec<char> = code.chars().collect();
+    let mut out = String::with_capacity(code.len());
+    let mut i = 0;
+
+    while i < chars.len() {
+        let c = chars[i];
+        let next = chars.get(i + 1).copied();
+        match c {
+            '/' if next == Some('/') => {
+                while i < chars.len() && chars[i] != '\n' {
+                    i += 1;
+                }
+                continue;
+            }
+            '/' if next == Some('*') => {
+                i += 2;
+                while i < chars.len() && !(chars[i] == '*' && chars.get(i + 1) == Some(&'/')) {
+                    i += 1;
+                }
+                i += 2;
+                out.push(' ');
+                continue;
+            }
+            '"' | '\'' | '`' => {
+                let quote = c;
+                i += 1;
+                while i < chars.len() && chars[i] != quote {
+                    if chars[i] == '\\' && quote != '`' {
+                        i += 1;
+                    }
+                    i += 1;
+                }
+                i += 1;
+                out.push(' ');
+                continue;
+            }
+            _ => out.push(c),
+        }
+        i += 1;
+    }
+
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_detects_primitives() {
+        let code = r#"
+func (wp *WorkerPool) Start() {
+    var mu sync.Mutex
+    ctx, cancel := context.WithCancel(context.Background())
+    go wp.worker(ctx)
+    select {
+    case t := <-wp.tasks:
+    }
+}"#;
+        assert_eq!(
+            go_primitives(code),
+            vec![
+                GoPrimitive::Channel,
+                GoPrimitive::Sync,
+                GoPrimitive::Context,
+                GoPrimitive::Goroutine,
+                GoPrimitive::Select,
+            ]
+        );
+        assert!(uses_go_concurrency("func f(c chan int) {}"));
+        assert!(uses_go_concurrency("atomic.AddInt64(&n, 1)"));
+    }
+
+    #[test]
+    fn test_plain_code_is_not_tagged() {
+        let code = r#"
+// go fetch results from the channel in a goroutine
+func Sum(numbers []int) int {
+    msg := "use sync.Mutex and context.Context <- here"
+    total := 0
+    for _, n := range numbers {
+        total += n
+    }
+    return total
+}"#;
+        assert!(go_primitives(code).is_empty());
+        // Identifiers merely containing the keywords do not count
+        assert!(!uses_go_concurrency("gopher := channel + selected"));
+    }
+
+    #[test]
+    fn test_fixture_functions() {
+        let fixture = include_str!("../../tests/fixtures/languages/go/sample_go.go");
+        let function = |name: &str| {
+            let start = fixture.find(name).unwrap();
+            let end = fixture[start..].find("\n}\n").unwrap() + start;
+            &fixture[start..end]
+        };
+
+        assert!(uses_go_concurrency(function(
+            "func (wp *WorkerPool) worker("
+        )));
+        assert!(uses_go_concurrency(function("func Pipeline(")));
+        assert!(!uses_go_concurrency(function("func Sum[")));
+    }
+}
@@ -0,0 +1,471 @@
+//! YAML and JSON config file chunking
+//!
+//! Config files are chunked by their keys rather than by lines. Each
+//! top-level entry becomes a [`SemanticKind::Config`] chunk named by its key
+//! and qualified by its key path, with the path of the enclosing entry as
+//! parent:
+//!
+//! - entries under a collection key such as `jobs` (GitHub Actions) or
+//!   `services` (Compose) are chunked one level down, one per job or
+//!   service: `jobs.build`, `services.api`
+//! - entries longer than [`MAX_BLOCK_LINES`] are chunked by their own keys,
+//!   or split into parts of that size when they have none
+//! - a Kubernetes manifest (a YAML document with `apiVersion`, `kind` and
+//!   `metadata`) is one chunk qualified as `<Kind>/<name>` and tagged
+//!   `kind:<kind>`
+//! - items of a top-level YAML sequence, as in an Ansible playbook, are
+//!   qualified by their index and named by their `name` key
+//!
+//! YAML files may hold several documents separated by `---`. The `#`
+//! comment lines directly above an entry are part of its chunk. Files that
+//! do not parse, and JSON files that are not an object, are left to regular
+//! chunking; so are OpenAPI specs, which [`super::openapi`] handles.
+
+use std::path::Path;
+
+use serde_json::Value;
+
+use super::ast_chunker::SemanticKind;
+use super::openapi::{indent, key_of};
+use super::struct_tags::struct_tag;
+use super::Chunk;
+
+/// Language identifier assigned to YAML chunks
+pub const YAML_LANGUAGE: &str = "yaml";
+
+/// Language identifier assigned to JSON chunks
+pub const JSON_LANGUAGE: &str = "json";
+
+/// Longest entry kept in one chunk
+pub const MAX_BLOCK_LINES: usize = 60;
+
+/// Top-level keys whose entries are chunked one by one: CI jobs and
+/// workflows, Compose services
+pub const COLLECTION_KEYS: &[&str] = &["jobs", "services", "workflows"];
+
+/// Chunk a YAML or JSON file into one chunk per entry.
+///
+/// Returns `None` when `path` is not a YAML or JSON file or does not parse,
+/// so callers can fall back to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    let language = match path.extension().and_then(|e| e.to_str())? {
+        "json" => JSON_LANGUAGE,
+        "yaml" | "yml" => YAML_LANGUAGE,
+        _ => return None,
+    };
+
+    let lines: Vec<&str> = content.lines().collect();
+    let documents = if language == JSON_LANGUAGE {
+        vec![(0, lines.len())]
+    } else {
+        yaml_documents(&lines)
+    };
+
+    let chunker = Chunker {
+        path,
+        language,
+        lines: &lines,
+    };
+    let mut chunks = Vec::new();
+    for (start, end) in documents {
+        let document = lines[start..end].join("\n");
+        let value: Value = if language == JSON_LANGUAGE {
+            let value: Value = serde_json::from_str(&document).ok()?;
+            value.as_object()?;
+            value
+        } else {
+            serde_yaml::from_str(&document).ok()?
+        };
+        if let Some(manifest) = Manifest::of(&value) {
+            chunker.push_manifest(&mut chunks, start, end, &manifest);
+        } else {
+            chunker.push_entries(&mut chunks, start, end, None);
+        }
+    }
+
+    Some(chunks)
+}
+
+/// Line ranges of the documents of a YAML stream, without `---` lines.
+fn yaml_documents(lines: &[&str]) -> Vec<(usize, usize)> {
+    let mut documents = Vec::new();
+    let mut start = 0;
+    for (i, line) in lines.iter().enumerate() {
+        if line.starts_with("---") || line.starts_with("...") {
+            documents.push((start, i));
+            start = i + 1;
+        }
+    }
+    documents.push((start, lines.len()));
+    documents.retain(|&(start, end)| start < end);
+    documents
+}
+
+/// The identity of a Kubernetes object
+struct Manifest {
+    api_version: String,
+    kind: String,
+    name: String,
+}
+
+impl Manifest {
+    /// The manifest described by a document, if it is one
+    fn of(value: &Value) -> Option<Self> {
+        let field = |key: &str| value.get(key).and_then(Value::as_str).map(str::to_string);
+        Some(Self {
+            api_version: field("apiVersion")?,
+            kind: field("kind")?,
+            name: value
+                .get("metadata")?
+                .get("name")
+                .and_then(Value::as_str)
+                .unwrap_or_default()
+                .to_string(),
+        })
+    }
+}
+
+/// Builds the chunks of one file
+struct Chunker<'a> {
+    path: &'a Path,
+    language: &'a str,
+    lines: &'a [&'a str],
+}
+
+impl Chunker<'_> {
+    /// Push a Kubernetes manifest on lines `start..end` as one chunk.
+    fn push_manifest(
+        &self,
+        chunks: &mut Vec<Chunk>,
+        start: usize,
+        end: usize,
+        manifest: &Manifest,
+    ) {
+        let Some((start, end)) = self.trim(start, end) else {
+            return;
+        };
+        let address = format!("{}/{}", manifest.kind, manifest.name);
+        let entry = Entry {
+            start,
+            end,
+            name: manifest.name.clone(),
+            path: address,
+            parent: None,
+            signature: format!(
+                "{} {} {}",
+                manifest.api_version, manifest.kind, manifest.name
+            ),
+            tags: vec![struct_tag("kind", &manifest.kind)],
+        };
+        self.push_parts(chunks, &entry);
+    }
+
+    /// Push the entries of the block on lines `start..end`, nested under
+    /// the key path `parent`.
+    fn push_entries(
+        &self,
+        chunks: &mut Vec<Chunk>,
+        start: usize,
+        end: usize,
+        parent: Option<&str>,
+    ) {
+        let lines = self.lines;
+        // Entries are the key lines or sequence items at the outermost
+        // indentation of the block
+        let Some(first) = (start..end).find(|&i| is_entry(lines[i])) else {
+            return;
+        };
+        let level = indent(lines[first]);
+        let sequence = lines[first].trim_start().starts_with('-');
+
+        let mut index = 0;
+        let mut i = first;
+        while i < end {
+            let line = lines[i];
+            if indent(line) != level
+                || !is_entry(line)
+                || line.trim_start().starts_with('-') != sequence
+            {
+                i += 1;
+                continue;
+            }
+            let block_end = self.block_end(i, end);
+            let key = if sequence {
+                format!("[{}]", index)
+            } else {
+                key_of(line.trim_start()).unwrap_or_default().to_string()
+            };
+            index += 1;
+            let path = match parent {
+                Some(parent) if sequence => format!("{}{}", parent, key),
+                Some(parent) => format!("{}.{}", parent, key),
+                None => key.clone(),
+            };
+
+            let long = block_end + 1 - i > MAX_BLOCK_LINES;
+            let collection = parent.is_none() && COLLECTION_KEYS.contains(&key.as_str());
+            let has_children = (i + 1..=block_end).any(|j| is_entry(lines[j]));
+            if (long || collection) && has_children && !sequence {
+                self.push_entries(chunks, i + 1, block_end + 1, Some(&path));
+            } else {
+                let entry = Entry {
+                    start: leading_comments_start(lines, i, start),
+                    end: block_end,
+                    name: if sequence {
+                        item_name(line).unwrap_or_else(|| key.clone())
+                    } else {
+                        key
+                    },
+                    path,
+                    parent: parent.map(str::to_string),
+                    signature: line
+                        .trim()
+                        .trim_end_matches(['{', '['])
+                        .trim_end()
+                        .to_string(),
+                    tags: Vec::new(),
+                };
+                self.push_parts(chunks, &entry);
+            }
+            i = block_end + 1;
+        }
+    }
+
+    /// Push an entry's chunk, split into parts of [`MAX_BLOCK_LINES`] lines
+    /// when longer.
+    fn push_parts(&self, chunks: &mut Vec<Chunk>, entry: &Entry) {
+        let mut part_start = entry.start;
+        while part_start <= entry.end {
+            let part_end = (part_start + MAX_BLOCK_LINES - 1).min(entry.end);
+            chunks.push(Chunk {
+                content: self.lines[part_start..=part_end].join("\n"),
+                file_path: self.path.to_path_buf(),
+                start_line: part_start + 1,
+                end_line: part_end + 1,
+                language: Some(self.language.to_string()),
+                semantic_kind: Some(SemanticKind::Config),
+                name: Some(entry.name.clone()),
+                signature: Some(entry.signature.clone()),
+                doc: None,
+                parent: entry.parent.clone(),
+                qualified_name: Some(entry.path.clone()),
+                tags: entry.tags.clone(),
+            });
+            part_start = part_end + 1;
+        }
+    }
+
+    /// Last line of the entry starting on `line`, before `end`.
+    ///
+    /// The entry holds the lines indented deeper than its key, YAML
+    /// sequence items at the key's indentation, and a JSON closing bracket
+    /// at the key's indentation.
+    fn block_end(&self, line: usize, end: usize) -> usize {
+        let base = indent(self.lines[line]);
+        let is_item = self.lines[line].trim_start().starts_with('-');
+        let mut block_end = line;
+        for i in line + 1..end {
+            let text = self.lines[i];
+            let trimmed = text.trim();
+            if trimmed.is_empty() || trimmed.starts_with('#') {
+                continue;
+            }
+            if indent(text) < base {
+                break;
+            }
+            if indent(text) == base {
+                if trimmed.starts_with(['}', ']']) {
+                    block_end = i;
+                } else if trimmed.starts_with('-') && !is_item {
+                    block_end = i;
+                    continue;
+                }
+                break;
+            }
+            block_end = i;
+        }
+        block_end
+    }
+
+    /// First and last non-blank lines of `start..end`.
+    fn trim(&self, start: usize, end: usize) -> Option<(usize, usize)> {
+        let first = (start..end).find(|&i| !self.lines[i].trim().is_empty())?;
+        let last = (start..end)
+            .rev()
+            .find(|&i| !self.lines[i].trim().is_empty())?;
+        Some((first, last))
+    }
+}
+
+/// A chunked entry
+struct Entry {
+    /// First and last line, 0-based
+    start: usize,
+    end: usize,
+    name: String,
+    /// Key path
+    path: String,
+    parent: Option<String>,
+    signature: String,
+    tags: Vec<String>,
+}
+
+/// Whether a line is a mapping key or a sequence item.
+fn is_entry(line: &str) -> bool {
+    let trimmed = line.trim_start();
+    key_of(trimmed).is_some() || trimmed == "-" || trimmed.starts_with("- ")
+}
+
+/// Value of the `name` key on a sequence item's first line: `- name: build`.
+fn item_name(line: &str) -> Option<String> {
+    let item = line.trim_start().strip_prefix('-')?.trim_start();
+    let value = item.strip_prefix("name:")?.trim();
+    let value = value.trim_matches(['"', '\'']);
+    (!value.is_empty()).then(|| value.to_string())
+}
+
+/// First line of the `#` comment block directly above `line`, not before
+/// `floor`.
+fn leading_comments_start(lines: &[&str], line: usize, floor: usize) -> usize {
+    let mut start = line;
+    while start > floor && lines[start - 1].trim_start().starts_with('#') {
+        start -= 1;
+    }
+    start
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn summary(chunks: &[Chunk]) -> Vec<(&str, Option<&str>, usize, usize)> {
+        chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.qualified_name.as_deref().unwrap(),
+                    c.parent.as_deref(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect()
+    }
+
+    #[test]
+    fn test_ci_jobs_are_chunked_one_by_one() {
+        let content = r#"name: CI
+on:
+  push:
+    branches: [main]
+
+jobs:
+  # Unit tests on every push.
+  test:
+    runs-on: ubuntu-latest
+    steps:
+    - uses: actions/checkout@v4
+    - run: cargo test
+  lint:
+    runs-on: ubuntu-latest
+"#;
+        let chunks = chunk_file(Path::new(".github/workflows/ci.yml"), content).unwrap();
+        assert_eq!(
+            summary(&chunks),
+            vec![
+                ("name", None, 1, 1),
+                ("on", None, 2, 4),
+                ("jobs.test", Some("jobs"), 7, 12),
+                ("jobs.lint", Some("jobs"), 13, 14),
+            ]
+        );
+
+        let test = &chunks[2];
+        assert_eq!(test.semantic_kind, Some(SemanticKind::Config));
+        assert_eq!(test.name.as_deref(), Some("test"));
+        assert_eq!(test.signature.as_deref(), Some("test:"));
+        assert_eq!(test.language.as_deref(), Some("yaml"));
+    }
+
+    #[test]
+    fn test_kubernetes_manifests_are_one_chunk_per_document() {
+        let content = r#"apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+"#;
+        let chunks = chunk_file(Path::new("k8s/api.yaml"), content).unwrap();
+        assert_eq!(
+            summary(&chunks),
+            vec![("Deployment/api", None, 1, 6), ("Service/api", None, 8, 11)]
+        );
+        assert_eq!(
+            chunks[0].signature.as_deref(),
+            Some("apps/v1 Deployment api")
+        );
+        assert_eq!(chunks[0].tags, vec!["kind:deployment"]);
+    }
+
+    #[test]
+    fn test_json_objects_and_yaml_sequences() {
+        let content = r#"{
+  "name": "web",
+  "scripts": {
+    "build": "vite build"
+  },
+  "dependencies": {}
+}
+"#;
+        let chunks = chunk_file(Path::new("package.json"), content).unwrap();
+        assert_eq!(
+            summary(&chunks),
+            vec![
+                ("name", None, 2, 2),
+                ("scripts", None, 3, 5),
+                ("dependencies", None, 6, 6)
+            ]
+        );
+        assert_eq!(chunks[1].signature.as_deref(), Some(r#""scripts":"#));
+
+        let playbook = "- name: web servers\n  hosts: web\n- hosts: db\n";
+        let chunks = chunk_file(Path::new("site.yml"), playbook).unwrap();
+        assert_eq!(
+            summary(&chunks),
+            vec![("[0]", None, 1, 2), ("[1]", None, 3, 3)]
+        );
+        assert_eq!(chunks[0].name.as_deref(), Some("web servers"));
+        assert_eq!(chunks[1].name.as_deref(), Some("[1]"));
+
+        assert!(chunk_file(Path::new("list.json"), "[1, 2]").is_none());
+        assert!(chunk_file(Path::new("bad.yaml"), "a: [").is_none());
+    }
+
+    #[test]
+    fn test_long_entries_are_split_by_key() {
+        let mut content = "env:\n".to_string();
+        for i in 0..70 {
+            content.push_str(&format!("  VAR_{}: value\n", i));
+        }
+        content.push_str("script: |\n");
+        for i in 0..70 {
+            content.push_str(&format!("  echo {}\n", i));
+        }
+        let chunks = chunk_file(Path::new(".gitlab-ci.yml"), &content).unwrap();
+
+        // env has keys of its own, the script block does not
+        assert_eq!(chunks.len(), 72);
+        assert_eq!(chunks[0].qualified_name.as_deref(), Some("env.VAR_0"));
+        let script: Vec<_> = chunks[70..]
+            .iter()
+            .map(|c| (c.start_line, c.end_line))
+            .collect();
+        assert_eq!(script, vec![(72, 131), (132, 142)]);
+    }
+}
@@ -0,0 +1,137 @@
+//! Context headers for embedding input
+//!
+//! A chunk often says little about where it lives: the body of a
+//! `worker(id int)` method does not mention the `WorkerPool` it belongs to.
+//! With `indexer.context_headers` enabled, the text embedded for a chunk
+//! starts with a short header naming its file, package and enclosing type,
+//! plus the signature of its symbol when the chunk is a later part of a
+//! split function and does not hold it. The header is embedding input only:
+//! stored chunks keep their content and BM25 does not index the header.
+
+use super::ast_chunker::extractors::normalize_parent;
+use super::Chunk;
+
+/// Tokens kept free in each chunk for its header, so the header does not
+/// push a chunk over the embedding model's input limit
+pub const HEADER_TOKEN_RESERVE: usize = 64;
+
+/// Characters separating the parts of qualified names
+const SEPARATORS: &[char] = &['.', ':', '\\', '/', '#'];
+
+/// Where a chunk sits in the code base
+#[derive(Debug, Clone, Copy, Default)]
+pub struct ChunkContext<'a> {
+    /// Path of the chunk's file, relative to the indexed root
+    pub file_path: &'a str,
+    pub name: Option<&'a str>,
+    pub qualified_name: Option<&'a str>,
+    pub parent: Option<&'a str>,
+    pub signature: Option<&'a str>,
+}
+
+impl<'a> ChunkContext<'a> {
+    /// Context of a chunk of the file at `file_path`
+    pub fn of(file_path: &'a str, chunk: &'a Chunk) -> Self {
+        Self {
+            file_path,
+            name: chunk.name.as_deref(),
+            qualified_name: chunk.qualified_name.as_deref(),
+            parent: chunk.parent.as_deref(),
+            signature: chunk.signature.as_deref(),
+        }
+    }
+}
+
+/// Header describing a chunk's context, one `Key: value` line per known
+/// fact. `content` is the chunk's content, checked for the signature.
+pub fn context_header(context: &ChunkContext, content: &str) -> String {
+    let mut lines = vec![format!("File: {}", context.file_path)];
+    if let Some(package) = package(context) {
+        lines.push(format!("Package: {}", package));
+    }
+    let parent = context.parent.map(normalize_parent).unwrap_or_default();
+    if !parent.is_empty() {
+        lines.push(format!("In: {}", parent));
+    }
+    if let Some(signature) = context
+        .signature
+        .filter(|s| !s.is_empty() && !content.contains(*s))
+    {
+        lines.push(format!("Signature: {}", signature));
+    }
+    lines.join("\n")
+}
+
+/// `text` prefixed with the context header of its chunk.
+pub fn with_context_header(context: &ChunkContext, content: &str, text: &str) -> String {
+    format!("{}\n\n{}", context_header(context, content), text)
+}
+
+/// Package or module of a symbol: its qualified name without the enclosing
+/// type and the symbol's own name, `pool` for `pool.WorkerPool.worker`.
+fn package(context: &ChunkContext) -> Option<String> {
+    let rest = context
+        .qualified_name?
+        .strip_suffix(context.name?)?
+        .trim_end_matches(SEPARATORS);
+    let parent = context.parent.map(normalize_parent).unwrap_or_default();
+    let rest = match rest.strip_suffix(parent.as_str()) {
+        Some(package) if !parent.is_empty() => package.trim_end_matches(SEPARATORS),
+        _ => rest,
+    };
+    (!rest.is_empty()).then(|| rest.to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_method_header_names_package_and_type() {
+        let context = ChunkContext {
+            file_path: "internal/pool/worker.go",
+            name: Some("worker"),
+            qualified_name: Some("pool.WorkerPool.worker"),
+            parent: Some("*WorkerPool"),
+            signature: Some("func (p *WorkerPool) worker(id int)"),
+        };
+        let content = "\tfor job := range p.jobs {\n\t\tjob.Run(id)\n\t}";
+        assert_eq!(
+            context_header(&context, content),
+            "File: internal/pool/worker.go\nPackage: pool\nIn: WorkerPool\nSignature: func (p *WorkerPool) worker(id int)"
+        );
+
+        // The first part of a function holds its signature already
+        let content = "func (p *WorkerPool) worker(id int) {\n\tdefer p.wg.Done()";
+        assert!(!context_header(&context, content).contains("Signature:"));
+    }
+
+    #[test]
+    fn test_header_of_plain_chunk() {
+        let context = ChunkContext {
+            file_path: "src/lib.rs",
+            name: Some("parse"),
+            qualified_name: Some("parse"),
+            ..Default::default()
+        };
+        assert_eq!(
+            context_header(&context, "fn parse() {}"),
+            "File: src/lib.rs"
+        );
+        assert_eq!(
+            with_context_header(&context, "fn parse() {}", "fn parse() {}"),
+            "File: src/lib.rs\n\nfn parse() {}"
+        );
+    }
+
+    #[test]
+    fn test_package_with_other_separators() {
+        let context = ChunkContext {
+            name: Some("push"),
+            qualified_name: Some("crate::stack::Stack::push"),
+            parent: Some("Stack<T>"),
+            ..Default::default()
+        };
+        assert_eq!(package(&context).as_deref(), Some("crate::stack"));
+    }
+}
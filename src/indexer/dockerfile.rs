@@ -0,0 +1,293 @@
+//! Dockerfile chunking by build stage
+//!
+//! Each `FROM` instruction starts a build stage, and each stage becomes a
+//! [`SemanticKind::Module`] chunk running to the next `FROM`, so a question
+//! about how the release image is built lands on that stage rather than on
+//! an arbitrary window of instructions. A stage is named by its `AS` alias,
+//! or by its index when it has none, as `COPY --from=0` refers to it. The
+//! signature is the `FROM` line and the base image is a tag without its
+//! version, `image:golang` for `FROM golang:1.22 AS build`. A stage built
+//! on an earlier one (`FROM build AS test`) has that stage as parent
+//! instead.
+//!
+//! The `#` comment lines directly above a `FROM` belong to its stage;
+//! parser directives and `ARG`s before the first `FROM` are an unnamed
+//! chunk. Files are indexed when `dockerfile` is in `indexer.extensions`:
+//! `Dockerfile`, `Containerfile`, variants such as `Dockerfile.dev` and
+//! `*.dockerfile` files.
+
+use std::path::Path;
+
+use super::ast_chunker::SemanticKind;
+use super::struct_tags::struct_tag;
+use super::Chunk;
+
+/// Language identifier assigned to Dockerfile chunks
+pub const LANGUAGE: &str = "dockerfile";
+
+/// Extension that enables Dockerfile indexing in `indexer.extensions`
+pub const EXTENSION: &str = "dockerfile";
+
+/// File names of Dockerfiles, also with a suffix as in `Dockerfile.dev`
+pub const FILE_NAMES: &[&str] = &["Dockerfile", "Containerfile"];
+
+/// Whether `path` is a Dockerfile: `Dockerfile`, `Dockerfile.<suffix>`,
+/// `Containerfile` or `<name>.dockerfile`.
+///
+/// Most Dockerfiles have no `dockerfile` extension, so the walker admits
+/// them separately.
+pub fn is_dockerfile(path: &Path) -> bool {
+    let Some(name) = path.file_name().and_then(|n| n.to_str()) else {
+        return false;
+    };
+    let stem = name.split_once('.').map_or(name, |(stem, _)| stem);
+    FILE_NAMES.contains(&stem)
+        || path
+            .extension()
+            .and_then(|e| e.to_str())
+            .is_some_and(|e| e.eq_ignore_ascii_case(EXTENSION))
+}
+
+/// Chunk a Dockerfile into one chunk per build stage.
+///
+/// Returns `None` when `path` is not a Dockerfile, so callers can fall back
+/// to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    if !is_dockerfile(path) {
+        return None;
+    }
+
+    let lines: Vec<&str> = content.lines().collect();
+    let stages = stages(&lines);
+
+    let mut chunks = Vec::new();
+    let mut aliases: Vec<String> = Vec::new();
+    let first = stages.first().map_or(lines.len(), |s| s.start);
+    if let Some(chunk) = block(path, &lines, 0, first) {
+        chunks.push(Chunk {
+            semantic_kind: Some(SemanticKind::Block),
+            ..chunk
+        });
+    }
+
+    for (index, stage) in stages.iter().enumerate() {
+        let end = stages.get(index + 1).map_or(lines.len(), |s| s.start);
+        let Some(chunk) = block(path, &lines, stage.start, end) else {
+            continue;
+        };
+
+        // A stage built on an earlier one names it instead of an image
+        let base = aliases
+            .iter()
+            .find(|alias| alias.eq_ignore_ascii_case(&stage.image))
+            .cloned();
+        let tags = match base {
+            Some(_) => Vec::new(),
+            None => vec![struct_tag("image", image_repository(&stage.image))],
+        };
+        let name = stage.alias.clone().unwrap_or_else(|| index.to_string());
+        aliases.extend(stage.alias.clone());
+
+        chunks.push(Chunk {
+            semantic_kind: Some(SemanticKind::Module),
+            name: Some(name.clone()),
+            qualified_name: Some(name),
+            signature: Some(stage.signature.clone()),
+            parent: base,
+            tags,
+            ..chunk
+        });
+    }
+
+    Some(chunks)
+}
+
+/// A build stage and the line it starts on, comments included
+struct Stage {
+    start: usize,
+    /// The `FROM` instruction, continuation lines joined
+    signature: String,
+    image: String,
+    alias: Option<String>,
+}
+
+/// Build stages of a Dockerfile, in order.
+fn stages(lines: &[&str]) -> Vec<Stage> {
+    let mut stages = Vec::new();
+    let mut i = 0;
+    while i < lines.len() {
+        let trimmed = lines[i].trim();
+        if trimmed.is_empty() || trimmed.starts_with('#') {
+            i += 1;
+            continue;
+        }
+
+        // An instruction runs on while its lines end with `\`
+        let start = i;
+        let mut words: Vec<&str> = Vec::new();
+        loop {
+            let line = lines[i].trim();
+            let continued = line.ends_with('\\');
+            if !line.starts_with('#') {
+                words.extend(line.trim_end_matches('\\').split_whitespace());
+            }
+            i += 1;
+            if !continued || i >= lines.len() {
+                break;
+            }
+        }
+
+        if !words
+            .first()
+            .is_some_and(|w| w.eq_ignore_ascii_case("FROM"))
+        {
+            continue;
+        }
+        // FROM [--platform=<platform>] <image> [AS <name>]
+        let mut args = words[1..].iter().filter(|w| !w.starts_with("--"));
+        let Some(image) = args.next() else {
+            continue;
+        };
+        let alias = match (args.next(), args.next()) {
+            (Some(keyword), Some(alias)) if keyword.eq_ignore_ascii_case("AS") => {
+                Some(alias.to_string())
+            }
+            _ => None,
+        };
+        stages.push(Stage {
+            start: leading_comments_start(lines, start),
+            signature: words.join(" "),
+            image: image.to_string(),
+            alias,
+        });
+    }
+    stages
+}
+
+/// Repository of an image reference, without its tag or digest:
+/// `golang` for `golang:1.22`, `registry:5000/app` for
+/// `registry:5000/app:v1@sha256:...`.
+fn image_repository(image: &str) -> &str {
+    let image = image.split_once('@').map_or(image, |(name, _)| name);
+    let name_start = image.rfind('/').map_or(0, |slash| slash + 1);
+    match image[name_start..].find(':') {
+        Some(colon) => &image[..name_start + colon],
+        None => image,
+    }
+}
+
+/// A chunk of the lines from `start` to `end`, without surrounding blank
+/// lines; `None` when they are all blank.
+fn block(path: &Path, lines: &[&str], start: usize, end: usize) -> Option<Chunk> {
+    let mut start = start;
+    let mut end = end;
+    while end > start && lines[end - 1].trim().is_empty() {
+        end -= 1;
+    }
+    while start < end && lines[start].trim().is_empty() {
+        start += 1;
+    }
+    if start == end {
+        return None;
+    }
+    Some(Chunk {
+        content: lines[start..end].join("\n"),
+        file_path: path.to_path_buf(),
+        start_line: start + 1,
+        end_line: end,
+        language: Some(LANGUAGE.to_string()),
+        semantic_kind: None,
+        name: None,
+        signature: None,
+        doc: None,
+        parent: None,
+        qualified_name: None,
+        tags: Vec::new(),
+    })
+}
+
+/// First line of the `#` comment block directly above `line`.
+fn leading_comments_start(lines: &[&str], line: usize) -> usize {
+    let mut start = line;
+    while start > 0 && lines[start - 1].trim_start().starts_with('#') {
+        start -= 1;
+    }
+    start
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_is_dockerfile() {
+        assert!(is_dockerfile(Path::new("Dockerfile")));
+        assert!(is_dockerfile(Path::new("deploy/Dockerfile.dev")));
+        assert!(is_dockerfile(Path::new("Containerfile")));
+        assert!(is_dockerfile(Path::new("api.dockerfile")));
+        assert!(!is_dockerfile(Path::new("Dockerfile_notes.md")));
+        assert!(!is_dockerfile(Path::new("main.rs")));
+    }
+
+    #[test]
+    fn test_stages_carry_base_images() {
+        let content = "# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.22
+
+# Compile a static binary.
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+WORKDIR /src
+RUN go build \\
+    -o /out/api ./cmd/api
+
+FROM build as test
+RUN go test ./...
+
+FROM gcr.io/distroless/static:nonroot@sha256:abc
+COPY --from=build /out/api /api
+ENTRYPOINT [\"/api\"]
+";
+        let chunks = chunk_file(Path::new("Dockerfile"), content).unwrap();
+        let summary: Vec<_> = chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.name.as_deref(),
+                    c.parent.as_deref(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                (SemanticKind::Block, None, None, 1, 2),
+                (SemanticKind::Module, Some("build"), None, 4, 8),
+                (SemanticKind::Module, Some("test"), Some("build"), 10, 11),
+                (SemanticKind::Module, Some("2"), None, 13, 15),
+            ]
+        );
+
+        assert_eq!(
+            chunks[1].signature.as_deref(),
+            Some("FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build")
+        );
+        assert_eq!(chunks[1].tags, vec!["image:golang"]);
+        assert!(chunks[2].tags.is_empty());
+        assert_eq!(chunks[3].tags, vec!["image:gcr.io/distroless/static"]);
+        assert!(chunk_file(Path::new("main.rs"), "FROM x").is_none());
+    }
+
+    #[test]
+    fn test_image_repository() {
+        assert_eq!(image_repository("golang:1.22"), "golang");
+        assert_eq!(
+            image_repository("registry:5000/app:v1"),
+            "registry:5000/app"
+        );
+        assert_eq!(image_repository("registry:5000/app"), "registry:5000/app");
+        assert_eq!(image_repository("alpine@sha256:abc"), "alpine");
+    }
+}
@@ -0,0 +1,242 @@
+//! Dotenv file chunking
+//!
+//! Config keys are often documented in a dotenv template such as
+//! `.env.example`, one `KEY=value` per line with comments describing it.
+//! Each assignment becomes a [`SemanticKind::Config`] chunk named by its key,
+//! whose content is the assignment together with its documentation: the
+//! block of comment lines directly above it (a blank line ends the block)
+//! and any inline `# comment` after the value. A query like "what does
+//! WORKER_POOL_SIZE control" then matches the key and its description, and
+//! `--kind config` limits search to config keys.
+//!
+//! Files are indexed when `env` is in `indexer.extensions`: `*.env` files
+//! and templates named `.env.<suffix>` or `<name>.env.<suffix>` for the
+//! suffixes in [`TEMPLATE_SUFFIXES`]. A plain `.env` is never indexed, since
+//! it usually holds real secrets.
+
+use std::path::Path;
+
+use super::ast_chunker::SemanticKind;
+use super::Chunk;
+
+/// Language identifier assigned to dotenv chunks
+pub const LANGUAGE: &str = "dotenv";
+
+/// Extension that enables dotenv indexing in `indexer.extensions`
+pub const EXTENSION: &str = "env";
+
+/// Suffixes of dotenv template files, as in `.env.example`
+pub const TEMPLATE_SUFFIXES: &[&str] = &["example", "sample", "template", "dist", "defaults"];
+
+/// Whether `path` is a dotenv template such as `.env.example` or
+/// `api.env.sample`.
+///
+/// Templates are hidden files without an `env` extension, so the walker
+/// admits them separately.
+pub fn is_template(path: &Path) -> bool {
+    let Some(name) = path.file_name().and_then(|n| n.to_str()) else {
+        return false;
+    };
+    match name.rsplit_once('.') {
+        Some((stem, suffix)) if TEMPLATE_SUFFIXES.contains(&suffix) => {
+            stem == ".env" || (stem.ends_with(".env") && stem.len() > ".env".len())
+        }
+        _ => false,
+    }
+}
+
+/// Whether `path` is parsed as a dotenv file: a template or a `*.env` file.
+pub fn is_dotenv(path: &Path) -> bool {
+    path.extension().and_then(|e| e.to_str()) == Some(EXTENSION) || is_template(path)
+}
+
+/// Chunk a dotenv file into one chunk per key.
+///
+/// Returns `None` when `path` is not a dotenv file, so callers can fall back
+/// to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    if !is_dotenv(path) {
+        return None;
+    }
+
+    let lines: Vec<&str> = content.lines().collect();
+    let mut chunks = Vec::new();
+    // First line of the comment block above the current line
+    let mut comment_start = None;
+    let mut i = 0;
+
+    while i < lines.len() {
+        let trimmed = lines[i].trim();
+        if trimmed.is_empty() {
+            comment_start = None;
+            i += 1;
+            continue;
+        }
+        if trimmed.starts_with('#') {
+            comment_start.get_or_insert(i);
+            i += 1;
+            continue;
+        }
+
+        let Some(assignment) = parse_assignment(&lines, i) else {
+            comment_start = None;
+            i += 1;
+            continue;
+        };
+        let start = comment_start.take().unwrap_or(i);
+        chunks.push(Chunk {
+            content: lines[start..=assignment.end].join("\n"),
+            file_path: path.to_path_buf(),
+            start_line: start + 1,
+            end_line: assignment.end + 1,
+            language: Some(LANGUAGE.to_string()),
+            semantic_kind: Some(SemanticKind::Config),
+            name: Some(assignment.key.to_string()),
+            signature: Some(format!(
+                "{}={}",
+                assignment.key,
+                assignment.value.lines().next().unwrap_or_default()
+            )),
+            doc: None,
+            parent: None,
+            qualified_name: Some(assignment.key.to_string()),
+            tags: Vec::new(),
+        });
+        i = assignment.end + 1;
+    }
+
+    Some(chunks)
+}
+
+/// A `KEY=value` assignment
+struct Assignment<'a> {
+    key: &'a str,
+    /// Value without quotes or inline comment
+    value: String,
+    /// Index of the last line of the assignment
+    end: usize,
+}
+
+/// Parse the assignment starting on line `i`.
+///
+/// Handles an optional `export` prefix, quoted values (a `#` inside quotes
+/// is not a comment) and quoted values spanning several lines.
+fn parse_assignment<'a>(lines: &[&'a str], i: usize) -> Option<Assignment<'a>> {
+    let line = lines[i].trim_start();
+    let line = line.strip_prefix("export ").unwrap_or(line);
+    let (key, rest) = line.split_once('=')?;
+    let key = key.trim();
+    if !is_key(key) {
+        return None;
+    }
+
+    let rest = rest.trim_start();
+    let Some(quote) = rest
+        .chars()
+        .next()
+        .filter(|c| matches!(c, '"' | '\'' | '`'))
+    else {
+        // Unquoted: an inline comment starts at a `#` after whitespace
+        let comment = rest
+            .char_indices()
+            .find(|&(pos, c)| c == '#' && (pos == 0 || rest[..pos].ends_with(char::is_whitespace)));
+        let value = comment.map_or(rest, |(pos, _)| &rest[..pos]);
+        return Some(Assignment {
+            key,
+            value: value.trim().to_string(),
+            end: i,
+        });
+    };
+
+    // Quoted: the value runs to the closing quote, possibly on a later line
+    let mut value = String::new();
+    let mut text = &rest[1..];
+    let mut end = i;
+    loop {
+        if let Some(close) = text.find(quote) {
+            value.push_str(&text[..close]);
+            break;
+        }
+        value.push_str(text);
+        if end + 1 >= lines.len() {
+            // Unterminated quote: keep what was read
+            break;
+        }
+        end += 1;
+        value.push('\n');
+        text = lines[end];
+    }
+
+    Some(Assignment { key, value, end })
+}
+
+/// Keys are shell-style names; some tools also allow `.` and `-`
+fn is_key(key: &str) -> bool {
+    let mut chars = key.chars();
+    chars
+        .next()
+        .is_some_and(|c| c.is_ascii_alphabetic() || c == '_')
+        && chars.all(|c| c.is_ascii_alphanumeric() || matches!(c, '_' | '.' | '-'))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunks(content: &str) -> Vec<Chunk> {
+        chunk_file(Path::new(".env.example"), content).unwrap()
+    }
+
+    #[test]
+    fn test_keys_with_comment_blocks() {
+        let content = "# Workers\n\n# Number of goroutines that process jobs.\n# Defaults to the CPU count.\nWORKER_POOL_SIZE=8\nexport LOG_LEVEL=info # debug, info or warn\n";
+        let chunks = chunks(content);
+        assert_eq!(chunks.len(), 2);
+
+        let pool = &chunks[0];
+        assert_eq!(pool.semantic_kind, Some(SemanticKind::Config));
+        assert_eq!(pool.name.as_deref(), Some("WORKER_POOL_SIZE"));
+        assert_eq!(pool.signature.as_deref(), Some("WORKER_POOL_SIZE=8"));
+        // The section heading is separated by a blank line
+        assert_eq!((pool.start_line, pool.end_line), (3, 5));
+        assert!(pool.content.starts_with("# Number of goroutines"));
+        assert!(!pool.content.contains("# Workers"));
+
+        let level = &chunks[1];
+        assert_eq!(level.signature.as_deref(), Some("LOG_LEVEL=info"));
+        assert_eq!((level.start_line, level.end_line), (6, 6));
+        assert!(level.content.contains("debug, info or warn"));
+    }
+
+    #[test]
+    fn test_quoted_values() {
+        let content = "COLOR=\"#ff0000\" # brand color\nEMPTY= # unset by default\nKEY='-----BEGIN KEY-----\nabc\n-----END KEY-----'\nNEXT=1\nnot an assignment\n";
+        let chunks = chunks(content);
+        let signatures: Vec<_> = chunks
+            .iter()
+            .filter_map(|c| c.signature.as_deref())
+            .collect();
+        assert_eq!(
+            signatures,
+            vec![
+                "COLOR=#ff0000",
+                "EMPTY=",
+                "KEY=-----BEGIN KEY-----",
+                "NEXT=1"
+            ]
+        );
+        assert_eq!((chunks[2].start_line, chunks[2].end_line), (3, 5));
+    }
+
+    #[test]
+    fn test_dotenv_file_names() {
+        assert!(is_template(Path::new(".env.example")));
+        assert!(is_template(Path::new("deploy/api.env.sample")));
+        assert!(!is_template(Path::new(".env")));
+        assert!(!is_template(Path::new(".env.local")));
+        assert!(!is_template(Path::new("config.example")));
+        assert!(is_dotenv(Path::new("docker/app.env")));
+        assert!(!is_dotenv(Path::new(".env")));
+        assert!(chunk_file(Path::new("main.rs"), "A=1").is_none());
+    }
+}
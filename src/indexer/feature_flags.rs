@@ -0,0 +1,206 @@
+//! Feature-flag usage detection
+//!
+//! Finds calls to the configured flag accessors (`indexer.flag_accessors`)
+//! whose first argument is a string literal, such as
+//! `flags.Enabled("new-checkout")` or `featureflag.Get('dark_mode')`.
+//! Each chunk checking a flag is tagged `flag:<name>`, so the enclosing
+//! symbol carries the flag as metadata and
+//! `coderag search --tag flag:new-checkout` finds it. `coderag flags` lists
+//! every usage with its location.
+//!
+//! This is a textual match, not a full analysis: flags read through
+//! variables or non-literal arguments are not found.
+
+use serde::Serialize;
+
+use super::Chunk;
+
+/// Prefix of the tag recording a checked flag
+pub const FLAG_TAG_PREFIX: &str = "flag:";
+
+/// Tag recording that a chunk checks `flag`.
+///
+/// Tags are matched case-insensitively, so the name is lowercased.
+pub fn flag_tag(flag: &str) -> String {
+    format!("{}{}", FLAG_TAG_PREFIX, flag.to_lowercase())
+}
+
+/// A flag check found in code
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct FlagUsage {
+    /// Flag name, as written in the string literal
+    pub flag: String,
+    /// Accessor that checks it (e.g. `flags.Enabled`)
+    pub accessor: String,
+    /// 1-based line within the scanned text
+    pub line: usize,
+}
+
+/// Finds calls to flag accessors
+#[derive(Debug, Clone, Default)]
+pub struct FlagDetector {
+    accessors: Vec<String>,
+}
+
+impl FlagDetector {
+    /// Create a detector for the given accessors.
+    ///
+    /// Accessors may be written as the callee (`flags.Enabled`) or as an
+    /// example call (`flags.Enabled("X")`); the argument is ignored.
+    pub fn new(accessors: &[String]) -> Self {
+        let accessors = accessors
+            .iter()
+            .map(|a| a.split('(').next().unwrap_or_default().trim().to_string())
+            .filter(|a| !a.is_empty())
+            .collect();
+        Self { accessors }
+    }
+
+    /// Whether no accessors are configured
+    pub fn is_empty(&self) -> bool {
+        self.accessors.is_empty()
+    }
+
+    /// Flag checks in `code`, in order of appearance
+    pub fn find(&self, code: &str) -> Vec<FlagUsage> {
+        let mut usages = Vec::new();
+        for (index, line) in code.lines().enumerate() {
+            let mut found: Vec<(usize, FlagUsage)> = Vec::new();
+            for accessor in &self.accessors {
+                for (offset, _) in line.match_indices(accessor.as_str()) {
+                    // `myflags.Enabled` is not a call of `flags.Enabled`
+                    let preceded_by_ident = line[..offset]
+                        .chars()
+                        .next_back()
+                        .is_some_and(|c| c.is_alphanumeric() || c == '_');
+                    if preceded_by_ident {
+                        continue;
+                    }
+                    if let Some(flag) = literal_argument(&line[offset + accessor.len()..]) {
+                        let usage = FlagUsage {
+                            flag: flag.to_string(),
+                            accessor: accessor.clone(),
+                            line: index + 1,
+                        };
+                        found.push((offset, usage));
+                    }
+                }
+            }
+            found.sort_by_key(|(offset, _)| *offset);
+            usages.extend(found.into_iter().map(|(_, usage)| usage));
+        }
+        usages
+    }
+
+    /// Tag a chunk with every flag it checks
+    pub fn tag(&self, chunk: &mut Chunk) {
+        for usage in self.find(&chunk.content) {
+            // Tags are stored comma-separated
+            if usage.flag.contains(',') {
+                continue;
+            }
+            let tag = flag_tag(&usage.flag);
+            if !chunk.tags.contains(&tag) {
+                chunk.tags.push(tag);
+            }
+        }
+    }
+}
+
+/// The string literal opening an argument list: `("name"` yields `name`.
+fn literal_argument(rest: &str) -> Option<&str> {
+    let rest = rest.trim_start().strip_prefix('(')?.trim_start();
+    let quote = rest
+        .chars()
+        .next()
+        .filter(|c| matches!(c, '"' | '\'' | '`'))?;
+    let literal = &rest[1..];
+    let end = literal.find(quote)?;
+    let flag = &literal[..end];
+    (!flag.trim().is_empty()).then_some(flag)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn detector() -> FlagDetector {
+        FlagDetector::new(&[
+            "flags.Enabled".to_string(),
+            "featureflag.Get(\"X\")".to_string(),
+        ])
+    }
+
+    fn chunk(content: &str) -> Chunk {
+        Chunk {
+            content: content.to_string(),
+            file_path: PathBuf::from("checkout.go"),
+            start_line: 1,
+            end_line: content.lines().count(),
+            language: Some("go".to_string()),
+            semantic_kind: None,
+            name: Some("Checkout".to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            qualified_name: None,
+            tags: Vec::new(),
+        }
+    }
+
+    #[test]
+    fn test_finds_flag_checks() {
+        let code = "func Checkout(ctx context.Context) error {\n\
+                    \tif flags.Enabled(\"new-checkout\") {\n\
+                    \t\treturn newCheckout(ctx)\n\
+                    \t}\n\
+                    \tif v := featureflag.Get( 'Dark_Mode' ); v {}\n\
+                    \treturn legacyCheckout(ctx)\n\
+                    }";
+        let usages = detector().find(code);
+
+        assert_eq!(
+            usages,
+            vec![
+                FlagUsage {
+                    flag: "new-checkout".to_string(),
+                    accessor: "flags.Enabled".to_string(),
+                    line: 2,
+                },
+                FlagUsage {
+                    flag: "Dark_Mode".to_string(),
+                    accessor: "featureflag.Get".to_string(),
+                    line: 5,
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn test_ignores_non_literal_and_other_accessors() {
+        let code = "flags.Enabled(name)\n\
+                    myflags.Enabled(\"shadowed\")\n\
+                    cfg.flags.Enabled(\"nested\")\n\
+                    flags.Enabled(\"\")";
+        let flags: Vec<String> = detector().find(code).into_iter().map(|u| u.flag).collect();
+        assert_eq!(flags, vec!["nested"]);
+    }
+
+    #[test]
+    fn test_tags_enclosing_chunk() {
+        let mut checkout = chunk(
+            "func Checkout() {\n\
+             \tif flags.Enabled(\"NewCheckout\") && flags.Enabled(\"NewCheckout\") {}\n\
+             }",
+        );
+        detector().tag(&mut checkout);
+        assert_eq!(checkout.tags, vec!["flag:newcheckout"]);
+
+        let mut plain = chunk("func Plain() {}");
+        detector().tag(&mut plain);
+        assert!(plain.tags.is_empty());
+
+        assert!(FlagDetector::new(&[" ".to_string()]).is_empty());
+    }
+}
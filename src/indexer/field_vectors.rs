@@ -0,0 +1,209 @@
+//! Auxiliary per-field vectors
+//!
+//! Besides its main vector, a chunk can get short vectors for single
+//! fields, stored in their own tables and searched with
+//! `coderag search --field <field>`:
+//!
+//! - `name` - the symbol's name variants (`indexer.embed_name_variants`,
+//!   see [`super::name_variants`])
+//! - `comments` - the comments inside the symbol's body
+//!   (`indexer.embed_comment_field`, see [`super::comments::body_comments`])
+//! - `summary` - a summary of the chunk written by an LLM
+//!   (`indexer.summaries`, see [`super::summaries`])
+//! - `doc`, `body` and `structure` - the parts of the chunk that
+//!   `search --weights` scores separately (`indexer.embed_weighted_fields`,
+//!   see [`crate::search::field_weights`])
+
+use anyhow::{Context, Result};
+
+use super::comments::body_comments;
+use super::name_variants::name_variants;
+use crate::config::IndexerConfig;
+use crate::embeddings::EmbeddingGenerator;
+use crate::search::field_weights::{split_fields, Field};
+use crate::storage::{FieldVector, IndexedChunk, VectorField};
+
+/// Fields enabled in the config
+pub fn enabled_fields(config: &IndexerConfig) -> Vec<VectorField> {
+    let mut fields = Vec::new();
+    if config.embed_name_variants {
+        fields.push(VectorField::Name);
+    }
+    if config.embed_comment_field {
+        fields.push(VectorField::Comments);
+    }
+    if config.embed_weighted_fields {
+        fields.extend(Field::ALL.iter().map(Field::vector_field));
+    }
+    fields
+}
+
+/// Texts of one field of a chunk; empty when the chunk lacks the field.
+///
+/// Summaries are not derived from the chunk and are always empty here.
+pub fn field_texts(field: VectorField, chunk: &IndexedChunk) -> Vec<String> {
+    match field {
+        VectorField::Name => chunk
+            .symbol_name
+            .as_deref()
+            .map(|name| name_variants(name, chunk.qualified_name.as_deref()))
+            .unwrap_or_default(),
+        VectorField::Comments => chunk
+            .language
+            .as_deref()
+            .and_then(|language| body_comments(&chunk.content, language))
+            .into_iter()
+            .collect(),
+        VectorField::Summary => Vec::new(),
+        VectorField::Doc | VectorField::Body | VectorField::Structure => {
+            let [doc, body, structure] = split_fields(&chunk.content);
+            match field {
+                VectorField::Doc => doc,
+                VectorField::Body => body,
+                _ => structure,
+            }
+            .into_iter()
+            .collect()
+        }
+    }
+}
+
+/// Embed the texts of `fields` for every chunk.
+///
+/// Chunks need their final ids, since the vectors refer to them.
+pub async fn embed_fields(
+    embedder: &EmbeddingGenerator,
+    chunks: &[IndexedChunk],
+    fields: &[VectorField],
+    batch_size: usize,
+) -> Result<Vec<(VectorField, Vec<FieldVector>)>> {
+    let mut embedded = Vec::with_capacity(fields.len());
+
+    for &field in fields {
+        let entries: Vec<(&IndexedChunk, String)> = chunks
+            .iter()
+            .flat_map(|chunk| {
+                field_texts(field, chunk)
+                    .into_iter()
+                    .map(move |t| (chunk, t))
+            })
+            .collect();
+        let vectors = embed_texts(embedder, field, &entries, batch_size).await?;
+        embedded.push((field, vectors));
+    }
+
+    Ok(embedded)
+}
+
+/// Embed field texts, each given with the chunk it belongs to
+pub async fn embed_texts(
+    embedder: &EmbeddingGenerator,
+    field: VectorField,
+    entries: &[(&IndexedChunk, String)],
+    batch_size: usize,
+) -> Result<Vec<FieldVector>> {
+    let mut vectors = Vec::with_capacity(entries.len());
+    for batch in entries.chunks(batch_size.max(1)) {
+        let texts: Vec<String> = batch.iter().map(|(_, text)| text.clone()).collect();
+        let embeddings = embedder
+            .embed_async(&texts)
+            .await
+            .with_context(|| format!("Failed to embed {} field", field.as_str()))?;
+        for ((chunk, text), vector) in batch.iter().zip(embeddings) {
+            vectors.push(FieldVector {
+                chunk_id: chunk.id.clone(),
+                file_path: chunk.file_path.clone(),
+                branch: chunk.branch.clone(),
+                text: text.clone(),
+                vector,
+            });
+        }
+    }
+    Ok(vectors)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunk(name: &str, content: &str) -> IndexedChunk {
+        IndexedChunk {
+            id: "c1".to_string(),
+            content: content.to_string(),
+            file_path: "billing.go".to_string(),
+            start_line: 1,
+            end_line: content.lines().count(),
+            language: Some("go".to_string()),
+            vector: Vec::new(),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    #[test]
+    fn test_field_texts() {
+        let charge = chunk(
+            "ChargeCard",
+            "func ChargeCard() {\n\t// never retry declined cards\n\tsubmit()\n}",
+        );
+        assert_eq!(
+            field_texts(VectorField::Name, &charge),
+            vec!["ChargeCard", "charge card"]
+        );
+        assert_eq!(
+            field_texts(VectorField::Comments, &charge),
+            vec!["never retry declined cards"]
+        );
+
+        let plain = chunk("Submit", "func Submit() {}");
+        assert!(field_texts(VectorField::Comments, &plain).is_empty());
+
+        let documented = chunk(
+            "Refund",
+            "// Refund returns a charge to the card\nfunc Refund() {\n\tsubmit()\n}",
+        );
+        assert_eq!(
+            field_texts(VectorField::Doc, &documented),
+            vec!["// Refund returns a charge to the card"]
+        );
+        assert_eq!(
+            field_texts(VectorField::Body, &documented),
+            vec!["func Refund() {\n\tsubmit()\n}"]
+        );
+        assert_eq!(
+            field_texts(VectorField::Structure, &documented),
+            vec!["func Refund() {"]
+        );
+        assert!(field_texts(VectorField::Doc, &plain).is_empty());
+    }
+
+    #[test]
+    fn test_enabled_fields() {
+        let mut config = IndexerConfig::default();
+        assert!(enabled_fields(&config).is_empty());
+
+        config.embed_comment_field = true;
+        assert_eq!(enabled_fields(&config), vec![VectorField::Comments]);
+
+        config.embed_weighted_fields = true;
+        assert_eq!(
+            enabled_fields(&config),
+            vec![
+                VectorField::Comments,
+                VectorField::Doc,
+                VectorField::Body,
+                VectorField::Structure
+            ]
+        );
+    }
+}
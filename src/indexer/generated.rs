@@ -0,0 +1,156 @@
+//! Generated code detection
+//!
+//! Generated files are recognized by the marker comments that code
+//! generators put in their headers (Go's `// Code generated ... DO NOT EDIT.`,
+//! `@generated`, and similar) or by conventional generated file names such as
+//! `*.pb.go` and `*_pb2.py`.
+//!
+//! Generated API clients get special treatment: their functions and methods
+//! are what SDK users search for, so they are tagged as
+//! [`SemanticKind::ClientMethod`](super::SemanticKind::ClientMethod) instead
+//! of being excluded.
+
+use std::path::Path;
+
+/// Number of leading lines searched for generator markers
+const HEADER_LINES: usize = 30;
+
+/// Header phrases marking a file as generated (matched case-insensitively)
+const GENERATED_MARKERS: &[&str] = &[
+    "code generated",
+    "do not edit",
+    "@generated",
+    "auto-generated",
+    "auto generated",
+    "autogenerated",
+    "automatically generated",
+];
+
+/// File name suffixes of generated files
+const GENERATED_SUFFIXES: &[&str] = &[
+    ".pb.go",
+    "_grpc.pb.go",
+    "_pb2.py",
+    "_pb2_grpc.py",
+    ".g.dart",
+    ".generated.ts",
+    ".generated.cs",
+    ".gen.go",
+];
+
+/// Header phrases naming API client generators (matched case-insensitively)
+const CLIENT_GENERATORS: &[&str] = &[
+    "openapi-generator",
+    "openapi generator",
+    "swagger-codegen",
+    "swagger codegen",
+    "openapi-typescript-codegen",
+    "oapi-codegen",
+    "autorest",
+    "kiota",
+    "smithy",
+    "stainless",
+    "speakeasy",
+];
+
+/// Path components that mark a generated file as part of an API client
+const CLIENT_PATH_COMPONENTS: &[&str] = &["client", "clients", "sdk", "api"];
+
+/// Whether a file looks machine-generated.
+pub fn is_generated(path: &Path, content: &str) -> bool {
+    let file_name = path
+        .file_name()
+        .map(|n| n.to_string_lossy().to_lowercase())
+        .unwrap_or_default();
+    if GENERATED_SUFFIXES.iter().any(|s| file_name.ends_with(s)) {
+        return true;
+    }
+
+    let header = header(content);
+    GENERATED_MARKERS.iter().any(|m| header.contains(m))
+}
+
+/// Whether a file is a generated API client.
+///
+/// The file must be generated, and either name a known client generator in
+/// its header or live under a `client`, `sdk` or `api` directory (or have
+/// `client` in its file name).
+pub fn is_generated_client(path: &Path, content: &str) -> bool {
+    if !is_generated(path, content) {
+        return false;
+    }
+
+    let header = header(content);
+    if CLIENT_GENERATORS.iter().any(|g| header.contains(g)) {
+        return true;
+    }
+
+    let in_client_dir = path.parent().map_or(false, |dir| {
+        dir.components().any(|c| {
+            let name = c.as_os_str().to_string_lossy().to_lowercase();
+            CLIENT_PATH_COMPONENTS.contains(&name.as_str())
+        })
+    });
+    let client_file = path.file_stem().map_or(false, |s| {
+        s.to_string_lossy().to_lowercase().contains("client")
+    });
+
+    in_client_dir || client_file
+}
+
+/// Lowercased leading lines of a file
+fn header(content: &str) -> String {
+    content
+        .lines()
+        .take(HEADER_LINES)
+        .collect::<Vec<_>>()
+        .join("\n")
+        .to_lowercase()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_detects_generated_markers() {
+        let go = "// Code generated by protoc-gen-go. DO NOT EDIT.\npackage pb\n";
+        assert!(is_generated(Path::new("user.go"), go));
+        assert!(is_generated(
+            Path::new("lib.rs"),
+            "// @generated\nfn a() {}"
+        ));
+        assert!(is_generated(Path::new("user.pb.go"), "package pb"));
+        assert!(!is_generated(Path::new("main.go"), "package main\n"));
+    }
+
+    #[test]
+    fn test_markers_only_checked_in_header() {
+        let mut content = "package main\n".repeat(HEADER_LINES);
+        content.push_str("// Code generated DO NOT EDIT\n");
+        assert!(!is_generated(Path::new("main.go"), &content));
+    }
+
+    #[test]
+    fn test_detects_generated_clients() {
+        let openapi = "/* Generated by openapi-generator. Do not edit. */\nclass UsersApi {}";
+        assert!(is_generated_client(Path::new("src/users.ts"), openapi));
+
+        let generated = "// Code generated. DO NOT EDIT.\npackage x\n";
+        assert!(is_generated_client(
+            Path::new("pkg/sdk/users.go"),
+            generated
+        ));
+        assert!(is_generated_client(Path::new("users_client.go"), generated));
+        // Generated, but not a client
+        assert!(!is_generated_client(
+            Path::new("internal/models.go"),
+            generated
+        ));
+        // A hand-written client is not generated
+        assert!(!is_generated_client(
+            Path::new("pkg/client/users.go"),
+            "package client\n"
+        ));
+    }
+}
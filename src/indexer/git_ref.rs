@@ -0,0 +1,216 @@
+//! Git refs
+//!
+//! `coderag index --branch <ref>` indexes the tree of a branch, tag or commit
+//! into the same store as the working tree, so two branches can be searched
+//! and compared side by side. Files are read with `git archive`, leaving the
+//! working tree and checkout untouched, and are indexed under `<ref>:<path>`
+//! (git's own notation for a file at a ref). Every chunk records the ref in
+//! its `branch` column.
+
+use anyhow::{bail, Context, Result};
+use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
+use tracing::debug;
+
+use super::archive::{self, ArchiveFile};
+use crate::config::IndexerConfig;
+
+/// Separator between the ref name and the path inside it
+pub const REF_SEPARATOR: char = ':';
+
+/// A git ref resolved to a commit
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GitRef {
+    /// Name as given by the user (e.g. `main`, `v1.2.0`)
+    pub name: String,
+    /// Full commit hash the name pointed to
+    pub commit: String,
+    /// Commit time (Unix seconds), used as the mtime of its files
+    pub timestamp: i64,
+}
+
+/// Resolve a ref name in the repository at `root`.
+pub fn resolve(root: &Path, name: &str) -> Result<GitRef> {
+    if name.is_empty() || name.starts_with('-') {
+        bail!("Invalid git ref {:?}", name);
+    }
+
+    let commit = git(
+        root,
+        &["rev-parse", "--verify", &format!("{}^{{commit}}", name)],
+    )
+    .with_context(|| format!("Unknown git ref '{}'", name))?;
+    let timestamp = git(root, &["show", "-s", "--format=%ct", &commit])?
+        .parse()
+        .unwrap_or(0);
+
+    Ok(GitRef {
+        name: name.to_string(),
+        commit,
+        timestamp,
+    })
+}
+
+/// Path under which a file of `git_ref` is indexed.
+pub fn ref_path(git_ref: &str, relative: &Path) -> PathBuf {
+    let relative = relative.to_string_lossy().replace('\\', "/");
+    PathBuf::from(format!("{}{}{}", git_ref, REF_SEPARATOR, relative))
+}
+
+/// Path of an indexed file relative to its ref, if it was indexed from one.
+pub fn strip_ref<'a>(path: &'a str, git_ref: &str) -> Option<&'a str> {
+    path.strip_prefix(git_ref)?.strip_prefix(REF_SEPARATOR)
+}
+
+/// Read the indexable source files of a ref.
+///
+/// The tree is exported with `git archive` into a temporary directory that
+/// is removed before returning. Files are selected with the same extension
+/// and ignore rules as a normal walk.
+pub fn read_ref(root: &Path, git_ref: &GitRef, config: &IndexerConfig) -> Result<Vec<ArchiveFile>> {
+    let temp = tempfile::Builder::new()
+        .prefix("coderag-ref-")
+        .tempdir()
+        .context("Failed to create export directory")?;
+
+    let mut child = Command::new("git")
+        .arg("-C")
+        .arg(root)
+        .args(["archive", "--format=tar", &git_ref.commit])
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+        .context("Failed to run git archive")?;
+    let stdout = child
+        .stdout
+        .take()
+        .context("git archive produced no output")?;
+    tar::Archive::new(stdout)
+        .unpack(temp.path())
+        .with_context(|| format!("Failed to export ref '{}'", git_ref.name))?;
+    let output = child.wait_with_output()?;
+    if !output.status.success() {
+        bail!(
+            "git archive failed for '{}': {}",
+            git_ref.name,
+            String::from_utf8_lossy(&output.stderr).trim()
+        );
+    }
+
+    let files = archive::read_tree(temp.path(), config, |relative| {
+        ref_path(&git_ref.name, relative)
+    });
+    debug!(
+        "Read {} files from ref {} ({})",
+        files.len(),
+        git_ref.name,
+        git_ref.commit
+    );
+    Ok(files)
+}
+
+/// Run git in `root` and return its trimmed stdout
+fn git(root: &Path, args: &[&str]) -> Result<String> {
+    let output = Command::new("git")
+        .arg("-C")
+        .arg(root)
+        .args(args)
+        .output()
+        .context("Failed to run git")?;
+    if !output.status.success() {
+        bail!(
+            "git {} failed: {}",
+            args.join(" "),
+            String::from_utf8_lossy(&output.stderr).trim()
+        );
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).trim().to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::fs;
+    use tempfile::tempdir;
+
+    fn run(root: &Path, args: &[&str]) {
+        let status = Command::new("git")
+            .arg("-C")
+            .arg(root)
+            .args(["-c", "user.name=test", "-c", "user.email=test@example.com"])
+            .args(args)
+            .status()
+            .unwrap();
+        assert!(status.success(), "git {:?} failed", args);
+    }
+
+    /// Repository with `main` and a `feature` branch that changes one
+    /// function and adds another
+    fn synthetic_repo() -> tempfile::TempDir {
+        let dir = tempdir().unwrap();
+        let root = dir.path();
+        run(root, &["init", "-q", "-b", "main"]);
+        fs::write(
+            root.join("lib.rs"),
+            "pub fn shared() -> u32 {\n    1\n}\n\npub fn changed() -> u32 {\n    2\n}\n",
+        )
+        .unwrap();
+        run(root, &["add", "."]);
+        run(root, &["commit", "-q", "-m", "main"]);
+
+        run(root, &["checkout", "-q", "-b", "feature"]);
+        fs::write(
+            root.join("lib.rs"),
+            "pub fn shared() -> u32 {\n    1\n}\n\npub fn changed() -> u32 {\n    20\n}\n\npub fn added() {}\n",
+        )
+        .unwrap();
+        run(root, &["commit", "-q", "-am", "feature"]);
+        run(root, &["checkout", "-q", "main"]);
+        dir
+    }
+
+    fn config() -> IndexerConfig {
+        IndexerConfig {
+            extensions: vec!["rs".to_string()],
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_ref_paths() {
+        let path = ref_path("main", Path::new("src/lib.rs"));
+        assert_eq!(path, PathBuf::from("main:src/lib.rs"));
+        assert_eq!(strip_ref("main:src/lib.rs", "main"), Some("src/lib.rs"));
+        assert_eq!(strip_ref("mainline:src/lib.rs", "main"), None);
+    }
+
+    #[test]
+    fn test_read_two_refs() {
+        let repo = synthetic_repo();
+
+        let main = resolve(repo.path(), "main").unwrap();
+        let feature = resolve(repo.path(), "feature").unwrap();
+        assert_ne!(main.commit, feature.commit);
+
+        let main_files = read_ref(repo.path(), &main, &config()).unwrap();
+        let feature_files = read_ref(repo.path(), &feature, &config()).unwrap();
+
+        assert_eq!(main_files.len(), 1);
+        assert_eq!(main_files[0].path, PathBuf::from("main:lib.rs"));
+        assert!(!main_files[0].content.contains("added"));
+        assert_eq!(feature_files[0].path, PathBuf::from("feature:lib.rs"));
+        assert!(feature_files[0].content.contains("pub fn added()"));
+
+        // The working tree is left on main
+        assert!(!fs::read_to_string(repo.path().join("lib.rs"))
+            .unwrap()
+            .contains("added"));
+    }
+
+    #[test]
+    fn test_unknown_ref() {
+        let repo = synthetic_repo();
+        assert!(resolve(repo.path(), "missing").is_err());
+        assert!(resolve(repo.path(), "--help").is_err());
+    }
+}
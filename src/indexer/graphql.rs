@@ -0,0 +1,439 @@
+//! GraphQL schema and operation chunking
+//!
+//! Each top-level definition of a `.graphql` or `.gql` file becomes a chunk
+//! named by the type, operation or fragment it defines:
+//!
+//! - `type` and `input` are [`SemanticKind::Struct`] chunks, `interface` an
+//!   [`SemanticKind::Interface`] and `enum` an [`SemanticKind::Enum`]
+//! - `union` and `scalar` are [`SemanticKind::TypeAlias`] chunks
+//! - `query`, `mutation` and `subscription` operations are
+//!   [`SemanticKind::Function`] chunks
+//! - fragments, `schema` and `directive` definitions are
+//!   [`SemanticKind::Block`] chunks; a fragment's parent is its type
+//!   condition
+//!
+//! `extend type User { ... }` is a chunk of the extended type's kind. Field
+//! names are recorded as `field:<name>` tags: the fields a type, input or
+//! interface declares, and the root fields an operation selects, so
+//! `--tag field:createInvoice` finds the mutation field's declaration and
+//! the operations calling it. Enums are tagged `value:<NAME>` per value.
+//! A definition's description string and the `#` comment lines directly
+//! above it are part of its chunk.
+
+use std::path::Path;
+
+use super::ast_chunker::SemanticKind;
+use super::struct_tags::struct_tag;
+use super::Chunk;
+
+/// Language identifier assigned to GraphQL chunks
+pub const LANGUAGE: &str = "graphql";
+
+/// Extensions of GraphQL files
+pub const EXTENSIONS: &[&str] = &["graphql", "gql"];
+
+/// Keywords starting a top-level definition
+const DEFINITION_KEYWORDS: &[&str] = &[
+    "type",
+    "input",
+    "interface",
+    "enum",
+    "union",
+    "scalar",
+    "schema",
+    "directive",
+    "extend",
+    "query",
+    "mutation",
+    "subscription",
+    "fragment",
+];
+
+/// Chunk a GraphQL file into one chunk per top-level definition.
+///
+/// Returns `None` when `path` is not a GraphQL file, so callers can fall
+/// back to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    let extension = path.extension().and_then(|e| e.to_str())?;
+    if !EXTENSIONS.contains(&extension) {
+        return None;
+    }
+
+    let lines: Vec<&str> = content.lines().collect();
+    let line_of = |pos: usize| content[..pos].matches('\n').count();
+    let tokens = tokenize(content);
+    let text = |i: usize| tokens.get(i).map(|t| t.text).unwrap_or_default();
+
+    let mut chunks = Vec::new();
+    // Offset of the description string of the next definition
+    let mut description = None;
+    let mut i = 0;
+
+    while i < tokens.len() {
+        if tokens[i].text.starts_with('"') {
+            description = Some(tokens[i].pos);
+            i += 1;
+            continue;
+        }
+
+        let extend = text(i) == "extend";
+        let keyword_index = if extend { i + 1 } else { i };
+        let keyword = text(keyword_index);
+        if keyword != "{" && (keyword == "extend" || !DEFINITION_KEYWORDS.contains(&keyword)) {
+            description = None;
+            i += 1;
+            continue;
+        }
+
+        // Anonymous operations start with their selection set
+        let (kind, mut j) = match keyword {
+            "{" => (SemanticKind::Function, keyword_index),
+            _ => (kind_of(keyword), keyword_index + 1),
+        };
+        let name = match keyword {
+            "{" | "schema" => None,
+            "directive" if text(j) == "@" => {
+                j += 2;
+                Some(format!("@{}", text(j - 1)))
+            }
+            _ if is_name(text(j)) => {
+                j += 1;
+                Some(text(j - 1).to_string())
+            }
+            _ => None,
+        };
+        let parent = (keyword == "fragment" && text(j) == "on").then(|| text(j + 1).to_string());
+
+        // The header runs to the body's `{`, or to the next definition for
+        // bodiless ones such as unions and scalars
+        let mut parens = 0;
+        while j < tokens.len() {
+            match text(j) {
+                "(" => parens += 1,
+                ")" => parens -= 1,
+                "{" if parens == 0 => break,
+                word if parens == 0 && is_definition_start(&tokens, j) && word != "{" => break,
+                _ => {}
+            }
+            j += 1;
+        }
+        let header_end = tokens.get(j).map_or(content.len(), |t| t.pos);
+        let signature = match keyword {
+            "{" => "query".to_string(),
+            _ => content[tokens[i].pos..header_end]
+                .split_whitespace()
+                .collect::<Vec<_>>()
+                .join(" "),
+        };
+
+        let (end, tags) = if text(j) == "{" {
+            let close = matching_brace(&tokens, j);
+            let tags = body_tags(keyword, &tokens[j..close]);
+            (close, tags)
+        } else {
+            (j.saturating_sub(1).max(keyword_index), Vec::new())
+        };
+        let end_pos = tokens
+            .get(end)
+            .map_or(content.len(), |t| t.pos + t.text.len());
+
+        let first = description.take().unwrap_or(tokens[i].pos);
+        let start = leading_comments_start(&lines, line_of(first));
+        let end_line = line_of(end_pos.saturating_sub(1)).min(lines.len() - 1);
+        chunks.push(Chunk {
+            content: lines[start..=end_line].join("\n"),
+            file_path: path.to_path_buf(),
+            start_line: start + 1,
+            end_line: end_line + 1,
+            language: Some(LANGUAGE.to_string()),
+            semantic_kind: Some(kind),
+            qualified_name: name.clone(),
+            name,
+            signature: Some(signature),
+            doc: None,
+            parent,
+            tags,
+        });
+        i = end + 1;
+    }
+
+    Some(chunks)
+}
+
+/// Kind of the chunk of a definition starting with `keyword`.
+fn kind_of(keyword: &str) -> SemanticKind {
+    match keyword {
+        "type" | "input" => SemanticKind::Struct,
+        "interface" => SemanticKind::Interface,
+        "enum" => SemanticKind::Enum,
+        "union" | "scalar" => SemanticKind::TypeAlias,
+        "query" | "mutation" | "subscription" => SemanticKind::Function,
+        _ => SemanticKind::Block,
+    }
+}
+
+/// Whether token `i` starts a definition: a keyword followed by a name, a
+/// body or (for `schema`) directives.
+fn is_definition_start(tokens: &[Token], i: usize) -> bool {
+    let text = |i: usize| tokens.get(i).map(|t| t.text).unwrap_or_default();
+    DEFINITION_KEYWORDS.contains(&text(i))
+        && (is_name(text(i + 1)) || matches!(text(i + 1), "{" | "@" | "("))
+}
+
+/// Index of the `}` closing the `{` at `open`, or the last token.
+fn matching_brace(tokens: &[Token], open: usize) -> usize {
+    let mut depth = 0;
+    for (i, token) in tokens.iter().enumerate().skip(open) {
+        match token.text {
+            "{" => depth += 1,
+            "}" => {
+                depth -= 1;
+                if depth == 0 {
+                    return i;
+                }
+            }
+            _ => {}
+        }
+    }
+    tokens.len() - 1
+}
+
+/// `field:<name>` tags for the fields declared or selected at the top of
+/// `body`, or `value:<NAME>` tags for an enum's values.
+fn body_tags(keyword: &str, body: &[Token]) -> Vec<String> {
+    let text = |i: usize| body.get(i).map(|t| t.text).unwrap_or_default();
+    let selection = matches!(
+        keyword,
+        "{" | "query" | "mutation" | "subscription" | "fragment"
+    );
+    let mut tags: Vec<String> = Vec::new();
+    let mut braces = 0;
+    let mut parens = 0;
+    for i in 0..body.len() {
+        match text(i) {
+            "{" => braces += 1,
+            "}" => braces -= 1,
+            "(" => parens += 1,
+            ")" => parens -= 1,
+            word if braces == 1 && parens == 0 && is_name(word) => {
+                let previous = if i > 0 { text(i - 1) } else { "" };
+                let next = text(i + 1);
+                let tag = if selection {
+                    // Skip directives, fragment spreads and the alias of
+                    // `alias: field`
+                    if matches!(previous, "@" | "..." | "on") || next == ":" {
+                        continue;
+                    }
+                    struct_tag("field", word)
+                } else if keyword == "enum" {
+                    if previous == "@" {
+                        continue;
+                    }
+                    struct_tag("value", word)
+                } else {
+                    // Skip directives and field types
+                    if matches!(previous, "@" | ":" | "[" | "|") || !matches!(next, ":" | "(") {
+                        continue;
+                    }
+                    struct_tag("field", word)
+                };
+                if !tags.contains(&tag) {
+                    tags.push(tag);
+                }
+            }
+            _ => {}
+        }
+    }
+    tags
+}
+
+/// First line of the `#` comment block directly above `line`.
+fn leading_comments_start(lines: &[&str], line: usize) -> usize {
+    let mut start = line;
+    while start > 0 && lines[start - 1].trim_start().starts_with('#') {
+        start -= 1;
+    }
+    start
+}
+
+fn is_name(text: &str) -> bool {
+    text.starts_with(|c: char| c.is_ascii_alphabetic() || c == '_')
+}
+
+/// A name, string, spread or punctuation character and its byte offset
+struct Token<'a> {
+    text: &'a str,
+    pos: usize,
+}
+
+/// Tokens of a GraphQL document, skipping whitespace, commas and comments.
+fn tokenize(content: &str) -> Vec<Token<'_>> {
+    let mut tokens = Vec::new();
+    let mut i = 0;
+    while i < content.len() {
+        let rest = &content[i..];
+        let c = rest.chars().next().unwrap_or(' ');
+        let len = if c.is_whitespace() || c == ',' {
+            i += c.len_utf8();
+            continue;
+        } else if c == '#' {
+            i += rest.find('\n').unwrap_or(rest.len());
+            continue;
+        } else if let Some(block) = rest.strip_prefix("\"\"\"") {
+            block.find("\"\"\"").map_or(rest.len(), |end| end + 6)
+        } else if c == '"' {
+            string_len(rest)
+        } else if rest.starts_with("...") {
+            3
+        } else if c.is_alphanumeric() || c == '_' {
+            rest.find(|c: char| !(c.is_alphanumeric() || c == '_'))
+                .unwrap_or(rest.len())
+        } else {
+            c.len_utf8()
+        };
+        tokens.push(Token {
+            text: &rest[..len],
+            pos: i,
+        });
+        i += len;
+    }
+    tokens
+}
+
+/// Length of the string at the start of `text`, quotes included.
+fn string_len(text: &str) -> usize {
+    let mut escaped = false;
+    for (pos, c) in text.char_indices().skip(1) {
+        match c {
+            _ if escaped => escaped = false,
+            '\\' => escaped = true,
+            '"' | '\n' => return pos + 1,
+            _ => {}
+        }
+    }
+    text.len()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunks(content: &str) -> Vec<Chunk> {
+        chunk_file(Path::new("schema.graphql"), content).unwrap()
+    }
+
+    fn summary(chunks: &[Chunk]) -> Vec<(SemanticKind, Option<&str>, usize, usize)> {
+        chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.name.as_deref(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect()
+    }
+
+    #[test]
+    fn test_schema_definitions() {
+        let content = r#"schema {
+  query: Query
+}
+
+"""
+A customer invoice.
+"""
+type Invoice implements Node @key(fields: "id") {
+  id: ID!
+  "Total in cents"
+  totalCents(currency: Currency = USD): Int!
+  lines: [InvoiceLine!]! @deprecated(reason: "use items")
+}
+
+# Payment state.
+enum InvoiceState { OPEN PAID @deprecated }
+
+union SearchResult = Invoice | Customer
+scalar DateTime
+
+input CreateInvoiceInput {
+  customerId: ID!
+}
+
+extend type Query {
+  invoice(id: ID!): Invoice
+}
+"#;
+        let chunks = chunks(content);
+        assert_eq!(
+            summary(&chunks),
+            vec![
+                (SemanticKind::Block, None, 1, 3),
+                (SemanticKind::Struct, Some("Invoice"), 5, 13),
+                (SemanticKind::Enum, Some("InvoiceState"), 15, 16),
+                (SemanticKind::TypeAlias, Some("SearchResult"), 18, 18),
+                (SemanticKind::TypeAlias, Some("DateTime"), 19, 19),
+                (SemanticKind::Struct, Some("CreateInvoiceInput"), 21, 23),
+                (SemanticKind::Struct, Some("Query"), 25, 27),
+            ]
+        );
+
+        let invoice = &chunks[1];
+        assert_eq!(
+            invoice.signature.as_deref(),
+            Some(r#"type Invoice implements Node @key(fields: "id")"#)
+        );
+        assert_eq!(
+            invoice.tags,
+            vec!["field:id", "field:totalcents", "field:lines"]
+        );
+        assert_eq!(chunks[2].tags, vec!["value:open", "value:paid"]);
+        assert_eq!(
+            chunks[3].signature.as_deref(),
+            Some("union SearchResult = Invoice | Customer")
+        );
+        assert_eq!(chunks[6].signature.as_deref(), Some("extend type Query"));
+        assert_eq!(chunks[6].tags, vec!["field:invoice"]);
+    }
+
+    #[test]
+    fn test_operations_and_fragments() {
+        let content = r#"query GetInvoice($id: ID!) {
+  invoice(id: $id) {
+    ...InvoiceFields
+  }
+  me: viewer { name }
+}
+
+mutation {
+  createInvoice(input: { customerId: "1" }) { id }
+}
+
+fragment InvoiceFields on Invoice {
+  id
+  totalCents
+}
+"#;
+        let chunks = chunks(content);
+        assert_eq!(
+            summary(&chunks),
+            vec![
+                (SemanticKind::Function, Some("GetInvoice"), 1, 6),
+                (SemanticKind::Function, None, 8, 10),
+                (SemanticKind::Block, Some("InvoiceFields"), 12, 15),
+            ]
+        );
+
+        assert_eq!(
+            chunks[0].signature.as_deref(),
+            Some("query GetInvoice($id: ID!)")
+        );
+        assert_eq!(chunks[0].tags, vec!["field:invoice", "field:viewer"]);
+        assert_eq!(chunks[1].tags, vec!["field:createinvoice"]);
+        assert_eq!(chunks[2].parent.as_deref(), Some("Invoice"));
+        assert_eq!(chunks[2].tags, vec!["field:id", "field:totalcents"]);
+        assert!(chunk_file(Path::new("schema.json"), "{}").is_none());
+    }
+}
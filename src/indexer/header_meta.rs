@@ -0,0 +1,237 @@
+//! File header metadata
+//!
+//! Many files open with a structured comment naming their license, owner or
+//! module:
+//!
+//! ```text
+//! // SPDX-License-Identifier: Apache-2.0
+//! // Owner: payments-team
+//! // Module: billing/invoices
+//! ```
+//!
+//! The leading comment block of each file is scanned for `Key: Value` lines
+//! whose key is one of `indexer.header_keys` (case-insensitive). Parsing
+//! stops at the first line that is not part of the block: code or a blank
+//! line. Every chunk of the file is tagged `meta:<key>=<value>`, so
+//! `coderag search --meta owner=payments-team` finds the file's symbols
+//! without sidecar files.
+//!
+//! Line comments (`//`, `#`, `--`, `;`) and `/* */` blocks are recognized.
+//! Values containing a comma are skipped, since tags are stored
+//! comma-separated.
+
+use super::Chunk;
+
+/// Prefix of the tags recording header metadata
+pub const META_TAG_PREFIX: &str = "meta:";
+
+/// Line comment markers a header line may start with
+const LINE_COMMENTS: &[&str] = &["//", "#", "--", ";"];
+
+/// Tag recording that a file's header sets `key` to `value`.
+///
+/// Tags are matched case-insensitively, so both parts are lowercased.
+pub fn meta_tag(key: &str, value: &str) -> String {
+    format!(
+        "{}{}={}",
+        META_TAG_PREFIX,
+        key.trim().to_lowercase(),
+        value.trim().to_lowercase()
+    )
+}
+
+/// Filter tag for `search --meta`: `key=value` matches that value, a bare
+/// `key` any value.
+///
+/// A filter tag ending in `=` matches every tag it prefixes.
+pub fn meta_filter(spec: &str) -> String {
+    match spec.split_once('=') {
+        Some((key, value)) => meta_tag(key, value),
+        None => meta_tag(spec, ""),
+    }
+}
+
+/// Extracts `Key: Value` metadata from file headers
+#[derive(Debug, Clone, Default)]
+pub struct HeaderMetadata {
+    keys: Vec<String>,
+}
+
+impl HeaderMetadata {
+    /// Create an extractor for the given keys (e.g. `Owner`)
+    pub fn new(keys: &[String]) -> Self {
+        let keys = keys
+            .iter()
+            .map(|k| k.trim().to_string())
+            .filter(|k| !k.is_empty())
+            .collect();
+        Self { keys }
+    }
+
+    /// Whether no keys are configured
+    pub fn is_empty(&self) -> bool {
+        self.keys.is_empty()
+    }
+
+    /// `(key, value)` pairs of the file's header in order, keys spelled as
+    /// configured
+    pub fn parse(&self, content: &str) -> Vec<(String, String)> {
+        let mut found: Vec<(String, String)> = Vec::new();
+        if self.is_empty() {
+            return found;
+        }
+
+        let mut in_block = false;
+        let mut started = false;
+        for line in content.lines() {
+            let line = line.trim();
+            // Blank lines may precede the header but end it
+            if line.is_empty() && !in_block {
+                if started {
+                    break;
+                }
+                continue;
+            }
+            let Some(text) = comment_text(line, &mut in_block) else {
+                break;
+            };
+            started = true;
+
+            let Some((key, value)) = text.split_once(':') else {
+                continue;
+            };
+            let (key, value) = (key.trim(), value.trim());
+            let Some(key) = self.keys.iter().find(|k| k.eq_ignore_ascii_case(key)) else {
+                continue;
+            };
+            let entry = (key.clone(), value.to_string());
+            if !value.is_empty() && !found.contains(&entry) {
+                found.push(entry);
+            }
+        }
+        found
+    }
+
+    /// Tag every chunk of a file with the metadata in the file's header
+    pub fn tag(&self, content: &str, chunks: &mut [Chunk]) {
+        let tags: Vec<String> = self
+            .parse(content)
+            .into_iter()
+            // Tags are stored comma-separated
+            .filter(|(_, value)| !value.contains(','))
+            .map(|(key, value)| meta_tag(&key, &value))
+            .collect();
+        for chunk in chunks {
+            for tag in &tags {
+                if !chunk.tags.contains(tag) {
+                    chunk.tags.push(tag.clone());
+                }
+            }
+        }
+    }
+}
+
+/// Text of a trimmed comment line, or `None` if the line is not a comment.
+///
+/// `in_block` tracks whether the line is inside a `/* */` block.
+fn comment_text<'a>(line: &'a str, in_block: &mut bool) -> Option<&'a str> {
+    let text = if *in_block {
+        line
+    } else if let Some(rest) = line.strip_prefix("/*") {
+        *in_block = true;
+        rest
+    } else {
+        let marker = LINE_COMMENTS.iter().find(|m| line.starts_with(*m))?;
+        // Doc comment variants such as `///`, `//!` and `##`
+        return Some(line[marker.len()..].trim_start_matches(|c: char| marker.contains(c) || c == '!'));
+    };
+
+    let text = match text.find("*/") {
+        Some(end) => {
+            *in_block = false;
+            &text[..end]
+        }
+        None => text,
+    };
+    // Leading `*` of block comment lines
+    Some(text.trim_start_matches('*'))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn extractor() -> HeaderMetadata {
+        HeaderMetadata::new(&[
+            "SPDX-License-Identifier".to_string(),
+            "Owner".to_string(),
+            "Module".to_string(),
+        ])
+    }
+
+    #[test]
+    fn test_line_comment_header() {
+        let content = "#!/usr/bin/env python\n# SPDX-License-Identifier: MIT\n# owner: Payments-Team\n# Reviewed: 2024\n\nimport os\n# Module: not/the/header\n";
+        assert_eq!(
+            extractor().parse(content),
+            vec![
+                ("SPDX-License-Identifier".to_string(), "MIT".to_string()),
+                ("Owner".to_string(), "Payments-Team".to_string()),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_block_comment_header() {
+        let content = "/*\n * Copyright 2024 Example Corp\n *\n * Module: billing/invoices\n */\n// Owner: billing\npackage invoices\n";
+        assert_eq!(
+            extractor().parse(content),
+            vec![
+                ("Module".to_string(), "billing/invoices".to_string()),
+                ("Owner".to_string(), "billing".to_string()),
+            ]
+        );
+        assert_eq!(
+            extractor().parse("/* Owner: core */\nint x;\n// Module: late\n"),
+            vec![("Owner".to_string(), "core".to_string())]
+        );
+        // Code first: no header
+        assert!(extractor()
+            .parse("package main\n// Owner: core\n")
+            .is_empty());
+        assert!(HeaderMetadata::new(&[])
+            .parse("// Owner: core\n")
+            .is_empty());
+    }
+
+    #[test]
+    fn test_tags_and_filters() {
+        let mut chunks = vec![Chunk {
+            content: "fn main() {}".to_string(),
+            file_path: "src/main.rs".into(),
+            start_line: 4,
+            end_line: 4,
+            language: Some("rust".to_string()),
+            semantic_kind: None,
+            name: Some("main".to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            qualified_name: None,
+            tags: vec!["concurrency".to_string()],
+        }];
+        let content =
+            "// SPDX-License-Identifier: MIT OR Apache-2.0\n//! Owner: a, b\n\nfn main() {}\n";
+        extractor().tag(content, &mut chunks);
+        assert_eq!(
+            chunks[0].tags,
+            vec![
+                "concurrency",
+                "meta:spdx-license-identifier=mit or apache-2.0"
+            ]
+        );
+
+        assert_eq!(meta_filter("Owner=Payments"), "meta:owner=payments");
+        assert_eq!(meta_filter("owner"), "meta:owner=");
+    }
+}
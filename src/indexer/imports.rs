@@ -0,0 +1,519 @@
+//! Import context for embedding input
+//!
+//! A chunk calling `rdb.Get(ctx, key)` does not say that `rdb` is a Redis
+//! client; its file's imports do. With `indexer.import_context`, the text
+//! embedded for each chunk ends with import statements of its file, so a
+//! query like "where do we use redis" finds the code using it:
+//!
+//! - `referenced`: only the imports binding a name that the chunk uses
+//! - `all`: every import of the file
+//!
+//! Like context headers, imports are embedding input only: stored chunks
+//! keep their content and BM25 does not index them. They come after the
+//! code, so a model truncating long input drops imports, never code.
+//!
+//! Import statements are recognized at the start of a line: `use` (Rust,
+//! PHP), `import` (Python, JS/TS, Go, Java, Kotlin, Scala, Swift), `from ...
+//! import` (Python), `require` (JS, Ruby), `using` (C#) and `#include`
+//! (C/C++). Indented lines are skipped, which leaves out PHP trait uses and
+//! C# `using` statements. Includes bind no names, so they are only added
+//! with `all`.
+
+use std::collections::{HashMap, HashSet};
+
+use serde::{Deserialize, Serialize};
+
+use super::Chunk;
+
+/// Most import statements added to one chunk
+pub const MAX_IMPORTS: usize = 20;
+
+/// Lines a multi-line import statement may span
+const MAX_STATEMENT_LINES: usize = 50;
+
+/// Which imports are added to a chunk's embedding input
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ImportContext {
+    /// No imports
+    #[default]
+    Off,
+    /// Imports binding a name the chunk uses
+    Referenced,
+    /// Every import of the file
+    All,
+}
+
+impl ImportContext {
+    /// Parse mode from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "off" => Some(Self::Off),
+            "referenced" => Some(Self::Referenced),
+            "all" => Some(Self::All),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Off => "off",
+            Self::Referenced => "referenced",
+            Self::All => "all",
+        }
+    }
+}
+
+/// An import statement and the names it binds in its file
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Import {
+    /// The statement on one line, e.g. `use redis::AsyncCommands;`
+    pub statement: String,
+    /// Names the statement binds, e.g. `AsyncCommands`
+    pub names: Vec<String>,
+}
+
+/// Import statements of a file in `language`, in order.
+pub fn file_imports(content: &str, language: &str) -> Vec<Import> {
+    let lines: Vec<&str> = content.lines().collect();
+    let mut imports = Vec::new();
+    let mut i = 0;
+    while i < lines.len() {
+        let line = lines[i];
+        if line.starts_with(char::is_whitespace) || !starts_import(language, line) {
+            i += 1;
+            continue;
+        }
+
+        // Extend the statement over the lines of a group or block
+        let mut end = i;
+        let mut statement = line.trim().to_string();
+        while !is_complete(language, &statement)
+            && end + 1 < lines.len().min(i + MAX_STATEMENT_LINES)
+        {
+            end += 1;
+            // Go specs are told apart by line
+            statement.push(if language == "go" { '\n' } else { ' ' });
+            statement.push_str(lines[end].trim());
+        }
+        i = end + 1;
+
+        if language == "go" {
+            imports.extend(go_imports(&statement));
+        } else {
+            let names = bound_names(language, &statement);
+            imports.push(Import { statement, names });
+        }
+    }
+    imports
+}
+
+/// Import context of a chunk: the `Imports:` block appended to its
+/// embedding input, or `None` when no import qualifies.
+pub fn import_context(imports: &[Import], content: &str, mode: ImportContext) -> Option<String> {
+    let statements: Vec<&str> = match mode {
+        ImportContext::Off => return None,
+        ImportContext::All => imports.iter().map(|i| i.statement.as_str()).collect(),
+        ImportContext::Referenced => {
+            let words = identifiers(content);
+            imports
+                .iter()
+                .filter(|i| i.names.iter().any(|name| words.contains(name.as_str())))
+                .map(|i| i.statement.as_str())
+                .collect()
+        }
+    };
+    if statements.is_empty() {
+        return None;
+    }
+    let statements = &statements[..statements.len().min(MAX_IMPORTS)];
+    Some(format!("Imports:\n{}", statements.join("\n")))
+}
+
+/// `text` followed by an import context.
+pub fn with_import_context(text: &str, imports: &str) -> String {
+    format!("{}\n\n{}", text, imports)
+}
+
+/// Import context of each chunk of a file, in order. Imports are read for
+/// each chunk's language, so the script of a Vue component gets its own.
+pub fn chunk_import_contexts(
+    content: &str,
+    chunks: &[Chunk],
+    mode: ImportContext,
+) -> Vec<Option<String>> {
+    if mode == ImportContext::Off {
+        return vec![None; chunks.len()];
+    }
+    let mut by_language: HashMap<&str, Vec<Import>> = HashMap::new();
+    chunks
+        .iter()
+        .map(|chunk| {
+            let language = chunk.language.as_deref()?;
+            let imports = by_language
+                .entry(language)
+                .or_insert_with(|| file_imports(content, language));
+            import_context(imports, &chunk.content, mode)
+        })
+        .collect()
+}
+
+/// Whether an unindented line starts an import statement
+fn starts_import(language: &str, line: &str) -> bool {
+    let starts = |keywords: &[&str]| keywords.iter().any(|k| line.starts_with(k));
+    match language {
+        "rust" => starts(&["use ", "pub use ", "pub(crate) use ", "extern crate "]),
+        "php" => starts(&["use "]),
+        "csharp" => {
+            starts(&["using ", "global using "])
+                && !starts(&["using (", "using var ", "using await "])
+        }
+        "python" => starts(&["import ", "from "]),
+        "javascript" | "typescript" | "tsx" => {
+            (starts(&["import "]) && !starts(&["import ("]))
+                || (starts(&["const ", "let ", "var "]) && line.contains("require("))
+        }
+        "go" | "java" | "kotlin" | "scala" | "swift" => starts(&["import "]),
+        "ruby" => starts(&["require ", "require_relative "]),
+        "c" | "cpp" => starts(&["#include"]),
+        _ => false,
+    }
+}
+
+/// Whether `statement` is a whole import statement rather than the start
+/// of one spanning lines
+fn is_complete(language: &str, statement: &str) -> bool {
+    let balanced = |open: char, close: char| {
+        statement.matches(open).count() <= statement.matches(close).count()
+    };
+    match language {
+        "rust" | "php" | "csharp" | "java" => statement.ends_with(';'),
+        "python" => balanced('(', ')') && !statement.ends_with('\\'),
+        "javascript" | "typescript" | "tsx" => {
+            balanced('{', '}') && (statement.ends_with(';') || statement.contains(['\'', '"']))
+        }
+        "go" => balanced('(', ')'),
+        _ => true,
+    }
+}
+
+/// Names bound by an import statement
+fn bound_names(language: &str, statement: &str) -> Vec<String> {
+    let statement = statement.trim_end_matches(';').trim();
+    match language {
+        "rust" => {
+            let body = after(statement, "use ").or_else(|| after(statement, "extern crate "));
+            body.map_or_else(Vec::new, |body| group_names(body, "::", " as "))
+        }
+        "php" => {
+            let body = after(statement, "use ").unwrap_or_default();
+            let body = body
+                .strip_prefix("function ")
+                .or_else(|| body.strip_prefix("const "))
+                .unwrap_or(body);
+            group_names(body, "\\", " as ")
+        }
+        "csharp" => {
+            let body = after(statement, "using ").unwrap_or_default();
+            let body = body.strip_prefix("static ").unwrap_or(body);
+            match body.split_once('=') {
+                Some((alias, _)) => identifier(alias.trim()).into_iter().collect(),
+                None => last_segment(body, ".").into_iter().collect(),
+            }
+        }
+        "java" => {
+            let body = after(statement, "import ").unwrap_or_default();
+            let body = body.strip_prefix("static ").unwrap_or(body);
+            last_segment(body, ".").into_iter().collect()
+        }
+        "kotlin" => group_names(after(statement, "import ").unwrap_or_default(), ".", " as "),
+        "scala" => group_names(after(statement, "import ").unwrap_or_default(), ".", "=>"),
+        "swift" => {
+            let body = after(statement, "import ").unwrap_or_default();
+            let path = body.split_whitespace().last().unwrap_or_default();
+            last_segment(path, ".").into_iter().collect()
+        }
+        "python" => python_names(statement),
+        "javascript" | "typescript" | "tsx" => js_names(statement),
+        "ruby" => {
+            let path = quoted(statement).unwrap_or_default();
+            let name = path.rsplit('/').next().unwrap_or_default();
+            let camel: String = name
+                .split(['_', '-'])
+                .map(|part| {
+                    let mut chars = part.chars();
+                    chars
+                        .next()
+                        .map(|first| first.to_uppercase().chain(chars).collect::<String>())
+                        .unwrap_or_default()
+                })
+                .collect();
+            [name.to_string(), camel]
+                .into_iter()
+                .filter(|n| identifier(n).is_some())
+                .collect()
+        }
+        _ => Vec::new(),
+    }
+}
+
+/// Each import spec of a Go `import` statement or block
+fn go_imports(statement: &str) -> Vec<Import> {
+    let body = after(statement, "import ").unwrap_or_default();
+    let body = body.trim_start_matches('(').trim_end_matches(')');
+
+    // Each spec is an optional alias followed by a quoted path, on a line
+    // of its own in a block
+    let mut imports = Vec::new();
+    let mut rest = body;
+    while let Some((before, after_quote)) = rest.split_once('"') {
+        let Some((path, after_path)) = after_quote.split_once('"') else {
+            break;
+        };
+        rest = after_path;
+        let alias = before.rsplit('\n').next().unwrap_or_default().trim();
+        let name = match alias {
+            "" => go_package_name(path),
+            "_" | "." => None,
+            alias => identifier(alias),
+        };
+        let spec = if alias.is_empty() {
+            format!("import \"{}\"", path)
+        } else {
+            format!("import {} \"{}\"", alias, path)
+        };
+        imports.push(Import {
+            statement: spec,
+            names: name.into_iter().collect(),
+        });
+    }
+    imports
+}
+
+/// Package name a Go import path binds by convention: its last element,
+/// skipping a major version (`redis` for `github.com/go-redis/redis/v8`)
+/// and a `.vN` suffix (`yaml` for `gopkg.in/yaml.v3`)
+fn go_package_name(path: &str) -> Option<String> {
+    let is_version = |s: &str| {
+        s.strip_prefix('v')
+            .is_some_and(|n| !n.is_empty() && n.chars().all(|c| c.is_ascii_digit()))
+    };
+    let element = path.rsplit('/').find(|element| !is_version(element))?;
+    let element = element.split('.').next().unwrap_or(element);
+    identifier(element.rsplit('-').next().unwrap_or(element))
+}
+
+/// Names bound by a Python `import` or `from ... import` statement
+fn python_names(statement: &str) -> Vec<String> {
+    let statement: String = statement
+        .chars()
+        .filter(|c| !matches!(c, '(' | ')' | '\\'))
+        .collect();
+    if let Some(rest) = statement.strip_prefix("from ") {
+        let names = rest.split_once(" import ").map_or("", |(_, names)| names);
+        return group_names(names, "\u{0}", " as ");
+    }
+    statement
+        .strip_prefix("import ")
+        .unwrap_or_default()
+        .split(',')
+        .filter_map(|part| match part.split_once(" as ") {
+            Some((_, alias)) => identifier(alias.trim()),
+            None => identifier(part.trim().split('.').next().unwrap_or_default()),
+        })
+        .collect()
+}
+
+/// Names bound by a JavaScript/TypeScript `import` or `require`
+fn js_names(statement: &str) -> Vec<String> {
+    if let Some(rest) = statement.strip_prefix("import ") {
+        let rest = rest.strip_prefix("type ").unwrap_or(rest);
+        let Some((clause, _)) = rest.split_once(" from ") else {
+            // `import 'polyfill'` binds nothing
+            return Vec::new();
+        };
+        return group_names(clause, "\u{0}", " as ");
+    }
+    let clause = statement
+        .split_once(char::is_whitespace)
+        .and_then(|(_, rest)| rest.split_once('='))
+        .map_or("", |(clause, _)| clause);
+    group_names(clause, "\u{0}", ":")
+}
+
+/// Names bound by a path list with `{...}` groups, such as
+/// `std::io::{self, Read as R}`: the last segment of each leaf or its
+/// alias. A `self` leaf binds the group's own path.
+fn group_names(body: &str, separator: &str, alias: &str) -> Vec<String> {
+    let mut names = Vec::new();
+    let mut prefixes: Vec<&str> = Vec::new();
+    let mut push = |leaf: &str, prefix: Option<&&str>| {
+        let leaf = leaf.trim();
+        let name = match leaf.split_once(alias) {
+            Some((_, alias)) => identifier(alias.trim()),
+            None if leaf == "self" => {
+                prefix.and_then(|p| last_segment(p.trim().trim_end_matches(separator), separator))
+            }
+            // TypeScript's `import { type User }`
+            None => leaf
+                .split_whitespace()
+                .last()
+                .and_then(|leaf| last_segment(leaf, separator)),
+        };
+        names.extend(name);
+    };
+
+    let mut start = 0;
+    for (i, c) in body.char_indices() {
+        if matches!(c, '{' | '}' | ',') {
+            let leaf = &body[start..i];
+            match c {
+                '{' => prefixes.push(leaf),
+                _ => push(leaf, prefixes.last()),
+            }
+            if c == '}' {
+                prefixes.pop();
+            }
+            start = i + 1;
+        }
+    }
+    push(&body[start..], prefixes.last());
+    names
+}
+
+/// Last segment of a path, if it is a name (not `*` or `_`)
+fn last_segment(path: &str, separator: &str) -> Option<String> {
+    identifier(path.trim().rsplit(separator).next()?.trim())
+}
+
+/// `s` if it is an identifier other than `_`
+fn identifier(s: &str) -> Option<String> {
+    let valid = !s.is_empty()
+        && s != "_"
+        && s != "self"
+        && !s.starts_with(|c: char| c.is_ascii_digit())
+        && s.chars()
+            .all(|c| c.is_alphanumeric() || c == '_' || c == '$');
+    valid.then(|| s.to_string())
+}
+
+/// Text after the first `keyword` in `statement`
+fn after<'a>(statement: &'a str, keyword: &str) -> Option<&'a str> {
+    statement
+        .find(keyword)
+        .map(|i| statement[i + keyword.len()..].trim())
+}
+
+/// The first quoted string in `s`
+fn quoted(s: &str) -> Option<&str> {
+    let start = s.find(['\'', '"'])?;
+    let quote = s[start..].chars().next()?;
+    let rest = &s[start + 1..];
+    rest.find(quote).map(|end| &rest[..end])
+}
+
+/// Identifiers used in `content`
+fn identifiers(content: &str) -> HashSet<&str> {
+    content
+        .split(|c: char| !(c.is_alphanumeric() || c == '_' || c == '$'))
+        .filter(|word| !word.is_empty())
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn names(imports: &[Import]) -> Vec<&str> {
+        imports
+            .iter()
+            .flat_map(|i| i.names.iter().map(String::as_str))
+            .collect()
+    }
+
+    #[test]
+    fn test_rust_use_groups() {
+        let source = "use std::io::{self, Read as R};\nuse redis::{\n    AsyncCommands,\n    Client,\n};\n\nfn main() {\n    use std::fmt;\n}\n";
+        let imports = file_imports(source, "rust");
+        assert_eq!(imports.len(), 2);
+        assert_eq!(
+            imports[1].statement,
+            "use redis::{ AsyncCommands, Client, };"
+        );
+        assert_eq!(names(&imports), vec!["io", "R", "AsyncCommands", "Client"]);
+    }
+
+    #[test]
+    fn test_go_import_block() {
+        let source = "package cache\n\nimport (\n\t\"context\"\n\n\tredis \"github.com/go-redis/redis/v8\"\n\t\"gopkg.in/yaml.v3\"\n\t_ \"github.com/lib/pq\"\n)\n";
+        let imports = file_imports(source, "go");
+        let statements: Vec<&str> = imports.iter().map(|i| i.statement.as_str()).collect();
+        assert_eq!(
+            statements,
+            vec![
+                "import \"context\"",
+                "import redis \"github.com/go-redis/redis/v8\"",
+                "import \"gopkg.in/yaml.v3\"",
+                "import _ \"github.com/lib/pq\"",
+            ]
+        );
+        assert_eq!(names(&imports), vec!["context", "redis", "yaml"]);
+    }
+
+    #[test]
+    fn test_python_and_typescript_names() {
+        let source = "import os.path\nimport numpy as np\nfrom redis import (\n    Redis,\n    ConnectionPool as Pool,\n)\n";
+        assert_eq!(
+            names(&file_imports(source, "python")),
+            vec!["os", "np", "Redis", "Pool"]
+        );
+
+        let source = "import React, { useState as useLocal, type FC } from 'react';\nimport * as fs from 'fs';\nimport './polyfill';\nconst { createClient } = require('redis');\n";
+        assert_eq!(
+            names(&file_imports(source, "typescript")),
+            vec!["React", "useLocal", "FC", "fs", "createClient"]
+        );
+    }
+
+    #[test]
+    fn test_referenced_imports_only() {
+        let source =
+            "use redis::AsyncCommands;\nuse serde::Deserialize;\nuse std::collections::HashMap;\n";
+        let imports = file_imports(source, "rust");
+        let chunk = "async fn cached(con: &mut impl AsyncCommands) -> HashMap<String, u32> {}";
+
+        assert_eq!(
+            import_context(&imports, chunk, ImportContext::Referenced).as_deref(),
+            Some("Imports:\nuse redis::AsyncCommands;\nuse std::collections::HashMap;")
+        );
+        assert_eq!(
+            import_context(&imports, chunk, ImportContext::All)
+                .unwrap()
+                .lines()
+                .count(),
+            4
+        );
+        assert_eq!(import_context(&imports, chunk, ImportContext::Off), None);
+        assert_eq!(
+            import_context(&imports, "fn f() {}", ImportContext::Referenced),
+            None
+        );
+    }
+
+    #[test]
+    fn test_indented_and_other_statements_are_skipped() {
+        // PHP trait uses and C# using statements are indented
+        let source = "use App\\Models\\{User, Invoice as Bill};\n\nclass Invoice\n{\n    use FormatsMoney;\n}\n";
+        assert_eq!(names(&file_imports(source, "php")), vec!["User", "Bill"]);
+
+        let source = "using System.Text;\nusing Json = Newtonsoft.Json;\n\nclass A {\n    void F() {\n        using (var s = Open()) {}\n    }\n}\n";
+        assert_eq!(names(&file_imports(source, "csharp")), vec!["Text", "Json"]);
+
+        let source = "require 'redis'\nrequire_relative 'lib/active_record'\n";
+        assert_eq!(
+            names(&file_imports(source, "ruby")),
+            vec!["redis", "Redis", "active_record", "ActiveRecord"]
+        );
+    }
+}
@@ -0,0 +1,339 @@
+//! Markdown chunking by heading
+//!
+//! READMEs and design docs are split into sections, one
+//! [`SemanticKind::Section`] chunk from each heading to the next, so a
+//! question about a design lands on the part of the document answering it.
+//! A section is named by its heading and keeps its place in the document
+//! as a breadcrumb: the qualified name of `### Linux` under
+//! `## Installation` in `# Guide` is `Guide > Installation > Linux`, and
+//! its parent is `Guide > Installation`.
+//!
+//! ATX (`## Title`) and setext (`Title` underlined with `===` or `---`)
+//! headings are recognized; `#` lines inside fenced code blocks and YAML
+//! front matter are not headings. Text before the first heading is an
+//! unnamed section, and sections longer than [`MAX_SECTION_LINES`] are
+//! split at blank lines into parts with the same name.
+
+use std::path::Path;
+
+use super::ast_chunker::SemanticKind;
+use super::Chunk;
+
+/// Language identifier assigned to Markdown chunks
+pub const LANGUAGE: &str = "markdown";
+
+/// Extensions of Markdown files
+pub const EXTENSIONS: &[&str] = &["md", "markdown"];
+
+/// Longest section kept in one chunk
+pub const MAX_SECTION_LINES: usize = 80;
+
+/// Separator between the headings of a breadcrumb
+pub const BREADCRUMB_SEPARATOR: &str = " > ";
+
+/// Chunk a Markdown file into one chunk per section.
+///
+/// Returns `None` when `path` is not a Markdown file, so callers can fall
+/// back to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    let extension = path.extension().and_then(|e| e.to_str())?;
+    if !EXTENSIONS.contains(&extension.to_lowercase().as_str()) {
+        return None;
+    }
+
+    let lines: Vec<&str> = content.lines().collect();
+    let headings = headings(&lines);
+
+    let mut chunks = Vec::new();
+    // Titles of the enclosing headings, by level
+    let mut trail: Vec<(usize, String)> = Vec::new();
+
+    // Text before the first heading
+    let first = headings.first().map_or(lines.len(), |h| h.line);
+    let preamble = Section {
+        start: 0,
+        end: first,
+        title: None,
+        qualified_name: None,
+        parent: None,
+    };
+    preamble.push_chunks(&mut chunks, path, &lines);
+
+    for (i, heading) in headings.iter().enumerate() {
+        let end = headings.get(i + 1).map_or(lines.len(), |h| h.line);
+        while trail
+            .last()
+            .is_some_and(|(level, _)| *level >= heading.level)
+        {
+            trail.pop();
+        }
+        let parent = (!trail.is_empty()).then(|| breadcrumb(&trail));
+        trail.push((heading.level, heading.title.clone()));
+
+        let section = Section {
+            start: heading.line,
+            end,
+            title: Some(&heading.title),
+            qualified_name: Some(breadcrumb(&trail)),
+            parent,
+        };
+        section.push_chunks(&mut chunks, path, &lines);
+    }
+
+    Some(chunks)
+}
+
+/// A heading and the line it starts on
+#[derive(Debug, PartialEq)]
+struct Heading {
+    line: usize,
+    level: usize,
+    title: String,
+}
+
+/// Headings of a document, outside fenced code blocks and front matter.
+fn headings(lines: &[&str]) -> Vec<Heading> {
+    let mut headings = Vec::new();
+    // Fence that opened the current code block
+    let mut fence: Option<&str> = None;
+    let mut i = 0;
+
+    // YAML front matter, between `---` lines at the top
+    if lines.first().map(|l| l.trim_end()) == Some("---") {
+        if let Some(close) = lines.iter().skip(1).position(|l| l.trim_end() == "---") {
+            i = close + 2;
+        }
+    }
+
+    while i < lines.len() {
+        let line = lines[i];
+        let trimmed = line.trim_start();
+        if let Some(open) = fence {
+            if trimmed.starts_with(open) {
+                fence = None;
+            }
+            i += 1;
+            continue;
+        }
+        if trimmed.starts_with("```") || trimmed.starts_with("~~~") {
+            fence = Some(&trimmed[..3]);
+            i += 1;
+            continue;
+        }
+        // Indented code is not a heading
+        if line.len() - trimmed.len() >= 4 {
+            i += 1;
+            continue;
+        }
+
+        if let Some(heading) = atx_heading(trimmed) {
+            headings.push(Heading { line: i, ..heading });
+        } else if let Some(level) = lines.get(i + 1).and_then(|next| setext_level(next)) {
+            if !trimmed.is_empty() && !is_block_start(trimmed) {
+                headings.push(Heading {
+                    line: i,
+                    level,
+                    title: trimmed.trim_end().to_string(),
+                });
+                i += 1;
+            }
+        }
+        i += 1;
+    }
+    headings
+}
+
+/// An ATX heading: one to six `#`, a space and the title, with optional
+/// closing `#`s.
+fn atx_heading(line: &str) -> Option<Heading> {
+    let level = line.chars().take_while(|&c| c == '#').count();
+    if !(1..=6).contains(&level) {
+        return None;
+    }
+    let rest = &line[level..];
+    if !rest.is_empty() && !rest.starts_with([' ', '\t']) {
+        return None;
+    }
+    let title = rest.trim().trim_end_matches('#').trim_end();
+    Some(Heading {
+        line: 0,
+        level,
+        title: title.to_string(),
+    })
+}
+
+/// Level of the heading a setext underline (`===` or `---`) makes.
+fn setext_level(line: &str) -> Option<usize> {
+    let underline = line.trim();
+    if underline.is_empty() || line.len() - line.trim_start().len() >= 4 {
+        return None;
+    }
+    if underline.chars().all(|c| c == '=') {
+        Some(1)
+    } else if underline.chars().all(|c| c == '-') {
+        Some(2)
+    } else {
+        None
+    }
+}
+
+/// Whether a line starts a list item, quote, table or other block that an
+/// underline cannot turn into a heading.
+fn is_block_start(line: &str) -> bool {
+    line.starts_with(['-', '*', '+', '>', '|', '<'])
+        || line
+            .split_once(['.', ')'])
+            .is_some_and(|(n, _)| !n.is_empty() && n.chars().all(|c| c.is_ascii_digit()))
+}
+
+/// Headings of a trail joined into a breadcrumb.
+fn breadcrumb(trail: &[(usize, String)]) -> String {
+    trail
+        .iter()
+        .map(|(_, title)| title.as_str())
+        .collect::<Vec<_>>()
+        .join(BREADCRUMB_SEPARATOR)
+}
+
+/// The lines from a heading to the next one
+struct Section<'a> {
+    /// First line and the line after the last, 0-based
+    start: usize,
+    end: usize,
+    /// Heading, `None` for the text before the first one
+    title: Option<&'a str>,
+    /// Breadcrumb of the section and of its parent
+    qualified_name: Option<String>,
+    parent: Option<String>,
+}
+
+impl Section<'_> {
+    /// Push the section's chunks, split at blank lines when longer than
+    /// [`MAX_SECTION_LINES`]. Blank sections are skipped.
+    fn push_chunks(&self, chunks: &mut Vec<Chunk>, path: &Path, lines: &[&str]) {
+        // Blank lines around the text belong to no section
+        let mut start = self.start;
+        let mut end = self.end;
+        while end > start && lines[end - 1].trim().is_empty() {
+            end -= 1;
+        }
+        while start < end && lines[start].trim().is_empty() {
+            start += 1;
+        }
+        if end == start {
+            return;
+        }
+
+        let mut part_start = start;
+        while part_start < end {
+            let mut part_end = end;
+            if part_end - part_start > MAX_SECTION_LINES {
+                // Split at the last blank line that keeps the part short enough,
+                // or hard at the limit
+                let limit = part_start + MAX_SECTION_LINES;
+                part_end = (part_start + 1..limit)
+                    .rev()
+                    .find(|&l| lines[l].trim().is_empty())
+                    .unwrap_or(limit);
+            }
+
+            chunks.push(Chunk {
+                content: lines[part_start..part_end].join("\n"),
+                file_path: path.to_path_buf(),
+                start_line: part_start + 1,
+                end_line: part_end,
+                language: Some(LANGUAGE.to_string()),
+                semantic_kind: Some(SemanticKind::Section),
+                name: self.title.map(str::to_string),
+                signature: self.title.map(|_| lines[start].trim().to_string()),
+                doc: None,
+                parent: self.parent.clone(),
+                qualified_name: self.qualified_name.clone(),
+                tags: Vec::new(),
+            });
+
+            part_start = part_end;
+            while part_start < end && lines[part_start].trim().is_empty() {
+                part_start += 1;
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunks(content: &str) -> Vec<Chunk> {
+        chunk_file(Path::new("README.md"), content).unwrap()
+    }
+
+    fn summary(chunks: &[Chunk]) -> Vec<(Option<&str>, Option<&str>, usize, usize)> {
+        chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.qualified_name.as_deref(),
+                    c.parent.as_deref(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect()
+    }
+
+    #[test]
+    fn test_sections_keep_their_breadcrumb() {
+        let content = "\nBadges go here.\n\n# Guide\n\nIntro.\n\n## Installation\n\n### Linux\n\nRun it.\n\n```sh\n# not a heading\n```\n\n## Usage ##\n\nUsage\n-----\n\nSee above.\n";
+        let chunks = chunks(content);
+        assert_eq!(
+            summary(&chunks),
+            vec![
+                (None, None, 2, 2),
+                (Some("Guide"), None, 4, 6),
+                (Some("Guide > Installation"), Some("Guide"), 8, 8),
+                (
+                    Some("Guide > Installation > Linux"),
+                    Some("Guide > Installation"),
+                    10,
+                    16
+                ),
+                (Some("Guide > Usage"), Some("Guide"), 18, 18),
+                (Some("Guide > Usage"), Some("Guide"), 20, 23),
+            ]
+        );
+
+        let linux = &chunks[3];
+        assert_eq!(linux.semantic_kind, Some(SemanticKind::Section));
+        assert_eq!(linux.name.as_deref(), Some("Linux"));
+        assert_eq!(linux.signature.as_deref(), Some("### Linux"));
+        assert!(linux.content.contains("# not a heading"));
+        assert_eq!(chunks[4].name.as_deref(), Some("Usage"));
+    }
+
+    #[test]
+    fn test_front_matter_and_setext() {
+        let content = "---\ntitle: Design\n---\nDesign\n======\n\n- item\n---\n\nText.\n";
+        let chunks = chunks(content);
+        assert_eq!(
+            summary(&chunks),
+            vec![(None, None, 1, 3), (Some("Design"), None, 4, 10)]
+        );
+    }
+
+    #[test]
+    fn test_long_sections_are_split_at_blank_lines() {
+        let mut content = "# Notes\n".to_string();
+        for i in 0..30 {
+            content.push_str(&format!("Paragraph {}\ncontinues.\n\n", i));
+        }
+        let chunks = chunks(&content);
+
+        assert_eq!(summary(&chunks)[0], (Some("Notes"), None, 1, 78));
+        assert_eq!(summary(&chunks)[1], (Some("Notes"), None, 80, 90));
+        assert!(chunks
+            .iter()
+            .all(|c| c.end_line - c.start_line < MAX_SECTION_LINES));
+        assert!(chunk_file(Path::new("main.rs"), "# x").is_none());
+    }
+}
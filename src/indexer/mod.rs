@@ -1,7 +1,37 @@
+pub mod archive;
 pub mod ast_chunker;
 pub mod chunker;
+pub mod comments;
+pub mod concurrency;
+pub mod config_files;
+pub mod context_header;
+pub mod dockerfile;
+pub mod dotenv;
+pub mod feature_flags;
+pub mod field_vectors;
+pub mod generated;
+pub mod git_ref;
+pub mod graphql;
+pub mod header_meta;
+pub mod imports;
+pub mod markdown;
+pub mod name_variants;
+pub mod notebook;
+pub mod openapi;
+pub mod protobuf;
+pub mod sfc;
+pub mod shell;
+pub mod sql;
+pub mod struct_tags;
+pub mod summaries;
+pub mod terraform;
 pub mod walker;
 
-pub use ast_chunker::{AstChunker, ChunkingMethod, ChunkingStats, SemanticKind};
+pub use ast_chunker::{
+    AstChunker, ChunkingMethod, ChunkingStats, Granularity, SemanticKind, SymbolCapPolicy,
+    DEFAULT_MAX_MERGED_TOKENS, DEFAULT_MAX_SYMBOLS_PER_FILE,
+};
 pub use chunker::{Chunk, Chunker, ChunkerStrategy};
-pub use walker::Walker;
+pub use feature_flags::{FlagDetector, FlagUsage};
+pub use header_meta::HeaderMetadata;
+pub use walker::{SymlinkAliases, SymlinkPolicy, Walker};
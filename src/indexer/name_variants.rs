@@ -0,0 +1,106 @@
+//! Symbol name variants for name-intent search
+//!
+//! A chunk vector mostly reflects the body of a symbol, so a short query
+//! like "shutdown" can rank `Server.Shutdown` below longer code that merely
+//! mentions shutting down. When `indexer.embed_name_variants` is enabled,
+//! each named chunk also gets a few short vectors, one per variant of its
+//! name:
+//!
+//! - the qualified name (`server.Server.Shutdown`)
+//! - the bare name (`Shutdown`)
+//! - the subword-split form, lowercased (`graceful shutdown` for
+//!   `GracefulShutdown` or `graceful_shutdown`)
+//!
+//! They are embedded by [`super::field_vectors`] and searched by
+//! `coderag search --field name`.
+
+/// Split an identifier into lowercase words.
+///
+/// Splits at separators (`_`, `-`, `.`, `::`, whitespace), at lower-to-upper
+/// case changes and at the end of acronyms, so `parseHTTPRequest` becomes
+/// `parse http request`.
+pub fn split_subwords(name: &str) -> String {
+    let mut words: Vec<String> = Vec::new();
+    let mut current = String::new();
+    let chars: Vec<char> = name.chars().collect();
+
+    for (i, &c) in chars.iter().enumerate() {
+        if !c.is_alphanumeric() {
+            if !current.is_empty() {
+                words.push(std::mem::take(&mut current));
+            }
+            continue;
+        }
+        if let Some(prev) = current.chars().last() {
+            let next_is_lower = chars.get(i + 1).is_some_and(|n| n.is_lowercase());
+            let boundary = c.is_uppercase()
+                && (prev.is_lowercase()
+                    || prev.is_ascii_digit()
+                    || (prev.is_uppercase() && next_is_lower));
+            if boundary {
+                words.push(std::mem::take(&mut current));
+            }
+        }
+        current.push(c);
+    }
+    if !current.is_empty() {
+        words.push(current);
+    }
+
+    words
+        .iter()
+        .map(|w| w.to_lowercase())
+        .collect::<Vec<_>>()
+        .join(" ")
+}
+
+/// Name variants of a symbol: qualified name, bare name and subword-split
+/// form, without duplicates.
+pub fn name_variants(name: &str, qualified_name: Option<&str>) -> Vec<String> {
+    let mut variants: Vec<String> = Vec::new();
+    let candidates = [
+        qualified_name.map(str::to_string),
+        Some(name.to_string()),
+        Some(split_subwords(name)),
+    ];
+    for variant in candidates.into_iter().flatten() {
+        let variant = variant.trim().to_string();
+        if !variant.is_empty() && !variants.contains(&variant) {
+            variants.push(variant);
+        }
+    }
+    variants
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_split_subwords() {
+        assert_eq!(split_subwords("Shutdown"), "shutdown");
+        assert_eq!(split_subwords("GracefulShutdown"), "graceful shutdown");
+        assert_eq!(split_subwords("graceful_shutdown"), "graceful shutdown");
+        assert_eq!(split_subwords("parseHTTPRequest"), "parse http request");
+        assert_eq!(split_subwords("Server::start_v2"), "server start v2");
+        assert_eq!(split_subwords("utf8Decode"), "utf8 decode");
+    }
+
+    #[test]
+    fn test_name_variants() {
+        assert_eq!(
+            name_variants("GracefulShutdown", Some("server.Server.GracefulShutdown")),
+            vec![
+                "server.Server.GracefulShutdown",
+                "GracefulShutdown",
+                "graceful shutdown"
+            ]
+        );
+        // Duplicates are dropped
+        assert_eq!(name_variants("run", Some("run")), vec!["run"]);
+        assert_eq!(
+            name_variants("Shutdown", None),
+            vec!["Shutdown", "shutdown"]
+        );
+    }
+}
@@ -0,0 +1,283 @@
+//! Jupyter notebook chunking by cell
+//!
+//! A notebook is a JSON document whose cells hold the code and prose of an
+//! analysis, next to their outputs. Each code and Markdown cell becomes a
+//! chunk of its source, in notebook order; outputs are dropped, and so are
+//! the base64 payloads of images embedded in Markdown cells, since neither
+//! says anything a search should match.
+//!
+//! Code cells are [`SemanticKind::Block`] chunks in the kernel's language
+//! (`python` unless the notebook's metadata says otherwise), Markdown cells
+//! are [`SemanticKind::Section`] chunks in `markdown`. A chunk is named by
+//! its cell, `cell 3` for the third, tagged `cell:3`, and signed with the
+//! cell's first line. Its line numbers point at the cell in the `.ipynb`
+//! file, whose lines are JSON rather than the cell's source. Cells longer
+//! than [`MAX_CELL_LINES`] are split at blank lines into parts with the
+//! same name.
+
+use std::path::Path;
+
+use serde_json::Value;
+
+use super::ast_chunker::SemanticKind;
+use super::struct_tags::struct_tag;
+use super::Chunk;
+
+/// Extension of Jupyter notebooks
+pub const EXTENSION: &str = "ipynb";
+
+/// Language of notebooks whose metadata names none
+pub const DEFAULT_KERNEL_LANGUAGE: &str = "python";
+
+/// Language identifier assigned to Markdown cells
+pub const MARKDOWN_LANGUAGE: &str = "markdown";
+
+/// Longest cell kept in one chunk
+pub const MAX_CELL_LINES: usize = 80;
+
+/// Key of the tag numbering the cell a chunk comes from
+pub const CELL_TAG: &str = "cell";
+
+/// Chunk a notebook into one chunk per code or Markdown cell.
+///
+/// Returns `None` when `path` is not a notebook or does not parse, so
+/// callers can fall back to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    if path.extension().and_then(|e| e.to_str()) != Some(EXTENSION) {
+        return None;
+    }
+    let notebook: Value = serde_json::from_str(content).ok()?;
+    let cells = notebook.get("cells")?.as_array()?;
+
+    let metadata = notebook.get("metadata");
+    let kernel_language = metadata
+        .and_then(|m| m.pointer("/kernelspec/language"))
+        .or_else(|| metadata.and_then(|m| m.pointer("/language_info/name")))
+        .and_then(Value::as_str)
+        .unwrap_or(DEFAULT_KERNEL_LANGUAGE)
+        .to_lowercase();
+
+    let cell_lines = cell_lines(content, cells.len());
+    let mut chunks = Vec::new();
+
+    for (index, cell) in cells.iter().enumerate() {
+        let (kind, language) = match cell.get("cell_type").and_then(Value::as_str) {
+            Some("code") => (SemanticKind::Block, kernel_language.as_str()),
+            Some("markdown") => (SemanticKind::Section, MARKDOWN_LANGUAGE),
+            _ => continue,
+        };
+        let source = match cell.get("source") {
+            Some(Value::Array(lines)) => lines.iter().filter_map(Value::as_str).collect(),
+            Some(Value::String(source)) => source.clone(),
+            _ => continue,
+        };
+        let source = strip_base64(&source);
+
+        // Cells are numbered from 1, as a reader counts them
+        let number = index + 1;
+        let (start_line, end_line) = cell_lines[index];
+        let lines: Vec<&str> = source.lines().collect();
+        for part in parts(&lines) {
+            chunks.push(Chunk {
+                content: part.join("\n"),
+                file_path: path.to_path_buf(),
+                start_line,
+                end_line,
+                language: Some(language.to_string()),
+                semantic_kind: Some(kind),
+                name: Some(format!("cell {}", number)),
+                signature: part.first().map(|line| line.trim().to_string()),
+                doc: None,
+                parent: None,
+                qualified_name: None,
+                tags: vec![struct_tag(CELL_TAG, &number.to_string())],
+            });
+        }
+    }
+
+    Some(chunks)
+}
+
+/// Lines of a cell's source without surrounding blank lines, split at
+/// blank lines into parts of at most [`MAX_CELL_LINES`].
+fn parts<'a>(lines: &'a [&'a str]) -> Vec<&'a [&'a str]> {
+    let mut start = 0;
+    let mut end = lines.len();
+    while end > start && lines[end - 1].trim().is_empty() {
+        end -= 1;
+    }
+
+    let mut parts = Vec::new();
+    while start < end {
+        while start < end && lines[start].trim().is_empty() {
+            start += 1;
+        }
+        if start == end {
+            break;
+        }
+        let mut part_end = end;
+        if part_end - start > MAX_CELL_LINES {
+            let limit = start + MAX_CELL_LINES;
+            part_end = (start + 1..limit)
+                .rev()
+                .find(|&l| lines[l].trim().is_empty())
+                .unwrap_or(limit);
+        }
+        parts.push(&lines[start..part_end]);
+        start = part_end;
+    }
+    parts
+}
+
+/// First and last line (1-based) of each of `count` cells in the notebook's
+/// JSON: from the line of its `"cell_type"` key to the line before the next
+/// cell's. A cell whose key is not found gets the whole file.
+fn cell_lines(content: &str, count: usize) -> Vec<(usize, usize)> {
+    let total = content.lines().count().max(1);
+    let mut starts = Vec::new();
+    for (i, line) in content.lines().enumerate() {
+        // Keys inside string values are escaped, `\"cell_type\"`
+        let mut rest = line;
+        while let Some(pos) = rest.find("\"cell_type\"") {
+            if !rest[..pos].ends_with('\\') {
+                starts.push(i + 1);
+            }
+            rest = &rest[pos + 1..];
+        }
+    }
+    if starts.len() != count {
+        return vec![(1, total); count];
+    }
+    (0..count)
+        .map(|i| {
+            let end = starts
+                .get(i + 1)
+                .map_or(total, |next| (next - 1).max(starts[i]));
+            (starts[i], end)
+        })
+        .collect()
+}
+
+/// Text with the payloads of base64 data URIs removed, keeping the
+/// `data:image/png;base64,` prefix so the reference stays readable.
+fn strip_base64(text: &str) -> String {
+    const MARKER: &str = ";base64,";
+    let mut stripped = String::with_capacity(text.len());
+    let mut rest = text;
+    while let Some(pos) = rest.find(MARKER) {
+        let payload = pos + MARKER.len();
+        stripped.push_str(&rest[..payload]);
+        rest = rest[payload..].trim_start_matches(|c: char| {
+            c.is_ascii_alphanumeric() || matches!(c, '+' | '/' | '=')
+        });
+    }
+    stripped.push_str(rest);
+    stripped
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const NOTEBOOK: &str = r##"{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": [
+    "# Churn analysis\n",
+    "\n",
+    "![chart](data:image/png;base64,iVBORw0KGgo=)"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 1,
+   "metadata": {},
+   "outputs": [
+    {
+     "data": { "image/png": "iVBORw0KGgoAAAANSUhEUg==", "text/plain": ["\"cell_type\""] },
+     "output_type": "display_data"
+    }
+   ],
+   "source": [
+    "import pandas as pd\n",
+    "churn = pd.read_csv('churn.csv')"
+   ]
+  },
+  {
+   "cell_type": "raw",
+   "metadata": {},
+   "source": "raw text"
+  },
+  {
+   "cell_type": "code",
+   "metadata": {},
+   "outputs": [],
+   "source": ""
+  }
+ ],
+ "metadata": {
+  "kernelspec": { "display_name": "R", "language": "R", "name": "ir" }
+ },
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+"##;
+
+    #[test]
+    fn test_cells_are_chunked_in_order() {
+        let chunks = chunk_file(Path::new("analysis/churn.ipynb"), NOTEBOOK).unwrap();
+        let summary: Vec<_> = chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.language.as_deref().unwrap(),
+                    c.name.as_deref().unwrap(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                (SemanticKind::Section, "markdown", "cell 1", 4, 12),
+                (SemanticKind::Block, "r", "cell 2", 13, 27),
+            ]
+        );
+
+        let markdown = &chunks[0];
+        assert_eq!(
+            markdown.content,
+            "# Churn analysis\n\n![chart](data:image/png;base64,)"
+        );
+        assert_eq!(markdown.signature.as_deref(), Some("# Churn analysis"));
+        assert_eq!(markdown.tags, vec!["cell:1"]);
+
+        let code = &chunks[1];
+        assert_eq!(
+            code.content,
+            "import pandas as pd\nchurn = pd.read_csv('churn.csv')"
+        );
+        assert!(!code.content.contains("iVBOR"));
+        assert_eq!(code.tags, vec!["cell:2"]);
+    }
+
+    #[test]
+    fn test_not_a_notebook() {
+        assert!(chunk_file(Path::new("data.json"), NOTEBOOK).is_none());
+        assert!(chunk_file(Path::new("broken.ipynb"), "{").is_none());
+        assert!(chunk_file(Path::new("empty.ipynb"), "{}").is_none());
+    }
+
+    #[test]
+    fn test_long_cells_are_split() {
+        let lines: Vec<String> = (0..100).map(|i| format!("x{} = {}", i, i)).collect();
+        let lines: Vec<&str> = lines.iter().map(String::as_str).collect();
+        let parts = parts(&lines);
+        assert_eq!(parts.len(), 2);
+        assert_eq!(parts[0].len(), MAX_CELL_LINES);
+    }
+}
@@ -0,0 +1,607 @@
+//! OpenAPI / Swagger spec chunking
+//!
+//! OpenAPI documents are JSON or YAML rather than code, so they are not parsed
+//! with tree-sitter. Instead the spec is deserialized and each operation under
+//! `paths` becomes an [`SemanticKind::Endpoint`] chunk named `METHOD /route`,
+//! and each schema under `components.schemas` (or Swagger 2 `definitions`)
+//! becomes a [`SemanticKind::Struct`] chunk, with one [`SemanticKind::Field`]
+//! chunk per property whose parent is the schema. Operation signatures link
+//! to the request and response schemas they reference, so a query like
+//! "which endpoint returns a User" matches on the signature as well as the
+//! content. Operation summaries and schema titles and descriptions are kept
+//! as the chunk's doc.
+//!
+//! Chunk content is the original source text of the operation or schema.
+//! Line ranges are recovered by locating the keys in the source, which
+//! works for pretty-printed JSON and block-style YAML.
+
+use std::path::Path;
+
+use serde_json::{Map, Value};
+
+use super::ast_chunker::SemanticKind;
+use super::Chunk;
+
+/// Language identifier assigned to OpenAPI chunks
+pub const LANGUAGE: &str = "openapi";
+
+/// HTTP methods recognised as operations under a path item
+const METHODS: &[&str] = &[
+    "get", "put", "post", "delete", "options", "head", "patch", "trace",
+];
+
+/// Chunk an OpenAPI document.
+///
+/// Returns `None` when the file is not JSON/YAML, cannot be parsed, or does
+/// not declare an `openapi` or `swagger` version, so callers can fall back to
+/// regular chunking.
+pub fn chunk_spec(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    let spec = parse_spec(path, content)?;
+    let root = spec.as_object()?;
+    if !root.contains_key("openapi") && !root.contains_key("swagger") {
+        return None;
+    }
+
+    let source = SourceMap::new(content);
+    let mut chunks = Vec::new();
+
+    if let Some(paths) = root.get("paths").and_then(Value::as_object) {
+        let paths_line = source.find_child(None, "paths");
+        for (route, item) in paths {
+            let Some(item) = item.as_object() else {
+                continue;
+            };
+            let route_line = paths_line.and_then(|l| source.find_child(Some(l), route));
+            for method in METHODS {
+                let Some(operation) = item.get(*method).and_then(Value::as_object) else {
+                    continue;
+                };
+                let line = route_line.and_then(|l| source.find_child(Some(l), method));
+                chunks.push(operation_chunk(
+                    path, &source, line, method, route, operation,
+                ));
+            }
+        }
+    }
+
+    let (schemas, pointer) = match root
+        .get("components")
+        .and_then(|c| c.get("schemas"))
+        .and_then(Value::as_object)
+    {
+        Some(schemas) => (Some(schemas), "#/components/schemas/"),
+        None => (
+            root.get("definitions").and_then(Value::as_object),
+            "#/definitions/",
+        ),
+    };
+    if let Some(schemas) = schemas {
+        let schemas_line = if pointer.starts_with("#/components") {
+            source
+                .find_child(None, "components")
+                .and_then(|l| source.find_child(Some(l), "schemas"))
+        } else {
+            source.find_child(None, "definitions")
+        };
+        for (name, schema) in schemas {
+            let line = schemas_line.and_then(|l| source.find_child(Some(l), name));
+            chunks.push(schema_chunk(path, &source, line, name, pointer, schema));
+            chunks.extend(property_chunks(path, &source, line, name, pointer, schema));
+        }
+    }
+
+    chunks.sort_by_key(|c| c.start_line);
+    Some(chunks)
+}
+
+fn parse_spec(path: &Path, content: &str) -> Option<Value> {
+    match path.extension().and_then(|e| e.to_str())? {
+        "json" => serde_json::from_str(content).ok(),
+        "yaml" | "yml" => serde_yaml::from_str(content).ok(),
+        _ => None,
+    }
+}
+
+fn operation_chunk(
+    path: &Path,
+    source: &SourceMap,
+    line: Option<usize>,
+    method: &str,
+    route: &str,
+    operation: &Map<String, Value>,
+) -> Chunk {
+    let name = format!("{} {}", method.to_uppercase(), route);
+
+    let mut signature = name.clone();
+    if let Some(body) = request_schema(operation) {
+        signature.push_str(&format!(" (body: {})", body));
+    }
+    let responses = response_schemas(operation);
+    if !responses.is_empty() {
+        let rendered: Vec<String> = responses
+            .iter()
+            .map(|(status, schema)| format!("{}: {}", status, schema))
+            .collect();
+        signature.push_str(&format!(" -> {}", rendered.join(", ")));
+    }
+
+    let doc = doc_text(operation, "summary");
+    let (start_line, end_line, mut content) = source.block(line);
+    if line.is_none() {
+        // Key could not be located; describe the operation instead of
+        // pointing at unrelated lines
+        content = match &doc {
+            Some(doc) => format!("{}\n{}", signature, doc),
+            None => signature.clone(),
+        };
+    }
+
+    Chunk {
+        content,
+        file_path: path.to_path_buf(),
+        start_line,
+        end_line,
+        language: Some(LANGUAGE.to_string()),
+        semantic_kind: Some(SemanticKind::Endpoint),
+        name: Some(name.clone()),
+        signature: Some(signature),
+        doc,
+        parent: operation
+            .get("tags")
+            .and_then(Value::as_array)
+            .and_then(|tags| tags.first())
+            .and_then(Value::as_str)
+            .map(String::from),
+        qualified_name: Some(name),
+        tags: Vec::new(),
+    }
+}
+
+fn schema_chunk(
+    path: &Path,
+    source: &SourceMap,
+    line: Option<usize>,
+    name: &str,
+    pointer: &str,
+    schema: &Value,
+) -> Chunk {
+    let properties: Vec<String> = schema
+        .get("properties")
+        .and_then(Value::as_object)
+        .map(|props| {
+            props
+                .iter()
+                .map(|(prop, prop_schema)| format!("{}: {}", prop, schema_name(prop_schema)))
+                .collect()
+        })
+        .unwrap_or_default();
+    let signature = if properties.is_empty() {
+        format!("{}: {}", name, schema_name(schema))
+    } else {
+        format!("{} {{ {} }}", name, properties.join(", "))
+    };
+
+    let (start_line, end_line, mut content) = source.block(line);
+    if line.is_none() {
+        content = signature.clone();
+    }
+
+    Chunk {
+        content,
+        file_path: path.to_path_buf(),
+        start_line,
+        end_line,
+        language: Some(LANGUAGE.to_string()),
+        semantic_kind: Some(SemanticKind::Struct),
+        name: Some(name.to_string()),
+        signature: Some(signature),
+        doc: schema.as_object().and_then(|s| doc_text(s, "title")),
+        parent: None,
+        qualified_name: Some(format!("{}{}", pointer, name)),
+        tags: Vec::new(),
+    }
+}
+
+/// One chunk per property of a schema, with the schema as parent
+fn property_chunks(
+    path: &Path,
+    source: &SourceMap,
+    schema_line: Option<usize>,
+    name: &str,
+    pointer: &str,
+    schema: &Value,
+) -> Vec<Chunk> {
+    let Some(properties) = schema.get("properties").and_then(Value::as_object) else {
+        return Vec::new();
+    };
+    let properties_line = schema_line.and_then(|l| source.find_child(Some(l), "properties"));
+
+    properties
+        .iter()
+        .map(|(prop, prop_schema)| {
+            let line = properties_line.and_then(|l| source.find_child(Some(l), prop));
+            let signature = format!("{}: {}", prop, schema_name(prop_schema));
+            let (start_line, end_line, mut content) = source.block(line);
+            if line.is_none() {
+                content = signature.clone();
+            }
+
+            Chunk {
+                content,
+                file_path: path.to_path_buf(),
+                start_line,
+                end_line,
+                language: Some(LANGUAGE.to_string()),
+                semantic_kind: Some(SemanticKind::Field),
+                name: Some(prop.clone()),
+                signature: Some(signature),
+                doc: prop_schema.as_object().and_then(|s| doc_text(s, "title")),
+                parent: Some(name.to_string()),
+                qualified_name: Some(format!("{}{}/properties/{}", pointer, name, prop)),
+                tags: Vec::new(),
+            }
+        })
+        .collect()
+}
+
+/// `heading` (an operation's `summary` or a schema's `title`) and
+/// `description` of an object, separated by a blank line
+fn doc_text(object: &Map<String, Value>, heading: &str) -> Option<String> {
+    let parts: Vec<&str> = [heading, "description"]
+        .iter()
+        .filter_map(|key| object.get(*key).and_then(Value::as_str))
+        .map(str::trim)
+        .filter(|text| !text.is_empty())
+        .collect();
+    (!parts.is_empty()).then(|| parts.join("\n\n"))
+}
+
+/// Schema referenced by an operation's request body (OpenAPI 3) or body
+/// parameter (Swagger 2)
+fn request_schema(operation: &Map<String, Value>) -> Option<String> {
+    if let Some(schema) = operation
+        .get("requestBody")
+        .and_then(|b| b.get("content"))
+        .and_then(first_media_schema)
+    {
+        return Some(schema_name(schema));
+    }
+    operation
+        .get("parameters")
+        .and_then(Value::as_array)?
+        .iter()
+        .find(|p| p.get("in").and_then(Value::as_str) == Some("body"))
+        .and_then(|p| p.get("schema"))
+        .map(schema_name)
+}
+
+/// Schemas returned per response status code
+fn response_schemas(operation: &Map<String, Value>) -> Vec<(String, String)> {
+    let Some(responses) = operation.get("responses").and_then(Value::as_object) else {
+        return Vec::new();
+    };
+    responses
+        .iter()
+        .filter_map(|(status, response)| {
+            let schema = response
+                .get("content")
+                .and_then(first_media_schema)
+                .or_else(|| response.get("schema"))?;
+            Some((status.clone(), schema_name(schema)))
+        })
+        .collect()
+}
+
+fn first_media_schema(content: &Value) -> Option<&Value> {
+    content
+        .as_object()?
+        .values()
+        .find_map(|media| media.get("schema"))
+}
+
+/// Short display name for a schema: the `$ref` target, `T[]` for arrays, or
+/// the primitive type
+fn schema_name(schema: &Value) -> String {
+    if let Some(reference) = schema.get("$ref").and_then(Value::as_str) {
+        return reference
+            .rsplit('/')
+            .next()
+            .unwrap_or(reference)
+            .to_string();
+    }
+    match schema.get("type").and_then(Value::as_str) {
+        Some("array") => match schema.get("items") {
+            Some(items) => format!("{}[]", schema_name(items)),
+            None => "array".to_string(),
+        },
+        Some(ty) => ty.to_string(),
+        None => "object".to_string(),
+    }
+}
+
+/// Locates mapping keys in the original source text by indentation.
+struct SourceMap<'a> {
+    lines: Vec<&'a str>,
+}
+
+impl<'a> SourceMap<'a> {
+    fn new(content: &'a str) -> Self {
+        Self {
+            lines: content.lines().collect(),
+        }
+    }
+
+    /// Find the line of `key` among the direct children of the block opened
+    /// on `parent` (or the top level when `parent` is `None`).
+    fn find_child(&self, parent: Option<usize>, key: &str) -> Option<usize> {
+        let (start, end) = match parent {
+            Some(line) => (line + 1, self.block_end(line)),
+            None => (0, self.lines.len().saturating_sub(1)),
+        };
+        let child_indent = (start..=end.min(self.lines.len().saturating_sub(1)))
+            .filter(|&i| is_key_line(self.lines[i]))
+            .map(|i| indent(self.lines[i]))
+            .next()?;
+
+        (start..=end.min(self.lines.len().saturating_sub(1))).find(|&i| {
+            let line = self.lines[i];
+            indent(line) == child_indent && key_of(line.trim_start()) == Some(key)
+        })
+    }
+
+    /// Last line belonging to the block that starts on `line`
+    fn block_end(&self, line: usize) -> usize {
+        let base = indent(self.lines[line]);
+        let mut end = line;
+        for i in line + 1..self.lines.len() {
+            let text = self.lines[i];
+            let trimmed = text.trim();
+            if trimmed.is_empty() || trimmed.starts_with('#') {
+                continue;
+            }
+            if indent(text) <= base {
+                // Closing JSON brackets at the key's indentation still belong to it
+                if trimmed.starts_with('}') || trimmed.starts_with(']') {
+                    end = i;
+                }
+                break;
+            }
+            end = i;
+        }
+        end
+    }
+
+    /// 1-indexed line range and source text of the block on `line`
+    fn block(&self, line: Option<usize>) -> (usize, usize, String) {
+        match line {
+            Some(line) => {
+                let end = self.block_end(line);
+                (line + 1, end + 1, self.lines[line..=end].join("\n"))
+            }
+            None => (1, 1, String::new()),
+        }
+    }
+}
+
+pub(super) fn indent(line: &str) -> usize {
+    line.len() - line.trim_start().len()
+}
+
+fn is_key_line(line: &str) -> bool {
+    key_of(line.trim_start()).is_some()
+}
+
+/// Extract the mapping key from a trimmed YAML or JSON line
+pub(super) fn key_of(trimmed: &str) -> Option<&str> {
+    let (key, rest) = match trimmed.chars().next()? {
+        quote @ ('"' | '\'') => {
+            let close = trimmed[1..].find(quote)? + 1;
+            (&trimmed[1..close], &trimmed[close + 1..])
+        }
+        '{' | '}' | '[' | ']' | '-' | '#' => return None,
+        _ => {
+            let colon = trimmed.find(':')?;
+            (trimmed[..colon].trim_end(), &trimmed[colon..])
+        }
+    };
+    rest.trim_start().starts_with(':').then_some(key)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const JSON_SPEC: &str = r##"{
+  "openapi": "3.0.0",
+  "info": { "title": "Users", "version": "1.0" },
+  "paths": {
+    "/users/{id}": {
+      "get": {
+        "summary": "Get a user by id",
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": { "$ref": "#/components/schemas/User" }
+              }
+            }
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "User": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "integer" },
+          "name": { "type": "string" }
+        }
+      }
+    }
+  }
+}
+"##;
+
+    #[test]
+    fn test_json_spec_operations_and_schemas() {
+        let chunks = chunk_spec(Path::new("api.json"), JSON_SPEC).unwrap();
+        assert_eq!(chunks.len(), 4);
+
+        let op = &chunks[0];
+        assert_eq!(op.semantic_kind, Some(SemanticKind::Endpoint));
+        assert_eq!(op.name.as_deref(), Some("GET /users/{id}"));
+        assert_eq!(
+            op.signature.as_deref(),
+            Some("GET /users/{id} -> 200: User")
+        );
+        assert_eq!(op.start_line, 6);
+        assert_eq!(op.end_line, 17);
+        assert!(op.content.trim_start().starts_with("\"get\""));
+        assert!(op.content.contains("Get a user by id"));
+        assert_eq!(op.doc.as_deref(), Some("Get a user by id"));
+
+        let schema = &chunks[1];
+        assert_eq!(schema.semantic_kind, Some(SemanticKind::Struct));
+        assert_eq!(schema.name.as_deref(), Some("User"));
+        assert_eq!(
+            schema.signature.as_deref(),
+            Some("User { id: integer, name: string }")
+        );
+        assert_eq!(
+            schema.qualified_name.as_deref(),
+            Some("#/components/schemas/User")
+        );
+        assert_eq!(schema.start_line, 22);
+
+        let fields: Vec<(&str, &str, usize)> = chunks[2..]
+            .iter()
+            .map(|c| {
+                assert_eq!(c.semantic_kind, Some(SemanticKind::Field));
+                assert_eq!(c.parent.as_deref(), Some("User"));
+                (
+                    c.name.as_deref().unwrap(),
+                    c.signature.as_deref().unwrap(),
+                    c.start_line,
+                )
+            })
+            .collect();
+        assert_eq!(
+            fields,
+            vec![("id", "id: integer", 25), ("name", "name: string", 26)]
+        );
+        assert_eq!(
+            chunks[2].qualified_name.as_deref(),
+            Some("#/components/schemas/User/properties/id")
+        );
+    }
+
+    #[test]
+    fn test_descriptions_become_docs() {
+        let spec = r##"openapi: 3.0.0
+paths:
+  /orders:
+    post:
+      summary: Place an order
+      description: Charges the customer and reserves stock.
+      responses:
+        "201":
+          description: Created
+components:
+  schemas:
+    Order:
+      title: Order
+      description: A customer order.
+      type: object
+      properties:
+        total:
+          type: number
+          description: Total in cents, including tax.
+        note:
+          type: string
+"##;
+        let chunks = chunk_spec(Path::new("api.yaml"), spec).unwrap();
+        let doc = |name: &str| {
+            chunks
+                .iter()
+                .find(|c| c.name.as_deref() == Some(name))
+                .unwrap()
+                .doc
+                .clone()
+        };
+
+        assert_eq!(
+            doc("POST /orders").as_deref(),
+            Some("Place an order\n\nCharges the customer and reserves stock.")
+        );
+        assert_eq!(doc("Order").as_deref(), Some("Order\n\nA customer order."));
+        assert_eq!(
+            doc("total").as_deref(),
+            Some("Total in cents, including tax.")
+        );
+        assert_eq!(doc("note"), None);
+
+        let total = chunks
+            .iter()
+            .find(|c| c.name.as_deref() == Some("total"))
+            .unwrap();
+        assert_eq!(total.parent.as_deref(), Some("Order"));
+        assert_eq!((total.start_line, total.end_line), (17, 19));
+    }
+
+    #[test]
+    fn test_swagger2_body_parameters() {
+        let spec = r##"swagger: "2.0"
+paths:
+  /pets:
+    post:
+      tags: [pets]
+      parameters:
+        - in: body
+          name: pet
+          schema:
+            $ref: "#/definitions/Pet"
+      responses:
+        "201":
+          schema:
+            type: array
+            items:
+              $ref: "#/definitions/Pet"
+definitions:
+  Pet:
+    type: object
+"##;
+        let chunks = chunk_spec(Path::new("swagger.yaml"), spec).unwrap();
+        let op = &chunks[0];
+        assert_eq!(
+            op.signature.as_deref(),
+            Some("POST /pets (body: Pet) -> 201: Pet[]")
+        );
+        assert_eq!(op.parent.as_deref(), Some("pets"));
+        assert_eq!(op.start_line, 4);
+        assert_eq!(op.end_line, 16);
+        assert_eq!(
+            chunks[1].qualified_name.as_deref(),
+            Some("#/definitions/Pet")
+        );
+    }
+
+    #[test]
+    fn test_non_openapi_documents_are_ignored() {
+        assert!(chunk_spec(Path::new("package.json"), r#"{"name": "x"}"#).is_none());
+        assert!(chunk_spec(Path::new("config.yaml"), "key: value\n").is_none());
+        assert!(chunk_spec(Path::new("main.rs"), "fn main() {}").is_none());
+        assert!(chunk_spec(Path::new("broken.json"), "{ not json").is_none());
+    }
+
+    #[test]
+    fn test_key_of() {
+        assert_eq!(key_of("get:"), Some("get"));
+        assert_eq!(key_of("\"/users/{id}\": {"), Some("/users/{id}"));
+        assert_eq!(key_of("'200':"), Some("200"));
+        assert_eq!(key_of("/pets:"), Some("/pets"));
+        assert_eq!(key_of("- in: body"), None);
+        assert_eq!(key_of("}"), None);
+    }
+}
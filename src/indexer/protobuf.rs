@@ -0,0 +1,404 @@
+//! Protocol Buffers schema chunking
+//!
+//! Each definition of a `.proto` file becomes a chunk qualified by the
+//! file's package:
+//!
+//! - `message` is a [`SemanticKind::Struct`] chunk
+//! - `enum` is a [`SemanticKind::Enum`] chunk
+//! - `service` is a [`SemanticKind::Interface`] chunk
+//! - each `rpc` is a [`SemanticKind::Method`] chunk whose parent is its
+//!   service, qualified as `<package>.<Service>.<Method>`
+//!
+//! Nested messages and enums get chunks of their own too, with the
+//! enclosing message as parent. A chunk keeps the `//` comment lines
+//! directly above its definition and the whole body, including option
+//! annotations such as `option (google.api.http) = { get: "/v1/..." }`.
+//! The options set on a definition are also tags: `option:google.api.http`,
+//! `option:deprecated`.
+
+use std::path::Path;
+
+use super::ast_chunker::SemanticKind;
+use super::struct_tags::struct_tag;
+use super::Chunk;
+
+/// Language identifier assigned to Protocol Buffers chunks
+pub const LANGUAGE: &str = "protobuf";
+
+/// Extension of Protocol Buffers files
+pub const EXTENSION: &str = "proto";
+
+/// Chunk a `.proto` file into one chunk per message, enum, service and
+/// RPC.
+///
+/// Returns `None` when `path` is not a `.proto` file, so callers can fall
+/// back to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    if path.extension().and_then(|e| e.to_str()) != Some(EXTENSION) {
+        return None;
+    }
+
+    let lines: Vec<&str> = content.lines().collect();
+    let line_of = |pos: usize| content[..pos].matches('\n').count();
+    let tokens = tokenize(content);
+    let text = |i: usize| tokens.get(i).map(|t| t.text).unwrap_or_default();
+
+    let mut package: Option<&str> = None;
+    let mut scopes: Vec<Scope> = Vec::new();
+    let mut definitions = Vec::new();
+    let mut i = 0;
+
+    while i < tokens.len() {
+        let token = &tokens[i];
+        let kind = match token.text {
+            "message" => Some(SemanticKind::Struct),
+            "enum" => Some(SemanticKind::Enum),
+            "service" => Some(SemanticKind::Interface),
+            _ => None,
+        };
+
+        if token.text == "package" && scopes.is_empty() {
+            package = Some(text(i + 1));
+            i += 2;
+        } else if let Some(kind) = kind.filter(|_| is_name(text(i + 1)) && text(i + 2) == "{") {
+            scopes.push(Scope {
+                definition: Some(Definition {
+                    kind,
+                    name: text(i + 1).to_string(),
+                    signature: format!("{} {}", token.text, text(i + 1)),
+                    start: token.pos,
+                    end: token.pos,
+                    options: Vec::new(),
+                }),
+            });
+            i += 3;
+        } else if token.text == "rpc" && is_name(text(i + 1)) {
+            // rpc Name ( [stream] Request ) returns ( [stream] Response )
+            let mut words = Vec::new();
+            let mut j = i + 2;
+            while j < tokens.len() && !matches!(text(j), ";" | "{") {
+                words.push(text(j));
+                j += 1;
+            }
+            let types = words.join(" ").replace("( ", "(").replace(" )", ")");
+            let definition = Definition {
+                kind: SemanticKind::Method,
+                name: text(i + 1).to_string(),
+                signature: format!("rpc {}{}", text(i + 1), types),
+                start: token.pos,
+                end: tokens.get(j).map_or(content.len(), |t| t.pos),
+                options: Vec::new(),
+            };
+            if text(j) == "{" {
+                scopes.push(Scope {
+                    definition: Some(definition),
+                });
+            } else {
+                definitions.push((definition, qualify(package, &scopes)));
+            }
+            i = j + 1;
+        } else if token.text == "option" {
+            // option (google.api.http) = { ... }; records google.api.http
+            let mut name = String::new();
+            let mut j = i + 1;
+            while j < tokens.len() && !matches!(text(j), "=" | ";") {
+                name.push_str(text(j));
+                j += 1;
+            }
+            if let Some(definition) = scopes.last_mut().and_then(|s| s.definition.as_mut()) {
+                definition.options.push(name.replace(['(', ')'], ""));
+            }
+            i = j;
+        } else if token.text == "{" {
+            scopes.push(Scope { definition: None });
+            i += 1;
+        } else if token.text == "}" {
+            if let Some(Scope {
+                definition: Some(mut definition),
+            }) = scopes.pop()
+            {
+                definition.end = token.pos;
+                definitions.push((definition, qualify(package, &scopes)));
+            }
+            i += 1;
+        } else {
+            i += 1;
+        }
+    }
+
+    let mut chunks: Vec<Chunk> = definitions
+        .into_iter()
+        .map(|(definition, (prefix, parent))| {
+            let start = leading_comments_start(&lines, line_of(definition.start));
+            let end = line_of(definition.end).min(lines.len() - 1);
+            let mut tags: Vec<String> = Vec::new();
+            for option in &definition.options {
+                let tag = struct_tag("option", option);
+                if !tags.contains(&tag) {
+                    tags.push(tag);
+                }
+            }
+            Chunk {
+                content: lines[start..=end].join("\n"),
+                file_path: path.to_path_buf(),
+                start_line: start + 1,
+                end_line: end + 1,
+                language: Some(LANGUAGE.to_string()),
+                semantic_kind: Some(definition.kind),
+                qualified_name: Some(match prefix {
+                    Some(prefix) => format!("{}.{}", prefix, definition.name),
+                    None => definition.name.clone(),
+                }),
+                name: Some(definition.name),
+                signature: Some(definition.signature),
+                doc: None,
+                parent,
+                tags,
+            }
+        })
+        .collect();
+    // Definitions are complete at their closing brace, after nested ones
+    chunks.sort_by_key(|c| c.start_line);
+
+    Some(chunks)
+}
+
+/// A `{ ... }` body: a definition's, or another one such as `oneof` or an
+/// option value
+struct Scope {
+    definition: Option<Definition>,
+}
+
+/// A message, enum, service or RPC
+struct Definition {
+    kind: SemanticKind,
+    name: String,
+    signature: String,
+    /// Byte offsets of the keyword and of the closing `}` or `;`
+    start: usize,
+    end: usize,
+    /// Names of the options set in the body
+    options: Vec<String>,
+}
+
+/// Qualifier and parent of a definition in `scopes`: the package and the
+/// enclosing definitions, and the innermost of those.
+fn qualify(package: Option<&str>, scopes: &[Scope]) -> (Option<String>, Option<String>) {
+    let mut parts: Vec<&str> = package.into_iter().collect();
+    let mut parent = None;
+    for definition in scopes.iter().filter_map(|s| s.definition.as_ref()) {
+        parts.push(&definition.name);
+        parent = Some(definition.name.clone());
+    }
+    let prefix = (!parts.is_empty()).then(|| parts.join("."));
+    (prefix, parent)
+}
+
+/// First line of the `//` comment block directly above `line`.
+fn leading_comments_start(lines: &[&str], line: usize) -> usize {
+    let mut start = line;
+    while start > 0 && lines[start - 1].trim_start().starts_with("//") {
+        start -= 1;
+    }
+    start
+}
+
+fn is_name(text: &str) -> bool {
+    text.starts_with(|c: char| c.is_alphabetic() || c == '_')
+}
+
+/// A word, string literal or punctuation character and its byte offset
+struct Token<'a> {
+    text: &'a str,
+    pos: usize,
+}
+
+/// Tokens of a `.proto` file, skipping whitespace and comments.
+fn tokenize(content: &str) -> Vec<Token<'_>> {
+    let mut tokens = Vec::new();
+    let mut i = 0;
+    while i < content.len() {
+        let rest = &content[i..];
+        let c = rest.chars().next().unwrap_or(' ');
+        let len = if c.is_whitespace() {
+            i += c.len_utf8();
+            continue;
+        } else if rest.starts_with("//") {
+            i += rest.find('\n').unwrap_or(rest.len());
+            continue;
+        } else if rest.starts_with("/*") {
+            i += rest.find("*/").map_or(rest.len(), |end| end + 2);
+            continue;
+        } else if c == '"' || c == '\'' {
+            string_len(rest, c)
+        } else if c.is_alphanumeric() || c == '_' || c == '.' {
+            rest.find(|c: char| !(c.is_alphanumeric() || c == '_' || c == '.'))
+                .unwrap_or(rest.len())
+        } else {
+            c.len_utf8()
+        };
+        tokens.push(Token {
+            text: &rest[..len],
+            pos: i,
+        });
+        i += len;
+    }
+    tokens
+}
+
+/// Length of the string literal at the start of `text`, quotes included.
+fn string_len(text: &str, quote: char) -> usize {
+    let mut escaped = false;
+    for (pos, c) in text.char_indices().skip(1) {
+        match c {
+            _ if escaped => escaped = false,
+            '\\' => escaped = true,
+            _ if c == quote => return pos + 1,
+            _ => {}
+        }
+    }
+    text.len()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunks(content: &str) -> Vec<Chunk> {
+        chunk_file(Path::new("invoice.proto"), content).unwrap()
+    }
+
+    #[test]
+    fn test_services_and_rpcs() {
+        let content = r#"syntax = "proto3";
+
+package acme.billing.v1;
+
+import "google/api/annotations.proto";
+
+// Invoices of a customer account.
+service InvoiceService {
+  // Fetch one invoice.
+  rpc GetInvoice(GetInvoiceRequest) returns (Invoice) {
+    option (google.api.http) = { get: "/v1/invoices/{id}" };
+  }
+
+  rpc WatchInvoices(WatchRequest) returns (stream Invoice);
+  rpc Legacy(GetInvoiceRequest) returns (Invoice) { option deprecated = true; }
+}
+"#;
+        let chunks = chunks(content);
+        let summary: Vec<_> = chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.qualified_name.as_deref().unwrap(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            summary,
+            vec![
+                (
+                    SemanticKind::Interface,
+                    "acme.billing.v1.InvoiceService",
+                    7,
+                    16
+                ),
+                (
+                    SemanticKind::Method,
+                    "acme.billing.v1.InvoiceService.GetInvoice",
+                    9,
+                    12
+                ),
+                (
+                    SemanticKind::Method,
+                    "acme.billing.v1.InvoiceService.WatchInvoices",
+                    14,
+                    14
+                ),
+                (
+                    SemanticKind::Method,
+                    "acme.billing.v1.InvoiceService.Legacy",
+                    15,
+                    15
+                ),
+            ]
+        );
+
+        let get = &chunks[1];
+        assert_eq!(get.name.as_deref(), Some("GetInvoice"));
+        assert_eq!(get.parent.as_deref(), Some("InvoiceService"));
+        assert_eq!(
+            get.signature.as_deref(),
+            Some("rpc GetInvoice(GetInvoiceRequest) returns (Invoice)")
+        );
+        assert!(get.content.contains(r#"get: "/v1/invoices/{id}""#));
+        assert_eq!(get.tags, vec!["option:google.api.http"]);
+
+        assert_eq!(
+            chunks[2].signature.as_deref(),
+            Some("rpc WatchInvoices(WatchRequest) returns (stream Invoice)")
+        );
+        assert_eq!(chunks[3].tags, vec!["option:deprecated"]);
+        assert!(chunks[0].tags.is_empty());
+    }
+
+    #[test]
+    fn test_messages_and_enums() {
+        let content = r#"package acme.billing.v1;
+
+message Invoice {
+  option deprecated = false;
+
+  enum State {
+    STATE_UNSPECIFIED = 0;
+    STATE_PAID = 1;
+  }
+
+  string id = 1;
+  string message = 2; // "}" in a comment
+  oneof payer {
+    string user_id = 3;
+  }
+  State state = 4 [deprecated = true];
+}
+
+enum Currency { CURRENCY_UNSPECIFIED = 0; }
+"#;
+        let chunks = chunks(content);
+        let summary: Vec<_> = chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.qualified_name.as_deref().unwrap(),
+                    c.parent.as_deref(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            summary,
+            vec![
+                (SemanticKind::Struct, "acme.billing.v1.Invoice", None, 3, 17),
+                (
+                    SemanticKind::Enum,
+                    "acme.billing.v1.Invoice.State",
+                    Some("Invoice"),
+                    6,
+                    9
+                ),
+                (SemanticKind::Enum, "acme.billing.v1.Currency", None, 19, 19),
+            ]
+        );
+        assert_eq!(chunks[0].tags, vec!["option:deprecated"]);
+        assert!(chunk_file(Path::new("main.rs"), "fn main() {}").is_none());
+    }
+}
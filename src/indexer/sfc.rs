@@ -0,0 +1,378 @@
+//! Vue and Svelte single-file components
+//!
+//! A `.vue` or `.svelte` file holds a component's script, markup and styles
+//! in one file. [`split`] finds its top-level `<script>`, `<template>` and
+//! `<style>` sections so the script can be chunked with the JavaScript or
+//! TypeScript parser and the markup and styles kept as chunks of their own.
+//! Svelte markup is not wrapped in a `<template>`: the lines outside the
+//! script and style sections are the template.
+//!
+//! Sections are found textually: an opening tag must start its line, and
+//! a section ends at the first matching closing tag, counting nested
+//! `<template>` tags in Vue markup.
+
+use std::path::Path;
+
+/// Extensions of single-file components
+pub const EXTENSIONS: &[&str] = &["vue", "svelte"];
+
+/// Key of the tag naming the component a chunk belongs to
+pub const COMPONENT_TAG: &str = "component";
+
+/// Whether `path` is a Vue or Svelte single-file component.
+pub fn is_component(path: &Path) -> bool {
+    path.extension()
+        .and_then(|e| e.to_str())
+        .is_some_and(|e| EXTENSIONS.contains(&e))
+}
+
+/// Language identifier of a component's markup and styles: `vue` or
+/// `svelte`.
+pub fn language(path: &Path) -> Option<&'static str> {
+    let extension = path.extension().and_then(|e| e.to_str())?;
+    EXTENSIONS.iter().copied().find(|e| *e == extension)
+}
+
+/// Kind of a component section
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum SectionKind {
+    Script,
+    Template,
+    Style,
+}
+
+impl SectionKind {
+    /// Tag name of the section
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            SectionKind::Script => "script",
+            SectionKind::Template => "template",
+            SectionKind::Style => "style",
+        }
+    }
+}
+
+/// A top-level section of a component
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Section {
+    pub kind: SectionKind,
+    /// Opening tag, e.g. `<script setup lang="ts">`; empty for Svelte
+    /// markup
+    pub tag: String,
+    /// First and last line of the section, tags included, 0-based
+    pub start_line: usize,
+    pub end_line: usize,
+    /// First line of the body, between the tags, 0-based
+    pub body_start_line: usize,
+    /// Lines between the opening and closing tag
+    pub body: String,
+}
+
+impl Section {
+    /// Value of an attribute of the opening tag, `Some("")` for a bare
+    /// attribute such as `setup`.
+    pub fn attribute(&self, name: &str) -> Option<&str> {
+        let attributes = self.tag.trim_start_matches('<').trim_end_matches('>');
+        let mut rest = attributes.split_once(char::is_whitespace)?.1;
+        loop {
+            rest = rest.trim_start();
+            let key_len = rest
+                .find(|c: char| c.is_whitespace() || c == '=' || c == '/')
+                .unwrap_or(rest.len());
+            if key_len == 0 {
+                return None;
+            }
+            let (key, after) = rest.split_at(key_len);
+            let after = after.trim_start();
+            let (value, next) = match after.strip_prefix('=') {
+                Some(value) => {
+                    let value = value.trim_start();
+                    match value.chars().next() {
+                        Some(quote @ ('"' | '\'')) => {
+                            let end = value[1..].find(quote).map_or(value.len(), |e| e + 1);
+                            (&value[1..end], value.get(end + 1..).unwrap_or_default())
+                        }
+                        _ => {
+                            let end = value.find(char::is_whitespace).unwrap_or(value.len());
+                            value.split_at(end)
+                        }
+                    }
+                }
+                None => ("", after),
+            };
+            if key == name {
+                return Some(value);
+            }
+            rest = next;
+        }
+    }
+
+    /// Language of a script section: `typescript` for `lang="ts"`,
+    /// otherwise `javascript`.
+    pub fn script_language(&self) -> &'static str {
+        match self.attribute("lang") {
+            Some("ts" | "tsx" | "typescript") => "typescript",
+            _ => "javascript",
+        }
+    }
+}
+
+/// Top-level sections of a component, in order.
+pub fn split(path: &Path, content: &str) -> Vec<Section> {
+    let lines: Vec<&str> = content.lines().collect();
+    let svelte = language(path) == Some("svelte");
+    let mut sections = Vec::new();
+    // Lines outside sections, the markup of a Svelte component
+    let mut outside: Vec<usize> = Vec::new();
+    let mut i = 0;
+
+    while i < lines.len() {
+        let Some((kind, name)) = opening_tag(lines[i]) else {
+            outside.push(i);
+            i += 1;
+            continue;
+        };
+
+        // The opening tag may span lines; the body starts after its `>`
+        let start = i;
+        let mut tag = String::new();
+        while i < lines.len() {
+            tag.push_str(lines[i].trim());
+            tag.push(' ');
+            if lines[i].contains('>') {
+                break;
+            }
+            i += 1;
+        }
+        let tag_end_line = i;
+        let tag = tag.split('>').next().unwrap_or_default().trim().to_string() + ">";
+
+        // The closing tag may be on the opening tag's line
+        let close = format!("</{}>", name);
+        let open = format!("<{}", name);
+        let mut depth = 0usize;
+        let mut end = None;
+        let first_line = &lines[tag_end_line][lines[tag_end_line].find('>').unwrap_or(0)..];
+        for (j, line) in lines.iter().enumerate().skip(tag_end_line) {
+            let line = if j == tag_end_line { first_line } else { line };
+            // Nested `<template #slot>` tags in Vue markup
+            if kind == SectionKind::Template && j > tag_end_line {
+                depth += line.matches(open.as_str()).count();
+            }
+            let closes = line.matches(close.as_str()).count();
+            if closes > depth {
+                end = Some(j);
+                break;
+            }
+            depth -= closes;
+        }
+        let end = end.unwrap_or(lines.len() - 1);
+
+        let body_start_line = (tag_end_line + 1).min(end);
+        sections.push(Section {
+            kind,
+            tag,
+            start_line: start,
+            end_line: end,
+            body_start_line,
+            body: lines[body_start_line..end].join("\n"),
+        });
+        i = end + 1;
+    }
+
+    if svelte {
+        // Runs of markup lines, separated only by blank lines, are one
+        // template each
+        let mut runs: Vec<(usize, usize)> = Vec::new();
+        for &line in outside.iter().filter(|&&l| !lines[l].trim().is_empty()) {
+            match runs.last_mut() {
+                Some((_, last)) if (*last + 1..line).all(|l| lines[l].trim().is_empty()) => {
+                    *last = line;
+                }
+                _ => runs.push((line, line)),
+            }
+        }
+        for (start, end) in runs {
+            sections.push(Section {
+                kind: SectionKind::Template,
+                tag: String::new(),
+                start_line: start,
+                end_line: end,
+                body_start_line: start,
+                body: lines[start..=end].join("\n"),
+            });
+        }
+        sections.sort_by_key(|s| s.start_line);
+    }
+    sections
+}
+
+/// Section kind and tag name of a line opening a top-level section.
+fn opening_tag(line: &str) -> Option<(SectionKind, &'static str)> {
+    [
+        (SectionKind::Script, "script"),
+        (SectionKind::Template, "template"),
+        (SectionKind::Style, "style"),
+    ]
+    .into_iter()
+    .find(|(_, name)| {
+        line.strip_prefix('<')
+            .and_then(|rest| rest.strip_prefix(name))
+            .is_some_and(|rest| rest.is_empty() || rest.starts_with([' ', '\t', '>']))
+    })
+}
+
+/// Name of a component: the `name` option of a Vue component, or the file
+/// stem.
+pub fn component_name(path: &Path, sections: &[Section]) -> String {
+    let declared = sections
+        .iter()
+        .filter(|s| s.kind == SectionKind::Script)
+        .find_map(|s| name_option(&s.body));
+    declared.unwrap_or_else(|| {
+        path.file_stem()
+            .and_then(|s| s.to_str())
+            .unwrap_or_default()
+            .to_string()
+    })
+}
+
+/// Openings of the objects holding a Vue component's options
+const OPTIONS_OPENINGS: &[&str] = &["export default {", "defineComponent({", "defineOptions({"];
+
+/// The `name` option of a script: a `name: '...'` key directly inside
+/// `export default {`, `defineComponent({` or `defineOptions({`.
+fn name_option(script: &str) -> Option<String> {
+    // Brace depth inside the options object, once it is open
+    let mut depth: Option<usize> = None;
+    for line in script.lines() {
+        let text = match depth {
+            Some(_) => line.trim(),
+            None => {
+                let Some(rest) = OPTIONS_OPENINGS
+                    .iter()
+                    .find_map(|open| line.find(open).map(|i| &line[i + open.len()..]))
+                else {
+                    continue;
+                };
+                depth = Some(1);
+                rest.trim()
+            }
+        };
+        if depth == Some(1) {
+            let value = text.strip_prefix("name:").map(str::trim);
+            let quote = value.and_then(|v| v.chars().next());
+            if let (Some(value), Some(quote @ ('\'' | '"' | '`'))) = (value, quote) {
+                let name = value[1..].split(quote).next().unwrap_or_default();
+                if !name.is_empty() {
+                    return Some(name.to_string());
+                }
+            }
+        }
+        let d = depth.unwrap_or_default() + text.matches('{').count();
+        depth = d.checked_sub(text.matches('}').count()).filter(|&d| d > 0);
+    }
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_vue_sections() {
+        let content = r#"<template>
+  <UserList :users="users">
+    <template #empty>No users</template>
+  </UserList>
+</template>
+
+<script setup lang="ts">
+import { ref } from 'vue'
+defineOptions({ name: 'UserDirectory' })
+const users = ref([])
+</script>
+
+<style scoped>
+.list { margin: 0 }
+</style>
+"#;
+        let path = Path::new("src/components/Users.vue");
+        let sections = split(path, content);
+        let summary: Vec<_> = sections
+            .iter()
+            .map(|s| (s.kind, s.start_line, s.end_line, s.body_start_line))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                (SectionKind::Template, 0, 4, 1),
+                (SectionKind::Script, 6, 10, 7),
+                (SectionKind::Style, 12, 14, 13),
+            ]
+        );
+
+        let script = &sections[1];
+        assert_eq!(script.tag, r#"<script setup lang="ts">"#);
+        assert_eq!(script.attribute("setup"), Some(""));
+        assert_eq!(script.attribute("lang"), Some("ts"));
+        assert_eq!(script.attribute("src"), None);
+        assert_eq!(script.script_language(), "typescript");
+        assert!(script.body.starts_with("import { ref }"));
+        assert!(sections[0].body.contains("#empty"));
+        assert_eq!(component_name(path, &sections), "UserDirectory");
+    }
+
+    #[test]
+    fn test_name_option() {
+        let script = "export default defineComponent({
+  name: 'UserCard',
+  data() {
+    return { name: 'Bob' }
+  },
+})";
+        assert_eq!(name_option(script).as_deref(), Some("UserCard"));
+
+        let script = "const user = {\n  name: 'Bob',\n}\nexport default {\n  props: ['user'],\n}";
+        assert_eq!(name_option(script), None);
+    }
+
+    #[test]
+    fn test_svelte_markup_is_the_template() {
+        let content = r#"<script context="module">
+  export const prerender = true
+</script>
+
+<script>
+  export let name
+</script>
+
+<h1>Hello {name}</h1>
+
+<p>Welcome</p>
+
+<style>
+  h1 { color: red }
+</style>
+"#;
+        let path = Path::new("Greeting.svelte");
+        let sections = split(path, content);
+        let summary: Vec<_> = sections
+            .iter()
+            .map(|s| (s.kind, s.start_line, s.end_line))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                (SectionKind::Script, 0, 2),
+                (SectionKind::Script, 4, 6),
+                (SectionKind::Template, 8, 10),
+                (SectionKind::Style, 12, 14),
+            ]
+        );
+        assert_eq!(sections[0].attribute("context"), Some("module"));
+        assert_eq!(sections[1].script_language(), "javascript");
+        assert_eq!(component_name(path, &sections), "Greeting");
+        assert!(!is_component(Path::new("main.ts")));
+    }
+}
@@ -0,0 +1,353 @@
+//! Shell script chunking by function
+//!
+//! Each function of a shell script, declared as `name() { ... }` or
+//! `function name { ... }`, becomes a [`SemanticKind::Function`] chunk named
+//! by the function, with the `#` comment lines directly above it. The code
+//! between functions, which in a deploy script is usually the part that
+//! calls them, is grouped into [`SemanticKind::Block`] chunks named by the
+//! file, split at blank lines when longer than [`MAX_GROUP_LINES`].
+//!
+//! Function bodies are delimited by matching braces outside quotes,
+//! comments and here-documents; this is a textual scan, not a full shell
+//! parse. Scripts without an extension are not walked, even with a shebang.
+
+use std::path::Path;
+
+use super::ast_chunker::SemanticKind;
+use super::Chunk;
+
+/// Language identifier assigned to shell script chunks
+pub const LANGUAGE: &str = "shell";
+
+/// Extensions of shell scripts
+pub const EXTENSIONS: &[&str] = &["sh", "bash", "zsh"];
+
+/// Longest run of top-level code kept in one chunk
+pub const MAX_GROUP_LINES: usize = 50;
+
+/// Chunk a shell script into one chunk per function, with the code around
+/// them grouped.
+///
+/// Returns `None` when `path` is not a shell script, so callers can fall
+/// back to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    let extension = path.extension().and_then(|e| e.to_str())?;
+    if !EXTENSIONS.contains(&extension) {
+        return None;
+    }
+
+    let lines: Vec<&str> = content.lines().collect();
+    let file_name = path
+        .file_name()
+        .and_then(|n| n.to_str())
+        .unwrap_or_default();
+    let mut chunks = Vec::new();
+    // First line of the top-level code since the last function
+    let mut group_start = 0;
+    let mut heredoc: Option<String> = None;
+    let mut i = 0;
+
+    while i < lines.len() {
+        if let Some(delimiter) = &heredoc {
+            if lines[i].trim() == delimiter {
+                heredoc = None;
+            }
+            i += 1;
+            continue;
+        }
+        let Some((name, after)) = function_name(lines[i]) else {
+            heredoc = heredoc_delimiter(lines[i]);
+            i += 1;
+            continue;
+        };
+
+        let start = leading_comments_start(&lines, i).max(group_start);
+        // `name() ( ... )` runs its body in a subshell
+        let opener = match after.chars().next() {
+            Some(c) => c,
+            None => lines
+                .get(i + 1)
+                .and_then(|l| l.trim_start().chars().next())
+                .unwrap_or('{'),
+        };
+        let end = body_end(&lines, i, opener == '(');
+        push_group(&mut chunks, path, &lines, file_name, group_start, start);
+        chunks.push(Chunk {
+            content: lines[start..=end].join("\n"),
+            file_path: path.to_path_buf(),
+            start_line: start + 1,
+            end_line: end + 1,
+            language: Some(LANGUAGE.to_string()),
+            semantic_kind: Some(SemanticKind::Function),
+            name: Some(name.to_string()),
+            signature: Some(signature(lines[i])),
+            doc: None,
+            parent: None,
+            qualified_name: Some(name.to_string()),
+            tags: Vec::new(),
+        });
+        group_start = end + 1;
+        i = end + 1;
+    }
+    push_group(
+        &mut chunks,
+        path,
+        &lines,
+        file_name,
+        group_start,
+        lines.len(),
+    );
+
+    Some(chunks)
+}
+
+/// Name of the function a line declares (`name() {`, `function name {` or
+/// `function name() {`) and the rest of the line from its body.
+fn function_name(line: &str) -> Option<(&str, &str)> {
+    let line = line.trim_start();
+    let (rest, keyword) = match line.strip_prefix("function") {
+        Some(rest) if rest.starts_with([' ', '\t']) => (rest.trim_start(), true),
+        _ => (line, false),
+    };
+    let len = rest
+        .find(|c: char| !(c.is_alphanumeric() || matches!(c, '_' | '-' | ':' | '.')))
+        .unwrap_or(rest.len());
+    let (name, after) = rest.split_at(len);
+    if name.is_empty() || name.starts_with(|c: char| c.is_ascii_digit()) {
+        return None;
+    }
+    let after = after.trim_start();
+    let after = match after.strip_prefix("()") {
+        Some(after) => after.trim_start(),
+        None if keyword => after,
+        None => return None,
+    };
+    // The body opens on this line, or on the next when this one is bare
+    (after.is_empty() || after.starts_with(['{', '('])).then_some((name, after))
+}
+
+/// Header of a function declaration, without its opening brace.
+fn signature(line: &str) -> String {
+    let header = line.trim();
+    let header = header.split_once('{').map_or(header, |(header, _)| header);
+    header.trim_end().to_string()
+}
+
+/// Last line of the function declared on line `start`: where the braces of
+/// its body, or parentheses of a subshell body, balance again, or the end
+/// of the file.
+fn body_end(lines: &[&str], start: usize, subshell: bool) -> usize {
+    let (open, close) = if subshell { ('(', ')') } else { ('{', '}') };
+    let mut depth = 0usize;
+    let mut opened = false;
+    let mut quote: Option<char> = None;
+    let mut heredoc: Option<String> = None;
+
+    for (i, line) in lines.iter().enumerate().skip(start) {
+        if let Some(delimiter) = &heredoc {
+            if line.trim() == delimiter {
+                heredoc = None;
+            }
+            continue;
+        }
+
+        let mut escaped = false;
+        let mut previous = ' ';
+        for c in line.chars() {
+            match quote {
+                _ if escaped => escaped = false,
+                Some('\'') if c == '\'' => quote = None,
+                Some('\'') => {}
+                _ if c == '\\' => escaped = true,
+                Some(q) if c == q => quote = None,
+                Some(_) => {}
+                None => match c {
+                    '\'' | '"' | '`' => quote = Some(c),
+                    // A comment starts a word; `$#` and `${x#y}` are not comments
+                    '#' if previous.is_whitespace() || previous == ';' => break,
+                    _ if c == open => {
+                        depth += 1;
+                        opened = true;
+                    }
+                    _ if c == close => depth = depth.saturating_sub(1),
+                    _ => {}
+                },
+            }
+            previous = c;
+        }
+        if quote.is_none() {
+            heredoc = heredoc_delimiter(line);
+        }
+        if opened && depth == 0 {
+            return i;
+        }
+    }
+    lines.len() - 1
+}
+
+/// Delimiter of a here-document a line opens: `EOF` for `cat <<EOF`,
+/// `<<-'EOF'` or `<<"EOF"`.
+fn heredoc_delimiter(line: &str) -> Option<String> {
+    let (_, rest) = line.split_once("<<")?;
+    // `<<<` is a here-string
+    if rest.starts_with('<') {
+        return None;
+    }
+    let rest = rest.strip_prefix('-').unwrap_or(rest).trim_start();
+    let delimiter: String = rest
+        .chars()
+        .take_while(|c| !c.is_whitespace() && !matches!(c, ';' | '|' | '&' | ')'))
+        .filter(|c| !matches!(c, '\'' | '"'))
+        .collect();
+    (!delimiter.is_empty()).then_some(delimiter)
+}
+
+/// Push the top-level code from `start` to `end`, split at blank lines
+/// into parts of at most [`MAX_GROUP_LINES`]. Blank runs are skipped.
+fn push_group(
+    chunks: &mut Vec<Chunk>,
+    path: &Path,
+    lines: &[&str],
+    file_name: &str,
+    start: usize,
+    end: usize,
+) {
+    let mut start = start;
+    let mut end = end;
+    while end > start && lines[end - 1].trim().is_empty() {
+        end -= 1;
+    }
+    while start < end && lines[start].trim().is_empty() {
+        start += 1;
+    }
+
+    while start < end {
+        let mut part_end = end;
+        if part_end - start > MAX_GROUP_LINES {
+            let limit = start + MAX_GROUP_LINES;
+            part_end = (start + 1..limit)
+                .rev()
+                .find(|&l| lines[l].trim().is_empty())
+                .unwrap_or(limit);
+        }
+
+        chunks.push(Chunk {
+            content: lines[start..part_end].join("\n"),
+            file_path: path.to_path_buf(),
+            start_line: start + 1,
+            end_line: part_end,
+            language: Some(LANGUAGE.to_string()),
+            semantic_kind: Some(SemanticKind::Block),
+            name: Some(file_name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            qualified_name: None,
+            tags: Vec::new(),
+        });
+
+        start = part_end;
+        while start < end && lines[start].trim().is_empty() {
+            start += 1;
+        }
+    }
+}
+
+/// First line of the `#` comment block directly above `line`, not counting
+/// a shebang.
+fn leading_comments_start(lines: &[&str], line: usize) -> usize {
+    let mut start = line;
+    while start > 0
+        && lines[start - 1].trim_start().starts_with('#')
+        && !lines[start - 1].starts_with("#!")
+    {
+        start -= 1;
+    }
+    start
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunks(content: &str) -> Vec<Chunk> {
+        chunk_file(Path::new("deploy.sh"), content).unwrap()
+    }
+
+    #[test]
+    fn test_functions_and_top_level_code() {
+        let content = r#"#!/usr/bin/env bash
+set -euo pipefail
+
+# Push the image to the registry.
+push_image() {
+  local tag="$1"
+  docker push "registry/app:${tag}" # "}" in a comment
+}
+
+function render_manifest {
+  cat <<-EOF
+	image: registry/app:$1 }
+	EOF
+}
+
+function rollback() (
+  kubectl rollout undo deployment/app
+)
+
+push_image "$TAG"
+render_manifest "$TAG" | kubectl apply -f -
+"#;
+        let chunks = chunks(content);
+        let summary: Vec<_> = chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.name.as_deref().unwrap(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                (SemanticKind::Block, "deploy.sh", 1, 2),
+                (SemanticKind::Function, "push_image", 4, 8),
+                (SemanticKind::Function, "render_manifest", 10, 14),
+                (SemanticKind::Function, "rollback", 16, 18),
+                (SemanticKind::Block, "deploy.sh", 20, 21),
+            ]
+        );
+        assert_eq!(chunks[1].signature.as_deref(), Some("push_image()"));
+        assert_eq!(
+            chunks[2].signature.as_deref(),
+            Some("function render_manifest")
+        );
+        assert!(chunk_file(Path::new("main.rs"), "f() { :; }").is_none());
+    }
+
+    #[test]
+    fn test_function_name() {
+        assert_eq!(function_name("deploy::run() {"), Some(("deploy::run", "{")));
+        assert_eq!(
+            function_name("  function build-all"),
+            Some(("build-all", ""))
+        );
+        assert_eq!(function_name("main()"), Some(("main", "")));
+        assert_eq!(function_name("echo hi"), None);
+        assert_eq!(function_name("if [ -f x ]; then"), None);
+        assert_eq!(function_name("functions=(a b)"), None);
+    }
+
+    #[test]
+    fn test_long_top_level_code_is_split() {
+        let content = "echo step\n\n".repeat(40);
+        let chunks = chunks(&content);
+        assert!(chunks.len() > 1);
+        assert!(chunks
+            .iter()
+            .all(|c| c.end_line - c.start_line < MAX_GROUP_LINES));
+    }
+}
@@ -0,0 +1,758 @@
+//! SQL file chunking
+//!
+//! Schema files and migrations are split into statements, and each
+//! definition becomes a chunk of its own:
+//!
+//! - `CREATE TABLE` and `CREATE VIEW` are [`SemanticKind::Struct`] chunks
+//!   named by the table
+//! - `CREATE FUNCTION`, `CREATE PROCEDURE` and `CREATE TRIGGER` are
+//!   [`SemanticKind::Function`] chunks
+//! - `CREATE TYPE` is an enum (`AS ENUM`) or a type alias
+//! - `ALTER TABLE` and `CREATE INDEX` are [`SemanticKind::Block`] chunks
+//!   whose parent is the table they change
+//!
+//! Other statements (`INSERT`, `UPDATE`, `GRANT`, ...) are grouped, in
+//! runs of at most [`MAX_GROUP_LINES`] lines, into a block named after the
+//! migration step. Migration tools that keep both directions in one file
+//! mark the steps with comments (`-- +goose Up`, `-- migrate:down`); a step
+//! is named `<file stem> up` or `<file stem> down`, and a file without
+//! markers is a single step named by its stem.
+//!
+//! Chunks are tagged `table:<name>` for the tables they define or touch and
+//! `db:<column>` for the columns they define, the same tag Go struct fields
+//! mapped to a column get (see [`super::struct_tags`]), so
+//! `coderag search --tag-key db --tag-value user_id` finds the table
+//! defining a column next to the structs reading it. The `--` comment lines
+//! directly above a statement are part of its chunk.
+//!
+//! Statements are split on `;` outside of quotes, comments and
+//! dollar-quoted bodies; MySQL `DELIMITER` lines and goose
+//! `StatementBegin`/`StatementEnd` blocks are honored. This is a textual
+//! split, not a full parse.
+
+use std::path::Path;
+
+use super::ast_chunker::SemanticKind;
+use super::struct_tags::struct_tag;
+use super::Chunk;
+
+/// Language identifier assigned to SQL chunks
+pub const LANGUAGE: &str = "sql";
+
+/// Extension of SQL files
+pub const EXTENSION: &str = "sql";
+
+/// Longest run of ungrouped statements merged into one block
+pub const MAX_GROUP_LINES: usize = 50;
+
+/// Words of a table definition's body that start a constraint, not a column
+const CONSTRAINT_WORDS: &[&str] = &[
+    "CONSTRAINT",
+    "PRIMARY",
+    "FOREIGN",
+    "UNIQUE",
+    "CHECK",
+    "INDEX",
+    "KEY",
+    "EXCLUDE",
+    "LIKE",
+    "FULLTEXT",
+    "SPATIAL",
+    "PERIOD",
+];
+
+/// Words between `CREATE` and the kind of object it creates
+const CREATE_MODIFIERS: &[&str] = &[
+    "OR",
+    "REPLACE",
+    "TEMP",
+    "TEMPORARY",
+    "UNLOGGED",
+    "GLOBAL",
+    "LOCAL",
+    "MATERIALIZED",
+    "RECURSIVE",
+    "UNIQUE",
+    "CONSTRAINT",
+    "VIRTUAL",
+];
+
+/// Chunk a SQL file into one chunk per definition and per group of other
+/// statements.
+///
+/// Returns `None` when `path` is not a SQL file, so callers can fall back
+/// to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    if path.extension().and_then(|e| e.to_str()) != Some(EXTENSION) {
+        return None;
+    }
+
+    let stem = path
+        .file_stem()
+        .and_then(|s| s.to_str())
+        .unwrap_or_default();
+    let lines: Vec<&str> = content.lines().collect();
+    let line_of = |byte: usize| content[..byte].matches('\n').count();
+
+    let mut chunks = Vec::new();
+    let mut group: Option<Group> = None;
+
+    for statement in split_statements(content) {
+        let start = leading_comments_start(&lines, line_of(statement.start));
+        let end = line_of(statement.end.saturating_sub(1).max(statement.start));
+        let step = match statement.step {
+            Some(step) => format!("{} {}", stem, step),
+            None => stem.to_string(),
+        };
+        let parsed = parse_statement(&content[statement.start..statement.end]);
+
+        let Some(kind) = parsed.kind else {
+            // Extend the current group, unless it is from another step or
+            // would grow too long
+            if let Some(current) = group.as_mut() {
+                if current.step == step && end + 1 - current.start <= MAX_GROUP_LINES {
+                    current.end = end;
+                    current.tables.extend(parsed.tables);
+                    continue;
+                }
+            }
+            if let Some(done) = group.take() {
+                chunks.push(done.into_chunk(path, &lines));
+            }
+            group = Some(Group {
+                step,
+                start,
+                end,
+                signature: first_line(&content[statement.start..statement.end]),
+                tables: parsed.tables,
+            });
+            continue;
+        };
+
+        if let Some(done) = group.take() {
+            chunks.push(done.into_chunk(path, &lines));
+        }
+        let mut tags: Vec<String> = Vec::new();
+        for table in &parsed.tables {
+            push_unique(&mut tags, struct_tag("table", table));
+        }
+        for column in &parsed.columns {
+            push_unique(&mut tags, struct_tag("db", column));
+        }
+        chunks.push(Chunk {
+            content: lines[start..=end].join("\n"),
+            file_path: path.to_path_buf(),
+            start_line: start + 1,
+            end_line: end + 1,
+            language: Some(LANGUAGE.to_string()),
+            semantic_kind: Some(kind),
+            name: parsed.name.as_deref().map(unqualified).map(str::to_string),
+            signature: Some(first_line(&content[statement.start..statement.end])),
+            doc: None,
+            parent: parsed.parent,
+            qualified_name: parsed.name,
+            tags,
+        });
+    }
+    if let Some(done) = group.take() {
+        chunks.push(done.into_chunk(path, &lines));
+    }
+
+    Some(chunks)
+}
+
+/// Consecutive statements without a chunk of their own
+struct Group {
+    /// Migration step the statements belong to
+    step: String,
+    /// First and last line, 0-based
+    start: usize,
+    end: usize,
+    /// First line of the first statement
+    signature: String,
+    /// Tables the statements touch
+    tables: Vec<String>,
+}
+
+impl Group {
+    fn into_chunk(self, path: &Path, lines: &[&str]) -> Chunk {
+        let mut tags = Vec::new();
+        for table in &self.tables {
+            push_unique(&mut tags, struct_tag("table", table));
+        }
+        Chunk {
+            content: lines[self.start..=self.end].join("\n"),
+            file_path: path.to_path_buf(),
+            start_line: self.start + 1,
+            end_line: self.end + 1,
+            language: Some(LANGUAGE.to_string()),
+            semantic_kind: Some(SemanticKind::Block),
+            name: Some(self.step),
+            signature: Some(self.signature),
+            doc: None,
+            parent: None,
+            qualified_name: None,
+            tags,
+        }
+    }
+}
+
+/// A statement's byte range, without leading whitespace and comments but
+/// with its delimiter
+#[derive(Debug)]
+struct Statement {
+    start: usize,
+    end: usize,
+    /// Migration step (`up` or `down`) the statement is in, if the file
+    /// marks steps
+    step: Option<&'static str>,
+}
+
+/// Split SQL text into statements.
+fn split_statements(content: &str) -> Vec<Statement> {
+    let bytes = content.as_bytes();
+    let mut statements = Vec::new();
+    let mut delimiter = ";".to_string();
+    let mut step = None;
+    // Inside a goose StatementBegin/StatementEnd block
+    let mut verbatim = false;
+    let mut start: Option<usize> = None;
+    let mut i = 0;
+
+    while i < bytes.len() {
+        let rest = &content[i..];
+        let at_line_start = i == 0 || bytes[i - 1] == b'\n';
+
+        // A `DELIMITER //` line changes the delimiter (MySQL clients)
+        if at_line_start && start.is_none() {
+            let line = rest.lines().next().unwrap_or_default();
+            if let Some(new) = strip_keyword(line.trim(), "DELIMITER") {
+                delimiter = new.trim().to_string();
+                i += line.len();
+                continue;
+            }
+        }
+
+        if rest.starts_with("--") {
+            let line = rest.lines().next().unwrap_or_default();
+            let comment = line[2..].trim();
+            if let Some(marker) = step_marker(comment) {
+                step = Some(marker);
+            } else if comment.eq_ignore_ascii_case("+goose StatementBegin") {
+                verbatim = true;
+            } else if comment.eq_ignore_ascii_case("+goose StatementEnd") {
+                verbatim = false;
+                if let Some(begin) = start.take() {
+                    statements.push(Statement {
+                        start: begin,
+                        end: trimmed_end(content, i),
+                        step,
+                    });
+                }
+            }
+            i += line.len();
+            continue;
+        }
+        if rest.starts_with("/*") {
+            i += rest.find("*/").map_or(rest.len(), |end| end + 2);
+            continue;
+        }
+
+        let c = bytes[i];
+        if c.is_ascii_whitespace() {
+            i += 1;
+            continue;
+        }
+        start.get_or_insert(i);
+
+        if let Some(len) = quoted_len(rest) {
+            i += len;
+        } else if !verbatim && rest.starts_with(delimiter.as_str()) {
+            i += delimiter.len();
+            statements.push(Statement {
+                start: start.take().unwrap_or(i),
+                end: i,
+                step,
+            });
+        } else {
+            i += rest.chars().next().map_or(1, char::len_utf8);
+        }
+    }
+    if let Some(begin) = start {
+        statements.push(Statement {
+            start: begin,
+            end: trimmed_end(content, content.len()),
+            step,
+        });
+    }
+    statements
+}
+
+/// Length of the quoted string, quoted identifier or dollar-quoted body at
+/// the start of `text`, if it starts with one.
+fn quoted_len(text: &str) -> Option<usize> {
+    let quote = text.chars().next()?;
+    match quote {
+        // A doubled quote inside ('it''s') closes and reopens the string,
+        // which scans the same
+        '\'' | '"' | '`' => {
+            let close = text[1..].find(quote).map_or(text.len(), |end| end + 2);
+            Some(close)
+        }
+        '$' => {
+            // `$$` or `$tag$`, but not a parameter like `$1`
+            let tag_end = text[1..].find('$')? + 2;
+            let tag = &text[..tag_end];
+            if !tag[1..tag_end - 1]
+                .chars()
+                .all(|c| c.is_alphanumeric() || c == '_')
+                || tag[1..].starts_with(|c: char| c.is_ascii_digit())
+            {
+                return None;
+            }
+            let close = text[tag_end..]
+                .find(tag)
+                .map_or(text.len(), |end| tag_end + end + tag.len());
+            Some(close)
+        }
+        _ => None,
+    }
+}
+
+/// Migration step named by a comment: `+goose Up`, `migrate:down`,
+/// `+migrate Up`.
+fn step_marker(comment: &str) -> Option<&'static str> {
+    match comment.to_lowercase().as_str() {
+        "+goose up" | "migrate:up" | "+migrate up" => Some("up"),
+        "+goose down" | "migrate:down" | "+migrate down" => Some("down"),
+        _ => None,
+    }
+}
+
+/// First line of the `--` comment block directly above `line`; a blank
+/// line or a migration marker ends the block.
+fn leading_comments_start(lines: &[&str], line: usize) -> usize {
+    let mut start = line;
+    while start > 0 {
+        let above = lines[start - 1].trim();
+        let Some(comment) = above.strip_prefix("--") else {
+            break;
+        };
+        let comment = comment.trim();
+        if step_marker(comment).is_some() || comment.to_lowercase().starts_with("+goose") {
+            break;
+        }
+        start -= 1;
+    }
+    start
+}
+
+/// End of the text before `end`, without trailing whitespace.
+fn trimmed_end(content: &str, end: usize) -> usize {
+    content[..end].trim_end().len()
+}
+
+/// `text` after a leading keyword, matched case-insensitively.
+fn strip_keyword<'a>(text: &'a str, keyword: &str) -> Option<&'a str> {
+    let head = text.get(..keyword.len())?;
+    let rest = &text[keyword.len()..];
+    (head.eq_ignore_ascii_case(keyword) && rest.starts_with(char::is_whitespace)).then_some(rest)
+}
+
+/// First line of a statement, without a trailing `(` or delimiter.
+fn first_line(statement: &str) -> String {
+    let line = statement.lines().next().unwrap_or_default().trim();
+    line.trim_end_matches(['(', ';']).trim_end().to_string()
+}
+
+/// Last part of a schema-qualified name: `users` for `public.users`.
+fn unqualified(name: &str) -> &str {
+    name.rsplit('.').next().unwrap_or(name)
+}
+
+fn push_unique(tags: &mut Vec<String>, tag: String) {
+    if !tags.contains(&tag) {
+        tags.push(tag);
+    }
+}
+
+/// What a statement defines or touches
+#[derive(Debug, Default, PartialEq)]
+struct Parsed {
+    /// Kind of the chunk, `None` for statements that are grouped
+    kind: Option<SemanticKind>,
+    /// Name of the defined object, schema-qualified as written
+    name: Option<String>,
+    /// Table an `ALTER TABLE` or `CREATE INDEX` changes
+    parent: Option<String>,
+    /// Tables defined or touched
+    tables: Vec<String>,
+    /// Columns defined
+    columns: Vec<String>,
+}
+
+/// Classify a statement by its leading keywords.
+fn parse_statement(statement: &str) -> Parsed {
+    let tokens = tokenize(statement);
+    let upper: Vec<String> = tokens.iter().map(|t| t.to_uppercase()).collect();
+    let word = |i: usize| upper.get(i).map(String::as_str).unwrap_or_default();
+
+    match word(0) {
+        "CREATE" => {
+            let mut i = 1;
+            while CREATE_MODIFIERS.contains(&word(i)) {
+                i += 1;
+            }
+            let object = word(i);
+            i += 1;
+            if word(i) == "IF" && word(i + 1) == "NOT" && word(i + 2) == "EXISTS" {
+                i += 3;
+            }
+            match object {
+                "TABLE" | "VIEW" => {
+                    let Some(name) = tokens.get(i).map(|t| unquote(t)) else {
+                        return Parsed::default();
+                    };
+                    let columns = if object == "TABLE" && tokens.get(i + 1) == Some(&"(") {
+                        table_columns(&tokens[i + 2..])
+                    } else {
+                        Vec::new()
+                    };
+                    Parsed {
+                        kind: Some(SemanticKind::Struct),
+                        tables: vec![name.clone()],
+                        name: Some(name),
+                        columns,
+                        ..Parsed::default()
+                    }
+                }
+                "FUNCTION" | "PROCEDURE" | "TRIGGER" => {
+                    let name = tokens.get(i).map(|t| unquote(t));
+                    // A trigger's table follows `ON`
+                    let table = upper[i..]
+                        .iter()
+                        .position(|w| w == "ON")
+                        .filter(|_| object == "TRIGGER")
+                        .and_then(|on| tokens.get(i + on + 1))
+                        .map(|t| unquote(t));
+                    Parsed {
+                        kind: Some(SemanticKind::Function),
+                        name,
+                        tables: table.clone().into_iter().collect(),
+                        parent: table,
+                        ..Parsed::default()
+                    }
+                }
+                "TYPE" => {
+                    let is_enum = word(i + 1) == "AS" && word(i + 2) == "ENUM";
+                    Parsed {
+                        kind: Some(if is_enum {
+                            SemanticKind::Enum
+                        } else {
+                            SemanticKind::TypeAlias
+                        }),
+                        name: tokens.get(i).map(|t| unquote(t)),
+                        ..Parsed::default()
+                    }
+                }
+                "INDEX" => {
+                    let on = upper[i..].iter().position(|w| w == "ON").map(|on| i + on);
+                    let name = on.filter(|&on| on > i).map(|_| unquote(tokens[i]));
+                    let table = on.and_then(|on| {
+                        let skip = usize::from(word(on + 1) == "ONLY");
+                        tokens.get(on + 1 + skip).map(|t| unquote(t))
+                    });
+                    Parsed {
+                        kind: Some(SemanticKind::Block),
+                        name,
+                        tables: table.clone().into_iter().collect(),
+                        parent: table,
+                        ..Parsed::default()
+                    }
+                }
+                _ => Parsed::default(),
+            }
+        }
+        "ALTER" if word(1) == "TABLE" => {
+            let mut i = 2;
+            if word(i) == "IF" && word(i + 1) == "EXISTS" {
+                i += 2;
+            }
+            if word(i) == "ONLY" {
+                i += 1;
+            }
+            let table = tokens.get(i).map(|t| unquote(t));
+            // `ADD [COLUMN] [IF NOT EXISTS] name`, but not `ADD CONSTRAINT`
+            let mut columns = Vec::new();
+            for add in (i..upper.len()).filter(|&j| upper[j] == "ADD") {
+                let mut j = add + 1;
+                if word(j) == "COLUMN" {
+                    j += 1;
+                }
+                if word(j) == "IF" && word(j + 1) == "NOT" && word(j + 2) == "EXISTS" {
+                    j += 3;
+                }
+                if let Some(column) = tokens
+                    .get(j)
+                    .filter(|_| !CONSTRAINT_WORDS.contains(&word(j)))
+                {
+                    columns.push(unquote(column));
+                }
+            }
+            Parsed {
+                kind: Some(SemanticKind::Block),
+                tables: table.clone().into_iter().collect(),
+                parent: table,
+                columns,
+                ..Parsed::default()
+            }
+        }
+        // Grouped statements still record the tables they touch
+        _ => {
+            let table = match (word(0), word(1)) {
+                ("INSERT", "INTO") | ("DELETE", "FROM") | ("TRUNCATE", "TABLE") => tokens.get(2),
+                ("UPDATE", _) | ("TRUNCATE", _) => tokens.get(1),
+                ("DROP", "TABLE") if word(2) == "IF" => tokens.get(4),
+                ("DROP", "TABLE") => tokens.get(2),
+                _ => None,
+            };
+            Parsed {
+                tables: table.map(|t| unquote(t)).into_iter().collect(),
+                ..Parsed::default()
+            }
+        }
+    }
+}
+
+/// Column names of a table body, given the tokens after its `(`.
+fn table_columns(tokens: &[&str]) -> Vec<String> {
+    let mut columns = Vec::new();
+    let mut depth = 0;
+    let mut item_start = true;
+    for token in tokens {
+        match *token {
+            "(" => depth += 1,
+            ")" if depth == 0 => break,
+            ")" => depth -= 1,
+            "," if depth == 0 => {
+                item_start = true;
+                continue;
+            }
+            _ if item_start && !CONSTRAINT_WORDS.contains(&token.to_uppercase().as_str()) => {
+                columns.push(unquote(token));
+            }
+            _ => {}
+        }
+        item_start = false;
+    }
+    columns
+}
+
+/// Words, quoted identifiers and the punctuation `(`, `)` and `,` of a
+/// statement, skipping comments and string literals.
+fn tokenize(statement: &str) -> Vec<&str> {
+    let mut tokens = Vec::new();
+    let mut i = 0;
+    while i < statement.len() {
+        let rest = &statement[i..];
+        let c = rest.chars().next().unwrap_or(' ');
+        if rest.starts_with("--") {
+            i += rest.find('\n').unwrap_or(rest.len());
+        } else if rest.starts_with("/*") {
+            i += rest.find("*/").map_or(rest.len(), |end| end + 2);
+        } else if matches!(c, '"' | '`') {
+            let len = quoted_len(rest).unwrap_or(rest.len());
+            tokens.push(&rest[..len]);
+            i += len;
+        } else if c == '\'' || c == '$' {
+            // Literals and bodies are not names
+            i += quoted_len(rest).unwrap_or(1);
+        } else if c == '[' {
+            let len = rest.find(']').map_or(rest.len(), |end| end + 1);
+            tokens.push(&rest[..len]);
+            i += len;
+        } else if matches!(c, '(' | ')' | ',') {
+            tokens.push(&rest[..1]);
+            i += 1;
+        } else if c.is_alphanumeric() || c == '_' {
+            let len = rest
+                .find(|c: char| !(c.is_alphanumeric() || matches!(c, '_' | '.' | '"' | '`')))
+                .unwrap_or(rest.len());
+            tokens.push(&rest[..len]);
+            i += len;
+        } else {
+            i += c.len_utf8();
+        }
+    }
+    tokens
+}
+
+/// Identifier without quotes: `"Users"`, `` `users` `` and `[users]` are
+/// `Users`, `users` and `users`; quotes around each part of a qualified
+/// name are removed too.
+fn unquote(identifier: &str) -> String {
+    identifier
+        .split('.')
+        .map(|part| part.trim_matches(['"', '`', '[', ']']))
+        .collect::<Vec<_>>()
+        .join(".")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunks(path: &str, content: &str) -> Vec<Chunk> {
+        chunk_file(Path::new(path), content).unwrap()
+    }
+
+    #[test]
+    fn test_tables_with_columns_and_comments() {
+        let content = r#"-- Schema
+
+-- Registered users.
+-- Emails are unique.
+CREATE TABLE IF NOT EXISTS public.users (
+    id bigserial PRIMARY KEY,
+    "Email" text NOT NULL,
+    created_at timestamptz DEFAULT now(),
+    CONSTRAINT users_email_key UNIQUE ("Email")
+);
+
+CREATE INDEX users_created_at_idx ON users (created_at);
+
+ALTER TABLE users ADD COLUMN last_login timestamptz, ADD CONSTRAINT x CHECK (id > 0);
+"#;
+        let chunks = chunks("schema.sql", content);
+        assert_eq!(chunks.len(), 3);
+
+        let users = &chunks[0];
+        assert_eq!(users.semantic_kind, Some(SemanticKind::Struct));
+        assert_eq!(users.name.as_deref(), Some("users"));
+        assert_eq!(users.qualified_name.as_deref(), Some("public.users"));
+        assert_eq!(
+            users.signature.as_deref(),
+            Some("CREATE TABLE IF NOT EXISTS public.users")
+        );
+        // The heading is separated by a blank line
+        assert_eq!((users.start_line, users.end_line), (3, 10));
+        assert_eq!(
+            users.tags,
+            vec!["table:public.users", "db:id", "db:email", "db:created_at"]
+        );
+
+        let index = &chunks[1];
+        assert_eq!(index.semantic_kind, Some(SemanticKind::Block));
+        assert_eq!(index.name.as_deref(), Some("users_created_at_idx"));
+        assert_eq!(index.parent.as_deref(), Some("users"));
+
+        let alter = &chunks[2];
+        assert_eq!(alter.parent.as_deref(), Some("users"));
+        assert_eq!(alter.tags, vec!["table:users", "db:last_login"]);
+    }
+
+    #[test]
+    fn test_functions_keep_their_bodies() {
+        let content = r#"CREATE OR REPLACE FUNCTION touch_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now(); -- not the end of the statement
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_touch BEFORE UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+CREATE TYPE invoice_state AS ENUM ('open', 'paid');
+
+DELIMITER //
+CREATE PROCEDURE archive_invoices()
+BEGIN
+    DELETE FROM invoices WHERE paid;
+END //
+DELIMITER ;
+"#;
+        let chunks = chunks("functions.sql", content);
+        let summary: Vec<_> = chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.name.as_deref().unwrap(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            summary,
+            vec![
+                (SemanticKind::Function, "touch_updated_at", 1, 6),
+                (SemanticKind::Function, "users_touch", 8, 9),
+                (SemanticKind::Enum, "invoice_state", 11, 11),
+                (SemanticKind::Function, "archive_invoices", 14, 17),
+            ]
+        );
+        assert_eq!(chunks[1].parent.as_deref(), Some("users"));
+    }
+
+    #[test]
+    fn test_migration_steps_group_other_statements() {
+        let content = r#"-- +goose Up
+CREATE TABLE plans (id int, name text);
+INSERT INTO plans VALUES (1, 'free');
+INSERT INTO plans VALUES (2, 'pro; annual');
+UPDATE accounts SET plan_id = 1;
+
+-- +goose StatementBegin
+CREATE FUNCTION plan_name(id int) RETURNS text AS 'SELECT name FROM plans WHERE id = $1; ' LANGUAGE sql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS plans;
+"#;
+        let chunks = chunks("20240101_plans.sql", content);
+        let summary: Vec<_> = chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.name.as_deref().unwrap(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            summary,
+            vec![
+                (SemanticKind::Struct, "plans", 2, 2),
+                (SemanticKind::Block, "20240101_plans up", 3, 5),
+                (SemanticKind::Function, "plan_name", 8, 8),
+                (SemanticKind::Block, "20240101_plans down", 12, 12),
+            ]
+        );
+        assert_eq!(chunks[0].tags, vec!["table:plans", "db:id", "db:name"]);
+        assert_eq!(chunks[1].tags, vec!["table:plans", "table:accounts"]);
+        assert_eq!(
+            chunks[1].signature.as_deref(),
+            Some("INSERT INTO plans VALUES (1, 'free')")
+        );
+        assert_eq!(chunks[3].tags, vec!["table:plans"]);
+    }
+
+    #[test]
+    fn test_long_runs_are_split() {
+        let content: String = (0..120)
+            .map(|i| format!("INSERT INTO events VALUES ({});\n", i))
+            .collect();
+        let chunks = chunks("seed.sql", &content);
+        let ranges: Vec<_> = chunks.iter().map(|c| (c.start_line, c.end_line)).collect();
+
+        assert_eq!(ranges, vec![(1, 50), (51, 100), (101, 120)]);
+        assert!(chunks.iter().all(|c| c.name.as_deref() == Some("seed")));
+        assert!(chunk_file(Path::new("main.rs"), "SELECT 1;").is_none());
+    }
+}
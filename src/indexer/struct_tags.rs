@@ -0,0 +1,198 @@
+//! Go struct field tags
+//!
+//! Fields of Go structs name their serialized form in tags such as
+//! `` ID int64 `json:"id" db:"user_id"` ``. Each Go struct chunk is tagged
+//! `<key>:<name>` for every field tag it contains (`json:id`, `db:user_id`),
+//! so `coderag search --tag-key db --tag-value user_id` finds the struct
+//! whose field maps to the `user_id` column.
+//!
+//! The name is the tag value up to the first comma (`json:"email,omitempty"`
+//! is `email`); for `gorm` it is the `column:` setting. Fields excluded from
+//! serialization (`json:"-"`) and values without a name are skipped.
+//!
+//! Like the other analysis tags this is a textual match on field lines, not
+//! a full parse.
+
+/// A tag on a struct field
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct StructTag {
+    /// Field name; for embedded fields, the type name
+    pub field: String,
+    /// Tag key (`json`, `db`, ...)
+    pub key: String,
+    /// Tag value as written, with options (`email,omitempty`)
+    pub value: String,
+}
+
+impl StructTag {
+    /// Serialized name of the field under this key, if any
+    pub fn name(&self) -> Option<&str> {
+        let name = if self.key == "gorm" {
+            self.value
+                .split(';')
+                .find_map(|setting| setting.trim().strip_prefix("column:"))?
+        } else {
+            self.value.split(',').next().unwrap_or_default()
+        };
+        let name = name.trim();
+        (!name.is_empty() && name != "-").then_some(name)
+    }
+}
+
+/// Chunk tag recording that a field is serialized as `name` under `key`.
+///
+/// Tags are matched case-insensitively, so both parts are lowercased.
+pub fn struct_tag(key: &str, name: &str) -> String {
+    format!(
+        "{}:{}",
+        key.trim().to_lowercase(),
+        name.trim().to_lowercase()
+    )
+}
+
+/// Field tags in a piece of Go code, in order
+pub fn struct_tags(code: &str) -> Vec<StructTag> {
+    let mut tags = Vec::new();
+    for line in code.lines() {
+        let Some((field, raw)) = field_line(line) else {
+            continue;
+        };
+        for (key, value) in parse_tag(raw) {
+            tags.push(StructTag {
+                field: field.clone(),
+                key,
+                value,
+            });
+        }
+    }
+    tags
+}
+
+/// Chunk tags for the named field tags in `code`, without duplicates
+pub fn struct_tag_labels(code: &str) -> Vec<String> {
+    let mut labels: Vec<String> = Vec::new();
+    for tag in struct_tags(code) {
+        if let Some(name) = tag.name() {
+            let label = struct_tag(&tag.key, name);
+            if !labels.contains(&label) {
+                labels.push(label);
+            }
+        }
+    }
+    labels
+}
+
+/// Field name and raw tag of a struct field line such as
+/// `` ID int64 `json:"id"` // comment ``
+fn field_line(line: &str) -> Option<(String, &str)> {
+    let line = line.trim();
+    let (before, after) = line.rsplit_once('`')?;
+    let after = after.trim();
+    if !after.is_empty() && !after.starts_with("//") {
+        return None;
+    }
+    let (declaration, raw) = before.rsplit_once('`')?;
+
+    // Fields are `Name Type`, `A, B Type` or an embedded `*pkg.Type`
+    let tokens: Vec<&str> = declaration.split_whitespace().collect();
+    let first = tokens.first()?;
+    if declaration.contains('=') || matches!(*first, "return" | "case" | "var" | "const") {
+        return None;
+    }
+    let field = if tokens.len() == 1 {
+        first.trim_start_matches('*').rsplit('.').next()?
+    } else {
+        first.trim_end_matches(',')
+    };
+    if !field.chars().all(|c| c.is_alphanumeric() || c == '_') {
+        return None;
+    }
+    Some((field.to_string(), raw))
+}
+
+/// Key/value pairs of a raw tag, following Go's `key:"value" key:"value"`
+/// convention; parsing stops at the first malformed pair.
+fn parse_tag(raw: &str) -> Vec<(String, String)> {
+    let mut pairs = Vec::new();
+    let mut rest = raw;
+
+    loop {
+        rest = rest.trim_start();
+        let Some(colon) = rest.find(":\"") else {
+            break;
+        };
+        let key = &rest[..colon];
+        if key.is_empty() || key.contains(|c: char| c.is_whitespace() || c == '"') {
+            break;
+        }
+
+        let bytes = rest.as_bytes();
+        let start = colon + 2;
+        let mut end = start;
+        while end < bytes.len() && bytes[end] != b'"' {
+            if bytes[end] == b'\\' {
+                end += 1;
+            }
+            end += 1;
+        }
+        if end >= bytes.len() {
+            break;
+        }
+
+        pairs.push((key.to_string(), rest[start..end].replace("\\\"", "\"")));
+        rest = &rest[end + 1..];
+    }
+
+    pairs
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_multiple_keys_per_field() {
+        let tags =
+            struct_tags(r#"	ID int64 `json:"id" db:"user_id" gorm:"column:user_id;primaryKey"`"#);
+        assert_eq!(tags.len(), 3);
+        assert!(tags.iter().all(|t| t.field == "ID"));
+        assert_eq!(tags[0].key, "json");
+        assert_eq!(tags[2].value, "column:user_id;primaryKey");
+        assert_eq!(
+            tags.iter().map(|t| t.name()).collect::<Vec<_>>(),
+            vec![Some("id"), Some("user_id"), Some("user_id")]
+        );
+    }
+
+    #[test]
+    fn test_tag_names() {
+        let tag = |key: &str, value: &str| StructTag {
+            field: "F".to_string(),
+            key: key.to_string(),
+            value: value.to_string(),
+        };
+        assert_eq!(tag("json", "email,omitempty").name(), Some("email"));
+        assert_eq!(tag("json", "-").name(), None);
+        assert_eq!(tag("json", ",omitempty").name(), None);
+        assert_eq!(tag("gorm", "primaryKey").name(), None);
+        assert_eq!(struct_tag("JSON", "userId"), "json:userid");
+    }
+
+    #[test]
+    fn test_fixture_labels() {
+        let fixture = include_str!("../../tests/fixtures/struct_tags/models.go");
+        let labels = struct_tag_labels(fixture);
+
+        for expected in ["json:id", "db:user_id", "gorm:user_id", "db:password_hash"] {
+            assert!(labels.contains(&expected.to_string()), "{:?}", labels);
+        }
+        assert!(labels.contains(&"yaml:worker_count".to_string()));
+        // Skipped names and raw strings outside of field declarations
+        assert!(!labels
+            .iter()
+            .any(|l| l == "json:-" || l.contains("not_a_field")));
+
+        let embedded = struct_tags("\t*audit.Audit `json:\"audit\"`");
+        assert_eq!(embedded[0].field, "Audit");
+    }
+}
@@ -0,0 +1,237 @@
+//! LLM-written chunk summaries
+//!
+//! With `indexer.summaries` enabled, each chunk of at least `min_lines`
+//! lines is sent to a chat model behind an OpenAI-compatible API, which
+//! describes in one paragraph what the code does. The summaries are embedded
+//! as the `summary` field (see [`super::field_vectors`]), searched with
+//! `coderag search --field summary` or fused into vector search by
+//! `search.summary_weight`. They help queries that describe intent rather
+//! than name identifiers.
+//!
+//! Identical chunks in a batch are summarized once. A chunk whose request
+//! fails is indexed without a summary.
+
+use std::collections::HashMap;
+
+use anyhow::{Context, Result};
+use async_openai::config::OpenAIConfig;
+use async_openai::types::{
+    ChatCompletionRequestSystemMessageArgs, ChatCompletionRequestUserMessageArgs,
+    CreateChatCompletionRequestArgs,
+};
+use async_openai::Client;
+use futures::stream::{self, StreamExt};
+use tracing::{debug, warn};
+
+use super::field_vectors::embed_texts;
+use crate::config::SummaryConfig;
+use crate::embeddings::EmbeddingGenerator;
+use crate::storage::{content_hash, FieldVector, IndexedChunk, VectorField};
+
+/// Instructions sent with every chunk
+const SYSTEM_PROMPT: &str = "You summarize source code for a code search index. \
+Describe in one short paragraph of plain prose what the code does and what \
+someone looking for it would be trying to do. Name the domain concepts it \
+handles. Do not repeat the code, list parameters or use Markdown.";
+
+/// Characters of a chunk sent to the model; the rest is cut off
+const MAX_PROMPT_CHARS: usize = 12_000;
+
+/// Writes chunk summaries with the configured chat model
+pub struct Summarizer {
+    client: Client<OpenAIConfig>,
+    config: SummaryConfig,
+}
+
+impl Summarizer {
+    /// Create a summarizer for the configured API.
+    ///
+    /// Fails in offline mode, and without an API key unless `base_url` is
+    /// set, since local servers usually need none.
+    pub fn new(config: &SummaryConfig) -> Result<Self> {
+        crate::offline::ensure_network_allowed("summaries", &config.model)?;
+
+        let api_key = match config.load_api_key() {
+            Ok(key) => key,
+            Err(_) if config.base_url.is_some() => String::new(),
+            Err(e) => return Err(e),
+        };
+        let mut api = OpenAIConfig::new().with_api_key(api_key);
+        if let Some(base_url) = &config.base_url {
+            api = api.with_api_base(base_url);
+        }
+
+        Ok(Self {
+            client: Client::with_config(api),
+            config: config.clone(),
+        })
+    }
+
+    /// Summarize `chunks` and embed the summaries as `summary` field
+    /// vectors.
+    ///
+    /// Chunks need their final ids, since the vectors refer to them.
+    pub async fn embed(
+        &self,
+        embedder: &EmbeddingGenerator,
+        chunks: &[IndexedChunk],
+        batch_size: usize,
+    ) -> Result<Vec<FieldVector>> {
+        let summaries = self.summarize_all(chunks).await;
+        let entries: Vec<(&IndexedChunk, String)> = chunks
+            .iter()
+            .filter_map(|chunk| {
+                let hash = content_hash(&chunk.content, chunk.language.as_deref());
+                summaries.get(&hash).map(|summary| (chunk, summary.clone()))
+            })
+            .collect();
+        debug!("Summarized {} of {} chunks", entries.len(), chunks.len());
+
+        embed_texts(embedder, VectorField::Summary, &entries, batch_size).await
+    }
+
+    /// Summaries of the chunks long enough to summarize, keyed by
+    /// [`content_hash`]
+    async fn summarize_all(&self, chunks: &[IndexedChunk]) -> HashMap<u64, String> {
+        let mut distinct: HashMap<u64, &IndexedChunk> = HashMap::new();
+        for chunk in chunks
+            .iter()
+            .filter(|c| c.content.lines().count() >= self.config.min_lines)
+        {
+            distinct
+                .entry(content_hash(&chunk.content, chunk.language.as_deref()))
+                .or_insert(chunk);
+        }
+
+        stream::iter(distinct)
+            .map(|(hash, chunk)| async move {
+                match self.summarize(chunk).await {
+                    Ok(summary) => summary.map(|s| (hash, s)),
+                    Err(e) => {
+                        warn!(
+                            "Failed to summarize {}:{}: {:#}",
+                            chunk.file_path, chunk.start_line, e
+                        );
+                        None
+                    }
+                }
+            })
+            .buffer_unordered(self.config.concurrency.max(1))
+            .filter_map(|summary| async move { summary })
+            .collect()
+            .await
+    }
+
+    /// Ask the model for the summary of one chunk
+    async fn summarize(&self, chunk: &IndexedChunk) -> Result<Option<String>> {
+        let request = CreateChatCompletionRequestArgs::default()
+            .model(&self.config.model)
+            .max_tokens(self.config.max_tokens)
+            .temperature(0.0)
+            .messages([
+                ChatCompletionRequestSystemMessageArgs::default()
+                    .content(SYSTEM_PROMPT)
+                    .build()?
+                    .into(),
+                ChatCompletionRequestUserMessageArgs::default()
+                    .content(prompt(chunk))
+                    .build()?
+                    .into(),
+            ])
+            .build()?;
+
+        let response = self
+            .client
+            .chat()
+            .create(request)
+            .await
+            .context("Summary request failed")?;
+        Ok(response
+            .choices
+            .into_iter()
+            .next()
+            .and_then(|choice| choice.message.content)
+            .and_then(|reply| clean_summary(&reply)))
+    }
+}
+
+/// Message asking for the summary of `chunk`: its location and symbol,
+/// then its code
+fn prompt(chunk: &IndexedChunk) -> String {
+    let mut prompt = format!("File: {}\n", chunk.file_path);
+    if let Some(language) = &chunk.language {
+        prompt.push_str(&format!("Language: {}\n", language));
+    }
+    if let Some(name) = chunk.qualified_name.as_ref().or(chunk.symbol_name.as_ref()) {
+        match &chunk.semantic_kind {
+            Some(kind) => prompt.push_str(&format!("Symbol: {} {}\n", kind, name)),
+            None => prompt.push_str(&format!("Symbol: {}\n", name)),
+        }
+    }
+
+    let code = match chunk.content.char_indices().nth(MAX_PROMPT_CHARS) {
+        Some((end, _)) => &chunk.content[..end],
+        None => &chunk.content,
+    };
+    prompt.push('\n');
+    prompt.push_str(code);
+    prompt
+}
+
+/// The model's reply as a single paragraph; `None` when it is empty
+fn clean_summary(reply: &str) -> Option<String> {
+    let summary = reply.split_whitespace().collect::<Vec<_>>().join(" ");
+    (!summary.is_empty()).then_some(summary)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunk(content: &str) -> IndexedChunk {
+        IndexedChunk {
+            id: "c1".to_string(),
+            content: content.to_string(),
+            file_path: "billing/retry.go".to_string(),
+            start_line: 10,
+            end_line: 10 + content.lines().count(),
+            language: Some("go".to_string()),
+            vector: Vec::new(),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some("RetryCharge".to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: Some("billing.RetryCharge".to_string()),
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    #[test]
+    fn test_prompt_describes_chunk() {
+        let prompt = prompt(&chunk("func RetryCharge() {\n\tsubmit()\n}"));
+        assert_eq!(
+            prompt,
+            "File: billing/retry.go\nLanguage: go\nSymbol: function billing.RetryCharge\n\n\
+             func RetryCharge() {\n\tsubmit()\n}"
+        );
+
+        let long = chunk(&"é".repeat(MAX_PROMPT_CHARS + 10));
+        let code = prompt(&long).split("\n\n").nth(1).unwrap().to_string();
+        assert_eq!(code.chars().count(), MAX_PROMPT_CHARS);
+    }
+
+    #[test]
+    fn test_clean_summary() {
+        assert_eq!(
+            clean_summary("  Retries a declined card charge.\n\nUsed by   billing.  "),
+            Some("Retries a declined card charge. Used by billing.".to_string())
+        );
+        assert_eq!(clean_summary(" \n "), None);
+    }
+}
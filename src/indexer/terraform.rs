@@ -0,0 +1,476 @@
+//! Terraform file chunking
+//!
+//! Each top-level block of a `.tf` file becomes a chunk named by its label
+//! and qualified by its Terraform address:
+//!
+//! - `resource "aws_s3_bucket" "logs"` is a [`SemanticKind::Struct`] chunk
+//!   named `logs`, qualified as `aws_s3_bucket.logs`
+//! - `data` sources are structs too, qualified as `data.<type>.<name>`
+//! - `module` blocks are [`SemanticKind::Module`] chunks (`module.<name>`)
+//! - `variable` and `provider` blocks are [`SemanticKind::Config`] chunks
+//!   (`var.<name>`, `provider.<name>`)
+//! - `output` blocks are [`SemanticKind::Constant`] chunks (`output.<name>`)
+//! - `locals`, `terraform` and other blocks are [`SemanticKind::Block`]
+//!   chunks named by their type
+//!
+//! Nested blocks such as `versioning { enabled = true }` stay in their
+//! resource's chunk, so "S3 bucket with versioning enabled" matches the
+//! whole resource. Resources and data sources are tagged
+//! `provider:<name>` and `resource:<type>` (`data:<type>` for data
+//! sources); the provider is the one set with `provider = aws.west`, or the
+//! resource type's prefix. Modules are tagged `source:<source>`. The `#` and
+//! `//` comment lines directly above a block are part of its chunk.
+
+use std::path::Path;
+
+use super::ast_chunker::SemanticKind;
+use super::struct_tags::struct_tag;
+use super::Chunk;
+
+/// Language identifier assigned to Terraform chunks
+pub const LANGUAGE: &str = "terraform";
+
+/// Extension of Terraform files
+pub const EXTENSION: &str = "tf";
+
+/// Chunk a Terraform file into one chunk per top-level block.
+///
+/// Returns `None` when `path` is not a Terraform file, so callers can fall
+/// back to regular chunking.
+pub fn chunk_file(path: &Path, content: &str) -> Option<Vec<Chunk>> {
+    if path.extension().and_then(|e| e.to_str()) != Some(EXTENSION) {
+        return None;
+    }
+
+    let lines: Vec<&str> = content.lines().collect();
+    let line_offsets: Vec<usize> = lines
+        .iter()
+        .map(|line| line.as_ptr() as usize - content.as_ptr() as usize)
+        .collect();
+    let mut chunks = Vec::new();
+    // First line of the comment block above the current line
+    let mut comment_start = None;
+    let mut i = 0;
+
+    while i < lines.len() {
+        let trimmed = lines[i].trim();
+        if trimmed.starts_with('#') || trimmed.starts_with("//") {
+            comment_start.get_or_insert(i);
+            i += 1;
+            continue;
+        }
+        if trimmed.starts_with("/*") {
+            // Block comments are skipped, not attached
+            while i < lines.len() && !lines[i].contains("*/") {
+                i += 1;
+            }
+            comment_start = None;
+            i += 1;
+            continue;
+        }
+
+        let Some(header) = parse_header(lines[i]) else {
+            comment_start = None;
+            i += 1;
+            continue;
+        };
+        let open = line_offsets[i] + header.open;
+        let close = block_end(content, open).unwrap_or(content.len());
+        let end = content[..close].matches('\n').count().min(lines.len() - 1);
+        let start = comment_start.take().unwrap_or(i);
+        let body = &content[open + 1..close.max(open + 1)];
+
+        let block = describe(&header, body);
+        chunks.push(Chunk {
+            content: lines[start..=end].join("\n"),
+            file_path: path.to_path_buf(),
+            start_line: start + 1,
+            end_line: end + 1,
+            language: Some(LANGUAGE.to_string()),
+            semantic_kind: Some(block.kind),
+            name: Some(block.name),
+            signature: Some(header.signature),
+            doc: None,
+            parent: None,
+            qualified_name: Some(block.address),
+            tags: block.tags,
+        });
+        i = end + 1;
+    }
+
+    Some(chunks)
+}
+
+/// A block's opening line: `resource "aws_s3_bucket" "logs" {`
+#[derive(Debug, PartialEq)]
+struct Header {
+    /// Block type, as in `resource`
+    kind: String,
+    /// Labels without quotes
+    labels: Vec<String>,
+    /// The line up to the `{`
+    signature: String,
+    /// Byte offset of the `{` in the line
+    open: usize,
+}
+
+/// Parse a block header: a type, any number of quoted or bare labels, and
+/// an opening brace.
+fn parse_header(line: &str) -> Option<Header> {
+    let indent = line.len() - line.trim_start().len();
+    // Top-level blocks only
+    if indent > 0 {
+        return None;
+    }
+
+    let mut rest = line;
+    let mut words = Vec::new();
+    loop {
+        rest = rest.trim_start();
+        if let Some(quoted) = rest.strip_prefix('"') {
+            let close = quoted.find('"')?;
+            words.push(quoted[..close].to_string());
+            rest = &quoted[close + 1..];
+        } else if rest.starts_with('{') {
+            break;
+        } else {
+            let len = rest
+                .find(|c: char| !(c.is_alphanumeric() || matches!(c, '_' | '-')))
+                .unwrap_or(rest.len());
+            if len == 0 {
+                return None;
+            }
+            words.push(rest[..len].to_string());
+            rest = &rest[len..];
+        }
+    }
+
+    let open = line.len() - rest.len();
+    let mut words = words.into_iter();
+    let kind = words.next()?;
+    if kind.starts_with(|c: char| c.is_ascii_digit()) {
+        return None;
+    }
+    Some(Header {
+        kind,
+        labels: words.collect(),
+        signature: line[..open].trim_end().to_string(),
+        open,
+    })
+}
+
+/// Byte offset of the `}` closing the brace at `open`.
+///
+/// Skips braces in strings, comments and heredocs; `${...}` and `%{...}`
+/// template sequences inside strings may hold strings of their own.
+fn block_end(content: &str, open: usize) -> Option<usize> {
+    enum Context {
+        Brace,
+        Template,
+        String,
+    }
+
+    let bytes = content.as_bytes();
+    let mut stack = vec![Context::Brace];
+    let mut i = open + 1;
+    while i < bytes.len() {
+        if !content.is_char_boundary(i) {
+            i += 1;
+            continue;
+        }
+        let rest = &content[i..];
+        if let Some(Context::String) = stack.last() {
+            match bytes[i] {
+                b'\\' => i += 1,
+                b'"' => {
+                    stack.pop();
+                }
+                b'$' | b'%' if rest[1..].starts_with('{') => {
+                    stack.push(Context::Template);
+                    i += 1;
+                }
+                _ => {}
+            }
+            i += 1;
+            continue;
+        }
+
+        if rest.starts_with('#') || rest.starts_with("//") {
+            i += rest.find('\n').unwrap_or(rest.len());
+            continue;
+        }
+        if rest.starts_with("/*") {
+            i += rest.find("*/").map_or(rest.len(), |end| end + 2);
+            continue;
+        }
+        if let Some(len) = heredoc_len(rest) {
+            i += len;
+            continue;
+        }
+        match bytes[i] {
+            b'"' => stack.push(Context::String),
+            b'{' => stack.push(Context::Brace),
+            b'}' => {
+                stack.pop();
+                if stack.is_empty() {
+                    return Some(i);
+                }
+            }
+            _ => {}
+        }
+        i += 1;
+    }
+    None
+}
+
+/// Length of the heredoc (`<<EOF` or `<<-EOF` to the line holding just
+/// `EOF`) at the start of `text`, if it starts with one.
+fn heredoc_len(text: &str) -> Option<usize> {
+    let marker = text.strip_prefix("<<")?;
+    let marker = marker.strip_prefix('-').unwrap_or(marker);
+    let len = marker
+        .find(|c: char| !(c.is_alphanumeric() || c == '_'))
+        .unwrap_or(marker.len());
+    let marker = &marker[..len];
+    if marker.is_empty() {
+        return None;
+    }
+
+    let mut offset = text.find('\n')? + 1;
+    for line in text[offset..].split_inclusive('\n') {
+        offset += line.len();
+        if line.trim() == marker {
+            return Some(offset);
+        }
+    }
+    Some(text.len())
+}
+
+/// What a block is in Terraform's terms
+struct Block {
+    kind: SemanticKind,
+    name: String,
+    address: String,
+    tags: Vec<String>,
+}
+
+/// Kind, name, address and tags of a block.
+fn describe(header: &Header, body: &str) -> Block {
+    let label = |i: usize| header.labels.get(i).cloned().unwrap_or_default();
+    let provider_of = |resource_type: &str| {
+        attribute(body, "provider")
+            .map(|p| p.split('.').next().unwrap_or_default().to_string())
+            .unwrap_or_else(|| {
+                let prefix = resource_type.split('_').next().unwrap_or(resource_type);
+                prefix.to_string()
+            })
+    };
+
+    match (header.kind.as_str(), header.labels.len()) {
+        ("resource", 2) => Block {
+            kind: SemanticKind::Struct,
+            name: label(1),
+            address: format!("{}.{}", label(0), label(1)),
+            tags: vec![
+                struct_tag("provider", &provider_of(&label(0))),
+                struct_tag("resource", &label(0)),
+            ],
+        },
+        ("data", 2) => Block {
+            kind: SemanticKind::Struct,
+            name: label(1),
+            address: format!("data.{}.{}", label(0), label(1)),
+            tags: vec![
+                struct_tag("provider", &provider_of(&label(0))),
+                struct_tag("data", &label(0)),
+            ],
+        },
+        ("module", 1) => Block {
+            kind: SemanticKind::Module,
+            name: label(0),
+            address: format!("module.{}", label(0)),
+            tags: attribute(body, "source")
+                .map(|source| struct_tag("source", &source))
+                .into_iter()
+                .collect(),
+        },
+        ("variable", 1) => Block {
+            kind: SemanticKind::Config,
+            name: label(0),
+            address: format!("var.{}", label(0)),
+            tags: Vec::new(),
+        },
+        ("output", 1) => Block {
+            kind: SemanticKind::Constant,
+            name: label(0),
+            address: format!("output.{}", label(0)),
+            tags: Vec::new(),
+        },
+        ("provider", 1) => Block {
+            kind: SemanticKind::Config,
+            name: label(0),
+            address: match attribute(body, "alias") {
+                Some(alias) => format!("provider.{}.{}", label(0), alias),
+                None => format!("provider.{}", label(0)),
+            },
+            tags: vec![struct_tag("provider", &label(0))],
+        },
+        _ => {
+            let mut address = vec![header.kind.clone()];
+            address.extend(header.labels.iter().cloned());
+            Block {
+                kind: SemanticKind::Block,
+                name: header.kind.clone(),
+                address: address.join("."),
+                tags: Vec::new(),
+            }
+        }
+    }
+}
+
+/// Value of a top-level `key = value` attribute of a block body, without
+/// quotes.
+fn attribute(body: &str, key: &str) -> Option<String> {
+    let mut depth = 0i32;
+    for line in body.lines() {
+        let trimmed = line.trim();
+        if depth == 0 {
+            if let Some((name, value)) = trimmed.split_once('=') {
+                if name.trim() == key {
+                    let value = value.split(" #").next().unwrap_or_default().trim();
+                    return Some(value.trim_matches('"').to_string());
+                }
+            }
+        }
+        // Strings rarely hold braces in attribute lines; count them outside
+        // of quotes anyway
+        let mut quoted = false;
+        for c in trimmed.chars() {
+            match c {
+                '"' => quoted = !quoted,
+                '{' | '[' if !quoted => depth += 1,
+                '}' | ']' if !quoted => depth -= 1,
+                _ => {}
+            }
+        }
+    }
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunks(content: &str) -> Vec<Chunk> {
+        chunk_file(Path::new("main.tf"), content).unwrap()
+    }
+
+    #[test]
+    fn test_resources_with_nested_blocks() {
+        let content = r#"# Storage
+
+# Access logs for the CDN.
+resource "aws_s3_bucket" "logs" {
+  bucket = "acme-logs-${var.env}"
+  tags   = { Name = "logs" }
+
+  versioning {
+    enabled = true # "}" in a comment
+  }
+
+  policy = <<EOF
+{ "Version": "2012-10-17" }
+EOF
+}
+
+data "google_storage_bucket" "assets" {
+  name     = "assets"
+  provider = google-beta.eu
+}
+"#;
+        let chunks = chunks(content);
+        assert_eq!(chunks.len(), 2);
+
+        let logs = &chunks[0];
+        assert_eq!(logs.semantic_kind, Some(SemanticKind::Struct));
+        assert_eq!(logs.name.as_deref(), Some("logs"));
+        assert_eq!(logs.qualified_name.as_deref(), Some("aws_s3_bucket.logs"));
+        assert_eq!(
+            logs.signature.as_deref(),
+            Some(r#"resource "aws_s3_bucket" "logs""#)
+        );
+        // The heading is separated by a blank line
+        assert_eq!((logs.start_line, logs.end_line), (3, 15));
+        assert_eq!(logs.tags, vec!["provider:aws", "resource:aws_s3_bucket"]);
+
+        let assets = &chunks[1];
+        assert_eq!(
+            assets.qualified_name.as_deref(),
+            Some("data.google_storage_bucket.assets")
+        );
+        assert_eq!(
+            assets.tags,
+            vec!["provider:google-beta", "data:google_storage_bucket"]
+        );
+    }
+
+    #[test]
+    fn test_modules_variables_and_outputs() {
+        let content = r#"terraform {
+  required_version = ">= 1.5"
+}
+
+variable "region" {
+  type    = string
+  default = "eu-west-1"
+}
+
+variable "empty" {}
+
+module "network" {
+  source = "terraform-aws-modules/vpc/aws"
+  cidr   = "10.0.0.0/16"
+}
+
+provider "aws" {
+  alias  = "west"
+  region = var.region
+}
+
+output "vpc_id" {
+  value = module.network.vpc_id
+}
+
+locals {
+  name = "acme"
+}
+"#;
+        let chunks = chunks(content);
+        let summary: Vec<_> = chunks
+            .iter()
+            .map(|c| {
+                (
+                    c.semantic_kind.unwrap(),
+                    c.qualified_name.as_deref().unwrap(),
+                    c.start_line,
+                    c.end_line,
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            summary,
+            vec![
+                (SemanticKind::Block, "terraform", 1, 3),
+                (SemanticKind::Config, "var.region", 5, 8),
+                (SemanticKind::Config, "var.empty", 10, 10),
+                (SemanticKind::Module, "module.network", 12, 15),
+                (SemanticKind::Config, "provider.aws.west", 17, 20),
+                (SemanticKind::Constant, "output.vpc_id", 22, 24),
+                (SemanticKind::Block, "locals", 26, 28),
+            ]
+        );
+        assert_eq!(chunks[3].tags, vec!["source:terraform-aws-modules/vpc/aws"]);
+        assert!(chunk_file(Path::new("main.rs"), "fn main() {}").is_none());
+    }
+}
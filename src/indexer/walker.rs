@@ -1,15 +1,95 @@
+use anyhow::Result;
 use ignore::WalkBuilder;
-use std::collections::HashSet;
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashSet};
 use std::ffi::OsStr;
-use std::path::PathBuf;
+use std::fs;
+use std::path::{Path, PathBuf};
+use tracing::warn;
 
+use super::{dockerfile, dotenv};
 use crate::config::IndexerConfig;
 
+/// File name of the symlink alias map in the storage directory
+pub const SYMLINK_ALIASES_FILE: &str = "symlink_aliases.json";
+
+/// How symlinked files and directories are handled while walking.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "kebab-case")]
+pub enum SymlinkPolicy {
+    /// Follow symlinks; a file reachable through several paths is indexed once per path
+    Follow,
+    /// Ignore symlinks entirely
+    #[default]
+    Skip,
+    /// Follow symlinks but index each real file once, recording the other
+    /// paths as aliases
+    DedupByRealpath,
+}
+
+impl SymlinkPolicy {
+    /// Parse policy from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "follow" => Some(Self::Follow),
+            "skip" => Some(Self::Skip),
+            "dedup-by-realpath" => Some(Self::DedupByRealpath),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Follow => "follow",
+            Self::Skip => "skip",
+            Self::DedupByRealpath => "dedup-by-realpath",
+        }
+    }
+}
+
+/// Alternate paths of deduplicated files, keyed by the indexed path.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SymlinkAliases(pub BTreeMap<PathBuf, Vec<PathBuf>>);
+
+impl SymlinkAliases {
+    /// Aliases of an indexed file
+    pub fn aliases(&self, path: &Path) -> &[PathBuf] {
+        self.0.get(path).map(Vec::as_slice).unwrap_or(&[])
+    }
+
+    /// Load the alias map from a storage directory; missing files load empty
+    pub fn load(storage_dir: &Path) -> Result<Self> {
+        let path = storage_dir.join(SYMLINK_ALIASES_FILE);
+        if !path.exists() {
+            return Ok(Self::default());
+        }
+        Ok(serde_json::from_str(&fs::read_to_string(path)?)?)
+    }
+
+    /// Save the alias map to a storage directory, removing it when empty
+    pub fn save(&self, storage_dir: &Path) -> Result<()> {
+        let path = storage_dir.join(SYMLINK_ALIASES_FILE);
+        if self.0.is_empty() {
+            if path.exists() {
+                fs::remove_file(path)?;
+            }
+            return Ok(());
+        }
+        fs::create_dir_all(storage_dir)?;
+        fs::write(path, serde_json::to_string_pretty(self)?)?;
+        Ok(())
+    }
+}
+
 /// Walks the filesystem respecting .gitignore and custom ignore patterns
 pub struct Walker {
     root: PathBuf,
     extensions: HashSet<String>,
     ignore_patterns: Vec<String>,
+    symlinks: SymlinkPolicy,
+    max_depth: usize,
+    max_path_length: usize,
 }
 
 impl Walker {
@@ -17,8 +97,11 @@ impl Walker {
     pub fn new(root: PathBuf, config: &IndexerConfig) -> Self {
         Self {
             root,
-            extensions: config.extensions.iter().cloned().collect(),
+            extensions: config.indexed_extensions().into_iter().collect(),
             ignore_patterns: config.ignore_patterns.clone(),
+            symlinks: config.symlinks,
+            max_depth: config.max_depth,
+            max_path_length: config.max_path_length,
         }
     }
 
@@ -27,17 +110,74 @@ impl Walker {
     /// This respects:
     /// - .gitignore files
     /// - Custom ignore patterns from config
-    /// - File extension filtering
+    /// - File extension filtering. With `env` in the extensions, dotenv
+    ///   templates such as `.env.example` are walked too, and with
+    ///   `dockerfile`, files named `Dockerfile` or `Dockerfile.<suffix>`.
+    /// - The symlink policy. Symlink cycles are detected by the walker and
+    ///   skipped.
+    /// - The depth and path length limits. Directories nested deeper than
+    ///   `max_depth` and paths longer than `max_path_length` are skipped with
+    ///   a warning.
+    ///
+    /// Under [`SymlinkPolicy::DedupByRealpath`] each real file is yielded
+    /// once, under its canonical path when that lies inside the root.
     pub fn walk(&self) -> impl Iterator<Item = PathBuf> {
+        let mut seen = HashSet::new();
+        let dedup = self.symlinks == SymlinkPolicy::DedupByRealpath;
+        self.walk_entries().filter_map(move |(path, real)| {
+            if !dedup {
+                return Some(path);
+            }
+            let real = real?;
+            seen.insert(real.0).then_some(real.1)
+        })
+    }
+
+    /// Map each file yielded by [`walk`](Self::walk) to the other paths it
+    /// is reachable through. Only populated under
+    /// [`SymlinkPolicy::DedupByRealpath`].
+    pub fn symlink_aliases(&self) -> SymlinkAliases {
+        let mut aliases = SymlinkAliases::default();
+        if self.symlinks != SymlinkPolicy::DedupByRealpath {
+            return aliases;
+        }
+
+        let mut indexed: BTreeMap<PathBuf, PathBuf> = BTreeMap::new();
+        for (path, real) in self.walk_entries() {
+            let Some((canonical, chosen)) = real else {
+                continue;
+            };
+            let chosen = indexed.entry(canonical).or_insert(chosen).clone();
+            if path != chosen {
+                aliases.0.entry(chosen).or_default().push(path);
+            }
+        }
+        for paths in aliases.0.values_mut() {
+            paths.sort();
+            paths.dedup();
+        }
+        aliases
+    }
+
+    /// Walk matching files, pairing each with its real path and the path it
+    /// is indexed under when deduplicating by real path.
+    fn walk_entries(&self) -> impl Iterator<Item = (PathBuf, Option<(PathBuf, PathBuf)>)> {
         let mut builder = WalkBuilder::new(&self.root);
 
+        // Sort so deduplication always keeps the same path
+        builder.sort_by_file_name(|a, b| a.cmp(b));
+        builder.follow_links(self.symlinks != SymlinkPolicy::Skip);
+
         // Enable .gitignore support (enabled by default, but explicit)
         builder.git_ignore(true);
         builder.git_global(true);
         builder.git_exclude(true);
 
-        // Add hidden file filtering (skip .git, etc.)
-        builder.hidden(true);
+        // Hidden files (.git, etc.) are skipped in `filter_entry` below,
+        // except dotenv templates such as `.env.example`
+        builder.hidden(false);
+        let dotenv_templates = self.extensions.contains(dotenv::EXTENSION);
+        let dockerfiles = self.extensions.contains(dockerfile::EXTENSION);
 
         // Add custom ignore patterns using overrides
         let mut override_builder = ignore::overrides::OverrideBuilder::new(&self.root);
@@ -51,8 +191,42 @@ impl Walker {
             builder.overrides(overrides);
         }
 
+        // Prune pathological trees, such as deeply nested generated output,
+        // before descending into them
+        let max_depth = self.max_depth;
+        let max_path_length = self.max_path_length;
+        builder.filter_entry(move |entry| {
+            let hidden = entry.depth() > 0 && entry.file_name().to_string_lossy().starts_with('.');
+            if hidden && !(dotenv_templates && dotenv::is_template(entry.path())) {
+                return false;
+            }
+            let is_dir = entry.file_type().is_some_and(|ft| ft.is_dir());
+            if is_dir && entry.depth() > max_depth {
+                warn!(
+                    "Skipping {}: nested deeper than max_depth ({})",
+                    entry.path().display(),
+                    max_depth
+                );
+                return false;
+            }
+            let length = entry.path().as_os_str().len();
+            if length > max_path_length {
+                warn!(
+                    "Skipping {}: path is {} bytes, longer than max_path_length ({})",
+                    entry.path().display(),
+                    length,
+                    max_path_length
+                );
+                return false;
+            }
+            true
+        });
+
         let extensions = self.extensions.clone();
         let ignore_patterns = self.ignore_patterns.clone();
+        let root = self.root.clone();
+        let canonical_root = fs::canonicalize(&self.root).unwrap_or_else(|_| self.root.clone());
+        let dedup = self.symlinks == SymlinkPolicy::DedupByRealpath;
 
         builder
             .build()
@@ -75,8 +249,25 @@ impl Walker {
                     .and_then(OsStr::to_str)
                     .map(|ext| extensions.contains(ext))
                     .unwrap_or(false)
+                    || (dotenv_templates && dotenv::is_template(entry.path()))
+                    || (dockerfiles && dockerfile::is_dockerfile(entry.path()))
+            })
+            .map(move |entry| {
+                let path = entry.into_path();
+                if !dedup {
+                    return (path, None);
+                }
+                let real = fs::canonicalize(&path).ok().map(|canonical| {
+                    // Index under the real path when it is inside the
+                    // project, otherwise under the first path found
+                    let chosen = canonical
+                        .strip_prefix(&canonical_root)
+                        .map(|rel| root.join(rel))
+                        .unwrap_or_else(|_| path.clone());
+                    (canonical, chosen)
+                });
+                (path, real)
             })
-            .map(|entry| entry.into_path())
     }
 
     /// Get the number of files that will be walked (for progress bars)
@@ -136,6 +327,55 @@ mod tests {
         assert_eq!(files.len(), 2);
     }
 
+    #[test]
+    fn test_walker_finds_dotenv_templates() {
+        let dir = tempdir().unwrap();
+        fs::create_dir_all(dir.path().join(".git")).unwrap();
+
+        fs::write(dir.path().join("main.rs"), "fn main() {}").unwrap();
+        fs::write(dir.path().join(".env.example"), "PORT=8080").unwrap();
+        fs::write(dir.path().join(".env"), "API_KEY=secret").unwrap();
+        fs::write(dir.path().join(".hidden.rs"), "fn hidden() {}").unwrap();
+        fs::write(dir.path().join(".git/config.env.example"), "A=1").unwrap();
+
+        // Only with `env` among the extensions
+        let walker = Walker::new(dir.path().to_path_buf(), &test_config());
+        assert_eq!(walker.collect_files(), vec![dir.path().join("main.rs")]);
+
+        let mut config = test_config();
+        config.extensions.push("env".to_string());
+        let walker = Walker::new(dir.path().to_path_buf(), &config);
+        assert_eq!(
+            walker.collect_files(),
+            vec![dir.path().join(".env.example"), dir.path().join("main.rs")]
+        );
+    }
+
+    #[test]
+    fn test_walker_finds_dockerfiles() {
+        let dir = tempdir().unwrap();
+        fs::create_dir_all(dir.path().join("deploy")).unwrap();
+
+        fs::write(dir.path().join("main.rs"), "fn main() {}").unwrap();
+        fs::write(dir.path().join("Dockerfile"), "FROM rust").unwrap();
+        fs::write(dir.path().join("deploy/Dockerfile.dev"), "FROM rust").unwrap();
+        fs::write(dir.path().join("Makefile"), "all:").unwrap();
+
+        let mut config = test_config();
+        config.extensions.push("dockerfile".to_string());
+        let walker = Walker::new(dir.path().to_path_buf(), &config);
+        let mut files = walker.collect_files();
+        files.sort();
+        assert_eq!(
+            files,
+            vec![
+                dir.path().join("Dockerfile"),
+                dir.path().join("deploy/Dockerfile.dev"),
+                dir.path().join("main.rs"),
+            ]
+        );
+    }
+
     #[test]
     fn test_walker_ignores_directories() {
         let dir = tempdir().unwrap();
@@ -155,4 +395,156 @@ mod tests {
         assert_eq!(files.len(), 1);
         assert!(files[0].ends_with("main.rs"));
     }
+
+    /// Monorepo layout where an app vendors a shared package via symlink:
+    /// `packages/app/vendor/shared -> ../../shared`
+    #[cfg(unix)]
+    fn symlinked_monorepo() -> tempfile::TempDir {
+        let dir = tempdir().unwrap();
+        let shared = dir.path().join("packages/shared");
+        let vendor = dir.path().join("packages/app/vendor");
+        fs::create_dir_all(&shared).unwrap();
+        fs::create_dir_all(&vendor).unwrap();
+        fs::write(shared.join("util.rs"), "pub fn util() {}").unwrap();
+        fs::write(dir.path().join("packages/app/main.rs"), "fn main() {}").unwrap();
+        std::os::unix::fs::symlink("../../shared", vendor.join("shared")).unwrap();
+        dir
+    }
+
+    #[cfg(unix)]
+    fn walk_with(root: &Path, policy: SymlinkPolicy) -> Walker {
+        let config = IndexerConfig {
+            symlinks: policy,
+            ..test_config()
+        };
+        Walker::new(root.to_path_buf(), &config)
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn test_symlinks_skipped_by_default() {
+        let dir = symlinked_monorepo();
+        let files = walk_with(dir.path(), SymlinkPolicy::default()).collect_files();
+
+        assert_eq!(files.len(), 2);
+        assert!(!files.iter().any(|f| f.to_string_lossy().contains("vendor")));
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn test_follow_symlinks_duplicates_files() {
+        let dir = symlinked_monorepo();
+        let files = walk_with(dir.path(), SymlinkPolicy::Follow).collect_files();
+
+        assert_eq!(files.len(), 3);
+        assert!(files.contains(&dir.path().join("packages/app/vendor/shared/util.rs")));
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn test_dedup_by_realpath_records_aliases() {
+        let dir = symlinked_monorepo();
+        let walker = walk_with(dir.path(), SymlinkPolicy::DedupByRealpath);
+        let real = dir.path().join("packages/shared/util.rs");
+        let alias = dir.path().join("packages/app/vendor/shared/util.rs");
+
+        let files = walker.collect_files();
+        assert_eq!(files.len(), 2);
+        assert!(files.contains(&real));
+        assert!(!files.contains(&alias));
+
+        let aliases = walker.symlink_aliases();
+        assert_eq!(aliases.aliases(&real), &[alias]);
+        assert!(aliases
+            .aliases(&dir.path().join("packages/app/main.rs"))
+            .is_empty());
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn test_symlink_cycle_terminates() {
+        let dir = symlinked_monorepo();
+        // packages/shared/loop -> packages, so following it never ends
+        std::os::unix::fs::symlink("..", dir.path().join("packages/shared/loop")).unwrap();
+
+        let files = walk_with(dir.path(), SymlinkPolicy::DedupByRealpath).collect_files();
+        assert_eq!(files.len(), 2);
+    }
+
+    /// `a/a/.../a/deep.rs`, with one file per level
+    fn nested_tree(levels: usize) -> tempfile::TempDir {
+        let dir = tempdir().unwrap();
+        let mut path = dir.path().to_path_buf();
+        for level in 0..levels {
+            path.push("a");
+            fs::create_dir_all(&path).unwrap();
+            fs::write(path.join(format!("level{}.rs", level + 1)), "fn f() {}").unwrap();
+        }
+        dir
+    }
+
+    #[test]
+    fn test_max_depth_skips_deep_directories() {
+        let dir = nested_tree(40);
+        let config = IndexerConfig {
+            max_depth: 10,
+            ..test_config()
+        };
+        let files = Walker::new(dir.path().to_path_buf(), &config).collect_files();
+
+        // Directories at depths 1-10 are walked, with the files they hold
+        assert_eq!(files.len(), 10);
+        assert!(files.iter().any(|f| f.ends_with("level10.rs")));
+        assert!(!files.iter().any(|f| f.ends_with("level11.rs")));
+
+        // The default limit is generous enough for the whole tree
+        let files = Walker::new(dir.path().to_path_buf(), &test_config()).collect_files();
+        assert_eq!(files.len(), 40);
+    }
+
+    #[test]
+    fn test_max_path_length_skips_long_paths() {
+        let dir = nested_tree(40);
+        let root_length = dir.path().as_os_str().len();
+        // Room for five `/a` components and a file name
+        let config = IndexerConfig {
+            max_path_length: root_length + 5 * 2 + "/level5.rs".len(),
+            ..test_config()
+        };
+        let files = Walker::new(dir.path().to_path_buf(), &config).collect_files();
+
+        assert_eq!(files.len(), 5);
+        assert!(files.iter().any(|f| f.ends_with("level5.rs")));
+    }
+
+    #[test]
+    fn test_symlink_aliases_roundtrip() {
+        let dir = tempdir().unwrap();
+        assert_eq!(
+            SymlinkAliases::load(dir.path()).unwrap(),
+            SymlinkAliases::default()
+        );
+
+        let mut aliases = SymlinkAliases::default();
+        aliases
+            .0
+            .insert(PathBuf::from("a.rs"), vec![PathBuf::from("link/a.rs")]);
+        aliases.save(dir.path()).unwrap();
+        assert_eq!(SymlinkAliases::load(dir.path()).unwrap(), aliases);
+
+        // Saving an empty map removes the file
+        SymlinkAliases::default().save(dir.path()).unwrap();
+        assert!(!dir.path().join(SYMLINK_ALIASES_FILE).exists());
+    }
+
+    #[test]
+    fn test_symlink_policy_parse() {
+        assert_eq!(
+            SymlinkPolicy::parse("dedup-by-realpath"),
+            Some(SymlinkPolicy::DedupByRealpath)
+        );
+        assert_eq!(SymlinkPolicy::parse("Follow"), Some(SymlinkPolicy::Follow));
+        assert_eq!(SymlinkPolicy::parse("copy"), None);
+        assert_eq!(SymlinkPolicy::Skip.as_str(), "skip");
+    }
 }
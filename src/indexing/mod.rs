@@ -3,6 +3,7 @@
 pub mod parallel;
 pub mod errors;
 pub mod pipeline;
+pub mod stages;
 
 pub use parallel::ParallelIndexer;
 pub use errors::{FileError, ProcessingStage, ErrorCollector, ErrorReport};
@@ -3,26 +3,46 @@
 use anyhow::{Context, Result};
 use indicatif::{MultiProgress, ProgressBar, ProgressStyle};
 use rayon::prelude::*;
+use std::collections::{HashMap, HashSet};
 use std::fs;
-use std::path::PathBuf;
-use std::sync::atomic::{AtomicUsize, Ordering};
+use std::path::{Path, PathBuf};
 use std::sync::{Arc, Mutex};
 use std::time::{Instant, UNIX_EPOCH};
 use tokio::sync::Semaphore;
-use tracing::{error, info};
-
-use crate::config::Config;
-use crate::embeddings::EmbeddingGenerator;
-use crate::indexer::{AstChunker, Chunker, ChunkerStrategy, Walker};
-use crate::storage::{IndexedChunk, Storage};
+use tracing::info;
+
+use crate::config::{Config, EmbeddingProvider};
+use crate::embeddings::{tokenizer_for_config, EmbeddingGenerator};
+use crate::indexer::comments::embedding_text;
+use crate::indexer::context_header::{with_context_header, ChunkContext};
+use crate::indexer::field_vectors::{embed_fields, enabled_fields};
+use crate::indexer::imports::{chunk_import_contexts, with_import_context};
+use crate::indexer::summaries::Summarizer;
+use crate::indexer::{AstChunker, Chunker, ChunkerStrategy, FlagDetector, HeaderMetadata, Walker};
+use crate::storage::{
+    assign_ids, content_hash, open_configured_store, DeterministicIds, IdGenerator, IndexedChunk,
+    VectorField, VectorStore,
+};
 
 use super::errors::{ErrorCollector, ProcessingStage};
 use super::pipeline::{FileContent, ProcessingResult, RawChunk};
+use super::stages;
+
+/// Where the files of a pipeline run come from
+enum Source {
+    /// Files on disk, read in batches as the pipeline runs
+    Paths(Vec<PathBuf>),
+    /// Contents already read, such as from an archive or a git ref
+    Contents(Vec<FileContent>),
+}
 
 /// Parallel indexer for processing files concurrently
 pub struct ParallelIndexer {
-    storage: Arc<Storage>,
+    root: PathBuf,
+    storage: Arc<dyn VectorStore>,
     embedder: Arc<EmbeddingGenerator>,
+    /// Embedding provider whose vectors `storage` holds
+    provider: EmbeddingProvider,
     line_chunker: Option<Arc<Chunker>>,
     ast_chunker: Option<Arc<Mutex<AstChunker>>>,
     #[allow(dead_code)]
@@ -30,6 +50,11 @@ pub struct ParallelIndexer {
     config: Config,
     error_collector: ErrorCollector,
     semaphore: Arc<Semaphore>,
+    ids: Arc<dyn IdGenerator>,
+    flags: Arc<FlagDetector>,
+    header_meta: Arc<HeaderMetadata>,
+    /// Writes chunk summaries when `indexer.summaries` is enabled
+    summarizer: Option<Arc<Summarizer>>,
 }
 
 impl ParallelIndexer {
@@ -68,25 +93,45 @@ impl ParallelIndexer {
                 .context("Failed to initialize embedder")?
         );
         let vector_dimension = embedder.embedding_dimension();
+        let provider = embedder.provider();
 
         // Then create storage with the correct vector dimension
-        let db_path = storage_path.unwrap_or_else(|| config.db_path(&root));
-        let storage = Arc::new(
-            Storage::new(&db_path, vector_dimension)
-                .await
-                .context("Failed to initialize storage")?
-        );
+        let db_path = embedder.index_path(&storage_path.unwrap_or_else(|| config.db_path(&root)));
+        let storage = open_configured_store(&config.storage, &db_path, Some(vector_dimension))
+            .await
+            .context("Failed to initialize storage")?;
 
         let walker = Arc::new(Walker::new(root.clone(), &config.indexer));
 
-        // Initialize appropriate chunker based on strategy
-        let (line_chunker, ast_chunker) = if config.indexer.chunker_strategy == ChunkerStrategy::Ast {
-            (None, Some(Arc::new(Mutex::new(AstChunker::with_limits(
+        // Initialize appropriate chunker based on strategy, counting tokens
+        // with the embedding model's tokenizer. API-surface mode needs the AST.
+        // Chunks stay within the model's input limit, so none is truncated.
+        let tokenizer = tokenizer_for_config(&config.embeddings);
+        let input_limit = config.chunk_token_limit();
+        let use_ast =
+            config.indexer.chunker_strategy == ChunkerStrategy::Ast || config.indexer.api_surface;
+        let (line_chunker, ast_chunker) = if use_ast {
+            let chunker = AstChunker::with_limits(
                 config.indexer.min_chunk_tokens,
-                config.indexer.max_chunk_tokens,
-            )))))
+                config.indexer.max_chunk_tokens.min(input_limit),
+            )
+            .with_tokenizer(tokenizer)
+            .with_merge_limit(config.indexer.max_merged_tokens)
+            .with_fallback_window(
+                config.indexer.window.tokens.min(input_limit),
+                config.indexer.window.overlap,
+            )
+            .with_api_surface(config.indexer.api_surface)
+            .with_symbol_cap(
+                config.indexer.max_symbols_per_file,
+                config.indexer.symbol_cap_policy,
+            )
+            .with_grammars(&config.indexer.grammars)?
+            .with_granularity(&root, &config.indexer.granularity)?;
+            (None, Some(Arc::new(Mutex::new(chunker))))
         } else {
-            (Some(Arc::new(Chunker::new(config.indexer.chunk_size))), None)
+            let chunk_size = config.indexer.chunk_size.min(input_limit);
+            (Some(Arc::new(Chunker::new(chunk_size).with_tokenizer(tokenizer))), None)
         };
 
         // Create semaphore for backpressure control
@@ -95,18 +140,37 @@ impl ParallelIndexer {
         // Error collector with reasonable limit
         let error_collector = ErrorCollector::new(1000);
 
+        let flags = Arc::new(FlagDetector::new(&config.indexer.flag_accessors));
+        let header_meta = Arc::new(HeaderMetadata::new(&config.indexer.header_keys));
+        let summarizer = match config.indexer.summaries.enabled {
+            true => Some(Arc::new(Summarizer::new(&config.indexer.summaries)?)),
+            false => None,
+        };
+
         Ok(Self {
+            root,
             storage,
             embedder,
+            provider,
             line_chunker,
             ast_chunker,
             walker,
             config,
             error_collector,
             semaphore,
+            ids: Arc::new(DeterministicIds),
+            flags,
+            header_meta,
+            summarizer,
         })
     }
 
+    /// Generate chunk IDs with `ids` instead of [`DeterministicIds`]
+    pub fn with_id_generator(mut self, ids: Arc<dyn IdGenerator>) -> Self {
+        self.ids = ids;
+        self
+    }
+
     /// Index files using parallel processing pipeline
     pub async fn index_files(&self, files: Vec<PathBuf>) -> Result<ProcessingResult> {
         let start = Instant::now();
@@ -114,7 +178,7 @@ impl ParallelIndexer {
 
         info!("Starting parallel indexing of {} files", total_files);
 
-        // Stage 1: Filter files needing indexing (sequential)
+        // Filter files needing indexing (sequential)
         let files_to_index = self.filter_modified_files(files).await?;
 
         if files_to_index.is_empty() {
@@ -127,40 +191,156 @@ impl ParallelIndexer {
         // Create progress tracking
         let multi_progress = MultiProgress::new();
         let file_pb = self.create_progress_bar(&multi_progress, files_to_index.len(), "Files");
-        let chunk_pb = self.create_progress_bar(&multi_progress, 100, "Chunks");
+        let chunk_pb = self.create_progress_bar(&multi_progress, 0, "Chunks");
+
+        let files_processed = files_to_index.len();
+        self.run_pipeline(
+            Source::Paths(files_to_index),
+            files_processed,
+            total_files,
+            start,
+            &file_pb,
+            &chunk_pb,
+            None,
+        )
+        .await
+    }
 
-        // Stage 2: Parallel file reading
-        let file_contents = self.read_files_parallel(
-            files_to_index.clone(),
+    /// Index file contents that were read elsewhere, such as from an archive.
+    ///
+    /// Every file is indexed; there is no modification-time check.
+    pub async fn index_contents(&self, contents: Vec<FileContent>) -> Result<ProcessingResult> {
+        self.index_contents_from(contents, None).await
+    }
+
+    /// Index file contents read from a git ref, tagging every chunk with
+    /// `branch`.
+    ///
+    /// Chunks whose content is already stored (from the working tree or
+    /// another ref) reuse the stored embedding instead of being embedded
+    /// again.
+    pub async fn index_contents_at_ref(
+        &self,
+        contents: Vec<FileContent>,
+        branch: &str,
+    ) -> Result<ProcessingResult> {
+        self.index_contents_from(contents, Some(branch)).await
+    }
+
+    async fn index_contents_from(
+        &self,
+        contents: Vec<FileContent>,
+        branch: Option<&str>,
+    ) -> Result<ProcessingResult> {
+        let start = Instant::now();
+        info!("Starting parallel indexing of {} files", contents.len());
+
+        let multi_progress = MultiProgress::new();
+        let file_pb = self.create_progress_bar(&multi_progress, contents.len(), "Files");
+        let chunk_pb = self.create_progress_bar(&multi_progress, 0, "Chunks");
+        file_pb.set_position(contents.len() as u64);
+
+        let total_files = contents.len();
+        self.run_pipeline(
+            Source::Contents(contents),
+            total_files,
+            total_files,
+            start,
             &file_pb,
-        ).await?;
+            &chunk_pb,
+            branch,
+        )
+        .await
+    }
+
+    /// Read, chunk, embed and store files as a staged pipeline.
+    ///
+    /// The stages run concurrently and hand batches to each other over
+    /// bounded channels (see [`super::stages`]), so a slow embedder holds
+    /// back reading and chunking, and memory use depends on the buffer sizes
+    /// in `[indexer.pipeline]` rather than on the number of files.
+    async fn run_pipeline(
+        &self,
+        source: Source,
+        files_processed: usize,
+        total_files: usize,
+        start: Instant,
+        file_pb: &ProgressBar,
+        chunk_pb: &ProgressBar,
+        branch: Option<&str>,
+    ) -> Result<ProcessingResult> {
+        let buffers = &self.config.indexer.pipeline;
+        let (files_tx, files_rx) = stages::channel(buffers.read_buffer);
+        let (chunks_tx, chunks_rx) = stages::channel(buffers.chunk_buffer);
+        let (embedded_tx, mut embedded_rx) = stages::channel(buffers.embed_buffer);
+
+        // Stored vectors by content hash, reused when indexing a git ref
+        let known = match branch {
+            Some(_) => Some(self.storage.vectors_by_content_hash().await?),
+            None => None,
+        };
+        let known = known.as_ref();
+
+        chunk_pb.set_message("Indexing...");
+
+        // Stage 1: read files in batches
+        let read = async move {
+            let batch_size = self.config.indexer.file_batch_size.max(1);
+            match source {
+                Source::Paths(paths) => {
+                    for batch in paths.chunks(batch_size) {
+                        let contents = self.read_files_parallel(batch.to_vec(), file_pb).await?;
+                        if files_tx.send(contents).await.is_err() {
+                            break;
+                        }
+                    }
+                }
+                Source::Contents(contents) => {
+                    let mut contents = contents.into_iter();
+                    loop {
+                        let batch: Vec<FileContent> = contents.by_ref().take(batch_size).collect();
+                        if batch.is_empty() || files_tx.send(batch).await.is_err() {
+                            break;
+                        }
+                    }
+                }
+            }
+            Ok::<_, anyhow::Error>(())
+        };
 
-        // Stage 3: Parallel chunking
-        let raw_chunks = self.chunk_files_parallel(file_contents, &chunk_pb).await?;
-        chunk_pb.set_length(raw_chunks.len() as u64);
+        // Stage 2: parallel chunking
+        let chunk = stages::run_stage(files_rx, chunks_tx, |files: Vec<FileContent>| async move {
+            let chunks = self.chunk_files_parallel(files).await?;
+            chunk_pb.inc_length(chunks.len() as u64);
+            Ok(chunks)
+        });
+
+        // Stage 3: embedding and assembly of indexed chunks
+        let embed = stages::run_stage(chunks_rx, embedded_tx, |raw_chunks: Vec<RawChunk>| {
+            self.embed_chunks(raw_chunks, known, branch)
+        });
+
+        // Stage 4: storage with backpressure
+        let store = async {
+            let mut replaced_files = HashSet::new();
+            let mut chunk_count = 0;
+            while let Some(chunks) = embedded_rx.recv().await {
+                chunk_count += chunks.len();
+                self.store_chunks_with_backpressure(chunks, &mut replaced_files, chunk_pb)
+                    .await?;
+            }
+            Ok::<_, anyhow::Error>(chunk_count)
+        };
 
-        if raw_chunks.is_empty() {
+        let ((), (), (), chunk_count) = tokio::try_join!(read, chunk, embed, store)?;
+
+        if chunk_count == 0 {
             info!("No chunks generated");
             file_pb.finish_with_message("No chunks to process");
             chunk_pb.finish_with_message("No chunks");
             return Ok(ProcessingResult::new());
         }
 
-        // Stage 4: Batch embedding generation
-        chunk_pb.set_position(0);
-        chunk_pb.set_message("Generating embeddings...");
-        let embeddings = self.generate_embeddings_batch(&raw_chunks, &chunk_pb).await?;
-
-        // Stage 5: Parallel assembly of indexed chunks
-        chunk_pb.set_message("Assembling chunks...");
-        let indexed_chunks = self.assemble_chunks_parallel(raw_chunks, embeddings).await?;
-
-        // Stage 6: Storage with backpressure
-        chunk_pb.set_position(0);
-        chunk_pb.set_message("Storing chunks...");
-        let chunk_count = indexed_chunks.len();
-        self.store_chunks_with_backpressure(indexed_chunks.clone(), &chunk_pb).await?;
-
         // Finish progress bars
         file_pb.finish_with_message("Complete");
         chunk_pb.finish_with_message("Complete");
@@ -179,16 +359,16 @@ impl ParallelIndexer {
         }
 
         Ok(ProcessingResult {
-            successful: indexed_chunks,
+            successful: Vec::new(),
             errors: errors.by_stage.into_values().flatten().collect(),
-            files_processed: files_to_index.len(),
+            files_processed,
             chunks_created: chunk_count,
         })
     }
 
     /// Filter files that need indexing based on modification time
     async fn filter_modified_files(&self, files: Vec<PathBuf>) -> Result<Vec<PathBuf>> {
-        let existing_mtimes = self.storage.get_file_mtimes().await?;
+        let existing_mtimes = self.storage.file_mtimes().await?;
 
         Ok(files
             .into_iter()
@@ -204,68 +384,48 @@ impl ParallelIndexer {
             .collect())
     }
 
-    /// Read files in parallel using spawn_blocking
+    /// Read a batch of files in parallel using spawn_blocking
     async fn read_files_parallel(
         &self,
         files: Vec<PathBuf>,
         progress: &ProgressBar,
     ) -> Result<Vec<FileContent>> {
-        let batch_size = self.config.indexer.file_batch_size;
-        let mut all_contents = Vec::new();
-        let processed = Arc::new(AtomicUsize::new(0));
-
-        for batch in files.chunks(batch_size) {
-            let batch = batch.to_vec();
-            let error_collector = self.error_collector.clone();
-            let processed_clone = processed.clone();
-
-            let contents = tokio::task::spawn_blocking(move || {
-                batch
-                    .par_iter()
-                    .filter_map(|path| {
-                        match fs::read_to_string(path) {
-                            Ok(content) => {
-                                let mtime = get_file_mtime(path).unwrap_or(0);
-                                processed_clone.fetch_add(1, Ordering::Relaxed);
-                                Some(FileContent {
-                                    path: path.clone(),
-                                    content,
-                                    mtime,
-                                })
-                            }
-                            Err(e) => {
-                                error_collector.record(
-                                    path.clone(),
-                                    e.into(),
-                                    ProcessingStage::FileRead,
-                                );
-                                processed_clone.fetch_add(1, Ordering::Relaxed);
-                                None
-                            }
-                        }
-                    })
-                    .collect::<Vec<_>>()
-            })
-            .await?;
+        let error_collector = self.error_collector.clone();
+        let count = files.len();
 
-            all_contents.extend(contents);
-            progress.set_position(processed.load(Ordering::Relaxed) as u64);
-        }
+        let contents = tokio::task::spawn_blocking(move || {
+            files
+                .par_iter()
+                .filter_map(|path| match fs::read_to_string(path) {
+                    Ok(content) => {
+                        let mtime = get_file_mtime(path).unwrap_or(0);
+                        Some(FileContent {
+                            path: path.clone(),
+                            content,
+                            mtime,
+                        })
+                    }
+                    Err(e) => {
+                        error_collector.record(path.clone(), e.into(), ProcessingStage::FileRead);
+                        None
+                    }
+                })
+                .collect::<Vec<_>>()
+        })
+        .await?;
 
-        Ok(all_contents)
+        progress.inc(count as u64);
+        Ok(contents)
     }
 
     /// Chunk files in parallel
-    async fn chunk_files_parallel(
-        &self,
-        files: Vec<FileContent>,
-        progress: &ProgressBar,
-    ) -> Result<Vec<RawChunk>> {
+    async fn chunk_files_parallel(&self, files: Vec<FileContent>) -> Result<Vec<RawChunk>> {
         let line_chunker = self.line_chunker.clone();
         let ast_chunker = self.ast_chunker.clone();
         let error_collector = self.error_collector.clone();
-
-        progress.set_message("Chunking files...");
+        let flags = self.flags.clone();
+        let header_meta = self.header_meta.clone();
+        let import_context = self.config.indexer.import_context;
 
         let result = tokio::task::spawn_blocking(move || {
             files
@@ -284,10 +444,17 @@ impl ParallelIndexer {
                             Vec::new()
                         }
                     }) {
-                        Ok(chunks) => {
+                        Ok(mut chunks) => {
+                            for chunk in &mut chunks {
+                                flags.tag(chunk);
+                            }
+                            header_meta.tag(&file.content, &mut chunks);
+                            let imports =
+                                chunk_import_contexts(&file.content, &chunks, import_context);
                             chunks
                                 .into_par_iter()
-                                .map(|chunk| RawChunk {
+                                .zip(imports)
+                                .map(|(chunk, imports)| RawChunk {
                                     content: chunk.content,
                                     file_path: file.path.to_string_lossy().to_string(),
                                     start_line: chunk.start_line,
@@ -295,11 +462,15 @@ impl ParallelIndexer {
                                     language: chunk.language,
                                     mtime: file.mtime,
                                     file_header: file_header.clone(),
+                                    imports,
                                     semantic_kind: chunk.semantic_kind.map(|k| k.as_str().to_string()),
                                     symbol_name: chunk.name,
                                     signature: chunk.signature,
+                                    doc: chunk.doc,
                                     parent: chunk.parent,
                                     visibility: None, // TODO: Extract from AST
+                                    qualified_name: chunk.qualified_name,
+                                    tags: chunk.tags,
                                 })
                                 .collect::<Vec<_>>()
                         }
@@ -320,54 +491,131 @@ impl ParallelIndexer {
         Ok(result)
     }
 
-    /// Generate embeddings in batches
-    async fn generate_embeddings_batch(
+    /// Embed a batch of chunks and assemble them into indexed chunks with
+    /// their final IDs
+    async fn embed_chunks(
         &self,
-        chunks: &[RawChunk],
-        progress: &ProgressBar,
-    ) -> Result<Vec<Vec<f32>>> {
+        raw_chunks: Vec<RawChunk>,
+        known: Option<&HashMap<u64, Vec<f32>>>,
+        branch: Option<&str>,
+    ) -> Result<Vec<IndexedChunk>> {
+        let embeddings = self.generate_embeddings_reusing(&raw_chunks, known).await?;
+        let mut indexed_chunks = self
+            .assemble_chunks_parallel(raw_chunks, embeddings)
+            .await?;
+        if let Some(branch) = branch {
+            for chunk in &mut indexed_chunks {
+                chunk.branch = Some(branch.to_string());
+            }
+        }
+        assign_ids(&mut indexed_chunks, self.ids.as_ref());
+        Ok(indexed_chunks)
+    }
+
+    /// Generate embeddings in batches
+    async fn generate_embeddings_batch(&self, chunks: &[RawChunk]) -> Result<Vec<Vec<f32>>> {
         let batch_size = self.config.embeddings.batch_size;
         let mut all_embeddings = Vec::new();
-        let total_chunks = chunks.len();
-        let mut processed = 0;
 
-        // Collect all chunk content
-        let contents: Vec<String> = chunks.iter().map(|c| c.content.clone()).collect();
+        // Collect all chunk content (optionally without comments), after
+        // a header giving the chunk's context and before its imports
+        let embed_comments = self.config.indexer.embed_comments;
+        let context_headers = self.config.indexer.context_headers;
+        let contents: Vec<String> = chunks
+            .iter()
+            .map(|c| {
+                let text = embedding_text(&c.content, c.language.as_deref(), embed_comments);
+                let text = match &c.imports {
+                    Some(imports) => with_import_context(&text, imports),
+                    None => text,
+                };
+                if !context_headers {
+                    return text;
+                }
+                let path = Path::new(&c.file_path);
+                let relative_path = path
+                    .strip_prefix(&self.root)
+                    .unwrap_or(path)
+                    .to_string_lossy();
+                let context = ChunkContext {
+                    file_path: &relative_path,
+                    name: c.symbol_name.as_deref(),
+                    qualified_name: c.qualified_name.as_deref(),
+                    parent: c.parent.as_deref(),
+                    signature: c.signature.as_deref(),
+                };
+                with_context_header(&context, &c.content, &text)
+            })
+            .collect();
 
         // Process in batches using the async embed method to avoid runtime nesting
         for batch in contents.chunks(batch_size * 10) {
             let batch_vec: Vec<String> = batch.to_vec();
-            match self.embedder.embed_async(&batch_vec).await {
-                Ok(embeddings) => {
-                    processed += embeddings.len();
-                    progress.set_position(processed as u64);
-                    progress.set_message(format!(
-                        "Generating embeddings ({}/{})",
-                        processed, total_chunks
-                    ));
-                    all_embeddings.extend(embeddings);
-                }
-                Err(e) => {
-                    error!("Failed to generate embeddings for batch: {}", e);
-                    // Generate zero embeddings as fallback using the embedder's dimension
-                    let fallback_count = batch.len();
-                    let dimension = self.embedder.embedding_dimension();
-                    for _ in batch {
-                        all_embeddings.push(vec![0.0; dimension]);
-                    }
-                    processed += fallback_count;
-                    progress.set_position(processed as u64);
-                    progress.set_message(format!(
-                        "Generating embeddings ({}/{})",
-                        processed, total_chunks
-                    ));
-                }
+            let embeddings = self
+                .embedder
+                .embed_async(&batch_vec)
+                .await
+                .context("Failed to generate embeddings for batch")?;
+            // A fallback provider's vectors belong in its own index
+            let provider = self.embedder.provider();
+            if provider != self.provider {
+                anyhow::bail!(
+                    "The {} embedding provider failed and embeddings fell back to {}, \
+                     whose vectors are kept in a separate index; index again to build it",
+                    self.provider.as_str(),
+                    provider.as_str()
+                );
             }
+            all_embeddings.extend(embeddings);
         }
 
         Ok(all_embeddings)
     }
 
+    /// Generate embeddings, reusing the `known` vectors of chunks with
+    /// identical content and embedding each remaining content once
+    async fn generate_embeddings_reusing(
+        &self,
+        chunks: &[RawChunk],
+        known: Option<&HashMap<u64, Vec<f32>>>,
+    ) -> Result<Vec<Vec<f32>>> {
+        let hashes: Vec<u64> = chunks
+            .iter()
+            .map(|c| content_hash(&c.content, c.language.as_deref()))
+            .collect();
+
+        let mut seen = HashSet::new();
+        let missing: Vec<(u64, RawChunk)> = chunks
+            .iter()
+            .zip(&hashes)
+            .filter(|(_, hash)| !known.is_some_and(|k| k.contains_key(hash)) && seen.insert(**hash))
+            .map(|(chunk, hash)| (*hash, chunk.clone()))
+            .collect();
+        if missing.len() < chunks.len() {
+            info!(
+                "Reusing embeddings for {} chunks, embedding {} new chunks",
+                chunks.len() - missing.len(),
+                missing.len()
+            );
+        }
+
+        let (missing_hashes, missing): (Vec<u64>, Vec<RawChunk>) = missing.into_iter().unzip();
+        let fresh: HashMap<u64, Vec<f32>> = missing_hashes
+            .into_iter()
+            .zip(self.generate_embeddings_batch(&missing).await?)
+            .collect();
+        Ok(hashes
+            .iter()
+            .map(|hash| {
+                known
+                    .and_then(|k| k.get(hash))
+                    .or_else(|| fresh.get(hash))
+                    .cloned()
+                    .unwrap_or_default()
+            })
+            .collect())
+    }
+
     /// Assemble chunks with embeddings in parallel
     async fn assemble_chunks_parallel(
         &self,
@@ -387,7 +635,8 @@ impl ParallelIndexer {
                 .into_par_iter()
                 .zip(embeddings.into_par_iter())
                 .map(|(chunk, embedding)| IndexedChunk {
-                    id: uuid::Uuid::new_v4().to_string(),
+                    // Assigned once the branch is known
+                    id: String::new(),
                     content: chunk.content.clone(),
                     file_path: chunk.file_path,
                     start_line: chunk.start_line,
@@ -400,8 +649,13 @@ impl ParallelIndexer {
                     semantic_kind: chunk.semantic_kind,
                     symbol_name: chunk.symbol_name,
                     signature: chunk.signature,
+                    doc: chunk.doc,
                     parent: chunk.parent,
                     visibility: chunk.visibility,
+                    qualified_name: chunk.qualified_name,
+                    tags: chunk.tags,
+                    branch: None,
+                    duplicate_of: None,
                 })
                 .collect::<Vec<_>>()
         })
@@ -410,39 +664,61 @@ impl ParallelIndexer {
         Ok(result)
     }
 
-    /// Store chunks with backpressure control
+    /// Store chunks with backpressure control.
+    ///
+    /// Existing chunks of a file are deleted before its first new chunks are
+    /// stored; `replaced_files` tracks the files already handled, since the
+    /// chunks of one file can arrive in several batches.
     async fn store_chunks_with_backpressure(
         &self,
         chunks: Vec<IndexedChunk>,
+        replaced_files: &mut HashSet<String>,
         progress: &ProgressBar,
     ) -> Result<()> {
         let batch_size = self.config.embeddings.batch_size * 10;
-        let total_chunks = chunks.len();
-        let mut stored = 0;
 
         // First, delete existing chunks for modified files
-        let files: Vec<PathBuf> = chunks
-            .iter()
-            .map(|c| PathBuf::from(&c.file_path))
-            .collect::<std::collections::HashSet<_>>()
-            .into_iter()
-            .collect();
-
-        for file in &files {
-            self.storage.delete_by_file(file).await?;
+        for chunk in &chunks {
+            if replaced_files.insert(chunk.file_path.clone()) {
+                self.storage
+                    .delete_file(&PathBuf::from(&chunk.file_path))
+                    .await?;
+            }
         }
 
         // Store in batches
-        for batch in chunks.chunks(batch_size) {
+        for batch in chunks.chunks(batch_size.max(1)) {
             let _permit = self.semaphore.acquire().await?;
 
             self.storage
-                .insert_chunks(batch.to_vec())
+                .upsert(batch.to_vec())
                 .await
                 .context("Failed to insert chunk batch")?;
 
-            stored += batch.len();
-            progress.set_position((stored * 100 / total_chunks) as u64);
+            progress.inc(batch.len() as u64);
+        }
+
+        let fields = enabled_fields(&self.config.indexer);
+        if !fields.is_empty() || self.summarizer.is_some() {
+            let mut field_vectors = embed_fields(
+                &self.embedder,
+                &chunks,
+                &fields,
+                self.config.embeddings.batch_size,
+            )
+            .await?;
+            if let Some(summarizer) = &self.summarizer {
+                let vectors = summarizer
+                    .embed(&self.embedder, &chunks, self.config.embeddings.batch_size)
+                    .await?;
+                field_vectors.push((VectorField::Summary, vectors));
+            }
+            for (field, vectors) in field_vectors {
+                self.storage
+                    .upsert_field_vectors(field, vectors)
+                    .await
+                    .with_context(|| format!("Failed to insert {} vectors", field.as_str()))?;
+            }
         }
 
         Ok(())
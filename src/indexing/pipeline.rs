@@ -22,17 +22,24 @@ pub struct RawChunk {
     pub language: Option<String>,
     pub mtime: i64,
     pub file_header: String,
+    /// Import statements appended to the embedding input, not stored
+    pub imports: Option<String>,
     // Symbol metadata
     pub semantic_kind: Option<String>,
     pub symbol_name: Option<String>,
     pub signature: Option<String>,
+    pub doc: Option<String>,
     pub parent: Option<String>,
     pub visibility: Option<String>,
+    pub qualified_name: Option<String>,
+    pub tags: Vec<String>,
 }
 
 /// Result of processing a batch of files
 #[derive(Debug, Default)]
 pub struct ProcessingResult {
+    /// Indexed chunks; left empty by `ParallelIndexer`, which streams chunks
+    /// to storage instead of keeping them all in memory
     pub successful: Vec<IndexedChunk>,
     pub errors: Vec<FileError>,
     pub files_processed: usize,
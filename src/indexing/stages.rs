@@ -0,0 +1,141 @@
+//! Bounded channels between indexing stages
+//!
+//! Indexing runs as a pipeline of stages (read → chunk → embed → store),
+//! each a loop that takes batches from a bounded channel and sends its
+//! output to the next one. When a stage falls behind, its input channel
+//! fills up and the stage before it waits on `send`, so a slow embedder
+//! throttles reading and chunking instead of letting their output pile up
+//! in memory.
+//!
+//! Between two stages at most `capacity + 2` batches exist at once: the
+//! channel's buffer, one batch waiting to be sent and one being processed.
+//! Channel capacities are set by `[indexer.pipeline]`.
+
+use anyhow::Result;
+use std::future::Future;
+use tokio::sync::mpsc::{self, Receiver, Sender};
+
+/// Bounded channel between two stages; a capacity of 0 is treated as 1
+pub fn channel<T>(capacity: usize) -> (Sender<T>, Receiver<T>) {
+    mpsc::channel(capacity.max(1))
+}
+
+/// Run `stage` on every batch from `input`, sending the results to `output`.
+///
+/// Returns once `input` is closed and drained, or early if the receiving
+/// stage has gone away. The first error from `stage` stops the loop.
+pub async fn run_stage<I, O, F, Fut>(
+    mut input: Receiver<I>,
+    output: Sender<O>,
+    mut stage: F,
+) -> Result<()>
+where
+    F: FnMut(I) -> Fut,
+    Fut: Future<Output = Result<O>>,
+{
+    while let Some(batch) = input.recv().await {
+        let result = stage(batch).await?;
+        if output.send(result).await.is_err() {
+            break;
+        }
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::atomic::{AtomicUsize, Ordering};
+    use std::time::Duration;
+
+    /// Batches created by the source and not yet finished by the sink
+    #[derive(Default)]
+    struct InFlight {
+        current: AtomicUsize,
+        max: AtomicUsize,
+    }
+
+    impl InFlight {
+        fn created(&self) {
+            let current = self.current.fetch_add(1, Ordering::SeqCst) + 1;
+            self.max.fetch_max(current, Ordering::SeqCst);
+        }
+
+        fn finished(&self) {
+            self.current.fetch_sub(1, Ordering::SeqCst);
+        }
+    }
+
+    #[tokio::test]
+    async fn test_slow_stage_bounds_batches_in_memory() {
+        const BATCHES: usize = 60;
+        let (chunk_capacity, embed_capacity) = (2, 3);
+        let in_flight = InFlight::default();
+        let stored = AtomicUsize::new(0);
+
+        let (files_tx, files_rx) = channel::<Vec<u8>>(chunk_capacity);
+        let (chunks_tx, mut chunks_rx) = channel::<Vec<u8>>(embed_capacity);
+
+        let read = async {
+            for i in 0..BATCHES {
+                in_flight.created();
+                if files_tx.send(vec![i as u8; 1024]).await.is_err() {
+                    break;
+                }
+            }
+            drop(files_tx);
+            Ok::<_, anyhow::Error>(())
+        };
+        let chunk = run_stage(files_rx, chunks_tx, |batch| async move { Ok(batch) });
+        // The slow embedder, which also stores
+        let embed = async {
+            while let Some(batch) = chunks_rx.recv().await {
+                tokio::time::sleep(Duration::from_millis(2)).await;
+                assert_eq!(batch.len(), 1024);
+                stored.fetch_add(1, Ordering::SeqCst);
+                in_flight.finished();
+            }
+            Ok(())
+        };
+        tokio::try_join!(read, chunk, embed).unwrap();
+
+        assert_eq!(stored.load(Ordering::SeqCst), BATCHES);
+        // One batch waiting in the reader, one in the chunker, one in the
+        // embedder, plus the two channel buffers
+        let bound = chunk_capacity + embed_capacity + 3;
+        let max = in_flight.max.load(Ordering::SeqCst);
+        assert!(max <= bound, "{} batches in memory, bound {}", max, bound);
+    }
+
+    #[tokio::test]
+    async fn test_stage_error_stops_pipeline() {
+        let (files_tx, files_rx) = channel::<usize>(1);
+        let (chunks_tx, mut chunks_rx) = channel::<usize>(0);
+        let sent = AtomicUsize::new(0);
+
+        let read = async {
+            for i in 0..100 {
+                if files_tx.send(i).await.is_err() {
+                    break;
+                }
+                sent.fetch_add(1, Ordering::SeqCst);
+            }
+            drop(files_tx);
+            Ok(())
+        };
+        let chunk = run_stage(files_rx, chunks_tx, |i| async move {
+            if i == 3 {
+                anyhow::bail!("cannot chunk batch {}", i);
+            }
+            Ok(i)
+        });
+        let store = async {
+            while chunks_rx.recv().await.is_some() {}
+            Ok(())
+        };
+
+        let err = tokio::try_join!(read, chunk, store).unwrap_err();
+        assert_eq!(err.to_string(), "cannot chunk batch 3");
+        assert!(sent.load(Ordering::SeqCst) < 100);
+    }
+}
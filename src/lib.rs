@@ -8,9 +8,12 @@ pub mod indexing;
 pub mod logging;
 pub mod mcp;
 pub mod metrics;
+pub mod offline;
+pub mod profile;
 pub mod project_detection;
 pub mod registry;
 pub mod search;
+pub mod seed;
 pub mod storage;
 pub mod symbol;
 pub mod watcher;
@@ -2,7 +2,7 @@ use anyhow::Result;
 use clap::Parser;
 use std::path::PathBuf;
 
-use coderag::cli::{Cli, Commands, ProjectsCommand};
+use coderag::cli::{Cli, Commands, GraphCommand, ProjectsCommand};
 use coderag::config::Config;
 use coderag::logging::init_logging;
 use coderag::metrics;
@@ -27,28 +27,102 @@ async fn main() -> Result<()> {
 
     let cli = Cli::parse();
 
+    if let Some(profile) = &cli.profile {
+        coderag::profile::select(coderag::profile::Profile::from_name(profile)?);
+    }
+    if let Some(seed) = cli.seed {
+        coderag::seed::select(seed);
+    }
+    if cli.offline {
+        coderag::offline::enable();
+    }
+    if coderag::offline::is_enabled() {
+        // Refuse to start rather than fail on first use
+        coderag::offline::check_config(&config)?;
+        tracing::info!("Offline mode enabled");
+    }
+
     match cli.command {
         Commands::Init { force } => {
             coderag::commands::init::run(force).await?;
         }
-        Commands::Index { force } => {
-            coderag::commands::index::run(force).await?;
-        }
+        Commands::Index {
+            archive,
+            branch,
+            force,
+        } => match archive {
+            Some(archive) => coderag::commands::index::run_archive(&archive).await?,
+            None if !branch.is_empty() => coderag::commands::index::run_branches(&branch).await?,
+            None => coderag::commands::index::run(force).await?,
+        },
         Commands::Serve {
             http,
             port,
             no_auto_index,
             watch,
             debounce_ms,
+            read_only,
         } => {
-            coderag::commands::serve::run(http, port, no_auto_index, watch, debounce_ms).await?;
+            coderag::commands::serve::run(http, port, no_auto_index, watch, debounce_ms, read_only)
+                .await?;
         }
         Commands::Search {
             query,
             limit,
+            search_profile,
             no_auto_index,
+            auto_refresh,
+            no_freshness_check,
+            cluster,
+            clusters,
+            kind,
+            tag,
+            tag_key,
+            tag_value,
+            meta,
+            branch,
+            prefer_kind,
+            query_model,
+            weights,
+            field,
+            with_siblings,
+            min_score,
+            candidates,
+            verbose,
+            anchor,
+            context,
+            highlight,
+            format,
         } => {
-            coderag::commands::search::run(&query, limit, no_auto_index).await?;
+            coderag::commands::search::run(
+                &query,
+                limit,
+                search_profile.as_deref(),
+                no_auto_index,
+                auto_refresh,
+                no_freshness_check,
+                cluster || clusters.is_some(),
+                clusters,
+                kind.as_deref(),
+                tag.as_deref(),
+                tag_key.as_deref(),
+                tag_value.as_deref(),
+                &meta,
+                branch.as_deref(),
+                &prefer_kind,
+                query_model.as_deref(),
+                weights.as_deref(),
+                field.as_deref(),
+                with_siblings,
+                min_score,
+                candidates,
+                verbose,
+                anchor.as_deref(),
+                context,
+                highlight,
+                &format,
+            )
+            .await?;
         }
         Commands::Watch { debounce_ms } => {
             coderag::commands::watch::run(debounce_ms).await?;
@@ -73,12 +147,58 @@ async fn main() -> Result<()> {
                 coderag::commands::projects::status().await?;
             }
         },
-        Commands::Web { port } => {
-            coderag::commands::web::run(port).await?;
+        Commands::Web { port, read_only } => {
+            coderag::commands::web::run(port, read_only).await?;
         }
         Commands::Status => {
             coderag::commands::status::run().await?;
         }
+        Commands::Symbols {
+            lang,
+            kind,
+            sort,
+            limit,
+            offset,
+            json,
+        } => {
+            coderag::commands::symbols::run(lang, kind, &sort, limit, offset, json).await?;
+        }
+        Commands::Diff { branch, json } => {
+            coderag::commands::diff::run(&branch, json).await?;
+        }
+        Commands::Export { out } => {
+            coderag::commands::export::run(&out).await?;
+        }
+        Commands::Flags { flag, json } => {
+            coderag::commands::flags::run(flag.as_deref(), json).await?;
+        }
+        Commands::Counterparts { symbol, rule, json } => {
+            coderag::commands::counterparts::run(&symbol, rule.as_deref(), json).await?;
+        }
+        Commands::Untested { path, lang, json } => {
+            coderag::commands::untested::run(path, lang, json).await?;
+        }
+        Commands::Graph { command } => match command {
+            GraphCommand::Export {
+                format,
+                path,
+                lang,
+                edge_kind,
+                output,
+            } => {
+                coderag::commands::graph::export(
+                    &format,
+                    path,
+                    lang,
+                    &edge_kind,
+                    output.as_deref(),
+                )
+                .await?;
+            }
+        },
+        Commands::Validate { fix, json } => {
+            coderag::commands::validate::run(fix, json).await?;
+        }
         Commands::Migrate {
             keep_local,
             move_files,
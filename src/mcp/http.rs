@@ -13,8 +13,9 @@ use tokio_util::sync::CancellationToken;
 use tracing::{error, info};
 
 use crate::search::SearchEngine;
-use crate::storage::Storage;
+use crate::storage::VectorStore;
 use crate::symbol::SymbolIndex;
+use crate::web::security::{self, SecurityPolicy};
 
 use super::server::CodeRagServer;
 
@@ -27,6 +28,8 @@ pub struct HttpTransportConfig {
     pub sse_path: String,
     /// Path for the POST message endpoint
     pub post_path: String,
+    /// Authentication, body size and result limits
+    pub security: SecurityPolicy,
 }
 
 impl Default for HttpTransportConfig {
@@ -35,6 +38,7 @@ impl Default for HttpTransportConfig {
             bind_addr: "127.0.0.1:3000".parse().expect("valid default address"),
             sse_path: "/sse".to_string(),
             post_path: "/message".to_string(),
+            security: SecurityPolicy::default(),
         }
     }
 }
@@ -55,13 +59,19 @@ impl HttpTransportConfig {
             ..Default::default()
         }
     }
+
+    /// Use the given security policy
+    pub fn with_security(mut self, security: SecurityPolicy) -> Self {
+        self.security = security;
+        self
+    }
 }
 
 /// HTTP/SSE transport for MCP server
 pub struct HttpTransport {
     config: HttpTransportConfig,
     search_engine: Arc<SearchEngine>,
-    storage: Arc<Storage>,
+    storage: Arc<dyn VectorStore>,
     symbol_index: Arc<SymbolIndex>,
     root_path: PathBuf,
 }
@@ -71,7 +81,7 @@ impl HttpTransport {
     pub fn new(
         config: HttpTransportConfig,
         search_engine: Arc<SearchEngine>,
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
         symbol_index: Arc<SymbolIndex>,
         root_path: PathBuf,
     ) -> Self {
@@ -107,6 +117,10 @@ impl HttpTransport {
         info!("  Message endpoint: {}", self.config.post_path);
 
         let (sse_server, router) = SseServer::new(sse_config);
+        let router = security::protect(router, Arc::new(self.config.security.clone()));
+        if self.config.security.requires_auth() {
+            info!("  Bearer token authentication enabled");
+        }
         let listener = tokio::net::TcpListener::bind(sse_server.config.bind).await?;
 
         let server_ct = sse_server.config.ct.child_token();
@@ -127,10 +141,12 @@ impl HttpTransport {
         let storage = self.storage.clone();
         let symbol_index = self.symbol_index.clone();
         let root_path = self.root_path.clone();
+        let max_limit = self.config.security.max_limit;
 
         // Register service factory with the SSE server
         let service_ct = sse_server.with_service(move || {
             CodeRagServer::new(search_engine.clone(), storage.clone(), symbol_index.clone(), root_path.clone())
+                .with_max_limit(max_limit)
         });
 
         info!("MCP HTTP/SSE server is ready and accepting connections");
@@ -156,14 +172,16 @@ impl HttpTransport {
 /// * `storage` - The storage instance
 /// * `root_path` - The project root path
 /// * `port` - The port to bind to
+/// * `security` - Authentication, body size and result limits
 pub async fn run_http_server(
     search_engine: Arc<SearchEngine>,
-    storage: Arc<Storage>,
+    storage: Arc<dyn VectorStore>,
     symbol_index: Arc<SymbolIndex>,
     root_path: PathBuf,
     port: u16,
+    security: SecurityPolicy,
 ) -> Result<()> {
-    let config = HttpTransportConfig::with_port(port);
+    let config = HttpTransportConfig::with_port(port).with_security(security);
     let transport = HttpTransport::new(config, search_engine, storage, symbol_index, root_path);
     transport.run().await
 }
@@ -12,9 +12,10 @@ use serde::Deserialize;
 use std::path::PathBuf;
 use std::sync::Arc;
 
+use crate::config::SecurityConfig;
 use crate::search::traits::Search;
 use crate::search::SearchEngine;
-use crate::storage::Storage;
+use crate::storage::VectorStore;
 use crate::symbol::{
     FindReferencesRequest, FindSymbolRequest, ListSymbolsRequest, SymbolIndex, SymbolSearcher,
 };
@@ -66,11 +67,12 @@ pub struct GetFileRequest {
 #[derive(Clone)]
 pub struct CodeRagServer {
     search_engine: Arc<SearchEngine>,
-    storage: Arc<Storage>,
+    storage: Arc<dyn VectorStore>,
     #[allow(dead_code)]
     symbol_index: Arc<SymbolIndex>,
     symbol_searcher: Arc<SymbolSearcher>,
     root_path: PathBuf,
+    max_limit: usize,
     tool_router: ToolRouter<Self>,
 }
 
@@ -79,7 +81,7 @@ impl CodeRagServer {
     /// Create a new CodeRAG MCP server
     pub fn new(
         search_engine: Arc<SearchEngine>,
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
         symbol_index: Arc<SymbolIndex>,
         root_path: PathBuf,
     ) -> Self {
@@ -95,10 +97,17 @@ impl CodeRagServer {
             symbol_index,
             symbol_searcher,
             root_path,
+            max_limit: SecurityConfig::default().max_limit,
             tool_router: Self::tool_router(),
         }
     }
 
+    /// Cap the number of results a single tool call may request
+    pub fn with_max_limit(mut self, max_limit: usize) -> Self {
+        self.max_limit = max_limit.max(1);
+        self
+    }
+
     /// Search for relevant code snippets using semantic search
     #[tool(
         name = "search",
@@ -108,7 +117,7 @@ impl CodeRagServer {
         &self,
         Parameters(req): Parameters<SearchRequest>,
     ) -> Result<CallToolResult, McpError> {
-        let limit = req.limit.unwrap_or(10);
+        let limit = req.limit.unwrap_or(10).clamp(1, self.max_limit);
 
         let results = self
             .search_engine
@@ -138,6 +147,9 @@ impl CodeRagServer {
                     "**File:** {}:{}-{}\n",
                     result.file_path, result.start_line, result.end_line
                 ));
+                if !result.duplicates.is_empty() {
+                    output.push_str(&format!("**Also in:** {}\n", result.duplicates.join(", ")));
+                }
 
                 // Include file header if available
                 if let Some(ref header) = result.file_header {
@@ -247,8 +259,9 @@ impl CodeRagServer {
     )]
     async fn find_symbol(
         &self,
-        Parameters(req): Parameters<FindSymbolRequest>,
+        Parameters(mut req): Parameters<FindSymbolRequest>,
     ) -> Result<CallToolResult, McpError> {
+        req.limit = req.limit.map(|l| l.clamp(1, self.max_limit));
         let response = self
             .symbol_searcher
             .find_symbol(req)
@@ -267,6 +280,9 @@ impl CodeRagServer {
                 symbol.kind.to_uppercase(),
                 symbol.name
             ));
+            if let Some(qualified_name) = symbol.qualified_name {
+                output.push_str(&format!("**Qualified name:** `{}`\n", qualified_name));
+            }
             output.push_str(&format!("**File:** {}\n", symbol.file_path));
             output.push_str(&format!("**Lines:** {}-{}\n", symbol.start_line, symbol.end_line));
 
@@ -292,8 +308,9 @@ impl CodeRagServer {
     )]
     async fn list_symbols(
         &self,
-        Parameters(req): Parameters<ListSymbolsRequest>,
+        Parameters(mut req): Parameters<ListSymbolsRequest>,
     ) -> Result<CallToolResult, McpError> {
+        req.limit = Some(req.limit.unwrap_or(self.max_limit).clamp(1, self.max_limit));
         let response = self
             .symbol_searcher
             .list_symbols(req)
@@ -308,7 +325,11 @@ impl CodeRagServer {
             output.push_str("# All Symbols\n\n");
         }
 
-        output.push_str(&format!("**Total:** {} symbols\n\n", response.total_symbols));
+        output.push_str(&format!("**Total:** {} symbols", response.total_symbols));
+        if response.symbols.len() < response.total_symbols {
+            output.push_str(&format!(" (showing first {})", response.symbols.len()));
+        }
+        output.push_str("\n\n");
 
         if let Some(ref by_kind) = response.by_kind {
             // Group by kind
@@ -347,8 +368,9 @@ impl CodeRagServer {
     )]
     async fn find_references(
         &self,
-        Parameters(req): Parameters<FindReferencesRequest>,
+        Parameters(mut req): Parameters<FindReferencesRequest>,
     ) -> Result<CallToolResult, McpError> {
+        req.limit = req.limit.map(|l| l.clamp(1, self.max_limit));
         let symbol_name = req.symbol_name.clone();
         let response = self
             .symbol_searcher
@@ -398,7 +420,7 @@ impl CodeRagServer {
     }
 
     /// Get a reference to the storage
-    pub fn storage(&self) -> &Arc<Storage> {
+    pub fn storage(&self) -> &Arc<dyn VectorStore> {
         &self.storage
     }
 
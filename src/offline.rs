@@ -0,0 +1,226 @@
+//! Offline ("safe") mode.
+//!
+//! Offline mode guarantees that no code leaves the machine: every backend
+//! that would send chunks or queries over the network refuses to start. It is
+//! enabled by the global `--offline` flag, the `CODERAG_OFFLINE` environment
+//! variable, or `offline = true` in a config file. Once enabled it stays
+//! enabled for the rest of the process, so a later config without the
+//! setting cannot turn it off.
+
+use std::sync::atomic::{AtomicBool, Ordering};
+use thiserror::Error;
+
+use crate::config::{Config, EmbeddingProvider, EmbeddingsConfig};
+
+/// Environment variable that enables offline mode when set to a truthy value
+pub const OFFLINE_ENV_VAR: &str = "CODERAG_OFFLINE";
+
+static ENABLED: AtomicBool = AtomicBool::new(false);
+
+/// Errors raised when offline mode forbids a configured backend.
+#[derive(Error, Debug, PartialEq, Eq)]
+pub enum OfflineError {
+    /// A backend that sends data over the network is configured.
+    #[error(
+        "Offline mode forbids the network {component} backend '{backend}'. \
+         Configure a local backend or run without --offline"
+    )]
+    NetworkBackend {
+        /// Kind of component (e.g. "embeddings")
+        component: &'static str,
+        /// Name of the configured backend
+        backend: String,
+    },
+}
+
+/// Enable offline mode for the rest of the process.
+pub fn enable() {
+    ENABLED.store(true, Ordering::SeqCst);
+}
+
+/// Whether offline mode is enabled, by [`enable`] or the environment.
+pub fn is_enabled() -> bool {
+    ENABLED.load(Ordering::SeqCst) || env_enabled()
+}
+
+fn env_enabled() -> bool {
+    std::env::var(OFFLINE_ENV_VAR)
+        .map(|v| matches!(v.to_lowercase().as_str(), "1" | "true" | "yes" | "on"))
+        .unwrap_or(false)
+}
+
+/// Check that an embeddings configuration only uses local backends.
+pub fn check_embeddings(config: &EmbeddingsConfig) -> Result<(), OfflineError> {
+    // A server on this machine keeps code local
+    let (backend, local) = match config.provider {
+        EmbeddingProvider::FastEmbed | EmbeddingProvider::Onnx => return Ok(()),
+        EmbeddingProvider::OpenAI => (
+            "openai",
+            config
+                .openai_base_url
+                .as_deref()
+                .is_some_and(crate::embeddings::is_loopback),
+        ),
+        EmbeddingProvider::Ollama => (
+            "ollama",
+            crate::embeddings::is_loopback(&config.ollama_host),
+        ),
+        EmbeddingProvider::Azure => ("azure", false),
+        EmbeddingProvider::Bedrock => ("bedrock", false),
+        EmbeddingProvider::Gemini => ("gemini", false),
+        EmbeddingProvider::Voyage => ("voyage", false),
+        EmbeddingProvider::Cohere => ("cohere", false),
+        EmbeddingProvider::Tei => ("tei", crate::embeddings::is_loopback(&config.tei_url)),
+    };
+    if local {
+        return Ok(());
+    }
+    Err(OfflineError::NetworkBackend {
+        component: "embeddings",
+        backend: backend.to_string(),
+    })
+}
+
+/// Check that a configuration only uses local backends.
+pub fn check_config(config: &Config) -> Result<(), OfflineError> {
+    check_embeddings(&config.embeddings)?;
+    if config.indexer.summaries.enabled {
+        return Err(OfflineError::NetworkBackend {
+            component: "summaries",
+            backend: config.indexer.summaries.model.clone(),
+        });
+    }
+    if let Some(webhook) = &config.watcher.webhook {
+        if !crate::embeddings::is_loopback(&webhook.url) {
+            return Err(OfflineError::NetworkBackend {
+                component: "webhook",
+                backend: webhook.url.clone(),
+            });
+        }
+    }
+    Ok(())
+}
+
+/// Refuse to create a network backend while offline mode is enabled.
+///
+/// Called by network backends before they open any connection.
+pub fn ensure_network_allowed(component: &'static str, backend: &str) -> Result<(), OfflineError> {
+    if is_enabled() {
+        return Err(OfflineError::NetworkBackend {
+            component,
+            backend: backend.to_string(),
+        });
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::WebhookConfig;
+
+    #[test]
+    fn test_openai_embedder_is_rejected() {
+        let mut config = Config::default();
+        config.embeddings.provider = EmbeddingProvider::OpenAI;
+
+        let err = check_config(&config).unwrap_err();
+        assert_eq!(
+            err,
+            OfflineError::NetworkBackend {
+                component: "embeddings",
+                backend: "openai".to_string(),
+            }
+        );
+        assert!(err.to_string().contains("Offline mode forbids"));
+    }
+
+    #[test]
+    fn test_llm_summaries_are_rejected() {
+        let mut config = Config::default();
+        config.indexer.summaries.enabled = true;
+
+        let err = check_config(&config).unwrap_err();
+        assert_eq!(
+            err,
+            OfflineError::NetworkBackend {
+                component: "summaries",
+                backend: "gpt-4o-mini".to_string(),
+            }
+        );
+    }
+
+    #[test]
+    fn test_local_openai_compatible_server_is_allowed() {
+        let mut config = Config::default();
+        config.embeddings.provider = EmbeddingProvider::OpenAI;
+        config.embeddings.openai_base_url = Some("http://127.0.0.1:8000/v1".to_string());
+        assert!(check_config(&config).is_ok());
+
+        config.embeddings.openai_base_url = Some("https://llm-proxy.example.com/v1".to_string());
+        assert!(check_config(&config).is_err());
+    }
+
+    #[test]
+    fn test_only_local_ollama_is_allowed() {
+        let mut config = Config::default();
+        config.embeddings.provider = EmbeddingProvider::Ollama;
+        assert!(check_config(&config).is_ok());
+
+        config.embeddings.ollama_host = "http://gpu-box.internal:11434".to_string();
+        assert_eq!(
+            check_config(&config).unwrap_err(),
+            OfflineError::NetworkBackend {
+                component: "embeddings",
+                backend: "ollama".to_string(),
+            }
+        );
+    }
+
+    #[test]
+    fn test_only_local_tei_is_allowed() {
+        let mut config = Config::default();
+        config.embeddings.provider = EmbeddingProvider::Tei;
+        assert!(check_config(&config).is_ok());
+
+        config.embeddings.tei_url = "https://abc123.endpoints.huggingface.cloud".to_string();
+        assert_eq!(
+            check_config(&config).unwrap_err(),
+            OfflineError::NetworkBackend {
+                component: "embeddings",
+                backend: "tei".to_string(),
+            }
+        );
+    }
+
+    #[test]
+    fn test_only_local_webhook_is_allowed() {
+        let mut config = Config::default();
+        config.watcher.webhook = Some(WebhookConfig {
+            url: "http://127.0.0.1:9000/hook".to_string(),
+            retries: 0,
+            retry_delay_ms: 0,
+            timeout_ms: 1000,
+        });
+        assert!(check_config(&config).is_ok());
+
+        let url = "https://ci.example.com/hooks/coderag".to_string();
+        config.watcher.webhook.as_mut().unwrap().url = url.clone();
+        assert_eq!(
+            check_config(&config).unwrap_err(),
+            OfflineError::NetworkBackend {
+                component: "webhook",
+                backend: url,
+            }
+        );
+    }
+
+    #[test]
+    fn test_local_embedder_is_allowed() {
+        assert!(check_config(&Config::default()).is_ok());
+
+        let mut config = Config::default();
+        config.embeddings.provider = EmbeddingProvider::Onnx;
+        assert!(check_config(&config).is_ok());
+    }
+}
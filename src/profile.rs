@@ -0,0 +1,202 @@
+//! Embedding throughput presets.
+//!
+//! How fast indexing runs, and what it costs with a paid embedding API,
+//! depends on several settings that only work well together: the embedding
+//! batch size, how many files are chunked at once, retries and the client
+//! side rate limit. A profile sets all of them at once:
+//!
+//! | Setting                         | `cheap` | `balanced` | `fast` |
+//! |---------------------------------|---------|------------|--------|
+//! | `embeddings.batch_size`         | 16      | 32         | 128    |
+//! | `embeddings.max_retries`        | 2       | 3          | 5      |
+//! | `embeddings.requests_per_minute`| 500     | 3500       | 10000  |
+//! | `indexer.max_concurrent_files`  | 8       | 50         | 100    |
+//! | `indexer.file_batch_size`       | 50      | 100        | 500    |
+//! | `indexer.parallel_threads`      | 2       | auto       | auto   |
+//!
+//! `balanced` matches the built-in defaults. A profile is selected by the
+//! global `--profile` flag, the `CODERAG_PROFILE` environment variable or
+//! `profile = "..."` in a config file, in that order. Values written in the
+//! config file override the profile's, so a profile is a starting point
+//! rather than a lock.
+
+use anyhow::{anyhow, Result};
+use std::sync::Mutex;
+use toml::{Table, Value};
+
+/// Environment variable that selects a profile
+pub const PROFILE_ENV_VAR: &str = "CODERAG_PROFILE";
+
+static SELECTED: Mutex<Option<Profile>> = Mutex::new(None);
+
+/// A named set of embedding and concurrency settings
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Profile {
+    /// Few concurrent requests and small batches; slow but gentle on API
+    /// quotas and shared machines
+    Cheap,
+    /// The defaults
+    Balanced,
+    /// Large batches and high concurrency, for fast machines and generous
+    /// API limits
+    Fast,
+}
+
+impl Profile {
+    /// Parse profile from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_lowercase().as_str() {
+            "cheap" => Some(Self::Cheap),
+            "balanced" => Some(Self::Balanced),
+            "fast" => Some(Self::Fast),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Cheap => "cheap",
+            Self::Balanced => "balanced",
+            Self::Fast => "fast",
+        }
+    }
+
+    /// Parse a profile name, listing the valid names on error
+    pub fn from_name(name: &str) -> Result<Self> {
+        Self::parse(name)
+            .ok_or_else(|| anyhow!("Unknown profile '{}'. Use cheap, balanced or fast", name))
+    }
+
+    /// The settings of this profile, as config file sections
+    pub fn settings(&self) -> Table {
+        let (batch_size, max_retries, requests_per_minute) = match self {
+            Self::Cheap => (16, 2, 500),
+            Self::Balanced => (32, 3, 3500),
+            Self::Fast => (128, 5, 10000),
+        };
+        let (max_concurrent_files, file_batch_size, parallel_threads) = match self {
+            Self::Cheap => (8, 50, Some(2)),
+            Self::Balanced => (50, 100, None),
+            Self::Fast => (100, 500, None),
+        };
+
+        let mut embeddings = Table::new();
+        embeddings.insert("batch_size".into(), Value::Integer(batch_size));
+        embeddings.insert("max_retries".into(), Value::Integer(max_retries));
+        embeddings.insert(
+            "requests_per_minute".into(),
+            Value::Integer(requests_per_minute),
+        );
+
+        let mut indexer = Table::new();
+        indexer.insert(
+            "max_concurrent_files".into(),
+            Value::Integer(max_concurrent_files),
+        );
+        indexer.insert("file_batch_size".into(), Value::Integer(file_batch_size));
+        if let Some(threads) = parallel_threads {
+            indexer.insert("parallel_threads".into(), Value::Integer(threads));
+        }
+
+        let mut settings = Table::new();
+        settings.insert("embeddings".into(), Value::Table(embeddings));
+        settings.insert("indexer".into(), Value::Table(indexer));
+        settings
+    }
+}
+
+/// Select a profile for the rest of the process, overriding the
+/// environment and config files.
+pub fn select(profile: Profile) {
+    *SELECTED.lock().unwrap_or_else(|e| e.into_inner()) = Some(profile);
+}
+
+/// The profile selected by [`select`] or the environment, if any
+pub fn selected() -> Result<Option<Profile>> {
+    if let Some(profile) = *SELECTED.lock().unwrap_or_else(|e| e.into_inner()) {
+        return Ok(Some(profile));
+    }
+    match std::env::var(PROFILE_ENV_VAR) {
+        Ok(name) if !name.trim().is_empty() => Profile::from_name(&name).map(Some),
+        _ => Ok(None),
+    }
+}
+
+/// Layer a parsed config file over the settings of its profile.
+///
+/// The profile is the selected one, else the file's `profile` key. Without
+/// either, the file is returned unchanged.
+pub fn apply(config: Table) -> Result<Table> {
+    let profile = match selected()? {
+        Some(profile) => profile,
+        None => match config.get("profile").and_then(Value::as_str) {
+            Some(name) => Profile::from_name(name)?,
+            None => return Ok(config),
+        },
+    };
+    Ok(merge(profile.settings(), config))
+}
+
+/// `overrides` on top of `base`, merging tables key by key
+fn merge(mut base: Table, overrides: Table) -> Table {
+    for (key, value) in overrides {
+        let merged = match (base.remove(&key), value) {
+            (Some(Value::Table(base)), Value::Table(value)) => Value::Table(merge(base, value)),
+            (_, value) => value,
+        };
+        base.insert(key, merged);
+    }
+    base
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn get(table: &Table, section: &str, key: &str) -> Option<i64> {
+        table.get(section)?.get(key)?.as_integer()
+    }
+
+    #[test]
+    fn test_parse() {
+        assert_eq!(Profile::parse("Fast"), Some(Profile::Fast));
+        assert_eq!(Profile::parse("cheap"), Some(Profile::Cheap));
+        assert_eq!(Profile::parse("turbo"), None);
+        assert!(Profile::from_name("turbo").is_err());
+        assert_eq!(Profile::Balanced.as_str(), "balanced");
+    }
+
+    #[test]
+    fn test_config_values_override_profile() {
+        let config: Table = toml::from_str(
+            r#"
+            [embeddings]
+            batch_size = 64
+            model = "bge-small-en-v1.5"
+            "#,
+        )
+        .unwrap();
+        let merged = merge(Profile::Fast.settings(), config);
+
+        assert_eq!(get(&merged, "embeddings", "batch_size"), Some(64));
+        assert_eq!(get(&merged, "embeddings", "max_retries"), Some(5));
+        assert_eq!(get(&merged, "indexer", "file_batch_size"), Some(500));
+        assert_eq!(
+            merged["embeddings"]["model"].as_str(),
+            Some("bge-small-en-v1.5")
+        );
+    }
+
+    #[test]
+    fn test_only_cheap_pins_threads() {
+        assert_eq!(
+            get(&Profile::Cheap.settings(), "indexer", "parallel_threads"),
+            Some(2)
+        );
+        assert_eq!(
+            get(&Profile::Fast.settings(), "indexer", "parallel_threads"),
+            None
+        );
+    }
+}
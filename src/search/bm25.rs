@@ -2,18 +2,29 @@
 //!
 //! This module provides BM25-based full-text search for code chunks
 //! using the Tantivy search engine library.
+//!
+//! Besides the content, each document stores the chunk's language and its
+//! identifiers grouped by token role, which `[search.term_boosts]` rules
+//! weight at query time (see [`super::term_boosts`]).
 
 use anyhow::{Context, Result};
 use async_trait::async_trait;
 use std::path::Path;
 use std::sync::RwLock;
 use tantivy::collector::TopDocs;
-use tantivy::query::QueryParser;
-use tantivy::schema::{Field, Schema, Value as _, STORED, TEXT};
-use tantivy::{doc, Index, IndexReader, IndexWriter, ReloadPolicy, TantivyDocument};
+use tantivy::query::{
+    AllQuery, BooleanQuery, BoostQuery, ConstScoreQuery, Occur, Query, QueryParser, TermQuery,
+};
+use tantivy::schema::{Field, IndexRecordOption, Schema, Value as _, STORED, STRING, TEXT};
+use tantivy::{doc, Index, IndexReader, IndexWriter, ReloadPolicy, TantivyDocument, Term};
 use tracing::{debug, info, warn};
 
+use super::provenance::{record_source, RetrievalPath};
+use super::synonyms::SynonymMap;
+use super::term_boosts::TermBoosts;
 use super::traits::Search;
+use crate::indexer::ast_chunker::parser_pool::ParserPool;
+use crate::indexer::ast_chunker::token_roles::{token_roles, TokenRole};
 use crate::storage::{IndexedChunk, SearchResult};
 
 /// BM25 index directory name within .coderag/
@@ -30,6 +41,7 @@ const FIELD_CONTENT: &str = "content";
 const FIELD_FILE_PATH: &str = "file_path";
 const FIELD_START_LINE: &str = "start_line";
 const FIELD_END_LINE: &str = "end_line";
+const FIELD_LANGUAGE: &str = "language";
 
 /// BM25 search index schema.
 ///
@@ -42,6 +54,9 @@ pub struct Bm25Schema {
     file_path: Field,
     start_line: Field,
     end_line: Field,
+    language: Field,
+    /// Identifiers by role, in [`TokenRole::ALL`] order
+    roles: Vec<Field>,
 }
 
 impl Bm25Schema {
@@ -54,6 +69,11 @@ impl Bm25Schema {
         let file_path = schema_builder.add_text_field(FIELD_FILE_PATH, TEXT | STORED);
         let start_line = schema_builder.add_text_field(FIELD_START_LINE, STORED);
         let end_line = schema_builder.add_text_field(FIELD_END_LINE, STORED);
+        let language = schema_builder.add_text_field(FIELD_LANGUAGE, STRING);
+        let roles = TokenRole::ALL
+            .iter()
+            .map(|role| schema_builder.add_text_field(&role_field_name(*role), TEXT))
+            .collect();
 
         let schema = schema_builder.build();
 
@@ -64,6 +84,8 @@ impl Bm25Schema {
             file_path,
             start_line,
             end_line,
+            language,
+            roles,
         }
     }
 
@@ -71,6 +93,17 @@ impl Bm25Schema {
     pub fn schema(&self) -> &Schema {
         &self.schema
     }
+
+    /// Field holding the identifiers of one role
+    fn role(&self, role: TokenRole) -> Field {
+        // `ALL` lists the roles in declaration order
+        self.roles[role as usize]
+    }
+}
+
+/// Index field name for identifiers of `role`
+fn role_field_name(role: TokenRole) -> String {
+    format!("role_{}", role.as_str())
 }
 
 impl Default for Bm25Schema {
@@ -99,10 +132,30 @@ impl Bm25Index {
         let index_path = path.join(BM25_INDEX_DIR);
         let schema = Bm25Schema::new();
 
-        let index = if index_path.exists() {
+        let existing = if index_path.exists() {
             info!("Opening existing BM25 index at {:?}", index_path);
-            Index::open_in_dir(&index_path)
-                .with_context(|| format!("Failed to open BM25 index at {:?}", index_path))?
+            let index = Index::open_in_dir(&index_path)
+                .with_context(|| format!("Failed to open BM25 index at {:?}", index_path))?;
+            // Indexes written before token roles lack their fields; they are
+            // rebuilt from the vector store on the next index run
+            if index.schema() == *schema.schema() {
+                Some(index)
+            } else {
+                warn!(
+                    "BM25 index at {:?} has an outdated schema; recreating it empty. Run 'coderag index' to refill it",
+                    index_path
+                );
+                std::fs::remove_dir_all(&index_path).with_context(|| {
+                    format!("Failed to remove outdated BM25 index {:?}", index_path)
+                })?;
+                None
+            }
+        } else {
+            None
+        };
+
+        let index = if let Some(index) = existing {
+            index
         } else {
             info!("Creating new BM25 index at {:?}", index_path);
             std::fs::create_dir_all(&index_path)
@@ -135,15 +188,27 @@ impl Bm25Index {
     ///
     /// # Arguments
     /// * `chunks` - Vector of indexed chunks to add
+    ///
+    /// Chunks in a language with a parser also get their identifiers
+    /// indexed by token role.
     pub fn add_chunks(&mut self, chunks: &[IndexedChunk]) -> Result<()> {
+        let mut parsers = ParserPool::new();
         for chunk in chunks {
-            self.writer.add_document(doc!(
+            let mut document = doc!(
                 self.schema.id => chunk.id.as_str(),
                 self.schema.content => chunk.content.as_str(),
                 self.schema.file_path => chunk.file_path.as_str(),
                 self.schema.start_line => chunk.start_line.to_string(),
                 self.schema.end_line => chunk.end_line.to_string(),
-            ))?;
+            );
+            if let Some(language) = chunk.language.as_deref() {
+                document.add_text(self.schema.language, language.to_lowercase());
+                let grammar = ParserPool::grammar(Path::new(&chunk.file_path), language);
+                for (role, text) in token_roles(&mut parsers, grammar, &chunk.content) {
+                    document.add_text(self.schema.role(role), text);
+                }
+            }
+            self.writer.add_document(document)?;
         }
 
         debug!("Added {} chunks to BM25 index", chunks.len());
@@ -197,20 +262,46 @@ impl Bm25Index {
     /// # Returns
     /// A vector of search results sorted by BM25 score
     pub fn search(&self, query: &str, limit: usize) -> Result<Vec<SearchResult>> {
+        self.search_boosted(query, limit, &TermBoosts::default())
+    }
+
+    /// Search, adding the weighted scores of identifier matches for each
+    /// term boost rule.
+    pub fn search_boosted(
+        &self,
+        query: &str,
+        limit: usize,
+        boosts: &TermBoosts,
+    ) -> Result<Vec<SearchResult>> {
         let searcher = self.reader.searcher();
 
-        // Parse query against content field
-        let query_parser = QueryParser::for_index(&self.index, vec![self.schema.content]);
-        let parsed_query = match query_parser.parse_query(query) {
-            Ok(q) => q,
-            Err(e) => {
-                warn!("Failed to parse query '{}': {}", query, e);
-                // Try to escape the query and retry
-                let escaped = query.replace(['(', ')', '[', ']', '{', '}', '"', '\'', ':', '\\', '/', '^', '~', '*', '?', '!', '+', '-'], " ");
-                query_parser
-                    .parse_query(&escaped)
-                    .with_context(|| format!("Failed to parse escaped query: {}", escaped))?
+        let content_query = self.parse_query(self.schema.content, query)?;
+        let parsed_query: Box<dyn Query> = if boosts.is_empty() {
+            content_query
+        } else {
+            let mut clauses = vec![(Occur::Should, content_query)];
+            for rule in boosts.rules() {
+                // Restrict the rule to its language without scoring the match
+                let language = TermQuery::new(
+                    Term::from_field_text(self.schema.language, &rule.language),
+                    IndexRecordOption::Basic,
+                );
+                let matches = BooleanQuery::new(vec![
+                    (
+                        Occur::Must,
+                        Box::new(ConstScoreQuery::new(Box::new(language), 0.0)) as Box<dyn Query>,
+                    ),
+                    (
+                        Occur::Must,
+                        self.parse_query(self.schema.role(rule.role), query)?,
+                    ),
+                ]);
+                clauses.push((
+                    Occur::Should,
+                    Box::new(BoostQuery::new(Box::new(matches), rule.boost)),
+                ));
             }
+            Box::new(BooleanQuery::new(clauses))
         };
 
         let top_docs = searcher
@@ -255,6 +346,9 @@ impl Bm25Index {
                 end_line,
                 score,
                 file_header: None, // BM25 doesn't store file headers
+                semantic_kind: None,
+                sources: Vec::new(),
+                duplicates: Vec::new(),
             });
         }
 
@@ -262,6 +356,47 @@ impl Bm25Index {
         Ok(results)
     }
 
+    /// Parse a query against one field, escaping query syntax if the query
+    /// does not parse as written
+    fn parse_query(&self, field: Field, query: &str) -> Result<Box<dyn Query>> {
+        let query_parser = QueryParser::for_index(&self.index, vec![field]);
+        match query_parser.parse_query(query) {
+            Ok(q) => Ok(q),
+            Err(e) => {
+                warn!("Failed to parse query '{}': {}", query, e);
+                // Try to escape the query and retry
+                let escaped = query.replace(['(', ')', '[', ']', '{', '}', '"', '\'', ':', '\\', '/', '^', '~', '*', '?', '!', '+', '-'], " ");
+                query_parser
+                    .parse_query(&escaped)
+                    .with_context(|| format!("Failed to parse escaped query: {}", escaped))
+            }
+        }
+    }
+
+    /// Ids of all documents in the index, in index order.
+    pub fn document_ids(&self) -> Result<Vec<String>> {
+        let searcher = self.reader.searcher();
+        let count = searcher.num_docs() as usize;
+        if count == 0 {
+            return Ok(Vec::new());
+        }
+
+        let top_docs = searcher
+            .search(&AllQuery, &TopDocs::with_limit(count))
+            .with_context(|| "Failed to list BM25 documents")?;
+
+        let mut ids = Vec::with_capacity(top_docs.len());
+        for (_, doc_address) in top_docs {
+            let retrieved_doc: TantivyDocument = searcher
+                .doc(doc_address)
+                .with_context(|| "Failed to retrieve document")?;
+            if let Some(id) = retrieved_doc.get_first(self.schema.id).and_then(|v| v.as_str()) {
+                ids.push(id.to_string());
+            }
+        }
+        Ok(ids)
+    }
+
     /// Check if the index exists at the given path.
     pub fn exists(path: &Path) -> bool {
         path.join(BM25_INDEX_DIR).exists()
@@ -273,6 +408,10 @@ impl Bm25Index {
 /// Thread-safe wrapper around `Bm25Index` for use in async contexts.
 pub struct Bm25Search {
     index: RwLock<Bm25Index>,
+    /// Acronyms and synonyms appended to queries
+    synonyms: SynonymMap,
+    /// Per-language weights for identifier matches by role
+    term_boosts: TermBoosts,
 }
 
 impl Bm25Search {
@@ -284,9 +423,29 @@ impl Bm25Search {
         let index = Bm25Index::new(path)?;
         Ok(Self {
             index: RwLock::new(index),
+            synonyms: SynonymMap::default(),
+            term_boosts: TermBoosts::default(),
         })
     }
 
+    /// Expand queries with the given acronym/synonym map.
+    pub fn with_synonyms(mut self, synonyms: SynonymMap) -> Self {
+        self.synonyms = synonyms;
+        self
+    }
+
+    /// Weight identifier matches by role with the given rules.
+    pub fn with_term_boosts(mut self, term_boosts: TermBoosts) -> Self {
+        self.term_boosts = term_boosts;
+        self
+    }
+
+    /// The keyword query searched for `query`, with its acronyms and
+    /// synonyms appended.
+    pub fn expand(&self, query: &str) -> String {
+        self.synonyms.expand(query)
+    }
+
     /// Get mutable access to the index for updates.
     ///
     /// # Panics
@@ -327,7 +486,9 @@ impl Search for Bm25Search {
             // Clear the poison and return the guard
             poisoned.into_inner()
         });
-        let results = index.search(query, limit)?;
+        let expanded = self.synonyms.expand(query);
+        let mut results = index.search_boosted(&expanded, limit, &self.term_boosts)?;
+        record_source(&mut results, RetrievalPath::Lexical, query, &expanded);
         let elapsed = start.elapsed();
         info!(
             search_type = "bm25",
@@ -363,8 +524,13 @@ mod tests {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         }
     }
 
@@ -415,4 +581,94 @@ mod tests {
         let results = index.search("test_function", 10).unwrap();
         assert_eq!(results.len(), 0);
     }
+
+    #[test]
+    fn test_bm25_document_ids() {
+        let dir = tempdir().unwrap();
+        let mut index = Bm25Index::new(dir.path()).unwrap();
+        assert!(index.document_ids().unwrap().is_empty());
+
+        let chunks = vec![
+            create_test_chunk("1", "fn one() {}", "src/a.rs"),
+            create_test_chunk("2", "fn two() {}", "src/b.rs"),
+        ];
+        index.add_chunks(&chunks).unwrap();
+        index.commit().unwrap();
+
+        let mut ids = index.document_ids().unwrap();
+        ids.sort();
+        assert_eq!(ids, vec!["1", "2"]);
+    }
+
+    #[tokio::test]
+    async fn test_bm25_acronym_query_resolves_via_synonyms() {
+        let dir = tempdir().unwrap();
+        let search = Bm25Search::new(dir.path()).unwrap();
+        {
+            let mut index = search.index_mut();
+            index
+                .add_chunks(&[
+                    create_test_chunk(
+                        "1",
+                        "func (p *WorkerPool) Shutdown() { close(p.taskQueue) }",
+                        "pool.go",
+                    ),
+                    create_test_chunk("2", "func (s *Server) Stop() {}", "server.go"),
+                ])
+                .unwrap();
+            index.commit().unwrap();
+        }
+
+        // The acronym does not occur in the code
+        assert!(search.search("wp", 10).await.unwrap().is_empty());
+
+        let synonyms = SynonymMap::new(
+            &[("wp".to_string(), vec!["worker pool".to_string()])]
+                .into_iter()
+                .collect(),
+        );
+        let search = search.with_synonyms(synonyms);
+
+        let results = search.search("wp", 10).await.unwrap();
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].file_path, "pool.go");
+    }
+
+    #[tokio::test]
+    async fn test_type_name_boost_outranks_local_variable() {
+        let dir = tempdir().unwrap();
+        let search = Bm25Search::new(dir.path()).unwrap();
+        {
+            let mut index = search.index_mut();
+            let chunks = [
+                create_test_chunk("1", "type Pool struct {\n\tsize int\n}", "pool.go"),
+                create_test_chunk(
+                    "2",
+                    "func drain() {\n\tpool := acquire()\n\tpool.Close()\n}",
+                    "drain.go",
+                ),
+            ]
+            .map(|chunk| IndexedChunk {
+                language: Some("go".to_string()),
+                ..chunk
+            });
+            index.add_chunks(&chunks).unwrap();
+            index.commit().unwrap();
+        }
+
+        // Without boosts the local, used twice, matches best
+        let results = search.search("pool", 10).await.unwrap();
+        assert_eq!(results[0].file_path, "drain.go");
+
+        let rules = [(
+            "go".to_string(),
+            [("type".to_string(), 2.0)].into_iter().collect(),
+        )];
+        let boosts = TermBoosts::new(&rules.into_iter().collect()).unwrap();
+        let search = search.with_term_boosts(boosts);
+
+        let results = search.search("pool", 10).await.unwrap();
+        assert_eq!(results.len(), 2);
+        assert_eq!(results[0].file_path, "pool.go");
+    }
 }
@@ -0,0 +1,268 @@
+//! Candidate pool sizing
+//!
+//! Re-ranking by field weights or kind preference, and hybrid fusion, can
+//! only reorder the candidates retrieved first. The size of that pool trades
+//! latency against quality: a larger pool lets a match from further down
+//! reach the top, at the cost of fetching, and for field weights embedding,
+//! more chunks. The pool is sized independently of the number of results:
+//!
+//! - `fixed` (default): `search --candidates N` or `search.candidates`, else
+//!   `max(50, 5 × limit)`.
+//! - `dynamic`: start from that size and double it until the last candidate
+//!   scores at least `search.candidate_score_gap` below the `limit`-th one,
+//!   so anything further down is unlikely to be re-ranked into the results,
+//!   or until the index has no more matches.
+//!
+//! Either way the pool holds at least `limit` candidates and otherwise at
+//! most `search.max_candidates`.
+
+use anyhow::Result;
+use serde::{Deserialize, Serialize};
+use std::future::Future;
+use tracing::debug;
+
+use super::SearchResult;
+use crate::config::SearchConfig;
+
+/// Smallest default pool
+pub const MIN_CANDIDATES: usize = 50;
+
+/// Default pool size per requested result
+pub const CANDIDATES_PER_RESULT: usize = 5;
+
+/// Default upper bound on the pool
+pub const DEFAULT_MAX_CANDIDATES: usize = 500;
+
+/// Default score gap that stops a dynamic pool from growing
+pub const DEFAULT_SCORE_GAP: f32 = 0.1;
+
+/// How the candidate pool is sized
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum CandidateStrategy {
+    /// One retrieval of the configured size
+    #[default]
+    Fixed,
+    /// Grow the pool until the scores leave a gap below the results
+    Dynamic,
+}
+
+impl CandidateStrategy {
+    /// Parse strategy from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "fixed" => Some(Self::Fixed),
+            "dynamic" => Some(Self::Dynamic),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Fixed => "fixed",
+            Self::Dynamic => "dynamic",
+        }
+    }
+}
+
+/// Size of the candidate pool retrieved before re-ranking
+#[derive(Debug, Clone, PartialEq)]
+pub struct CandidatePool {
+    /// Explicit size; `max(50, 5 × limit)` when unset
+    size: Option<usize>,
+    strategy: CandidateStrategy,
+    max: usize,
+    score_gap: f32,
+}
+
+impl Default for CandidatePool {
+    fn default() -> Self {
+        Self {
+            size: None,
+            strategy: CandidateStrategy::Fixed,
+            max: DEFAULT_MAX_CANDIDATES,
+            score_gap: DEFAULT_SCORE_GAP,
+        }
+    }
+}
+
+impl CandidatePool {
+    /// Pool configured by `[search]`
+    pub fn from_config(config: &SearchConfig) -> Self {
+        Self {
+            size: config.candidates,
+            strategy: config.candidate_strategy,
+            max: config.max_candidates,
+            score_gap: config.candidate_score_gap,
+        }
+    }
+
+    /// A fixed pool of exactly `size` candidates
+    pub fn exact(size: usize) -> Self {
+        Self {
+            size: Some(size),
+            max: size,
+            ..Default::default()
+        }
+    }
+
+    /// Override the configured size (`search --candidates`)
+    pub fn with_size(mut self, size: Option<usize>) -> Self {
+        if size.is_some() {
+            self.size = size;
+        }
+        self
+    }
+
+    /// Size of the first retrieval for `limit` results
+    pub fn initial(&self, limit: usize) -> usize {
+        let size = self
+            .size
+            .unwrap_or_else(|| MIN_CANDIDATES.max(CANDIDATES_PER_RESULT * limit));
+        size.min(self.max).max(limit)
+    }
+
+    /// Size of the next retrieval when a dynamic pool of `size` candidates,
+    /// sorted by descending score, is not enough for `limit` results.
+    fn grow(&self, size: usize, candidates: &[SearchResult], limit: usize) -> Option<usize> {
+        // A short pool holds every match there is
+        if self.strategy != CandidateStrategy::Dynamic
+            || candidates.len() < size
+            || size >= self.max
+        {
+            return None;
+        }
+        let (Some(kth), Some(last)) = (candidates.get(limit.saturating_sub(1)), candidates.last())
+        else {
+            return None;
+        };
+        if kth.score - last.score >= self.score_gap {
+            return None;
+        }
+        Some((size * 2).min(self.max))
+    }
+
+    /// Retrieve the pool for `limit` results, calling `fetch` with each
+    /// size to retrieve; `fetch` returns candidates by descending score.
+    pub async fn fetch<F, Fut>(&self, limit: usize, mut fetch: F) -> Result<Vec<SearchResult>>
+    where
+        F: FnMut(usize) -> Fut,
+        Fut: Future<Output = Result<Vec<SearchResult>>>,
+    {
+        let mut size = self.initial(limit);
+        loop {
+            let candidates = fetch(size).await?;
+            match self.grow(size, &candidates, limit) {
+                Some(next) => {
+                    debug!("Growing candidate pool from {} to {}", size, next);
+                    size = next;
+                }
+                None => return Ok(candidates),
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::Mutex;
+
+    /// `available` candidates with scores falling by `step`
+    fn candidates(available: usize, step: f32, size: usize) -> Vec<SearchResult> {
+        (0..available.min(size))
+            .map(|i| SearchResult {
+                content: String::new(),
+                file_path: format!("src/{}.rs", i),
+                start_line: 1,
+                end_line: 1,
+                score: 1.0 - step * i as f32,
+                file_header: None,
+                semantic_kind: None,
+                sources: Vec::new(),
+                duplicates: Vec::new(),
+            })
+            .collect()
+    }
+
+    /// Sizes requested by `pool` for `limit` results
+    async fn requested(
+        pool: &CandidatePool,
+        limit: usize,
+        available: usize,
+        step: f32,
+    ) -> Vec<usize> {
+        let sizes = Mutex::new(Vec::new());
+        let pool_len = pool
+            .fetch(limit, |size| {
+                sizes.lock().unwrap().push(size);
+                async move { Ok(candidates(available, step, size)) }
+            })
+            .await
+            .unwrap()
+            .len();
+        let sizes = sizes.into_inner().unwrap();
+        assert_eq!(pool_len, available.min(*sizes.last().unwrap()));
+        sizes
+    }
+
+    #[tokio::test]
+    async fn test_fixed_pool_size_is_honored_and_bounded() {
+        let pool = CandidatePool::default();
+        // max(50, 5 × limit)
+        assert_eq!(requested(&pool, 5, 1000, 0.0).await, vec![50]);
+        assert_eq!(requested(&pool, 20, 1000, 0.0).await, vec![100]);
+        assert_eq!(pool.initial(200), DEFAULT_MAX_CANDIDATES);
+
+        let pool = CandidatePool::default().with_size(Some(30));
+        assert_eq!(requested(&pool, 10, 1000, 0.0).await, vec![30]);
+        // Never fewer candidates than results
+        assert_eq!(pool.initial(40), 40);
+        assert_eq!(pool.clone().with_size(None), pool);
+        assert_eq!(CandidatePool::exact(7).initial(7), 7);
+
+        let pool = CandidatePool {
+            max: 100,
+            ..Default::default()
+        }
+        .with_size(Some(5000));
+        assert_eq!(requested(&pool, 10, 1000, 0.0).await, vec![100]);
+    }
+
+    #[tokio::test]
+    async fn test_dynamic_pool_grows_until_score_gap() {
+        let pool = CandidatePool {
+            strategy: CandidateStrategy::Dynamic,
+            max: 400,
+            score_gap: 0.1,
+            ..Default::default()
+        };
+
+        // Scores fall by 0.001 per rank: the 100th candidate is only 0.09
+        // below the 10th, the 200th well past the gap
+        assert_eq!(requested(&pool, 10, 1000, 0.001).await, vec![50, 100, 200]);
+        // Flat scores never leave a gap; growth stops at the bound
+        assert_eq!(
+            requested(&pool, 10, 1000, 0.0).await,
+            vec![50, 100, 200, 400]
+        );
+        // A short pool holds every match
+        assert_eq!(requested(&pool, 10, 70, 0.0).await, vec![50, 100]);
+        // A steep fall needs no growth
+        assert_eq!(requested(&pool, 10, 1000, 0.01).await, vec![50]);
+    }
+
+    #[test]
+    fn test_strategy_names() {
+        assert_eq!(
+            CandidateStrategy::parse("Dynamic"),
+            Some(CandidateStrategy::Dynamic)
+        );
+        assert_eq!(
+            CandidateStrategy::parse(CandidateStrategy::Fixed.as_str()),
+            Some(CandidateStrategy::Fixed)
+        );
+        assert_eq!(CandidateStrategy::parse("adaptive"), None);
+    }
+}
@@ -0,0 +1,346 @@
+//! Topic clustering of search results.
+//!
+//! Broad queries often match several unrelated ways of doing the same thing.
+//! Clustering groups the retrieved candidates by embedding proximity so they
+//! can be browsed by subtopic instead of as one flat ranked list.
+//!
+//! Clustering is agglomerative with average linkage over cosine similarity.
+//! When no cluster count is requested, the count with the best silhouette
+//! score is chosen.
+
+use std::collections::HashMap;
+
+use crate::storage::SearchResult;
+
+/// Upper bound on the automatically chosen number of clusters
+const MAX_AUTO_CLUSTERS: usize = 8;
+
+/// Number of keywords in a cluster label
+const LABEL_TERMS: usize = 3;
+
+/// A group of related search results
+#[derive(Debug, Clone)]
+pub struct ResultCluster {
+    /// Short keyword label describing the cluster
+    pub label: String,
+    /// The member closest to the rest of the cluster
+    pub representative: SearchResult,
+    /// All members, in their original ranking order (including the representative)
+    pub members: Vec<SearchResult>,
+}
+
+/// Group search results into topic clusters.
+///
+/// `vectors[i]` is the embedding of `results[i]`. With `count` of `None`
+/// the number of clusters is chosen automatically. Clusters are ordered by
+/// the rank of their best result.
+pub fn cluster_results(
+    results: Vec<SearchResult>,
+    vectors: &[Vec<f32>],
+    count: Option<usize>,
+) -> Vec<ResultCluster> {
+    debug_assert_eq!(results.len(), vectors.len());
+    let groups = cluster_vectors(vectors, count);
+    let similarity = similarity_matrix(vectors);
+
+    groups
+        .into_iter()
+        .map(|members| {
+            let representative = medoid(&members, &similarity);
+            let contents: Vec<&str> = members
+                .iter()
+                .map(|&i| results[i].content.as_str())
+                .collect();
+            ResultCluster {
+                label: keyword_label(&contents),
+                representative: results[representative].clone(),
+                members: members.iter().map(|&i| results[i].clone()).collect(),
+            }
+        })
+        .collect()
+}
+
+/// Cluster vectors, returning groups of indices.
+///
+/// Each group is sorted ascending, and groups are ordered by their smallest
+/// index, so with ranked input the best-ranked cluster comes first.
+pub fn cluster_vectors(vectors: &[Vec<f32>], count: Option<usize>) -> Vec<Vec<usize>> {
+    let n = vectors.len();
+    if n == 0 {
+        return Vec::new();
+    }
+    let similarity = similarity_matrix(vectors);
+    let partitions = agglomerate(&similarity);
+
+    // partitions[k - 1] holds the partition with k clusters
+    let k = match count {
+        Some(k) => k.clamp(1, n),
+        None => (2..=MAX_AUTO_CLUSTERS.min(n - 1))
+            .map(|k| (k, silhouette(&partitions[k - 1], &similarity)))
+            .max_by(|a, b| a.1.partial_cmp(&b.1).unwrap_or(std::cmp::Ordering::Equal))
+            .map(|(k, _)| k)
+            .unwrap_or(1),
+    };
+
+    let mut groups = partitions[k - 1].clone();
+    for group in &mut groups {
+        group.sort_unstable();
+    }
+    groups.sort_by_key(|g| g[0]);
+    groups
+}
+
+/// Cosine similarity between every pair of vectors
+fn similarity_matrix(vectors: &[Vec<f32>]) -> Vec<Vec<f32>> {
+    let norms: Vec<f32> = vectors
+        .iter()
+        .map(|v| v.iter().map(|x| x * x).sum::<f32>().sqrt())
+        .collect();
+    (0..vectors.len())
+        .map(|i| {
+            (0..vectors.len())
+                .map(|j| {
+                    let dot: f32 = vectors[i].iter().zip(&vectors[j]).map(|(a, b)| a * b).sum();
+                    let denom = norms[i] * norms[j];
+                    if denom == 0.0 {
+                        0.0
+                    } else {
+                        dot / denom
+                    }
+                })
+                .collect()
+        })
+        .collect()
+}
+
+/// Average-linkage agglomerative clustering.
+///
+/// Returns the partition at every level: element `k - 1` has `k` clusters.
+fn agglomerate(similarity: &[Vec<f32>]) -> Vec<Vec<Vec<usize>>> {
+    let n = similarity.len();
+    let mut clusters: Vec<Vec<usize>> = (0..n).map(|i| vec![i]).collect();
+    let mut levels = vec![clusters.clone()];
+
+    while clusters.len() > 1 {
+        let mut best = (0, 1, f32::NEG_INFINITY);
+        for a in 0..clusters.len() {
+            for b in a + 1..clusters.len() {
+                let link = average_similarity(&clusters[a], &clusters[b], similarity);
+                if link > best.2 {
+                    best = (a, b, link);
+                }
+            }
+        }
+        let merged = clusters.remove(best.1);
+        clusters[best.0].extend(merged);
+        levels.push(clusters.clone());
+    }
+
+    levels.reverse();
+    levels
+}
+
+fn average_similarity(a: &[usize], b: &[usize], similarity: &[Vec<f32>]) -> f32 {
+    let total: f32 = a
+        .iter()
+        .flat_map(|&i| b.iter().map(move |&j| similarity[i][j]))
+        .sum();
+    total / (a.len() * b.len()) as f32
+}
+
+/// Mean silhouette score of a partition, using cosine distance
+fn silhouette(partition: &[Vec<usize>], similarity: &[Vec<f32>]) -> f32 {
+    let n: usize = partition.iter().map(Vec::len).sum();
+    let mut total = 0.0;
+
+    for (c, cluster) in partition.iter().enumerate() {
+        if cluster.len() < 2 {
+            // Singletons contribute 0 by convention
+            continue;
+        }
+        for &i in cluster {
+            let distance = |j: &usize| 1.0 - similarity[i][*j];
+            let a = cluster
+                .iter()
+                .filter(|&&j| j != i)
+                .map(distance)
+                .sum::<f32>()
+                / (cluster.len() - 1) as f32;
+            let b = partition
+                .iter()
+                .enumerate()
+                .filter(|(other, _)| *other != c)
+                .map(|(_, other)| other.iter().map(distance).sum::<f32>() / other.len() as f32)
+                .fold(f32::INFINITY, f32::min);
+            let max = a.max(b);
+            if max > 0.0 {
+                total += (b - a) / max;
+            }
+        }
+    }
+
+    total / n as f32
+}
+
+/// Index of the member with the highest total similarity to the others
+fn medoid(members: &[usize], similarity: &[Vec<f32>]) -> usize {
+    members
+        .iter()
+        .copied()
+        .max_by(|&a, &b| {
+            let score = |i: usize| members.iter().map(|&j| similarity[i][j]).sum::<f32>();
+            score(a)
+                .partial_cmp(&score(b))
+                .unwrap_or(std::cmp::Ordering::Equal)
+                // Prefer the better-ranked member on ties
+                .then(b.cmp(&a))
+        })
+        .unwrap_or(members[0])
+}
+
+/// Label a cluster with the identifiers most shared among its members
+fn keyword_label(contents: &[&str]) -> String {
+    let mut document_frequency: HashMap<String, usize> = HashMap::new();
+    for content in contents {
+        let mut seen = std::collections::HashSet::new();
+        for word in content.split(|c: char| !(c.is_alphanumeric() || c == '_')) {
+            if word.len() >= 4 && !word.chars().all(|c| c.is_ascii_digit()) {
+                let word = word.to_lowercase();
+                if !is_common_keyword(&word) && seen.insert(word.clone()) {
+                    *document_frequency.entry(word).or_default() += 1;
+                }
+            }
+        }
+    }
+
+    let mut terms: Vec<(String, usize)> = document_frequency.into_iter().collect();
+    terms.sort_by(|a, b| b.1.cmp(&a.1).then_with(|| a.0.cmp(&b.0)));
+    terms
+        .into_iter()
+        .take(LABEL_TERMS)
+        .map(|(term, _)| term)
+        .collect::<Vec<_>>()
+        .join(", ")
+}
+
+fn is_common_keyword(word: &str) -> bool {
+    matches!(
+        word,
+        "self"
+            | "return"
+            | "const"
+            | "static"
+            | "async"
+            | "await"
+            | "impl"
+            | "struct"
+            | "pub"
+            | "func"
+            | "function"
+            | "def"
+            | "class"
+            | "this"
+            | "else"
+            | "true"
+            | "false"
+            | "null"
+            | "none"
+            | "string"
+            | "import"
+            | "from"
+            | "public"
+            | "private"
+            | "void"
+            | "with"
+            | "match"
+            | "some"
+            | "while"
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn result(content: &str, score: f32) -> SearchResult {
+        SearchResult {
+            content: content.to_string(),
+            file_path: "src/lib.rs".to_string(),
+            start_line: 1,
+            end_line: 1,
+            score,
+            file_header: None,
+            semantic_kind: None,
+            sources: Vec::new(),
+            duplicates: Vec::new(),
+        }
+    }
+
+    /// Three well-separated groups of vectors, interleaved by rank
+    fn three_groups() -> Vec<Vec<f32>> {
+        vec![
+            vec![1.0, 0.0, 0.0],
+            vec![0.0, 1.0, 0.0],
+            vec![0.95, 0.05, 0.0],
+            vec![0.0, 0.0, 1.0],
+            vec![0.05, 0.9, 0.0],
+            vec![0.0, 0.1, 0.95],
+            vec![0.9, 0.0, 0.1],
+        ]
+    }
+
+    #[test]
+    fn test_auto_cluster_count_finds_distinct_groups() {
+        let groups = cluster_vectors(&three_groups(), None);
+        assert_eq!(groups, vec![vec![0, 2, 6], vec![1, 4], vec![3, 5]]);
+    }
+
+    #[test]
+    fn test_explicit_cluster_count() {
+        let vectors = three_groups();
+        assert_eq!(cluster_vectors(&vectors, Some(1)).len(), 1);
+        assert_eq!(cluster_vectors(&vectors, Some(3)).len(), 3);
+        assert_eq!(cluster_vectors(&vectors, Some(7)).len(), 7);
+        // More clusters than vectors is capped
+        assert_eq!(cluster_vectors(&vectors, Some(50)).len(), 7);
+    }
+
+    #[test]
+    fn test_small_inputs() {
+        assert!(cluster_vectors(&[], None).is_empty());
+        assert_eq!(cluster_vectors(&[vec![1.0, 0.0]], None), vec![vec![0]]);
+        assert_eq!(
+            cluster_vectors(&[vec![1.0, 0.0], vec![0.0, 1.0]], None),
+            vec![vec![0, 1]]
+        );
+    }
+
+    #[test]
+    fn test_cluster_results_representative_and_label() {
+        let results = vec![
+            result("fn parse_config(path) { read_config(path) }", 0.9),
+            result("fn send_request(client) { client.request() }", 0.8),
+            result("fn load_config(path) { parse_config(path) }", 0.7),
+            result("fn retry_request(client) { send_request(client) }", 0.6),
+        ];
+        let vectors = vec![
+            vec![1.0, 0.0],
+            vec![0.0, 1.0],
+            vec![0.9, 0.1],
+            vec![0.1, 0.9],
+        ];
+
+        let clusters = cluster_results(results, &vectors, Some(2));
+        assert_eq!(clusters.len(), 2);
+
+        assert_eq!(clusters[0].members.len(), 2);
+        assert!(clusters[0].members[0].content.contains("parse_config"));
+        assert!(clusters[0].label.contains("config"));
+        assert!(clusters[0].label.contains("path"));
+        assert!(clusters[1].label.contains("client"));
+        assert!(clusters[1]
+            .members
+            .iter()
+            .any(|m| m.content == clusters[1].representative.content));
+    }
+}
@@ -0,0 +1,422 @@
+//! Weighted multi-field scoring
+//!
+//! A chunk is stored with one vector for its whole content, which mixes what
+//! the code is documented to do with how it does it. With
+//! `indexer.embed_weighted_fields`, each chunk is split into fields and each
+//! field is embedded at index time as a field vector. For
+//! `coderag search --weights doc=0.7,body=0.3` the query is compared with the
+//! stored field vectors of each candidate, and the per-field similarities
+//! are combined with the given weights:
+//!
+//! - `doc` - comment and docstring lines
+//! - `body` - the code without those lines
+//! - `structure` - the declaration line (the signature of a function,
+//!   the header of a type)
+//!
+//! Weighting `doc` favors conceptual queries ("retry policy"), weighting
+//! `body` favors implementation queries ("exponential backoff sleep").
+//! Fields a chunk does not have are left out and the remaining weights are
+//! renormalized, so undocumented code is not penalized.
+
+use anyhow::{bail, Context, Result};
+use std::collections::{BTreeMap, HashMap};
+
+use crate::storage::{SearchResult, VectorField};
+
+/// A part of a chunk that is scored separately
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Field {
+    Doc,
+    Body,
+    Structure,
+}
+
+impl Field {
+    /// All fields, in score order
+    pub const ALL: [Field; 3] = [Field::Doc, Field::Body, Field::Structure];
+
+    /// Parse from string representation.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_lowercase().as_str() {
+            "doc" | "docs" => Some(Self::Doc),
+            "body" => Some(Self::Body),
+            "structure" | "signature" => Some(Self::Structure),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Doc => "doc",
+            Self::Body => "body",
+            Self::Structure => "structure",
+        }
+    }
+
+    /// The field vectors the field is stored as
+    pub fn vector_field(&self) -> VectorField {
+        match self {
+            Self::Doc => VectorField::Doc,
+            Self::Body => VectorField::Body,
+            Self::Structure => VectorField::Structure,
+        }
+    }
+}
+
+/// Relative weight of each field in the combined score
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct FieldWeights {
+    pub doc: f32,
+    pub body: f32,
+    pub structure: f32,
+}
+
+impl FieldWeights {
+    /// Parse a `doc=0.7,body=0.3` specification.
+    ///
+    /// Fields that are not listed get a weight of zero.
+    pub fn parse(spec: &str) -> Result<Self> {
+        let mut entries = BTreeMap::new();
+        for pair in spec.split(',').filter(|p| !p.trim().is_empty()) {
+            let (field, weight) = pair
+                .split_once('=')
+                .with_context(|| format!("Expected field=weight, got '{}'", pair.trim()))?;
+            let weight: f32 = weight
+                .trim()
+                .parse()
+                .with_context(|| format!("Invalid weight for '{}'", field.trim()))?;
+            entries.insert(field.trim().to_string(), weight);
+        }
+        Self::from_map(&entries)
+    }
+
+    /// Build weights from a `field -> weight` table, as used in the config.
+    ///
+    /// Fields that are not listed get a weight of zero.
+    pub fn from_map(entries: &BTreeMap<String, f32>) -> Result<Self> {
+        let mut weights = Self {
+            doc: 0.0,
+            body: 0.0,
+            structure: 0.0,
+        };
+        for (name, &weight) in entries {
+            let Some(field) = Field::parse(name) else {
+                bail!("Unknown field '{}' (expected doc, body or structure)", name);
+            };
+            if !weight.is_finite() || weight < 0.0 {
+                bail!("Weight for '{}' must be a non-negative number", name);
+            }
+            *weights.get_mut(field) = weight;
+        }
+        if weights.total() == 0.0 {
+            bail!("At least one field weight must be greater than zero");
+        }
+        Ok(weights)
+    }
+
+    /// Weight of one field
+    pub fn get(&self, field: Field) -> f32 {
+        match field {
+            Field::Doc => self.doc,
+            Field::Body => self.body,
+            Field::Structure => self.structure,
+        }
+    }
+
+    fn get_mut(&mut self, field: Field) -> &mut f32 {
+        match field {
+            Field::Doc => &mut self.doc,
+            Field::Body => &mut self.body,
+            Field::Structure => &mut self.structure,
+        }
+    }
+
+    fn total(&self) -> f32 {
+        self.doc + self.body + self.structure
+    }
+
+    /// Combine per-field similarities, in [`Field::ALL`] order, into one
+    /// score. Missing fields are skipped and the weights of the others
+    /// renormalized.
+    pub fn combine(&self, similarities: [Option<f32>; 3]) -> f32 {
+        let mut score = 0.0;
+        let mut total = 0.0;
+        for (field, similarity) in Field::ALL.iter().zip(similarities) {
+            if let Some(similarity) = similarity {
+                score += self.get(*field) * similarity;
+                total += self.get(*field);
+            }
+        }
+        if total == 0.0 {
+            0.0
+        } else {
+            score / total
+        }
+    }
+}
+
+/// The text of each field of a chunk, in [`Field::ALL`] order; `None` when
+/// the chunk does not have the field.
+///
+/// This is a line-based split that works across languages: lines starting
+/// with a comment marker, and triple-quoted docstrings, are documentation;
+/// the first remaining non-empty line is the structure.
+pub fn split_fields(content: &str) -> [Option<String>; 3] {
+    let mut doc = Vec::new();
+    let mut body = Vec::new();
+    let mut in_docstring = false;
+
+    for line in content.lines() {
+        let trimmed = line.trim_start();
+        let fences = trimmed.matches("\"\"\"").count() + trimmed.matches("'''").count();
+        if in_docstring {
+            doc.push(line);
+            in_docstring = fences % 2 == 0;
+        } else if fences > 0 && (trimmed.starts_with("\"\"\"") || trimmed.starts_with("'''")) {
+            doc.push(line);
+            in_docstring = fences % 2 == 1;
+        } else if is_comment_line(trimmed) {
+            doc.push(line);
+        } else {
+            body.push(line);
+        }
+    }
+
+    let structure = body
+        .iter()
+        .find(|line| !line.trim().is_empty())
+        .map(|line| line.trim().to_string());
+    let non_empty = |lines: Vec<&str>| {
+        let text = lines.join("\n");
+        (!text.trim().is_empty()).then_some(text)
+    };
+
+    [non_empty(doc), non_empty(body), structure]
+}
+
+fn is_comment_line(trimmed: &str) -> bool {
+    // Continuation lines of block comments, but not dereferences
+    let block_continuation =
+        trimmed == "*" || trimmed.starts_with("* ") || trimmed.starts_with("*/");
+    (block_continuation
+        || ["//", "#", "/*", "--", ";;"]
+            .iter()
+            .any(|marker| trimmed.starts_with(marker)))
+        // Rust attributes and C preprocessor lines are code
+        && !trimmed.starts_with("#[")
+        && !trimmed.starts_with("#!")
+        && !trimmed.starts_with("#include")
+        && !trimmed.starts_with("#define")
+}
+
+/// Cosine similarity of two vectors (0 when either is zero)
+pub fn cosine(a: &[f32], b: &[f32]) -> f32 {
+    let dot: f32 = a.iter().zip(b).map(|(x, y)| x * y).sum();
+    let norm = |v: &[f32]| v.iter().map(|x| x * x).sum::<f32>().sqrt();
+    let denom = norm(a) * norm(b);
+    if denom == 0.0 {
+        0.0
+    } else {
+        dot / denom
+    }
+}
+
+/// Similarity of each field of each of `results`, in [`Field::ALL`] order,
+/// from `matches[i]`, the results of searching the stored vectors of field
+/// `i` for at most `limit` chunks (None when the field is not searched).
+///
+/// A result the field search returned gets its score there. One it did not
+/// return although its content has the field ranked below every returned
+/// match, and gets the lowest returned score, unless the search returned
+/// fewer than `limit` chunks: then it reached every chunk with a vector of
+/// the field, and the result has none.
+pub fn field_similarities(
+    results: &[SearchResult],
+    matches: &[Option<Vec<SearchResult>>; 3],
+    limit: usize,
+) -> Vec<[Option<f32>; 3]> {
+    let scores: Vec<Option<(HashMap<(&str, usize), f32>, Option<f32>)>> = matches
+        .iter()
+        .map(|matches| {
+            matches.as_ref().map(|matches| {
+                let scores = matches
+                    .iter()
+                    .map(|m| ((m.file_path.as_str(), m.start_line), m.score))
+                    .collect();
+                let floor = (matches.len() >= limit)
+                    .then(|| matches.iter().map(|m| m.score).reduce(f32::min))
+                    .flatten();
+                (scores, floor)
+            })
+        })
+        .collect();
+
+    results
+        .iter()
+        .map(|result| {
+            let present = split_fields(&result.content).map(|text| text.is_some());
+            let key = (result.file_path.as_str(), result.start_line);
+            std::array::from_fn(|i| {
+                let (scores, floor) = scores[i].as_ref()?;
+                match scores.get(&key) {
+                    Some(&score) => Some(score),
+                    None if present[i] => *floor,
+                    None => None,
+                }
+            })
+        })
+        .collect()
+}
+
+/// Replace result scores with weighted field scores and re-rank.
+///
+/// `similarities[i]` holds the per-field similarities of `results[i]`.
+/// The sort is stable, so ties keep their original order.
+pub fn rank_by_fields(
+    results: &mut Vec<SearchResult>,
+    similarities: &[[Option<f32>; 3]],
+    weights: &FieldWeights,
+) {
+    debug_assert_eq!(results.len(), similarities.len());
+    for (result, similarities) in results.iter_mut().zip(similarities) {
+        result.score = weights.combine(*similarities);
+    }
+    results.sort_by(|a, b| {
+        b.score
+            .partial_cmp(&a.score)
+            .unwrap_or(std::cmp::Ordering::Equal)
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn result(file_path: &str) -> SearchResult {
+        SearchResult {
+            content: String::new(),
+            file_path: file_path.to_string(),
+            start_line: 1,
+            end_line: 10,
+            score: 0.5,
+            file_header: None,
+            semantic_kind: None,
+            sources: Vec::new(),
+            duplicates: Vec::new(),
+        }
+    }
+
+    #[test]
+    fn test_parse_weights() {
+        let weights = FieldWeights::parse("doc=0.7, body=0.3").unwrap();
+        assert_eq!(weights.doc, 0.7);
+        assert_eq!(weights.body, 0.3);
+        assert_eq!(weights.structure, 0.0);
+
+        assert!(FieldWeights::parse("docs=1,signature=1").is_ok());
+        assert!(FieldWeights::parse("title=1").is_err());
+        assert!(FieldWeights::parse("doc").is_err());
+        assert!(FieldWeights::parse("doc=-1").is_err());
+        assert!(FieldWeights::parse("doc=0,body=0").is_err());
+    }
+
+    #[test]
+    fn test_split_fields() {
+        let content = "/// Retry a request with exponential backoff.\n\
+                       pub fn retry(req: Request) -> Response {\n    \
+                       // sleep between attempts\n    \
+                       loop { sleep(delay); }\n\
+                       }";
+        let [doc, body, structure] = split_fields(content);
+
+        assert_eq!(
+            doc.unwrap(),
+            "/// Retry a request with exponential backoff.\n    // sleep between attempts"
+        );
+        assert!(body.unwrap().contains("loop { sleep(delay); }"));
+        assert_eq!(
+            structure.unwrap(),
+            "pub fn retry(req: Request) -> Response {"
+        );
+
+        let python = "def retry(req):\n    \"\"\"Retry with backoff.\n\n    Doubles the delay.\n    \"\"\"\n    return send(req)";
+        let [doc, body, _] = split_fields(python);
+        assert!(doc.unwrap().contains("Doubles the delay."));
+        assert!(!body.unwrap().contains("Doubles"));
+
+        let [doc, _, _] = split_fields("#[derive(Debug)]\nstruct Plain;");
+        assert!(doc.is_none());
+    }
+
+    #[test]
+    fn test_missing_fields_are_renormalized() {
+        let weights = FieldWeights::parse("doc=0.5,body=0.5").unwrap();
+        assert_eq!(weights.combine([None, Some(0.8), Some(0.1)]), 0.8);
+        assert_eq!(weights.combine([None, None, None]), 0.0);
+    }
+
+    #[test]
+    fn test_field_similarities_from_stored_vectors() {
+        let scored = |file_path: &str, score: f32| SearchResult {
+            score,
+            ..result(file_path)
+        };
+        let mut documented = result("documented.rs");
+        documented.content = "// Retries\nfn retry() {}".to_string();
+        let mut plain = result("plain.rs");
+        plain.content = "fn plain() {}".to_string();
+        let mut deep = result("deep.rs");
+        deep.content = "// Deep\nfn deep() {}".to_string();
+        let results = [documented, plain, deep];
+
+        let matches = [
+            // deep.rs has a doc field, but ranked below the two returned
+            Some(vec![scored("documented.rs", 0.9), scored("other.rs", 0.4)]),
+            Some(vec![
+                scored("plain.rs", 0.8),
+                scored("documented.rs", 0.3),
+                scored("deep.rs", 0.2),
+            ]),
+            None,
+        ];
+        let similarities = field_similarities(&results, &matches, 2);
+
+        assert_eq!(similarities[0], [Some(0.9), Some(0.3), None]);
+        // plain.rs has no doc field at all
+        assert_eq!(similarities[1], [None, Some(0.8), None]);
+        assert_eq!(similarities[2], [Some(0.4), Some(0.2), None]);
+
+        // A search that returned fewer than its limit reached every chunk
+        let similarities = field_similarities(&results, &matches, 10);
+        assert_eq!(similarities[2], [None, Some(0.2), None]);
+    }
+
+    #[test]
+    fn test_doc_weight_changes_ranking() {
+        // `documented` explains the concept in its doc comment;
+        // `implementation` matches the query in its code
+        let similarities = [
+            [Some(0.9), Some(0.3), Some(0.4)],
+            [Some(0.2), Some(0.8), Some(0.4)],
+        ];
+
+        let mut results = vec![result("documented.rs"), result("implementation.rs")];
+        let doc_heavy = FieldWeights::parse("doc=0.7,body=0.3").unwrap();
+        rank_by_fields(&mut results, &similarities, &doc_heavy);
+        assert_eq!(results[0].file_path, "documented.rs");
+
+        let mut results = vec![result("documented.rs"), result("implementation.rs")];
+        let body_heavy = FieldWeights::parse("doc=0.1,body=0.9").unwrap();
+        rank_by_fields(&mut results, &similarities, &body_heavy);
+        assert_eq!(results[0].file_path, "implementation.rs");
+        assert!(results[0].score > results[1].score);
+    }
+
+    #[test]
+    fn test_cosine() {
+        assert_eq!(cosine(&[1.0, 0.0], &[1.0, 0.0]), 1.0);
+        assert_eq!(cosine(&[1.0, 0.0], &[0.0, 1.0]), 0.0);
+        assert_eq!(cosine(&[0.0, 0.0], &[1.0, 0.0]), 0.0);
+    }
+}
@@ -0,0 +1,341 @@
+//! Syntax-highlighted snippets
+//!
+//! Plain snippets are hard to read in rich clients, so results can be
+//! highlighted by the language of their file: ANSI colors for terminals, and
+//! `<span class="hl-...">` markup for `search --format html` and the web UI.
+//! Highlighting reuses the indexer's tree-sitter grammars, but parses every
+//! snippet again, so it is off unless `search --highlight` or
+//! `search.highlight` enables it.
+//!
+//! Comments, strings, numbers and keywords are recognized by their syntax
+//! node; types and functions by the roles of [`role_of`]. Snippets in a
+//! language without a grammar stay plain text. ANSI colors are only written
+//! to a terminal, and never when `NO_COLOR` is set.
+
+use std::io::IsTerminal;
+use std::ops::Range;
+use std::path::Path;
+
+use tree_sitter::Node;
+
+use crate::indexer::ast_chunker::parser_pool::ParserPool;
+use crate::indexer::ast_chunker::token_roles::{role_of, TokenRole};
+
+/// Class of a highlighted token
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Highlight {
+    Keyword,
+    String,
+    Comment,
+    Number,
+    Type,
+    Function,
+}
+
+impl Highlight {
+    /// CSS class of the HTML span
+    pub fn class(&self) -> &'static str {
+        match self {
+            Self::Keyword => "hl-keyword",
+            Self::String => "hl-string",
+            Self::Comment => "hl-comment",
+            Self::Number => "hl-number",
+            Self::Type => "hl-type",
+            Self::Function => "hl-function",
+        }
+    }
+
+    /// ANSI color (SGR parameter)
+    fn ansi(&self) -> &'static str {
+        match self {
+            Self::Keyword => "35",
+            Self::String => "32",
+            Self::Comment => "90",
+            Self::Number => "36",
+            Self::Type => "33",
+            Self::Function => "34",
+        }
+    }
+}
+
+/// Leaf kinds of built-in types, which are not type identifiers
+const PRIMITIVE_TYPES: &[&str] = &[
+    "primitive_type",
+    "predefined_type",
+    "integral_type",
+    "floating_point_type",
+    "boolean_type",
+    "void_type",
+];
+
+/// Named leaf kinds of literal keywords
+const KEYWORD_LITERALS: &[&str] = &[
+    "true",
+    "false",
+    "nil",
+    "null",
+    "none",
+    "undefined",
+    "self",
+    "this",
+    "iota",
+];
+
+/// Words in the kinds of number literals
+const NUMBER_LITERALS: &[&str] = &[
+    "int",
+    "float",
+    "number",
+    "decimal",
+    "hex",
+    "octal",
+    "binary",
+    "imaginary",
+];
+
+/// Grammar of a result's file, from its extension
+pub fn language_for_path(path: &str) -> Option<&'static str> {
+    let extension = Path::new(path).extension()?.to_str()?;
+    let language = match extension {
+        "rs" => "rust",
+        "py" => "python",
+        "js" | "jsx" | "mjs" | "cjs" => "javascript",
+        "ts" => "typescript",
+        "tsx" => "tsx",
+        "go" => "go",
+        "java" => "java",
+        "c" => "c",
+        "cc" | "cpp" | "cxx" | "h" | "hpp" | "hxx" => "cpp",
+        _ => return None,
+    };
+    Some(language)
+}
+
+/// Whether ANSI colors may be written to stdout: it is a terminal and
+/// `NO_COLOR` is unset or empty
+pub fn ansi_enabled() -> bool {
+    std::env::var_os("NO_COLOR").map_or(true, |v| v.is_empty()) && std::io::stdout().is_terminal()
+}
+
+/// Escape text for HTML element content and attribute values
+pub fn escape_html(text: &str) -> String {
+    let mut escaped = String::with_capacity(text.len());
+    for c in text.chars() {
+        match c {
+            '&' => escaped.push_str("&amp;"),
+            '<' => escaped.push_str("&lt;"),
+            '>' => escaped.push_str("&gt;"),
+            '"' => escaped.push_str("&quot;"),
+            '\'' => escaped.push_str("&#39;"),
+            _ => escaped.push(c),
+        }
+    }
+    escaped
+}
+
+/// Highlights snippets, reusing a parser per language
+#[derive(Default)]
+pub struct Highlighter {
+    parsers: ParserPool,
+}
+
+impl Highlighter {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Highlighted byte ranges of `code`, in order and non-overlapping.
+    ///
+    /// Empty when `language` has no grammar or the code cannot be parsed.
+    pub fn spans(&mut self, language: &str, code: &str) -> Vec<(Range<usize>, Highlight)> {
+        let Some(tree) = self
+            .parsers
+            .get_parser(language)
+            .and_then(|parser| parser.parse(code.as_bytes(), None))
+        else {
+            return Vec::new();
+        };
+
+        let mut spans = Vec::new();
+        let mut stack = vec![tree.root_node()];
+        while let Some(node) = stack.pop() {
+            if let Some(highlight) = classify(node, code) {
+                spans.push((node.byte_range(), highlight));
+                continue;
+            }
+            // Reversed so spans come out in source order
+            let children: Vec<Node> = node.children(&mut node.walk()).collect();
+            stack.extend(children.into_iter().rev());
+        }
+        spans
+    }
+
+    /// `code` with ANSI colors; every line resets its colors, so a preview
+    /// can stop at any line
+    pub fn ansi(&mut self, language: &str, code: &str) -> String {
+        let spans = self.spans(language, code);
+        render(
+            code,
+            &spans,
+            |highlight, text| format!("\x1b[{}m{}\x1b[0m", highlight.ansi(), text),
+            str::to_string,
+        )
+    }
+
+    /// `code` escaped for HTML, with highlighted tokens in
+    /// `<span class="hl-...">`; spans never cross lines
+    pub fn html(&mut self, language: &str, code: &str) -> String {
+        let spans = self.spans(language, code);
+        render(
+            code,
+            &spans,
+            |highlight, text| {
+                format!(
+                    "<span class=\"{}\">{}</span>",
+                    highlight.class(),
+                    escape_html(text)
+                )
+            },
+            escape_html,
+        )
+    }
+}
+
+/// Highlight of a node covering its whole text, if any.
+///
+/// Comments and strings are highlighted whole, with whatever they contain;
+/// other highlights are leaves.
+fn classify(node: Node, code: &str) -> Option<Highlight> {
+    let kind = node.kind();
+    if kind.contains("comment") {
+        return Some(Highlight::Comment);
+    }
+    if kind.contains("string")
+        || matches!(kind, "char_literal" | "rune_literal" | "character_literal")
+    {
+        return Some(Highlight::String);
+    }
+    if node.child_count() > 0 {
+        return None;
+    }
+
+    if is_number(kind) {
+        return Some(Highlight::Number);
+    }
+    if PRIMITIVE_TYPES.contains(&kind) {
+        return Some(Highlight::Type);
+    }
+    if KEYWORD_LITERALS.contains(&kind) {
+        return Some(Highlight::Keyword);
+    }
+    if !node.is_named() {
+        // Anonymous word tokens are keywords; the others are punctuation
+        let text = node.utf8_text(code.as_bytes()).ok()?;
+        let is_word = !text.is_empty() && text.chars().all(|c| c.is_ascii_alphabetic() || c == '_');
+        return is_word.then_some(Highlight::Keyword);
+    }
+    match role_of(node)? {
+        TokenRole::Type => Some(Highlight::Type),
+        TokenRole::Function => Some(Highlight::Function),
+        TokenRole::Field | TokenRole::Local => None,
+    }
+}
+
+/// Number literal kinds across grammars (`int_literal`, `integer`, `number`, ...)
+fn is_number(kind: &str) -> bool {
+    matches!(kind, "integer" | "float" | "number")
+        || (kind.ends_with("literal") && NUMBER_LITERALS.iter().any(|n| kind.contains(n)))
+}
+
+/// `code` with each span wrapped by `wrap` and the text between spans
+/// passed through `plain`. Spans covering several lines are wrapped line
+/// by line.
+fn render(
+    code: &str,
+    spans: &[(Range<usize>, Highlight)],
+    wrap: impl Fn(Highlight, &str) -> String,
+    plain: impl Fn(&str) -> String,
+) -> String {
+    let mut out = String::with_capacity(code.len() * 2);
+    let mut pos = 0;
+    for (range, highlight) in spans {
+        if range.start < pos || range.end > code.len() {
+            continue;
+        }
+        out.push_str(&plain(&code[pos..range.start]));
+        for (i, line) in code[range.clone()].split('\n').enumerate() {
+            if i > 0 {
+                out.push('\n');
+            }
+            if !line.is_empty() {
+                out.push_str(&wrap(*highlight, line));
+            }
+        }
+        pos = range.end;
+    }
+    out.push_str(&plain(&code[pos..]));
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const GO_SNIPPET: &str = "// Drain closes the pool.\nfunc (p *Pool) Drain() int {\n\tp.Close()\n\tfmt.Println(\"a<b\")\n\treturn 42\n}\n\n/* multi\nline */\n";
+
+    #[test]
+    fn test_go_snippet_html_markup() {
+        let html = Highlighter::new().html("go", GO_SNIPPET);
+        for expected in [
+            r#"<span class="hl-comment">// Drain closes the pool.</span>"#,
+            r#"<span class="hl-keyword">func</span>"#,
+            r#"<span class="hl-type">Pool</span>"#,
+            r#"<span class="hl-function">Drain</span>"#,
+            r#"<span class="hl-type">int</span>"#,
+            r#"<span class="hl-function">Close</span>"#,
+            r#"<span class="hl-string">&quot;a&lt;b&quot;</span>"#,
+            r#"<span class="hl-keyword">return</span>"#,
+            r#"<span class="hl-number">42</span>"#,
+            // One span per line of a block comment
+            "<span class=\"hl-comment\">/* multi</span>\n<span class=\"hl-comment\">line */</span>",
+        ] {
+            assert!(html.contains(expected), "missing {} in {}", expected, html);
+        }
+        // Locals and punctuation stay plain, and the text is unchanged
+        assert!(html.contains("(p *"));
+        let stripped = html
+            .split('<')
+            .map(|part| part.split_once('>').map_or(part, |(_, text)| text))
+            .collect::<String>();
+        assert_eq!(
+            stripped.replace("&quot;", "\"").replace("&lt;", "<"),
+            GO_SNIPPET
+        );
+    }
+
+    #[test]
+    fn test_go_snippet_ansi() {
+        let ansi = Highlighter::new().ansi("go", GO_SNIPPET);
+        assert!(ansi.contains("\x1b[35mfunc\x1b[0m"));
+        assert!(ansi.contains("\x1b[36m42\x1b[0m"));
+        // Every line resets its colors
+        for line in ansi.lines() {
+            assert_eq!(
+                line.matches("\x1b[0m").count(),
+                line.matches("\x1b[").count() / 2,
+                "{:?}",
+                line
+            );
+        }
+    }
+
+    #[test]
+    fn test_unknown_language_is_plain() {
+        let mut highlighter = Highlighter::new();
+        assert_eq!(highlighter.html("cobol", "a < b"), "a &lt; b");
+        assert_eq!(highlighter.ansi("cobol", "a < b"), "a < b");
+        assert_eq!(language_for_path("main.go"), Some("go"));
+        assert_eq!(language_for_path("release:web/app.tsx"), Some("tsx"));
+        assert_eq!(language_for_path("README.md"), None);
+    }
+}
@@ -1,20 +1,26 @@
 //! Hybrid search combining vector similarity and BM25 keyword matching.
 //!
 //! This module implements hybrid search using Reciprocal Rank Fusion (RRF)
-//! to combine results from vector search and BM25 search.
+//! to combine results from vector search and BM25 search. A vector store
+//! with hybrid search of its own, such as Weaviate, ranks both itself.
 
 use anyhow::{Context, Result};
 use async_trait::async_trait;
-use std::collections::HashMap;
+use std::collections::hash_map::Entry;
+use std::collections::{HashMap, HashSet};
 use std::path::Path;
 use std::sync::Arc;
 use tracing::info;
 
 use super::bm25::Bm25Search;
+use super::candidates::CandidatePool;
+use super::synonyms::SynonymMap;
+use super::term_boosts::TermBoosts;
 use super::traits::Search;
 use super::SearchEngine;
 use crate::embeddings::EmbeddingGenerator;
-use crate::storage::{SearchResult, Storage};
+use crate::seed;
+use crate::storage::{SearchFilter, SearchResult, VectorStore};
 
 /// Default RRF constant (k parameter).
 ///
@@ -31,20 +37,36 @@ const DEFAULT_BM25_WEIGHT: f32 = 0.3;
 ///
 /// Combines multiple ranked result lists using the formula:
 /// `score = sum(weight / (k + rank))` for each result across all lists.
+/// Results with equal scores are ordered by a seeded hash of their location
+/// (see [`crate::seed`]). A result found by several lists keeps the
+/// retrieval sources of each (see [`super::provenance`]). A result at
+/// another result's duplicate location is dropped: only vector search skips
+/// duplicates, so BM25 may still find them.
 pub struct RrfFusion {
     /// The k constant in the RRF formula
     k: f32,
+    /// Seed for ordering ties
+    seed: u64,
 }
 
 impl RrfFusion {
     /// Create RRF with default k=60.
     pub fn new() -> Self {
-        Self { k: DEFAULT_RRF_K }
+        Self::with_k(DEFAULT_RRF_K)
     }
 
     /// Create RRF with custom k value.
     pub fn with_k(k: f32) -> Self {
-        Self { k }
+        Self {
+            k,
+            seed: seed::current(),
+        }
+    }
+
+    /// Order ties with `seed` instead of the process-wide seed.
+    pub fn with_seed(mut self, seed: u64) -> Self {
+        self.seed = seed;
+        self
     }
 
     /// Fuse multiple ranked result lists into one.
@@ -68,22 +90,56 @@ impl RrfFusion {
                 // rank is 0-indexed, but RRF traditionally uses 1-indexed ranks
                 let rrf_score = weight / (self.k + (rank + 1) as f32);
 
-                fused_scores
-                    .entry(key)
-                    .and_modify(|(_, score)| *score += rrf_score)
-                    .or_insert((result, rrf_score));
+                match fused_scores.entry(key) {
+                    Entry::Occupied(mut entry) => {
+                        let (existing, score) = entry.get_mut();
+                        *score += rrf_score;
+                        // BM25 results carry no kind; keep the one from vector search
+                        if existing.semantic_kind.is_none() {
+                            existing.semantic_kind = result.semantic_kind;
+                        }
+                        existing.sources.extend(result.sources);
+                        if existing.duplicates.is_empty() {
+                            existing.duplicates = result.duplicates;
+                        }
+                    }
+                    Entry::Vacant(entry) => {
+                        entry.insert((result, rrf_score));
+                    }
+                }
             }
         }
 
-        // Sort by fused score descending
-        let mut sorted: Vec<_> = fused_scores.into_values().collect();
-        sorted.sort_by(|a, b| b.1.partial_cmp(&a.1).unwrap_or(std::cmp::Ordering::Equal));
+        let duplicates: HashSet<String> = fused_scores
+            .values()
+            .flat_map(|(result, _)| result.duplicates.iter().cloned())
+            .collect();
+        fused_scores.retain(|_, (result, _)| {
+            let location = format!(
+                "{}:{}-{}",
+                result.file_path, result.start_line, result.end_line
+            );
+            !duplicates.contains(&location)
+        });
+
+        // Sort by fused score descending; map order is arbitrary, so ties
+        // are ordered by the seeded hash of their key
+        let mut sorted: Vec<_> = fused_scores
+            .into_iter()
+            .map(|(key, (result, score))| (seed::tie_breaker(self.seed, &key), key, result, score))
+            .collect();
+        sorted.sort_by(|a, b| {
+            b.3.partial_cmp(&a.3)
+                .unwrap_or(std::cmp::Ordering::Equal)
+                .then(a.0.cmp(&b.0))
+                .then_with(|| a.1.cmp(&b.1))
+        });
 
         // Take top results and update scores
         sorted
             .into_iter()
             .take(limit)
-            .map(|(mut result, score)| {
+            .map(|(_, _, mut result, score)| {
                 result.score = score;
                 result
             })
@@ -112,6 +168,8 @@ pub struct HybridSearch {
     vector_weight: f32,
     /// Weight for BM25 results (0.0 - 1.0)
     bm25_weight: f32,
+    /// Candidates fetched from each search before fusion
+    candidates: CandidatePool,
 }
 
 impl HybridSearch {
@@ -124,7 +182,7 @@ impl HybridSearch {
     /// * `vector_weight` - Weight for vector results (default 0.7)
     /// * `bm25_weight` - Weight for BM25 results (default 0.3)
     pub fn new(
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
         embedder: Arc<EmbeddingGenerator>,
         bm25_path: &Path,
         vector_weight: f32,
@@ -140,12 +198,13 @@ impl HybridSearch {
             fusion: RrfFusion::new(),
             vector_weight,
             bm25_weight,
+            candidates: CandidatePool::default(),
         })
     }
 
     /// Create a new hybrid search engine with default weights (0.7 vector, 0.3 BM25).
     pub fn with_defaults(
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
         embedder: Arc<EmbeddingGenerator>,
         bm25_path: &Path,
     ) -> Result<Self> {
@@ -164,6 +223,33 @@ impl HybridSearch {
         self
     }
 
+    /// Expand queries with an acronym/synonym map.
+    ///
+    /// The lexical (BM25) query is always expanded; the text embedded for
+    /// vector search only when `expand_embeddings` is set.
+    pub fn with_synonyms(mut self, synonyms: SynonymMap, expand_embeddings: bool) -> Self {
+        if expand_embeddings {
+            self.vector = self.vector.with_synonyms(synonyms.clone());
+        }
+        self.bm25 = self.bm25.with_synonyms(synonyms);
+        self
+    }
+
+    /// Weight lexical identifier matches by role (`[search.term_boosts]`).
+    pub fn with_term_boosts(mut self, term_boosts: TermBoosts) -> Self {
+        self.bm25 = self.bm25.with_term_boosts(term_boosts);
+        self
+    }
+
+    /// Size the candidate pool fetched from each search before fusion.
+    ///
+    /// Hybrid search fetches the initial pool size only; a dynamic pool
+    /// does not grow, since fused scores depend on both result lists.
+    pub fn with_candidates(mut self, candidates: CandidatePool) -> Self {
+        self.candidates = candidates;
+        self
+    }
+
     /// Set custom RRF k value.
     pub fn with_rrf_k(mut self, k: f32) -> Self {
         self.fusion = RrfFusion::with_k(k);
@@ -184,24 +270,63 @@ impl HybridSearch {
     pub fn weights(&self) -> (f32, f32) {
         (self.vector_weight, self.bm25_weight)
     }
-}
 
-#[async_trait]
-impl Search for HybridSearch {
-    async fn search(&self, query: &str, limit: usize) -> Result<Vec<SearchResult>> {
+    /// Share of the vector side in a store's own hybrid search
+    pub fn alpha(&self) -> f32 {
+        hybrid_alpha(self.vector_weight, self.bm25_weight)
+    }
+
+    /// Hybrid search of chunks matching a metadata filter
+    ///
+    /// The BM25 index holds no metadata, so with a filter a keyword hit
+    /// counts only when the filtered vector search also found its chunk.
+    pub async fn search_filtered(
+        &self,
+        query: &str,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
         let start = std::time::Instant::now();
 
+        // A store with hybrid search of its own ranks both sides itself,
+        // over its own keyword index
+        let keywords = self.bm25.expand(query);
+        let delegated = self
+            .vector
+            .search_hybrid(query, &keywords, limit, filter, self.alpha())
+            .await
+            .with_context(|| "Hybrid search failed")?;
+        if let Some(results) = delegated {
+            info!(
+                search_type = "hybrid",
+                query = query,
+                results = results.len(),
+                backend = self.vector.storage().backend(),
+                alpha = self.alpha(),
+                elapsed_ms = start.elapsed().as_millis() as u64,
+                "Hybrid search delegated to the vector store"
+            );
+            return Ok(results);
+        }
+
         // Fetch more results from each search to ensure good fusion
-        let fetch_limit = limit * 3;
+        let fetch_limit = self.candidates.initial(limit);
 
         // Run both searches concurrently
         let (vector_results, bm25_results) = tokio::join!(
-            self.vector.search(query, fetch_limit),
+            self.vector.search_filtered(query, fetch_limit, filter),
             self.bm25.search(query, fetch_limit)
         );
 
         let vector_results = vector_results.with_context(|| "Vector search failed")?;
-        let bm25_results = bm25_results.with_context(|| "BM25 search failed")?;
+        let mut bm25_results = bm25_results.with_context(|| "BM25 search failed")?;
+        if *filter != SearchFilter::default() {
+            let matching: HashSet<(&str, usize)> = vector_results
+                .iter()
+                .map(|r| (r.file_path.as_str(), r.start_line))
+                .collect();
+            bm25_results.retain(|r| matching.contains(&(r.file_path.as_str(), r.start_line)));
+        }
 
         // Fuse results using RRF
         let fused = self.fusion.fuse(
@@ -225,6 +350,25 @@ impl Search for HybridSearch {
 
         Ok(fused)
     }
+}
+
+/// `vector_weight / (vector_weight + bm25_weight)`: 0 is pure keyword, 1
+/// pure vector; an even split when both weights are zero
+fn hybrid_alpha(vector_weight: f32, bm25_weight: f32) -> f32 {
+    let total = vector_weight + bm25_weight;
+    if total > 0.0 {
+        vector_weight / total
+    } else {
+        0.5
+    }
+}
+
+#[async_trait]
+impl Search for HybridSearch {
+    async fn search(&self, query: &str, limit: usize) -> Result<Vec<SearchResult>> {
+        self.search_filtered(query, limit, &SearchFilter::default())
+            .await
+    }
 
     fn search_type(&self) -> &'static str {
         "hybrid"
@@ -249,6 +393,7 @@ pub fn rrf_score(ranks: &[(usize, f32)], k: f32) -> f32 {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::search::provenance::{RetrievalPath, RetrievalSource};
 
     fn create_test_result(file_path: &str, start_line: usize, score: f32) -> SearchResult {
         SearchResult {
@@ -258,6 +403,9 @@ mod tests {
             end_line: start_line + 10,
             score,
             file_header: None,
+            semantic_kind: None,
+            sources: Vec::new(),
+            duplicates: Vec::new(),
         }
     }
 
@@ -273,6 +421,15 @@ mod tests {
         assert!((score - expected).abs() < 0.0001);
     }
 
+    #[test]
+    fn test_hybrid_alpha() {
+        assert!((hybrid_alpha(0.7, 0.3) - 0.7).abs() < 0.0001);
+        assert!((hybrid_alpha(1.0, 1.0) - 0.5).abs() < 0.0001);
+        assert_eq!(hybrid_alpha(1.0, 0.0), 1.0);
+        assert_eq!(hybrid_alpha(0.0, 2.0), 0.0);
+        assert_eq!(hybrid_alpha(0.0, 0.0), 0.5);
+    }
+
     #[test]
     fn test_rrf_fusion_basic() {
         let fusion = RrfFusion::new();
@@ -297,6 +454,80 @@ mod tests {
         assert_eq!(fused[0].file_path, "file2.rs");
     }
 
+    #[test]
+    fn test_hybrid_hit_records_both_sources() {
+        let source = |path, score| RetrievalSource {
+            path,
+            score,
+            query_variant: None,
+        };
+        let mut shared = create_test_result("pool.rs", 1, 0.8);
+        shared.sources = vec![source(RetrievalPath::Vector, 0.8)];
+        let mut lexical = create_test_result("pool.rs", 1, 6.5);
+        lexical.sources = vec![RetrievalSource {
+            query_variant: Some("wp worker pool".to_string()),
+            ..source(RetrievalPath::Lexical, 6.5)
+        }];
+        let mut vector_only = create_test_result("queue.rs", 1, 0.7);
+        vector_only.sources = vec![source(RetrievalPath::Vector, 0.7)];
+
+        let fused = RrfFusion::new().fuse(
+            vec![(vec![shared, vector_only], 0.7), (vec![lexical], 0.3)],
+            10,
+        );
+
+        assert_eq!(fused[0].file_path, "pool.rs");
+        let paths: Vec<_> = fused[0].sources.iter().map(|s| s.path).collect();
+        assert_eq!(paths, vec![RetrievalPath::Vector, RetrievalPath::Lexical]);
+        assert_eq!(fused[0].sources[0].score, 0.8);
+        assert_eq!(
+            fused[0].sources[1].query_variant.as_deref(),
+            Some("wp worker pool")
+        );
+        assert_eq!(fused[1].sources, vec![source(RetrievalPath::Vector, 0.7)]);
+    }
+
+    #[test]
+    fn test_lexical_hit_on_duplicate_location_is_dropped() {
+        let mut original = create_test_result("src/pool.rs", 1, 0.8);
+        original.duplicates = vec!["vendor/pool.rs:1-11".to_string()];
+        let copy = create_test_result("vendor/pool.rs", 1, 6.5);
+        let other = create_test_result("src/queue.rs", 1, 4.0);
+
+        let fused =
+            RrfFusion::new().fuse(vec![(vec![original], 0.7), (vec![copy, other], 0.3)], 10);
+
+        let files: Vec<_> = fused.iter().map(|r| r.file_path.as_str()).collect();
+        assert_eq!(files, vec!["src/pool.rs", "src/queue.rs"]);
+        assert_eq!(fused[0].duplicates, vec!["vendor/pool.rs:1-11"]);
+    }
+
+    #[test]
+    fn test_rrf_ties_follow_seed() {
+        // Each file is first in one list, so all three tie
+        let lists = || {
+            ["a.rs", "b.rs", "c.rs"]
+                .iter()
+                .map(|f| (vec![create_test_result(f, 1, 0.5)], 1.0))
+                .collect::<Vec<_>>()
+        };
+        let order = |seed: u64| {
+            RrfFusion::new()
+                .with_seed(seed)
+                .fuse(lists(), 10)
+                .into_iter()
+                .map(|r| r.file_path)
+                .collect::<Vec<_>>()
+        };
+
+        // Every fusion uses a new hash map, so this would vary without the seed
+        let first = order(7);
+        for _ in 0..20 {
+            assert_eq!(order(7), first);
+        }
+        assert!((0..16).any(|seed| order(seed) != first));
+    }
+
     #[test]
     fn test_rrf_fusion_limit() {
         let fusion = RrfFusion::new();
@@ -336,4 +567,140 @@ mod tests {
 
         assert!(diff_low > diff_high);
     }
+
+    /// Store with hybrid search of its own that records the filter of
+    /// each hybrid query
+    #[derive(Default)]
+    struct DelegatingStore {
+        filters: std::sync::Mutex<Vec<SearchFilter>>,
+    }
+
+    #[async_trait]
+    impl VectorStore for DelegatingStore {
+        fn backend(&self) -> &'static str {
+            "delegating"
+        }
+
+        fn vector_dimension(&self) -> usize {
+            4
+        }
+
+        async fn upsert(&self, _chunks: Vec<crate::storage::IndexedChunk>) -> Result<()> {
+            Ok(())
+        }
+
+        async fn upsert_field_vectors(
+            &self,
+            _field: crate::storage::VectorField,
+            _vectors: Vec<crate::storage::FieldVector>,
+        ) -> Result<()> {
+            Ok(())
+        }
+
+        async fn delete_file(&self, _path: &Path) -> Result<()> {
+            Ok(())
+        }
+
+        async fn delete_branch(&self, _branch: &str) -> Result<()> {
+            Ok(())
+        }
+
+        async fn query(
+            &self,
+            _vector: Vec<f32>,
+            _limit: usize,
+            _filter: &SearchFilter,
+        ) -> Result<Vec<SearchResult>> {
+            unreachable!("hybrid queries are delegated")
+        }
+
+        fn supports_hybrid_query(&self) -> bool {
+            true
+        }
+
+        async fn hybrid_query(
+            &self,
+            _query: &str,
+            _vector: Vec<f32>,
+            _limit: usize,
+            filter: &SearchFilter,
+            _alpha: f32,
+        ) -> Result<Option<Vec<SearchResult>>> {
+            self.filters.lock().unwrap().push(filter.clone());
+            Ok(Some(vec![create_test_result("a.rs", 1, 0.5)]))
+        }
+
+        async fn query_field(
+            &self,
+            _field: crate::storage::VectorField,
+            _vector: Vec<f32>,
+            _limit: usize,
+            _filter: &SearchFilter,
+        ) -> Result<Vec<SearchResult>> {
+            Ok(Vec::new())
+        }
+
+        async fn has_field_vectors(&self, _field: crate::storage::VectorField) -> Result<bool> {
+            Ok(false)
+        }
+
+        async fn all_chunks(&self) -> Result<Vec<crate::storage::IndexedChunk>> {
+            Ok(Vec::new())
+        }
+
+        async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>> {
+            Ok(HashMap::new())
+        }
+
+        async fn file_mtimes(&self) -> Result<HashMap<std::path::PathBuf, i64>> {
+            Ok(HashMap::new())
+        }
+
+        async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>> {
+            Ok(HashMap::new())
+        }
+
+        async fn stats(&self) -> Result<crate::storage::StoreStats> {
+            Ok(crate::storage::StoreStats {
+                backend: self.backend(),
+                chunks: 0,
+                files: 0,
+                vector_dimension: self.vector_dimension(),
+            })
+        }
+
+        async fn snapshot(&self, _dest: &Path) -> Result<()> {
+            Ok(())
+        }
+    }
+
+    #[tokio::test]
+    async fn test_delegated_hybrid_search_applies_the_filter() {
+        let store = Arc::new(DelegatingStore::default());
+        let embedder = EmbeddingGenerator::with_provider(
+            Arc::new(crate::embeddings::mock::MockEmbedder::new(4)),
+            &crate::config::EmbeddingsConfig {
+                cache: false,
+                ..Default::default()
+            },
+        )
+        .unwrap();
+        let dir = tempfile::tempdir().unwrap();
+        let hybrid =
+            HybridSearch::with_defaults(store.clone(), Arc::new(embedder), dir.path()).unwrap();
+        let filter = SearchFilter {
+            kind: Some("function".to_string()),
+            branch: Some("main".to_string()),
+            ..Default::default()
+        };
+
+        let results = hybrid.search_filtered("parse", 5, &filter).await.unwrap();
+        assert_eq!(results.len(), 1);
+        hybrid.search("parse", 5).await.unwrap();
+
+        assert_eq!(
+            *store.filters.lock().unwrap(),
+            vec![filter, SearchFilter::default()]
+        );
+    }
 }
@@ -0,0 +1,158 @@
+//! Symbol-kind ranking preference
+//!
+//! When several results are about equally similar to a query, the kind of
+//! symbol decides which one is most useful: a function implementing a
+//! behaviour is usually a better hit than a field or constant that merely
+//! mentions it. [`KindPreference`] applies a small, position-weighted boost
+//! to preferred kinds and re-sorts the results, so it only reorders results
+//! whose scores are close; the reported scores are left unchanged.
+
+use super::SearchResult;
+
+/// Ordered list of preferred semantic kinds with a maximum boost
+#[derive(Debug, Clone, PartialEq)]
+pub struct KindPreference {
+    /// Kinds in order of preference (most preferred first)
+    order: Vec<String>,
+    /// Relative boost given to the most preferred kind (0.05 = 5%)
+    boost: f32,
+}
+
+impl KindPreference {
+    /// Create a preference from an ordered list of kinds.
+    ///
+    /// Kinds are normalized through `SemanticKind::parse` where possible so
+    /// that `client_method` and `client-method` are treated alike.
+    pub fn new(order: Vec<String>, boost: f32) -> Self {
+        let order = order
+            .into_iter()
+            .map(|k| normalize(&k))
+            .filter(|k| !k.is_empty())
+            .collect();
+        Self {
+            order,
+            boost: boost.max(0.0),
+        }
+    }
+
+    /// Whether the preference would change any ranking
+    pub fn is_empty(&self) -> bool {
+        self.order.is_empty() || self.boost == 0.0
+    }
+
+    /// Boost factor for a kind: `boost` for the first preferred kind,
+    /// decreasing linearly to `boost / n` for the last, and zero for kinds
+    /// not in the list or results without a kind.
+    pub fn weight(&self, kind: Option<&str>) -> f32 {
+        let Some(kind) = kind else {
+            return 0.0;
+        };
+        let kind = normalize(kind);
+        let n = self.order.len();
+        self.order
+            .iter()
+            .position(|k| *k == kind)
+            .map(|pos| self.boost * (n - pos) as f32 / n as f32)
+            .unwrap_or(0.0)
+    }
+
+    /// Re-rank results so preferred kinds come first among similar scores.
+    ///
+    /// The sort is stable, so results with equal adjusted scores keep their
+    /// original order.
+    pub fn apply(&self, results: &mut [SearchResult]) {
+        if self.is_empty() {
+            return;
+        }
+        results.sort_by(|a, b| {
+            let a = self.adjusted_score(a);
+            let b = self.adjusted_score(b);
+            b.partial_cmp(&a).unwrap_or(std::cmp::Ordering::Equal)
+        });
+    }
+
+    fn adjusted_score(&self, result: &SearchResult) -> f32 {
+        result.score * (1.0 + self.weight(result.semantic_kind.as_deref()))
+    }
+}
+
+fn normalize(kind: &str) -> String {
+    let kind = kind.trim().to_lowercase();
+    crate::indexer::SemanticKind::parse(&kind)
+        .map(|k| k.as_str().to_string())
+        .unwrap_or(kind)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn result(name: &str, kind: Option<&str>, score: f32) -> SearchResult {
+        SearchResult {
+            content: name.to_string(),
+            file_path: "src/lib.rs".to_string(),
+            start_line: 1,
+            end_line: 1,
+            score,
+            file_header: None,
+            semantic_kind: kind.map(str::to_string),
+            sources: Vec::new(),
+            duplicates: Vec::new(),
+        }
+    }
+
+    fn preference(kinds: &[&str]) -> KindPreference {
+        KindPreference::new(kinds.iter().map(|k| k.to_string()).collect(), 0.05)
+    }
+
+    #[test]
+    fn test_function_ranks_before_equally_similar_field() {
+        let mut results = vec![
+            result("retry_count", Some("field"), 0.8),
+            result("retry", Some("function"), 0.8),
+        ];
+
+        preference(&["function", "method"]).apply(&mut results);
+
+        assert_eq!(results[0].content, "retry");
+        assert_eq!(results[1].content, "retry_count");
+        // Scores are reported unchanged
+        assert_eq!(results[0].score, 0.8);
+    }
+
+    #[test]
+    fn test_clearly_better_result_is_not_overtaken() {
+        let mut results = vec![
+            result("Config", Some("struct"), 0.9),
+            result("load", Some("function"), 0.6),
+        ];
+
+        preference(&["function"]).apply(&mut results);
+
+        assert_eq!(results[0].content, "Config");
+    }
+
+    #[test]
+    fn test_weight_follows_preference_order() {
+        let pref = preference(&["function", "method", "client_method"]);
+
+        assert!(pref.weight(Some("function")) > pref.weight(Some("method")));
+        assert!(pref.weight(Some("method")) > pref.weight(Some("client-method")));
+        assert_eq!(pref.weight(Some("constant")), 0.0);
+        assert_eq!(pref.weight(None), 0.0);
+    }
+
+    #[test]
+    fn test_empty_preference_keeps_order() {
+        let mut results = vec![
+            result("a", Some("constant"), 0.5),
+            result("b", Some("function"), 0.5),
+        ];
+
+        KindPreference::new(Vec::new(), 0.05).apply(&mut results);
+        assert_eq!(results[0].content, "a");
+
+        KindPreference::new(vec!["function".to_string()], 0.0).apply(&mut results);
+        assert_eq!(results[0].content, "a");
+    }
+}
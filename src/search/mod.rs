@@ -5,14 +5,50 @@
 //! - `vector` - Semantic vector search using embeddings
 //! - `bm25` - BM25 keyword search using Tantivy
 //! - `hybrid` - Hybrid search combining vector and BM25 with RRF fusion
+//! - `candidates` - Candidate pool sizing before re-ranking
+//! - `cluster` - Topic clustering of retrieved results
+//! - `field_weights` - Weighted doc/body/structure scoring
+//! - `highlight` - Syntax-highlighted result snippets
+//! - `kind_preference` - Symbol-kind ranking preference
+//! - `no_results` - Explanations for searches without results
+//! - `processor` - Result post-processing hooks
+//! - `provenance` - Which search paths retrieved each result
+//! - `quickfix` - Editor quickfix output
+//! - `snippet` - Result previews anchored at the symbol or the match
+//! - `synonyms` - Acronym and synonym expansion for queries
+//! - `term_boosts` - Per-language weights for identifier matches by role
 
 pub mod bm25;
+pub mod candidates;
+pub mod cluster;
+pub mod field_weights;
+pub mod highlight;
 pub mod hybrid;
+pub mod kind_preference;
+pub mod no_results;
+pub mod processor;
+pub mod provenance;
+pub mod quickfix;
+pub mod snippet;
+pub mod synonyms;
+pub mod term_boosts;
 pub mod traits;
 mod vector;
 
 // Re-export commonly used types
 pub use bm25::{Bm25Index, Bm25Search};
+pub use candidates::{CandidatePool, CandidateStrategy};
+pub use cluster::{cluster_results, ResultCluster};
+pub use field_weights::FieldWeights;
+pub use highlight::{ansi_enabled, escape_html, language_for_path, Highlight, Highlighter};
 pub use hybrid::{HybridSearch, RrfFusion};
+pub use kind_preference::KindPreference;
+pub use no_results::{explain_no_results, EmptySearch, NoResultsCause};
+pub use processor::{DedupFiles, ProcessedSearch, ProcessorChain, ResultFilter, ResultProcessor};
+pub use provenance::{describe_sources, RetrievalPath, RetrievalSource};
+pub use quickfix::{quickfix_line, OutputFormat};
+pub use snippet::{Snippet, SnippetAnchor};
+pub use synonyms::SynonymMap;
+pub use term_boosts::{TermBoost, TermBoosts};
 pub use traits::Search;
 pub use vector::{SearchEngine, SearchResult};
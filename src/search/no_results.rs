@@ -0,0 +1,401 @@
+//! Explanations for searches without results
+//!
+//! An empty result list usually has a mundane cause: nothing is indexed, a
+//! filter names a kind or tag that does not exist, the score threshold is
+//! above every match, or the files being searched for were never indexed.
+//! [`explain_no_results`] checks each of these against the actual index
+//! contents, so `coderag search` can say which one applies instead of just
+//! "no results".
+
+use std::collections::BTreeSet;
+
+use crate::storage::{IndexedChunk, SearchFilter};
+
+/// Language names recognized in queries, with the language they refer to.
+///
+/// Words that are also common English (`go`, `c`) are left out, since a query
+/// mentioning them is rarely about the language.
+const LANGUAGE_NAMES: &[(&str, &str)] = &[
+    ("rust", "rust"),
+    ("python", "python"),
+    ("golang", "go"),
+    ("typescript", "typescript"),
+    ("javascript", "javascript"),
+    ("java", "java"),
+    ("cpp", "cpp"),
+    ("c++", "cpp"),
+    ("ruby", "ruby"),
+    ("php", "php"),
+    ("swift", "swift"),
+    ("kotlin", "kotlin"),
+    ("scala", "scala"),
+    ("csharp", "csharp"),
+    ("c#", "csharp"),
+];
+
+/// A search that returned nothing
+#[derive(Debug, Clone)]
+pub struct EmptySearch<'a> {
+    pub query: &'a str,
+    pub filter: &'a SearchFilter,
+    /// Minimum score results had to reach
+    pub min_score: f32,
+    /// Best score among the results dropped by `min_score`, if any
+    pub best_score: Option<f32>,
+    /// Files changed since the last index
+    pub stale_files: usize,
+}
+
+impl<'a> EmptySearch<'a> {
+    /// A search without threshold or staleness information
+    pub fn new(query: &'a str, filter: &'a SearchFilter) -> Self {
+        Self {
+            query,
+            filter,
+            min_score: 0.0,
+            best_score: None,
+            stale_files: 0,
+        }
+    }
+}
+
+/// A likely reason for an empty search
+#[derive(Debug, Clone, PartialEq)]
+pub enum NoResultsCause {
+    /// Nothing is indexed
+    EmptyIndex,
+    /// A filter (e.g. `--kind struct`) matches no indexed chunk; `available`
+    /// lists the values that do exist
+    FilterMatchesNothing {
+        filter: String,
+        available: Vec<String>,
+    },
+    /// Each filter matches something, but no chunk matches all of them
+    FiltersExcludeEverything { filters: Vec<String> },
+    /// The query names a language with no indexed chunks
+    LanguageNotIndexed {
+        language: String,
+        indexed: Vec<String>,
+    },
+    /// Matches were found, but all scored below the threshold
+    BelowThreshold { min_score: f32, best_score: f32 },
+    /// Files changed since the last index, so the match may not be indexed yet
+    StaleIndex { files: usize },
+}
+
+impl NoResultsCause {
+    /// One-line description
+    pub fn message(&self) -> String {
+        match self {
+            Self::EmptyIndex => "The index is empty".to_string(),
+            Self::FilterMatchesNothing { filter, .. } => {
+                format!("No indexed chunk matches {}", filter)
+            }
+            Self::FiltersExcludeEverything { filters } => {
+                format!("No indexed chunk matches {} together", filters.join(" "))
+            }
+            Self::LanguageNotIndexed { language, .. } => {
+                format!(
+                    "The query mentions {}, but no {} files are indexed",
+                    language, language
+                )
+            }
+            Self::BelowThreshold {
+                min_score,
+                best_score,
+            } => format!(
+                "All matches scored below the threshold (best {:.0}%, minimum {:.0}%)",
+                best_score * 100.0,
+                min_score * 100.0
+            ),
+            Self::StaleIndex { files } => {
+                format!("{} file(s) changed since the last index", files)
+            }
+        }
+    }
+
+    /// What to do about it
+    pub fn hint(&self) -> String {
+        match self {
+            Self::EmptyIndex => "Run 'coderag index' to index the project".to_string(),
+            Self::FilterMatchesNothing { available, .. } if available.is_empty() => {
+                "Drop the filter; no indexed chunk has this field set".to_string()
+            }
+            Self::FilterMatchesNothing { available, .. } => {
+                format!("Indexed values: {}", available.join(", "))
+            }
+            Self::FiltersExcludeEverything { .. } => {
+                "Drop one of the filters to widen the search".to_string()
+            }
+            Self::LanguageNotIndexed { indexed, .. } => format!(
+                "Indexed languages: {}. Add the extension to indexer.extensions and re-index",
+                if indexed.is_empty() {
+                    "none".to_string()
+                } else {
+                    indexed.join(", ")
+                }
+            ),
+            Self::BelowThreshold { best_score, .. } => format!(
+                "Lower --min-score (or search.min_score) to {:.2} or below",
+                best_score
+            ),
+            Self::StaleIndex { .. } => {
+                "Run 'coderag index', or search with --auto-refresh".to_string()
+            }
+        }
+    }
+}
+
+/// Likely causes of an empty search, most fundamental first
+pub fn explain_no_results(
+    chunks: &[IndexedChunk],
+    search: &EmptySearch<'_>,
+) -> Vec<NoResultsCause> {
+    if chunks.is_empty() {
+        return vec![NoResultsCause::EmptyIndex];
+    }
+
+    let mut causes = Vec::new();
+    let filter = search.filter;
+
+    // Each filter alone, then all of them together
+    let kind = filter.kind.as_deref();
+    let tag = filter.tag.as_deref();
+    let branch = filter.branch.as_deref();
+    let kind_matches =
+        |c: &IndexedChunk| kind.map_or(true, |k| c.semantic_kind.as_deref() == Some(k));
+    let tag_matches =
+        |c: &IndexedChunk| tag.map_or(true, |t| c.tags.iter().any(|ct| ct.eq_ignore_ascii_case(t)));
+    let branch_matches = |c: &IndexedChunk| branch.map_or(true, |b| c.branch.as_deref() == Some(b));
+
+    let mut unmatched = false;
+    if let Some(kind) = kind {
+        if !chunks.iter().any(kind_matches) {
+            unmatched = true;
+            causes.push(NoResultsCause::FilterMatchesNothing {
+                filter: format!("--kind {}", kind),
+                available: distinct(chunks.iter().filter_map(|c| c.semantic_kind.as_deref())),
+            });
+        }
+    }
+    if let Some(tag) = tag {
+        if !chunks.iter().any(tag_matches) {
+            unmatched = true;
+            causes.push(NoResultsCause::FilterMatchesNothing {
+                filter: format!("--tag {}", tag),
+                available: distinct(
+                    chunks
+                        .iter()
+                        .flat_map(|c| c.tags.iter().map(String::as_str)),
+                ),
+            });
+        }
+    }
+    if let Some(branch) = branch {
+        if !chunks.iter().any(branch_matches) {
+            unmatched = true;
+            causes.push(NoResultsCause::FilterMatchesNothing {
+                filter: format!("--branch {}", branch),
+                available: distinct(chunks.iter().filter_map(|c| c.branch.as_deref())),
+            });
+        }
+    }
+    let filters: Vec<String> = [("--kind", kind), ("--tag", tag), ("--branch", branch)]
+        .into_iter()
+        .filter_map(|(flag, value)| value.map(|v| format!("{} {}", flag, v)))
+        .collect();
+    if !unmatched
+        && filters.len() > 1
+        && !chunks
+            .iter()
+            .any(|c| kind_matches(c) && tag_matches(c) && branch_matches(c))
+    {
+        causes.push(NoResultsCause::FiltersExcludeEverything { filters });
+    }
+
+    let indexed = distinct(chunks.iter().filter_map(|c| c.language.as_deref()));
+    for language in query_languages(search.query) {
+        if !indexed.iter().any(|l| l == language) {
+            causes.push(NoResultsCause::LanguageNotIndexed {
+                language: language.to_string(),
+                indexed: indexed.clone(),
+            });
+        }
+    }
+
+    if let Some(best_score) = search.best_score {
+        if best_score < search.min_score {
+            causes.push(NoResultsCause::BelowThreshold {
+                min_score: search.min_score,
+                best_score,
+            });
+        }
+    }
+
+    if search.stale_files > 0 {
+        causes.push(NoResultsCause::StaleIndex {
+            files: search.stale_files,
+        });
+    }
+
+    causes
+}
+
+/// Languages named in a query, in order, without duplicates
+fn query_languages(query: &str) -> Vec<&'static str> {
+    let mut languages = Vec::new();
+    for word in query
+        .split(|c: char| c.is_whitespace() || matches!(c, ',' | ';' | '(' | ')' | '"' | '\''))
+        .map(str::to_lowercase)
+    {
+        let found = LANGUAGE_NAMES
+            .iter()
+            .find(|(name, _)| *name == word.trim_end_matches(['.', '?', '!']));
+        if let Some((_, language)) = found {
+            if !languages.contains(language) {
+                languages.push(*language);
+            }
+        }
+    }
+    languages
+}
+
+/// Sorted distinct values
+fn distinct<'a>(values: impl Iterator<Item = &'a str>) -> Vec<String> {
+    values
+        .collect::<BTreeSet<_>>()
+        .into_iter()
+        .map(str::to_string)
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunk(language: &str, kind: &str, tags: &[&str]) -> IndexedChunk {
+        IndexedChunk {
+            id: String::new(),
+            content: "fn retry() {}".to_string(),
+            file_path: "src/retry.rs".to_string(),
+            start_line: 1,
+            end_line: 1,
+            language: Some(language.to_string()),
+            vector: Vec::new(),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some(kind.to_string()),
+            symbol_name: None,
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: tags.iter().map(|t| t.to_string()).collect(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    fn index() -> Vec<IndexedChunk> {
+        vec![
+            chunk("rust", "function", &["concurrency"]),
+            chunk("rust", "struct", &[]),
+            chunk("go", "method", &["concurrency"]),
+        ]
+    }
+
+    fn explain(chunks: &[IndexedChunk], query: &str, filter: &SearchFilter) -> Vec<NoResultsCause> {
+        explain_no_results(chunks, &EmptySearch::new(query, filter))
+    }
+
+    #[test]
+    fn test_empty_index() {
+        let causes = explain(&[], "retry", &SearchFilter::default());
+        assert_eq!(causes, vec![NoResultsCause::EmptyIndex]);
+    }
+
+    #[test]
+    fn test_filter_matches_nothing() {
+        let filter = SearchFilter {
+            kind: Some("interface".to_string()),
+            ..Default::default()
+        };
+        let causes = explain(&index(), "retry", &filter);
+        assert_eq!(
+            causes,
+            vec![NoResultsCause::FilterMatchesNothing {
+                filter: "--kind interface".to_string(),
+                available: vec![
+                    "function".to_string(),
+                    "method".to_string(),
+                    "struct".to_string()
+                ],
+            }]
+        );
+        assert_eq!(causes[0].hint(), "Indexed values: function, method, struct");
+    }
+
+    #[test]
+    fn test_filters_exclude_everything_together() {
+        let filter = SearchFilter {
+            kind: Some("struct".to_string()),
+            tag: Some("concurrency".to_string()),
+            ..Default::default()
+        };
+        let causes = explain(&index(), "retry", &filter);
+        assert_eq!(
+            causes,
+            vec![NoResultsCause::FiltersExcludeEverything {
+                filters: vec!["--kind struct".to_string(), "--tag concurrency".to_string()],
+            }]
+        );
+    }
+
+    #[test]
+    fn test_language_not_indexed() {
+        let causes = explain(&index(), "Python retry decorator", &SearchFilter::default());
+        assert_eq!(
+            causes,
+            vec![NoResultsCause::LanguageNotIndexed {
+                language: "python".to_string(),
+                indexed: vec!["go".to_string(), "rust".to_string()],
+            }]
+        );
+
+        // Indexed languages and common words are not reported
+        assert!(explain(&index(), "rust retry, go worker", &SearchFilter::default()).is_empty());
+    }
+
+    #[test]
+    fn test_below_threshold() {
+        let filter = SearchFilter::default();
+        let search = EmptySearch {
+            min_score: 0.8,
+            best_score: Some(0.42),
+            ..EmptySearch::new("retry", &filter)
+        };
+        let causes = explain_no_results(&index(), &search);
+        assert_eq!(
+            causes,
+            vec![NoResultsCause::BelowThreshold {
+                min_score: 0.8,
+                best_score: 0.42,
+            }]
+        );
+        assert!(causes[0].message().contains("best 42%, minimum 80%"));
+    }
+
+    #[test]
+    fn test_stale_index() {
+        let filter = SearchFilter::default();
+        let search = EmptySearch {
+            stale_files: 3,
+            ..EmptySearch::new("retry", &filter)
+        };
+        assert_eq!(
+            explain_no_results(&index(), &search),
+            vec![NoResultsCause::StaleIndex { files: 3 }]
+        );
+    }
+}
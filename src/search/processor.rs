@@ -0,0 +1,297 @@
+//! Result post-processing hooks
+//!
+//! A [`ResultProcessor`] receives the ranked results of a search and returns
+//! a transformed list, so teams can enrich or filter results (attach
+//! ownership, drop archived modules) without forking. Processors are
+//! registered on a [`ProcessorChain`], or on a [`ProcessedSearch`] that
+//! wraps any [`Search`] implementation:
+//!
+//! ```ignore
+//! let search = ProcessedSearch::new(engine)
+//!     .with_processor(ResultFilter::new("no-archived", |r| !r.file_path.starts_with("archived/")))
+//!     .with_processor(DedupFiles);
+//! ```
+//!
+//! Processors run after ranking and before output, in registration order:
+//! each receives the output of the previous one. Register filters before
+//! processors that reorder or truncate, so that e.g. [`DedupFiles`] keeps the
+//! best *remaining* chunk of a file. Built-in ranking adjustments such as
+//! [`KindPreference`] are processors too.
+
+use anyhow::Result;
+use async_trait::async_trait;
+use std::collections::HashSet;
+use std::sync::Arc;
+
+use super::kind_preference::KindPreference;
+use super::traits::Search;
+use crate::storage::SearchResult;
+
+/// A hook that transforms a ranked result list
+pub trait ResultProcessor: Send + Sync {
+    /// Short name used in logs
+    fn name(&self) -> &str;
+
+    /// Transform the results of `query`. Results arrive ranked, highest
+    /// score first, and may be reordered, changed, dropped or added.
+    fn process(&self, query: &str, results: Vec<SearchResult>) -> Vec<SearchResult>;
+}
+
+/// Processors applied in registration order
+#[derive(Clone, Default)]
+pub struct ProcessorChain {
+    processors: Vec<Arc<dyn ResultProcessor>>,
+}
+
+impl ProcessorChain {
+    /// Create an empty chain
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Append a processor; it runs after those already registered.
+    pub fn with(mut self, processor: impl ResultProcessor + 'static) -> Self {
+        self.push(Arc::new(processor));
+        self
+    }
+
+    /// Append a shared processor.
+    pub fn push(&mut self, processor: Arc<dyn ResultProcessor>) {
+        self.processors.push(processor);
+    }
+
+    /// Whether no processors are registered
+    pub fn is_empty(&self) -> bool {
+        self.processors.is_empty()
+    }
+
+    /// Names of the registered processors, in order
+    pub fn names(&self) -> Vec<&str> {
+        self.processors.iter().map(|p| p.name()).collect()
+    }
+
+    /// Run every processor over the results.
+    pub fn apply(&self, query: &str, results: Vec<SearchResult>) -> Vec<SearchResult> {
+        self.processors.iter().fold(results, |results, processor| {
+            processor.process(query, results)
+        })
+    }
+}
+
+/// A search whose results pass through a processor chain
+pub struct ProcessedSearch {
+    inner: Arc<dyn Search>,
+    chain: ProcessorChain,
+}
+
+impl ProcessedSearch {
+    /// Wrap a search with an empty chain
+    pub fn new(inner: Arc<dyn Search>) -> Self {
+        Self {
+            inner,
+            chain: ProcessorChain::new(),
+        }
+    }
+
+    /// Register a processor after those already registered.
+    pub fn with_processor(mut self, processor: impl ResultProcessor + 'static) -> Self {
+        self.chain = self.chain.with(processor);
+        self
+    }
+
+    /// Replace the processor chain
+    pub fn with_chain(mut self, chain: ProcessorChain) -> Self {
+        self.chain = chain;
+        self
+    }
+
+    /// The registered processors
+    pub fn chain(&self) -> &ProcessorChain {
+        &self.chain
+    }
+}
+
+#[async_trait]
+impl Search for ProcessedSearch {
+    /// Search with the wrapped implementation, then apply the chain.
+    ///
+    /// Processors that drop results may return fewer than `limit`.
+    async fn search(&self, query: &str, limit: usize) -> Result<Vec<SearchResult>> {
+        let results = self.inner.search(query, limit).await?;
+        let mut results = self.chain.apply(query, results);
+        results.truncate(limit);
+        Ok(results)
+    }
+
+    fn search_type(&self) -> &'static str {
+        self.inner.search_type()
+    }
+}
+
+/// Keeps the results matching a predicate
+pub struct ResultFilter<F> {
+    name: String,
+    predicate: F,
+}
+
+impl<F> ResultFilter<F>
+where
+    F: Fn(&SearchResult) -> bool + Send + Sync,
+{
+    /// Create a filter that keeps results for which `predicate` is true
+    pub fn new(name: impl Into<String>, predicate: F) -> Self {
+        Self {
+            name: name.into(),
+            predicate,
+        }
+    }
+}
+
+impl<F> ResultProcessor for ResultFilter<F>
+where
+    F: Fn(&SearchResult) -> bool + Send + Sync,
+{
+    fn name(&self) -> &str {
+        &self.name
+    }
+
+    fn process(&self, _query: &str, mut results: Vec<SearchResult>) -> Vec<SearchResult> {
+        results.retain(|r| (self.predicate)(r));
+        results
+    }
+}
+
+/// Keeps only the highest ranked result of each file
+#[derive(Debug, Clone, Copy, Default)]
+pub struct DedupFiles;
+
+impl ResultProcessor for DedupFiles {
+    fn name(&self) -> &str {
+        "dedup-files"
+    }
+
+    fn process(&self, _query: &str, results: Vec<SearchResult>) -> Vec<SearchResult> {
+        let mut seen = HashSet::new();
+        results
+            .into_iter()
+            .filter(|r| seen.insert(r.file_path.clone()))
+            .collect()
+    }
+}
+
+impl ResultProcessor for KindPreference {
+    fn name(&self) -> &str {
+        "kind-preference"
+    }
+
+    fn process(&self, _query: &str, mut results: Vec<SearchResult>) -> Vec<SearchResult> {
+        self.apply(&mut results);
+        results
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn result(file_path: &str, score: f32, kind: Option<&str>) -> SearchResult {
+        SearchResult {
+            content: String::new(),
+            file_path: file_path.to_string(),
+            start_line: 1,
+            end_line: 5,
+            score,
+            file_header: None,
+            semantic_kind: kind.map(str::to_string),
+            sources: Vec::new(),
+            duplicates: Vec::new(),
+        }
+    }
+
+    fn paths(results: &[SearchResult]) -> Vec<&str> {
+        results.iter().map(|r| r.file_path.as_str()).collect()
+    }
+
+    /// Tags every result with a marker, to observe processor order
+    struct Mark(&'static str);
+
+    impl ResultProcessor for Mark {
+        fn name(&self) -> &str {
+            self.0
+        }
+
+        fn process(&self, _query: &str, mut results: Vec<SearchResult>) -> Vec<SearchResult> {
+            for r in &mut results {
+                r.content.push_str(self.0);
+            }
+            results
+        }
+    }
+
+    struct Fixed(Vec<SearchResult>);
+
+    #[async_trait]
+    impl Search for Fixed {
+        async fn search(&self, _query: &str, limit: usize) -> Result<Vec<SearchResult>> {
+            Ok(self.0.iter().take(limit).cloned().collect())
+        }
+
+        fn search_type(&self) -> &'static str {
+            "fixed"
+        }
+    }
+
+    #[test]
+    fn test_filter_drops_by_metadata_predicate() {
+        let results = vec![
+            result("src/pool.rs", 0.9, Some("function")),
+            result("archived/old_pool.rs", 0.8, Some("function")),
+            result("src/pool_test.rs", 0.7, Some("test")),
+        ];
+        let chain = ProcessorChain::new()
+            .with(ResultFilter::new("no-archived", |r: &SearchResult| {
+                !r.file_path.starts_with("archived/")
+            }))
+            .with(ResultFilter::new("no-tests", |r: &SearchResult| {
+                r.semantic_kind.as_deref() != Some("test")
+            }));
+
+        assert_eq!(paths(&chain.apply("pool", results)), vec!["src/pool.rs"]);
+        assert_eq!(chain.names(), vec!["no-archived", "no-tests"]);
+    }
+
+    #[test]
+    fn test_processors_run_in_registration_order() {
+        let chain = ProcessorChain::new().with(Mark("a")).with(Mark("b"));
+        let results = chain.apply("q", vec![result("x.rs", 1.0, None)]);
+        assert_eq!(results[0].content, "ab");
+    }
+
+    #[test]
+    fn test_dedup_keeps_best_result_per_file() {
+        let results = vec![
+            result("a.rs", 0.9, None),
+            result("b.rs", 0.8, None),
+            result("a.rs", 0.7, None),
+        ];
+        let results = DedupFiles.process("q", results);
+        assert_eq!(paths(&results), vec!["a.rs", "b.rs"]);
+    }
+
+    #[tokio::test]
+    async fn test_processed_search() {
+        let inner = Fixed(vec![
+            result("archived/a.rs", 0.9, None),
+            result("b.rs", 0.8, None),
+            result("c.rs", 0.7, None),
+        ]);
+        let search = ProcessedSearch::new(Arc::new(inner))
+            .with_processor(ResultFilter::new("no-archived", |r: &SearchResult| {
+                !r.file_path.starts_with("archived/")
+            }));
+
+        let results = search.search("q", 3).await.unwrap();
+        assert_eq!(paths(&results), vec!["b.rs", "c.rs"]);
+        assert_eq!(search.search_type(), "fixed");
+    }
+}
@@ -0,0 +1,132 @@
+//! Match provenance
+//!
+//! Every result records how it was retrieved as a list of
+//! [`RetrievalSource`]s: the path that found it (vector, lexical or a field
+//! vector), its score on that path, and the expanded query when synonym
+//! expansion changed what was run. Hybrid search merges the sources of a
+//! result found by both paths, so such a result carries a vector and a
+//! lexical source.
+//!
+//! Scores are the ones each path reported before fusion or re-ranking;
+//! comparing them across results shows which path carried a hit when tuning
+//! hybrid weights or `[search.synonyms]`. They are printed by
+//! `coderag search --format json` and `--verbose`, and returned by the web
+//! API.
+
+use serde::{Serialize, Serializer};
+
+use super::SearchResult;
+use crate::storage::VectorField;
+
+/// A search path that can retrieve a result
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum RetrievalPath {
+    /// Similarity to the chunk vector
+    Vector,
+    /// BM25 keyword match
+    Lexical,
+    /// Similarity to a field vector (`--field`)
+    Field(VectorField),
+}
+
+impl RetrievalPath {
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Vector => "vector",
+            Self::Lexical => "lexical",
+            Self::Field(VectorField::Name) => "field:name",
+            Self::Field(VectorField::Comments) => "field:comments",
+            Self::Field(VectorField::Summary) => "field:summary",
+            Self::Field(VectorField::Doc) => "field:doc",
+            Self::Field(VectorField::Body) => "field:body",
+            Self::Field(VectorField::Structure) => "field:structure",
+        }
+    }
+}
+
+impl Serialize for RetrievalPath {
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        serializer.serialize_str(self.as_str())
+    }
+}
+
+/// One path that retrieved a result
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct RetrievalSource {
+    pub path: RetrievalPath,
+    /// Score on this path, before fusion or re-ranking
+    pub score: f32,
+    /// The query as run, when synonym expansion changed it
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub query_variant: Option<String>,
+}
+
+/// Record `path` as a source of each result, with the result's current
+/// score. `run` is the query text actually searched for `query`.
+pub fn record_source(results: &mut [SearchResult], path: RetrievalPath, query: &str, run: &str) {
+    let query_variant = (run != query).then(|| run.to_string());
+    for result in results {
+        result.sources.push(RetrievalSource {
+            path,
+            score: result.score,
+            query_variant: query_variant.clone(),
+        });
+    }
+}
+
+/// Sources as one line, e.g. `vector 0.82 + lexical 4.10 (query "wp worker pool")`
+pub fn describe_sources(sources: &[RetrievalSource]) -> String {
+    sources
+        .iter()
+        .map(|source| match &source.query_variant {
+            Some(variant) => format!(
+                "{} {:.2} (query \"{}\")",
+                source.path.as_str(),
+                source.score,
+                variant
+            ),
+            None => format!("{} {:.2}", source.path.as_str(), source.score),
+        })
+        .collect::<Vec<_>>()
+        .join(" + ")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn result(score: f32) -> SearchResult {
+        SearchResult {
+            content: "fn run() {}".to_string(),
+            file_path: "src/pool.rs".to_string(),
+            start_line: 1,
+            end_line: 1,
+            score,
+            file_header: None,
+            semantic_kind: None,
+            sources: Vec::new(),
+            duplicates: Vec::new(),
+        }
+    }
+
+    #[test]
+    fn test_record_and_describe_sources() {
+        let mut results = vec![result(0.82)];
+        record_source(&mut results, RetrievalPath::Vector, "pool", "pool");
+        results[0].score = 4.1;
+        record_source(&mut results, RetrievalPath::Lexical, "wp", "wp worker pool");
+
+        let sources = &results[0].sources;
+        assert_eq!(sources[0].query_variant, None);
+        assert_eq!(sources[1].query_variant.as_deref(), Some("wp worker pool"));
+        assert_eq!(
+            describe_sources(sources),
+            "vector 0.82 + lexical 4.10 (query \"wp worker pool\")"
+        );
+        assert_eq!(
+            serde_json::to_value(&sources[0]).unwrap(),
+            serde_json::json!({"path": "vector", "score": 0.82f32})
+        );
+    }
+}
@@ -0,0 +1,165 @@
+//! Editor quickfix output
+//!
+//! Formats results as grep-style `path:line:col: message` lines, the shape
+//! Vim's quickfix list (`:cexpr`, `:cfile`) and Emacs' compilation and grep
+//! modes parse into a jump list. Lines and columns are 1-based.
+
+use super::SearchResult;
+
+/// How search results are printed
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum OutputFormat {
+    /// Human-readable results with content previews
+    #[default]
+    Text,
+    /// One `path:line:col: message` line per result
+    Quickfix,
+    /// A JSON array of results with their retrieval sources
+    Json,
+    /// An HTML fragment of results, with highlighted snippets under
+    /// `--highlight`
+    Html,
+}
+
+impl OutputFormat {
+    /// Parse output format from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "text" => Some(Self::Text),
+            "quickfix" => Some(Self::Quickfix),
+            "json" => Some(Self::Json),
+            "html" => Some(Self::Html),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Text => "text",
+            Self::Quickfix => "quickfix",
+            Self::Json => "json",
+            Self::Html => "html",
+        }
+    }
+}
+
+/// Quickfix line for a result.
+///
+/// The position is where `signature` starts in the result's content, so
+/// editors jump past leading doc comments and indentation; signatures
+/// spanning several lines are joined into one message. Without a signature,
+/// or when it is not found, the first non-blank line is used as both
+/// position and message.
+pub fn quickfix_line(result: &SearchResult, signature: Option<&str>) -> String {
+    let signature =
+        signature.filter(|s| first_line(s).is_some_and(|first| result.content.contains(first)));
+
+    let lines = result.content.lines().enumerate();
+    let (offset, line, col) = match signature.and_then(first_line) {
+        Some(first) => lines
+            .filter_map(|(i, line)| line.find(first).map(|byte| (i, line, byte)))
+            .next(),
+        None => lines
+            .filter_map(|(i, line)| {
+                line.find(|c: char| !c.is_whitespace())
+                    .map(|b| (i, line, b))
+            })
+            .next(),
+    }
+    .unwrap_or((0, "", 0));
+
+    let message = collapse_whitespace(signature.unwrap_or(line));
+    format!(
+        "{}:{}:{}: {}",
+        result.file_path,
+        result.start_line + offset,
+        line[..col].chars().count() + 1,
+        message
+    )
+}
+
+/// First non-blank line, trimmed
+fn first_line(s: &str) -> Option<&str> {
+    s.lines().map(str::trim).find(|l| !l.is_empty())
+}
+
+/// Single-line message: runs of whitespace become one space
+fn collapse_whitespace(s: &str) -> String {
+    s.split_whitespace().collect::<Vec<_>>().join(" ")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn result(content: &str) -> SearchResult {
+        SearchResult {
+            content: content.to_string(),
+            file_path: "src/pool.rs".to_string(),
+            start_line: 40,
+            end_line: 40 + content.lines().count() - 1,
+            score: 0.9,
+            file_header: None,
+            semantic_kind: Some("method".to_string()),
+            sources: Vec::new(),
+            duplicates: Vec::new(),
+        }
+    }
+
+    #[test]
+    fn test_quickfix_line_points_at_signature() {
+        let r = result(
+            "    /// Take a connection, waiting up to `timeout`\n    \
+             pub fn acquire(&self, timeout: Duration) -> Result<Conn> {\n        \
+             self.inner.acquire(timeout)\n    }",
+        );
+        assert_eq!(
+            quickfix_line(
+                &r,
+                Some("pub fn acquire(&self, timeout: Duration) -> Result<Conn>")
+            ),
+            "src/pool.rs:41:5: pub fn acquire(&self, timeout: Duration) -> Result<Conn>"
+        );
+    }
+
+    #[test]
+    fn test_quickfix_line_without_signature() {
+        let r = result("\n\tconst  MAX_CONNS = 16");
+        assert_eq!(
+            quickfix_line(&r, None),
+            "src/pool.rs:41:2: const MAX_CONNS = 16"
+        );
+
+        // A signature missing from the content falls back the same way
+        assert_eq!(
+            quickfix_line(&r, Some("fn elsewhere()")),
+            "src/pool.rs:41:2: const MAX_CONNS = 16"
+        );
+    }
+
+    #[test]
+    fn test_quickfix_line_joins_multiline_signature() {
+        let r = result("func (p *Pool) Acquire(\n\tctx context.Context,\n) (*Conn, error) {\n}");
+        assert_eq!(
+            quickfix_line(
+                &r,
+                Some("func (p *Pool) Acquire(\n\tctx context.Context,\n) (*Conn, error)")
+            ),
+            "src/pool.rs:40:1: func (p *Pool) Acquire( ctx context.Context, ) (*Conn, error)"
+        );
+    }
+
+    #[test]
+    fn test_output_format_parse() {
+        assert_eq!(
+            OutputFormat::parse("QuickFix"),
+            Some(OutputFormat::Quickfix)
+        );
+        assert_eq!(OutputFormat::parse("text"), Some(OutputFormat::Text));
+        assert_eq!(OutputFormat::parse("JSON"), Some(OutputFormat::Json));
+        assert_eq!(OutputFormat::parse("html"), Some(OutputFormat::Html));
+        assert_eq!(OutputFormat::parse("xml"), None);
+        assert_eq!(OutputFormat::Quickfix.as_str(), "quickfix");
+    }
+}
@@ -0,0 +1,230 @@
+//! Snippet anchoring
+//!
+//! A result's preview normally shows the first lines of its chunk, which
+//! for a large symbol is the signature and setup rather than the code that
+//! matched. Anchored at the match, the preview instead shows the line
+//! matching the most query words with `context` lines around it, labeled
+//! with the declaration of the enclosing symbol.
+//!
+//! The match line is found lexically: query words are matched as
+//! case-insensitive substrings of each line, so `retry` finds
+//! `maxRetries`. When no line matches, or the match is already within the
+//! symbol-start preview, the preview starts at the symbol.
+
+use serde::{Deserialize, Serialize};
+
+/// Lines shown by a symbol-anchored preview
+pub const SYMBOL_PREVIEW_LINES: usize = 5;
+
+/// Default lines shown on each side of the match line
+pub const DEFAULT_SNIPPET_CONTEXT: usize = 2;
+
+/// Query words too common to locate a match
+const STOP_WORDS: &[&str] = &[
+    "the", "and", "for", "with", "how", "what", "where", "when", "does", "from", "that", "this",
+];
+
+/// Where a result preview starts
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "kebab-case")]
+pub enum SnippetAnchor {
+    /// The start of the chunk's symbol
+    #[default]
+    Symbol,
+    /// The line matching the query best
+    Match,
+}
+
+impl SnippetAnchor {
+    /// Parse anchor from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "symbol" => Some(Self::Symbol),
+            "match" => Some(Self::Match),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Symbol => "symbol",
+            Self::Match => "match",
+        }
+    }
+}
+
+/// The lines of a chunk shown as a result preview
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Snippet {
+    /// Index of the first shown line in the chunk
+    pub first: usize,
+    /// Index past the last shown line
+    pub end: usize,
+    /// Number of lines in the chunk
+    pub total: usize,
+    /// Declaration of the enclosing symbol, when the snippet starts below it
+    pub symbol: Option<String>,
+}
+
+impl Snippet {
+    /// Snippet of `content` for `query`, anchored at the symbol start or at
+    /// the best matching line with `context` lines on each side
+    pub fn new(content: &str, query: &str, anchor: SnippetAnchor, context: usize) -> Self {
+        let lines: Vec<&str> = content.lines().collect();
+        let total = lines.len();
+        let at_symbol = Self {
+            first: 0,
+            end: total.min(SYMBOL_PREVIEW_LINES),
+            total,
+            symbol: None,
+        };
+        if anchor == SnippetAnchor::Symbol {
+            return at_symbol;
+        }
+
+        match match_line(&lines, query) {
+            Some(line) if line >= at_symbol.end => {
+                let first = line.saturating_sub(context);
+                Self {
+                    first,
+                    end: total.min(line + context + 1),
+                    total,
+                    symbol: (first > 0).then(|| declaration(&lines)).flatten(),
+                }
+            }
+            _ => at_symbol,
+        }
+    }
+
+    /// Whether chunk lines are left out before the snippet
+    pub fn skips_before(&self) -> bool {
+        self.first > 0
+    }
+
+    /// Whether chunk lines are left out after the snippet
+    pub fn skips_after(&self) -> bool {
+        self.end < self.total
+    }
+}
+
+/// Index of the line containing the most distinct query words; the first
+/// such line on ties, `None` when no line contains any
+pub fn match_line(lines: &[&str], query: &str) -> Option<usize> {
+    let words = query_words(query);
+    lines
+        .iter()
+        .enumerate()
+        .map(|(i, line)| {
+            let line = line.to_lowercase();
+            (
+                i,
+                words.iter().filter(|w| line.contains(w.as_str())).count(),
+            )
+        })
+        .filter(|&(_, hits)| hits > 0)
+        // Reversed so `max_by_key` keeps the first line of equal counts
+        .rev()
+        .max_by_key(|&(_, hits)| hits)
+        .map(|(i, _)| i)
+}
+
+/// Distinct lowercase words of a query that can locate a match
+fn query_words(query: &str) -> Vec<String> {
+    let mut words: Vec<String> = Vec::new();
+    for word in query.split(|c: char| !c.is_alphanumeric()) {
+        let word = word.to_lowercase();
+        if word.len() >= 2 && !STOP_WORDS.contains(&word.as_str()) && !words.contains(&word) {
+            words.push(word);
+        }
+    }
+    words
+}
+
+/// First non-blank line after doc comments, attributes and decorators,
+/// without a trailing `{` or `:`
+fn declaration(lines: &[&str]) -> Option<String> {
+    let line = lines
+        .iter()
+        .map(|l| l.trim())
+        .find(|l| !l.is_empty() && !["//", "/*", "#[", "@"].iter().any(|p| l.starts_with(p)))?;
+    Some(line.trim_end_matches(['{', ':']).trim_end().to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// A 40-line function whose retry logic sits at line 31
+    fn large_function() -> String {
+        let mut lines = vec!["func (w *Worker) Run(ctx context.Context) error {".to_string()];
+        for i in 1..30 {
+            lines.push(format!("\tstep{}(ctx)", i));
+        }
+        lines.push("\tdelay := backoff.Next(attempt)".to_string());
+        lines.push("\tif err := w.retryWithBackoff(ctx, delay); err != nil {".to_string());
+        for i in 32..39 {
+            lines.push(format!("\tcleanup{}()", i));
+        }
+        lines.push("}".to_string());
+        lines.join("\n")
+    }
+
+    #[test]
+    fn test_deep_match_snippet_starts_near_match_line() {
+        let content = large_function();
+        let query = "retry with backoff";
+
+        let snippet = Snippet::new(&content, query, SnippetAnchor::Match, 2);
+        // Line 31 matches both words; line 30 only one
+        assert_eq!(
+            match_line(&content.lines().collect::<Vec<_>>(), query),
+            Some(31)
+        );
+        assert_eq!((snippet.first, snippet.end), (29, 34));
+        assert!(snippet.skips_before() && snippet.skips_after());
+        assert_eq!(
+            snippet.symbol.as_deref(),
+            Some("func (w *Worker) Run(ctx context.Context) error")
+        );
+
+        // The default keeps the symbol start
+        let snippet = Snippet::new(&content, query, SnippetAnchor::Symbol, 2);
+        assert_eq!((snippet.first, snippet.end, snippet.symbol), (0, 5, None));
+
+        // Docs and attributes heading the chunk are not the declaration
+        let documented = format!("/// Runs the worker.\n#[instrument]\n{}", content);
+        let snippet = Snippet::new(&documented, query, SnippetAnchor::Match, 2);
+        assert_eq!(
+            snippet.symbol.as_deref(),
+            Some("func (w *Worker) Run(ctx context.Context) error")
+        );
+    }
+
+    #[test]
+    fn test_shallow_or_missing_match_keeps_symbol_start() {
+        let content = large_function();
+        let at_symbol = Snippet::new(&content, "worker run", SnippetAnchor::Symbol, 2);
+        assert_eq!(
+            Snippet::new(&content, "worker run", SnippetAnchor::Match, 2),
+            at_symbol
+        );
+        assert_eq!(
+            Snippet::new(&content, "how does the parser", SnippetAnchor::Match, 2),
+            at_symbol
+        );
+
+        // Context is clamped to the chunk
+        let snippet = Snippet::new(&content, "cleanup38", SnippetAnchor::Match, 5);
+        assert_eq!((snippet.first, snippet.end), (33, 40));
+        assert!(!snippet.skips_after());
+    }
+
+    #[test]
+    fn test_anchor_names() {
+        for anchor in [SnippetAnchor::Symbol, SnippetAnchor::Match] {
+            assert_eq!(SnippetAnchor::parse(anchor.as_str()), Some(anchor));
+        }
+        assert_eq!(SnippetAnchor::parse("line"), None);
+    }
+}
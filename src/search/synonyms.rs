@@ -0,0 +1,147 @@
+//! Acronym and synonym expansion for queries
+//!
+//! Project vocabulary often differs from the identifiers in the code: a team
+//! may say "wp" where the code says `WorkerPool`. A [`SynonymMap`] built from
+//! the `[search.synonyms]` config table appends the other spellings of any
+//! term it finds in a query, so the lexical (BM25) search can match them.
+//! Expansion is deterministic and needs no model.
+//!
+//! Mappings are bidirectional: `wp = ["worker pool"]` expands "wp" to
+//! "worker pool" and "worker pool" to "wp". Multi-word terms are also added
+//! in joined form (`workerpool`) because the BM25 tokenizer keeps
+//! `WorkerPool` as a single lowercased token.
+
+use std::collections::BTreeMap;
+
+/// Bidirectional map of interchangeable query terms
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct SynonymMap {
+    /// Groups of interchangeable terms, each term a sequence of lowercase words
+    groups: Vec<Vec<Vec<String>>>,
+}
+
+impl SynonymMap {
+    /// Build a map from config entries of `term = [alternatives...]`.
+    ///
+    /// Each entry forms one group; a term found in a query expands to every
+    /// other term of its group.
+    pub fn new(entries: &BTreeMap<String, Vec<String>>) -> Self {
+        let groups = entries
+            .iter()
+            .map(|(term, alternatives)| {
+                let mut group: Vec<Vec<String>> = Vec::new();
+                for term in std::iter::once(term).chain(alternatives) {
+                    let words = words(term);
+                    if !words.is_empty() && !group.contains(&words) {
+                        group.push(words);
+                    }
+                }
+                group
+            })
+            .filter(|group| group.len() > 1)
+            .collect();
+        Self { groups }
+    }
+
+    /// Whether the map has no mappings
+    pub fn is_empty(&self) -> bool {
+        self.groups.is_empty()
+    }
+
+    /// Terms to add to a query: the alternatives of every mapped term it
+    /// contains, excluding words already in the query.
+    pub fn expansions(&self, query: &str) -> Vec<String> {
+        let query_words = words(query);
+        let mut expansions: Vec<String> = Vec::new();
+        let mut add = |term: String| {
+            if !query_words.contains(&term) && !expansions.contains(&term) {
+                expansions.push(term);
+            }
+        };
+
+        for group in &self.groups {
+            if !group.iter().any(|term| contains_phrase(&query_words, term)) {
+                continue;
+            }
+            for term in group {
+                if contains_phrase(&query_words, term) {
+                    continue;
+                }
+                add(term.join(" "));
+                if term.len() > 1 {
+                    add(term.concat());
+                }
+            }
+        }
+
+        expansions
+    }
+
+    /// The query with the expansions appended, or the query unchanged when
+    /// nothing matches.
+    pub fn expand(&self, query: &str) -> String {
+        let expansions = self.expansions(query);
+        if expansions.is_empty() {
+            return query.to_string();
+        }
+        format!("{} {}", query, expansions.join(" "))
+    }
+}
+
+/// Lowercase words of a term, split on anything but letters and digits
+fn words(text: &str) -> Vec<String> {
+    text.split(|c: char| !c.is_alphanumeric())
+        .filter(|w| !w.is_empty())
+        .map(str::to_lowercase)
+        .collect()
+}
+
+/// Whether `phrase` occurs as consecutive words of `words`
+fn contains_phrase(words: &[String], phrase: &[String]) -> bool {
+    !phrase.is_empty() && words.windows(phrase.len()).any(|window| window == phrase)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn map(entries: &[(&str, &[&str])]) -> SynonymMap {
+        let entries = entries
+            .iter()
+            .map(|(k, v)| (k.to_string(), v.iter().map(|s| s.to_string()).collect()))
+            .collect();
+        SynonymMap::new(&entries)
+    }
+
+    #[test]
+    fn test_acronym_expands_to_identifier_forms() {
+        let synonyms = map(&[("wp", &["worker pool"])]);
+
+        assert_eq!(
+            synonyms.expand("wp shutdown"),
+            "wp shutdown worker pool workerpool"
+        );
+    }
+
+    #[test]
+    fn test_mapping_is_bidirectional() {
+        let synonyms = map(&[("wp", &["worker pool"])]);
+
+        assert_eq!(synonyms.expansions("Worker Pool shutdown"), vec!["wp"]);
+    }
+
+    #[test]
+    fn test_unmatched_query_is_unchanged() {
+        let synonyms = map(&[("wp", &["worker pool"]), ("ctx", &["context"])]);
+
+        assert_eq!(synonyms.expand("shutdown workers"), "shutdown workers");
+        // Partial phrases and substrings do not match
+        assert!(synonyms.expansions("pool wps").is_empty());
+    }
+
+    #[test]
+    fn test_empty_map() {
+        assert!(SynonymMap::new(&BTreeMap::new()).is_empty());
+        assert!(map(&[("wp", &[])]).is_empty());
+    }
+}
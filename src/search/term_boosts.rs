@@ -0,0 +1,118 @@
+//! Per-language term boosts for lexical search
+//!
+//! The BM25 index stores each chunk's identifiers a second time, grouped by
+//! the role the parser gave them (see
+//! [`crate::indexer::ast_chunker::token_roles`]). A rule from
+//! `[search.term_boosts.<language>]` such as `type = 2.0` adds the weighted
+//! score of query terms matching that role to chunks of that language, so
+//! a chunk declaring the `Pool` type outranks one with a `pool` local.
+//!
+//! Boosts add to the plain content score: a role without a rule, or with a
+//! boost of 0, ranks as before. Rules apply at query time, so changing them
+//! needs no reindex.
+
+use anyhow::{bail, Result};
+use std::collections::BTreeMap;
+
+use crate::indexer::ast_chunker::token_roles::TokenRole;
+
+/// Boost for matches on identifiers of one role in one language
+#[derive(Debug, Clone, PartialEq)]
+pub struct TermBoost {
+    /// Chunk language, lowercase (e.g. `go`)
+    pub language: String,
+    pub role: TokenRole,
+    pub boost: f32,
+}
+
+/// Term boost rules, in config order
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct TermBoosts {
+    rules: Vec<TermBoost>,
+}
+
+impl TermBoosts {
+    /// Build rules from config entries of `language -> { role = boost }`.
+    ///
+    /// Fails on unknown roles and on negative or non-finite boosts; rules
+    /// with a boost of 0 are dropped.
+    pub fn new(entries: &BTreeMap<String, BTreeMap<String, f32>>) -> Result<Self> {
+        let mut rules = Vec::new();
+        for (language, roles) in entries {
+            for (name, &boost) in roles {
+                let Some(role) = TokenRole::parse(name) else {
+                    bail!(
+                        "Unknown token role '{}' in search.term_boosts.{}. Use type, function, field or local",
+                        name,
+                        language
+                    );
+                };
+                if !boost.is_finite() || boost < 0.0 {
+                    bail!(
+                        "Invalid boost {} for search.term_boosts.{}.{}: must be 0 or more",
+                        boost,
+                        language,
+                        name
+                    );
+                }
+                if boost > 0.0 {
+                    rules.push(TermBoost {
+                        language: language.trim().to_lowercase(),
+                        role,
+                        boost,
+                    });
+                }
+            }
+        }
+        Ok(Self { rules })
+    }
+
+    /// Whether there are no rules
+    pub fn is_empty(&self) -> bool {
+        self.rules.is_empty()
+    }
+
+    /// The rules
+    pub fn rules(&self) -> &[TermBoost] {
+        &self.rules
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn entries(rules: &[(&str, &str, f32)]) -> BTreeMap<String, BTreeMap<String, f32>> {
+        let mut entries: BTreeMap<String, BTreeMap<String, f32>> = BTreeMap::new();
+        for (language, role, boost) in rules {
+            entries
+                .entry(language.to_string())
+                .or_default()
+                .insert(role.to_string(), *boost);
+        }
+        entries
+    }
+
+    #[test]
+    fn test_term_boost_rules() {
+        let boosts =
+            TermBoosts::new(&entries(&[("Go", "type", 2.0), ("go", "local", 0.0)])).unwrap();
+        assert_eq!(
+            boosts.rules(),
+            &[TermBoost {
+                language: "go".to_string(),
+                role: TokenRole::Type,
+                boost: 2.0,
+            }]
+        );
+        assert!(TermBoosts::new(&BTreeMap::new()).unwrap().is_empty());
+    }
+
+    #[test]
+    fn test_invalid_term_boosts() {
+        let err = TermBoosts::new(&entries(&[("rust", "keyword", 1.0)])).unwrap_err();
+        assert!(err.to_string().contains("search.term_boosts.rust"));
+        assert!(TermBoosts::new(&entries(&[("rust", "type", -1.0)])).is_err());
+        assert!(TermBoosts::new(&entries(&[("rust", "type", f32::NAN)])).is_err());
+    }
+}
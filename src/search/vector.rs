@@ -1,29 +1,62 @@
 use anyhow::{Context, Result};
 use async_trait::async_trait;
+use std::collections::HashMap;
 use std::sync::Arc;
 use std::time::Instant;
 use tracing::{debug, info};
 
+use super::candidates::CandidatePool;
+use super::field_weights::{field_similarities, rank_by_fields, Field, FieldWeights};
+use super::processor::{DedupFiles, ResultProcessor};
+use super::provenance::{record_source, RetrievalPath};
+use super::synonyms::SynonymMap;
 use super::traits::Search;
 use crate::embeddings::EmbeddingGenerator;
 use crate::metrics::{SEARCH_LATENCY, SEARCH_REQUESTS, SEARCH_RESULTS};
-use crate::storage::Storage;
+use crate::storage::{SearchFilter, VectorField, VectorStore};
 
 pub use crate::storage::SearchResult;
 
+/// Chunks of each field searched per candidate by
+/// [`SearchEngine::search_weighted`], so that most candidates are among the
+/// field matches
+const FIELD_SEARCH_FACTOR: usize = 4;
+
 /// Search engine for semantic code search using vector embeddings.
 ///
 /// This engine converts queries into embeddings and performs
 /// similarity search against indexed code chunks.
 pub struct SearchEngine {
-    storage: Arc<Storage>,
+    storage: Arc<dyn VectorStore>,
     embedder: Arc<EmbeddingGenerator>,
+    /// Acronyms and synonyms appended to the text that is embedded
+    synonyms: SynonymMap,
+    /// Weight of chunk summary similarity in vector search scores
+    summary_weight: f32,
 }
 
 impl SearchEngine {
     /// Create a new SearchEngine with the given storage and embedder
-    pub fn new(storage: Arc<Storage>, embedder: Arc<EmbeddingGenerator>) -> Self {
-        Self { storage, embedder }
+    pub fn new(storage: Arc<dyn VectorStore>, embedder: Arc<EmbeddingGenerator>) -> Self {
+        Self {
+            storage,
+            embedder,
+            synonyms: SynonymMap::default(),
+            summary_weight: 0.0,
+        }
+    }
+
+    /// Append acronym/synonym expansions to queries before embedding them.
+    pub fn with_synonyms(mut self, synonyms: SynonymMap) -> Self {
+        self.synonyms = synonyms;
+        self
+    }
+
+    /// Blend the similarity of chunk summaries into vector search scores,
+    /// with `weight` from 0 (off) to 1, when the index has summaries.
+    pub fn with_summary_weight(mut self, weight: f32) -> Self {
+        self.summary_weight = weight.clamp(0.0, 1.0);
+        self
     }
 
     /// Search and deduplicate results by file
@@ -33,33 +66,49 @@ impl SearchEngine {
         // Get more results to ensure we have enough unique files
         let results = self.search(query, limit * 3).await?;
 
-        let mut seen_files = std::collections::HashSet::new();
-        let unique_results: Vec<SearchResult> = results
-            .into_iter()
-            .filter(|r| seen_files.insert(r.file_path.clone()))
-            .take(limit)
-            .collect();
+        let mut unique_results = DedupFiles.process(query, results);
+        unique_results.truncate(limit);
 
         Ok(unique_results)
     }
 
-    /// Get a reference to the underlying storage
-    pub fn storage(&self) -> &Arc<Storage> {
-        &self.storage
+    /// Semantic search restricted to chunks of one semantic kind
+    /// (e.g. `function`, `client-method`)
+    pub async fn search_by_kind(
+        &self,
+        query: &str,
+        limit: usize,
+        kind: &str,
+    ) -> Result<Vec<SearchResult>> {
+        let filter = SearchFilter {
+            kind: Some(kind.to_string()),
+            ..Default::default()
+        };
+        self.search_filtered(query, limit, &filter).await
     }
 
-    /// Get a reference to the embedder
-    pub fn embedder(&self) -> &Arc<EmbeddingGenerator> {
-        &self.embedder
+    /// Embed the query and search chunks matching a metadata filter
+    /// (semantic kind and/or tag)
+    pub async fn search_filtered(
+        &self,
+        query: &str,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.search_candidates(query, limit, filter, &CandidatePool::exact(limit))
+            .await
     }
-}
 
-#[async_trait]
-impl Search for SearchEngine {
-    /// Perform semantic search for the given query
-    ///
-    /// Returns results sorted by relevance (highest score first)
-    async fn search(&self, query: &str, limit: usize) -> Result<Vec<SearchResult>> {
+    /// Like [`search_filtered`](Self::search_filtered), but retrieve the
+    /// whole candidate pool for `limit` results, for the caller to re-rank
+    /// and truncate.
+    pub async fn search_candidates(
+        &self,
+        query: &str,
+        limit: usize,
+        filter: &SearchFilter,
+        pool: &CandidatePool,
+    ) -> Result<Vec<SearchResult>> {
         // Record search request metric
         SEARCH_REQUESTS.inc();
         let start = Instant::now();
@@ -67,24 +116,44 @@ impl Search for SearchEngine {
         info!(search_type = "vector", query = query, "Starting vector search");
 
         // Generate query embedding (use async version to avoid runtime nesting)
+        let expanded = self.synonyms.expand(query);
         let query_vector = self
             .embedder
-            .embed_query_async(query)
+            .embed_query_async(&expanded)
             .await
             .with_context(|| format!("Failed to embed query: {}", query))?;
 
         debug!("Generated query embedding with {} dimensions", query_vector.len());
 
-        // Perform vector search
-        let mut results = self
-            .storage
-            .search(query_vector, limit)
-            .await
-            .with_context(|| "Failed to perform vector search")?;
+        // Perform vector search, growing the pool if it is dynamic
+        let query_vector = &query_vector;
+        let mut results = pool
+            .fetch(limit, |size| async move {
+                let mut results = self
+                    .storage
+                    .query(query_vector.clone(), size, filter)
+                    .await
+                    .with_context(|| "Failed to perform vector search")?;
+
+                // Results are already sorted by score from the store
+                // But let's ensure they're sorted descending by score
+                results.sort_by(|a, b| {
+                    b.score
+                        .partial_cmp(&a.score)
+                        .unwrap_or(std::cmp::Ordering::Equal)
+                });
+                Ok(results)
+            })
+            .await?;
+        record_source(&mut results, RetrievalPath::Vector, query, &expanded);
 
-        // Results are already sorted by score from LanceDB
-        // But let's ensure they're sorted descending by score
-        results.sort_by(|a, b| b.score.partial_cmp(&a.score).unwrap_or(std::cmp::Ordering::Equal));
+        if self.summary_weight > 0.0 && self.storage.has_field_vectors(VectorField::Summary).await?
+        {
+            let summaries = self
+                .search_field(VectorField::Summary, query, limit, filter, pool)
+                .await?;
+            results = blend_summaries(results, summaries, self.summary_weight);
+        }
 
         // Record latency and result count metrics
         let elapsed = start.elapsed();
@@ -102,7 +171,226 @@ impl Search for SearchEngine {
         Ok(results)
     }
 
+    /// Search, then re-rank by weighted per-field similarity
+    ///
+    /// Takes the candidate pool for `limit` results from the vector search
+    /// and scores each candidate by the weighted similarity of its doc,
+    /// body and structure vectors, stored with
+    /// `indexer.embed_weighted_fields`, to the query.
+    pub async fn search_weighted(
+        &self,
+        query: &str,
+        limit: usize,
+        filter: &SearchFilter,
+        weights: &FieldWeights,
+        pool: &CandidatePool,
+    ) -> Result<Vec<SearchResult>> {
+        let mut results = self.search_candidates(query, limit, filter, pool).await?;
+        if results.is_empty() {
+            return Ok(results);
+        }
+
+        let query_vector = self
+            .embedder
+            .embed_query_async(&self.synonyms.expand(query))
+            .await
+            .with_context(|| format!("Failed to embed query: {}", query))?;
+
+        // Score the candidates' fields by their stored field vectors; fields
+        // without weight are not searched
+        let field_limit = results.len() * FIELD_SEARCH_FACTOR;
+        let mut matches: [Option<Vec<SearchResult>>; 3] = Default::default();
+        for (field, field_matches) in Field::ALL.iter().zip(&mut matches) {
+            if weights.get(*field) == 0.0 {
+                continue;
+            }
+            let field = field.vector_field();
+            *field_matches = Some(
+                self.storage
+                    .query_field(field, query_vector.clone(), field_limit, filter)
+                    .await
+                    .with_context(|| format!("Failed to search the {} field", field.as_str()))?,
+            );
+        }
+        let similarities = field_similarities(&results, &matches, field_limit);
+
+        rank_by_fields(&mut results, &similarities, weights);
+        results.truncate(limit);
+        debug!("Re-ranked {} results by field weights {:?}", results.len(), weights);
+
+        Ok(results)
+    }
+
+    /// Search one field only, using the field vectors stored when it is
+    /// enabled (e.g. `indexer.embed_name_variants` for names)
+    ///
+    /// The query is embedded as written, without synonym expansion, since
+    /// field queries target a short text closely. Returns the candidate
+    /// pool for `limit` results.
+    pub async fn search_field(
+        &self,
+        field: VectorField,
+        query: &str,
+        limit: usize,
+        filter: &SearchFilter,
+        pool: &CandidatePool,
+    ) -> Result<Vec<SearchResult>> {
+        let query_vector = self
+            .embedder
+            .embed_query_async(query)
+            .await
+            .with_context(|| format!("Failed to embed query: {}", query))?;
+
+        let query_vector = &query_vector;
+        let mut results = pool
+            .fetch(limit, |size| async move {
+                let mut results = self
+                    .storage
+                    .query_field(field, query_vector.clone(), size, filter)
+                    .await
+                    .with_context(|| format!("Failed to search the {} field", field.as_str()))?;
+                results.sort_by(|a, b| b.score.total_cmp(&a.score));
+                Ok(results)
+            })
+            .await?;
+        record_source(&mut results, RetrievalPath::Field(field), query, query);
+        debug!("Field search returned {} results", results.len());
+
+        Ok(results)
+    }
+
+    /// Keyword and vector search run by the store itself, matching
+    /// `keywords` and the embedded query, with `alpha` weighting the vector
+    /// side (0 is pure keyword, 1 pure vector)
+    ///
+    /// Returns None without embedding the query when the store has no
+    /// hybrid search of its own.
+    pub async fn search_hybrid(
+        &self,
+        query: &str,
+        keywords: &str,
+        limit: usize,
+        filter: &SearchFilter,
+        alpha: f32,
+    ) -> Result<Option<Vec<SearchResult>>> {
+        if !self.storage.supports_hybrid_query() {
+            return Ok(None);
+        }
+
+        let expanded = self.synonyms.expand(query);
+        let query_vector = self
+            .embedder
+            .embed_query_async(&expanded)
+            .await
+            .with_context(|| format!("Failed to embed query: {}", query))?;
+
+        let results = self
+            .storage
+            .hybrid_query(keywords, query_vector, limit, filter, alpha)
+            .await
+            .with_context(|| "Failed to perform hybrid search")?;
+        Ok(results.map(|mut results| {
+            results.sort_by(|a, b| b.score.total_cmp(&a.score));
+            // The store's fused score stands for both paths
+            record_source(&mut results, RetrievalPath::Vector, query, &expanded);
+            record_source(&mut results, RetrievalPath::Lexical, query, keywords);
+            results
+        }))
+    }
+
+    /// Get a reference to the underlying storage
+    pub fn storage(&self) -> &Arc<dyn VectorStore> {
+        &self.storage
+    }
+
+    /// Get a reference to the embedder
+    pub fn embedder(&self) -> &Arc<EmbeddingGenerator> {
+        &self.embedder
+    }
+}
+
+/// Score each chunk `(1 - weight) × code similarity + weight × summary
+/// similarity`, a chunk missing from one list scoring 0 there, and sort by
+/// the blended score
+fn blend_summaries(
+    results: Vec<SearchResult>,
+    summaries: Vec<SearchResult>,
+    weight: f32,
+) -> Vec<SearchResult> {
+    let key = |r: &SearchResult| (r.file_path.clone(), r.start_line, r.end_line);
+    let mut blended: Vec<SearchResult> = Vec::with_capacity(results.len() + summaries.len());
+    let mut positions = HashMap::new();
+
+    for mut result in results {
+        result.score *= 1.0 - weight;
+        positions.insert(key(&result), blended.len());
+        blended.push(result);
+    }
+    for summary in summaries {
+        match positions.get(&key(&summary)) {
+            Some(&i) => {
+                blended[i].score += weight * summary.score;
+                blended[i].sources.extend(summary.sources);
+            }
+            None => {
+                let mut summary = summary;
+                summary.score *= weight;
+                positions.insert(key(&summary), blended.len());
+                blended.push(summary);
+            }
+        }
+    }
+
+    blended.sort_by(|a, b| b.score.total_cmp(&a.score));
+    blended
+}
+
+#[async_trait]
+impl Search for SearchEngine {
+    /// Perform semantic search for the given query
+    ///
+    /// Returns results sorted by relevance (highest score first)
+    async fn search(&self, query: &str, limit: usize) -> Result<Vec<SearchResult>> {
+        self.search_filtered(query, limit, &SearchFilter::default()).await
+    }
+
     fn search_type(&self) -> &'static str {
         "vector"
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn result(file_path: &str, score: f32) -> SearchResult {
+        SearchResult {
+            content: String::new(),
+            file_path: file_path.to_string(),
+            start_line: 1,
+            end_line: 5,
+            score,
+            file_header: None,
+            semantic_kind: None,
+            sources: Vec::new(),
+            duplicates: Vec::new(),
+        }
+    }
+
+    #[test]
+    fn test_blend_summaries() {
+        let results = vec![result("retry.go", 0.6), result("card.go", 0.5)];
+        let summaries = vec![result("card.go", 0.9), result("ledger.go", 0.8)];
+
+        let blended = blend_summaries(results, summaries, 0.5);
+
+        let scores: Vec<(&str, f32)> = blended
+            .iter()
+            .map(|r| (r.file_path.as_str(), r.score))
+            .collect();
+        assert_eq!(
+            scores,
+            vec![("card.go", 0.7), ("ledger.go", 0.4), ("retry.go", 0.3)]
+        );
+    }
+}
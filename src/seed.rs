@@ -0,0 +1,93 @@
+//! Seed for arbitrary choices in ranking.
+//!
+//! Indexing and search use no random components: chunk IDs are derived from
+//! chunk keys ([`crate::storage::DeterministicIds`]), vector search scans
+//! the table without an approximate index, and result clustering is
+//! agglomerative. What remained arbitrary was the order of results with equal
+//! fused scores in hybrid search, which followed hash map iteration order
+//! and so changed from run to run.
+//!
+//! Such ties are broken by a hash of each result's location mixed with a
+//! seed, so identical inputs always rank identically. The seed is set by the
+//! global `--seed` flag or the `CODERAG_SEED` environment variable, in that
+//! order, and defaults to [`DEFAULT_SEED`]. Changing it reshuffles ties only.
+//!
+//! The same hash, unseeded, is [`stable_hash`], for values that are written
+//! to disk and must match when read back by another build.
+
+use std::sync::Mutex;
+
+/// Environment variable that sets the seed
+pub const SEED_ENV_VAR: &str = "CODERAG_SEED";
+
+/// Seed used when none is selected
+pub const DEFAULT_SEED: u64 = 0;
+
+static SELECTED: Mutex<Option<u64>> = Mutex::new(None);
+
+/// Use `seed` for the rest of the process, overriding the environment.
+pub fn select(seed: u64) {
+    *SELECTED.lock().unwrap_or_else(|e| e.into_inner()) = Some(seed);
+}
+
+/// The seed selected by [`select`] or the environment, else
+/// [`DEFAULT_SEED`]. An environment value that is not a number is ignored.
+pub fn current() -> u64 {
+    if let Some(seed) = *SELECTED.lock().unwrap_or_else(|e| e.into_inner()) {
+        return seed;
+    }
+    std::env::var(SEED_ENV_VAR)
+        .ok()
+        .and_then(|v| v.trim().parse().ok())
+        .unwrap_or(DEFAULT_SEED)
+}
+
+/// Stable hash of `key` under `seed`, for ordering ties.
+///
+/// Unlike the standard library's hashers, the result is the same in every
+/// process and on every platform.
+pub fn tie_breaker(seed: u64, key: &str) -> u64 {
+    mix(seed.to_le_bytes().iter().chain(key.as_bytes()))
+}
+
+/// Stable hash of `key`, the same in every process, platform and Rust
+/// release, for hashes that are persisted.
+pub fn stable_hash(key: &str) -> u64 {
+    mix(key.as_bytes().iter())
+}
+
+fn mix<'a>(bytes: impl Iterator<Item = &'a u8>) -> u64 {
+    // FNV-1a over the bytes, then a splitmix64 finalizer so that nearby
+    // inputs give unrelated hashes
+    let mut hash: u64 = 0xcbf2_9ce4_8422_2325;
+    for byte in bytes {
+        hash ^= u64::from(*byte);
+        hash = hash.wrapping_mul(0x0100_0000_01b3);
+    }
+    hash ^= hash >> 30;
+    hash = hash.wrapping_mul(0xbf58_476d_1ce4_e5b9);
+    hash ^= hash >> 27;
+    hash = hash.wrapping_mul(0x94d0_49bb_1331_11eb);
+    hash ^ (hash >> 31)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_tie_breaker_is_stable() {
+        let key = "src/a.rs:1:9";
+        assert_eq!(tie_breaker(7, key), tie_breaker(7, key));
+        assert_ne!(tie_breaker(7, key), tie_breaker(8, key));
+        assert_ne!(tie_breaker(7, key), tie_breaker(7, "src/b.rs:1:9"));
+        // Fixed across processes and platforms
+        assert_eq!(tie_breaker(42, "src/pool.rs:10:20"), 0xd7b0_c274_7550_cec2);
+    }
+
+    #[test]
+    fn test_stable_hash_is_fixed() {
+        assert_eq!(stable_hash("fn main() {}"), 0xcd8d_73ce_6d11_e223);
+        assert_ne!(stable_hash("a"), stable_hash("b"));
+    }
+}
@@ -0,0 +1,151 @@
+//! Pluggable chunk ID generation
+//!
+//! Every stored chunk is keyed by a string ID, which is also the ID that
+//! symbol lookups and BM25 documents refer to. Teams that feed coderag's
+//! output into their own pipelines can align these IDs with their keying
+//! scheme by implementing [`IdGenerator`] and passing it to the indexer
+//! (`ParallelIndexer::with_id_generator`).
+//!
+//! # Contract
+//!
+//! An ID generator must be:
+//!
+//! - **Deterministic**: the same [`ChunkKey`] always produces the same ID,
+//!   across runs and machines, so re-indexing an unchanged chunk keeps its ID
+//! - **Collision-resistant**: different keys produce different IDs; chunks
+//!   that differ in any key field (including the git ref they were indexed
+//!   from) must not share an ID
+//! - **Pure**: IDs depend only on the key, never on indexing order or time
+//!
+//! IDs are stored as-is and should be short, printable strings.
+//! The default, [`DeterministicIds`], is a UUIDv5 over every key field.
+
+use uuid::Uuid;
+
+use super::IndexedChunk;
+
+/// Namespace of the UUIDv5 chunk IDs generated by [`DeterministicIds`]
+pub const CHUNK_ID_NAMESPACE: Uuid = Uuid::from_u128(0x6c1d_2a3e_8f4b_5c6d_9e0f_1a2b_3c4d_5e6f);
+
+/// The fields that identify a chunk
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct ChunkKey<'a> {
+    /// Path of the file, as stored
+    pub file_path: &'a str,
+    pub start_line: usize,
+    pub end_line: usize,
+    /// Qualified symbol name, or the plain name when none was recorded
+    pub symbol: Option<&'a str>,
+    pub content: &'a str,
+    /// Git ref the chunk was indexed from (`None` for the working tree)
+    pub branch: Option<&'a str>,
+}
+
+impl<'a> ChunkKey<'a> {
+    /// The key of an indexed chunk
+    pub fn of(chunk: &'a IndexedChunk) -> Self {
+        Self {
+            file_path: &chunk.file_path,
+            start_line: chunk.start_line,
+            end_line: chunk.end_line,
+            symbol: chunk
+                .qualified_name
+                .as_deref()
+                .or(chunk.symbol_name.as_deref()),
+            content: &chunk.content,
+            branch: chunk.branch.as_deref(),
+        }
+    }
+}
+
+/// Produces the IDs chunks are stored under; see the module docs for the
+/// contract implementations must follow.
+pub trait IdGenerator: Send + Sync {
+    /// ID for the chunk identified by `key`
+    fn chunk_id(&self, key: &ChunkKey<'_>) -> String;
+}
+
+/// Default generator: a UUIDv5 in [`CHUNK_ID_NAMESPACE`] over all key
+/// fields
+#[derive(Debug, Clone, Copy, Default)]
+pub struct DeterministicIds;
+
+impl IdGenerator for DeterministicIds {
+    fn chunk_id(&self, key: &ChunkKey<'_>) -> String {
+        // Fields are NUL-separated. Only the last one, the content, may
+        // contain NUL, so distinct keys never serialize to the same bytes
+        let name = [
+            key.branch.unwrap_or_default(),
+            key.file_path,
+            &key.start_line.to_string(),
+            &key.end_line.to_string(),
+            key.symbol.unwrap_or_default(),
+            key.content,
+        ]
+        .join("\0");
+        Uuid::new_v5(&CHUNK_ID_NAMESPACE, name.as_bytes()).to_string()
+    }
+}
+
+/// Set the ID of every chunk from its key
+pub fn assign_ids(chunks: &mut [IndexedChunk], ids: &dyn IdGenerator) {
+    for chunk in chunks {
+        chunk.id = ids.chunk_id(&ChunkKey::of(chunk));
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn key<'a>(file_path: &'a str, content: &'a str) -> ChunkKey<'a> {
+        ChunkKey {
+            file_path,
+            start_line: 1,
+            end_line: 3,
+            symbol: Some("crate::pool::Pool::acquire"),
+            content,
+            branch: None,
+        }
+    }
+
+    #[test]
+    fn test_deterministic_ids_are_stable() {
+        let a = DeterministicIds.chunk_id(&key("src/pool.rs", "fn acquire() {}"));
+        let b = DeterministicIds.chunk_id(&key("src/pool.rs", "fn acquire() {}"));
+        assert_eq!(a, b);
+        assert!(Uuid::parse_str(&a).is_ok());
+    }
+
+    #[test]
+    fn test_deterministic_ids_differ_by_key_field() {
+        let base = key("src/pool.rs", "fn acquire() {}");
+        let variants = [
+            ChunkKey {
+                file_path: "src/other.rs",
+                ..base
+            },
+            ChunkKey {
+                start_line: 2,
+                ..base
+            },
+            ChunkKey {
+                symbol: None,
+                ..base
+            },
+            ChunkKey {
+                content: "fn acquire() { wait() }",
+                ..base
+            },
+            ChunkKey {
+                branch: Some("main"),
+                ..base
+            },
+        ];
+
+        let base_id = DeterministicIds.chunk_id(&base);
+        for variant in &variants {
+            assert_ne!(DeterministicIds.chunk_id(variant), base_id, "{:?}", variant);
+        }
+    }
+}
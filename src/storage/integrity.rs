@@ -0,0 +1,569 @@
+//! Index integrity checks
+//!
+//! Backs `coderag validate`. The checks read what is stored and report
+//! inconsistencies before they surface as odd search behavior:
+//!
+//! - `vector` - every chunk has a finite, non-zero vector of the store's
+//!   dimension, and the store's dimension matches the configured model
+//! - `line-range` - chunk line ranges start at 1 and do not run backwards
+//! - `file` - chunks of one file agree on its modification time, and no
+//!   chunk is stored twice
+//! - `symbol` - symbol metadata is complete (a qualified name has a name)
+//! - `bm25` - the BM25 index holds exactly the chunks in the store
+//! - `edge` - every symbol a symbol graph edge references is stored
+//! - `manifest` - the symbols the graph records for each file are the ones
+//!   the stored chunks define
+//!
+//! Chunk-level problems are repaired by dropping every chunk of the file so
+//! the next `coderag index` re-indexes it from source; a BM25 index or symbol
+//! graph that is out of sync is rebuilt from the store, and edges to symbols
+//! that are no longer stored are dropped.
+
+use serde::Serialize;
+use std::collections::{BTreeMap, BTreeSet, HashMap, HashSet};
+
+use super::IndexedChunk;
+use crate::symbol::{Edge, SymbolGraph};
+
+/// How serious an issue is
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Severity {
+    /// Search results may be wrong or missing
+    Error,
+    /// The index works but is inconsistent
+    Warning,
+}
+
+impl Severity {
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Error => "error",
+            Self::Warning => "warning",
+        }
+    }
+}
+
+/// A safe repair for an issue
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize)]
+#[serde(tag = "action", content = "target", rename_all = "kebab-case")]
+pub enum Repair {
+    /// Drop every chunk of the file so it is re-indexed from source
+    DropFile(String),
+    /// Rebuild the BM25 index from the store
+    RebuildBm25,
+    /// Drop symbol graph edges that reference symbols no longer stored
+    DropOrphanedEdges,
+    /// Rebuild the symbol graph from the store
+    RebuildGraph,
+}
+
+/// An integrity problem found in the index
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct Issue {
+    pub severity: Severity,
+    /// Name of the check that found it (`vector`, `line-range`, ...)
+    pub check: &'static str,
+    pub message: String,
+    /// The repair `--fix` applies, if the issue can be repaired safely
+    pub repair: Option<Repair>,
+}
+
+impl Issue {
+    fn error(check: &'static str, message: String, repair: Option<Repair>) -> Self {
+        Self {
+            severity: Severity::Error,
+            check,
+            message,
+            repair,
+        }
+    }
+
+    fn warning(check: &'static str, message: String, repair: Option<Repair>) -> Self {
+        Self {
+            severity: Severity::Warning,
+            check,
+            message,
+            repair,
+        }
+    }
+}
+
+/// Compare the dimension of the store with the one the configured model
+/// produces.
+///
+/// Not repairable in place: the index must be rebuilt with `index --force`.
+pub fn check_dimension(stored: usize, model: &str, model_dimension: Option<usize>) -> Vec<Issue> {
+    match model_dimension {
+        Some(expected) if expected != stored => vec![Issue::error(
+            "vector",
+            format!(
+                "Index stores {}-dimensional vectors but model '{}' produces {}; \
+                 run `coderag index --force`",
+                stored, model, expected
+            ),
+            None,
+        )],
+        _ => Vec::new(),
+    }
+}
+
+/// Check the stored chunks and their vectors (keyed by chunk id).
+///
+/// Issues are ordered by check, then file.
+pub fn check_chunks(
+    chunks: &[IndexedChunk],
+    vectors: &HashMap<String, Vec<f32>>,
+    dimension: usize,
+) -> Vec<Issue> {
+    let mut issues = Vec::new();
+    let drop = |chunk: &IndexedChunk| Some(Repair::DropFile(chunk.file_path.clone()));
+
+    for chunk in chunks {
+        let location = format!(
+            "{}:{}-{}",
+            chunk.file_path, chunk.start_line, chunk.end_line
+        );
+        match vectors.get(&chunk.id) {
+            None => issues.push(Issue::error(
+                "vector",
+                format!("{} has no readable vector", location),
+                drop(chunk),
+            )),
+            Some(vector) if vector.len() != dimension => issues.push(Issue::error(
+                "vector",
+                format!(
+                    "{} has a {}-dimensional vector, expected {}",
+                    location,
+                    vector.len(),
+                    dimension
+                ),
+                drop(chunk),
+            )),
+            Some(vector) if vector.iter().any(|x| !x.is_finite()) => issues.push(Issue::error(
+                "vector",
+                format!("{} has a vector with NaN or infinite values", location),
+                drop(chunk),
+            )),
+            Some(vector) if vector.iter().all(|x| *x == 0.0) => issues.push(Issue::warning(
+                "vector",
+                format!("{} has an all-zero vector and never matches", location),
+                drop(chunk),
+            )),
+            Some(_) => {}
+        }
+    }
+
+    for chunk in chunks {
+        if chunk.start_line == 0 {
+            issues.push(Issue::error(
+                "line-range",
+                format!("{} starts at line 0; lines are 1-based", chunk.file_path),
+                drop(chunk),
+            ));
+        } else if chunk.start_line > chunk.end_line {
+            issues.push(Issue::error(
+                "line-range",
+                format!(
+                    "{} has line range {}-{} that ends before it starts",
+                    chunk.file_path, chunk.start_line, chunk.end_line
+                ),
+                drop(chunk),
+            ));
+        }
+    }
+
+    let mut files: BTreeMap<&str, Vec<&IndexedChunk>> = BTreeMap::new();
+    for chunk in chunks {
+        files.entry(&chunk.file_path).or_default().push(chunk);
+    }
+    for (path, file_chunks) in &files {
+        let mtimes: BTreeSet<i64> = file_chunks.iter().map(|c| c.mtime).collect();
+        if mtimes.len() > 1 {
+            issues.push(Issue::warning(
+                "file",
+                format!(
+                    "{} has chunks from {} different versions of the file",
+                    path,
+                    mtimes.len()
+                ),
+                Some(Repair::DropFile(path.to_string())),
+            ));
+        }
+    }
+
+    let mut seen = HashSet::new();
+    for chunk in chunks {
+        if !seen.insert(chunk.id.as_str()) {
+            issues.push(Issue::error(
+                "file",
+                format!("Chunk {} of {} is stored twice", chunk.id, chunk.file_path),
+                drop(chunk),
+            ));
+        }
+    }
+
+    for chunk in chunks {
+        if chunk.qualified_name.is_some() && chunk.symbol_name.is_none() {
+            issues.push(Issue::warning(
+                "symbol",
+                format!(
+                    "{}:{} has a qualified name but no symbol name",
+                    chunk.file_path, chunk.start_line
+                ),
+                drop(chunk),
+            ));
+        }
+    }
+
+    issues
+}
+
+/// Compare the ids in the BM25 index (`None` when it does not exist) with
+/// the stored chunks.
+pub fn check_bm25(chunks: &[IndexedChunk], bm25_ids: Option<&[String]>) -> Vec<Issue> {
+    let Some(bm25_ids) = bm25_ids else {
+        if chunks.is_empty() {
+            return Vec::new();
+        }
+        return vec![Issue::warning(
+            "bm25",
+            "BM25 index is missing; keyword and hybrid search find nothing".to_string(),
+            Some(Repair::RebuildBm25),
+        )];
+    };
+
+    let stored: HashSet<&str> = chunks.iter().map(|c| c.id.as_str()).collect();
+    let indexed: HashSet<&str> = bm25_ids.iter().map(String::as_str).collect();
+    let missing = stored.difference(&indexed).count();
+    let orphaned = indexed.difference(&stored).count();
+    let duplicated = bm25_ids.len() - indexed.len();
+
+    let mut issues = Vec::new();
+    if missing > 0 {
+        issues.push(Issue::warning(
+            "bm25",
+            format!("{} stored chunks are missing from the BM25 index", missing),
+            Some(Repair::RebuildBm25),
+        ));
+    }
+    if orphaned > 0 {
+        issues.push(Issue::warning(
+            "bm25",
+            format!(
+                "BM25 index has {} entries for chunks that are no longer stored",
+                orphaned
+            ),
+            Some(Repair::RebuildBm25),
+        ));
+    }
+    if duplicated > 0 {
+        issues.push(Issue::warning(
+            "bm25",
+            format!("BM25 index has {} duplicate entries", duplicated),
+            Some(Repair::RebuildBm25),
+        ));
+    }
+    issues
+}
+
+/// Check the saved symbol graph (`None` when it does not exist) against
+/// `stored`, the graph built from the stored chunks.
+///
+/// An edge is orphaned when its source file or its target symbol is not
+/// stored; the graph's symbols for a file, its manifest of what the file
+/// defines, must equal those of the stored chunks.
+pub fn check_graph(stored: &SymbolGraph, saved: Option<&SymbolGraph>) -> Vec<Issue> {
+    let Some(saved) = saved else {
+        if stored
+            .files()
+            .all(|file| stored.file_symbols(file).is_empty())
+        {
+            return Vec::new();
+        }
+        return vec![Issue::warning(
+            "manifest",
+            "Symbol graph is missing; callers, callees and related symbols are unavailable"
+                .to_string(),
+            Some(Repair::RebuildGraph),
+        )];
+    };
+
+    let mut issues = Vec::new();
+    let resolver = EdgeResolver::new(stored);
+    let orphaned: Vec<&Edge> = saved.edges().filter(|e| !resolver.resolves(e)).collect();
+    if let Some(first) = orphaned.first() {
+        issues.push(Issue::warning(
+            "edge",
+            format!(
+                "Symbol graph has {} edge(s) to symbols that are not stored, \
+                 e.g. {} -> {} in {}",
+                orphaned.len(),
+                first.source,
+                first.target,
+                first.file
+            ),
+            Some(Repair::DropOrphanedEdges),
+        ));
+    }
+
+    let files: BTreeSet<&str> = saved.files().chain(stored.files()).collect();
+    for file in files {
+        let recorded = saved.file_symbols(file);
+        let expected = stored.file_symbols(file);
+        if recorded == expected {
+            continue;
+        }
+        let extra = recorded.difference(expected).count();
+        let missing = expected.difference(recorded).count();
+        issues.push(Issue::warning(
+            "manifest",
+            format!(
+                "Symbol graph records {} symbol(s) of {} that are not stored \
+                 and misses {} stored one(s)",
+                extra, file, missing
+            ),
+            Some(Repair::RebuildGraph),
+        ));
+    }
+    issues
+}
+
+/// Drop the edges of `saved` that reference symbols missing from `stored`,
+/// the graph built from the stored chunks.
+///
+/// Returns the number of edges dropped.
+pub fn drop_orphaned_edges(saved: &mut SymbolGraph, stored: &SymbolGraph) -> usize {
+    let resolver = EdgeResolver::new(stored);
+    saved.retain_edges(|edge| resolver.resolves(edge))
+}
+
+/// Decides whether an edge's symbols are stored
+struct EdgeResolver<'a> {
+    /// Files with stored symbols
+    files: HashSet<&'a str>,
+    /// Bare names of every stored symbol
+    symbols: HashSet<&'a str>,
+}
+
+impl<'a> EdgeResolver<'a> {
+    fn new(stored: &'a SymbolGraph) -> Self {
+        let files = stored
+            .files()
+            .filter(|file| !stored.file_nodes(file).is_empty())
+            .collect();
+        let symbols = stored
+            .files()
+            .flat_map(|file| stored.file_symbols(file))
+            .map(String::as_str)
+            .collect();
+        Self { files, symbols }
+    }
+
+    fn resolves(&self, edge: &Edge) -> bool {
+        self.files.contains(edge.file.as_str()) && self.symbols.contains(edge.target.as_str())
+    }
+}
+
+/// The distinct repairs for a set of issues, files first
+pub fn repairs(issues: &[Issue]) -> Vec<Repair> {
+    let repairs: BTreeSet<Repair> = issues.iter().filter_map(|i| i.repair.clone()).collect();
+    repairs.into_iter().collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const DIMENSION: usize = 4;
+
+    fn chunk(id: &str, file_path: &str, start_line: usize, end_line: usize) -> IndexedChunk {
+        IndexedChunk {
+            id: id.to_string(),
+            content: "fn f() {}".to_string(),
+            file_path: file_path.to_string(),
+            start_line,
+            end_line,
+            language: Some("rust".to_string()),
+            vector: Vec::new(),
+            mtime: 100,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some("f".to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: Some("crate::f".to_string()),
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    fn vectors(chunks: &[IndexedChunk]) -> HashMap<String, Vec<f32>> {
+        chunks
+            .iter()
+            .map(|c| (c.id.clone(), vec![0.5; DIMENSION]))
+            .collect()
+    }
+
+    #[test]
+    fn test_consistent_index_has_no_issues() {
+        let chunks = vec![chunk("a", "src/a.rs", 1, 3), chunk("b", "src/a.rs", 5, 9)];
+        let ids: Vec<String> = chunks.iter().map(|c| c.id.clone()).collect();
+
+        assert!(check_chunks(&chunks, &vectors(&chunks), DIMENSION).is_empty());
+        assert!(check_bm25(&chunks, Some(&ids)).is_empty());
+        assert!(check_dimension(DIMENSION, "model", Some(DIMENSION)).is_empty());
+    }
+
+    #[test]
+    fn test_reports_injected_inconsistencies() {
+        let mut chunks = vec![chunk("a", "src/a.rs", 1, 3), chunk("b", "src/b.rs", 9, 4)];
+        let mut vectors = vectors(&chunks);
+        vectors.insert("a".to_string(), vec![f32::NAN; DIMENSION]);
+        chunks.push(chunk("c", "src/c.rs", 1, 2));
+        vectors.insert("c".to_string(), vec![1.0; DIMENSION - 1]);
+
+        let issues = check_chunks(&chunks, &vectors, DIMENSION);
+        let found: Vec<(&str, Option<Repair>)> =
+            issues.iter().map(|i| (i.check, i.repair.clone())).collect();
+
+        assert_eq!(
+            found,
+            vec![
+                ("vector", Some(Repair::DropFile("src/a.rs".to_string()))),
+                ("vector", Some(Repair::DropFile("src/c.rs".to_string()))),
+                ("line-range", Some(Repair::DropFile("src/b.rs".to_string()))),
+            ]
+        );
+        assert!(issues.iter().all(|i| i.severity == Severity::Error));
+        assert!(issues[2].message.contains("9-4"));
+    }
+
+    #[test]
+    fn test_reports_file_and_symbol_inconsistencies() {
+        let mut stale = chunk("b", "src/a.rs", 5, 9);
+        stale.mtime = 50;
+        let mut unnamed = chunk("c", "src/c.rs", 1, 2);
+        unnamed.symbol_name = None;
+        let chunks = vec![
+            chunk("a", "src/a.rs", 1, 3),
+            stale,
+            unnamed.clone(),
+            unnamed,
+        ];
+
+        let issues = check_chunks(&chunks, &vectors(&chunks), DIMENSION);
+        let checks: Vec<(&str, Severity)> = issues.iter().map(|i| (i.check, i.severity)).collect();
+
+        assert_eq!(
+            checks,
+            vec![
+                ("file", Severity::Warning),
+                ("file", Severity::Error),
+                ("symbol", Severity::Warning),
+                ("symbol", Severity::Warning),
+            ]
+        );
+        assert_eq!(
+            repairs(&issues),
+            vec![
+                Repair::DropFile("src/a.rs".to_string()),
+                Repair::DropFile("src/c.rs".to_string()),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_bm25_out_of_sync() {
+        let chunks = vec![chunk("a", "src/a.rs", 1, 3), chunk("b", "src/a.rs", 5, 9)];
+        let ids = vec!["a".to_string(), "gone".to_string(), "gone".to_string()];
+
+        let issues = check_bm25(&chunks, Some(&ids));
+        assert_eq!(issues.len(), 3);
+        assert!(issues.iter().all(|i| i.repair == Some(Repair::RebuildBm25)));
+
+        assert_eq!(check_bm25(&chunks, None).len(), 1);
+        assert!(check_bm25(&[], None).is_empty());
+    }
+
+    fn go_chunk(id: &str, file_path: &str, name: &str, content: &str) -> IndexedChunk {
+        let mut chunk = chunk(id, file_path, 1, content.lines().count());
+        chunk.language = Some("go".to_string());
+        chunk.symbol_name = Some(name.to_string());
+        chunk.qualified_name = None;
+        chunk.content = content.to_string();
+        chunk
+    }
+
+    fn service() -> Vec<IndexedChunk> {
+        vec![
+            go_chunk("run", "main.go", "run", "func run() {\n\tacquire(4)\n}"),
+            go_chunk("acquire", "pool.go", "acquire", "func acquire(n int) {}"),
+        ]
+    }
+
+    #[test]
+    fn test_consistent_graph_has_no_issues() {
+        let stored = SymbolGraph::build(&service());
+        assert_eq!(stored.len(), 1);
+        assert!(check_graph(&stored, Some(&stored.clone())).is_empty());
+    }
+
+    #[test]
+    fn test_reports_orphaned_edges() {
+        let saved = SymbolGraph::build(&service());
+        // pool.go was dropped from the store but the graph still links to it
+        let stored = SymbolGraph::build(&service()[..1]);
+
+        let issues = check_graph(&stored, Some(&saved));
+        let edge = issues.iter().find(|i| i.check == "edge").unwrap();
+        assert_eq!(edge.repair, Some(Repair::DropOrphanedEdges));
+        assert!(edge.message.contains("run -> acquire in main.go"));
+    }
+
+    #[test]
+    fn test_reports_manifest_mismatch() {
+        let saved = SymbolGraph::build(&service());
+        let stored = SymbolGraph::build(&service()[..1]);
+
+        let issues = check_graph(&stored, Some(&saved));
+        let manifest: Vec<&Issue> = issues.iter().filter(|i| i.check == "manifest").collect();
+        assert_eq!(manifest.len(), 1);
+        assert!(manifest[0].message.contains("1 symbol(s) of pool.go"));
+        assert_eq!(manifest[0].repair, Some(Repair::RebuildGraph));
+
+        // A missing graph is reported once there are symbols to record
+        let missing = check_graph(&stored, None);
+        assert_eq!(missing.len(), 1);
+        assert_eq!(missing[0].repair, Some(Repair::RebuildGraph));
+        assert!(check_graph(&SymbolGraph::default(), None).is_empty());
+    }
+
+    #[test]
+    fn test_fix_drops_orphaned_edges() {
+        let mut saved = SymbolGraph::build(&service());
+        let stored = SymbolGraph::build(&service()[..1]);
+
+        assert_eq!(drop_orphaned_edges(&mut saved, &stored), 1);
+        assert!(saved.is_empty());
+        assert!(check_graph(&stored, Some(&saved))
+            .iter()
+            .all(|i| i.check != "edge"));
+        // Nothing left to drop
+        assert_eq!(drop_orphaned_edges(&mut saved, &stored), 0);
+    }
+
+    #[test]
+    fn test_dimension_mismatch_is_not_repairable() {
+        let issues = check_dimension(384, "nomic-embed-text-v1.5", Some(768));
+        assert_eq!(issues.len(), 1);
+        assert_eq!(issues[0].severity, Severity::Error);
+        assert!(issues[0].repair.is_none());
+        // Unknown models cannot be checked
+        assert!(check_dimension(384, "custom", None).is_empty());
+    }
+}
@@ -12,6 +12,9 @@ use std::path::{Path, PathBuf};
 use std::sync::Arc;
 use tracing::{debug, info, warn};
 
+use super::store::{content_hashes, mark_duplicates};
+use crate::search::provenance::RetrievalSource;
+
 const TABLE_NAME: &str = "chunks";
 /// Default vector dimension (OpenAI text-embedding-3-small)
 /// Used when no explicit dimension is provided.
@@ -39,10 +42,102 @@ pub struct IndexedChunk {
     pub symbol_name: Option<String>,
     /// Full signature of the symbol (e.g., function signature with parameters)
     pub signature: Option<String>,
+    /// Doc comment or leading comment block of the symbol
+    pub doc: Option<String>,
     /// Parent symbol context (e.g., class name for methods)
     pub parent: Option<String>,
     /// Visibility modifier (public, private, protected)
     pub visibility: Option<String>,
+    /// Fully qualified symbol name (e.g., `com.example.User.getName`)
+    pub qualified_name: Option<String>,
+    /// Static analysis tags (e.g., `concurrency`)
+    pub tags: Vec<String>,
+    /// Git ref the chunk was indexed from (`None` for the working tree)
+    pub branch: Option<String>,
+    /// Id of the chunk with identical content on the same branch whose
+    /// vector this chunk shares; set when the chunk is stored. Duplicates
+    /// store no vector of their own and are left out of search results.
+    pub duplicate_of: Option<String>,
+}
+
+/// A part of a chunk with its own short vectors, stored in a separate table
+/// and searched with `coderag search --field <field>`
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum VectorField {
+    /// Symbol name variants (see `indexer::name_variants`)
+    Name,
+    /// Comments inside a symbol's body (see `indexer::comments`)
+    Comments,
+    /// LLM-written summary of a chunk (see `indexer::summaries`)
+    Summary,
+    /// Comment and docstring lines of a chunk, for `search --weights`
+    /// (see `search::field_weights`)
+    Doc,
+    /// The code of a chunk without its comment lines, for `search --weights`
+    Body,
+    /// The declaration line of a chunk, for `search --weights`
+    Structure,
+}
+
+impl VectorField {
+    /// All fields
+    pub const ALL: [VectorField; 6] = [
+        VectorField::Name,
+        VectorField::Comments,
+        VectorField::Summary,
+        VectorField::Doc,
+        VectorField::Body,
+        VectorField::Structure,
+    ];
+
+    /// Parse from string representation.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_lowercase().as_str() {
+            "name" | "names" => Some(Self::Name),
+            "comments" | "comment" => Some(Self::Comments),
+            "summary" | "summaries" => Some(Self::Summary),
+            "doc" | "docs" => Some(Self::Doc),
+            "body" => Some(Self::Body),
+            "structure" | "signature" => Some(Self::Structure),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Name => "name",
+            Self::Comments => "comments",
+            Self::Summary => "summary",
+            Self::Doc => "doc",
+            Self::Body => "body",
+            Self::Structure => "structure",
+        }
+    }
+
+    /// Table holding the field's vectors
+    fn table_name(&self) -> &'static str {
+        match self {
+            Self::Name => "names",
+            Self::Comments => "comments",
+            Self::Summary => "summaries",
+            Self::Doc => "doc_fields",
+            Self::Body => "body_fields",
+            Self::Structure => "structure_fields",
+        }
+    }
+}
+
+/// Vector of one field text of a chunk, such as a name variant
+#[derive(Debug, Clone)]
+pub struct FieldVector {
+    /// Id of the chunk the text belongs to
+    pub chunk_id: String,
+    pub file_path: String,
+    pub branch: Option<String>,
+    /// The embedded text (e.g. `Shutdown` or `graceful shutdown`)
+    pub text: String,
+    pub vector: Vec<f32>,
 }
 
 /// Search result from vector similarity search
@@ -55,6 +150,93 @@ pub struct SearchResult {
     pub score: f32,
     /// First 50 lines of the file for context
     pub file_header: Option<String>,
+    /// Semantic kind of the chunk (function, struct, ...) when known
+    pub semantic_kind: Option<String>,
+    /// How the result was retrieved; filled in by the searcher
+    pub sources: Vec<RetrievalSource>,
+    /// Other locations of identical content, as `path:start-end`
+    pub duplicates: Vec<String>,
+}
+
+/// Metadata restrictions applied to a vector search
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct SearchFilter {
+    /// Only chunks of this semantic kind
+    pub kind: Option<String>,
+    /// Only chunks carrying this tag
+    pub tag: Option<String>,
+    /// Only chunks carrying every one of these header metadata tags (see
+    /// [`crate::indexer::header_meta::meta_filter`]); a tag ending in `=`
+    /// matches any value of its key
+    pub meta: Vec<String>,
+    /// Only chunks indexed from this git ref
+    pub branch: Option<String>,
+}
+
+impl SearchFilter {
+    /// SQL predicate for the filter, or `None` when it matches everything
+    pub(super) fn to_sql(&self) -> Option<String> {
+        let mut predicates = Vec::new();
+        if let Some(kind) = &self.kind {
+            predicates.push(format!("semantic_kind = '{}'", sql_escape(kind)));
+        }
+        if let Some(tag) = &self.tag {
+            predicates.push(tag_predicate(tag));
+        }
+        for tag in &self.meta {
+            predicates.push(if tag.ends_with('=') {
+                // Any value: the tag is a prefix of an item
+                let tag = sql_escape(tag);
+                format!("(tags LIKE '{tag}%' OR tags LIKE '%,{tag}%')")
+            } else {
+                tag_predicate(tag)
+            });
+        }
+        if let Some(branch) = &self.branch {
+            predicates.push(format!("branch = '{}'", sql_escape(branch)));
+        }
+        (!predicates.is_empty()).then(|| predicates.join(" AND "))
+    }
+}
+
+/// Predicate matching chunks carrying `tag`
+fn tag_predicate(tag: &str) -> String {
+    // Tags are stored comma-separated, so match the tag as a whole item
+    let tag = sql_escape(tag);
+    format!(
+        "(tags = '{tag}' OR tags LIKE '{tag},%' OR tags LIKE '%,{tag}' OR tags LIKE '%,{tag},%')"
+    )
+}
+
+pub(super) fn sql_escape(value: &str) -> String {
+    value.replace('\'', "''")
+}
+
+/// Hash of a chunk's content and language, used to share embeddings between
+/// identical chunks (e.g. the same function on two branches). It is stored,
+/// so it must not change between builds.
+pub fn content_hash(content: &str, language: Option<&str>) -> u64 {
+    // The language is prefixed so that no content can pass for another
+    // language's
+    let key = match language {
+        Some(language) => format!("1{language}\0{content}"),
+        None => format!("0{content}"),
+    };
+    crate::seed::stable_hash(&key)
+}
+
+/// [`content_hash`] as stored in the `content_hash` column
+pub(super) fn stored_hash(hash: u64) -> String {
+    format!("{:016x}", hash)
+}
+
+/// SQL list of quoted values, for `IN (...)`
+pub(super) fn sql_list<'a>(values: impl IntoIterator<Item = &'a str>) -> String {
+    values
+        .into_iter()
+        .map(|value| format!("'{}'", sql_escape(value)))
+        .collect::<Vec<_>>()
+        .join(", ")
 }
 
 /// LanceDB storage backend for vector embeddings
@@ -110,6 +292,19 @@ impl Storage {
         Self::new(path, DEFAULT_VECTOR_DIMENSION).await
     }
 
+    /// Open an existing index with the vector dimension it was written with
+    ///
+    /// Returns None when no chunks table exists yet. Use this for reads that
+    /// don't know the embedding model, so indexes built with a dimension
+    /// other than the default can still be opened.
+    pub async fn open_existing(path: &Path) -> Result<Option<Self>> {
+        let probe = Self::new_with_default_dimension(path).await?;
+        match probe.stored_vector_dimension().await? {
+            Some(dimension) => Ok(Some(Self::new(path, dimension).await?)),
+            None => Ok(None),
+        }
+    }
+
     /// Get the configured vector dimension
     pub fn vector_dimension(&self) -> usize {
         self.vector_dimension as usize
@@ -131,8 +326,9 @@ impl Storage {
                 .await
                 .with_context(|| format!("Failed to open table {}", TABLE_NAME))?;
 
-            // Validate dimension compatibility with existing table
+            // Validate dimension and column compatibility with existing table
             self.validate_existing_table_dimension(&table).await?;
+            self.validate_existing_table_columns(&table).await?;
 
             Ok(table)
         } else {
@@ -180,13 +376,14 @@ impl Storage {
             Field::new("start_line", DataType::Int32, false),
             Field::new("end_line", DataType::Int32, false),
             Field::new("language", DataType::Utf8, true),
+            // Null for duplicates, which share the vector of another chunk
             Field::new(
                 "vector",
                 DataType::FixedSizeList(
                     Arc::new(Field::new("item", DataType::Float32, true)),
                     self.vector_dimension,
                 ),
-                false,
+                true,
             ),
             Field::new("mtime", DataType::Int64, false),
             Field::new("file_header", DataType::Utf8, true),
@@ -196,6 +393,13 @@ impl Storage {
             Field::new("signature", DataType::Utf8, true),
             Field::new("parent", DataType::Utf8, true),
             Field::new("visibility", DataType::Utf8, true),
+            Field::new("qualified_name", DataType::Utf8, true),
+            Field::new("tags", DataType::Utf8, true),
+            Field::new("branch", DataType::Utf8, true),
+            Field::new("doc", DataType::Utf8, true),
+            // Deduplication of identical chunks
+            Field::new("content_hash", DataType::Utf8, false),
+            Field::new("duplicate_of", DataType::Utf8, true),
         ])
     }
 
@@ -203,33 +407,139 @@ impl Storage {
     async fn validate_existing_table_dimension(&self, table: &Table) -> Result<()> {
         let schema = table.schema().await?;
 
-        for field in schema.fields() {
-            if field.name() == "vector" {
-                if let DataType::FixedSizeList(_, existing_dim) = field.data_type() {
-                    if *existing_dim != self.vector_dimension {
-                        anyhow::bail!(
-                            "Vector dimension mismatch: storage configured for {} dimensions, \
-                             but existing table has {} dimensions. \
-                             Delete the existing index or use matching embedding model.",
-                            self.vector_dimension,
-                            existing_dim
-                        );
-                    }
-                }
+        if let Some(existing_dim) = Self::schema_vector_dimension(&schema) {
+            if existing_dim != self.vector_dimension as usize {
+                anyhow::bail!(
+                    "Vector dimension mismatch: storage configured for {} dimensions, \
+                     but existing table has {} dimensions. \
+                     Delete the existing index or use matching embedding model.",
+                    self.vector_dimension,
+                    existing_dim
+                );
+            }
+        }
+        Ok(())
+    }
+
+    /// Vector dimension of the stored chunks table, or None when no index
+    /// has been written yet
+    ///
+    /// Unlike other reads this does not validate the table against the
+    /// configured dimension, so it can be called on storage opened with
+    /// `new_with_default_dimension`.
+    pub async fn stored_vector_dimension(&self) -> Result<Option<usize>> {
+        let table_names = self.db.table_names().execute().await?;
+        if !table_names.contains(&TABLE_NAME.to_string()) {
+            return Ok(None);
+        }
+
+        let table = self
+            .db
+            .open_table(TABLE_NAME)
+            .execute()
+            .await
+            .with_context(|| format!("Failed to open table {}", TABLE_NAME))?;
+        Ok(Self::schema_vector_dimension(&table.schema().await?))
+    }
+
+    /// Dimension of the `vector` column of a table schema
+    fn schema_vector_dimension(schema: &Schema) -> Option<usize> {
+        match schema.field_with_name("vector").ok()?.data_type() {
+            DataType::FixedSizeList(_, dim) => Some(*dim as usize),
+            _ => None,
+        }
+    }
+
+    /// Validate that an existing table has every column of the current schema
+    ///
+    /// Tables created by older versions lack newly added metadata columns and
+    /// must be rebuilt before they can be written to.
+    async fn validate_existing_table_columns(&self, table: &Table) -> Result<()> {
+        let schema = table.schema().await?;
+
+        for field in self.table_schema().fields() {
+            if schema.field_with_name(field.name()).is_err() {
+                anyhow::bail!(
+                    "Index schema is outdated: missing column '{}'. \
+                     Run `coderag index --force` to rebuild the index.",
+                    field.name()
+                );
             }
         }
         Ok(())
     }
 
     /// Insert chunks into the database
-    pub async fn insert_chunks(&self, chunks: Vec<IndexedChunk>) -> Result<()> {
+    ///
+    /// A chunk whose content and language match a chunk already stored on
+    /// the same branch, or one earlier in `chunks`, is stored as its
+    /// duplicate: without a vector, and left out of search results.
+    pub async fn insert_chunks(&self, mut chunks: Vec<IndexedChunk>) -> Result<()> {
         if chunks.is_empty() {
             return Ok(());
         }
 
+        let table = self.get_or_create_table().await?;
+        self.mark_duplicates(&table, &mut chunks).await?;
+        self.write_chunks(&table, chunks).await
+    }
+
+    /// Set `duplicate_of` on each chunk with the content of a stored chunk
+    /// or of an earlier one in `chunks`, on the same branch
+    async fn mark_duplicates(&self, table: &Table, chunks: &mut [IndexedChunk]) -> Result<()> {
+        let hashes = content_hashes(chunks);
+        let batches: Vec<RecordBatch> = table
+            .query()
+            .only_if(format!(
+                "duplicate_of IS NULL AND content_hash IN ({})",
+                sql_list(hashes.iter().map(String::as_str))
+            ))
+            .select(lancedb::query::Select::Columns(vec![
+                "id".to_string(),
+                "content_hash".to_string(),
+                "branch".to_string(),
+            ]))
+            .limit(Self::get_row_count_or_max(table).await)
+            .execute()
+            .await
+            .with_context(|| "Failed to query stored duplicates")?
+            .try_collect()
+            .await
+            .with_context(|| "Failed to collect stored duplicates")?;
+
+        let mut holders = HashMap::new();
+        for batch in batches {
+            let ids = batch
+                .column_by_name("id")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing id column"))?;
+            let stored_hashes = batch
+                .column_by_name("content_hash")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing content_hash column"))?;
+            let branches = batch
+                .column_by_name("branch")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+            for i in 0..batch.num_rows() {
+                let branch = branches
+                    .filter(|b| !b.is_null(i))
+                    .map(|b| b.value(i).to_string());
+                holders.insert(
+                    (stored_hashes.value(i).to_string(), branch),
+                    ids.value(i).to_string(),
+                );
+            }
+        }
+
+        mark_duplicates(chunks, holders);
+        Ok(())
+    }
+
+    /// Write chunks as they are, with their `duplicate_of` references
+    async fn write_chunks(&self, table: &Table, chunks: Vec<IndexedChunk>) -> Result<()> {
         // Validate vector dimensions before storing
         let expected_dim = self.vector_dimension as usize;
-        for chunk in &chunks {
+        for chunk in chunks.iter().filter(|c| c.duplicate_of.is_none()) {
             if chunk.vector.len() != expected_dim {
                 anyhow::bail!(
                     "Vector dimension mismatch for chunk '{}': expected {} dimensions, got {}",
@@ -240,8 +550,6 @@ impl Storage {
             }
         }
 
-        let table = self.get_or_create_table().await?;
-
         let batch = self.chunks_to_record_batch(&chunks)?;
         let batches = RecordBatchIterator::new(vec![Ok(batch)], Arc::new(self.table_schema()));
 
@@ -294,12 +602,33 @@ impl Storage {
             .iter()
             .map(|c| c.visibility.as_deref())
             .collect();
+        let qualified_names: Vec<Option<&str>> = chunks
+            .iter()
+            .map(|c| c.qualified_name.as_deref())
+            .collect();
+        let tags: Vec<Option<String>> = chunks
+            .iter()
+            .map(|c| (!c.tags.is_empty()).then(|| c.tags.join(",")))
+            .collect();
+        let branches: Vec<Option<&str>> = chunks
+            .iter()
+            .map(|c| c.branch.as_deref())
+            .collect();
+        let docs: Vec<Option<&str>> = chunks.iter().map(|c| c.doc.as_deref()).collect();
+        let hashes: Vec<String> = chunks
+            .iter()
+            .map(|c| stored_hash(content_hash(&c.content, c.language.as_deref())))
+            .collect();
+        let duplicates: Vec<Option<&str>> =
+            chunks.iter().map(|c| c.duplicate_of.as_deref()).collect();
 
-        // Build vector array
+        // Build vector array; duplicates have none
         let vector_array = FixedSizeListArray::from_iter_primitive::<Float32Type, _, _>(
-            chunks
-                .iter()
-                .map(|c| Some(c.vector.iter().map(|&v| Some(v)))),
+            chunks.iter().map(|c| {
+                c.duplicate_of
+                    .is_none()
+                    .then(|| c.vector.iter().map(|&v| Some(v)))
+            }),
             self.vector_dimension,
         );
 
@@ -322,6 +651,12 @@ impl Storage {
                 Arc::new(StringArray::from(signatures)),
                 Arc::new(StringArray::from(parents)),
                 Arc::new(StringArray::from(visibilities)),
+                Arc::new(StringArray::from(qualified_names)),
+                Arc::new(StringArray::from(tags)),
+                Arc::new(StringArray::from(branches)),
+                Arc::new(StringArray::from(docs)),
+                Arc::new(StringArray::from(hashes)),
+                Arc::new(StringArray::from(duplicates)),
             ],
         )
         .with_context(|| "Failed to create RecordBatch")
@@ -329,12 +664,55 @@ impl Storage {
 
     /// Perform vector similarity search
     pub async fn search(&self, vector: Vec<f32>, limit: usize) -> Result<Vec<SearchResult>> {
+        self.search_where(vector, limit, None).await
+    }
+
+    /// Perform vector similarity search over chunks of one semantic kind
+    pub async fn search_by_kind(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        kind: &str,
+    ) -> Result<Vec<SearchResult>> {
+        let filter = SearchFilter {
+            kind: Some(kind.to_string()),
+            ..Default::default()
+        };
+        self.search_filtered(vector, limit, &filter).await
+    }
+
+    /// Perform vector similarity search restricted by metadata
+    pub async fn search_filtered(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.search_where(vector, limit, filter.to_sql()).await
+    }
+
+    /// Vector search, prefiltered by an optional SQL predicate
+    ///
+    /// Duplicates are left out; each result lists their locations instead.
+    async fn search_where(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: Option<String>,
+    ) -> Result<Vec<SearchResult>> {
         let table = self.get_or_create_table().await?;
 
-        let results = table
+        let filter = match filter {
+            Some(filter) => format!("duplicate_of IS NULL AND {}", filter),
+            None => "duplicate_of IS NULL".to_string(),
+        };
+        let query = table
             .vector_search(vector)
             .with_context(|| "Failed to create vector search query")?
             .limit(limit)
+            .only_if(filter);
+
+        let results = query
             .execute()
             .await
             .with_context(|| "Failed to execute vector search")?;
@@ -345,8 +723,14 @@ impl Storage {
             .with_context(|| "Failed to collect search results")?;
 
         let mut search_results = Vec::new();
+        let mut result_ids = Vec::new();
 
         for batch in batches {
+            let ids = batch
+                .column_by_name("id")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing id column"))?;
+
             let contents = batch
                 .column_by_name("content")
                 .and_then(|c| c.as_any().downcast_ref::<StringArray>())
@@ -371,6 +755,10 @@ impl Storage {
                 .column_by_name("file_header")
                 .and_then(|c| c.as_any().downcast_ref::<StringArray>());
 
+            let semantic_kinds = batch
+                .column_by_name("semantic_kind")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+
             // LanceDB returns _distance column for similarity score
             let distances = batch
                 .column_by_name("_distance")
@@ -390,6 +778,16 @@ impl Storage {
                         }
                     });
 
+                let semantic_kind = semantic_kinds
+                    .and_then(|k| {
+                        if k.is_null(i) {
+                            None
+                        } else {
+                            Some(k.value(i).to_string())
+                        }
+                    });
+
+                result_ids.push(ids.value(i).to_string());
                 search_results.push(SearchResult {
                     content: contents.value(i).to_string(),
                     file_path: file_paths.value(i).to_string(),
@@ -397,14 +795,92 @@ impl Storage {
                     end_line: end_lines.value(i) as usize,
                     score,
                     file_header,
+                    semantic_kind,
+                    sources: Vec::new(),
+                    duplicates: Vec::new(),
                 });
             }
         }
 
+        let mut duplicates = self.duplicate_locations(&table, &result_ids).await?;
+        for (result, id) in search_results.iter_mut().zip(&result_ids) {
+            result.duplicates = duplicates.remove(id).unwrap_or_default();
+        }
+
         Ok(search_results)
     }
 
+    /// Locations (`path:start-end`) of the duplicates of each chunk in
+    /// `ids`, keyed by chunk id
+    async fn duplicate_locations(
+        &self,
+        table: &Table,
+        ids: &[String],
+    ) -> Result<HashMap<String, Vec<String>>> {
+        let mut locations: HashMap<String, Vec<String>> = HashMap::new();
+        if ids.is_empty() {
+            return Ok(locations);
+        }
+
+        let batches: Vec<RecordBatch> = table
+            .query()
+            .only_if(format!(
+                "duplicate_of IN ({})",
+                sql_list(ids.iter().map(String::as_str))
+            ))
+            .select(lancedb::query::Select::Columns(vec![
+                "duplicate_of".to_string(),
+                "file_path".to_string(),
+                "start_line".to_string(),
+                "end_line".to_string(),
+            ]))
+            .limit(Self::get_row_count_or_max(table).await)
+            .execute()
+            .await
+            .with_context(|| "Failed to query duplicates")?
+            .try_collect()
+            .await
+            .with_context(|| "Failed to collect duplicates")?;
+
+        for batch in batches {
+            let holders = batch
+                .column_by_name("duplicate_of")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing duplicate_of column"))?;
+            let file_paths = batch
+                .column_by_name("file_path")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing file_path column"))?;
+            let start_lines = batch
+                .column_by_name("start_line")
+                .and_then(|c| c.as_any().downcast_ref::<Int32Array>())
+                .ok_or_else(|| anyhow::anyhow!("Missing start_line column"))?;
+            let end_lines = batch
+                .column_by_name("end_line")
+                .and_then(|c| c.as_any().downcast_ref::<Int32Array>())
+                .ok_or_else(|| anyhow::anyhow!("Missing end_line column"))?;
+            for i in 0..batch.num_rows() {
+                locations
+                    .entry(holders.value(i).to_string())
+                    .or_default()
+                    .push(format!(
+                        "{}:{}-{}",
+                        file_paths.value(i),
+                        start_lines.value(i),
+                        end_lines.value(i)
+                    ));
+            }
+        }
+        for paths in locations.values_mut() {
+            paths.sort();
+        }
+        Ok(locations)
+    }
+
     /// Get modification times for all indexed files
+    ///
+    /// Only working-tree files are included; chunks indexed from git refs
+    /// have no file on disk to compare against.
     pub async fn get_file_mtimes(&self) -> Result<HashMap<PathBuf, i64>> {
         let table = self.get_or_create_table().await?;
 
@@ -413,6 +889,7 @@ impl Storage {
 
         let results = table
             .query()
+            .only_if("branch IS NULL")
             .select(lancedb::query::Select::Columns(vec![
                 "file_path".to_string(),
                 "mtime".to_string(),
@@ -459,20 +936,288 @@ impl Storage {
     }
 
     /// Delete all chunks for a given file path
+    ///
+    /// Chunks of other files duplicating a deleted chunk keep its vector:
+    /// one of them takes it over and the others refer to that one.
     pub async fn delete_by_file(&self, path: &Path) -> Result<()> {
         let table = self.get_or_create_table().await?;
         let path_str = path.to_string_lossy();
+        let predicate = format!("file_path = '{}'", path_str);
 
+        self.promote_duplicates(&table, &predicate).await?;
         table
-            .delete(&format!("file_path = '{}'", path_str))
+            .delete(&predicate)
             .await
             .with_context(|| format!("Failed to delete chunks for file: {}", path_str))?;
+        for field in VectorField::ALL {
+            if let Some(table) = self.field_table(field).await? {
+                table
+                    .delete(&format!("file_path = '{}'", sql_escape(&path_str)))
+                    .await
+                    .with_context(|| {
+                        format!("Failed to delete {} for file: {}", field.as_str(), path_str)
+                    })?;
+            }
+        }
 
         debug!("Deleted chunks for file: {}", path_str);
 
         Ok(())
     }
 
+    /// Delete the chunks with the given ids and their field vectors
+    ///
+    /// As for [`Storage::delete_by_file`], duplicates of a deleted chunk
+    /// keep its vector.
+    pub async fn delete_by_ids(&self, ids: &[String]) -> Result<()> {
+        if ids.is_empty() {
+            return Ok(());
+        }
+        let table = self.get_or_create_table().await?;
+        let list = sql_list(ids.iter().map(String::as_str));
+        let predicate = format!("id IN ({})", list);
+
+        self.promote_duplicates(&table, &predicate).await?;
+        table
+            .delete(&predicate)
+            .await
+            .with_context(|| "Failed to delete chunks by id")?;
+        for field in VectorField::ALL {
+            if let Some(table) = self.field_table(field).await? {
+                table
+                    .delete(&format!("chunk_id IN ({})", list))
+                    .await
+                    .with_context(|| format!("Failed to delete {} by chunk id", field.as_str()))?;
+            }
+        }
+        Ok(())
+    }
+
+    /// Before the chunks matching `predicate` are deleted, make one
+    /// remaining duplicate of each the holder of its vector, and point the
+    /// other duplicates at it.
+    async fn promote_duplicates(&self, table: &Table, predicate: &str) -> Result<()> {
+        let deleted: Vec<String> = self
+            .query_chunks(Some(format!("duplicate_of IS NULL AND {}", predicate)))
+            .await?
+            .into_iter()
+            .map(|c| c.id)
+            .collect();
+        if deleted.is_empty() {
+            return Ok(());
+        }
+        let mut duplicates: Vec<IndexedChunk> = self
+            .query_chunks(Some(format!(
+                "duplicate_of IN ({}) AND NOT ({})",
+                sql_list(deleted.iter().map(String::as_str)),
+                predicate
+            )))
+            .await?;
+        if duplicates.is_empty() {
+            return Ok(());
+        }
+
+        let mut holders: HashMap<String, String> = HashMap::new();
+        for chunk in &duplicates {
+            if let Some(old) = &chunk.duplicate_of {
+                holders
+                    .entry(old.clone())
+                    .or_insert_with(|| chunk.id.clone());
+            }
+        }
+        let vectors = self
+            .vectors_where(
+                table,
+                Some(format!(
+                    "id IN ({})",
+                    sql_list(holders.keys().map(String::as_str))
+                )),
+            )
+            .await?;
+        for chunk in &mut duplicates {
+            let Some(old) = chunk.duplicate_of.take() else {
+                continue;
+            };
+            if holders[&old] == chunk.id {
+                chunk.vector = vectors.get(&old).cloned().unwrap_or_default();
+            } else {
+                chunk.duplicate_of = Some(holders[&old].clone());
+            }
+        }
+
+        table
+            .delete(&format!(
+                "id IN ({})",
+                sql_list(duplicates.iter().map(|c| c.id.as_str()))
+            ))
+            .await
+            .with_context(|| "Failed to delete duplicates being promoted")?;
+        self.write_chunks(table, duplicates).await
+    }
+
+    /// Delete all chunks indexed from a git ref
+    ///
+    /// Duplicates are always on the same ref as the chunk holding their
+    /// vector, so none outlives it.
+    pub async fn delete_by_branch(&self, branch: &str) -> Result<()> {
+        let table = self.get_or_create_table().await?;
+
+        table
+            .delete(&format!("branch = '{}'", sql_escape(branch)))
+            .await
+            .with_context(|| format!("Failed to delete chunks for branch: {}", branch))?;
+        for field in VectorField::ALL {
+            if let Some(table) = self.field_table(field).await? {
+                table
+                    .delete(&format!("branch = '{}'", sql_escape(branch)))
+                    .await
+                    .with_context(|| {
+                        format!("Failed to delete {} for branch: {}", field.as_str(), branch)
+                    })?;
+            }
+        }
+
+        debug!("Deleted chunks for branch: {}", branch);
+
+        Ok(())
+    }
+
+    /// Stored vectors keyed by [`content_hash`], for reusing embeddings of
+    /// identical chunks
+    pub async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>> {
+        let table = self.get_or_create_table().await?;
+        let total_rows = Self::get_row_count_or_max(&table).await;
+
+        let results = table
+            .query()
+            .only_if("duplicate_of IS NULL")
+            .select(lancedb::query::Select::Columns(vec![
+                "content".to_string(),
+                "language".to_string(),
+                "vector".to_string(),
+            ]))
+            .limit(total_rows)
+            .execute()
+            .await
+            .with_context(|| "Failed to query stored vectors")?;
+
+        let batches: Vec<RecordBatch> = results
+            .try_collect()
+            .await
+            .with_context(|| "Failed to collect stored vectors")?;
+
+        let mut vectors = HashMap::new();
+
+        for batch in batches {
+            let contents = batch
+                .column_by_name("content")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing content column"))?;
+
+            let languages = batch
+                .column_by_name("language")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+
+            let vector_col = batch
+                .column_by_name("vector")
+                .and_then(|c| c.as_any().downcast_ref::<FixedSizeListArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing vector column"))?;
+
+            for i in 0..batch.num_rows() {
+                let language = languages.filter(|l| !l.is_null(i)).map(|l| l.value(i));
+                let values = vector_col.value(i);
+                let Some(values) = values.as_any().downcast_ref::<arrow_array::Float32Array>()
+                else {
+                    continue;
+                };
+                vectors
+                    .entry(content_hash(contents.value(i), language))
+                    .or_insert_with(|| values.values().to_vec());
+            }
+        }
+
+        Ok(vectors)
+    }
+
+    /// Stored vectors keyed by chunk id, for integrity checks
+    ///
+    /// Duplicates get the vector of the chunk they duplicate.
+    pub async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>> {
+        let table = self.get_or_create_table().await?;
+        let mut vectors = self.vectors_where(&table, None).await?;
+
+        for chunk in self
+            .query_chunks(Some("duplicate_of IS NOT NULL".to_string()))
+            .await?
+        {
+            let holder = chunk.duplicate_of.as_ref().and_then(|id| vectors.get(id));
+            if let Some(vector) = holder.cloned() {
+                vectors.insert(chunk.id, vector);
+            }
+        }
+
+        Ok(vectors)
+    }
+
+    /// Vectors of the chunks matching `filter` (all when `None`) that store
+    /// one, keyed by chunk id
+    async fn vectors_where(
+        &self,
+        table: &Table,
+        filter: Option<String>,
+    ) -> Result<HashMap<String, Vec<f32>>> {
+        let total_rows = Self::get_row_count_or_max(table).await;
+
+        let mut query = table
+            .query()
+            .select(lancedb::query::Select::Columns(vec![
+                "id".to_string(),
+                "vector".to_string(),
+            ]))
+            .limit(total_rows);
+        if let Some(filter) = filter {
+            query = query.only_if(filter);
+        }
+
+        let results = query
+            .execute()
+            .await
+            .with_context(|| "Failed to query stored vectors")?;
+
+        let batches: Vec<RecordBatch> = results
+            .try_collect()
+            .await
+            .with_context(|| "Failed to collect stored vectors")?;
+
+        let mut vectors = HashMap::new();
+
+        for batch in batches {
+            let ids = batch
+                .column_by_name("id")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing id column"))?;
+
+            let vector_col = batch
+                .column_by_name("vector")
+                .and_then(|c| c.as_any().downcast_ref::<FixedSizeListArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing vector column"))?;
+
+            for i in 0..batch.num_rows() {
+                if vector_col.is_null(i) {
+                    continue;
+                }
+                let values = vector_col.value(i);
+                let Some(values) = values.as_any().downcast_ref::<arrow_array::Float32Array>()
+                else {
+                    continue;
+                };
+                vectors.insert(ids.value(i).to_string(), values.values().to_vec());
+            }
+        }
+
+        Ok(vectors)
+    }
+
     /// List all unique file paths in the index, optionally filtered by pattern
     pub async fn list_files(&self, pattern: Option<&str>) -> Result<Vec<String>> {
         let table = self.get_or_create_table().await?;
@@ -545,6 +1290,14 @@ impl Storage {
                 .await
                 .with_context(|| "Failed to drop chunks table")?;
         }
+        for field in VectorField::ALL {
+            if table_names.contains(&field.table_name().to_string()) {
+                self.db
+                    .drop_table(field.table_name())
+                    .await
+                    .with_context(|| format!("Failed to drop {} table", field.table_name()))?;
+            }
+        }
 
         info!("Cleared all data from database");
         Ok(())
@@ -560,12 +1313,24 @@ impl Storage {
     /// This method is used for building secondary indices like BM25.
     /// Returns all indexed chunks with their metadata (excluding vectors for efficiency).
     pub async fn get_all_chunks(&self) -> Result<Vec<IndexedChunk>> {
+        self.query_chunks(None).await
+    }
+
+    /// Get the chunks of one file, without vectors
+    pub async fn get_file_chunks(&self, path: &Path) -> Result<Vec<IndexedChunk>> {
+        let path_str = path.to_string_lossy();
+        self.query_chunks(Some(format!("file_path = '{}'", sql_escape(&path_str))))
+            .await
+    }
+
+    /// Chunks matching `filter` (all chunks when `None`), without vectors
+    async fn query_chunks(&self, filter: Option<String>) -> Result<Vec<IndexedChunk>> {
         let table = self.get_or_create_table().await?;
 
         // Get total row count to ensure we query all rows
         let total_rows = Self::get_row_count_or_max(&table).await;
 
-        let results = table
+        let mut query = table
             .query()
             .select(lancedb::query::Select::Columns(vec![
                 "id".to_string(),
@@ -581,11 +1346,21 @@ impl Storage {
                 "signature".to_string(),
                 "parent".to_string(),
                 "visibility".to_string(),
+                "qualified_name".to_string(),
+                "tags".to_string(),
+                "branch".to_string(),
+                "doc".to_string(),
+                "duplicate_of".to_string(),
             ]))
-            .limit(total_rows) // Explicitly request all rows
+            .limit(total_rows); // Explicitly request all rows
+        if let Some(filter) = filter {
+            query = query.only_if(filter);
+        }
+
+        let results = query
             .execute()
             .await
-            .with_context(|| "Failed to query all chunks")?;
+            .with_context(|| "Failed to query chunks")?;
 
         let batches: Vec<RecordBatch> = results
             .try_collect()
@@ -653,6 +1428,26 @@ impl Storage {
                 .column_by_name("visibility")
                 .and_then(|c| c.as_any().downcast_ref::<StringArray>());
 
+            let qualified_names = batch
+                .column_by_name("qualified_name")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+
+            let tag_lists = batch
+                .column_by_name("tags")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+
+            let branches = batch
+                .column_by_name("branch")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+
+            let docs = batch
+                .column_by_name("doc")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+
+            let duplicates = batch
+                .column_by_name("duplicate_of")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+
             for i in 0..batch.num_rows() {
                 let language = languages
                     .and_then(|l| {
@@ -717,6 +1512,32 @@ impl Storage {
                         }
                     });
 
+                let qualified_name = qualified_names
+                    .and_then(|q| {
+                        if q.is_null(i) {
+                            None
+                        } else {
+                            Some(q.value(i).to_string())
+                        }
+                    });
+
+                let tags = tag_lists
+                    .filter(|t| !t.is_null(i))
+                    .map(|t| t.value(i).split(',').map(str::to_string).collect())
+                    .unwrap_or_default();
+
+                let branch = branches
+                    .filter(|b| !b.is_null(i))
+                    .map(|b| b.value(i).to_string());
+
+                let doc = docs
+                    .filter(|d| !d.is_null(i))
+                    .map(|d| d.value(i).to_string());
+
+                let duplicate_of = duplicates
+                    .filter(|d| !d.is_null(i))
+                    .map(|d| d.value(i).to_string());
+
                 chunks.push(IndexedChunk {
                     id: ids.value(i).to_string(),
                     content: contents.value(i).to_string(),
@@ -730,8 +1551,13 @@ impl Storage {
                     semantic_kind,
                     symbol_name,
                     signature,
+                    doc,
                     parent,
                     visibility,
+                    qualified_name,
+                    tags,
+                    branch,
+                    duplicate_of,
                 });
             }
         }
@@ -739,6 +1565,268 @@ impl Storage {
         debug!("Retrieved {} chunks from database", chunks.len());
         Ok(chunks)
     }
+
+    /// Define the Arrow schema for the tables of field vectors
+    fn field_schema(&self) -> Schema {
+        Schema::new(vec![
+            Field::new("chunk_id", DataType::Utf8, false),
+            Field::new("file_path", DataType::Utf8, false),
+            Field::new("branch", DataType::Utf8, true),
+            Field::new("text", DataType::Utf8, false),
+            Field::new(
+                "vector",
+                DataType::FixedSizeList(
+                    Arc::new(Field::new("item", DataType::Float32, true)),
+                    self.vector_dimension,
+                ),
+                false,
+            ),
+        ])
+    }
+
+    /// Open the table of a field, or None when no vectors of it were stored
+    async fn field_table(&self, field: VectorField) -> Result<Option<Table>> {
+        let table_names = self.db.table_names().execute().await?;
+        if !table_names.contains(&field.table_name().to_string()) {
+            return Ok(None);
+        }
+
+        let table = self
+            .db
+            .open_table(field.table_name())
+            .execute()
+            .await
+            .with_context(|| format!("Failed to open table {}", field.table_name()))?;
+        Ok(Some(table))
+    }
+
+    /// Insert vectors of a field
+    pub async fn insert_field_vectors(
+        &self,
+        field: VectorField,
+        vectors: Vec<FieldVector>,
+    ) -> Result<()> {
+        if vectors.is_empty() {
+            return Ok(());
+        }
+
+        let expected_dim = self.vector_dimension as usize;
+        for v in &vectors {
+            if v.vector.len() != expected_dim {
+                anyhow::bail!(
+                    "Vector dimension mismatch for {} '{}': expected {} dimensions, got {}",
+                    field.as_str(),
+                    v.text,
+                    expected_dim,
+                    v.vector.len()
+                );
+            }
+        }
+
+        let chunk_ids: Vec<&str> = vectors.iter().map(|v| v.chunk_id.as_str()).collect();
+        let file_paths: Vec<&str> = vectors.iter().map(|v| v.file_path.as_str()).collect();
+        let branches: Vec<Option<&str>> = vectors.iter().map(|v| v.branch.as_deref()).collect();
+        let texts: Vec<&str> = vectors.iter().map(|v| v.text.as_str()).collect();
+        let vector_array = FixedSizeListArray::from_iter_primitive::<Float32Type, _, _>(
+            vectors
+                .iter()
+                .map(|v| Some(v.vector.iter().map(|&x| Some(x)))),
+            self.vector_dimension,
+        );
+
+        let schema = Arc::new(self.field_schema());
+        let batch = RecordBatch::try_new(
+            schema.clone(),
+            vec![
+                Arc::new(StringArray::from(chunk_ids)),
+                Arc::new(StringArray::from(file_paths)),
+                Arc::new(StringArray::from(branches)),
+                Arc::new(StringArray::from(texts)),
+                Arc::new(vector_array),
+            ],
+        )
+        .with_context(|| "Failed to create RecordBatch")?;
+        let batches = RecordBatchIterator::new(vec![Ok(batch)], schema);
+
+        match self.field_table(field).await? {
+            Some(table) => {
+                self.validate_existing_table_dimension(&table).await?;
+                table
+                    .add(Box::new(batches))
+                    .execute()
+                    .await
+                    .with_context(|| format!("Failed to insert {} vectors", field.as_str()))?;
+            }
+            None => {
+                self.db
+                    .create_table(field.table_name(), Box::new(batches))
+                    .execute()
+                    .await
+                    .with_context(|| format!("Failed to create {} table", field.table_name()))?;
+            }
+        }
+
+        debug!(
+            "Inserted {} {} vectors into database",
+            vectors.len(),
+            field.as_str()
+        );
+
+        Ok(())
+    }
+
+    /// Whether vectors of a field have been stored
+    pub async fn has_field_vectors(&self, field: VectorField) -> Result<bool> {
+        match self.field_table(field).await? {
+            Some(table) => Ok(table.count_rows(None).await? > 0),
+            None => Ok(false),
+        }
+    }
+
+    /// Search the vectors of one field, returning the chunks whose field
+    /// is closest to the query vector, best first
+    ///
+    /// Each chunk appears once, scored by its best matching vector.
+    pub async fn search_field(
+        &self,
+        field: VectorField,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        let Some(table) = self.field_table(field).await? else {
+            return Ok(Vec::new());
+        };
+
+        // A chunk may have several vectors, and filters apply to chunks
+        let mut query = table
+            .vector_search(vector)
+            .with_context(|| "Failed to create field search query")?
+            .limit(limit * 4);
+        if let Some(branch) = &filter.branch {
+            query = query.only_if(format!("branch = '{}'", sql_escape(branch)));
+        }
+        let batches: Vec<RecordBatch> = query
+            .execute()
+            .await
+            .with_context(|| format!("Failed to execute {} search", field.as_str()))?
+            .try_collect()
+            .await
+            .with_context(|| format!("Failed to collect {} search results", field.as_str()))?;
+
+        let mut scores: Vec<(String, f32)> = Vec::new();
+        for batch in batches {
+            let chunk_ids = batch
+                .column_by_name("chunk_id")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing chunk_id column"))?;
+            let distances = batch
+                .column_by_name("_distance")
+                .and_then(|c| c.as_any().downcast_ref::<arrow_array::Float32Array>());
+
+            for i in 0..batch.num_rows() {
+                let score = distances.map(|d| 1.0 / (1.0 + d.value(i))).unwrap_or(1.0);
+                let chunk_id = chunk_ids.value(i);
+                match scores.iter_mut().find(|(id, _)| id == chunk_id) {
+                    Some((_, best)) => *best = best.max(score),
+                    None => scores.push((chunk_id.to_string(), score)),
+                }
+            }
+        }
+        if scores.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        // Fetch the matched chunks, applying the remaining filters
+        let ids = scores
+            .iter()
+            .map(|(id, _)| format!("'{}'", sql_escape(id)))
+            .collect::<Vec<_>>()
+            .join(", ");
+        let mut predicate = format!("id IN ({}) AND duplicate_of IS NULL", ids);
+        if let Some(sql) = filter.to_sql() {
+            predicate = format!("{} AND {}", predicate, sql);
+        }
+        let chunks_table = self.get_or_create_table().await?;
+        let batches: Vec<RecordBatch> = chunks_table
+            .query()
+            .only_if(predicate)
+            .select(lancedb::query::Select::Columns(vec![
+                "id".to_string(),
+                "content".to_string(),
+                "file_path".to_string(),
+                "start_line".to_string(),
+                "end_line".to_string(),
+                "file_header".to_string(),
+                "semantic_kind".to_string(),
+            ]))
+            .limit(scores.len())
+            .execute()
+            .await
+            .with_context(|| format!("Failed to query chunks matched by {}", field.as_str()))?
+            .try_collect()
+            .await
+            .with_context(|| format!("Failed to collect chunks matched by {}", field.as_str()))?;
+
+        let mut results = Vec::new();
+        for batch in batches {
+            let ids = batch
+                .column_by_name("id")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing id column"))?;
+            let contents = batch
+                .column_by_name("content")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing content column"))?;
+            let file_paths = batch
+                .column_by_name("file_path")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>())
+                .ok_or_else(|| anyhow::anyhow!("Missing file_path column"))?;
+            let start_lines = batch
+                .column_by_name("start_line")
+                .and_then(|c| c.as_any().downcast_ref::<Int32Array>())
+                .ok_or_else(|| anyhow::anyhow!("Missing start_line column"))?;
+            let end_lines = batch
+                .column_by_name("end_line")
+                .and_then(|c| c.as_any().downcast_ref::<Int32Array>())
+                .ok_or_else(|| anyhow::anyhow!("Missing end_line column"))?;
+            let file_headers = batch
+                .column_by_name("file_header")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+            let semantic_kinds = batch
+                .column_by_name("semantic_kind")
+                .and_then(|c| c.as_any().downcast_ref::<StringArray>());
+
+            for i in 0..batch.num_rows() {
+                let Some(&(_, score)) = scores.iter().find(|(id, _)| id == ids.value(i)) else {
+                    continue;
+                };
+                results.push(SearchResult {
+                    content: contents.value(i).to_string(),
+                    file_path: file_paths.value(i).to_string(),
+                    start_line: start_lines.value(i) as usize,
+                    end_line: end_lines.value(i) as usize,
+                    score,
+                    file_header: file_headers
+                        .filter(|h| !h.is_null(i))
+                        .map(|h| h.value(i).to_string()),
+                    semantic_kind: semantic_kinds
+                        .filter(|k| !k.is_null(i))
+                        .map(|k| k.value(i).to_string()),
+                    sources: Vec::new(),
+                    duplicates: Vec::new(),
+                });
+            }
+        }
+
+        results.sort_by(|a, b| {
+            b.score
+                .partial_cmp(&a.score)
+                .unwrap_or(std::cmp::Ordering::Equal)
+        });
+        results.truncate(limit);
+        Ok(results)
+    }
 }
 
 // Required for arrow streams
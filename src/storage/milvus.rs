@@ -0,0 +1,1117 @@
+//! Milvus vector store
+//!
+//! Talks to a Milvus server, or a Zilliz Cloud cluster, through its REST
+//! API. Indexes share two collections per vector dimension, one for chunks
+//! and one for field vectors, and each index lives in its own partition, so
+//! one cluster can host the indexes of many repositories. Collections and
+//! partitions are created on first use, with L2 distance on the vectors.
+//!
+//! Filtered fields are scalar columns with inverted indexes; the remaining
+//! chunk metadata goes into the dynamic field. Behaviour matches the
+//! LanceDB [`Storage`](super::Storage): identical chunks on one branch are
+//! searched once, and scores are `1 / (1 + d)` of the squared L2 distance
+//! `d`.
+
+use anyhow::{bail, Context, Result};
+use async_trait::async_trait;
+use serde_json::{json, Map, Value};
+use std::collections::{HashMap, HashSet};
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+use tracing::{debug, info};
+use uuid::Uuid;
+
+use super::lancedb::{
+    content_hash, stored_hash, FieldVector, IndexedChunk, SearchFilter, SearchResult, VectorField,
+};
+use super::sqlite::SqliteStore;
+use super::store::{
+    chunk_result, content_hashes, field_search, group_duplicates, latest_mtimes, mark_duplicates,
+    promote_duplicates, StoreStats, VectorStore, FIELD_HITS_PER_RESULT, MILVUS_BACKEND,
+};
+
+/// Timeout of one request; queries and upserts of large pages take longest
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(60);
+
+/// Namespace of the UUIDv5 ids of field vectors
+const FIELD_ID_NAMESPACE: Uuid = Uuid::from_u128(0x9c4d_2e71_5a0b_4f38_b1e6_7d2c_48a9_f305);
+
+/// Rows read per query and written per upsert
+const PAGE: usize = 1000;
+
+/// Longest string a VarChar column holds, in bytes
+const MAX_VARCHAR: usize = 65535;
+
+/// Scalar columns of the chunks collection, read back with every chunk
+const CHUNK_FIELDS: &[&str] = &[
+    "id",
+    "content",
+    "file_path",
+    "start_line",
+    "end_line",
+    "mtime",
+    "semantic_kind",
+    "tags",
+    "branch",
+    "content_hash",
+    "duplicate_of",
+];
+
+/// Chunk metadata kept in the dynamic field, only when set
+const DYNAMIC_FIELDS: &[&str] = &[
+    "language",
+    "file_header",
+    "symbol_name",
+    "signature",
+    "doc",
+    "parent",
+    "visibility",
+    "qualified_name",
+];
+
+/// Chunk columns with an inverted index, for filters
+const CHUNK_INDEXES: &[&str] = &[
+    "file_path",
+    "branch",
+    "semantic_kind",
+    "tags",
+    "tag_keys",
+    "content_hash",
+    "duplicate_of",
+];
+
+/// Columns of the field vectors collection
+const FIELD_VECTOR_FIELDS: &[&str] = &["field", "chunk_id", "file_path", "branch", "text"];
+
+/// Field vector columns with an inverted index
+const FIELD_VECTOR_INDEXES: &[&str] = &["field", "chunk_id", "file_path", "branch"];
+
+/// `value` as a Milvus string literal
+fn literal(value: &str) -> String {
+    format!("\"{}\"", value.replace('\\', "\\\\").replace('"', "\\\""))
+}
+
+/// `values` as a Milvus list literal
+fn list(values: &[String]) -> String {
+    let values: Vec<String> = values.iter().map(|v| literal(v)).collect();
+    format!("[{}]", values.join(", "))
+}
+
+/// Both expressions, where an empty one matches everything
+fn and(a: &str, b: &str) -> String {
+    match (a.is_empty(), b.is_empty()) {
+        (true, _) => b.to_string(),
+        (_, true) => a.to_string(),
+        _ => format!("({}) and ({})", a, b),
+    }
+}
+
+/// Boolean expression of a search filter; header metadata tags ending in
+/// `=` match any value of their key through the `tag_keys` column
+fn search_expression(filter: &SearchFilter) -> String {
+    let mut terms = vec!["duplicate_of == \"\"".to_string()];
+    if let Some(kind) = &filter.kind {
+        terms.push(format!("semantic_kind == {}", literal(kind)));
+    }
+    if let Some(tag) = &filter.tag {
+        terms.push(format!("array_contains(tags, {})", literal(tag)));
+    }
+    for tag in &filter.meta {
+        let column = if tag.ends_with('=') {
+            "tag_keys"
+        } else {
+            "tags"
+        };
+        terms.push(format!("array_contains({}, {})", column, literal(tag)));
+    }
+    if let Some(branch) = &filter.branch {
+        terms.push(format!("branch == {}", literal(branch)));
+    }
+    terms.join(" and ")
+}
+
+fn varchar(name: &str, max_length: usize) -> Value {
+    json!({
+        "fieldName": name,
+        "dataType": "VarChar",
+        "elementTypeParams": {"max_length": max_length},
+    })
+}
+
+fn int64(name: &str) -> Value {
+    json!({"fieldName": name, "dataType": "Int64"})
+}
+
+fn string_array(name: &str) -> Value {
+    json!({
+        "fieldName": name,
+        "dataType": "Array",
+        "elementDataType": "VarChar",
+        "elementTypeParams": {"max_capacity": 1024, "max_length": 512},
+    })
+}
+
+fn float_vector(name: &str, dimension: usize) -> Value {
+    json!({
+        "fieldName": name,
+        "dataType": "FloatVector",
+        "elementTypeParams": {"dim": dimension},
+    })
+}
+
+fn primary_key() -> Value {
+    let mut field = varchar("id", 512);
+    field["isPrimary"] = json!(true);
+    field
+}
+
+/// Schema of the chunks collection
+fn chunk_schema(dimension: usize) -> Vec<Value> {
+    vec![
+        primary_key(),
+        varchar("content", MAX_VARCHAR),
+        varchar("file_path", 4096),
+        int64("start_line"),
+        int64("end_line"),
+        int64("mtime"),
+        varchar("semantic_kind", 256),
+        string_array("tags"),
+        string_array("tag_keys"),
+        varchar("branch", 512),
+        varchar("content_hash", 16),
+        varchar("duplicate_of", 512),
+        float_vector("embedding", dimension),
+    ]
+}
+
+/// Schema of the field vectors collection
+fn field_vector_schema(dimension: usize) -> Vec<Value> {
+    vec![
+        primary_key(),
+        varchar("field", 32),
+        varchar("chunk_id", 512),
+        varchar("file_path", 4096),
+        varchar("branch", 512),
+        varchar("text", MAX_VARCHAR),
+        float_vector("embedding", dimension),
+    ]
+}
+
+fn chunk_row(chunk: &IndexedChunk) -> Map<String, Value> {
+    // `key=` of each `key=value` tag, for filters on any value of a key
+    let tag_keys: Vec<&str> = chunk
+        .tags
+        .iter()
+        .filter_map(|tag| tag.find('=').map(|i| &tag[..=i]))
+        .collect();
+    // Columns cannot be null; an empty string stands for None
+    let row = json!({
+        "id": chunk.id,
+        "content": chunk.content,
+        "file_path": chunk.file_path,
+        "start_line": chunk.start_line,
+        "end_line": chunk.end_line,
+        "mtime": chunk.mtime,
+        "semantic_kind": chunk.semantic_kind.as_deref().unwrap_or(""),
+        "tags": chunk.tags,
+        "tag_keys": tag_keys,
+        "branch": chunk.branch.as_deref().unwrap_or(""),
+        "content_hash": stored_hash(content_hash(&chunk.content, chunk.language.as_deref())),
+        "duplicate_of": chunk.duplicate_of.as_deref().unwrap_or(""),
+        "embedding": chunk.vector,
+    });
+    let Value::Object(mut row) = row else {
+        unreachable!()
+    };
+    let dynamic = [
+        &chunk.language,
+        &chunk.file_header,
+        &chunk.symbol_name,
+        &chunk.signature,
+        &chunk.doc,
+        &chunk.parent,
+        &chunk.visibility,
+        &chunk.qualified_name,
+    ];
+    for (key, value) in DYNAMIC_FIELDS.iter().zip(dynamic) {
+        if let Some(value) = value {
+            row.insert(key.to_string(), json!(value));
+        }
+    }
+    row
+}
+
+/// A string column, with the empty string read as None
+fn row_str(row: &Map<String, Value>, key: &str) -> Option<String> {
+    row.get(key)
+        .and_then(Value::as_str)
+        .filter(|s| !s.is_empty())
+        .map(str::to_string)
+}
+
+fn row_id(row: &Map<String, Value>) -> &str {
+    row.get("id").and_then(Value::as_str).unwrap_or_default()
+}
+
+fn row_usize(row: &Map<String, Value>, key: &str) -> usize {
+    row.get(key).and_then(Value::as_u64).unwrap_or(0) as usize
+}
+
+fn row_vector(row: &Map<String, Value>) -> Vec<f32> {
+    row.get("embedding")
+        .and_then(Value::as_array)
+        .map(|v| {
+            v.iter()
+                .filter_map(Value::as_f64)
+                .map(|x| x as f32)
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+fn chunk_from_row(row: &Map<String, Value>) -> IndexedChunk {
+    IndexedChunk {
+        id: row_str(row, "id").unwrap_or_default(),
+        content: row_str(row, "content").unwrap_or_default(),
+        file_path: row_str(row, "file_path").unwrap_or_default(),
+        start_line: row_usize(row, "start_line"),
+        end_line: row_usize(row, "end_line"),
+        language: row_str(row, "language"),
+        mtime: row.get("mtime").and_then(Value::as_i64).unwrap_or(0),
+        file_header: row_str(row, "file_header"),
+        semantic_kind: row_str(row, "semantic_kind"),
+        symbol_name: row_str(row, "symbol_name"),
+        signature: row_str(row, "signature"),
+        doc: row_str(row, "doc"),
+        parent: row_str(row, "parent"),
+        visibility: row_str(row, "visibility"),
+        qualified_name: row_str(row, "qualified_name"),
+        tags: row
+            .get("tags")
+            .and_then(Value::as_array)
+            .map(|tags| {
+                tags.iter()
+                    .filter_map(Value::as_str)
+                    .map(str::to_string)
+                    .collect()
+            })
+            .unwrap_or_default(),
+        branch: row_str(row, "branch"),
+        duplicate_of: row_str(row, "duplicate_of"),
+        vector: row_vector(row),
+    }
+}
+
+/// Milvus storage backend
+pub struct MilvusStore {
+    client: reqwest::Client,
+    url: String,
+    /// `user:password` or a Zilliz Cloud API key
+    token: Option<String>,
+    collection: String,
+    fields_collection: String,
+    /// Partition of this index in both collections
+    partition: String,
+    vector_dimension: usize,
+}
+
+impl MilvusStore {
+    /// Connect to the Milvus server at `url` and open the index in
+    /// `partition`, creating the collections and the partition when missing
+    ///
+    /// # Errors
+    ///
+    /// Returns an error if `vector_dimension` is 0, the URL is not http(s)
+    /// or the server cannot be reached.
+    pub async fn connect(
+        url: &str,
+        token: Option<String>,
+        partition: &str,
+        vector_dimension: usize,
+    ) -> Result<Self> {
+        if vector_dimension == 0 {
+            bail!("Vector dimension must be greater than 0");
+        }
+        let store = Self::new(url, token, partition, vector_dimension)?;
+        store
+            .ensure_collection(
+                &store.collection,
+                chunk_schema(vector_dimension),
+                CHUNK_INDEXES,
+            )
+            .await?;
+        store
+            .ensure_collection(
+                &store.fields_collection,
+                field_vector_schema(vector_dimension),
+                FIELD_VECTOR_INDEXES,
+            )
+            .await?;
+        debug!(
+            "Opened Milvus partition {} of {}",
+            store.partition, store.collection
+        );
+        Ok(store)
+    }
+
+    /// Connect to the Milvus server at `url` and open the index in
+    /// `partition` with the vector dimension it was written with
+    ///
+    /// Returns None when no chunks collection has the partition yet.
+    pub async fn connect_existing(
+        url: &str,
+        token: Option<String>,
+        partition: &str,
+    ) -> Result<Option<Self>> {
+        let probe = Self::new(url, token.clone(), partition, 0)?;
+        let names = probe.call("collections/list", json!({})).await?;
+        let mut dimensions: Vec<usize> = names
+            .as_array()
+            .into_iter()
+            .flatten()
+            .filter_map(|name| name.as_str()?.strip_prefix("coderag_chunks_")?.parse().ok())
+            .collect();
+        dimensions.sort();
+        for dimension in dimensions {
+            let exists = probe
+                .call(
+                    "partitions/has",
+                    json!({
+                        "collectionName": format!("coderag_chunks_{}", dimension),
+                        "partitionName": partition,
+                    }),
+                )
+                .await?;
+            if exists["has"].as_bool().unwrap_or(false) {
+                return Ok(Some(Self::connect(url, token, partition, dimension).await?));
+            }
+        }
+        Ok(None)
+    }
+
+    /// Client of the index in `partition`, without touching the server
+    fn new(
+        url: &str,
+        token: Option<String>,
+        partition: &str,
+        vector_dimension: usize,
+    ) -> Result<Self> {
+        if !url.starts_with("http://") && !url.starts_with("https://") {
+            bail!("Milvus URL must start with http:// or https://: {}", url);
+        }
+        let client = reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .context("Failed to create HTTP client")?;
+
+        // The dimension is part of the names, so indexes built with
+        // different embedding models never share a collection
+        Ok(Self {
+            client,
+            url: url.trim_end_matches('/').to_string(),
+            token,
+            collection: format!("coderag_chunks_{}", vector_dimension),
+            fields_collection: format!("coderag_fields_{}", vector_dimension),
+            partition: partition.to_string(),
+            vector_dimension,
+        })
+    }
+
+    /// POST `body` to the REST endpoint and return the response's `data`
+    async fn call(&self, endpoint: &str, body: Value) -> Result<Value> {
+        let url = format!("{}/v2/vectordb/{}", self.url, endpoint);
+        let mut request = self.client.post(&url).json(&body);
+        if let Some(token) = &self.token {
+            request = request.bearer_auth(token);
+        }
+        let response = request
+            .send()
+            .await
+            .with_context(|| format!("Milvus request to {} failed", url))?;
+        let status = response.status();
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            bail!("Milvus returned {}: {}", status, body.trim());
+        }
+        let mut body: Value = response
+            .json()
+            .await
+            .context("Failed to parse Milvus response")?;
+        // Errors come back as HTTP 200 with a non-zero code
+        if let Some(code) = body["code"].as_i64().filter(|&code| code != 0) {
+            bail!(
+                "Milvus {} failed ({}): {}",
+                endpoint,
+                code,
+                body["message"].as_str().unwrap_or_default()
+            );
+        }
+        Ok(body["data"].take())
+    }
+
+    /// Create `collection` and this index's partition of it when missing,
+    /// and load the collection for searching
+    async fn ensure_collection(
+        &self,
+        collection: &str,
+        fields: Vec<Value>,
+        indexes: &[&str],
+    ) -> Result<()> {
+        let exists = self
+            .call("collections/has", json!({"collectionName": collection}))
+            .await?;
+        if !exists["has"].as_bool().unwrap_or(false) {
+            info!("Creating Milvus collection {}", collection);
+            let mut index_params = vec![json!({
+                "fieldName": "embedding",
+                "indexName": "embedding",
+                "metricType": "L2",
+                "indexType": "AUTOINDEX",
+            })];
+            index_params.extend(indexes.iter().map(
+                |field| json!({"fieldName": field, "indexName": field, "indexType": "INVERTED"}),
+            ));
+            self.call(
+                "collections/create",
+                json!({
+                    "collectionName": collection,
+                    "schema": {
+                        "autoId": false,
+                        "enableDynamicField": true,
+                        "fields": fields,
+                    },
+                    "indexParams": index_params,
+                }),
+            )
+            .await?;
+        }
+
+        let partition = json!({"collectionName": collection, "partitionName": self.partition});
+        let exists = self.call("partitions/has", partition.clone()).await?;
+        if !exists["has"].as_bool().unwrap_or(false) {
+            info!(
+                "Creating Milvus partition {} of {}",
+                self.partition, collection
+            );
+            self.call("partitions/create", partition).await?;
+        }
+        self.call("collections/load", json!({"collectionName": collection}))
+            .await?;
+        Ok(())
+    }
+
+    /// Check that `vector` has the index's dimension
+    fn check_dimension(&self, what: &str, vector: &[f32]) -> Result<()> {
+        if vector.len() != self.vector_dimension {
+            bail!(
+                "Vector dimension mismatch for {}: expected {} dimensions, got {}",
+                what,
+                self.vector_dimension,
+                vector.len()
+            );
+        }
+        Ok(())
+    }
+
+    /// Check that `text` fits a VarChar column
+    fn check_length(what: &str, text: &str) -> Result<()> {
+        if text.len() > MAX_VARCHAR {
+            bail!(
+                "{} is {} bytes, over Milvus' limit of {} bytes per string",
+                what,
+                text.len(),
+                MAX_VARCHAR
+            );
+        }
+        Ok(())
+    }
+
+    /// The page of rows of this partition matching `filter` with ids after
+    /// `after`, in id order
+    ///
+    /// Milvus merges the results of limited queries by primary key, so the
+    /// last id of a page is where the next one starts.
+    async fn query_page(
+        &self,
+        collection: &str,
+        filter: &str,
+        after: &str,
+        fields: &[&str],
+    ) -> Result<Vec<Map<String, Value>>> {
+        let filter = and(filter, &format!("id > {}", literal(after)));
+        let data = self
+            .call(
+                "entities/query",
+                json!({
+                    "collectionName": collection,
+                    "partitionNames": [self.partition],
+                    "filter": filter,
+                    "outputFields": fields,
+                    "limit": PAGE,
+                }),
+            )
+            .await?;
+        let Value::Array(rows) = data else {
+            bail!("Unexpected Milvus query response");
+        };
+        let mut rows: Vec<Map<String, Value>> = rows
+            .into_iter()
+            .filter_map(|row| match row {
+                Value::Object(row) => Some(row),
+                _ => None,
+            })
+            .collect();
+        rows.sort_by(|a, b| row_id(a).cmp(row_id(b)));
+        Ok(rows)
+    }
+
+    /// Every row of this partition matching `filter`
+    async fn query_all(
+        &self,
+        collection: &str,
+        filter: &str,
+        fields: &[&str],
+    ) -> Result<Vec<Map<String, Value>>> {
+        let mut rows = Vec::new();
+        let mut after = String::new();
+        loop {
+            let page = self.query_page(collection, filter, &after, fields).await?;
+            let full = page.len() == PAGE;
+            if let Some(last) = page.last().map(row_id) {
+                after = last.to_string();
+            }
+            rows.extend(page);
+            if !full {
+                return Ok(rows);
+            }
+        }
+    }
+
+    async fn upsert_rows(&self, collection: &str, rows: Vec<Map<String, Value>>) -> Result<()> {
+        for batch in rows.chunks(PAGE) {
+            self.call(
+                "entities/upsert",
+                json!({
+                    "collectionName": collection,
+                    "partitionName": self.partition,
+                    "data": batch,
+                }),
+            )
+            .await?;
+        }
+        Ok(())
+    }
+
+    async fn delete_rows(&self, collection: &str, filter: &str) -> Result<()> {
+        self.call(
+            "entities/delete",
+            json!({
+                "collectionName": collection,
+                "partitionName": self.partition,
+                "filter": filter,
+            }),
+        )
+        .await?;
+        Ok(())
+    }
+
+    /// Chunk columns to read, with the vector when `with_vector`
+    fn chunk_fields(with_vector: bool) -> Vec<&'static str> {
+        let mut fields = [CHUNK_FIELDS, DYNAMIC_FIELDS].concat();
+        if with_vector {
+            fields.push("embedding");
+        }
+        fields
+    }
+
+    /// Delete the chunks matching `filter`, and the field vectors matching
+    /// `field_filter`, promoting the duplicates left behind (see
+    /// [`promote_duplicates`])
+    ///
+    /// Milvus has no partial updates, so the promoted rows are written
+    /// again whole.
+    async fn delete_where(&self, filter: &str, field_filter: &str) -> Result<()> {
+        let holders: Vec<String> = self
+            .query_all(
+                &self.collection,
+                &and(filter, "duplicate_of == \"\""),
+                &["id"],
+            )
+            .await?
+            .iter()
+            .filter_map(|row| row_str(row, "id"))
+            .collect();
+
+        let mut duplicates = Vec::new();
+        for holders in holders.chunks(PAGE) {
+            let rows = self
+                .query_all(
+                    &self.collection,
+                    &format!("duplicate_of in {} and not ({})", list(holders), filter),
+                    &Self::chunk_fields(true),
+                )
+                .await?;
+            duplicates.extend(rows.iter().map(chunk_from_row));
+        }
+        let promoted = promote_duplicates(duplicates);
+        self.upsert_rows(&self.collection, promoted.iter().map(chunk_row).collect())
+            .await?;
+
+        self.delete_rows(&self.collection, filter).await?;
+        self.delete_rows(&self.fields_collection, field_filter)
+            .await
+    }
+}
+
+#[async_trait]
+impl VectorStore for MilvusStore {
+    fn backend(&self) -> &'static str {
+        MILVUS_BACKEND
+    }
+
+    fn vector_dimension(&self) -> usize {
+        self.vector_dimension
+    }
+
+    async fn upsert(&self, mut chunks: Vec<IndexedChunk>) -> Result<()> {
+        if chunks.is_empty() {
+            return Ok(());
+        }
+        let ids: Vec<String> = chunks.iter().map(|c| c.id.clone()).collect();
+        for ids in ids.chunks(PAGE) {
+            self.delete_where(
+                &format!("id in {}", list(ids)),
+                &format!("chunk_id in {}", list(ids)),
+            )
+            .await?;
+        }
+
+        let mut holders = HashMap::new();
+        for unique in content_hashes(&chunks).chunks(PAGE) {
+            let rows = self
+                .query_all(
+                    &self.collection,
+                    &format!("content_hash in {} and duplicate_of == \"\"", list(unique)),
+                    &["id", "content_hash", "branch"],
+                )
+                .await?;
+            for row in &rows {
+                if let (Some(hash), Some(id)) = (row_str(row, "content_hash"), row_str(row, "id")) {
+                    holders.insert((hash, row_str(row, "branch")), id);
+                }
+            }
+        }
+
+        mark_duplicates(&mut chunks, holders);
+
+        let mut rows = Vec::with_capacity(chunks.len());
+        for chunk in &chunks {
+            self.check_dimension(&format!("chunk '{}'", chunk.id), &chunk.vector)?;
+            Self::check_length(&format!("Chunk '{}'", chunk.id), &chunk.content)?;
+            rows.push(chunk_row(chunk));
+        }
+        let count = rows.len();
+        self.upsert_rows(&self.collection, rows).await?;
+
+        info!("Inserted {} chunks into database", count);
+        Ok(())
+    }
+
+    async fn upsert_field_vectors(
+        &self,
+        field: VectorField,
+        vectors: Vec<FieldVector>,
+    ) -> Result<()> {
+        if vectors.is_empty() {
+            return Ok(());
+        }
+        let mut rows = Vec::with_capacity(vectors.len());
+        for v in vectors {
+            let what = format!("{} '{}'", field.as_str(), v.text);
+            self.check_dimension(&what, &v.vector)?;
+            Self::check_length(&what, &v.text)?;
+            let key = format!("{}\0{}\0{}", field.as_str(), v.chunk_id, v.text);
+            let row = json!({
+                "id": Uuid::new_v5(&FIELD_ID_NAMESPACE, key.as_bytes()).to_string(),
+                "field": field.as_str(),
+                "chunk_id": v.chunk_id,
+                "file_path": v.file_path,
+                "branch": v.branch.as_deref().unwrap_or(""),
+                "text": v.text,
+                "embedding": v.vector,
+            });
+            let Value::Object(row) = row else {
+                unreachable!()
+            };
+            rows.push(row);
+        }
+        self.upsert_rows(&self.fields_collection, rows).await
+    }
+
+    async fn delete_file(&self, path: &Path) -> Result<()> {
+        let path_str = path.to_string_lossy().to_string();
+        let filter = format!("file_path == {}", literal(&path_str));
+        self.delete_where(&filter, &filter).await?;
+
+        debug!("Deleted chunks for file: {}", path_str);
+        Ok(())
+    }
+
+    async fn delete_branch(&self, branch: &str) -> Result<()> {
+        let filter = format!("branch == {}", literal(branch));
+        self.delete_where(&filter, &filter).await?;
+
+        debug!("Deleted chunks for branch: {}", branch);
+        Ok(())
+    }
+
+    async fn query(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        let data = self
+            .call(
+                "entities/search",
+                json!({
+                    "collectionName": self.collection,
+                    "partitionNames": [self.partition],
+                    "data": [vector],
+                    "annsField": "embedding",
+                    "filter": search_expression(filter),
+                    "limit": limit,
+                    "outputFields": Self::chunk_fields(false),
+                    "searchParams": {"metricType": "L2"},
+                }),
+            )
+            .await?;
+        let Value::Array(hits) = data else {
+            bail!("Unexpected Milvus search response");
+        };
+        let hits: Vec<Map<String, Value>> = hits
+            .into_iter()
+            .filter_map(|hit| match hit {
+                Value::Object(hit) => Some(hit),
+                _ => None,
+            })
+            .collect();
+
+        let ids: Vec<String> = hits.iter().filter_map(|hit| row_str(hit, "id")).collect();
+        let mut duplicates = HashMap::new();
+        if !ids.is_empty() {
+            let rows = self
+                .query_all(
+                    &self.collection,
+                    &format!("duplicate_of in {}", list(&ids)),
+                    &["id", "file_path", "start_line", "end_line", "duplicate_of"],
+                )
+                .await?;
+            duplicates = group_duplicates(rows.iter().map(chunk_from_row).filter_map(|chunk| {
+                Some((
+                    chunk.duplicate_of?,
+                    chunk.file_path,
+                    chunk.start_line,
+                    chunk.end_line,
+                ))
+            }));
+        }
+
+        Ok(hits
+            .iter()
+            .map(|hit| {
+                // Milvus reports L2 as the squared distance
+                let distance = hit.get("distance").and_then(Value::as_f64).unwrap_or(0.0) as f32;
+                let chunk = chunk_from_row(hit);
+                let locations = duplicates.remove(&chunk.id).unwrap_or_default();
+                chunk_result(chunk, 1.0 / (1.0 + distance), locations)
+            })
+            .collect())
+    }
+
+    async fn query_field(
+        &self,
+        field: VectorField,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        let mut field_filter = format!("field == {}", literal(field.as_str()));
+        if let Some(branch) = &filter.branch {
+            field_filter = and(&field_filter, &format!("branch == {}", literal(branch)));
+        }
+        let data = self
+            .call(
+                "entities/search",
+                json!({
+                    "collectionName": self.fields_collection,
+                    "partitionNames": [self.partition],
+                    "data": [vector],
+                    "annsField": "embedding",
+                    "filter": field_filter,
+                    "limit": limit * FIELD_HITS_PER_RESULT,
+                    "outputFields": ["chunk_id"],
+                    "searchParams": {"metricType": "L2"},
+                }),
+            )
+            .await?;
+        let Value::Array(hits) = data else {
+            bail!("Unexpected Milvus search response");
+        };
+        // Milvus reports L2 as the squared distance
+        let hits = hits.iter().filter_map(|hit| {
+            let distance = hit["distance"].as_f64().unwrap_or(0.0) as f32;
+            Some((
+                hit["chunk_id"].as_str()?.to_string(),
+                1.0 / (1.0 + distance),
+            ))
+        });
+        field_search(hits, limit, |ids| async move {
+            Ok(self
+                .query_all(
+                    &self.collection,
+                    &and(&format!("id in {}", list(&ids)), &search_expression(filter)),
+                    &Self::chunk_fields(false),
+                )
+                .await?
+                .iter()
+                .map(chunk_from_row)
+                .collect())
+        })
+        .await
+    }
+
+    async fn has_field_vectors(&self, field: VectorField) -> Result<bool> {
+        let data = self
+            .call(
+                "entities/query",
+                json!({
+                    "collectionName": self.fields_collection,
+                    "partitionNames": [self.partition],
+                    "filter": format!("field == {}", literal(field.as_str())),
+                    "outputFields": ["id"],
+                    "limit": 1,
+                }),
+            )
+            .await?;
+        Ok(data.as_array().is_some_and(|rows| !rows.is_empty()))
+    }
+
+    async fn all_chunks(&self) -> Result<Vec<IndexedChunk>> {
+        Ok(self
+            .query_all(&self.collection, "", &Self::chunk_fields(false))
+            .await?
+            .iter()
+            .map(chunk_from_row)
+            .collect())
+    }
+
+    async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>> {
+        // Duplicates keep their own vector, equal to their holder's
+        Ok(self
+            .query_all(&self.collection, "", &["id", "embedding"])
+            .await?
+            .iter()
+            .map(|row| (row_id(row).to_string(), row_vector(row)))
+            .collect())
+    }
+
+    async fn file_mtimes(&self) -> Result<HashMap<PathBuf, i64>> {
+        let rows = self
+            .query_all(
+                &self.collection,
+                "",
+                &["id", "file_path", "mtime", "branch"],
+            )
+            .await?;
+        Ok(latest_mtimes(rows.iter().map(chunk_from_row)))
+    }
+
+    async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>> {
+        let rows = self
+            .query_all(
+                &self.collection,
+                "duplicate_of == \"\"",
+                &["id", "content_hash", "embedding"],
+            )
+            .await?;
+        let mut vectors = HashMap::new();
+        for row in &rows {
+            let Some(hash) =
+                row_str(row, "content_hash").and_then(|hash| u64::from_str_radix(&hash, 16).ok())
+            else {
+                continue;
+            };
+            vectors.entry(hash).or_insert_with(|| row_vector(row));
+        }
+        Ok(vectors)
+    }
+
+    async fn stats(&self) -> Result<StoreStats> {
+        let data = self
+            .call(
+                "entities/query",
+                json!({
+                    "collectionName": self.collection,
+                    "partitionNames": [self.partition],
+                    "filter": "",
+                    "outputFields": ["count(*)"],
+                }),
+            )
+            .await?;
+        let chunks = data[0]["count(*)"].as_u64().unwrap_or(0) as usize;
+        let files: HashSet<String> = self
+            .query_all(&self.collection, "", &["id", "file_path"])
+            .await?
+            .iter()
+            .filter_map(|row| row_str(row, "file_path"))
+            .collect();
+        Ok(StoreStats {
+            backend: MILVUS_BACKEND,
+            chunks,
+            files: files.len(),
+            vector_dimension: self.vector_dimension,
+        })
+    }
+
+    /// Copy the partition into a single-file SQLite index at `dest`, which
+    /// the `sqlite` backend opens
+    async fn snapshot(&self, dest: &Path) -> Result<()> {
+        if dest.exists() {
+            bail!("Snapshot destination {} already exists", dest.display());
+        }
+        let snapshot = SqliteStore::open(dest, self.vector_dimension)?;
+
+        // Holders first, so each duplicate finds the chunk it shares with
+        let fields = Self::chunk_fields(true);
+        for filter in ["duplicate_of == \"\"", "duplicate_of != \"\""] {
+            let mut after = String::new();
+            loop {
+                let page = self
+                    .query_page(&self.collection, filter, &after, &fields)
+                    .await?;
+                let full = page.len() == PAGE;
+                if let Some(last) = page.last().map(row_id) {
+                    after = last.to_string();
+                }
+                snapshot
+                    .upsert(page.iter().map(chunk_from_row).collect())
+                    .await?;
+                if !full {
+                    break;
+                }
+            }
+        }
+
+        let mut fields = FIELD_VECTOR_FIELDS.to_vec();
+        fields.extend(["id", "embedding"]);
+        for field in VectorField::ALL {
+            let filter = format!("field == {}", literal(field.as_str()));
+            let mut after = String::new();
+            loop {
+                let page = self
+                    .query_page(&self.fields_collection, &filter, &after, &fields)
+                    .await?;
+                let full = page.len() == PAGE;
+                if let Some(last) = page.last().map(row_id) {
+                    after = last.to_string();
+                }
+                let vectors = page
+                    .iter()
+                    .map(|row| FieldVector {
+                        chunk_id: row_str(row, "chunk_id").unwrap_or_default(),
+                        file_path: row_str(row, "file_path").unwrap_or_default(),
+                        branch: row_str(row, "branch"),
+                        text: row_str(row, "text").unwrap_or_default(),
+                        vector: row_vector(row),
+                    })
+                    .collect();
+                snapshot.upsert_field_vectors(field, vectors).await?;
+                if !full {
+                    break;
+                }
+            }
+        }
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_search_filters_translate_to_expressions() {
+        let filter = SearchFilter {
+            kind: Some("function".to_string()),
+            tag: Some("concurrency".to_string()),
+            meta: vec!["owner=".to_string(), "team=search".to_string()],
+            branch: Some("main".to_string()),
+        };
+        assert_eq!(
+            search_expression(&filter),
+            "duplicate_of == \"\" and semantic_kind == \"function\" \
+             and array_contains(tags, \"concurrency\") \
+             and array_contains(tag_keys, \"owner=\") \
+             and array_contains(tags, \"team=search\") and branch == \"main\""
+        );
+        assert_eq!(
+            search_expression(&SearchFilter::default()),
+            "duplicate_of == \"\""
+        );
+    }
+
+    #[test]
+    fn test_literals_are_escaped() {
+        assert_eq!(literal(r#"a "b" \c"#), r#""a \"b\" \\c""#);
+        assert_eq!(list(&["a".to_string(), "b".to_string()]), r#"["a", "b"]"#);
+        assert_eq!(and("", "id > \"\""), "id > \"\"");
+        assert_eq!(and("a", "b"), "(a) and (b)");
+    }
+
+    #[test]
+    fn test_chunks_round_trip_through_rows() {
+        let chunk = IndexedChunk {
+            id: "a".to_string(),
+            content: "fn a() {}".to_string(),
+            file_path: "src/a.rs".to_string(),
+            start_line: 1,
+            end_line: 3,
+            language: Some("rust".to_string()),
+            vector: vec![1.0, 0.0],
+            mtime: 10,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some("a".to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: vec!["owner=search".to_string(), "concurrency".to_string()],
+            branch: None,
+            duplicate_of: None,
+        };
+        let row = chunk_row(&chunk);
+        assert_eq!(row["tag_keys"], json!(["owner="]));
+        assert_eq!(row["branch"], json!(""));
+        assert!(!row.contains_key("signature"));
+
+        let back = chunk_from_row(&row);
+        assert_eq!(back.id, chunk.id);
+        assert_eq!(back.language, chunk.language);
+        assert_eq!(back.tags, chunk.tags);
+        assert_eq!(back.branch, None);
+        assert_eq!(back.duplicate_of, None);
+        assert_eq!(back.vector, chunk.vector);
+        assert_eq!((back.start_line, back.end_line, back.mtime), (1, 3, 10));
+    }
+}
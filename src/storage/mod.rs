@@ -1,3 +1,26 @@
+pub mod chunk_id;
+pub mod integrity;
 mod lancedb;
+mod milvus;
+pub mod parquet;
+mod pgvector;
+mod qdrant;
+mod sqlite;
+mod store;
+mod weaviate;
 
-pub use self::lancedb::{IndexedChunk, SearchResult, Storage};
+pub use self::chunk_id::{assign_ids, ChunkKey, DeterministicIds, IdGenerator};
+pub use self::lancedb::{
+    content_hash, FieldVector, IndexedChunk, SearchFilter, SearchResult, Storage, VectorField,
+};
+pub use self::milvus::MilvusStore;
+pub use self::parquet::{ExportedFile, ParquetExport};
+pub use self::pgvector::PgVectorStore;
+pub use self::qdrant::QdrantStore;
+pub use self::sqlite::SqliteStore;
+pub use self::store::{
+    backends, open_configured_store, open_existing_store, open_vector_store, register_backend,
+    BackendFactory, NoIndex, StoreLocation, StoreStats, VectorStore, LANCEDB_BACKEND,
+    MILVUS_BACKEND, PGVECTOR_BACKEND, QDRANT_BACKEND, SQLITE_BACKEND, WEAVIATE_BACKEND,
+};
+pub use self::weaviate::WeaviateStore;
@@ -0,0 +1,400 @@
+//! Parquet export for analytics
+//!
+//! Writes the index as Parquet files that DuckDB, Spark or any lakehouse
+//! engine can query directly. This is an export target, not a searchable
+//! store: nothing reads the files back into coderag.
+//!
+//! # Layout
+//!
+//! Files are Hive-partitioned by language and never overwritten. Each export
+//! appends one new part file per language:
+//!
+//! ```text
+//! <dir>/language=go/part-20240501T120000.123Z.parquet
+//! <dir>/language=rust/part-20240501T120000.123Z.parquet
+//! <dir>/language=unknown/...      (chunks without a detected language)
+//! ```
+//!
+//! ```sql
+//! SELECT language, count(*) FROM read_parquet('<dir>/**/*.parquet', hive_partitioning = true)
+//! GROUP BY language;
+//! ```
+//!
+//! # Schema (version 2)
+//!
+//! | Column           | Type                          | Nullable |
+//! |------------------|-------------------------------|----------|
+//! | `id`             | Utf8                          | no       |
+//! | `file_path`      | Utf8                          | no       |
+//! | `start_line`     | Int32                         | no       |
+//! | `end_line`       | Int32                         | no       |
+//! | `semantic_kind`  | Utf8                          | yes      |
+//! | `symbol_name`    | Utf8                          | yes      |
+//! | `qualified_name` | Utf8                          | yes      |
+//! | `signature`      | Utf8                          | yes      |
+//! | `parent`         | Utf8                          | yes      |
+//! | `visibility`     | Utf8                          | yes      |
+//! | `tags`           | List\<Utf8\>                  | no       |
+//! | `branch`         | Utf8                          | yes      |
+//! | `mtime`          | Int64 (Unix seconds)          | no       |
+//! | `content`        | Utf8                          | no       |
+//! | `vector`         | FixedSizeList\<Float32, dim\> | yes      |
+//! | `doc`            | Utf8                          | yes      |
+//!
+//! `language` is the partition column and is not stored in the files. The
+//! schema version is recorded under the `coderag.schema_version` schema
+//! metadata key; columns are only ever added, under a new version. Version
+//! 2 added `doc`.
+
+use anyhow::{Context, Result};
+use arrow_array::builder::{ListBuilder, StringBuilder};
+use arrow_array::types::Float32Type;
+use arrow_array::{FixedSizeListArray, Int32Array, Int64Array, RecordBatch, StringArray};
+use arrow_schema::{DataType, Field, Schema, SchemaRef};
+use parquet::arrow::ArrowWriter;
+use parquet::basic::Compression;
+use parquet::file::properties::WriterProperties;
+use std::collections::{BTreeMap, HashMap};
+use std::fs::OpenOptions;
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+
+use super::IndexedChunk;
+
+/// Version of the export schema, stored in the file metadata
+pub const PARQUET_SCHEMA_VERSION: u32 = 2;
+
+/// Schema metadata key holding [`PARQUET_SCHEMA_VERSION`]
+pub const SCHEMA_VERSION_KEY: &str = "coderag.schema_version";
+
+/// Partition value for chunks without a language
+const UNKNOWN_LANGUAGE: &str = "unknown";
+
+/// A part file written by an export
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ExportedFile {
+    /// Partition (language) of the file
+    pub language: String,
+    pub path: PathBuf,
+    pub rows: usize,
+}
+
+/// Writes chunks as language-partitioned Parquet files
+#[derive(Debug, Clone)]
+pub struct ParquetExport {
+    dir: PathBuf,
+    vector_dimension: usize,
+}
+
+impl ParquetExport {
+    /// Export into `dir`, with vectors of `vector_dimension` floats
+    pub fn new(dir: impl Into<PathBuf>, vector_dimension: usize) -> Self {
+        Self {
+            dir: dir.into(),
+            vector_dimension,
+        }
+    }
+
+    /// The schema of every exported file
+    pub fn schema(&self) -> SchemaRef {
+        let metadata = HashMap::from([(
+            SCHEMA_VERSION_KEY.to_string(),
+            PARQUET_SCHEMA_VERSION.to_string(),
+        )]);
+        Arc::new(
+            Schema::new(vec![
+                Field::new("id", DataType::Utf8, false),
+                Field::new("file_path", DataType::Utf8, false),
+                Field::new("start_line", DataType::Int32, false),
+                Field::new("end_line", DataType::Int32, false),
+                Field::new("semantic_kind", DataType::Utf8, true),
+                Field::new("symbol_name", DataType::Utf8, true),
+                Field::new("qualified_name", DataType::Utf8, true),
+                Field::new("signature", DataType::Utf8, true),
+                Field::new("parent", DataType::Utf8, true),
+                Field::new("visibility", DataType::Utf8, true),
+                Field::new(
+                    "tags",
+                    DataType::List(Arc::new(Field::new("item", DataType::Utf8, true))),
+                    false,
+                ),
+                Field::new("branch", DataType::Utf8, true),
+                Field::new("mtime", DataType::Int64, false),
+                Field::new("content", DataType::Utf8, false),
+                Field::new(
+                    "vector",
+                    DataType::FixedSizeList(
+                        Arc::new(Field::new("item", DataType::Float32, true)),
+                        self.vector_dimension as i32,
+                    ),
+                    true,
+                ),
+                Field::new("doc", DataType::Utf8, true),
+            ])
+            .with_metadata(metadata),
+        )
+    }
+
+    /// Append one part file per language holding the given chunks.
+    ///
+    /// Chunks whose vector does not have the export dimension are written
+    /// with a null vector.
+    pub fn write(&self, chunks: &[IndexedChunk]) -> Result<Vec<ExportedFile>> {
+        let mut by_language: BTreeMap<String, Vec<&IndexedChunk>> = BTreeMap::new();
+        for chunk in chunks {
+            let language = chunk
+                .language
+                .as_deref()
+                .map(partition_value)
+                .unwrap_or_else(|| UNKNOWN_LANGUAGE.to_string());
+            by_language.entry(language).or_default().push(chunk);
+        }
+
+        let part = format!(
+            "part-{}.parquet",
+            chrono::Utc::now().format("%Y%m%dT%H%M%S%.3fZ")
+        );
+        let mut files = Vec::new();
+        for (language, chunks) in by_language {
+            let dir = self.dir.join(format!("language={}", language));
+            std::fs::create_dir_all(&dir)
+                .with_context(|| format!("Failed to create export directory {:?}", dir))?;
+            let path = dir.join(&part);
+            self.write_file(&path, &chunks)?;
+            files.push(ExportedFile {
+                language,
+                path,
+                rows: chunks.len(),
+            });
+        }
+        Ok(files)
+    }
+
+    fn write_file(&self, path: &Path, chunks: &[&IndexedChunk]) -> Result<()> {
+        // create_new: exports append, they never replace earlier files
+        let file = OpenOptions::new()
+            .write(true)
+            .create_new(true)
+            .open(path)
+            .with_context(|| format!("Failed to create Parquet file {:?}", path))?;
+
+        let schema = self.schema();
+        let batch = self.record_batch(&schema, chunks)?;
+        let props = WriterProperties::builder()
+            .set_compression(Compression::SNAPPY)
+            .build();
+        let mut writer = ArrowWriter::try_new(file, schema, Some(props))
+            .with_context(|| format!("Failed to write Parquet file {:?}", path))?;
+        writer
+            .write(&batch)
+            .with_context(|| format!("Failed to write Parquet file {:?}", path))?;
+        writer
+            .close()
+            .with_context(|| format!("Failed to finish Parquet file {:?}", path))?;
+        Ok(())
+    }
+
+    fn record_batch(&self, schema: &SchemaRef, chunks: &[&IndexedChunk]) -> Result<RecordBatch> {
+        let text = |f: fn(&IndexedChunk) -> &str| -> StringArray {
+            chunks.iter().map(|c| Some(f(c))).collect()
+        };
+        let optional = |f: fn(&IndexedChunk) -> Option<&str>| -> StringArray {
+            chunks.iter().map(|c| f(c)).collect()
+        };
+
+        let mut tags = ListBuilder::new(StringBuilder::new());
+        for chunk in chunks {
+            for tag in &chunk.tags {
+                tags.values().append_value(tag);
+            }
+            tags.append(true);
+        }
+
+        let vectors = FixedSizeListArray::from_iter_primitive::<Float32Type, _, _>(
+            chunks.iter().map(|c| {
+                (c.vector.len() == self.vector_dimension).then(|| c.vector.iter().map(|&v| Some(v)))
+            }),
+            self.vector_dimension as i32,
+        );
+
+        RecordBatch::try_new(
+            schema.clone(),
+            vec![
+                Arc::new(text(|c| c.id.as_str())),
+                Arc::new(text(|c| c.file_path.as_str())),
+                Arc::new(Int32Array::from_iter_values(
+                    chunks.iter().map(|c| c.start_line as i32),
+                )),
+                Arc::new(Int32Array::from_iter_values(
+                    chunks.iter().map(|c| c.end_line as i32),
+                )),
+                Arc::new(optional(|c| c.semantic_kind.as_deref())),
+                Arc::new(optional(|c| c.symbol_name.as_deref())),
+                Arc::new(optional(|c| c.qualified_name.as_deref())),
+                Arc::new(optional(|c| c.signature.as_deref())),
+                Arc::new(optional(|c| c.parent.as_deref())),
+                Arc::new(optional(|c| c.visibility.as_deref())),
+                Arc::new(tags.finish()),
+                Arc::new(optional(|c| c.branch.as_deref())),
+                Arc::new(Int64Array::from_iter_values(chunks.iter().map(|c| c.mtime))),
+                Arc::new(text(|c| c.content.as_str())),
+                Arc::new(vectors),
+                Arc::new(optional(|c| c.doc.as_deref())),
+            ],
+        )
+        .with_context(|| "Failed to create RecordBatch")
+    }
+}
+
+/// Partition directory value: characters outside `[A-Za-z0-9_+-]` become `_`
+fn partition_value(language: &str) -> String {
+    let value: String = language
+        .trim()
+        .to_lowercase()
+        .chars()
+        .map(|c| {
+            if c.is_ascii_alphanumeric() || matches!(c, '_' | '+' | '-') {
+                c
+            } else {
+                '_'
+            }
+        })
+        .collect();
+    if value.is_empty() {
+        UNKNOWN_LANGUAGE.to_string()
+    } else {
+        value
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use parquet::arrow::arrow_reader::ParquetRecordBatchReaderBuilder;
+    use std::fs::File;
+    use tempfile::tempdir;
+
+    fn chunk(id: &str, language: Option<&str>, vector: Vec<f32>) -> IndexedChunk {
+        IndexedChunk {
+            id: id.to_string(),
+            content: format!("fn {}() {{}}", id),
+            file_path: format!("src/{}.rs", id),
+            start_line: 1,
+            end_line: 3,
+            language: language.map(str::to_string),
+            vector,
+            mtime: 1_700_000_000,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some(id.to_string()),
+            signature: Some(format!("fn {}()", id)),
+            doc: None,
+            parent: None,
+            visibility: Some("pub".to_string()),
+            qualified_name: None,
+            tags: vec!["concurrency".to_string()],
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    /// Schema and row count of a Parquet file
+    fn read_back(path: &Path) -> (SchemaRef, usize) {
+        let builder = ParquetRecordBatchReaderBuilder::try_new(File::open(path).unwrap()).unwrap();
+        let schema = builder.schema().clone();
+        let rows = builder
+            .build()
+            .unwrap()
+            .map(|batch| batch.unwrap().num_rows())
+            .sum();
+        (schema, rows)
+    }
+
+    #[test]
+    fn test_export_partitions_by_language() {
+        let dir = tempdir().unwrap();
+        let export = ParquetExport::new(dir.path(), 3);
+        let chunks = vec![
+            chunk("a", Some("rust"), vec![0.1, 0.2, 0.3]),
+            chunk("b", Some("rust"), vec![0.4, 0.5, 0.6]),
+            chunk("c", Some("go"), vec![0.7, 0.8, 0.9]),
+            // Wrong dimension: exported with a null vector
+            chunk("d", None, vec![1.0]),
+        ];
+
+        let files = export.write(&chunks).unwrap();
+        let rows: Vec<(&str, usize)> = files
+            .iter()
+            .map(|f| (f.language.as_str(), f.rows))
+            .collect();
+        assert_eq!(rows, vec![("go", 1), ("rust", 2), ("unknown", 1)]);
+
+        for file in &files {
+            assert!(file
+                .path
+                .starts_with(dir.path().join(format!("language={}", file.language))));
+            let (schema, count) = read_back(&file.path);
+            assert_eq!(count, file.rows);
+
+            let names: Vec<&str> = schema.fields().iter().map(|f| f.name().as_str()).collect();
+            assert_eq!(
+                names,
+                vec![
+                    "id",
+                    "file_path",
+                    "start_line",
+                    "end_line",
+                    "semantic_kind",
+                    "symbol_name",
+                    "qualified_name",
+                    "signature",
+                    "parent",
+                    "visibility",
+                    "tags",
+                    "branch",
+                    "mtime",
+                    "content",
+                    "vector",
+                    "doc"
+                ]
+            );
+            assert_eq!(
+                schema.field_with_name("vector").unwrap().data_type(),
+                export
+                    .schema()
+                    .field_with_name("vector")
+                    .unwrap()
+                    .data_type()
+            );
+            assert_eq!(
+                schema
+                    .metadata()
+                    .get(SCHEMA_VERSION_KEY)
+                    .map(String::as_str),
+                Some("2")
+            );
+        }
+    }
+
+    #[test]
+    fn test_export_appends() {
+        let dir = tempdir().unwrap();
+        let export = ParquetExport::new(dir.path(), 2);
+        let chunks = vec![chunk("a", Some("rust"), vec![0.1, 0.2])];
+
+        let first = export.write(&chunks).unwrap();
+        std::thread::sleep(std::time::Duration::from_millis(5));
+        let second = export.write(&chunks).unwrap();
+
+        assert_ne!(first[0].path, second[0].path);
+        assert!(first[0].path.exists() && second[0].path.exists());
+    }
+
+    #[test]
+    fn test_partition_value() {
+        assert_eq!(partition_value("Rust"), "rust");
+        assert_eq!(partition_value("c++"), "c++");
+        assert_eq!(partition_value("objective c"), "objective_c");
+        assert_eq!(partition_value(" "), "unknown");
+    }
+}
@@ -0,0 +1,861 @@
+//! Postgres vector store
+//!
+//! Keeps the index in Postgres with the
+//! [pgvector](https://github.com/pgvector/pgvector) extension, so a team
+//! can share one central index. Each index has its own tables, named after
+//! a namespace, and an HNSW index on the chunk vectors; search filters are
+//! pushed down into the `WHERE` clause.
+//!
+//! Behaviour matches the LanceDB [`Storage`](super::Storage): identical
+//! chunks on one branch share a vector, and scores are `1 / (1 + d)` of the
+//! squared L2 distance `d`.
+
+use anyhow::{bail, Context, Result};
+use async_trait::async_trait;
+use sqlx::postgres::{PgPool, PgPoolOptions, PgRow};
+use sqlx::{Postgres, Row, Transaction};
+use std::collections::hash_map::Entry;
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use tracing::{debug, info};
+
+use super::lancedb::{
+    content_hash, sql_escape, sql_list, FieldVector, IndexedChunk, SearchFilter, SearchResult,
+    VectorField,
+};
+use super::sqlite::SqliteStore;
+use super::store::{
+    content_hashes, group_duplicates, holder_key, mark_duplicates, matching_files, StoreStats,
+    VectorStore, PGVECTOR_BACKEND,
+};
+
+/// Schema migrations, applied in order to each namespace; `{ns}` stands for
+/// the namespace and `{dim}` for the vector dimension. Never edit a released
+/// migration, append a new one.
+const MIGRATIONS: &[&[&str]] = &[
+    &[
+        "CREATE EXTENSION IF NOT EXISTS vector",
+        "CREATE TABLE {ns}_chunks (
+            id TEXT PRIMARY KEY,
+            content TEXT NOT NULL,
+            file_path TEXT NOT NULL,
+            start_line INTEGER NOT NULL,
+            end_line INTEGER NOT NULL,
+            language TEXT,
+            mtime BIGINT NOT NULL,
+            file_header TEXT,
+            semantic_kind TEXT,
+            symbol_name TEXT,
+            signature TEXT,
+            parent TEXT,
+            visibility TEXT,
+            qualified_name TEXT,
+            tags TEXT,
+            branch TEXT,
+            doc TEXT,
+            content_hash TEXT NOT NULL,
+            duplicate_of TEXT,
+            embedding vector({dim})
+        )",
+        "CREATE INDEX {ns}_chunks_file_path ON {ns}_chunks (file_path)",
+        "CREATE INDEX {ns}_chunks_branch ON {ns}_chunks (branch)",
+        "CREATE INDEX {ns}_chunks_content_hash ON {ns}_chunks (content_hash)",
+        "CREATE INDEX {ns}_chunks_duplicate_of ON {ns}_chunks (duplicate_of)",
+        "CREATE TABLE {ns}_field_vectors (
+            field TEXT NOT NULL,
+            chunk_id TEXT NOT NULL,
+            file_path TEXT NOT NULL,
+            branch TEXT,
+            text TEXT NOT NULL,
+            embedding vector({dim}) NOT NULL
+        )",
+        "CREATE INDEX {ns}_field_vectors_chunk_id ON {ns}_field_vectors (chunk_id)",
+    ],
+    &["CREATE INDEX {ns}_chunks_embedding ON {ns}_chunks USING hnsw (embedding vector_l2_ops)"],
+];
+
+/// Columns of a chunk read into an [`IndexedChunk`]
+const CHUNK_COLUMNS: &str = "id, content, file_path, start_line, end_line, language, mtime, \
+    file_header, semantic_kind, symbol_name, signature, parent, visibility, qualified_name, \
+    tags, branch, doc, duplicate_of, embedding::text AS embedding";
+
+/// [`CHUNK_COLUMNS`] with the vector left out
+const METADATA_COLUMNS: &str = "id, content, file_path, start_line, end_line, language, mtime, \
+    file_header, semantic_kind, symbol_name, signature, parent, visibility, qualified_name, \
+    tags, branch, doc, duplicate_of, NULL::text AS embedding";
+
+/// Rows copied per query when taking a snapshot
+const SNAPSHOT_PAGE: i64 = 1000;
+
+/// Postgres storage backend, with pgvector for vectors
+pub struct PgVectorStore {
+    pool: PgPool,
+    /// Prefix of the index's table names
+    namespace: String,
+    vector_dimension: usize,
+}
+
+/// `statement` of a migration for `namespace`
+fn render(statement: &str, namespace: &str, dimension: usize) -> String {
+    statement
+        .replace("{ns}", namespace)
+        .replace("{dim}", &dimension.to_string())
+}
+
+/// A vector as a pgvector literal, e.g. `[1,0.5]`
+fn vector_literal(vector: &[f32]) -> String {
+    let values: Vec<String> = vector.iter().map(|x| x.to_string()).collect();
+    format!("[{}]", values.join(","))
+}
+
+fn parse_vector(literal: &str) -> Result<Vec<f32>> {
+    let values = literal.trim().trim_start_matches('[').trim_end_matches(']');
+    if values.is_empty() {
+        return Ok(Vec::new());
+    }
+    values
+        .split(',')
+        .map(|x| {
+            x.trim()
+                .parse()
+                .with_context(|| format!("Invalid vector value: {}", x))
+        })
+        .collect()
+}
+
+fn chunk_from_row(row: &PgRow) -> Result<IndexedChunk> {
+    let tags: Option<String> = row.try_get("tags")?;
+    let embedding: Option<String> = row.try_get("embedding")?;
+    Ok(IndexedChunk {
+        id: row.try_get("id")?,
+        content: row.try_get("content")?,
+        file_path: row.try_get("file_path")?,
+        start_line: row.try_get::<i32, _>("start_line")? as usize,
+        end_line: row.try_get::<i32, _>("end_line")? as usize,
+        language: row.try_get("language")?,
+        vector: embedding
+            .as_deref()
+            .map(parse_vector)
+            .transpose()?
+            .unwrap_or_default(),
+        mtime: row.try_get("mtime")?,
+        file_header: row.try_get("file_header")?,
+        semantic_kind: row.try_get("semantic_kind")?,
+        symbol_name: row.try_get("symbol_name")?,
+        signature: row.try_get("signature")?,
+        doc: row.try_get("doc")?,
+        parent: row.try_get("parent")?,
+        visibility: row.try_get("visibility")?,
+        qualified_name: row.try_get("qualified_name")?,
+        tags: tags
+            .map(|t| t.split(',').map(str::to_string).collect())
+            .unwrap_or_default(),
+        branch: row.try_get("branch")?,
+        duplicate_of: row.try_get("duplicate_of")?,
+    })
+}
+
+impl PgVectorStore {
+    /// Connect to the database at `url` and open the index `namespace`,
+    /// creating or migrating its tables
+    ///
+    /// # Errors
+    ///
+    /// Returns an error if `vector_dimension` is 0, the database cannot be
+    /// reached or lacks pgvector, or the index holds vectors of another
+    /// dimension.
+    pub async fn connect(url: &str, namespace: &str, vector_dimension: usize) -> Result<Self> {
+        if vector_dimension == 0 {
+            bail!("Vector dimension must be greater than 0");
+        }
+        Self::open(Self::pool(url).await?, namespace, vector_dimension).await
+    }
+
+    /// Connect to the database at `url` and open the index `namespace` with
+    /// the vector dimension it was written with
+    ///
+    /// Returns None when the index has not been created yet.
+    pub async fn connect_existing(url: &str, namespace: &str) -> Result<Option<Self>> {
+        let pool = Self::pool(url).await?;
+        let has_schema: bool =
+            sqlx::query_scalar("SELECT to_regclass('coderag_schema') IS NOT NULL")
+                .fetch_one(&pool)
+                .await?;
+        if !has_schema {
+            return Ok(None);
+        }
+        let stored: Option<i32> =
+            sqlx::query_scalar("SELECT vector_dimension FROM coderag_schema WHERE namespace = $1")
+                .bind(namespace)
+                .fetch_optional(&pool)
+                .await?;
+        match stored {
+            Some(dimension) => Ok(Some(Self::open(pool, namespace, dimension as usize).await?)),
+            None => Ok(None),
+        }
+    }
+
+    async fn pool(url: &str) -> Result<PgPool> {
+        // The URL may hold a password, so it is left out of errors
+        PgPoolOptions::new()
+            .max_connections(8)
+            .connect(url)
+            .await
+            .context("Failed to connect to the pgvector database")
+    }
+
+    async fn open(pool: PgPool, namespace: &str, vector_dimension: usize) -> Result<Self> {
+        let store = Self {
+            pool,
+            namespace: namespace.to_string(),
+            vector_dimension,
+        };
+        store.migrate().await?;
+        debug!("Opened pgvector index {}", namespace);
+        Ok(store)
+    }
+
+    /// Bring the namespace's tables up to the latest migration
+    async fn migrate(&self) -> Result<()> {
+        let mut tx = self.pool.begin().await?;
+        // Team members may open the index at the same time
+        sqlx::query("SELECT pg_advisory_xact_lock(hashtext('coderag_schema'))")
+            .execute(&mut *tx)
+            .await?;
+        sqlx::query(
+            "CREATE TABLE IF NOT EXISTS coderag_schema (
+                namespace TEXT PRIMARY KEY,
+                version INTEGER NOT NULL,
+                vector_dimension INTEGER NOT NULL
+            )",
+        )
+        .execute(&mut *tx)
+        .await?;
+
+        let stored: Option<(i32, i32)> = sqlx::query_as(
+            "SELECT version, vector_dimension FROM coderag_schema WHERE namespace = $1",
+        )
+        .bind(&self.namespace)
+        .fetch_optional(&mut *tx)
+        .await?;
+        let version = match stored {
+            Some((_, dimension)) if dimension as usize != self.vector_dimension => bail!(
+                "Vector dimension mismatch: storage configured for {} dimensions, \
+                 but existing index has {} dimensions. \
+                 Delete the existing index or use matching embedding model.",
+                self.vector_dimension,
+                dimension
+            ),
+            Some((version, _)) => version as usize,
+            None => 0,
+        };
+        if version > MIGRATIONS.len() {
+            bail!(
+                "Index {} was created by a newer CodeRAG (schema version {})",
+                self.namespace,
+                version
+            );
+        }
+        if version == MIGRATIONS.len() {
+            return Ok(());
+        }
+
+        for (i, migration) in MIGRATIONS.iter().enumerate().skip(version) {
+            for statement in *migration {
+                sqlx::query(&render(statement, &self.namespace, self.vector_dimension))
+                    .execute(&mut *tx)
+                    .await
+                    .with_context(|| {
+                        format!("Failed to migrate {} to version {}", self.namespace, i + 1)
+                    })?;
+            }
+        }
+        sqlx::query(
+            "INSERT INTO coderag_schema (namespace, version, vector_dimension)
+             VALUES ($1, $2, $3)
+             ON CONFLICT (namespace) DO UPDATE SET version = EXCLUDED.version",
+        )
+        .bind(&self.namespace)
+        .bind(MIGRATIONS.len() as i32)
+        .bind(self.vector_dimension as i32)
+        .execute(&mut *tx)
+        .await?;
+        tx.commit().await?;
+
+        info!(
+            "Migrated pgvector index {} from schema version {} to {}",
+            self.namespace,
+            version,
+            MIGRATIONS.len()
+        );
+        Ok(())
+    }
+
+    /// Check that `vector` has the index's dimension
+    fn check_dimension(&self, what: &str, vector: &[f32]) -> Result<()> {
+        if vector.len() != self.vector_dimension {
+            bail!(
+                "Vector dimension mismatch for {}: expected {} dimensions, got {}",
+                what,
+                self.vector_dimension,
+                vector.len()
+            );
+        }
+        Ok(())
+    }
+
+    /// Delete the chunks matching `predicate`, and the field vectors
+    /// matching `field_predicate`
+    ///
+    /// The duplicates left behind are promoted as by
+    /// [`promote_duplicates`](super::store::promote_duplicates), in SQL, the
+    /// heir taking over the deleted holder's vector.
+    async fn delete_where(
+        &self,
+        tx: &mut Transaction<'_, Postgres>,
+        predicate: &str,
+        field_predicate: &str,
+    ) -> Result<()> {
+        let ns = &self.namespace;
+        let heirs: Vec<(String, String)> = sqlx::query_as(&format!(
+            "SELECT DISTINCT ON (duplicate_of) duplicate_of, id FROM {ns}_chunks
+             WHERE duplicate_of IN (SELECT id FROM {ns}_chunks
+                                    WHERE duplicate_of IS NULL AND ({predicate}))
+               AND NOT ({predicate})
+             ORDER BY duplicate_of, id"
+        ))
+        .fetch_all(&mut **tx)
+        .await?;
+        for (holder, heir) in heirs {
+            sqlx::query(&format!(
+                "UPDATE {ns}_chunks SET duplicate_of = NULL,
+                     embedding = (SELECT embedding FROM {ns}_chunks WHERE id = $2)
+                 WHERE id = $1"
+            ))
+            .bind(&heir)
+            .bind(&holder)
+            .execute(&mut **tx)
+            .await?;
+            sqlx::query(&format!(
+                "UPDATE {ns}_chunks SET duplicate_of = $1
+                 WHERE duplicate_of = $2 AND NOT ({predicate})"
+            ))
+            .bind(&heir)
+            .bind(&holder)
+            .execute(&mut **tx)
+            .await?;
+        }
+
+        sqlx::query(&format!("DELETE FROM {ns}_chunks WHERE {predicate}"))
+            .execute(&mut **tx)
+            .await?;
+        sqlx::query(&format!(
+            "DELETE FROM {ns}_field_vectors WHERE {field_predicate}"
+        ))
+        .execute(&mut **tx)
+        .await?;
+        Ok(())
+    }
+
+    /// Locations (`path:start-end`) of the duplicates of each chunk in
+    /// `ids`, keyed by chunk id
+    async fn duplicate_locations(&self, ids: &[String]) -> Result<HashMap<String, Vec<String>>> {
+        if ids.is_empty() {
+            return Ok(HashMap::new());
+        }
+        let rows: Vec<(String, String, i32, i32)> = sqlx::query_as(&format!(
+            "SELECT duplicate_of, file_path, start_line, end_line FROM {}_chunks
+             WHERE duplicate_of = ANY($1)",
+            self.namespace
+        ))
+        .bind(ids)
+        .fetch_all(&self.pool)
+        .await?;
+        Ok(group_duplicates(rows.into_iter().map(
+            |(holder, file_path, start_line, end_line)| {
+                (holder, file_path, start_line as usize, end_line as usize)
+            },
+        )))
+    }
+}
+
+#[async_trait]
+impl VectorStore for PgVectorStore {
+    fn backend(&self) -> &'static str {
+        PGVECTOR_BACKEND
+    }
+
+    fn vector_dimension(&self) -> usize {
+        self.vector_dimension
+    }
+
+    async fn upsert(&self, mut chunks: Vec<IndexedChunk>) -> Result<()> {
+        if chunks.is_empty() {
+            return Ok(());
+        }
+        let ns = &self.namespace;
+        let count = chunks.len();
+        let mut tx = self.pool.begin().await?;
+        let ids = sql_list(chunks.iter().map(|c| c.id.as_str()));
+        self.delete_where(
+            &mut tx,
+            &format!("id IN ({ids})"),
+            &format!("chunk_id IN ({ids})"),
+        )
+        .await?;
+
+        let holders: Vec<(String, Option<String>, String)> = sqlx::query_as(&format!(
+            "SELECT content_hash, branch, id FROM {ns}_chunks
+             WHERE content_hash = ANY($1) AND duplicate_of IS NULL"
+        ))
+        .bind(content_hashes(&chunks))
+        .fetch_all(&mut *tx)
+        .await?;
+        mark_duplicates(
+            &mut chunks,
+            holders
+                .into_iter()
+                .map(|(hash, branch, id)| ((hash, branch), id))
+                .collect(),
+        );
+
+        for chunk in chunks {
+            // Only holders store a vector
+            if chunk.duplicate_of.is_none() {
+                self.check_dimension(&format!("chunk '{}'", chunk.id), &chunk.vector)?;
+            }
+            let (hash, _) = holder_key(&chunk);
+            let tags = (!chunk.tags.is_empty()).then(|| chunk.tags.join(","));
+            let embedding = chunk
+                .duplicate_of
+                .is_none()
+                .then(|| vector_literal(&chunk.vector));
+            sqlx::query(&format!(
+                "INSERT INTO {ns}_chunks (id, content, file_path, start_line, end_line, language,
+                     mtime, file_header, semantic_kind, symbol_name, signature, parent,
+                     visibility, qualified_name, tags, branch, doc, content_hash, duplicate_of,
+                     embedding)
+                 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+                     $17, $18, $19, $20::vector)"
+            ))
+            .bind(&chunk.id)
+            .bind(&chunk.content)
+            .bind(&chunk.file_path)
+            .bind(chunk.start_line as i32)
+            .bind(chunk.end_line as i32)
+            .bind(&chunk.language)
+            .bind(chunk.mtime)
+            .bind(&chunk.file_header)
+            .bind(&chunk.semantic_kind)
+            .bind(&chunk.symbol_name)
+            .bind(&chunk.signature)
+            .bind(&chunk.parent)
+            .bind(&chunk.visibility)
+            .bind(&chunk.qualified_name)
+            .bind(tags)
+            .bind(&chunk.branch)
+            .bind(&chunk.doc)
+            .bind(&hash)
+            .bind(&chunk.duplicate_of)
+            .bind(embedding)
+            .execute(&mut *tx)
+            .await
+            .with_context(|| "Failed to insert chunks")?;
+        }
+        tx.commit().await?;
+
+        info!("Inserted {} chunks into database", count);
+        Ok(())
+    }
+
+    async fn upsert_field_vectors(
+        &self,
+        field: VectorField,
+        vectors: Vec<FieldVector>,
+    ) -> Result<()> {
+        if vectors.is_empty() {
+            return Ok(());
+        }
+        let mut tx = self.pool.begin().await?;
+        for v in &vectors {
+            self.check_dimension(&format!("{} '{}'", field.as_str(), v.text), &v.vector)?;
+            sqlx::query(&format!(
+                "INSERT INTO {}_field_vectors (field, chunk_id, file_path, branch, text, embedding)
+                 VALUES ($1, $2, $3, $4, $5, $6::vector)",
+                self.namespace
+            ))
+            .bind(field.as_str())
+            .bind(&v.chunk_id)
+            .bind(&v.file_path)
+            .bind(&v.branch)
+            .bind(&v.text)
+            .bind(vector_literal(&v.vector))
+            .execute(&mut *tx)
+            .await
+            .with_context(|| format!("Failed to insert {} vectors", field.as_str()))?;
+        }
+        tx.commit().await?;
+        Ok(())
+    }
+
+    async fn delete_file(&self, path: &Path) -> Result<()> {
+        let path_str = path.to_string_lossy();
+        let predicate = format!("file_path = '{}'", sql_escape(&path_str));
+        let mut tx = self.pool.begin().await?;
+        self.delete_where(&mut tx, &predicate, &predicate)
+            .await
+            .with_context(|| format!("Failed to delete chunks for file: {}", path_str))?;
+        tx.commit().await?;
+
+        debug!("Deleted chunks for file: {}", path_str);
+        Ok(())
+    }
+
+    async fn delete_branch(&self, branch: &str) -> Result<()> {
+        let predicate = format!("branch = '{}'", sql_escape(branch));
+        let mut tx = self.pool.begin().await?;
+        self.delete_where(&mut tx, &predicate, &predicate)
+            .await
+            .with_context(|| format!("Failed to delete chunks for branch: {}", branch))?;
+        tx.commit().await?;
+
+        debug!("Deleted chunks for branch: {}", branch);
+        Ok(())
+    }
+
+    async fn query(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        let filter = filter.to_sql();
+
+        let mut tx = self.pool.begin().await?;
+        if filter.is_some() {
+            // HNSW returns `ef_search` candidates before the filter applies,
+            // so widen the search to still fill `limit`
+            sqlx::query(&format!(
+                "SET LOCAL hnsw.ef_search = {}",
+                (limit * 4).clamp(40, 1000)
+            ))
+            .execute(&mut *tx)
+            .await?;
+        }
+        let rows = sqlx::query(&format!(
+            "SELECT id, content, file_path, start_line, end_line, file_header, semantic_kind,
+                 embedding <-> $1::vector AS distance
+             FROM {}_chunks
+             WHERE duplicate_of IS NULL{}
+             ORDER BY embedding <-> $1::vector
+             LIMIT $2",
+            self.namespace,
+            filter.map(|f| format!(" AND ({})", f)).unwrap_or_default()
+        ))
+        .bind(vector_literal(&vector))
+        .bind(limit as i64)
+        .fetch_all(&mut *tx)
+        .await
+        .with_context(|| "Failed to execute vector search")?;
+        tx.commit().await?;
+
+        let mut ids = Vec::with_capacity(rows.len());
+        let mut results = Vec::with_capacity(rows.len());
+        for row in rows {
+            let distance: f64 = row.try_get("distance")?;
+            ids.push(row.try_get::<String, _>("id")?);
+            results.push(SearchResult {
+                content: row.try_get("content")?,
+                file_path: row.try_get("file_path")?,
+                start_line: row.try_get::<i32, _>("start_line")? as usize,
+                end_line: row.try_get::<i32, _>("end_line")? as usize,
+                score: (1.0 / (1.0 + distance * distance)) as f32,
+                file_header: row.try_get("file_header")?,
+                semantic_kind: row.try_get("semantic_kind")?,
+                sources: Vec::new(),
+                duplicates: Vec::new(),
+            });
+        }
+
+        let mut duplicates = self.duplicate_locations(&ids).await?;
+        for (result, id) in results.iter_mut().zip(&ids) {
+            result.duplicates = duplicates.remove(id).unwrap_or_default();
+        }
+        Ok(results)
+    }
+
+    async fn query_field(
+        &self,
+        field: VectorField,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        // Each chunk keeps its nearest vector of the field
+        let ns = &self.namespace;
+        let rows = sqlx::query(&format!(
+            "SELECT c.content, c.file_path, c.start_line, c.end_line, c.file_header,
+                 c.semantic_kind, d.distance
+             FROM (SELECT chunk_id, MIN(embedding <-> $1::vector) AS distance
+                   FROM {ns}_field_vectors WHERE field = $3 GROUP BY chunk_id) d
+             JOIN {ns}_chunks c ON c.id = d.chunk_id
+             WHERE c.duplicate_of IS NULL{}
+             ORDER BY d.distance
+             LIMIT $2",
+            filter
+                .to_sql()
+                .map(|f| format!(" AND ({})", f))
+                .unwrap_or_default()
+        ))
+        .bind(vector_literal(&vector))
+        .bind(limit as i64)
+        .bind(field.as_str())
+        .fetch_all(&self.pool)
+        .await
+        .with_context(|| format!("Failed to execute {} search", field.as_str()))?;
+
+        rows.iter()
+            .map(|row| {
+                let distance: f64 = row.try_get("distance")?;
+                Ok(SearchResult {
+                    content: row.try_get("content")?,
+                    file_path: row.try_get("file_path")?,
+                    start_line: row.try_get::<i32, _>("start_line")? as usize,
+                    end_line: row.try_get::<i32, _>("end_line")? as usize,
+                    score: (1.0 / (1.0 + distance * distance)) as f32,
+                    file_header: row.try_get("file_header")?,
+                    semantic_kind: row.try_get("semantic_kind")?,
+                    sources: Vec::new(),
+                    duplicates: Vec::new(),
+                })
+            })
+            .collect()
+    }
+
+    async fn has_field_vectors(&self, field: VectorField) -> Result<bool> {
+        sqlx::query_scalar(&format!(
+            "SELECT EXISTS (SELECT 1 FROM {}_field_vectors WHERE field = $1)",
+            self.namespace
+        ))
+        .bind(field.as_str())
+        .fetch_one(&self.pool)
+        .await
+        .with_context(|| format!("Failed to query {} vectors", field.as_str()))
+    }
+
+    async fn all_chunks(&self) -> Result<Vec<IndexedChunk>> {
+        let rows = sqlx::query(&format!(
+            "SELECT {METADATA_COLUMNS} FROM {}_chunks ORDER BY id",
+            self.namespace
+        ))
+        .fetch_all(&self.pool)
+        .await
+        .with_context(|| "Failed to query chunks")?;
+        rows.iter().map(chunk_from_row).collect()
+    }
+
+    async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>> {
+        let ns = &self.namespace;
+        let rows: Vec<(String, String)> = sqlx::query_as(&format!(
+            "SELECT c.id, h.embedding::text FROM {ns}_chunks c
+             JOIN {ns}_chunks h ON h.id = COALESCE(c.duplicate_of, c.id)
+             WHERE h.embedding IS NOT NULL"
+        ))
+        .fetch_all(&self.pool)
+        .await
+        .with_context(|| "Failed to query stored vectors")?;
+        rows.into_iter()
+            .map(|(id, embedding)| Ok((id, parse_vector(&embedding)?)))
+            .collect()
+    }
+
+    async fn list_files(&self, pattern: Option<&str>) -> Result<Vec<String>> {
+        let files: Vec<String> = sqlx::query_scalar(&format!(
+            "SELECT DISTINCT file_path FROM {}_chunks ORDER BY file_path",
+            self.namespace
+        ))
+        .fetch_all(&self.pool)
+        .await
+        .with_context(|| "Failed to query file paths")?;
+        matching_files(files, pattern)
+    }
+
+    async fn clear(&self) -> Result<()> {
+        let ns = &self.namespace;
+        let mut tx = self.pool.begin().await?;
+        for table in ["chunks", "field_vectors"] {
+            sqlx::query(&format!("DELETE FROM {ns}_{table}"))
+                .execute(&mut *tx)
+                .await
+                .with_context(|| "Failed to clear the index")?;
+        }
+        tx.commit().await?;
+
+        info!("Cleared all data from database");
+        Ok(())
+    }
+
+    async fn file_mtimes(&self) -> Result<HashMap<PathBuf, i64>> {
+        let rows: Vec<(String, i64)> = sqlx::query_as(&format!(
+            "SELECT file_path, MAX(mtime) FROM {}_chunks WHERE branch IS NULL
+             GROUP BY file_path",
+            self.namespace
+        ))
+        .fetch_all(&self.pool)
+        .await
+        .with_context(|| "Failed to query file mtimes")?;
+        Ok(rows
+            .into_iter()
+            .map(|(path, mtime)| (PathBuf::from(path), mtime))
+            .collect())
+    }
+
+    async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>> {
+        let rows: Vec<(String, Option<String>, String)> = sqlx::query_as(&format!(
+            "SELECT content, language, embedding::text FROM {}_chunks
+             WHERE embedding IS NOT NULL",
+            self.namespace
+        ))
+        .fetch_all(&self.pool)
+        .await
+        .with_context(|| "Failed to query stored vectors")?;
+
+        let mut vectors = HashMap::new();
+        for (content, language, embedding) in rows {
+            let hash = content_hash(&content, language.as_deref());
+            if let Entry::Vacant(entry) = vectors.entry(hash) {
+                entry.insert(parse_vector(&embedding)?);
+            }
+        }
+        Ok(vectors)
+    }
+
+    async fn stats(&self) -> Result<StoreStats> {
+        let (chunks, files): (i64, i64) = sqlx::query_as(&format!(
+            "SELECT COUNT(*), COUNT(DISTINCT file_path) FROM {}_chunks",
+            self.namespace
+        ))
+        .fetch_one(&self.pool)
+        .await
+        .with_context(|| "Failed to count chunks")?;
+        Ok(StoreStats {
+            backend: PGVECTOR_BACKEND,
+            chunks: chunks as usize,
+            files: files as usize,
+            vector_dimension: self.vector_dimension,
+        })
+    }
+
+    /// Copy the index into a single-file SQLite index at `dest`, which the
+    /// `sqlite` backend opens
+    async fn snapshot(&self, dest: &Path) -> Result<()> {
+        if dest.exists() {
+            bail!("Snapshot destination {} already exists", dest.display());
+        }
+        let snapshot = SqliteStore::open(dest, self.vector_dimension)?;
+        let ns = &self.namespace;
+
+        // Holders first, so each duplicate finds the chunk it shares with
+        let mut offset = 0;
+        loop {
+            let rows = sqlx::query(&format!(
+                "SELECT {CHUNK_COLUMNS} FROM {ns}_chunks
+                 ORDER BY duplicate_of IS NOT NULL, id LIMIT $1 OFFSET $2"
+            ))
+            .bind(SNAPSHOT_PAGE)
+            .bind(offset)
+            .fetch_all(&self.pool)
+            .await
+            .with_context(|| "Failed to read chunks for the snapshot")?;
+            let chunks = rows
+                .iter()
+                .map(chunk_from_row)
+                .collect::<Result<Vec<_>>>()?;
+            let done = (chunks.len() as i64) < SNAPSHOT_PAGE;
+            snapshot.upsert(chunks).await?;
+            if done {
+                break;
+            }
+            offset += SNAPSHOT_PAGE;
+        }
+
+        for field in VectorField::ALL {
+            let mut offset = 0;
+            loop {
+                let rows: Vec<(String, String, Option<String>, String, String)> =
+                    sqlx::query_as(&format!(
+                        "SELECT chunk_id, file_path, branch, text, embedding::text
+                         FROM {ns}_field_vectors WHERE field = $1
+                         ORDER BY chunk_id, text LIMIT $2 OFFSET $3"
+                    ))
+                    .bind(field.as_str())
+                    .bind(SNAPSHOT_PAGE)
+                    .bind(offset)
+                    .fetch_all(&self.pool)
+                    .await
+                    .with_context(|| {
+                        format!("Failed to read {} vectors for the snapshot", field.as_str())
+                    })?;
+                let done = (rows.len() as i64) < SNAPSHOT_PAGE;
+                let vectors = rows
+                    .into_iter()
+                    .map(|(chunk_id, file_path, branch, text, embedding)| {
+                        Ok(FieldVector {
+                            chunk_id,
+                            file_path,
+                            branch,
+                            text,
+                            vector: parse_vector(&embedding)?,
+                        })
+                    })
+                    .collect::<Result<Vec<_>>>()?;
+                snapshot.upsert_field_vectors(field, vectors).await?;
+                if done {
+                    break;
+                }
+                offset += SNAPSHOT_PAGE;
+            }
+        }
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_vector_literals_round_trip() {
+        let vector = vec![1.0, -0.5, 0.25];
+        assert_eq!(vector_literal(&vector), "[1,-0.5,0.25]");
+        assert_eq!(parse_vector(&vector_literal(&vector)).unwrap(), vector);
+        assert_eq!(parse_vector("[]").unwrap(), Vec::<f32>::new());
+        assert!(parse_vector("[1,x]").is_err());
+    }
+
+    #[test]
+    fn test_migrations_are_rendered_per_namespace() {
+        let statements: Vec<String> = MIGRATIONS
+            .iter()
+            .flat_map(|m| m.iter())
+            .map(|s| render(s, "coderag_index", 384))
+            .collect();
+        assert!(statements
+            .iter()
+            .all(|s| !s.contains("{ns}") && !s.contains("{dim}")));
+        assert!(statements[1].contains("CREATE TABLE coderag_index_chunks"));
+        assert!(statements[1].contains("embedding vector(384)"));
+        assert!(statements
+            .last()
+            .unwrap()
+            .contains("ON coderag_index_chunks USING hnsw"));
+    }
+}
@@ -0,0 +1,236 @@
+//! Qdrant's gRPC API, through `qdrant-client`
+
+use anyhow::{bail, Context, Result};
+use async_trait::async_trait;
+use qdrant_client::qdrant::point_id::PointIdOptions;
+use qdrant_client::qdrant::vectors::VectorsOptions;
+use qdrant_client::qdrant::{
+    vectors_config, Condition as QdrantCondition, CountPointsBuilder, CreateCollectionBuilder,
+    CreateFieldIndexCollectionBuilder, DeletePointsBuilder, Distance, FieldType,
+    Filter as QdrantFilter, PointId, PointStruct, PointsIdsList, ScrollPointsBuilder,
+    SearchPointsBuilder, SetPayloadPointsBuilder, UpsertPointsBuilder, Value as QdrantValue,
+    VectorParamsBuilder, Vectors,
+};
+use qdrant_client::{Payload, Qdrant};
+use serde_json::{Map, Value};
+use std::collections::HashMap;
+
+use super::{Condition, Filter, Point, ScoredPoint, Transport};
+
+pub(super) struct GrpcTransport {
+    client: Qdrant,
+}
+
+impl GrpcTransport {
+    pub(super) fn new(url: &str, api_key: Option<String>) -> Result<Self> {
+        let client = Qdrant::from_url(url)
+            .api_key(api_key)
+            .build()
+            .context("Failed to create Qdrant gRPC client")?;
+        Ok(Self { client })
+    }
+}
+
+impl Filter {
+    fn to_grpc(&self) -> QdrantFilter {
+        let conditions = |conditions: &[Condition]| -> Vec<QdrantCondition> {
+            conditions
+                .iter()
+                .map(|condition| match condition {
+                    Condition::Match { key, value } => {
+                        QdrantCondition::matches(*key, value.clone())
+                    }
+                    Condition::MatchAny { key, values } => {
+                        QdrantCondition::matches(*key, values.clone())
+                    }
+                    Condition::IsEmpty { key } => QdrantCondition::is_empty(*key),
+                })
+                .collect()
+        };
+        QdrantFilter {
+            must: conditions(&self.must),
+            must_not: conditions(&self.must_not),
+            ..Default::default()
+        }
+    }
+}
+
+fn id_string(id: Option<PointId>) -> String {
+    match id.and_then(|id| id.point_id_options) {
+        Some(PointIdOptions::Uuid(id)) => id,
+        Some(PointIdOptions::Num(id)) => id.to_string(),
+        None => String::new(),
+    }
+}
+
+fn payload(payload: HashMap<String, QdrantValue>) -> Map<String, Value> {
+    payload
+        .into_iter()
+        .map(|(key, value)| (key, value.into_json()))
+        .collect()
+}
+
+fn vector(vectors: Option<Vectors>) -> Vec<f32> {
+    match vectors.and_then(|v| v.vectors_options) {
+        Some(VectorsOptions::Vector(vector)) => vector.data,
+        _ => Vec::new(),
+    }
+}
+
+#[async_trait]
+impl Transport for GrpcTransport {
+    async fn collection_dimension(&self, collection: &str) -> Result<Option<usize>> {
+        if !self.client.collection_exists(collection).await? {
+            return Ok(None);
+        }
+        let info = self.client.collection_info(collection).await?;
+        let config = info
+            .result
+            .and_then(|info| info.config)
+            .and_then(|config| config.params)
+            .and_then(|params| params.vectors_config)
+            .and_then(|vectors| vectors.config);
+        match config {
+            Some(vectors_config::Config::Params(params)) => Ok(Some(params.size as usize)),
+            _ => bail!(
+                "Qdrant collection {} does not have a single vector",
+                collection
+            ),
+        }
+    }
+
+    async fn create_collection(
+        &self,
+        collection: &str,
+        dimension: usize,
+        indexes: &[&str],
+    ) -> Result<()> {
+        self.client
+            .create_collection(
+                CreateCollectionBuilder::new(collection)
+                    .vectors_config(VectorParamsBuilder::new(dimension as u64, Distance::Euclid)),
+            )
+            .await?;
+        for key in indexes {
+            self.client
+                .create_field_index(
+                    CreateFieldIndexCollectionBuilder::new(collection, *key, FieldType::Keyword)
+                        .wait(true),
+                )
+                .await?;
+        }
+        Ok(())
+    }
+
+    async fn upsert(&self, collection: &str, points: Vec<Point>) -> Result<()> {
+        let points = points
+            .into_iter()
+            .map(|p| {
+                let payload = Payload::try_from(Value::Object(p.payload))?;
+                Ok(PointStruct::new(p.id, p.vector, payload))
+            })
+            .collect::<Result<Vec<_>>>()?;
+        self.client
+            .upsert_points(UpsertPointsBuilder::new(collection, points).wait(true))
+            .await?;
+        Ok(())
+    }
+
+    async fn delete(&self, collection: &str, filter: &Filter) -> Result<()> {
+        self.client
+            .delete_points(
+                DeletePointsBuilder::new(collection)
+                    .points(filter.to_grpc())
+                    .wait(true),
+            )
+            .await?;
+        Ok(())
+    }
+
+    async fn search(
+        &self,
+        collection: &str,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &Filter,
+    ) -> Result<Vec<ScoredPoint>> {
+        let response = self
+            .client
+            .search_points(
+                SearchPointsBuilder::new(collection, vector, limit as u64)
+                    .filter(filter.to_grpc())
+                    .with_payload(true),
+            )
+            .await?;
+        Ok(response
+            .result
+            .into_iter()
+            .map(|hit| ScoredPoint {
+                distance: hit.score,
+                payload: payload(hit.payload),
+            })
+            .collect())
+    }
+
+    async fn scroll(
+        &self,
+        collection: &str,
+        filter: &Filter,
+        offset: Option<String>,
+        limit: usize,
+        with_vectors: bool,
+    ) -> Result<(Vec<Point>, Option<String>)> {
+        let mut request = ScrollPointsBuilder::new(collection)
+            .filter(filter.to_grpc())
+            .limit(limit as u32)
+            .with_payload(true)
+            .with_vectors(with_vectors);
+        if let Some(offset) = offset {
+            request = request.offset(PointId::from(offset));
+        }
+        let response = self.client.scroll(request).await?;
+        let points = response
+            .result
+            .into_iter()
+            .map(|point| Point {
+                id: id_string(point.id),
+                vector: vector(point.vectors),
+                payload: payload(point.payload),
+            })
+            .collect();
+        let next = response.next_page_offset.map(|id| id_string(Some(id)));
+        Ok((points, next))
+    }
+
+    async fn set_payload(
+        &self,
+        collection: &str,
+        ids: Vec<String>,
+        payload: Map<String, Value>,
+    ) -> Result<()> {
+        let payload = Payload::try_from(Value::Object(payload))?;
+        let ids = PointsIdsList {
+            ids: ids.into_iter().map(PointId::from).collect(),
+        };
+        self.client
+            .set_payload(
+                SetPayloadPointsBuilder::new(collection, payload)
+                    .points_selector(ids)
+                    .wait(true),
+            )
+            .await?;
+        Ok(())
+    }
+
+    async fn count(&self, collection: &str, filter: &Filter) -> Result<usize> {
+        let response = self
+            .client
+            .count(
+                CountPointsBuilder::new(collection)
+                    .filter(filter.to_grpc())
+                    .exact(true),
+            )
+            .await?;
+        Ok(response.result.map_or(0, |r| r.count as usize))
+    }
+}
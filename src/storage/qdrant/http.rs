@@ -0,0 +1,258 @@
+//! Qdrant's REST API
+
+use anyhow::{bail, Context, Result};
+use async_trait::async_trait;
+use serde_json::{json, Map, Value};
+use std::time::Duration;
+
+use super::{Filter, Point, ScoredPoint, Transport};
+
+/// Timeout of one request; scrolls and upserts of large pages take longest
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(60);
+
+pub(super) struct HttpTransport {
+    client: reqwest::Client,
+    url: String,
+    api_key: Option<String>,
+}
+
+impl HttpTransport {
+    pub(super) fn new(url: &str, api_key: Option<String>) -> Result<Self> {
+        let client = reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .context("Failed to create HTTP client")?;
+        Ok(Self {
+            client,
+            url: url.trim_end_matches('/').to_string(),
+            api_key,
+        })
+    }
+
+    /// Send `body` to `path` and return the response's `result`
+    async fn call(
+        &self,
+        method: reqwest::Method,
+        path: &str,
+        body: Option<Value>,
+    ) -> Result<Value> {
+        let url = format!("{}{}", self.url, path);
+        let mut request = self.client.request(method, &url);
+        if let Some(key) = &self.api_key {
+            request = request.header("api-key", key);
+        }
+        if let Some(body) = body {
+            request = request.json(&body);
+        }
+        let response = request
+            .send()
+            .await
+            .with_context(|| format!("Qdrant request to {} failed", url))?;
+        let status = response.status();
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            bail!("Qdrant returned {}: {}", status, body.trim());
+        }
+        let mut body: Value = response
+            .json()
+            .await
+            .context("Failed to parse Qdrant response")?;
+        Ok(body["result"].take())
+    }
+}
+
+/// A point id as a string; Qdrant ids are UUIDs or integers
+fn id_string(id: &Value) -> String {
+    match id {
+        Value::String(id) => id.clone(),
+        other => other.to_string(),
+    }
+}
+
+fn payload(value: &mut Value) -> Map<String, Value> {
+    match value["payload"].take() {
+        Value::Object(payload) => payload,
+        _ => Map::new(),
+    }
+}
+
+fn vector(value: &Value) -> Vec<f32> {
+    value["vector"]
+        .as_array()
+        .map(|v| {
+            v.iter()
+                .filter_map(Value::as_f64)
+                .map(|x| x as f32)
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+#[async_trait]
+impl Transport for HttpTransport {
+    async fn collection_dimension(&self, collection: &str) -> Result<Option<usize>> {
+        let exists = self
+            .call(
+                reqwest::Method::GET,
+                &format!("/collections/{}/exists", collection),
+                None,
+            )
+            .await?;
+        if !exists["exists"].as_bool().unwrap_or(false) {
+            return Ok(None);
+        }
+        let info = self
+            .call(
+                reqwest::Method::GET,
+                &format!("/collections/{}", collection),
+                None,
+            )
+            .await?;
+        Ok(info["config"]["params"]["vectors"]["size"]
+            .as_u64()
+            .map(|size| size as usize))
+    }
+
+    async fn create_collection(
+        &self,
+        collection: &str,
+        dimension: usize,
+        indexes: &[&str],
+    ) -> Result<()> {
+        self.call(
+            reqwest::Method::PUT,
+            &format!("/collections/{}", collection),
+            Some(json!({"vectors": {"size": dimension, "distance": "Euclid"}})),
+        )
+        .await?;
+        for key in indexes {
+            self.call(
+                reqwest::Method::PUT,
+                &format!("/collections/{}/index?wait=true", collection),
+                Some(json!({"field_name": key, "field_schema": "keyword"})),
+            )
+            .await?;
+        }
+        Ok(())
+    }
+
+    async fn upsert(&self, collection: &str, points: Vec<Point>) -> Result<()> {
+        let points: Vec<Value> = points
+            .into_iter()
+            .map(|p| json!({"id": p.id, "vector": p.vector, "payload": p.payload}))
+            .collect();
+        self.call(
+            reqwest::Method::PUT,
+            &format!("/collections/{}/points?wait=true", collection),
+            Some(json!({ "points": points })),
+        )
+        .await?;
+        Ok(())
+    }
+
+    async fn delete(&self, collection: &str, filter: &Filter) -> Result<()> {
+        self.call(
+            reqwest::Method::POST,
+            &format!("/collections/{}/points/delete?wait=true", collection),
+            Some(json!({"filter": filter.to_json()})),
+        )
+        .await?;
+        Ok(())
+    }
+
+    async fn search(
+        &self,
+        collection: &str,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &Filter,
+    ) -> Result<Vec<ScoredPoint>> {
+        let result = self
+            .call(
+                reqwest::Method::POST,
+                &format!("/collections/{}/points/search", collection),
+                Some(json!({
+                    "vector": vector,
+                    "limit": limit,
+                    "filter": filter.to_json(),
+                    "with_payload": true,
+                })),
+            )
+            .await?;
+        let Value::Array(hits) = result else {
+            bail!("Unexpected Qdrant search response");
+        };
+        Ok(hits
+            .into_iter()
+            .map(|mut hit| ScoredPoint {
+                distance: hit["score"].as_f64().unwrap_or(0.0) as f32,
+                payload: payload(&mut hit),
+            })
+            .collect())
+    }
+
+    async fn scroll(
+        &self,
+        collection: &str,
+        filter: &Filter,
+        offset: Option<String>,
+        limit: usize,
+        with_vectors: bool,
+    ) -> Result<(Vec<Point>, Option<String>)> {
+        let mut result = self
+            .call(
+                reqwest::Method::POST,
+                &format!("/collections/{}/points/scroll", collection),
+                Some(json!({
+                    "filter": filter.to_json(),
+                    "offset": offset,
+                    "limit": limit,
+                    "with_payload": true,
+                    "with_vector": with_vectors,
+                })),
+            )
+            .await?;
+        let next = match &result["next_page_offset"] {
+            Value::Null => None,
+            id => Some(id_string(id)),
+        };
+        let Value::Array(points) = result["points"].take() else {
+            bail!("Unexpected Qdrant scroll response");
+        };
+        let points = points
+            .into_iter()
+            .map(|mut point| Point {
+                id: id_string(&point["id"]),
+                vector: vector(&point),
+                payload: payload(&mut point),
+            })
+            .collect();
+        Ok((points, next))
+    }
+
+    async fn set_payload(
+        &self,
+        collection: &str,
+        ids: Vec<String>,
+        payload: Map<String, Value>,
+    ) -> Result<()> {
+        self.call(
+            reqwest::Method::POST,
+            &format!("/collections/{}/points/payload?wait=true", collection),
+            Some(json!({"payload": payload, "points": ids})),
+        )
+        .await?;
+        Ok(())
+    }
+
+    async fn count(&self, collection: &str, filter: &Filter) -> Result<usize> {
+        let result = self
+            .call(
+                reqwest::Method::POST,
+                &format!("/collections/{}/points/count", collection),
+                Some(json!({"filter": filter.to_json(), "exact": true})),
+            )
+            .await?;
+        Ok(result["count"].as_u64().unwrap_or(0) as usize)
+    }
+}
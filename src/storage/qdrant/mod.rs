@@ -0,0 +1,960 @@
+//! Qdrant vector store
+//!
+//! Chunks are points of a Qdrant collection: the chunk vector, with every
+//! metadata field in the payload. Field vectors live in a second collection.
+//! Both are created on first use with Euclidean distance, and keyword
+//! payload indexes on the fields that [`SearchFilter`]s translate to.
+//!
+//! The server is reached over HTTP for `http(s)://` URLs and over gRPC for
+//! `grpc(s)://` ones. Behaviour matches the LanceDB
+//! [`Storage`](super::Storage): identical chunks on one branch are searched
+//! once, and scores are `1 / (1 + d)` of the squared L2 distance `d`.
+
+mod grpc;
+mod http;
+
+use anyhow::{bail, Result};
+use async_trait::async_trait;
+use serde_json::{json, Map, Value};
+use std::collections::{HashMap, HashSet};
+use std::path::{Path, PathBuf};
+use tracing::{debug, info};
+use uuid::Uuid;
+
+use super::lancedb::{
+    content_hash, stored_hash, FieldVector, IndexedChunk, SearchFilter, SearchResult, VectorField,
+};
+use super::sqlite::SqliteStore;
+use super::store::{
+    chunk_result, content_hashes, field_search, group_duplicates, latest_mtimes, mark_duplicates,
+    promote_duplicates, HolderKey, StoreStats, VectorStore, FIELD_HITS_PER_RESULT, QDRANT_BACKEND,
+};
+
+/// Namespace of the UUIDv5 point ids, which Qdrant requires instead of
+/// chunk ids
+const POINT_ID_NAMESPACE: Uuid = Uuid::from_u128(0x3f0e_8d1c_7b2a_4e5f_a6b7_c8d9_e0f1_a2b3);
+
+/// Points read per scroll request
+const SCROLL_PAGE: usize = 1000;
+
+/// Chunk payload keys with a keyword index, for filters
+const CHUNK_INDEXES: &[&str] = &[
+    "id",
+    "file_path",
+    "branch",
+    "semantic_kind",
+    "tags",
+    "tag_keys",
+    "content_hash",
+    "duplicate_of",
+];
+
+/// Field vector payload keys with a keyword index
+const FIELD_INDEXES: &[&str] = &["field", "chunk_id", "file_path", "branch"];
+
+/// One condition on a payload key
+#[derive(Debug, Clone, PartialEq)]
+enum Condition {
+    /// The value, or an item of the array, equals `value`
+    Match { key: &'static str, value: String },
+    /// The value, or an item of the array, is one of `values`
+    MatchAny {
+        key: &'static str,
+        values: Vec<String>,
+    },
+    /// The key is missing, null or an empty array
+    IsEmpty { key: &'static str },
+}
+
+/// Points matching all of `must` and none of `must_not`
+#[derive(Debug, Clone, Default, PartialEq)]
+struct Filter {
+    must: Vec<Condition>,
+    must_not: Vec<Condition>,
+}
+
+impl Filter {
+    fn must(must: Vec<Condition>) -> Self {
+        Self {
+            must,
+            must_not: Vec::new(),
+        }
+    }
+
+    /// The filter as Qdrant's JSON filter expression
+    fn to_json(&self) -> Value {
+        let conditions = |conditions: &[Condition]| -> Vec<Value> {
+            conditions
+                .iter()
+                .map(|condition| match condition {
+                    Condition::Match { key, value } => {
+                        json!({"key": key, "match": {"value": value}})
+                    }
+                    Condition::MatchAny { key, values } => {
+                        json!({"key": key, "match": {"any": values}})
+                    }
+                    Condition::IsEmpty { key } => json!({"is_empty": {"key": key}}),
+                })
+                .collect()
+        };
+        json!({"must": conditions(&self.must), "must_not": conditions(&self.must_not)})
+    }
+}
+
+/// Conditions of a search filter; header metadata tags ending in `=` match
+/// any value of their key through the `tag_keys` payload
+fn search_conditions(filter: &SearchFilter) -> Vec<Condition> {
+    let mut conditions = vec![Condition::IsEmpty {
+        key: "duplicate_of",
+    }];
+    if let Some(kind) = &filter.kind {
+        conditions.push(Condition::Match {
+            key: "semantic_kind",
+            value: kind.clone(),
+        });
+    }
+    if let Some(tag) = &filter.tag {
+        conditions.push(Condition::Match {
+            key: "tags",
+            value: tag.clone(),
+        });
+    }
+    for tag in &filter.meta {
+        conditions.push(Condition::Match {
+            key: if tag.ends_with('=') {
+                "tag_keys"
+            } else {
+                "tags"
+            },
+            value: tag.clone(),
+        });
+    }
+    if let Some(branch) = &filter.branch {
+        conditions.push(Condition::Match {
+            key: "branch",
+            value: branch.clone(),
+        });
+    }
+    conditions
+}
+
+/// A point as stored: id, vector and payload
+#[derive(Debug, Clone, PartialEq)]
+struct Point {
+    id: String,
+    vector: Vec<f32>,
+    payload: Map<String, Value>,
+}
+
+/// A search hit: payload and Qdrant's score, the L2 distance
+#[derive(Debug, Clone, PartialEq)]
+struct ScoredPoint {
+    payload: Map<String, Value>,
+    distance: f32,
+}
+
+/// The Qdrant operations the store needs, over one wire protocol
+#[async_trait]
+trait Transport: Send + Sync {
+    /// Dimension of `collection`'s vectors, or None when it does not exist
+    async fn collection_dimension(&self, collection: &str) -> Result<Option<usize>>;
+
+    /// Create `collection` for Euclidean vectors of `dimension`, with
+    /// keyword indexes on `indexes`
+    async fn create_collection(
+        &self,
+        collection: &str,
+        dimension: usize,
+        indexes: &[&str],
+    ) -> Result<()>;
+
+    async fn upsert(&self, collection: &str, points: Vec<Point>) -> Result<()>;
+
+    async fn delete(&self, collection: &str, filter: &Filter) -> Result<()>;
+
+    /// The `limit` points nearest `vector` that match `filter`, nearest first
+    async fn search(
+        &self,
+        collection: &str,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &Filter,
+    ) -> Result<Vec<ScoredPoint>>;
+
+    /// A page of points matching `filter` from `offset`, with the offset of
+    /// the next page; vectors are left empty unless `with_vectors`
+    async fn scroll(
+        &self,
+        collection: &str,
+        filter: &Filter,
+        offset: Option<String>,
+        limit: usize,
+        with_vectors: bool,
+    ) -> Result<(Vec<Point>, Option<String>)>;
+
+    /// Set `payload` keys on the points `ids`
+    async fn set_payload(
+        &self,
+        collection: &str,
+        ids: Vec<String>,
+        payload: Map<String, Value>,
+    ) -> Result<()>;
+
+    async fn count(&self, collection: &str, filter: &Filter) -> Result<usize>;
+}
+
+/// Id of the point of a chunk
+fn point_id(chunk_id: &str) -> String {
+    Uuid::new_v5(&POINT_ID_NAMESPACE, chunk_id.as_bytes()).to_string()
+}
+
+/// Id of the point of a field vector
+fn field_point_id(field: VectorField, chunk_id: &str, text: &str) -> String {
+    let key = format!("{}\0{}\0{}", field.as_str(), chunk_id, text);
+    Uuid::new_v5(&POINT_ID_NAMESPACE, key.as_bytes()).to_string()
+}
+
+fn chunk_payload(chunk: &IndexedChunk) -> Map<String, Value> {
+    // `key=` of each `key=value` tag, for filters on any value of a key
+    let tag_keys: Vec<&str> = chunk
+        .tags
+        .iter()
+        .filter_map(|tag| tag.find('=').map(|i| &tag[..=i]))
+        .collect();
+    let payload = json!({
+        "id": chunk.id,
+        "content": chunk.content,
+        "file_path": chunk.file_path,
+        "start_line": chunk.start_line,
+        "end_line": chunk.end_line,
+        "language": chunk.language,
+        "mtime": chunk.mtime,
+        "file_header": chunk.file_header,
+        "semantic_kind": chunk.semantic_kind,
+        "symbol_name": chunk.symbol_name,
+        "signature": chunk.signature,
+        "doc": chunk.doc,
+        "parent": chunk.parent,
+        "visibility": chunk.visibility,
+        "qualified_name": chunk.qualified_name,
+        "tags": chunk.tags,
+        "tag_keys": tag_keys,
+        "branch": chunk.branch,
+        "content_hash": stored_hash(content_hash(&chunk.content, chunk.language.as_deref())),
+        "duplicate_of": chunk.duplicate_of,
+    });
+    match payload {
+        Value::Object(map) => map,
+        _ => unreachable!(),
+    }
+}
+
+fn payload_str(payload: &Map<String, Value>, key: &str) -> Option<String> {
+    payload.get(key).and_then(Value::as_str).map(str::to_string)
+}
+
+fn payload_usize(payload: &Map<String, Value>, key: &str) -> usize {
+    payload.get(key).and_then(Value::as_u64).unwrap_or(0) as usize
+}
+
+fn chunk_from_point(point: Point) -> IndexedChunk {
+    let payload = &point.payload;
+    IndexedChunk {
+        id: payload_str(payload, "id").unwrap_or_default(),
+        content: payload_str(payload, "content").unwrap_or_default(),
+        file_path: payload_str(payload, "file_path").unwrap_or_default(),
+        start_line: payload_usize(payload, "start_line"),
+        end_line: payload_usize(payload, "end_line"),
+        language: payload_str(payload, "language"),
+        mtime: payload.get("mtime").and_then(Value::as_i64).unwrap_or(0),
+        file_header: payload_str(payload, "file_header"),
+        semantic_kind: payload_str(payload, "semantic_kind"),
+        symbol_name: payload_str(payload, "symbol_name"),
+        signature: payload_str(payload, "signature"),
+        doc: payload_str(payload, "doc"),
+        parent: payload_str(payload, "parent"),
+        visibility: payload_str(payload, "visibility"),
+        qualified_name: payload_str(payload, "qualified_name"),
+        tags: payload
+            .get("tags")
+            .and_then(Value::as_array)
+            .map(|tags| {
+                tags.iter()
+                    .filter_map(Value::as_str)
+                    .map(str::to_string)
+                    .collect()
+            })
+            .unwrap_or_default(),
+        branch: payload_str(payload, "branch"),
+        duplicate_of: payload_str(payload, "duplicate_of"),
+        vector: point.vector,
+    }
+}
+
+/// Qdrant storage backend
+pub struct QdrantStore {
+    transport: Box<dyn Transport>,
+    /// Name of the chunks collection; field vectors are in
+    /// `<collection>_fields`
+    collection: String,
+    fields_collection: String,
+    vector_dimension: usize,
+}
+
+impl QdrantStore {
+    /// Connect to the Qdrant server at `url` and open the collections of
+    /// `collection`, creating them when missing
+    ///
+    /// # Errors
+    ///
+    /// Returns an error if `vector_dimension` is 0, the URL scheme is not
+    /// http(s) or grpc(s), the server cannot be reached, or the collection
+    /// holds vectors of another dimension.
+    pub async fn connect(
+        url: &str,
+        api_key: Option<String>,
+        collection: &str,
+        vector_dimension: usize,
+    ) -> Result<Self> {
+        if vector_dimension == 0 {
+            bail!("Vector dimension must be greater than 0");
+        }
+        Self::open(Self::transport(url, api_key)?, collection, vector_dimension).await
+    }
+
+    /// Connect to the Qdrant server at `url` and open the collections of
+    /// `collection` with the vector dimension they were created with
+    ///
+    /// Returns None when the chunks collection does not exist yet.
+    pub async fn connect_existing(
+        url: &str,
+        api_key: Option<String>,
+        collection: &str,
+    ) -> Result<Option<Self>> {
+        let transport = Self::transport(url, api_key)?;
+        match transport.collection_dimension(collection).await? {
+            Some(dimension) => Ok(Some(Self::open(transport, collection, dimension).await?)),
+            None => Ok(None),
+        }
+    }
+
+    /// Transport for the scheme of `url`
+    fn transport(url: &str, api_key: Option<String>) -> Result<Box<dyn Transport>> {
+        let transport: Box<dyn Transport> = if let Some(rest) = url.strip_prefix("grpc://") {
+            Box::new(grpc::GrpcTransport::new(
+                &format!("http://{}", rest),
+                api_key,
+            )?)
+        } else if let Some(rest) = url.strip_prefix("grpcs://") {
+            Box::new(grpc::GrpcTransport::new(
+                &format!("https://{}", rest),
+                api_key,
+            )?)
+        } else if url.starts_with("http://") || url.starts_with("https://") {
+            Box::new(http::HttpTransport::new(url, api_key)?)
+        } else {
+            bail!(
+                "Qdrant URL must start with http://, https://, grpc:// or grpcs://: {}",
+                url
+            );
+        };
+        Ok(transport)
+    }
+
+    async fn open(
+        transport: Box<dyn Transport>,
+        collection: &str,
+        vector_dimension: usize,
+    ) -> Result<Self> {
+        let store = Self {
+            transport,
+            collection: collection.to_string(),
+            fields_collection: format!("{}_fields", collection),
+            vector_dimension,
+        };
+        store
+            .ensure_collection(&store.collection, CHUNK_INDEXES)
+            .await?;
+        store
+            .ensure_collection(&store.fields_collection, FIELD_INDEXES)
+            .await?;
+        debug!("Opened Qdrant collection {}", collection);
+        Ok(store)
+    }
+
+    /// Create `collection` when missing, or check its dimension
+    async fn ensure_collection(&self, collection: &str, indexes: &[&str]) -> Result<()> {
+        match self.transport.collection_dimension(collection).await? {
+            Some(dimension) if dimension != self.vector_dimension => bail!(
+                "Vector dimension mismatch: storage configured for {} dimensions, \
+                 but existing collection {} has {} dimensions. \
+                 Delete the existing index or use matching embedding model.",
+                self.vector_dimension,
+                collection,
+                dimension
+            ),
+            Some(_) => Ok(()),
+            None => {
+                info!("Creating Qdrant collection {}", collection);
+                self.transport
+                    .create_collection(collection, self.vector_dimension, indexes)
+                    .await
+            }
+        }
+    }
+
+    /// Check that `vector` has the index's dimension
+    fn check_dimension(&self, what: &str, vector: &[f32]) -> Result<()> {
+        if vector.len() != self.vector_dimension {
+            bail!(
+                "Vector dimension mismatch for {}: expected {} dimensions, got {}",
+                what,
+                self.vector_dimension,
+                vector.len()
+            );
+        }
+        Ok(())
+    }
+
+    /// Every point of `collection` matching `filter`
+    async fn scroll_all(
+        &self,
+        collection: &str,
+        filter: &Filter,
+        with_vectors: bool,
+    ) -> Result<Vec<Point>> {
+        let mut points = Vec::new();
+        let mut offset = None;
+        loop {
+            let (page, next) = self
+                .transport
+                .scroll(collection, filter, offset, SCROLL_PAGE, with_vectors)
+                .await?;
+            points.extend(page);
+            match next {
+                Some(next) => offset = Some(next),
+                None => return Ok(points),
+            }
+        }
+    }
+
+    /// Delete the chunks matching `condition`, and the field vectors
+    /// matching `field_condition`, promoting the duplicates left behind
+    /// (see [`promote_duplicates`])
+    async fn delete_where(&self, condition: Condition, field_condition: Condition) -> Result<()> {
+        let holders: Vec<String> = self
+            .scroll_all(
+                &self.collection,
+                &Filter::must(vec![
+                    condition.clone(),
+                    Condition::IsEmpty {
+                        key: "duplicate_of",
+                    },
+                ]),
+                false,
+            )
+            .await?
+            .into_iter()
+            .filter_map(|p| payload_str(&p.payload, "id"))
+            .collect();
+        if !holders.is_empty() {
+            let duplicates = self
+                .scroll_all(
+                    &self.collection,
+                    &Filter {
+                        must: vec![Condition::MatchAny {
+                            key: "duplicate_of",
+                            values: holders,
+                        }],
+                        must_not: vec![condition.clone()],
+                    },
+                    false,
+                )
+                .await?;
+
+            // Points of the promoted duplicates by their new `duplicate_of`
+            let mut updates: HashMap<Option<String>, Vec<String>> = HashMap::new();
+            for chunk in promote_duplicates(duplicates.into_iter().map(chunk_from_point).collect())
+            {
+                updates
+                    .entry(chunk.duplicate_of)
+                    .or_default()
+                    .push(point_id(&chunk.id));
+            }
+            for (holder, points) in updates {
+                let holder = holder.map_or(Value::Null, Value::String);
+                self.transport
+                    .set_payload(
+                        &self.collection,
+                        points,
+                        Map::from_iter([("duplicate_of".to_string(), holder)]),
+                    )
+                    .await?;
+            }
+        }
+
+        self.transport
+            .delete(&self.collection, &Filter::must(vec![condition]))
+            .await?;
+        self.transport
+            .delete(
+                &self.fields_collection,
+                &Filter::must(vec![field_condition]),
+            )
+            .await
+    }
+}
+
+#[async_trait]
+impl VectorStore for QdrantStore {
+    fn backend(&self) -> &'static str {
+        QDRANT_BACKEND
+    }
+
+    fn vector_dimension(&self) -> usize {
+        self.vector_dimension
+    }
+
+    async fn upsert(&self, mut chunks: Vec<IndexedChunk>) -> Result<()> {
+        if chunks.is_empty() {
+            return Ok(());
+        }
+        let ids: Vec<String> = chunks.iter().map(|c| c.id.clone()).collect();
+        self.delete_where(
+            Condition::MatchAny {
+                key: "id",
+                values: ids.clone(),
+            },
+            Condition::MatchAny {
+                key: "chunk_id",
+                values: ids,
+            },
+        )
+        .await?;
+
+        let stored = self
+            .scroll_all(
+                &self.collection,
+                &Filter::must(vec![
+                    Condition::MatchAny {
+                        key: "content_hash",
+                        values: content_hashes(&chunks),
+                    },
+                    Condition::IsEmpty {
+                        key: "duplicate_of",
+                    },
+                ]),
+                false,
+            )
+            .await?;
+        let holders: HashMap<HolderKey, String> = stored
+            .into_iter()
+            .filter_map(|p| {
+                let hash = payload_str(&p.payload, "content_hash")?;
+                let id = payload_str(&p.payload, "id")?;
+                Some(((hash, payload_str(&p.payload, "branch")), id))
+            })
+            .collect();
+        mark_duplicates(&mut chunks, holders);
+
+        let mut points = Vec::with_capacity(chunks.len());
+        for chunk in &mut chunks {
+            self.check_dimension(&format!("chunk '{}'", chunk.id), &chunk.vector)?;
+            points.push(Point {
+                id: point_id(&chunk.id),
+                vector: std::mem::take(&mut chunk.vector),
+                payload: chunk_payload(chunk),
+            });
+        }
+        let count = points.len();
+        self.transport.upsert(&self.collection, points).await?;
+
+        info!("Inserted {} chunks into database", count);
+        Ok(())
+    }
+
+    async fn upsert_field_vectors(
+        &self,
+        field: VectorField,
+        vectors: Vec<FieldVector>,
+    ) -> Result<()> {
+        if vectors.is_empty() {
+            return Ok(());
+        }
+        let mut points = Vec::with_capacity(vectors.len());
+        for v in vectors {
+            self.check_dimension(&format!("{} '{}'", field.as_str(), v.text), &v.vector)?;
+            let payload = json!({
+                "field": field.as_str(),
+                "chunk_id": v.chunk_id,
+                "file_path": v.file_path,
+                "branch": v.branch,
+                "text": v.text,
+            });
+            let Value::Object(payload) = payload else {
+                unreachable!()
+            };
+            points.push(Point {
+                id: field_point_id(field, &v.chunk_id, &v.text),
+                vector: v.vector,
+                payload,
+            });
+        }
+        self.transport.upsert(&self.fields_collection, points).await
+    }
+
+    async fn delete_file(&self, path: &Path) -> Result<()> {
+        let path_str = path.to_string_lossy().to_string();
+        let condition = Condition::Match {
+            key: "file_path",
+            value: path_str.clone(),
+        };
+        self.delete_where(condition.clone(), condition).await?;
+
+        debug!("Deleted chunks for file: {}", path_str);
+        Ok(())
+    }
+
+    async fn delete_branch(&self, branch: &str) -> Result<()> {
+        let condition = Condition::Match {
+            key: "branch",
+            value: branch.to_string(),
+        };
+        self.delete_where(condition.clone(), condition).await?;
+
+        debug!("Deleted chunks for branch: {}", branch);
+        Ok(())
+    }
+
+    async fn query(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        let hits = self
+            .transport
+            .search(
+                &self.collection,
+                vector,
+                limit,
+                &Filter::must(search_conditions(filter)),
+            )
+            .await?;
+
+        let ids: Vec<String> = hits
+            .iter()
+            .filter_map(|hit| payload_str(&hit.payload, "id"))
+            .collect();
+        let mut duplicates = HashMap::new();
+        if !ids.is_empty() {
+            let points = self
+                .scroll_all(
+                    &self.collection,
+                    &Filter::must(vec![Condition::MatchAny {
+                        key: "duplicate_of",
+                        values: ids,
+                    }]),
+                    false,
+                )
+                .await?;
+            duplicates = group_duplicates(points.into_iter().map(chunk_from_point).filter_map(
+                |chunk| {
+                    Some((
+                        chunk.duplicate_of?,
+                        chunk.file_path,
+                        chunk.start_line,
+                        chunk.end_line,
+                    ))
+                },
+            ));
+        }
+
+        Ok(hits
+            .into_iter()
+            .map(|hit| {
+                let distance = hit.distance;
+                let chunk = chunk_from_point(Point {
+                    id: String::new(),
+                    vector: Vec::new(),
+                    payload: hit.payload,
+                });
+                let locations = duplicates.remove(&chunk.id).unwrap_or_default();
+                chunk_result(chunk, 1.0 / (1.0 + distance * distance), locations)
+            })
+            .collect())
+    }
+
+    async fn query_field(
+        &self,
+        field: VectorField,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        let mut conditions = vec![Condition::Match {
+            key: "field",
+            value: field.as_str().to_string(),
+        }];
+        if let Some(branch) = &filter.branch {
+            conditions.push(Condition::Match {
+                key: "branch",
+                value: branch.clone(),
+            });
+        }
+        let hits = self
+            .transport
+            .search(
+                &self.fields_collection,
+                vector,
+                limit * FIELD_HITS_PER_RESULT,
+                &Filter::must(conditions),
+            )
+            .await?;
+        let hits = hits.into_iter().filter_map(|hit| {
+            let id = payload_str(&hit.payload, "chunk_id")?;
+            Some((id, 1.0 / (1.0 + hit.distance * hit.distance)))
+        });
+        field_search(hits, limit, |ids| async move {
+            let mut conditions = search_conditions(filter);
+            conditions.push(Condition::MatchAny {
+                key: "id",
+                values: ids,
+            });
+            Ok(self
+                .scroll_all(&self.collection, &Filter::must(conditions), false)
+                .await?
+                .into_iter()
+                .map(chunk_from_point)
+                .collect())
+        })
+        .await
+    }
+
+    async fn has_field_vectors(&self, field: VectorField) -> Result<bool> {
+        let filter = Filter::must(vec![Condition::Match {
+            key: "field",
+            value: field.as_str().to_string(),
+        }]);
+        Ok(self
+            .transport
+            .count(&self.fields_collection, &filter)
+            .await?
+            > 0)
+    }
+
+    async fn all_chunks(&self) -> Result<Vec<IndexedChunk>> {
+        Ok(self
+            .scroll_all(&self.collection, &Filter::default(), false)
+            .await?
+            .into_iter()
+            .map(chunk_from_point)
+            .collect())
+    }
+
+    async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>> {
+        // Duplicates keep their own vector, equal to their holder's
+        Ok(self
+            .scroll_all(&self.collection, &Filter::default(), true)
+            .await?
+            .into_iter()
+            .map(chunk_from_point)
+            .map(|chunk| (chunk.id, chunk.vector))
+            .collect())
+    }
+
+    async fn file_mtimes(&self) -> Result<HashMap<PathBuf, i64>> {
+        let points = self
+            .scroll_all(&self.collection, &Filter::default(), false)
+            .await?;
+        Ok(latest_mtimes(points.into_iter().map(chunk_from_point)))
+    }
+
+    async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>> {
+        let points = self
+            .scroll_all(
+                &self.collection,
+                &Filter::must(vec![Condition::IsEmpty {
+                    key: "duplicate_of",
+                }]),
+                true,
+            )
+            .await?;
+        let mut vectors = HashMap::new();
+        for point in points {
+            let chunk = chunk_from_point(point);
+            vectors
+                .entry(content_hash(&chunk.content, chunk.language.as_deref()))
+                .or_insert(chunk.vector);
+        }
+        Ok(vectors)
+    }
+
+    async fn stats(&self) -> Result<StoreStats> {
+        let chunks = self
+            .transport
+            .count(&self.collection, &Filter::default())
+            .await?;
+        let files: HashSet<String> = self
+            .scroll_all(&self.collection, &Filter::default(), false)
+            .await?
+            .into_iter()
+            .filter_map(|p| payload_str(&p.payload, "file_path"))
+            .collect();
+        Ok(StoreStats {
+            backend: QDRANT_BACKEND,
+            chunks,
+            files: files.len(),
+            vector_dimension: self.vector_dimension,
+        })
+    }
+
+    /// Copy the collections into a single-file SQLite index at `dest`,
+    /// which the `sqlite` backend opens
+    async fn snapshot(&self, dest: &Path) -> Result<()> {
+        if dest.exists() {
+            bail!("Snapshot destination {} already exists", dest.display());
+        }
+        let snapshot = SqliteStore::open(dest, self.vector_dimension)?;
+
+        // Holders first, so each duplicate finds the chunk it shares with
+        let holders = Filter::must(vec![Condition::IsEmpty {
+            key: "duplicate_of",
+        }]);
+        let duplicates = Filter {
+            must: Vec::new(),
+            must_not: vec![Condition::IsEmpty {
+                key: "duplicate_of",
+            }],
+        };
+        for filter in [holders, duplicates] {
+            let mut offset = None;
+            loop {
+                let (page, next) = self
+                    .transport
+                    .scroll(&self.collection, &filter, offset, SCROLL_PAGE, true)
+                    .await?;
+                snapshot
+                    .upsert(page.into_iter().map(chunk_from_point).collect())
+                    .await?;
+                match next {
+                    Some(next) => offset = Some(next),
+                    None => break,
+                }
+            }
+        }
+
+        for field in VectorField::ALL {
+            let filter = Filter::must(vec![Condition::Match {
+                key: "field",
+                value: field.as_str().to_string(),
+            }]);
+            let mut offset = None;
+            loop {
+                let (page, next) = self
+                    .transport
+                    .scroll(&self.fields_collection, &filter, offset, SCROLL_PAGE, true)
+                    .await?;
+                let vectors = page
+                    .into_iter()
+                    .map(|point| FieldVector {
+                        chunk_id: payload_str(&point.payload, "chunk_id").unwrap_or_default(),
+                        file_path: payload_str(&point.payload, "file_path").unwrap_or_default(),
+                        branch: payload_str(&point.payload, "branch"),
+                        text: payload_str(&point.payload, "text").unwrap_or_default(),
+                        vector: point.vector,
+                    })
+                    .collect();
+                snapshot.upsert_field_vectors(field, vectors).await?;
+                match next {
+                    Some(next) => offset = Some(next),
+                    None => break,
+                }
+            }
+        }
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_search_filters_translate_to_qdrant_filters() {
+        let filter = SearchFilter {
+            kind: Some("function".to_string()),
+            tag: Some("concurrency".to_string()),
+            meta: vec!["owner=".to_string(), "team=search".to_string()],
+            branch: Some("main".to_string()),
+        };
+        assert_eq!(
+            Filter::must(search_conditions(&filter)).to_json(),
+            json!({
+                "must": [
+                    {"is_empty": {"key": "duplicate_of"}},
+                    {"key": "semantic_kind", "match": {"value": "function"}},
+                    {"key": "tags", "match": {"value": "concurrency"}},
+                    {"key": "tag_keys", "match": {"value": "owner="}},
+                    {"key": "tags", "match": {"value": "team=search"}},
+                    {"key": "branch", "match": {"value": "main"}},
+                ],
+                "must_not": [],
+            })
+        );
+    }
+
+    #[test]
+    fn test_chunks_round_trip_through_payloads() {
+        let chunk = IndexedChunk {
+            id: "a".to_string(),
+            content: "fn a() {}".to_string(),
+            file_path: "src/a.rs".to_string(),
+            start_line: 1,
+            end_line: 3,
+            language: Some("rust".to_string()),
+            vector: vec![1.0, 0.0],
+            mtime: 10,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some("a".to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: vec!["owner=search".to_string(), "concurrency".to_string()],
+            branch: Some("main".to_string()),
+            duplicate_of: None,
+        };
+        let payload = chunk_payload(&chunk);
+        assert_eq!(payload["tag_keys"], json!(["owner="]));
+
+        let point = Point {
+            id: point_id(&chunk.id),
+            vector: chunk.vector.clone(),
+            payload,
+        };
+        let back = chunk_from_point(point);
+        assert_eq!(back.id, chunk.id);
+        assert_eq!(back.tags, chunk.tags);
+        assert_eq!(back.branch, chunk.branch);
+        assert_eq!(back.vector, chunk.vector);
+        assert_eq!((back.start_line, back.end_line, back.mtime), (1, 3, 10));
+    }
+
+    #[test]
+    fn test_point_ids_are_stable_uuids() {
+        assert_eq!(point_id("a"), point_id("a"));
+        assert_ne!(point_id("a"), point_id("b"));
+        assert!(Uuid::parse_str(&point_id("a")).is_ok());
+    }
+}
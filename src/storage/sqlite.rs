@@ -0,0 +1,944 @@
+//! SQLite vector store
+//!
+//! The whole index is one SQLite file: chunk metadata in ordinary tables,
+//! chunk vectors in a [sqlite-vec](https://github.com/asg017/sqlite-vec)
+//! `vec0` table sharing the chunks' rowids. SQLite is compiled in, so the
+//! index needs nothing installed and is easy to keep in a CI cache.
+//!
+//! Behaviour matches the LanceDB [`Storage`](super::Storage): identical
+//! chunks on one branch share a vector, and scores are `1 / (1 + d)` of the
+//! squared L2 distance `d`.
+
+use anyhow::{bail, Context, Result};
+use async_trait::async_trait;
+use rusqlite::{params, Connection, OptionalExtension, Transaction};
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, Mutex, Once};
+use tracing::{debug, info};
+
+use super::lancedb::{
+    content_hash, sql_escape, sql_list, FieldVector, IndexedChunk, SearchFilter, SearchResult,
+    VectorField,
+};
+use super::store::{
+    content_hashes, group_duplicates, holder_key, mark_duplicates, matching_files, HolderKey,
+    StoreStats, VectorStore, SQLITE_BACKEND,
+};
+
+/// Columns of a chunk read into a [`SearchResult`], in order
+const RESULT_COLUMNS: &str =
+    "c.id, c.content, c.file_path, c.start_line, c.end_line, c.file_header, c.semantic_kind";
+
+/// Columns of a chunk read into an [`IndexedChunk`], in order
+const CHUNK_COLUMNS: &str = "id, content, file_path, start_line, end_line, language, mtime, \
+    file_header, semantic_kind, symbol_name, signature, parent, visibility, qualified_name, \
+    tags, branch, doc, duplicate_of";
+
+/// SQLite storage backend, with sqlite-vec for vectors
+pub struct SqliteStore {
+    conn: Arc<Mutex<Connection>>,
+    vector_dimension: usize,
+}
+
+/// Register sqlite-vec with every connection opened afterwards
+fn load_sqlite_vec() {
+    static LOAD: Once = Once::new();
+    LOAD.call_once(|| unsafe {
+        // The documented way to load the statically linked extension
+        rusqlite::ffi::sqlite3_auto_extension(Some(std::mem::transmute(
+            sqlite_vec::sqlite3_vec_init as *const (),
+        )));
+    });
+}
+
+/// A vector as sqlite-vec reads it: little-endian f32s
+fn vector_blob(vector: &[f32]) -> Vec<u8> {
+    vector.iter().flat_map(|x| x.to_le_bytes()).collect()
+}
+
+fn blob_vector(blob: &[u8]) -> Vec<f32> {
+    blob.chunks_exact(4)
+        .map(|b| f32::from_le_bytes([b[0], b[1], b[2], b[3]]))
+        .collect()
+}
+
+/// A row of [`CHUNK_COLUMNS`] as a chunk without its vector
+fn chunk_from_row(row: &rusqlite::Row) -> rusqlite::Result<IndexedChunk> {
+    let tags: Option<String> = row.get(14)?;
+    Ok(IndexedChunk {
+        id: row.get(0)?,
+        content: row.get(1)?,
+        file_path: row.get(2)?,
+        start_line: row.get::<_, i64>(3)? as usize,
+        end_line: row.get::<_, i64>(4)? as usize,
+        language: row.get(5)?,
+        vector: Vec::new(),
+        mtime: row.get(6)?,
+        file_header: row.get(7)?,
+        semantic_kind: row.get(8)?,
+        symbol_name: row.get(9)?,
+        signature: row.get(10)?,
+        parent: row.get(11)?,
+        visibility: row.get(12)?,
+        qualified_name: row.get(13)?,
+        tags: tags
+            .map(|t| t.split(',').map(str::to_string).collect())
+            .unwrap_or_default(),
+        branch: row.get(15)?,
+        doc: row.get(16)?,
+        duplicate_of: row.get(17)?,
+    })
+}
+
+/// A row of [`RESULT_COLUMNS`] and a distance as a search result, with the
+/// chunk id
+fn result_from_row(row: &rusqlite::Row) -> rusqlite::Result<(String, SearchResult)> {
+    let distance: f64 = row.get(7)?;
+    Ok((
+        row.get(0)?,
+        SearchResult {
+            content: row.get(1)?,
+            file_path: row.get(2)?,
+            start_line: row.get::<_, i64>(3)? as usize,
+            end_line: row.get::<_, i64>(4)? as usize,
+            score: (1.0 / (1.0 + distance * distance)) as f32,
+            file_header: row.get(5)?,
+            semantic_kind: row.get(6)?,
+            sources: Vec::new(),
+            duplicates: Vec::new(),
+        },
+    ))
+}
+
+impl SqliteStore {
+    /// Create or open the index file at `path`
+    ///
+    /// # Errors
+    ///
+    /// Returns an error if `vector_dimension` is 0, the file cannot be
+    /// opened, or it holds vectors of another dimension.
+    pub fn open(path: &Path, vector_dimension: usize) -> Result<Self> {
+        if vector_dimension == 0 {
+            bail!("Vector dimension must be greater than 0");
+        }
+        if let Some(parent) = path.parent() {
+            std::fs::create_dir_all(parent)
+                .with_context(|| format!("Failed to create {}", parent.display()))?;
+        }
+        load_sqlite_vec();
+        let conn = Connection::open(path)
+            .with_context(|| format!("Failed to open SQLite index {}", path.display()))?;
+
+        // LIKE is case-sensitive in LanceDB, which tag filters rely on
+        conn.execute_batch(&format!(
+            "PRAGMA case_sensitive_like = ON;
+             CREATE TABLE IF NOT EXISTS meta (
+                 key TEXT PRIMARY KEY,
+                 value TEXT NOT NULL
+             );
+             CREATE TABLE IF NOT EXISTS chunks (
+                 rowid INTEGER PRIMARY KEY,
+                 id TEXT NOT NULL UNIQUE,
+                 content TEXT NOT NULL,
+                 file_path TEXT NOT NULL,
+                 start_line INTEGER NOT NULL,
+                 end_line INTEGER NOT NULL,
+                 language TEXT,
+                 mtime INTEGER NOT NULL,
+                 file_header TEXT,
+                 semantic_kind TEXT,
+                 symbol_name TEXT,
+                 signature TEXT,
+                 parent TEXT,
+                 visibility TEXT,
+                 qualified_name TEXT,
+                 tags TEXT,
+                 branch TEXT,
+                 doc TEXT,
+                 content_hash TEXT NOT NULL,
+                 duplicate_of TEXT
+             );
+             CREATE INDEX IF NOT EXISTS chunks_file_path ON chunks (file_path);
+             CREATE INDEX IF NOT EXISTS chunks_branch ON chunks (branch);
+             CREATE INDEX IF NOT EXISTS chunks_content_hash ON chunks (content_hash);
+             CREATE INDEX IF NOT EXISTS chunks_duplicate_of ON chunks (duplicate_of);
+             CREATE VIRTUAL TABLE IF NOT EXISTS chunk_vectors USING vec0 (
+                 embedding float[{vector_dimension}]
+             );
+             CREATE TABLE IF NOT EXISTS field_vectors (
+                 field TEXT NOT NULL,
+                 chunk_id TEXT NOT NULL,
+                 file_path TEXT NOT NULL,
+                 branch TEXT,
+                 text TEXT NOT NULL,
+                 embedding BLOB NOT NULL
+             );
+             CREATE INDEX IF NOT EXISTS field_vectors_chunk_id ON field_vectors (chunk_id);"
+        ))
+        .with_context(|| format!("Failed to create tables in {}", path.display()))?;
+
+        let stored: Option<String> = conn
+            .query_row(
+                "SELECT value FROM meta WHERE key = 'vector_dimension'",
+                [],
+                |row| row.get(0),
+            )
+            .optional()?;
+        match stored {
+            Some(stored) if stored != vector_dimension.to_string() => bail!(
+                "Vector dimension mismatch: storage configured for {} dimensions, \
+                 but existing index has {} dimensions. \
+                 Delete the existing index or use matching embedding model.",
+                vector_dimension,
+                stored
+            ),
+            Some(_) => {}
+            None => {
+                conn.execute(
+                    "INSERT INTO meta (key, value) VALUES ('vector_dimension', ?1)",
+                    params![vector_dimension.to_string()],
+                )?;
+            }
+        }
+
+        debug!("Opened SQLite index {}", path.display());
+        Ok(Self {
+            conn: Arc::new(Mutex::new(conn)),
+            vector_dimension,
+        })
+    }
+
+    /// Open the index file at `path` with the vector dimension it was
+    /// written with
+    ///
+    /// Returns None when the file does not exist or holds no index.
+    pub fn open_existing(path: &Path) -> Result<Option<Self>> {
+        if !path.exists() {
+            return Ok(None);
+        }
+        let stored: Option<String> = {
+            let conn = Connection::open(path)
+                .with_context(|| format!("Failed to open SQLite index {}", path.display()))?;
+            let has_meta: bool = conn.query_row(
+                "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'meta')",
+                [],
+                |row| row.get(0),
+            )?;
+            if !has_meta {
+                return Ok(None);
+            }
+            conn.query_row(
+                "SELECT value FROM meta WHERE key = 'vector_dimension'",
+                [],
+                |row| row.get(0),
+            )
+            .optional()?
+        };
+        let Some(stored) = stored else {
+            return Ok(None);
+        };
+        let dimension = stored.parse().with_context(|| {
+            format!("Invalid vector dimension in {}: {}", path.display(), stored)
+        })?;
+        Ok(Some(Self::open(path, dimension)?))
+    }
+
+    /// Run `f` with the connection on the blocking thread pool
+    async fn run<T, F>(&self, f: F) -> Result<T>
+    where
+        T: Send + 'static,
+        F: FnOnce(&mut Connection) -> Result<T> + Send + 'static,
+    {
+        let conn = self.conn.clone();
+        tokio::task::spawn_blocking(move || f(&mut conn.lock().unwrap())).await?
+    }
+
+    /// Check that `vector` has the index's dimension
+    fn check_dimension(&self, what: &str, vector: &[f32]) -> Result<()> {
+        if vector.len() != self.vector_dimension {
+            bail!(
+                "Vector dimension mismatch for {}: expected {} dimensions, got {}",
+                what,
+                self.vector_dimension,
+                vector.len()
+            );
+        }
+        Ok(())
+    }
+}
+
+/// Delete the chunks matching `predicate`, and the field vectors matching
+/// `field_predicate`
+///
+/// The duplicates left behind are promoted as by
+/// [`promote_duplicates`](super::store::promote_duplicates), in SQL, the
+/// heir taking over the deleted holder's vector.
+fn delete_where(tx: &Transaction, predicate: &str, field_predicate: &str) -> Result<()> {
+    let holders: Vec<(i64, String)> = tx
+        .prepare(&format!(
+            "SELECT rowid, id FROM chunks WHERE duplicate_of IS NULL AND ({predicate})"
+        ))?
+        .query_map([], |row| Ok((row.get(0)?, row.get(1)?)))?
+        .collect::<rusqlite::Result<_>>()?;
+    for (rowid, id) in holders {
+        let heir: Option<(i64, String)> = tx
+            .query_row(
+                &format!(
+                    "SELECT rowid, id FROM chunks WHERE duplicate_of = ?1 AND NOT ({predicate}) \
+                     ORDER BY id LIMIT 1"
+                ),
+                params![id],
+                |row| Ok((row.get(0)?, row.get(1)?)),
+            )
+            .optional()?;
+        let Some((heir_rowid, heir_id)) = heir else {
+            continue;
+        };
+        tx.execute(
+            "INSERT INTO chunk_vectors (rowid, embedding) \
+             SELECT ?1, embedding FROM chunk_vectors WHERE rowid = ?2",
+            params![heir_rowid, rowid],
+        )?;
+        tx.execute(
+            "UPDATE chunks SET duplicate_of = NULL WHERE rowid = ?1",
+            params![heir_rowid],
+        )?;
+        tx.execute(
+            &format!(
+                "UPDATE chunks SET duplicate_of = ?1 WHERE duplicate_of = ?2 AND NOT ({predicate})"
+            ),
+            params![heir_id, id],
+        )?;
+    }
+
+    tx.execute(
+        &format!(
+            "DELETE FROM chunk_vectors WHERE rowid IN (SELECT rowid FROM chunks WHERE {predicate})"
+        ),
+        [],
+    )?;
+    tx.execute(&format!("DELETE FROM chunks WHERE {predicate}"), [])?;
+    tx.execute(
+        &format!("DELETE FROM field_vectors WHERE {field_predicate}"),
+        [],
+    )?;
+    Ok(())
+}
+
+/// Locations (`path:start-end`) of the duplicates of each chunk in `ids`,
+/// keyed by chunk id
+fn duplicate_locations(conn: &Connection, ids: &[String]) -> Result<HashMap<String, Vec<String>>> {
+    if ids.is_empty() {
+        return Ok(HashMap::new());
+    }
+    let duplicates: Vec<(String, String, usize, usize)> = conn
+        .prepare(&format!(
+            "SELECT duplicate_of, file_path, start_line, end_line FROM chunks \
+             WHERE duplicate_of IN ({})",
+            sql_list(ids.iter().map(String::as_str))
+        ))?
+        .query_map([], |row| {
+            Ok((
+                row.get(0)?,
+                row.get(1)?,
+                row.get::<_, i64>(2)? as usize,
+                row.get::<_, i64>(3)? as usize,
+            ))
+        })?
+        .collect::<rusqlite::Result<_>>()?;
+    Ok(group_duplicates(duplicates))
+}
+
+#[async_trait]
+impl VectorStore for SqliteStore {
+    fn backend(&self) -> &'static str {
+        SQLITE_BACKEND
+    }
+
+    fn vector_dimension(&self) -> usize {
+        self.vector_dimension
+    }
+
+    async fn upsert(&self, mut chunks: Vec<IndexedChunk>) -> Result<()> {
+        if chunks.is_empty() {
+            return Ok(());
+        }
+        let dimension = self.vector_dimension;
+        let count = chunks.len();
+        self.run(move |conn| {
+            let tx = conn.transaction()?;
+            let ids = sql_list(chunks.iter().map(|c| c.id.as_str()));
+            delete_where(
+                &tx,
+                &format!("id IN ({ids})"),
+                &format!("chunk_id IN ({ids})"),
+            )?;
+
+            let mut holders: HashMap<HolderKey, String> = HashMap::new();
+            {
+                let mut statement = tx.prepare(
+                    "SELECT branch, id FROM chunks WHERE content_hash = ?1 \
+                     AND duplicate_of IS NULL",
+                )?;
+                for hash in content_hashes(&chunks) {
+                    let rows = statement.query_map(params![hash], |row| {
+                        Ok(((hash.clone(), row.get(0)?), row.get(1)?))
+                    })?;
+                    for row in rows {
+                        let (key, id) = row?;
+                        holders.insert(key, id);
+                    }
+                }
+            }
+            mark_duplicates(&mut chunks, holders);
+
+            for chunk in chunks {
+                // Only holders store a vector
+                if chunk.duplicate_of.is_none() && chunk.vector.len() != dimension {
+                    bail!(
+                        "Vector dimension mismatch for chunk '{}': expected {} dimensions, got {}",
+                        chunk.id,
+                        dimension,
+                        chunk.vector.len()
+                    );
+                }
+                let (hash, _) = holder_key(&chunk);
+                let tags = (!chunk.tags.is_empty()).then(|| chunk.tags.join(","));
+                tx.execute(
+                    "INSERT INTO chunks (id, content, file_path, start_line, end_line, language, \
+                     mtime, file_header, semantic_kind, symbol_name, signature, parent, \
+                     visibility, qualified_name, tags, branch, doc, content_hash, duplicate_of) \
+                     VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, \
+                     ?16, ?17, ?18, ?19)",
+                    params![
+                        chunk.id,
+                        chunk.content,
+                        chunk.file_path,
+                        chunk.start_line as i64,
+                        chunk.end_line as i64,
+                        chunk.language,
+                        chunk.mtime,
+                        chunk.file_header,
+                        chunk.semantic_kind,
+                        chunk.symbol_name,
+                        chunk.signature,
+                        chunk.parent,
+                        chunk.visibility,
+                        chunk.qualified_name,
+                        tags,
+                        chunk.branch,
+                        chunk.doc,
+                        hash,
+                        chunk.duplicate_of,
+                    ],
+                )?;
+                if chunk.duplicate_of.is_none() {
+                    tx.execute(
+                        "INSERT INTO chunk_vectors (rowid, embedding) VALUES (?1, ?2)",
+                        params![tx.last_insert_rowid(), vector_blob(&chunk.vector)],
+                    )?;
+                }
+            }
+            tx.commit()?;
+            Ok(())
+        })
+        .await
+        .with_context(|| "Failed to insert chunks")?;
+
+        info!("Inserted {} chunks into database", count);
+        Ok(())
+    }
+
+    async fn upsert_field_vectors(
+        &self,
+        field: VectorField,
+        vectors: Vec<FieldVector>,
+    ) -> Result<()> {
+        if vectors.is_empty() {
+            return Ok(());
+        }
+        for v in &vectors {
+            self.check_dimension(&format!("{} '{}'", field.as_str(), v.text), &v.vector)?;
+        }
+        self.run(move |conn| {
+            let tx = conn.transaction()?;
+            for v in vectors {
+                tx.execute(
+                    "INSERT INTO field_vectors (field, chunk_id, file_path, branch, text, embedding) \
+                     VALUES (?1, ?2, ?3, ?4, ?5, ?6)",
+                    params![
+                        field.as_str(),
+                        v.chunk_id,
+                        v.file_path,
+                        v.branch,
+                        v.text,
+                        vector_blob(&v.vector)
+                    ],
+                )?;
+            }
+            tx.commit()?;
+            Ok(())
+        })
+        .await
+        .with_context(|| format!("Failed to insert {} vectors", field.as_str()))
+    }
+
+    async fn delete_file(&self, path: &Path) -> Result<()> {
+        let path_str = path.to_string_lossy().to_string();
+        let predicate = format!("file_path = '{}'", sql_escape(&path_str));
+        self.run(move |conn| {
+            let tx = conn.transaction()?;
+            delete_where(&tx, &predicate, &predicate)?;
+            tx.commit()?;
+            Ok(())
+        })
+        .await
+        .with_context(|| format!("Failed to delete chunks for file: {}", path_str))?;
+
+        debug!("Deleted chunks for file: {}", path.display());
+        Ok(())
+    }
+
+    async fn delete_branch(&self, branch: &str) -> Result<()> {
+        let predicate = format!("branch = '{}'", sql_escape(branch));
+        self.run(move |conn| {
+            let tx = conn.transaction()?;
+            delete_where(&tx, &predicate, &predicate)?;
+            tx.commit()?;
+            Ok(())
+        })
+        .await
+        .with_context(|| format!("Failed to delete chunks for branch: {}", branch))?;
+
+        debug!("Deleted chunks for branch: {}", branch);
+        Ok(())
+    }
+
+    async fn query(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        // Unfiltered searches use the vec0 index; filtered ones scan the
+        // matching chunks, so the filter never leaves fewer than `limit`
+        let sql = match filter.to_sql() {
+            None => format!(
+                "SELECT {RESULT_COLUMNS}, knn.distance FROM \
+                 (SELECT rowid, distance FROM chunk_vectors WHERE embedding MATCH ?1 AND k = ?2) knn \
+                 JOIN chunks c ON c.rowid = knn.rowid ORDER BY knn.distance"
+            ),
+            Some(filter) => format!(
+                "SELECT {RESULT_COLUMNS}, vec_distance_l2(v.embedding, ?1) AS distance \
+                 FROM chunks c JOIN chunk_vectors v ON v.rowid = c.rowid \
+                 WHERE c.duplicate_of IS NULL AND ({filter}) ORDER BY distance LIMIT ?2"
+            ),
+        };
+
+        self.run(move |conn| {
+            let mut statement = conn.prepare(&sql)?;
+            let rows = statement
+                .query_map(params![vector_blob(&vector), limit as i64], result_from_row)?;
+            let (ids, mut results): (Vec<String>, Vec<SearchResult>) = rows
+                .collect::<rusqlite::Result<Vec<_>>>()?
+                .into_iter()
+                .unzip();
+
+            let mut duplicates = duplicate_locations(conn, &ids)?;
+            for (result, id) in results.iter_mut().zip(&ids) {
+                result.duplicates = duplicates.remove(id).unwrap_or_default();
+            }
+            Ok(results)
+        })
+        .await
+        .with_context(|| "Failed to execute vector search")
+    }
+
+    async fn query_field(
+        &self,
+        field: VectorField,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        // Field vectors have no vec0 index; each chunk keeps its nearest
+        let sql = format!(
+            "SELECT {RESULT_COLUMNS}, d.distance FROM \
+             (SELECT chunk_id, MIN(vec_distance_l2(embedding, ?1)) AS distance \
+              FROM field_vectors WHERE field = ?3 GROUP BY chunk_id) d \
+             JOIN chunks c ON c.id = d.chunk_id \
+             WHERE c.duplicate_of IS NULL{} ORDER BY d.distance LIMIT ?2",
+            filter
+                .to_sql()
+                .map(|f| format!(" AND ({})", f))
+                .unwrap_or_default()
+        );
+        self.run(move |conn| {
+            let results = conn
+                .prepare(&sql)?
+                .query_map(
+                    params![vector_blob(&vector), limit as i64, field.as_str()],
+                    result_from_row,
+                )?
+                .map(|row| row.map(|(_, result)| result))
+                .collect::<rusqlite::Result<_>>()?;
+            Ok(results)
+        })
+        .await
+        .with_context(|| format!("Failed to execute {} search", field.as_str()))
+    }
+
+    async fn has_field_vectors(&self, field: VectorField) -> Result<bool> {
+        self.run(move |conn| {
+            Ok(conn.query_row(
+                "SELECT EXISTS (SELECT 1 FROM field_vectors WHERE field = ?1)",
+                params![field.as_str()],
+                |row| row.get(0),
+            )?)
+        })
+        .await
+        .with_context(|| format!("Failed to query {} vectors", field.as_str()))
+    }
+
+    async fn all_chunks(&self) -> Result<Vec<IndexedChunk>> {
+        self.run(|conn| {
+            let chunks = conn
+                .prepare(&format!(
+                    "SELECT {CHUNK_COLUMNS} FROM chunks ORDER BY rowid"
+                ))?
+                .query_map([], chunk_from_row)?
+                .collect::<rusqlite::Result<_>>()?;
+            Ok(chunks)
+        })
+        .await
+        .with_context(|| "Failed to query chunks")
+    }
+
+    async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>> {
+        self.run(|conn| {
+            let mut statement = conn.prepare(
+                "SELECT c.id, v.embedding FROM chunks c \
+                 JOIN chunks h ON h.id = COALESCE(c.duplicate_of, c.id) \
+                 JOIN chunk_vectors v ON v.rowid = h.rowid",
+            )?;
+            let vectors = statement
+                .query_map([], |row| {
+                    let blob: Vec<u8> = row.get(1)?;
+                    Ok((row.get(0)?, blob_vector(&blob)))
+                })?
+                .collect::<rusqlite::Result<_>>()?;
+            Ok(vectors)
+        })
+        .await
+        .with_context(|| "Failed to query stored vectors")
+    }
+
+    async fn list_files(&self, pattern: Option<&str>) -> Result<Vec<String>> {
+        let files: Vec<String> = self
+            .run(|conn| {
+                let files = conn
+                    .prepare("SELECT DISTINCT file_path FROM chunks ORDER BY file_path")?
+                    .query_map([], |row| row.get(0))?
+                    .collect::<rusqlite::Result<_>>()?;
+                Ok(files)
+            })
+            .await
+            .with_context(|| "Failed to query file paths")?;
+        matching_files(files, pattern)
+    }
+
+    async fn clear(&self) -> Result<()> {
+        self.run(|conn| {
+            let tx = conn.transaction()?;
+            tx.execute_batch(
+                "DELETE FROM chunk_vectors;
+                 DELETE FROM chunks;
+                 DELETE FROM field_vectors;",
+            )?;
+            tx.commit()?;
+            Ok(())
+        })
+        .await
+        .with_context(|| "Failed to clear the index")?;
+
+        info!("Cleared all data from database");
+        Ok(())
+    }
+
+    async fn file_mtimes(&self) -> Result<HashMap<PathBuf, i64>> {
+        self.run(|conn| {
+            let mtimes = conn
+                .prepare(
+                    "SELECT file_path, MAX(mtime) FROM chunks WHERE branch IS NULL \
+                     GROUP BY file_path",
+                )?
+                .query_map([], |row| {
+                    Ok((PathBuf::from(row.get::<_, String>(0)?), row.get(1)?))
+                })?
+                .collect::<rusqlite::Result<_>>()?;
+            Ok(mtimes)
+        })
+        .await
+        .with_context(|| "Failed to query file mtimes")
+    }
+
+    async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>> {
+        self.run(|conn| {
+            let mut statement = conn.prepare(
+                "SELECT c.content, c.language, v.embedding \
+                 FROM chunks c JOIN chunk_vectors v ON v.rowid = c.rowid",
+            )?;
+            let rows = statement.query_map([], |row| {
+                let content: String = row.get(0)?;
+                let language: Option<String> = row.get(1)?;
+                let blob: Vec<u8> = row.get(2)?;
+                Ok((
+                    content_hash(&content, language.as_deref()),
+                    blob_vector(&blob),
+                ))
+            })?;
+            let mut vectors = HashMap::new();
+            for row in rows {
+                let (hash, vector) = row?;
+                vectors.entry(hash).or_insert(vector);
+            }
+            Ok(vectors)
+        })
+        .await
+        .with_context(|| "Failed to query stored vectors")
+    }
+
+    async fn stats(&self) -> Result<StoreStats> {
+        let (chunks, files) = self
+            .run(|conn| {
+                Ok(conn.query_row(
+                    "SELECT COUNT(*), COUNT(DISTINCT file_path) FROM chunks",
+                    [],
+                    |row| Ok((row.get::<_, i64>(0)?, row.get::<_, i64>(1)?)),
+                )?)
+            })
+            .await
+            .with_context(|| "Failed to count chunks")?;
+        Ok(StoreStats {
+            backend: SQLITE_BACKEND,
+            chunks: chunks as usize,
+            files: files as usize,
+            vector_dimension: self.vector_dimension,
+        })
+    }
+
+    async fn snapshot(&self, dest: &Path) -> Result<()> {
+        if dest.exists() {
+            bail!("Snapshot destination {} already exists", dest.display());
+        }
+        let dest_str = dest.to_string_lossy().to_string();
+        self.run(move |conn| {
+            conn.execute("VACUUM INTO ?1", params![dest_str])?;
+            Ok(())
+        })
+        .await
+        .with_context(|| format!("Failed to snapshot the index to {}", dest.display()))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunk(id: &str, file_path: &str, content: &str, vector: Vec<f32>) -> IndexedChunk {
+        IndexedChunk {
+            id: id.to_string(),
+            content: content.to_string(),
+            file_path: file_path.to_string(),
+            start_line: 1,
+            end_line: 3,
+            language: Some("rust".to_string()),
+            vector,
+            mtime: 10,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: None,
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    #[tokio::test]
+    async fn test_upsert_and_query() {
+        let dir = tempfile::tempdir().unwrap();
+        let store = SqliteStore::open(&dir.path().join("index.sqlite"), 2).unwrap();
+        store
+            .upsert(vec![
+                chunk("a", "src/a.rs", "fn a() {}", vec![1.0, 0.0]),
+                chunk("b", "src/b.rs", "fn b() {}", vec![0.0, 1.0]),
+            ])
+            .await
+            .unwrap();
+        let mut replaced = chunk("b", "src/b.rs", "struct B;", vec![0.8, 0.2]);
+        replaced.semantic_kind = Some("struct".to_string());
+        store.upsert(vec![replaced]).await.unwrap();
+
+        let results = store
+            .query(vec![1.0, 0.0], 10, &SearchFilter::default())
+            .await
+            .unwrap();
+        let contents: Vec<&str> = results.iter().map(|r| r.content.as_str()).collect();
+        assert_eq!(contents, vec!["fn a() {}", "struct B;"]);
+        assert_eq!(results[0].score, 1.0);
+
+        let filter = SearchFilter {
+            kind: Some("struct".to_string()),
+            ..Default::default()
+        };
+        let results = store.query(vec![1.0, 0.0], 10, &filter).await.unwrap();
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].file_path, "src/b.rs");
+
+        let stats = store.stats().await.unwrap();
+        assert_eq!((stats.chunks, stats.files), (2, 2));
+        assert_eq!(
+            store.file_mtimes().await.unwrap()[Path::new("src/a.rs")],
+            10
+        );
+    }
+
+    #[tokio::test]
+    async fn test_duplicates_keep_their_vector() {
+        let dir = tempfile::tempdir().unwrap();
+        let store = SqliteStore::open(&dir.path().join("index.sqlite"), 2).unwrap();
+        store
+            .upsert(vec![
+                chunk("a", "src/a.rs", "fn same() {}", vec![1.0, 0.0]),
+                chunk("b", "src/b.rs", "fn same() {}", vec![1.0, 0.0]),
+            ])
+            .await
+            .unwrap();
+
+        let results = store
+            .query(vec![1.0, 0.0], 10, &SearchFilter::default())
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].duplicates, vec!["src/b.rs:1-3"]);
+
+        store.delete_file(Path::new("src/a.rs")).await.unwrap();
+        let results = store
+            .query(vec![1.0, 0.0], 10, &SearchFilter::default())
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].file_path, "src/b.rs");
+        assert_eq!(store.vectors_by_content_hash().await.unwrap().len(), 1);
+    }
+
+    #[tokio::test]
+    async fn test_field_vectors_and_reads() {
+        let dir = tempfile::tempdir().unwrap();
+        let store = SqliteStore::open(&dir.path().join("index.sqlite"), 2).unwrap();
+        store
+            .upsert(vec![
+                chunk("a", "src/a.rs", "fn same() {}", vec![1.0, 0.0]),
+                chunk("b", "src/b.rs", "fn same() {}", vec![1.0, 0.0]),
+                chunk("c", "lib/c.rs", "fn c() {}", vec![0.0, 1.0]),
+            ])
+            .await
+            .unwrap();
+        let name = |chunk_id: &str, file_path: &str, text: &str, vector: Vec<f32>| FieldVector {
+            chunk_id: chunk_id.to_string(),
+            file_path: file_path.to_string(),
+            branch: None,
+            text: text.to_string(),
+            vector,
+        };
+        assert!(!store.has_field_vectors(VectorField::Name).await.unwrap());
+        store
+            .upsert_field_vectors(
+                VectorField::Name,
+                vec![
+                    name("a", "src/a.rs", "same", vec![0.0, 1.0]),
+                    name("c", "lib/c.rs", "c", vec![0.6, 0.8]),
+                    name("c", "lib/c.rs", "see", vec![1.0, 0.0]),
+                ],
+            )
+            .await
+            .unwrap();
+        assert!(store.has_field_vectors(VectorField::Name).await.unwrap());
+        assert!(!store.has_field_vectors(VectorField::Summary).await.unwrap());
+
+        let results = store
+            .query_field(
+                VectorField::Name,
+                vec![1.0, 0.0],
+                10,
+                &SearchFilter::default(),
+            )
+            .await
+            .unwrap();
+        let files: Vec<&str> = results.iter().map(|r| r.file_path.as_str()).collect();
+        assert_eq!(files, vec!["lib/c.rs", "src/a.rs"]);
+        assert_eq!(results[0].score, 1.0);
+
+        let chunks = store.all_chunks().await.unwrap();
+        assert_eq!(chunks.len(), 3);
+        assert_eq!(chunks[1].duplicate_of.as_deref(), Some("a"));
+        assert_eq!(chunks[0].semantic_kind.as_deref(), Some("function"));
+        let vectors = store.vectors_by_id().await.unwrap();
+        assert_eq!(vectors["b"], vec![1.0, 0.0]);
+        assert_eq!(vectors["c"], vec![0.0, 1.0]);
+
+        assert_eq!(
+            store.list_files(Some("src/*")).await.unwrap(),
+            vec!["src/a.rs", "src/b.rs"]
+        );
+        store.clear().await.unwrap();
+        assert_eq!(store.stats().await.unwrap().chunks, 0);
+        assert!(!store.has_field_vectors(VectorField::Name).await.unwrap());
+    }
+
+    #[tokio::test]
+    async fn test_open_existing_uses_stored_dimension() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("index.sqlite");
+        assert!(SqliteStore::open_existing(&path).unwrap().is_none());
+
+        SqliteStore::open(&path, 3).unwrap();
+        let store = SqliteStore::open_existing(&path).unwrap().unwrap();
+        assert_eq!(store.vector_dimension(), 3);
+    }
+
+    #[tokio::test]
+    async fn test_snapshot_and_dimension_check() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("index.sqlite");
+        let store = SqliteStore::open(&path, 2).unwrap();
+        store
+            .upsert(vec![chunk("a", "src/a.rs", "fn a() {}", vec![1.0, 0.0])])
+            .await
+            .unwrap();
+
+        let snapshot = dir.path().join("snapshot.sqlite");
+        store.snapshot(&snapshot).await.unwrap();
+        assert!(store.snapshot(&snapshot).await.is_err());
+        let copy = SqliteStore::open(&snapshot, 2).unwrap();
+        assert_eq!(copy.stats().await.unwrap().chunks, 1);
+
+        drop(store);
+        let error = SqliteStore::open(&path, 3).err().unwrap();
+        assert!(error.to_string().contains("dimension mismatch"));
+    }
+}
@@ -0,0 +1,933 @@
+//! Pluggable vector store interface
+//!
+//! [`VectorStore`] is what CodeRAG needs from a vector database: upserting
+//! chunks and their field vectors, deleting by file or git ref, filtered
+//! similarity queries, reading the chunks back for the symbol, graph and
+//! BM25 indexes, statistics and snapshots. Indexing, search, the watcher
+//! and every command open the configured store with
+//! [`open_configured_store`] or [`open_existing_store`]. LanceDB's
+//! [`Storage`], the single-file [`SqliteStore`] and the shared
+//! [`PgVectorStore`], [`QdrantStore`], [`MilvusStore`] and
+//! [`WeaviateStore`] are built in; others are added by registering a
+//! factory under a name with [`register_backend`] and selecting it with
+//! `storage.backend` in the config, without changes to the indexer.
+
+use anyhow::{bail, Context, Result};
+use async_trait::async_trait;
+use futures::future::BoxFuture;
+use std::collections::hash_map::Entry;
+use std::collections::{BTreeSet, HashMap};
+use std::future::Future;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, RwLock};
+
+use super::lancedb::{
+    content_hash, stored_hash, FieldVector, IndexedChunk, SearchFilter, SearchResult, Storage,
+    VectorField,
+};
+use super::milvus::MilvusStore;
+use super::pgvector::PgVectorStore;
+use super::qdrant::QdrantStore;
+use super::sqlite::SqliteStore;
+use super::weaviate::WeaviateStore;
+use crate::config::StorageConfig;
+
+/// Name of the built-in LanceDB backend
+pub const LANCEDB_BACKEND: &str = "lancedb";
+
+/// Name of the built-in SQLite backend
+pub const SQLITE_BACKEND: &str = "sqlite";
+
+/// Name of the built-in Postgres backend
+pub const PGVECTOR_BACKEND: &str = "pgvector";
+
+/// Name of the built-in Qdrant backend
+pub const QDRANT_BACKEND: &str = "qdrant";
+
+/// Name of the built-in Milvus backend
+pub const MILVUS_BACKEND: &str = "milvus";
+
+/// Name of the built-in Weaviate backend
+pub const WEAVIATE_BACKEND: &str = "weaviate";
+
+/// Size of a vector store's contents
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct StoreStats {
+    /// Backend name, e.g. "lancedb"
+    pub backend: &'static str,
+    /// Stored chunks, duplicates included
+    pub chunks: usize,
+    /// Distinct files the chunks come from
+    pub files: usize,
+    pub vector_dimension: usize,
+}
+
+/// Error of opening a store at its stored dimension where nothing has been
+/// indexed yet
+#[derive(Debug, thiserror::Error)]
+#[error("No index found. Run 'coderag index' first.")]
+pub struct NoIndex;
+
+/// A database of chunk vectors
+#[async_trait]
+pub trait VectorStore: Send + Sync {
+    /// Backend name, e.g. "lancedb"
+    fn backend(&self) -> &'static str;
+
+    /// Dimension of the stored vectors
+    fn vector_dimension(&self) -> usize;
+
+    /// Store `chunks`, replacing stored chunks with the same ids
+    async fn upsert(&self, chunks: Vec<IndexedChunk>) -> Result<()>;
+
+    /// Store vectors of a field of chunks, e.g. their names
+    async fn upsert_field_vectors(
+        &self,
+        field: VectorField,
+        vectors: Vec<FieldVector>,
+    ) -> Result<()>;
+
+    /// Delete the chunks, and their field vectors, of one file
+    async fn delete_file(&self, path: &Path) -> Result<()>;
+
+    /// Delete the chunks, and their field vectors, indexed from a git ref
+    async fn delete_branch(&self, branch: &str) -> Result<()>;
+
+    /// The `limit` chunks most similar to `vector` that match `filter`,
+    /// most similar first
+    async fn query(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>>;
+
+    /// Whether [`hybrid_query`](Self::hybrid_query) is implemented, so
+    /// callers skip embedding a query for it otherwise
+    fn supports_hybrid_query(&self) -> bool {
+        false
+    }
+
+    /// Keyword and vector search of `query` run by the backend itself, with
+    /// `alpha` weighting the vector side (0 is pure BM25, 1 pure vector);
+    /// None when the backend has no hybrid search of its own, and the caller
+    /// fuses [`query`](Self::query) with its own BM25 results instead
+    async fn hybrid_query(
+        &self,
+        _query: &str,
+        _vector: Vec<f32>,
+        _limit: usize,
+        _filter: &SearchFilter,
+        _alpha: f32,
+    ) -> Result<Option<Vec<SearchResult>>> {
+        Ok(None)
+    }
+
+    /// The `limit` chunks matching `filter` whose vectors of `field` are
+    /// most similar to `vector`, most similar first
+    ///
+    /// Each chunk appears once, scored by its best matching vector.
+    async fn query_field(
+        &self,
+        field: VectorField,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>>;
+
+    /// Whether vectors of `field` have been stored
+    async fn has_field_vectors(&self, field: VectorField) -> Result<bool>;
+
+    /// Every stored chunk, duplicates included, without vectors
+    async fn all_chunks(&self) -> Result<Vec<IndexedChunk>>;
+
+    /// Stored chunks of one file, without vectors
+    async fn file_chunks(&self, path: &Path) -> Result<Vec<IndexedChunk>> {
+        let path = path.to_string_lossy();
+        Ok(self
+            .all_chunks()
+            .await?
+            .into_iter()
+            .filter(|c| c.file_path == path)
+            .collect())
+    }
+
+    /// Stored vectors keyed by chunk id, for integrity checks and exports
+    ///
+    /// Duplicates get the vector of the chunk they duplicate.
+    async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>>;
+
+    /// Indexed file paths matching the glob `pattern` (all when None),
+    /// sorted
+    async fn list_files(&self, pattern: Option<&str>) -> Result<Vec<String>> {
+        let files: BTreeSet<String> = self
+            .all_chunks()
+            .await?
+            .into_iter()
+            .map(|c| c.file_path)
+            .collect();
+        matching_files(files, pattern)
+    }
+
+    /// Delete every chunk and field vector
+    async fn clear(&self) -> Result<()> {
+        for file in self.list_files(None).await? {
+            self.delete_file(Path::new(&file)).await?;
+        }
+        Ok(())
+    }
+
+    /// Modification time stored for each indexed file, for incremental
+    /// indexing
+    ///
+    /// Only working-tree files are included; chunks indexed from git refs
+    /// have no file on disk to compare against.
+    async fn file_mtimes(&self) -> Result<HashMap<PathBuf, i64>>;
+
+    /// Stored vectors keyed by content hash, for reusing the embeddings of
+    /// identical chunks
+    async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>>;
+
+    /// Size of the store's contents
+    async fn stats(&self) -> Result<StoreStats>;
+
+    /// Write a consistent copy of the store to `dest`, which must not exist
+    async fn snapshot(&self, dest: &Path) -> Result<()>;
+}
+
+#[async_trait]
+impl VectorStore for Storage {
+    fn backend(&self) -> &'static str {
+        LANCEDB_BACKEND
+    }
+
+    fn vector_dimension(&self) -> usize {
+        Storage::vector_dimension(self)
+    }
+
+    async fn upsert(&self, chunks: Vec<IndexedChunk>) -> Result<()> {
+        let ids: Vec<String> = chunks.iter().map(|c| c.id.clone()).collect();
+        self.delete_by_ids(&ids).await?;
+        self.insert_chunks(chunks).await
+    }
+
+    async fn upsert_field_vectors(
+        &self,
+        field: VectorField,
+        vectors: Vec<FieldVector>,
+    ) -> Result<()> {
+        self.insert_field_vectors(field, vectors).await
+    }
+
+    async fn delete_file(&self, path: &Path) -> Result<()> {
+        self.delete_by_file(path).await
+    }
+
+    async fn delete_branch(&self, branch: &str) -> Result<()> {
+        self.delete_by_branch(branch).await
+    }
+
+    async fn query(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.search_filtered(vector, limit, filter).await
+    }
+
+    async fn query_field(
+        &self,
+        field: VectorField,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.search_field(field, vector, limit, filter).await
+    }
+
+    async fn has_field_vectors(&self, field: VectorField) -> Result<bool> {
+        Storage::has_field_vectors(self, field).await
+    }
+
+    async fn all_chunks(&self) -> Result<Vec<IndexedChunk>> {
+        self.get_all_chunks().await
+    }
+
+    async fn file_chunks(&self, path: &Path) -> Result<Vec<IndexedChunk>> {
+        self.get_file_chunks(path).await
+    }
+
+    async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>> {
+        Storage::vectors_by_id(self).await
+    }
+
+    async fn list_files(&self, pattern: Option<&str>) -> Result<Vec<String>> {
+        Storage::list_files(self, pattern).await
+    }
+
+    async fn clear(&self) -> Result<()> {
+        Storage::clear(self).await
+    }
+
+    async fn file_mtimes(&self) -> Result<HashMap<PathBuf, i64>> {
+        self.get_file_mtimes().await
+    }
+
+    async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>> {
+        Storage::vectors_by_content_hash(self).await
+    }
+
+    async fn stats(&self) -> Result<StoreStats> {
+        Ok(StoreStats {
+            backend: LANCEDB_BACKEND,
+            chunks: self.count_chunks().await?,
+            files: Storage::list_files(self, None).await?.len(),
+            vector_dimension: Storage::vector_dimension(self),
+        })
+    }
+
+    async fn snapshot(&self, dest: &Path) -> Result<()> {
+        if dest.exists() {
+            bail!("Snapshot destination {} already exists", dest.display());
+        }
+        // LanceDB never rewrites data files in place, so a copy of the
+        // directory is a consistent version of the tables
+        copy_dir(self.path(), dest)
+            .with_context(|| format!("Failed to snapshot the index to {}", dest.display()))
+    }
+}
+
+/// The paths of `files` matching the glob `pattern` (all when None), in
+/// order
+pub(super) fn matching_files(
+    files: impl IntoIterator<Item = String>,
+    pattern: Option<&str>,
+) -> Result<Vec<String>> {
+    let Some(pattern) = pattern else {
+        return Ok(files.into_iter().collect());
+    };
+    let glob = glob::Pattern::new(pattern)
+        .with_context(|| format!("Invalid glob pattern: {}", pattern))?;
+    Ok(files.into_iter().filter(|f| glob.matches(f)).collect())
+}
+
+/// Stored content hash and branch of a chunk. Chunks with the same key are
+/// duplicates: the first one stored holds the vector, and searches return
+/// it with the locations of the others.
+pub(super) type HolderKey = (String, Option<String>);
+
+/// Key of the chunk holding the content of `chunk` on its branch
+pub(super) fn holder_key(chunk: &IndexedChunk) -> HolderKey {
+    (
+        stored_hash(content_hash(&chunk.content, chunk.language.as_deref())),
+        chunk.branch.clone(),
+    )
+}
+
+/// Stored content hashes of `chunks`, each once, for looking up the stored
+/// chunks holding them
+pub(super) fn content_hashes(chunks: &[IndexedChunk]) -> Vec<String> {
+    let mut hashes: Vec<String> = chunks.iter().map(|c| holder_key(c).0).collect();
+    hashes.sort_unstable();
+    hashes.dedup();
+    hashes
+}
+
+/// Set `duplicate_of` on each of `chunks` with the content of a stored
+/// chunk in `holders` or of an earlier one in `chunks`, on the same branch
+///
+/// Backends that need a vector on every record keep the duplicates' own;
+/// searches skip them by their `duplicate_of`.
+pub(super) fn mark_duplicates(
+    chunks: &mut [IndexedChunk],
+    mut holders: HashMap<HolderKey, String>,
+) {
+    for chunk in chunks {
+        match holders.entry(holder_key(chunk)) {
+            Entry::Occupied(holder) if *holder.get() != chunk.id => {
+                chunk.duplicate_of = Some(holder.get().clone());
+            }
+            Entry::Occupied(_) => {}
+            Entry::Vacant(entry) => {
+                entry.insert(chunk.id.clone());
+            }
+        }
+    }
+}
+
+/// The `duplicates` of holders about to be deleted, rewritten so they stay
+/// searchable: the first of each holder's duplicates by id takes its place
+/// and the others refer to that one
+pub(super) fn promote_duplicates(duplicates: Vec<IndexedChunk>) -> Vec<IndexedChunk> {
+    let mut groups: HashMap<String, Vec<IndexedChunk>> = HashMap::new();
+    for chunk in duplicates {
+        if let Some(holder) = chunk.duplicate_of.clone() {
+            groups.entry(holder).or_default().push(chunk);
+        }
+    }
+    let mut promoted = Vec::new();
+    for mut chunks in groups.into_values() {
+        chunks.sort_by(|a, b| a.id.cmp(&b.id));
+        let heir = chunks[0].id.clone();
+        for (i, mut chunk) in chunks.into_iter().enumerate() {
+            chunk.duplicate_of = (i > 0).then(|| heir.clone());
+            promoted.push(chunk);
+        }
+    }
+    promoted
+}
+
+/// Locations (`path:start-end`) of stored duplicates, given as the chunk
+/// each duplicates, its file path, start and end line; sorted and keyed by
+/// the chunk they duplicate
+pub(super) fn group_duplicates(
+    duplicates: impl IntoIterator<Item = (String, String, usize, usize)>,
+) -> HashMap<String, Vec<String>> {
+    let mut locations: HashMap<String, Vec<String>> = HashMap::new();
+    for (holder, file_path, start_line, end_line) in duplicates {
+        locations
+            .entry(holder)
+            .or_default()
+            .push(format!("{}:{}-{}", file_path, start_line, end_line));
+    }
+    for paths in locations.values_mut() {
+        paths.sort();
+    }
+    locations
+}
+
+/// Search result of `chunk` with `score` and the locations of its
+/// `duplicates`
+pub(super) fn chunk_result(
+    chunk: IndexedChunk,
+    score: f32,
+    duplicates: Vec<String>,
+) -> SearchResult {
+    SearchResult {
+        content: chunk.content,
+        file_path: chunk.file_path,
+        start_line: chunk.start_line,
+        end_line: chunk.end_line,
+        score,
+        file_header: chunk.file_header,
+        semantic_kind: chunk.semantic_kind,
+        sources: Vec::new(),
+        duplicates,
+    }
+}
+
+/// Latest modification time of each working-tree file among `chunks`,
+/// skipping those indexed from git refs (see
+/// [`VectorStore::file_mtimes`])
+pub(super) fn latest_mtimes(
+    chunks: impl IntoIterator<Item = IndexedChunk>,
+) -> HashMap<PathBuf, i64> {
+    let mut mtimes: HashMap<PathBuf, i64> = HashMap::new();
+    for chunk in chunks.into_iter().filter(|c| c.branch.is_none()) {
+        let mtime = mtimes
+            .entry(PathBuf::from(chunk.file_path))
+            .or_insert(chunk.mtime);
+        *mtime = (*mtime).max(chunk.mtime);
+    }
+    mtimes
+}
+
+/// Field vector hits searched per field search result: a chunk may have
+/// several vectors of a field, and filters apply to the chunks afterwards
+pub(super) const FIELD_HITS_PER_RESULT: usize = 4;
+
+/// Field search results from the field vector `hits`, as pairs of chunk id
+/// and score: each chunk is scored by its best vector, read with
+/// `chunks` (given the chunk ids, and applying the search filter), and the
+/// `limit` best are returned
+pub(super) async fn field_search<F, Fut>(
+    hits: impl IntoIterator<Item = (String, f32)>,
+    limit: usize,
+    chunks: F,
+) -> Result<Vec<SearchResult>>
+where
+    F: FnOnce(Vec<String>) -> Fut,
+    Fut: Future<Output = Result<Vec<IndexedChunk>>>,
+{
+    let scores = best_scores(hits);
+    if scores.is_empty() {
+        return Ok(Vec::new());
+    }
+    let chunks = chunks(scores.keys().cloned().collect()).await?;
+    Ok(field_results(chunks, &scores, limit))
+}
+
+/// Best score of each chunk among the field vector `hits`, as pairs of
+/// chunk id and score
+fn best_scores(hits: impl IntoIterator<Item = (String, f32)>) -> HashMap<String, f32> {
+    let mut scores: HashMap<String, f32> = HashMap::new();
+    for (id, score) in hits {
+        let best = scores.entry(id).or_insert(score);
+        *best = best.max(score);
+    }
+    scores
+}
+
+/// Field search results of `chunks`, scored by `scores` and cut to the
+/// `limit` best
+fn field_results(
+    chunks: Vec<IndexedChunk>,
+    scores: &HashMap<String, f32>,
+    limit: usize,
+) -> Vec<SearchResult> {
+    let mut results: Vec<SearchResult> = chunks
+        .into_iter()
+        .filter_map(|chunk| {
+            let score = *scores.get(&chunk.id)?;
+            Some(chunk_result(chunk, score, Vec::new()))
+        })
+        .collect();
+    results.sort_by(|a, b| b.score.total_cmp(&a.score));
+    results.truncate(limit);
+    results
+}
+
+/// Copy the directory `src` recursively to `dest`
+fn copy_dir(src: &Path, dest: &Path) -> Result<()> {
+    for entry in walkdir::WalkDir::new(src) {
+        let entry = entry?;
+        let target = dest.join(entry.path().strip_prefix(src)?);
+        if entry.file_type().is_dir() {
+            std::fs::create_dir_all(&target)?;
+        } else {
+            std::fs::copy(entry.path(), &target)?;
+        }
+    }
+    Ok(())
+}
+
+/// Where a backend keeps its store
+#[derive(Debug, Clone)]
+pub struct StoreLocation {
+    /// Index path inside `.coderag/`, namespaced per embedding provider.
+    /// File backends store the index here; server backends name their
+    /// tables after it.
+    pub path: PathBuf,
+    /// Connection URL of server backends (`storage.url`)
+    pub url: Option<String>,
+    /// API key of server backends (`storage.api_key`)
+    pub api_key: Option<String>,
+    /// Dimension of the stored vectors; None opens an existing store at the
+    /// dimension it was written with, failing with [`NoIndex`] when there
+    /// is none
+    pub dimension: Option<usize>,
+}
+
+impl StoreLocation {
+    /// Location of a store kept in a file or directory
+    pub fn file(path: &Path, dimension: usize) -> Self {
+        Self {
+            path: path.to_path_buf(),
+            url: None,
+            api_key: None,
+            dimension: Some(dimension),
+        }
+    }
+
+    /// Location of the index at `path` with the connection settings of
+    /// `config`
+    pub fn configured(
+        config: &StorageConfig,
+        path: &Path,
+        dimension: Option<usize>,
+    ) -> Result<Self> {
+        Ok(Self {
+            path: path.to_path_buf(),
+            url: config.load_url()?,
+            api_key: config.load_api_key()?,
+            dimension,
+        })
+    }
+}
+
+/// Opens a vector store at a location
+pub type BackendFactory = fn(StoreLocation) -> BoxFuture<'static, Result<Arc<dyn VectorStore>>>;
+
+fn open_lancedb(location: StoreLocation) -> BoxFuture<'static, Result<Arc<dyn VectorStore>>> {
+    Box::pin(async move {
+        let storage = match location.dimension {
+            Some(dimension) => Storage::new(&location.path, dimension).await?,
+            None => Storage::open_existing(&location.path)
+                .await?
+                .ok_or(NoIndex)?,
+        };
+        let store: Arc<dyn VectorStore> = Arc::new(storage);
+        Ok(store)
+    })
+}
+
+fn open_sqlite(location: StoreLocation) -> BoxFuture<'static, Result<Arc<dyn VectorStore>>> {
+    Box::pin(async move {
+        let store = match location.dimension {
+            Some(dimension) => SqliteStore::open(&location.path, dimension)?,
+            None => SqliteStore::open_existing(&location.path)?.ok_or(NoIndex)?,
+        };
+        let store: Arc<dyn VectorStore> = Arc::new(store);
+        Ok(store)
+    })
+}
+
+fn open_pgvector(location: StoreLocation) -> BoxFuture<'static, Result<Arc<dyn VectorStore>>> {
+    Box::pin(async move {
+        let Some(url) = &location.url else {
+            bail!("The pgvector backend needs a connection URL in storage.url");
+        };
+        if !crate::embeddings::is_loopback(url) {
+            crate::offline::ensure_network_allowed("storage", PGVECTOR_BACKEND)?;
+        }
+        let namespace = namespace(&location.path);
+        let store = match location.dimension {
+            Some(dimension) => PgVectorStore::connect(url, &namespace, dimension).await?,
+            None => PgVectorStore::connect_existing(url, &namespace)
+                .await?
+                .ok_or(NoIndex)?,
+        };
+        let store: Arc<dyn VectorStore> = Arc::new(store);
+        Ok(store)
+    })
+}
+
+fn open_qdrant(location: StoreLocation) -> BoxFuture<'static, Result<Arc<dyn VectorStore>>> {
+    Box::pin(async move {
+        let Some(url) = &location.url else {
+            bail!("The qdrant backend needs a server URL in storage.url");
+        };
+        if !crate::embeddings::is_loopback(url) {
+            crate::offline::ensure_network_allowed("storage", QDRANT_BACKEND)?;
+        }
+        let namespace = namespace(&location.path);
+        let api_key = location.api_key.clone();
+        let store = match location.dimension {
+            Some(dimension) => QdrantStore::connect(url, api_key, &namespace, dimension).await?,
+            None => QdrantStore::connect_existing(url, api_key, &namespace)
+                .await?
+                .ok_or(NoIndex)?,
+        };
+        let store: Arc<dyn VectorStore> = Arc::new(store);
+        Ok(store)
+    })
+}
+
+fn open_milvus(location: StoreLocation) -> BoxFuture<'static, Result<Arc<dyn VectorStore>>> {
+    Box::pin(async move {
+        let Some(url) = &location.url else {
+            bail!("The milvus backend needs a server URL in storage.url");
+        };
+        if !crate::embeddings::is_loopback(url) {
+            crate::offline::ensure_network_allowed("storage", MILVUS_BACKEND)?;
+        }
+        let namespace = namespace(&location.path);
+        let api_key = location.api_key.clone();
+        let store = match location.dimension {
+            Some(dimension) => MilvusStore::connect(url, api_key, &namespace, dimension).await?,
+            None => MilvusStore::connect_existing(url, api_key, &namespace)
+                .await?
+                .ok_or(NoIndex)?,
+        };
+        let store: Arc<dyn VectorStore> = Arc::new(store);
+        Ok(store)
+    })
+}
+
+fn open_weaviate(location: StoreLocation) -> BoxFuture<'static, Result<Arc<dyn VectorStore>>> {
+    Box::pin(async move {
+        let Some(url) = &location.url else {
+            bail!("The weaviate backend needs a server URL in storage.url");
+        };
+        if !crate::embeddings::is_loopback(url) {
+            crate::offline::ensure_network_allowed("storage", WEAVIATE_BACKEND)?;
+        }
+        let namespace = namespace(&location.path);
+        let api_key = location.api_key.clone();
+        let store = match location.dimension {
+            Some(dimension) => WeaviateStore::connect(url, api_key, &namespace, dimension).await?,
+            None => WeaviateStore::connect_existing(url, api_key, &namespace)
+                .await?
+                .ok_or(NoIndex)?,
+        };
+        let store: Arc<dyn VectorStore> = Arc::new(store);
+        Ok(store)
+    })
+}
+
+/// Table, collection or partition name of the index at `path`: `coderag_`
+/// and the file stem, lowercased with anything but ASCII letters and digits
+/// replaced by `_`
+fn namespace(path: &Path) -> String {
+    let stem = path.file_stem().and_then(|s| s.to_str()).unwrap_or("index");
+    let stem: String = stem
+        .chars()
+        .map(|c| match c {
+            'a'..='z' | '0'..='9' => c,
+            'A'..='Z' => c.to_ascii_lowercase(),
+            _ => '_',
+        })
+        .collect();
+    format!("coderag_{}", stem)
+}
+
+lazy_static::lazy_static! {
+    static ref BACKENDS: RwLock<HashMap<String, BackendFactory>> = {
+        let mut backends = HashMap::new();
+        backends.insert(LANCEDB_BACKEND.to_string(), open_lancedb as BackendFactory);
+        backends.insert(SQLITE_BACKEND.to_string(), open_sqlite as BackendFactory);
+        backends.insert(PGVECTOR_BACKEND.to_string(), open_pgvector as BackendFactory);
+        backends.insert(QDRANT_BACKEND.to_string(), open_qdrant as BackendFactory);
+        backends.insert(MILVUS_BACKEND.to_string(), open_milvus as BackendFactory);
+        backends.insert(WEAVIATE_BACKEND.to_string(), open_weaviate as BackendFactory);
+        RwLock::new(backends)
+    };
+}
+
+/// Make a backend available as `storage.backend = "<name>"`, replacing any
+/// registered under the same name
+pub fn register_backend(name: &str, factory: BackendFactory) {
+    BACKENDS.write().unwrap().insert(name.to_string(), factory);
+}
+
+/// Names of the registered backends, sorted
+pub fn backends() -> Vec<String> {
+    let mut names: Vec<String> = BACKENDS.read().unwrap().keys().cloned().collect();
+    names.sort();
+    names
+}
+
+/// Open the vector store of backend `name` at `location`
+pub async fn open_vector_store(
+    name: &str,
+    location: StoreLocation,
+) -> Result<Arc<dyn VectorStore>> {
+    let factory = BACKENDS.read().unwrap().get(name).copied();
+    let Some(factory) = factory else {
+        bail!(
+            "Unknown vector store backend '{}' (available: {})",
+            name,
+            backends().join(", ")
+        );
+    };
+    factory(location).await
+}
+
+/// Open the index at `path` with the backend and connection settings of
+/// `config`; with no `dimension`, at the dimension it was written with
+pub async fn open_configured_store(
+    config: &StorageConfig,
+    path: &Path,
+    dimension: Option<usize>,
+) -> Result<Arc<dyn VectorStore>> {
+    let location = StoreLocation::configured(config, path, dimension)?;
+    open_vector_store(&config.backend, location).await
+}
+
+/// Open the index at `path` at the dimension it was written with, for reads
+/// that don't know the embedding model; None when nothing has been indexed
+/// there yet
+pub async fn open_existing_store(
+    config: &StorageConfig,
+    path: &Path,
+) -> Result<Option<Arc<dyn VectorStore>>> {
+    match open_configured_store(config, path, None).await {
+        Ok(store) => Ok(Some(store)),
+        Err(e) if e.is::<NoIndex>() => Ok(None),
+        Err(e) => Err(e),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn open_nothing(_: StoreLocation) -> BoxFuture<'static, Result<Arc<dyn VectorStore>>> {
+        Box::pin(async { bail!("test backend") })
+    }
+
+    #[tokio::test]
+    async fn test_backends_are_registered_by_name() {
+        assert!(backends().contains(&LANCEDB_BACKEND.to_string()));
+
+        register_backend("test-nothing", open_nothing);
+        assert!(backends().contains(&"test-nothing".to_string()));
+        let error = open_vector_store(
+            "test-nothing",
+            StoreLocation::file(Path::new("/nonexistent"), 4),
+        )
+        .await
+        .err()
+        .unwrap();
+        assert_eq!(error.to_string(), "test backend");
+
+        let error = open_vector_store(
+            "no-such-backend",
+            StoreLocation::file(Path::new("/nonexistent"), 4),
+        )
+        .await
+        .err()
+        .unwrap();
+        assert!(error.to_string().contains("available: "));
+    }
+
+    #[test]
+    fn test_namespace() {
+        assert_eq!(
+            namespace(Path::new(".coderag/index.lance")),
+            "coderag_index"
+        );
+        assert_eq!(
+            namespace(Path::new(".coderag/My-App-ollama.lance")),
+            "coderag_my_app_ollama"
+        );
+    }
+
+    fn chunk(id: &str, file_path: &str, content: &str) -> IndexedChunk {
+        IndexedChunk {
+            id: id.to_string(),
+            content: content.to_string(),
+            file_path: file_path.to_string(),
+            start_line: 1,
+            end_line: 3,
+            language: Some("rust".to_string()),
+            vector: Vec::new(),
+            mtime: 10,
+            file_header: None,
+            semantic_kind: None,
+            symbol_name: None,
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    #[test]
+    fn test_mark_duplicates() {
+        let stored = chunk("s", "src/s.rs", "fn x() {}");
+        let mut chunks = vec![
+            chunk("s", "src/s.rs", "fn x() {}"),
+            chunk("a", "src/a.rs", "fn x() {}"),
+            chunk("b", "src/b.rs", "fn y() {}"),
+            chunk("c", "src/c.rs", "fn y() {}"),
+            IndexedChunk {
+                branch: Some("dev".to_string()),
+                ..chunk("d", "src/d.rs", "fn x() {}")
+            },
+        ];
+        mark_duplicates(
+            &mut chunks,
+            HashMap::from([(holder_key(&stored), stored.id.clone())]),
+        );
+        let holders: Vec<Option<&str>> = chunks.iter().map(|c| c.duplicate_of.as_deref()).collect();
+        assert_eq!(holders, vec![None, Some("s"), None, Some("b"), None]);
+        assert_eq!(content_hashes(&chunks).len(), 2);
+    }
+
+    #[test]
+    fn test_promote_duplicates() {
+        let duplicate = |id: &str, holder: &str| IndexedChunk {
+            duplicate_of: Some(holder.to_string()),
+            ..chunk(id, &format!("src/{id}.rs"), "fn x() {}")
+        };
+        let mut promoted = promote_duplicates(vec![
+            duplicate("c", "a"),
+            duplicate("b", "a"),
+            duplicate("e", "d"),
+        ]);
+        promoted.sort_by(|a, b| a.id.cmp(&b.id));
+        let holders: Vec<(&str, Option<&str>)> = promoted
+            .iter()
+            .map(|c| (c.id.as_str(), c.duplicate_of.as_deref()))
+            .collect();
+        assert_eq!(holders, vec![("b", None), ("c", Some("b")), ("e", None)]);
+    }
+
+    #[test]
+    fn test_group_duplicates() {
+        let locations = group_duplicates([
+            ("a".to_string(), "src/c.rs".to_string(), 4, 8),
+            ("a".to_string(), "src/b.rs".to_string(), 1, 3),
+            ("d".to_string(), "src/e.rs".to_string(), 2, 2),
+        ]);
+        assert_eq!(locations["a"], vec!["src/b.rs:1-3", "src/c.rs:4-8"]);
+        assert_eq!(locations["d"], vec!["src/e.rs:2-2"]);
+    }
+
+    #[test]
+    fn test_latest_mtimes_skip_git_refs() {
+        let mtimes = latest_mtimes([
+            chunk("a", "src/a.rs", "fn a() {}"),
+            IndexedChunk {
+                mtime: 20,
+                ..chunk("b", "src/a.rs", "fn b() {}")
+            },
+            IndexedChunk {
+                branch: Some("dev".to_string()),
+                mtime: 30,
+                ..chunk("c", "src/c.rs", "fn c() {}")
+            },
+        ]);
+        assert_eq!(mtimes, HashMap::from([(PathBuf::from("src/a.rs"), 20)]));
+    }
+
+    #[tokio::test]
+    async fn test_lancedb_upsert_stats_and_snapshot() {
+        let dir = tempfile::tempdir().unwrap();
+        let store = open_vector_store(
+            LANCEDB_BACKEND,
+            StoreLocation::file(&dir.path().join("index.lance"), 4),
+        )
+        .await
+        .unwrap();
+
+        let chunk = IndexedChunk {
+            id: "a".to_string(),
+            content: "fn a() {}".to_string(),
+            file_path: "src/a.rs".to_string(),
+            start_line: 1,
+            end_line: 1,
+            language: Some("rust".to_string()),
+            vector: vec![1.0, 0.0, 0.0, 0.0],
+            mtime: 0,
+            file_header: None,
+            semantic_kind: None,
+            symbol_name: None,
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        };
+        store.upsert(vec![chunk.clone()]).await.unwrap();
+        store
+            .upsert(vec![IndexedChunk {
+                content: "fn a() { todo!() }".to_string(),
+                ..chunk
+            }])
+            .await
+            .unwrap();
+
+        let stats = store.stats().await.unwrap();
+        assert_eq!(stats.chunks, 1);
+        assert_eq!(stats.files, 1);
+        assert_eq!(stats.vector_dimension, 4);
+
+        let snapshot = dir.path().join("snapshot.lance");
+        store.snapshot(&snapshot).await.unwrap();
+        assert!(store.snapshot(&snapshot).await.is_err());
+        let copy = open_vector_store(LANCEDB_BACKEND, StoreLocation::file(&snapshot, 4))
+            .await
+            .unwrap();
+        assert_eq!(copy.stats().await.unwrap().chunks, 1);
+    }
+}
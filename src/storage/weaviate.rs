@@ -0,0 +1,1271 @@
+//! Weaviate vector store
+//!
+//! Keeps each index in two Weaviate classes, one for chunks and one for
+//! field vectors, provisioned on first use with `l2-squared` distance and
+//! no vectorizer: vectors always come from CodeRAG's embedder. Reads and
+//! searches go through Weaviate's GraphQL API, writes and deletes through
+//! its REST batch API.
+//!
+//! Weaviate also ranks chunk contents with BM25, so
+//! [`VectorStore::hybrid_query`] is answered by Weaviate's own hybrid search
+//! instead of fusing a separate keyword search. Behaviour otherwise matches
+//! the LanceDB [`Storage`](super::Storage): identical chunks on one branch
+//! are searched once, and vector scores are `1 / (1 + d)` of the squared L2
+//! distance `d`.
+
+use anyhow::{bail, Context, Result};
+use async_trait::async_trait;
+use serde_json::{json, Map, Value};
+use std::collections::{HashMap, HashSet};
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+use tracing::{debug, info};
+use uuid::Uuid;
+
+use super::lancedb::{
+    content_hash, stored_hash, FieldVector, IndexedChunk, SearchFilter, SearchResult, VectorField,
+};
+use super::sqlite::SqliteStore;
+use super::store::{
+    chunk_result, content_hashes, field_search, group_duplicates, holder_key, latest_mtimes,
+    mark_duplicates, promote_duplicates, StoreStats, VectorStore, FIELD_HITS_PER_RESULT,
+    WEAVIATE_BACKEND,
+};
+
+/// Timeout of one request; batches and pages of vectors take longest
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(60);
+
+/// Namespace of the UUIDv5 object ids, which Weaviate requires instead of
+/// chunk ids
+const OBJECT_ID_NAMESPACE: Uuid = Uuid::from_u128(0x5b8e_1f0a_93c4_4d27_8e61_c0a7_2f94_b3d8);
+
+/// Objects read per query and written per batch
+const PAGE: usize = 1000;
+
+/// Chunk properties ranked by BM25 in hybrid searches
+const KEYWORD_PROPERTIES: &[&str] = &["content", "symbol_name", "signature", "doc"];
+
+/// Properties of the chunks class and their data types; text is tokenized
+/// into words for the [`KEYWORD_PROPERTIES`], and otherwise kept whole as
+/// one token, for exact filters
+const CHUNK_PROPERTIES: &[(&str, &str)] = &[
+    ("chunk_id", "text"),
+    ("content", "text"),
+    ("file_path", "text"),
+    ("start_line", "int"),
+    ("end_line", "int"),
+    ("language", "text"),
+    ("mtime", "int"),
+    ("file_header", "text"),
+    ("semantic_kind", "text"),
+    ("symbol_name", "text"),
+    ("signature", "text"),
+    ("doc", "text"),
+    ("parent", "text"),
+    ("visibility", "text"),
+    ("qualified_name", "text"),
+    ("tags", "text[]"),
+    ("tag_keys", "text[]"),
+    ("branch", "text"),
+    ("content_hash", "text"),
+    ("duplicate_of", "text"),
+];
+
+/// Properties of the field vectors class
+const FIELD_VECTOR_PROPERTIES: &[(&str, &str)] = &[
+    ("field", "text"),
+    ("chunk_id", "text"),
+    ("file_path", "text"),
+    ("branch", "text"),
+    ("text", "text"),
+];
+
+/// A Weaviate `where` filter
+#[derive(Debug, Clone, PartialEq)]
+enum Where {
+    /// The text property, or an item of the array, equals `value`
+    Equal {
+        path: &'static str,
+        value: String,
+    },
+    /// The text property, or an item of the array, is one of `values`
+    ContainsAny {
+        path: &'static str,
+        values: Vec<String>,
+    },
+    /// The property is unset
+    IsNull {
+        path: &'static str,
+    },
+    And(Vec<Where>),
+}
+
+impl Where {
+    /// The filter in the JSON of the REST API, which GraphQL arguments
+    /// mirror
+    fn to_json(&self) -> Value {
+        match self {
+            Where::Equal { path, value } => {
+                json!({"path": [path], "operator": "Equal", "valueText": value})
+            }
+            Where::ContainsAny { path, values } => {
+                json!({"path": [path], "operator": "ContainsAny", "valueTextArray": values})
+            }
+            Where::IsNull { path } => {
+                json!({"path": [path], "operator": "IsNull", "valueBoolean": true})
+            }
+            Where::And(operands) => json!({
+                "operator": "And",
+                "operands": operands.iter().map(Where::to_json).collect::<Vec<_>>(),
+            }),
+        }
+    }
+}
+
+/// Filter of a search; header metadata tags ending in `=` match any value
+/// of their key through the `tag_keys` property
+fn search_where(filter: &SearchFilter) -> Where {
+    let mut operands = vec![Where::IsNull {
+        path: "duplicate_of",
+    }];
+    if let Some(kind) = &filter.kind {
+        operands.push(Where::Equal {
+            path: "semantic_kind",
+            value: kind.clone(),
+        });
+    }
+    if let Some(tag) = &filter.tag {
+        operands.push(Where::Equal {
+            path: "tags",
+            value: tag.clone(),
+        });
+    }
+    for tag in &filter.meta {
+        operands.push(Where::Equal {
+            path: if tag.ends_with('=') {
+                "tag_keys"
+            } else {
+                "tags"
+            },
+            value: tag.clone(),
+        });
+    }
+    if let Some(branch) = &filter.branch {
+        operands.push(Where::Equal {
+            path: "branch",
+            value: branch.clone(),
+        });
+    }
+    Where::And(operands)
+}
+
+/// `value` as a GraphQL input value: JSON with bare keys, and bare enum
+/// values for the keys that take one
+fn graphql_value(value: &Value) -> String {
+    match value {
+        Value::Object(map) => format!("{{{}}}", graphql_arguments(map)),
+        Value::Array(items) => {
+            let items: Vec<String> = items.iter().map(graphql_value).collect();
+            format!("[{}]", items.join(", "))
+        }
+        other => other.to_string(),
+    }
+}
+
+/// `map` as comma-separated GraphQL arguments
+fn graphql_arguments(map: &Map<String, Value>) -> String {
+    let arguments: Vec<String> = map
+        .iter()
+        .map(|(key, value)| match (key.as_str(), value) {
+            ("operator" | "fusionType", Value::String(value)) => format!("{}: {}", key, value),
+            _ => format!("{}: {}", key, graphql_value(value)),
+        })
+        .collect();
+    arguments.join(", ")
+}
+
+/// A `Get` query of `class` with `arguments`, reading `properties` and the
+/// `additional` metadata
+fn get_query(
+    class: &str,
+    arguments: &Map<String, Value>,
+    properties: &[&str],
+    additional: &[&str],
+) -> String {
+    format!(
+        "{{ Get {{ {}({}) {{ {} _additional {{ {} }} }} }} }}",
+        class,
+        graphql_arguments(arguments),
+        properties.join(" "),
+        additional.join(" ")
+    )
+}
+
+/// Weaviate class name for a namespace; class names start with a capital
+fn class_name(namespace: &str) -> String {
+    let mut chars = namespace.chars();
+    match chars.next() {
+        Some(first) => first.to_ascii_uppercase().to_string() + chars.as_str(),
+        None => String::new(),
+    }
+}
+
+/// Id of the object of a chunk
+fn object_id(chunk_id: &str) -> String {
+    Uuid::new_v5(&OBJECT_ID_NAMESPACE, chunk_id.as_bytes()).to_string()
+}
+
+/// Class definition with `properties`, for vectors supplied by the client
+fn class_schema(class: &str, properties: &[(&str, &str)]) -> Value {
+    let properties: Vec<Value> = properties
+        .iter()
+        .map(|(name, data_type)| {
+            let tokenization = if KEYWORD_PROPERTIES.contains(name) {
+                "word"
+            } else {
+                "field"
+            };
+            match *data_type {
+                "int" => json!({"name": name, "dataType": ["int"]}),
+                _ => json!({
+                    "name": name,
+                    "dataType": [data_type],
+                    "tokenization": tokenization,
+                }),
+            }
+        })
+        .collect();
+    json!({
+        "class": class,
+        "vectorizer": "none",
+        "vectorIndexConfig": {"distance": "l2-squared"},
+        "invertedIndexConfig": {"indexNullState": true},
+        "properties": properties,
+    })
+}
+
+fn chunk_properties(chunk: &IndexedChunk) -> Map<String, Value> {
+    // `key=` of each `key=value` tag, for filters on any value of a key
+    let tag_keys: Vec<&str> = chunk
+        .tags
+        .iter()
+        .filter_map(|tag| tag.find('=').map(|i| &tag[..=i]))
+        .collect();
+    let properties = json!({
+        "chunk_id": chunk.id,
+        "content": chunk.content,
+        "file_path": chunk.file_path,
+        "start_line": chunk.start_line,
+        "end_line": chunk.end_line,
+        "language": chunk.language,
+        "mtime": chunk.mtime,
+        "file_header": chunk.file_header,
+        "semantic_kind": chunk.semantic_kind,
+        "symbol_name": chunk.symbol_name,
+        "signature": chunk.signature,
+        "doc": chunk.doc,
+        "parent": chunk.parent,
+        "visibility": chunk.visibility,
+        "qualified_name": chunk.qualified_name,
+        "tags": chunk.tags,
+        "tag_keys": tag_keys,
+        "branch": chunk.branch,
+        "content_hash": stored_hash(content_hash(&chunk.content, chunk.language.as_deref())),
+        "duplicate_of": chunk.duplicate_of,
+    });
+    let Value::Object(mut properties) = properties else {
+        unreachable!()
+    };
+    // Unset properties are left out, so `IsNull` filters match them
+    properties.retain(|_, value| !value.is_null());
+    properties
+}
+
+fn prop_str(object: &Map<String, Value>, key: &str) -> Option<String> {
+    object.get(key).and_then(Value::as_str).map(str::to_string)
+}
+
+/// An int property; GraphQL may return it as a float
+fn prop_i64(object: &Map<String, Value>, key: &str) -> i64 {
+    object
+        .get(key)
+        .and_then(|v| v.as_i64().or_else(|| v.as_f64().map(|x| x as i64)))
+        .unwrap_or(0)
+}
+
+/// A value of the object's `_additional` metadata
+fn additional<'a>(object: &'a Map<String, Value>, key: &str) -> &'a Value {
+    static NULL: Value = Value::Null;
+    object.get("_additional").map_or(&NULL, |meta| &meta[key])
+}
+
+fn object_vector(object: &Map<String, Value>) -> Vec<f32> {
+    additional(object, "vector")
+        .as_array()
+        .map(|v| {
+            v.iter()
+                .filter_map(Value::as_f64)
+                .map(|x| x as f32)
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+fn chunk_from_object(object: &Map<String, Value>) -> IndexedChunk {
+    IndexedChunk {
+        id: prop_str(object, "chunk_id").unwrap_or_default(),
+        content: prop_str(object, "content").unwrap_or_default(),
+        file_path: prop_str(object, "file_path").unwrap_or_default(),
+        start_line: prop_i64(object, "start_line") as usize,
+        end_line: prop_i64(object, "end_line") as usize,
+        language: prop_str(object, "language"),
+        mtime: prop_i64(object, "mtime"),
+        file_header: prop_str(object, "file_header"),
+        semantic_kind: prop_str(object, "semantic_kind"),
+        symbol_name: prop_str(object, "symbol_name"),
+        signature: prop_str(object, "signature"),
+        doc: prop_str(object, "doc"),
+        parent: prop_str(object, "parent"),
+        visibility: prop_str(object, "visibility"),
+        qualified_name: prop_str(object, "qualified_name"),
+        tags: object
+            .get("tags")
+            .and_then(Value::as_array)
+            .map(|tags| {
+                tags.iter()
+                    .filter_map(Value::as_str)
+                    .map(str::to_string)
+                    .collect()
+            })
+            .unwrap_or_default(),
+        branch: prop_str(object, "branch"),
+        duplicate_of: prop_str(object, "duplicate_of"),
+        vector: object_vector(object),
+    }
+}
+
+fn property_names(properties: &[(&'static str, &str)]) -> Vec<&'static str> {
+    properties.iter().map(|(name, _)| *name).collect()
+}
+
+/// Weaviate storage backend
+pub struct WeaviateStore {
+    client: reqwest::Client,
+    url: String,
+    api_key: Option<String>,
+    /// Class of the chunks; field vectors are in `<class>_fields`
+    class: String,
+    fields_class: String,
+    vector_dimension: usize,
+}
+
+impl WeaviateStore {
+    /// Connect to the Weaviate server at `url` and open the classes of
+    /// `namespace`, creating them when missing
+    ///
+    /// # Errors
+    ///
+    /// Returns an error if `vector_dimension` is 0, the URL is not http(s),
+    /// the server cannot be reached, or the index holds vectors of another
+    /// dimension.
+    pub async fn connect(
+        url: &str,
+        api_key: Option<String>,
+        namespace: &str,
+        vector_dimension: usize,
+    ) -> Result<Self> {
+        if vector_dimension == 0 {
+            bail!("Vector dimension must be greater than 0");
+        }
+        let store = Self::new(url, api_key, namespace, vector_dimension)?;
+        store.ensure_class(&store.class, CHUNK_PROPERTIES).await?;
+        store
+            .ensure_class(&store.fields_class, FIELD_VECTOR_PROPERTIES)
+            .await?;
+        debug!("Opened Weaviate class {}", store.class);
+        Ok(store)
+    }
+
+    /// Connect to the Weaviate server at `url` and open the classes of
+    /// `namespace` with the dimension of their stored vectors
+    ///
+    /// Returns None when the chunks class does not exist or is empty.
+    pub async fn connect_existing(
+        url: &str,
+        api_key: Option<String>,
+        namespace: &str,
+    ) -> Result<Option<Self>> {
+        let probe = Self::new(url, api_key.clone(), namespace, 0)?;
+        let path = format!("/v1/schema/{}", probe.class);
+        if probe
+            .call(reqwest::Method::GET, &path, None)
+            .await?
+            .is_none()
+        {
+            return Ok(None);
+        }
+        let arguments = Map::from_iter([("limit".to_string(), json!(1))]);
+        let objects = probe
+            .get(
+                &probe.class,
+                get_query(&probe.class, &arguments, &[], &["id", "vector"]),
+            )
+            .await?;
+        match objects.first().map(|o| object_vector(o).len()) {
+            Some(dimension) if dimension > 0 => Ok(Some(
+                Self::connect(url, api_key, namespace, dimension).await?,
+            )),
+            _ => Ok(None),
+        }
+    }
+
+    /// Client of the classes of `namespace`, without touching the server
+    fn new(
+        url: &str,
+        api_key: Option<String>,
+        namespace: &str,
+        vector_dimension: usize,
+    ) -> Result<Self> {
+        if !url.starts_with("http://") && !url.starts_with("https://") {
+            bail!("Weaviate URL must start with http:// or https://: {}", url);
+        }
+        let client = reqwest::Client::builder()
+            .timeout(REQUEST_TIMEOUT)
+            .build()
+            .context("Failed to create HTTP client")?;
+        let class = class_name(namespace);
+        Ok(Self {
+            client,
+            url: url.trim_end_matches('/').to_string(),
+            api_key,
+            fields_class: format!("{}_fields", class),
+            class,
+            vector_dimension,
+        })
+    }
+
+    /// Send `body` to `path` and return the parsed response, or None for a
+    /// 404
+    async fn call(
+        &self,
+        method: reqwest::Method,
+        path: &str,
+        body: Option<Value>,
+    ) -> Result<Option<Value>> {
+        let url = format!("{}{}", self.url, path);
+        let mut request = self.client.request(method, &url);
+        if let Some(key) = &self.api_key {
+            request = request.bearer_auth(key);
+        }
+        if let Some(body) = body {
+            request = request.json(&body);
+        }
+        let response = request
+            .send()
+            .await
+            .with_context(|| format!("Weaviate request to {} failed", url))?;
+        let status = response.status();
+        if status == reqwest::StatusCode::NOT_FOUND {
+            return Ok(None);
+        }
+        if !status.is_success() {
+            let body = response.text().await.unwrap_or_default();
+            bail!("Weaviate returned {}: {}", status, body.trim());
+        }
+        let body: Value = response
+            .json()
+            .await
+            .context("Failed to parse Weaviate response")?;
+        Ok(Some(body))
+    }
+
+    /// Run a GraphQL `Get` query and return the objects of `class`
+    async fn get(&self, class: &str, query: String) -> Result<Vec<Map<String, Value>>> {
+        let mut body = self
+            .call(
+                reqwest::Method::POST,
+                "/v1/graphql",
+                Some(json!({ "query": query })),
+            )
+            .await?
+            .unwrap_or_default();
+        if let Some(error) = body["errors"].as_array().and_then(|errors| errors.first()) {
+            bail!(
+                "Weaviate query failed: {}",
+                error["message"].as_str().unwrap_or_default()
+            );
+        }
+        let Value::Array(objects) = body["data"]["Get"][class].take() else {
+            bail!("Unexpected Weaviate query response");
+        };
+        Ok(objects
+            .into_iter()
+            .filter_map(|object| match object {
+                Value::Object(object) => Some(object),
+                _ => None,
+            })
+            .collect())
+    }
+
+    /// Create `class` when missing, or check its dimension against a stored
+    /// vector
+    async fn ensure_class(&self, class: &str, properties: &[(&str, &str)]) -> Result<()> {
+        let path = format!("/v1/schema/{}", class);
+        if self
+            .call(reqwest::Method::GET, &path, None)
+            .await?
+            .is_none()
+        {
+            info!("Creating Weaviate class {}", class);
+            self.call(
+                reqwest::Method::POST,
+                "/v1/schema",
+                Some(class_schema(class, properties)),
+            )
+            .await?;
+            return Ok(());
+        }
+
+        let arguments = Map::from_iter([("limit".to_string(), json!(1))]);
+        let objects = self
+            .get(class, get_query(class, &arguments, &[], &["id", "vector"]))
+            .await?;
+        let dimension = objects.first().map(|o| object_vector(o).len());
+        match dimension {
+            Some(dimension) if dimension != self.vector_dimension => bail!(
+                "Vector dimension mismatch: storage configured for {} dimensions, \
+                 but existing class {} has {} dimensions. \
+                 Delete the existing index or use matching embedding model.",
+                self.vector_dimension,
+                class,
+                dimension
+            ),
+            _ => Ok(()),
+        }
+    }
+
+    /// Check that `vector` has the index's dimension
+    fn check_dimension(&self, what: &str, vector: &[f32]) -> Result<()> {
+        if vector.len() != self.vector_dimension {
+            bail!(
+                "Vector dimension mismatch for {}: expected {} dimensions, got {}",
+                what,
+                self.vector_dimension,
+                vector.len()
+            );
+        }
+        Ok(())
+    }
+
+    /// A page of all objects of `class` after the object id `after`, with
+    /// the id of the last one when more may follow
+    ///
+    /// Weaviate's cursor cannot be combined with a filter, so scans of the
+    /// whole index read every object and filter them here.
+    async fn scan_page(
+        &self,
+        class: &str,
+        after: Option<String>,
+        properties: &[&str],
+        with_vector: bool,
+    ) -> Result<(Vec<Map<String, Value>>, Option<String>)> {
+        let mut arguments = Map::from_iter([("limit".to_string(), json!(PAGE))]);
+        if let Some(after) = after {
+            arguments.insert("after".to_string(), json!(after));
+        }
+        let meta: &[&str] = if with_vector {
+            &["id", "vector"]
+        } else {
+            &["id"]
+        };
+        let objects = self
+            .get(class, get_query(class, &arguments, properties, meta))
+            .await?;
+        let next = match objects.last() {
+            Some(last) if objects.len() == PAGE => {
+                additional(last, "id").as_str().map(str::to_string)
+            }
+            _ => None,
+        };
+        Ok((objects, next))
+    }
+
+    /// Every object of `class`
+    async fn scan(
+        &self,
+        class: &str,
+        properties: &[&str],
+        with_vector: bool,
+    ) -> Result<Vec<Map<String, Value>>> {
+        let mut objects = Vec::new();
+        let mut after = None;
+        loop {
+            let (page, next) = self
+                .scan_page(class, after, properties, with_vector)
+                .await?;
+            objects.extend(page);
+            match next {
+                Some(next) => after = Some(next),
+                None => return Ok(objects),
+            }
+        }
+    }
+
+    /// The chunks matching `filter`, for filters matching a bounded set
+    /// such as one file's chunks; Weaviate caps filtered results at its
+    /// `QUERY_MAXIMUM_RESULTS`
+    async fn find(&self, filter: &Where, with_vector: bool) -> Result<Vec<IndexedChunk>> {
+        let properties = property_names(CHUNK_PROPERTIES);
+        let meta: &[&str] = if with_vector {
+            &["id", "vector"]
+        } else {
+            &["id"]
+        };
+        let mut chunks = Vec::new();
+        loop {
+            let arguments = Map::from_iter([
+                ("where".to_string(), filter.to_json()),
+                ("limit".to_string(), json!(PAGE)),
+                ("offset".to_string(), json!(chunks.len())),
+            ]);
+            let page = self
+                .get(
+                    &self.class,
+                    get_query(&self.class, &arguments, &properties, meta),
+                )
+                .await?;
+            let full = page.len() == PAGE;
+            chunks.extend(page.iter().map(chunk_from_object));
+            if !full {
+                return Ok(chunks);
+            }
+        }
+    }
+
+    /// Write `objects` of `class`, replacing objects with the same ids
+    async fn batch_upsert(&self, class: &str, objects: Vec<Value>) -> Result<()> {
+        for batch in objects.chunks(PAGE) {
+            let results = self
+                .call(
+                    reqwest::Method::POST,
+                    "/v1/batch/objects",
+                    Some(json!({ "objects": batch })),
+                )
+                .await?
+                .unwrap_or_default();
+            let errors = results.as_array().into_iter().flatten().find_map(|result| {
+                result["result"]["errors"]["error"]
+                    .as_array()
+                    .and_then(|errors| errors.first())
+                    .and_then(|error| error["message"].as_str())
+            });
+            if let Some(error) = errors {
+                bail!("Weaviate rejected objects of {}: {}", class, error);
+            }
+        }
+        Ok(())
+    }
+
+    fn chunk_object(&self, chunk: &IndexedChunk) -> Value {
+        json!({
+            "class": self.class,
+            "id": object_id(&chunk.id),
+            "properties": chunk_properties(chunk),
+            "vector": chunk.vector,
+        })
+    }
+
+    /// Delete every object of `class` matching `filter`
+    async fn batch_delete(&self, class: &str, filter: &Where) -> Result<()> {
+        let body = json!({
+            "match": {"class": class, "where": filter.to_json()},
+            "output": "minimal",
+        });
+        // One request deletes at most Weaviate's QUERY_MAXIMUM_RESULTS
+        loop {
+            let response = self
+                .call(
+                    reqwest::Method::DELETE,
+                    "/v1/batch/objects",
+                    Some(body.clone()),
+                )
+                .await?
+                .unwrap_or_default();
+            let results = &response["results"];
+            let matches = results["matches"].as_u64().unwrap_or(0);
+            if results["failed"].as_u64().unwrap_or(0) > 0 {
+                bail!("Weaviate failed to delete objects of {}", class);
+            }
+            if matches <= results["limit"].as_u64().unwrap_or(matches) {
+                return Ok(());
+            }
+        }
+    }
+
+    /// Delete the chunks `doomed`, which match `filter`, and the field
+    /// vectors matching `field_filter`, promoting the duplicates left behind
+    /// (see [`promote_duplicates`])
+    async fn delete_chunks(
+        &self,
+        doomed: Vec<IndexedChunk>,
+        filter: Where,
+        field_filter: Where,
+    ) -> Result<()> {
+        let doomed_ids: HashSet<String> = doomed.iter().map(|c| c.id.clone()).collect();
+        let holders: Vec<String> = doomed
+            .into_iter()
+            .filter(|c| c.duplicate_of.is_none())
+            .map(|c| c.id)
+            .collect();
+
+        let mut duplicates = Vec::new();
+        for holders in holders.chunks(PAGE) {
+            let chunks = self
+                .find(
+                    &Where::ContainsAny {
+                        path: "duplicate_of",
+                        values: holders.to_vec(),
+                    },
+                    true,
+                )
+                .await?;
+            duplicates.extend(chunks.into_iter().filter(|c| !doomed_ids.contains(&c.id)));
+        }
+        let promoted = promote_duplicates(duplicates)
+            .iter()
+            .map(|chunk| self.chunk_object(chunk))
+            .collect();
+        self.batch_upsert(&self.class, promoted).await?;
+
+        self.batch_delete(&self.class, &filter).await?;
+        self.batch_delete(&self.fields_class, &field_filter).await
+    }
+
+    /// `path:start-end` of the duplicates of each of `ids`
+    async fn duplicate_locations(&self, ids: Vec<String>) -> Result<HashMap<String, Vec<String>>> {
+        if ids.is_empty() {
+            return Ok(HashMap::new());
+        }
+        let chunks = self
+            .find(
+                &Where::ContainsAny {
+                    path: "duplicate_of",
+                    values: ids,
+                },
+                false,
+            )
+            .await?;
+        Ok(group_duplicates(chunks.into_iter().filter_map(|chunk| {
+            Some((
+                chunk.duplicate_of?,
+                chunk.file_path,
+                chunk.start_line,
+                chunk.end_line,
+            ))
+        })))
+    }
+
+    /// Search results of the objects `hits`, scored by `score`
+    async fn results(
+        &self,
+        hits: Vec<Map<String, Value>>,
+        score: impl Fn(&Map<String, Value>) -> f32,
+    ) -> Result<Vec<SearchResult>> {
+        let chunks: Vec<(IndexedChunk, f32)> = hits
+            .iter()
+            .map(|hit| (chunk_from_object(hit), score(hit)))
+            .collect();
+        let mut duplicates = self
+            .duplicate_locations(chunks.iter().map(|(c, _)| c.id.clone()).collect())
+            .await?;
+        Ok(chunks
+            .into_iter()
+            .map(|(chunk, score)| {
+                let locations = duplicates.remove(&chunk.id).unwrap_or_default();
+                chunk_result(chunk, score, locations)
+            })
+            .collect())
+    }
+}
+
+#[async_trait]
+impl VectorStore for WeaviateStore {
+    fn backend(&self) -> &'static str {
+        WEAVIATE_BACKEND
+    }
+
+    fn vector_dimension(&self) -> usize {
+        self.vector_dimension
+    }
+
+    fn supports_hybrid_query(&self) -> bool {
+        true
+    }
+
+    async fn upsert(&self, mut chunks: Vec<IndexedChunk>) -> Result<()> {
+        if chunks.is_empty() {
+            return Ok(());
+        }
+        let ids: Vec<String> = chunks.iter().map(|c| c.id.clone()).collect();
+        for ids in ids.chunks(PAGE) {
+            let filter = Where::ContainsAny {
+                path: "chunk_id",
+                values: ids.to_vec(),
+            };
+            let doomed = self.find(&filter, false).await?;
+            self.delete_chunks(doomed, filter.clone(), filter).await?;
+        }
+
+        let mut holders = HashMap::new();
+        for unique in content_hashes(&chunks).chunks(PAGE) {
+            let stored = self
+                .find(
+                    &Where::And(vec![
+                        Where::ContainsAny {
+                            path: "content_hash",
+                            values: unique.to_vec(),
+                        },
+                        Where::IsNull {
+                            path: "duplicate_of",
+                        },
+                    ]),
+                    false,
+                )
+                .await?;
+            holders.extend(
+                stored
+                    .into_iter()
+                    .map(|chunk| (holder_key(&chunk), chunk.id)),
+            );
+        }
+        mark_duplicates(&mut chunks, holders);
+
+        let mut objects = Vec::with_capacity(chunks.len());
+        for chunk in &chunks {
+            self.check_dimension(&format!("chunk '{}'", chunk.id), &chunk.vector)?;
+            objects.push(self.chunk_object(chunk));
+        }
+        let count = objects.len();
+        self.batch_upsert(&self.class, objects).await?;
+
+        info!("Inserted {} chunks into database", count);
+        Ok(())
+    }
+
+    async fn upsert_field_vectors(
+        &self,
+        field: VectorField,
+        vectors: Vec<FieldVector>,
+    ) -> Result<()> {
+        if vectors.is_empty() {
+            return Ok(());
+        }
+        let mut objects = Vec::with_capacity(vectors.len());
+        for v in vectors {
+            self.check_dimension(&format!("{} '{}'", field.as_str(), v.text), &v.vector)?;
+            let key = format!("{}\0{}\0{}", field.as_str(), v.chunk_id, v.text);
+            let mut properties = json!({
+                "field": field.as_str(),
+                "chunk_id": v.chunk_id,
+                "file_path": v.file_path,
+                "text": v.text,
+            });
+            if let Some(branch) = v.branch {
+                properties["branch"] = json!(branch);
+            }
+            objects.push(json!({
+                "class": self.fields_class,
+                "id": Uuid::new_v5(&OBJECT_ID_NAMESPACE, key.as_bytes()).to_string(),
+                "properties": properties,
+                "vector": v.vector,
+            }));
+        }
+        self.batch_upsert(&self.fields_class, objects).await
+    }
+
+    async fn delete_file(&self, path: &Path) -> Result<()> {
+        let path_str = path.to_string_lossy().to_string();
+        let filter = Where::Equal {
+            path: "file_path",
+            value: path_str.clone(),
+        };
+        let doomed = self.find(&filter, false).await?;
+        self.delete_chunks(doomed, filter.clone(), filter).await?;
+
+        debug!("Deleted chunks for file: {}", path_str);
+        Ok(())
+    }
+
+    async fn delete_branch(&self, branch: &str) -> Result<()> {
+        // A branch may hold more chunks than a filtered query returns
+        let doomed: Vec<IndexedChunk> = self
+            .scan(&self.class, &["chunk_id", "branch", "duplicate_of"], false)
+            .await?
+            .iter()
+            .map(chunk_from_object)
+            .filter(|c| c.branch.as_deref() == Some(branch))
+            .collect();
+        let filter = Where::Equal {
+            path: "branch",
+            value: branch.to_string(),
+        };
+        self.delete_chunks(doomed, filter.clone(), filter).await?;
+
+        debug!("Deleted chunks for branch: {}", branch);
+        Ok(())
+    }
+
+    async fn query(
+        &self,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        let arguments = Map::from_iter([
+            ("nearVector".to_string(), json!({ "vector": vector })),
+            ("where".to_string(), search_where(filter).to_json()),
+            ("limit".to_string(), json!(limit)),
+        ]);
+        let hits = self
+            .get(
+                &self.class,
+                get_query(
+                    &self.class,
+                    &arguments,
+                    &property_names(CHUNK_PROPERTIES),
+                    &["id", "distance"],
+                ),
+            )
+            .await?;
+        // `l2-squared` distances are already squared
+        self.results(hits, |hit| {
+            let distance = additional(hit, "distance").as_f64().unwrap_or(0.0) as f32;
+            1.0 / (1.0 + distance)
+        })
+        .await
+    }
+
+    async fn hybrid_query(
+        &self,
+        query: &str,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+        alpha: f32,
+    ) -> Result<Option<Vec<SearchResult>>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Some(Vec::new()));
+        }
+        let arguments = Map::from_iter([
+            (
+                "hybrid".to_string(),
+                json!({
+                    "query": query,
+                    "vector": vector,
+                    "alpha": alpha,
+                    "properties": KEYWORD_PROPERTIES,
+                    "fusionType": "relativeScoreFusion",
+                }),
+            ),
+            ("where".to_string(), search_where(filter).to_json()),
+            ("limit".to_string(), json!(limit)),
+        ]);
+        let hits = self
+            .get(
+                &self.class,
+                get_query(
+                    &self.class,
+                    &arguments,
+                    &property_names(CHUNK_PROPERTIES),
+                    &["id", "score"],
+                ),
+            )
+            .await?;
+        // Relative score fusion scores are already in [0, 1]; GraphQL
+        // returns them as strings
+        let results = self
+            .results(hits, |hit| match additional(hit, "score") {
+                Value::String(score) => score.parse().unwrap_or(0.0),
+                score => score.as_f64().unwrap_or(0.0) as f32,
+            })
+            .await?;
+        Ok(Some(results))
+    }
+
+    async fn query_field(
+        &self,
+        field: VectorField,
+        vector: Vec<f32>,
+        limit: usize,
+        filter: &SearchFilter,
+    ) -> Result<Vec<SearchResult>> {
+        self.check_dimension("query", &vector)?;
+        if limit == 0 {
+            return Ok(Vec::new());
+        }
+        let mut operands = vec![Where::Equal {
+            path: "field",
+            value: field.as_str().to_string(),
+        }];
+        if let Some(branch) = &filter.branch {
+            operands.push(Where::Equal {
+                path: "branch",
+                value: branch.clone(),
+            });
+        }
+        let arguments = Map::from_iter([
+            ("nearVector".to_string(), json!({ "vector": vector })),
+            ("where".to_string(), Where::And(operands).to_json()),
+            ("limit".to_string(), json!(limit * FIELD_HITS_PER_RESULT)),
+        ]);
+        let hits = self
+            .get(
+                &self.fields_class,
+                get_query(
+                    &self.fields_class,
+                    &arguments,
+                    &["chunk_id"],
+                    &["id", "distance"],
+                ),
+            )
+            .await?;
+        // `l2-squared` distances are already squared
+        let hits = hits.iter().filter_map(|hit| {
+            let distance = additional(hit, "distance").as_f64().unwrap_or(0.0) as f32;
+            Some((prop_str(hit, "chunk_id")?, 1.0 / (1.0 + distance)))
+        });
+        field_search(hits, limit, |ids| async move {
+            let filter = Where::And(vec![
+                Where::ContainsAny {
+                    path: "chunk_id",
+                    values: ids,
+                },
+                search_where(filter),
+            ]);
+            self.find(&filter, false).await
+        })
+        .await
+    }
+
+    async fn has_field_vectors(&self, field: VectorField) -> Result<bool> {
+        let arguments = Map::from_iter([
+            (
+                "where".to_string(),
+                Where::Equal {
+                    path: "field",
+                    value: field.as_str().to_string(),
+                }
+                .to_json(),
+            ),
+            ("limit".to_string(), json!(1)),
+        ]);
+        let objects = self
+            .get(
+                &self.fields_class,
+                get_query(&self.fields_class, &arguments, &["field"], &["id"]),
+            )
+            .await?;
+        Ok(!objects.is_empty())
+    }
+
+    async fn all_chunks(&self) -> Result<Vec<IndexedChunk>> {
+        Ok(self
+            .scan(&self.class, &property_names(CHUNK_PROPERTIES), false)
+            .await?
+            .iter()
+            .map(chunk_from_object)
+            .collect())
+    }
+
+    async fn vectors_by_id(&self) -> Result<HashMap<String, Vec<f32>>> {
+        // Duplicates keep their own vector, equal to their holder's
+        Ok(self
+            .scan(&self.class, &["chunk_id"], true)
+            .await?
+            .iter()
+            .map(|object| {
+                (
+                    prop_str(object, "chunk_id").unwrap_or_default(),
+                    object_vector(object),
+                )
+            })
+            .collect())
+    }
+
+    async fn file_mtimes(&self) -> Result<HashMap<PathBuf, i64>> {
+        let objects = self
+            .scan(&self.class, &["file_path", "mtime", "branch"], false)
+            .await?;
+        Ok(latest_mtimes(objects.iter().map(chunk_from_object)))
+    }
+
+    async fn vectors_by_content_hash(&self) -> Result<HashMap<u64, Vec<f32>>> {
+        let objects = self
+            .scan(&self.class, &["content_hash", "duplicate_of"], true)
+            .await?;
+        let mut vectors = HashMap::new();
+        for object in &objects {
+            if object.get("duplicate_of").is_some_and(|v| !v.is_null()) {
+                continue;
+            }
+            let Some(hash) = prop_str(object, "content_hash")
+                .and_then(|hash| u64::from_str_radix(&hash, 16).ok())
+            else {
+                continue;
+            };
+            vectors.entry(hash).or_insert_with(|| object_vector(object));
+        }
+        Ok(vectors)
+    }
+
+    async fn stats(&self) -> Result<StoreStats> {
+        let objects = self.scan(&self.class, &["file_path"], false).await?;
+        let files: HashSet<String> = objects
+            .iter()
+            .filter_map(|object| prop_str(object, "file_path"))
+            .collect();
+        Ok(StoreStats {
+            backend: WEAVIATE_BACKEND,
+            chunks: objects.len(),
+            files: files.len(),
+            vector_dimension: self.vector_dimension,
+        })
+    }
+
+    /// Copy the classes into a single-file SQLite index at `dest`, which
+    /// the `sqlite` backend opens
+    async fn snapshot(&self, dest: &Path) -> Result<()> {
+        if dest.exists() {
+            bail!("Snapshot destination {} already exists", dest.display());
+        }
+        let snapshot = SqliteStore::open(dest, self.vector_dimension)?;
+
+        // Holders first, so each duplicate finds the chunk it shares with
+        let properties = property_names(CHUNK_PROPERTIES);
+        for holders in [true, false] {
+            let mut after = None;
+            loop {
+                let (page, next) = self
+                    .scan_page(&self.class, after, &properties, true)
+                    .await?;
+                let chunks = page
+                    .iter()
+                    .map(chunk_from_object)
+                    .filter(|c| c.duplicate_of.is_none() == holders)
+                    .collect();
+                snapshot.upsert(chunks).await?;
+                match next {
+                    Some(next) => after = Some(next),
+                    None => break,
+                }
+            }
+        }
+
+        let properties = property_names(FIELD_VECTOR_PROPERTIES);
+        let mut after = None;
+        loop {
+            let (page, next) = self
+                .scan_page(&self.fields_class, after, &properties, true)
+                .await?;
+            for field in VectorField::ALL {
+                let vectors = page
+                    .iter()
+                    .filter(|object| prop_str(object, "field").as_deref() == Some(field.as_str()))
+                    .map(|object| FieldVector {
+                        chunk_id: prop_str(object, "chunk_id").unwrap_or_default(),
+                        file_path: prop_str(object, "file_path").unwrap_or_default(),
+                        branch: prop_str(object, "branch"),
+                        text: prop_str(object, "text").unwrap_or_default(),
+                        vector: object_vector(object),
+                    })
+                    .collect();
+                snapshot.upsert_field_vectors(field, vectors).await?;
+            }
+            match next {
+                Some(next) => after = Some(next),
+                None => break,
+            }
+        }
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_search_filters_translate_to_graphql() {
+        let filter = SearchFilter {
+            kind: Some("function".to_string()),
+            tag: None,
+            meta: vec!["owner=".to_string()],
+            branch: Some("main".to_string()),
+        };
+        let arguments = Map::from_iter([("where".to_string(), search_where(&filter).to_json())]);
+        assert_eq!(
+            graphql_arguments(&arguments),
+            "where: {operands: [\
+             {operator: IsNull, path: [\"duplicate_of\"], valueBoolean: true}, \
+             {operator: Equal, path: [\"semantic_kind\"], valueText: \"function\"}, \
+             {operator: Equal, path: [\"tag_keys\"], valueText: \"owner=\"}, \
+             {operator: Equal, path: [\"branch\"], valueText: \"main\"}], \
+             operator: And}"
+        );
+    }
+
+    #[test]
+    fn test_get_queries_escape_strings() {
+        let arguments = Map::from_iter([(
+            "hybrid".to_string(),
+            json!({"query": "say \"hi\"", "fusionType": "relativeScoreFusion"}),
+        )]);
+        assert_eq!(
+            get_query("Coderag_index", &arguments, &["chunk_id"], &["score"]),
+            "{ Get { Coderag_index(hybrid: {fusionType: relativeScoreFusion, \
+             query: \"say \\\"hi\\\"\"}) { chunk_id _additional { score } } } }"
+        );
+        assert_eq!(class_name("coderag_my_service"), "Coderag_my_service");
+    }
+
+    #[test]
+    fn test_chunks_round_trip_through_properties() {
+        let chunk = IndexedChunk {
+            id: "a".to_string(),
+            content: "fn a() {}".to_string(),
+            file_path: "src/a.rs".to_string(),
+            start_line: 1,
+            end_line: 3,
+            language: Some("rust".to_string()),
+            vector: Vec::new(),
+            mtime: 10,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some("a".to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: vec!["owner=search".to_string()],
+            branch: None,
+            duplicate_of: None,
+        };
+        let properties = chunk_properties(&chunk);
+        assert_eq!(properties["tag_keys"], json!(["owner="]));
+        assert!(!properties.contains_key("branch"));
+        assert!(!properties.contains_key("duplicate_of"));
+
+        let back = chunk_from_object(&properties);
+        assert_eq!(back.id, chunk.id);
+        assert_eq!(back.language, chunk.language);
+        assert_eq!(back.tags, chunk.tags);
+        assert_eq!(back.branch, None);
+        assert_eq!((back.start_line, back.end_line, back.mtime), (1, 3, 10));
+    }
+}
@@ -0,0 +1,167 @@
+//! Symbol set comparison between indexed git refs
+//!
+//! Backs `coderag diff --branch a --branch b`. Symbols are matched by file
+//! path (relative to the ref), qualified name and kind; a symbol present in
+//! both refs with different content is reported as modified.
+
+use serde::Serialize;
+use std::collections::BTreeMap;
+
+use crate::indexer::git_ref;
+use crate::storage::{content_hash, IndexedChunk};
+
+/// How a symbol differs between two refs
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ChangeKind {
+    /// Only in the second ref
+    Added,
+    /// Only in the first ref
+    Removed,
+    /// In both refs with different content
+    Modified,
+}
+
+impl ChangeKind {
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Added => "added",
+            Self::Removed => "removed",
+            Self::Modified => "modified",
+        }
+    }
+
+    /// Marker used in the text output
+    pub fn marker(&self) -> char {
+        match self {
+            Self::Added => '+',
+            Self::Removed => '-',
+            Self::Modified => '~',
+        }
+    }
+}
+
+/// A symbol that differs between two refs
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct SymbolChange {
+    pub change: ChangeKind,
+    /// File path relative to the ref
+    pub file_path: String,
+    /// Qualified name, or the plain name when none was recorded
+    pub name: String,
+    pub kind: Option<String>,
+}
+
+type SymbolKey = (String, String, Option<String>);
+
+/// Compare the symbols indexed from refs `from` and `to`.
+///
+/// Changes are sorted by file path and name.
+pub fn diff_branches(chunks: &[IndexedChunk], from: &str, to: &str) -> Vec<SymbolChange> {
+    let before = symbols_at(chunks, from);
+    let after = symbols_at(chunks, to);
+
+    let mut changes = Vec::new();
+    for (key, hash) in &before {
+        match after.get(key) {
+            None => changes.push(change(ChangeKind::Removed, key)),
+            Some(other) if other != hash => changes.push(change(ChangeKind::Modified, key)),
+            Some(_) => {}
+        }
+    }
+    for key in after.keys().filter(|key| !before.contains_key(*key)) {
+        changes.push(change(ChangeKind::Added, key));
+    }
+
+    changes
+        .sort_by(|a, b| (&a.file_path, &a.name, a.change).cmp(&(&b.file_path, &b.name, b.change)));
+    changes
+}
+
+/// Named symbols of one ref with their content hashes
+fn symbols_at(chunks: &[IndexedChunk], branch: &str) -> BTreeMap<SymbolKey, u64> {
+    chunks
+        .iter()
+        .filter(|c| c.branch.as_deref() == Some(branch))
+        .filter_map(|c| {
+            let name = c.qualified_name.as_ref().or(c.symbol_name.as_ref())?;
+            let path = git_ref::strip_ref(&c.file_path, branch).unwrap_or(&c.file_path);
+            let key = (path.to_string(), name.clone(), c.semantic_kind.clone());
+            Some((key, content_hash(&c.content, c.language.as_deref())))
+        })
+        .collect()
+}
+
+fn change(change: ChangeKind, (file_path, name, kind): &SymbolKey) -> SymbolChange {
+    SymbolChange {
+        change,
+        file_path: file_path.clone(),
+        name: name.clone(),
+        kind: kind.clone(),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunk(branch: &str, name: &str, content: &str) -> IndexedChunk {
+        IndexedChunk {
+            id: format!("{}-{}", branch, name),
+            content: content.to_string(),
+            file_path: format!("{}:src/lib.rs", branch),
+            start_line: 1,
+            end_line: 3,
+            language: Some("rust".to_string()),
+            vector: Vec::new(),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: Some(branch.to_string()),
+            duplicate_of: None,
+        }
+    }
+
+    #[test]
+    fn test_diff_branches() {
+        let chunks = vec![
+            chunk("main", "shared", "fn shared() {}"),
+            chunk("main", "changed", "fn changed() { 1 }"),
+            chunk("main", "dropped", "fn dropped() {}"),
+            chunk("feature", "shared", "fn shared() {}"),
+            chunk("feature", "changed", "fn changed() { 2 }"),
+            chunk("feature", "added", "fn added() {}"),
+        ];
+
+        let changes: Vec<_> = diff_branches(&chunks, "main", "feature")
+            .into_iter()
+            .map(|c| (c.change, c.name))
+            .collect();
+
+        assert_eq!(
+            changes,
+            vec![
+                (ChangeKind::Added, "added".to_string()),
+                (ChangeKind::Modified, "changed".to_string()),
+                (ChangeKind::Removed, "dropped".to_string()),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_working_tree_chunks_are_ignored() {
+        let mut working = chunk("main", "local", "fn local() {}");
+        working.branch = None;
+        working.file_path = "src/lib.rs".to_string();
+
+        assert!(diff_branches(&[working], "main", "feature").is_empty());
+    }
+}
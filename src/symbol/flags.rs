@@ -0,0 +1,135 @@
+//! Feature-flag usage listing
+//!
+//! Backs `coderag flags`. Chunks tagged with a flag at index time (see
+//! [`crate::indexer::feature_flags`]) are scanned again to find the exact
+//! line of each check.
+
+use serde::Serialize;
+use std::collections::BTreeSet;
+
+use crate::indexer::feature_flags::FLAG_TAG_PREFIX;
+use crate::indexer::FlagDetector;
+use crate::storage::IndexedChunk;
+
+/// A place where a flag is checked
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct FlagLocation {
+    /// Flag name, as written in the code
+    pub flag: String,
+    pub file_path: String,
+    pub line: usize,
+    /// Symbol containing the check, when known
+    pub symbol: Option<String>,
+    /// Accessor used for the check (e.g. `flags.Enabled`)
+    pub accessor: String,
+}
+
+/// Flag checks in the working-tree chunks, sorted by flag, file and line.
+///
+/// With `flag`, only checks of that flag (case-insensitive) are returned.
+pub fn flag_locations(
+    chunks: &[IndexedChunk],
+    detector: &FlagDetector,
+    flag: Option<&str>,
+) -> Vec<FlagLocation> {
+    let mut seen = BTreeSet::new();
+    let mut locations = Vec::new();
+
+    for chunk in chunks {
+        let tagged = chunk.tags.iter().any(|t| t.starts_with(FLAG_TAG_PREFIX));
+        if !tagged || chunk.branch.is_some() {
+            continue;
+        }
+        for usage in detector.find(&chunk.content) {
+            if flag.is_some_and(|f| !f.eq_ignore_ascii_case(&usage.flag)) {
+                continue;
+            }
+            let line = chunk.start_line + usage.line - 1;
+            if !seen.insert((chunk.file_path.clone(), line, usage.flag.clone())) {
+                continue;
+            }
+            locations.push(FlagLocation {
+                flag: usage.flag,
+                file_path: chunk.file_path.clone(),
+                line,
+                symbol: chunk
+                    .qualified_name
+                    .clone()
+                    .or_else(|| chunk.symbol_name.clone()),
+                accessor: usage.accessor,
+            });
+        }
+    }
+
+    locations.sort_by(|a, b| (&a.flag, &a.file_path, a.line).cmp(&(&b.flag, &b.file_path, b.line)));
+    locations
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::indexer::feature_flags::flag_tag;
+
+    fn chunk(file_path: &str, start_line: usize, name: &str, content: &str) -> IndexedChunk {
+        IndexedChunk {
+            id: format!("{}:{}", file_path, start_line),
+            content: content.to_string(),
+            file_path: file_path.to_string(),
+            start_line,
+            end_line: start_line + content.lines().count() - 1,
+            language: Some("go".to_string()),
+            vector: Vec::new(),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: vec![flag_tag("new-checkout")],
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    #[test]
+    fn test_flag_locations() {
+        let detector = FlagDetector::new(&["flags.Enabled".to_string()]);
+        let mut on_branch = chunk("old.go", 1, "Old", "flags.Enabled(\"new-checkout\")");
+        on_branch.branch = Some("release".to_string());
+        let chunks = vec![
+            chunk(
+                "checkout.go",
+                10,
+                "Checkout",
+                "func Checkout() {\n\tif flags.Enabled(\"new-checkout\") {}\n}",
+            ),
+            chunk(
+                "banner.go",
+                3,
+                "Banner",
+                "if flags.Enabled(\"new-banner\") {}",
+            ),
+            on_branch,
+        ];
+
+        let locations = flag_locations(&chunks, &detector, None);
+        let found: Vec<(&str, &str, usize)> = locations
+            .iter()
+            .map(|l| (l.flag.as_str(), l.file_path.as_str(), l.line))
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                ("new-banner", "banner.go", 3),
+                ("new-checkout", "checkout.go", 11)
+            ]
+        );
+        assert_eq!(locations[1].symbol.as_deref(), Some("Checkout"));
+
+        let only = flag_locations(&chunks, &detector, Some("NEW-CHECKOUT"));
+        assert_eq!(only.len(), 1);
+    }
+}
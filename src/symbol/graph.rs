@@ -0,0 +1,792 @@
+//! Call and reference edges between symbols
+//!
+//! Each named chunk of the working tree is a symbol. Its code is classified
+//! with [`token_roles`]: called functions and methods give `calls` edges,
+//! type names give `references` edges, to every indexed symbol of that name.
+//! Calls made by a test (a chunk of kind `test`) give `tests` edges instead,
+//! linking each test to the code it exercises. A chunk's own name is not an edge target, so recursive calls are not
+//! recorded.
+//!
+//! Edges are stored by the file of their source symbol, together with the
+//! symbols the file defines. Reindexing a file replaces all of its edges,
+//! and [`SymbolGraph::prune`] then drops edges whose target is no longer
+//! defined anywhere, so the graph always matches the indexed code. An edge to
+//! a symbol that only appears later is added when the calling file is
+//! reindexed, or on the next full index.
+//!
+//! A function or method that a C or C++ header declares without a body
+//! gets a `declares` edge to its name, and the declaration does not count
+//! as defining it, so pruning keeps the edge only when another file (the
+//! implementation) defines the symbol.
+//!
+//! Every symbol is also recorded as a node with its kind, language and
+//! location. With `indexer.counterparts` set, same-named exported types in different languages (a Go `Task`
+//! struct and a TypeScript `Task` interface) are linked by `corresponds`
+//! edges in both directions, so contracts across a frontend/backend
+//! boundary can be found from either side. Names match exactly, or ignoring
+//! case and separators with `normalized`.
+//!
+//! The graph is saved as `graph.json` in the storage directory. A full index
+//! rebuilds it from all chunks; the watcher updates it file by file.
+
+use anyhow::Result;
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, BTreeSet, HashSet};
+use std::fs;
+use std::path::Path;
+
+use crate::indexer::ast_chunker::parser_pool::ParserPool;
+use crate::indexer::ast_chunker::token_roles::{token_roles, TokenRole};
+use crate::indexer::ast_chunker::{ExtractorRegistry, SemanticKind, SemanticUnit};
+use crate::storage::IndexedChunk;
+
+/// Graph file name within the storage directory
+pub const GRAPH_FILE: &str = "graph.json";
+
+/// What an edge records
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum EdgeKind {
+    /// The source calls the target function or method
+    Calls,
+    /// The source is a test calling the target function or method
+    Tests,
+    /// The source names the target type
+    References,
+    /// The source and target are same-named exported types in different
+    /// languages
+    Corresponds,
+    /// The source is a header declaration of the target function or method,
+    /// defined in another file
+    Declares,
+}
+
+impl EdgeKind {
+    /// Parse edge kind from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "calls" => Some(Self::Calls),
+            "tests" => Some(Self::Tests),
+            "references" => Some(Self::References),
+            "corresponds" => Some(Self::Corresponds),
+            "declares" => Some(Self::Declares),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Calls => "calls",
+            Self::Tests => "tests",
+            Self::References => "references",
+            Self::Corresponds => "corresponds",
+            Self::Declares => "declares",
+        }
+    }
+}
+
+/// An edge from a symbol to a symbol it uses
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+pub struct Edge {
+    /// Qualified name of the source symbol when known, else its bare name
+    pub source: String,
+    /// Bare name of the target symbol
+    pub target: String,
+    pub kind: EdgeKind,
+    /// File of the source symbol
+    pub file: String,
+    /// First line of the source symbol
+    pub line: usize,
+}
+
+/// How exported types are matched across languages
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "kebab-case")]
+pub enum CounterpartMatch {
+    /// No cross-language links
+    #[default]
+    Off,
+    /// Identical names (`Task` and `Task`)
+    Exact,
+    /// Names equal ignoring case, `_` and `-` (`task_item` and `TaskItem`)
+    Normalized,
+}
+
+impl CounterpartMatch {
+    /// Parse matching rule from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "off" => Some(Self::Off),
+            "exact" => Some(Self::Exact),
+            "normalized" => Some(Self::Normalized),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Off => "off",
+            Self::Exact => "exact",
+            Self::Normalized => "normalized",
+        }
+    }
+
+    /// Key that linked names share, or `None` when linking is off
+    fn key(&self, name: &str) -> Option<String> {
+        match self {
+            Self::Off => None,
+            Self::Exact => Some(name.to_string()),
+            Self::Normalized => Some(
+                name.chars()
+                    .filter(|c| !matches!(c, '_' | '-'))
+                    .flat_map(char::to_lowercase)
+                    .collect(),
+            ),
+        }
+    }
+}
+
+/// Semantic kinds of type definitions
+const TYPE_KINDS: &[SemanticKind] = &[
+    SemanticKind::Struct,
+    SemanticKind::Class,
+    SemanticKind::Trait,
+    SemanticKind::Interface,
+    SemanticKind::Enum,
+    SemanticKind::TypeAlias,
+];
+
+/// A symbol definition
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+pub struct SymbolNode {
+    /// Bare name
+    pub name: String,
+    pub qualified_name: Option<String>,
+    /// Semantic kind (e.g. `function`, `struct`)
+    pub kind: Option<String>,
+    pub language: Option<String>,
+    pub file: String,
+    /// First line of the symbol's chunk
+    pub line: usize,
+    /// Whether the language's visibility rules export the symbol
+    pub exported: bool,
+}
+
+impl SymbolNode {
+    /// Whether the node is a type its language exports
+    pub fn is_exported_type(&self) -> bool {
+        self.exported
+            && self
+                .kind
+                .as_deref()
+                .and_then(SemanticKind::parse)
+                .is_some_and(|kind| TYPE_KINDS.contains(&kind))
+    }
+}
+
+/// Symbols and outgoing edges of one file
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+struct FileGraph {
+    /// Bare names of the symbols the file defines
+    symbols: BTreeSet<String>,
+    edges: Vec<Edge>,
+    /// The file's symbols, in chunk order
+    #[serde(default)]
+    nodes: Vec<SymbolNode>,
+}
+
+/// Call and reference edges of the indexed symbols, by source file
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct SymbolGraph {
+    files: BTreeMap<String, FileGraph>,
+    /// `corresponds` edges between types of different files
+    #[serde(default)]
+    links: Vec<Edge>,
+}
+
+impl SymbolGraph {
+    /// Graph of all working-tree chunks, without cross-language links
+    /// (see [`link_counterparts`](Self::link_counterparts))
+    pub fn build(chunks: &[IndexedChunk]) -> Self {
+        let mut by_file: BTreeMap<&str, Vec<&IndexedChunk>> = BTreeMap::new();
+        for chunk in chunks.iter().filter(|c| c.branch.is_none()) {
+            by_file.entry(&chunk.file_path).or_default().push(chunk);
+        }
+
+        let mut parsers = ParserPool::new();
+        let mut graph = Self::default();
+        for (file, chunks) in by_file {
+            graph
+                .files
+                .insert(file.to_string(), file_graph(&mut parsers, chunks));
+        }
+        graph.prune();
+        graph
+    }
+
+    /// Replace everything recorded for `file` with the symbols and edges of
+    /// its freshly indexed chunks.
+    ///
+    /// Call [`prune`](Self::prune) once a batch of files is updated.
+    pub fn replace_file(&mut self, parsers: &mut ParserPool, file: &str, chunks: &[IndexedChunk]) {
+        let graph = file_graph(parsers, chunks.iter().filter(|c| c.branch.is_none()));
+        if graph.symbols.is_empty() && graph.edges.is_empty() && graph.nodes.is_empty() {
+            self.files.remove(file);
+        } else {
+            self.files.insert(file.to_string(), graph);
+        }
+    }
+
+    /// Forget a deleted file's symbols and edges
+    pub fn remove_file(&mut self, file: &str) {
+        self.files.remove(file);
+    }
+
+    /// Drop edges whose target is not defined in any file.
+    ///
+    /// Returns the number of edges dropped.
+    pub fn prune(&mut self) -> usize {
+        let defined: HashSet<String> = self
+            .files
+            .values()
+            .flat_map(|f| f.symbols.iter().cloned())
+            .collect();
+        let mut dropped = 0;
+        for graph in self.files.values_mut() {
+            let before = graph.edges.len();
+            graph.edges.retain(|edge| defined.contains(&edge.target));
+            dropped += before - graph.edges.len();
+        }
+        dropped
+    }
+
+    /// Keep only the edges, `corresponds` links included, for which `keep`
+    /// returns true.
+    ///
+    /// Returns the number of edges dropped.
+    pub fn retain_edges(&mut self, mut keep: impl FnMut(&Edge) -> bool) -> usize {
+        let before = self.len();
+        for graph in self.files.values_mut() {
+            graph.edges.retain(|edge| keep(edge));
+        }
+        self.links.retain(|edge| keep(edge));
+        before - self.len()
+    }
+
+    /// Replace the `corresponds` edges with links between the exported
+    /// types of different languages whose names match under `rule`.
+    ///
+    /// Returns the number of edges.
+    pub fn link_counterparts(&mut self, rule: CounterpartMatch) -> usize {
+        let mut groups: BTreeMap<String, Vec<&SymbolNode>> = BTreeMap::new();
+        for ty in self.types() {
+            if let Some(key) = rule.key(&ty.name) {
+                groups.entry(key).or_default().push(ty);
+            }
+        }
+
+        let mut links = Vec::new();
+        for group in groups.values() {
+            for source in group {
+                for target in group.iter().filter(|t| t.language != source.language) {
+                    links.push(Edge {
+                        source: source.name.clone(),
+                        target: target.name.clone(),
+                        kind: EdgeKind::Corresponds,
+                        file: source.file.clone(),
+                        line: source.line,
+                    });
+                }
+            }
+        }
+        self.links = links;
+        self.links.len()
+    }
+
+    /// Every definition linked with the exported type `name`, its own
+    /// included, ordered by file; empty when it has no counterpart
+    pub fn counterparts(&self, name: &str) -> Vec<&SymbolNode> {
+        // Links run both ways, so their sources are the whole group
+        let linked: HashSet<(&str, &str, usize)> = self
+            .links
+            .iter()
+            .filter(|l| l.source == name || l.target == name)
+            .map(|l| (l.source.as_str(), l.file.as_str(), l.line))
+            .collect();
+        self.types()
+            .filter(|t| linked.contains(&(t.name.as_str(), t.file.as_str(), t.line)))
+            .collect()
+    }
+
+    /// Files with recorded symbols or edges
+    pub fn files(&self) -> impl Iterator<Item = &str> {
+        self.files.keys().map(String::as_str)
+    }
+
+    /// Bare names of the symbols `file` defines; header declarations are not
+    /// definitions
+    pub fn file_symbols(&self, file: &str) -> &BTreeSet<String> {
+        static EMPTY: BTreeSet<String> = BTreeSet::new();
+        self.files.get(file).map_or(&EMPTY, |f| &f.symbols)
+    }
+
+    /// All symbols, by file
+    pub fn nodes(&self) -> impl Iterator<Item = &SymbolNode> {
+        self.files.values().flat_map(|f| f.nodes.iter())
+    }
+
+    /// Symbols defined in `file`
+    pub fn file_nodes(&self, file: &str) -> &[SymbolNode] {
+        self.files
+            .get(file)
+            .map(|f| f.nodes.as_slice())
+            .unwrap_or(&[])
+    }
+
+    /// Exported types, by file
+    pub fn types(&self) -> impl Iterator<Item = &SymbolNode> {
+        self.nodes().filter(|n| n.is_exported_type())
+    }
+
+    /// All edges, by source file, then the `corresponds` edges
+    pub fn edges(&self) -> impl Iterator<Item = &Edge> {
+        self.files
+            .values()
+            .flat_map(|f| f.edges.iter())
+            .chain(self.links.iter())
+    }
+
+    /// Edges originating from `file`
+    pub fn file_edges(&self, file: &str) -> &[Edge] {
+        self.files
+            .get(file)
+            .map(|f| f.edges.as_slice())
+            .unwrap_or(&[])
+    }
+
+    /// Number of edges
+    pub fn len(&self) -> usize {
+        self.files.values().map(|f| f.edges.len()).sum::<usize>() + self.links.len()
+    }
+
+    /// Whether the graph has no edges
+    pub fn is_empty(&self) -> bool {
+        self.len() == 0
+    }
+
+    /// Load the graph from a storage directory; missing files load empty
+    pub fn load(storage_dir: &Path) -> Result<Self> {
+        let path = storage_dir.join(GRAPH_FILE);
+        if !path.exists() {
+            return Ok(Self::default());
+        }
+        Ok(serde_json::from_str(&fs::read_to_string(path)?)?)
+    }
+
+    /// Save the graph to a storage directory, removing it when empty
+    pub fn save(&self, storage_dir: &Path) -> Result<()> {
+        let path = storage_dir.join(GRAPH_FILE);
+        if self.files.is_empty() {
+            if path.exists() {
+                fs::remove_file(path)?;
+            }
+            return Ok(());
+        }
+        fs::create_dir_all(storage_dir)?;
+        fs::write(path, serde_json::to_string(self)?)?;
+        Ok(())
+    }
+}
+
+/// Symbols and candidate edges of one file's chunks.
+///
+/// Edges are not resolved yet: any callee or type name is a candidate, and
+/// pruning keeps those naming an indexed symbol.
+fn file_graph<'a>(
+    parsers: &mut ParserPool,
+    chunks: impl IntoIterator<Item = &'a IndexedChunk>,
+) -> FileGraph {
+    let mut symbols = BTreeSet::new();
+    let mut edges = BTreeSet::new();
+    let mut nodes = Vec::new();
+    let extractors = ExtractorRegistry::new();
+
+    for chunk in chunks {
+        let Some(name) = &chunk.symbol_name else {
+            continue;
+        };
+        nodes.push(SymbolNode {
+            name: name.clone(),
+            qualified_name: chunk.qualified_name.clone(),
+            kind: chunk.semantic_kind.clone(),
+            language: chunk.language.clone(),
+            file: chunk.file_path.clone(),
+            line: chunk.start_line,
+            exported: is_exported(&extractors, chunk),
+        });
+        let Some(language) = &chunk.language else {
+            symbols.insert(name.clone());
+            continue;
+        };
+        let source = chunk.qualified_name.as_ref().unwrap_or(name);
+        let is_test = chunk.semantic_kind.as_deref() == Some("test");
+
+        let grammar = ParserPool::grammar(Path::new(&chunk.file_path), language);
+        let declarations = header_declarations(parsers, &extractors, grammar, chunk);
+        if !declarations.iter().any(|(_, target)| target == name) {
+            symbols.insert(name.clone());
+        }
+        for (declaration, target) in declarations {
+            edges.insert(Edge {
+                source: declaration,
+                target,
+                kind: EdgeKind::Declares,
+                file: chunk.file_path.clone(),
+                line: chunk.start_line,
+            });
+        }
+        for (role, target) in token_roles(parsers, grammar, &chunk.content) {
+            let kind = match role {
+                TokenRole::Function if is_test => EdgeKind::Tests,
+                TokenRole::Function => EdgeKind::Calls,
+                TokenRole::Type => EdgeKind::References,
+                TokenRole::Field | TokenRole::Local => continue,
+            };
+            if &target == name {
+                continue;
+            }
+            edges.insert(Edge {
+                source: source.clone(),
+                target,
+                kind,
+                file: chunk.file_path.clone(),
+                line: chunk.start_line,
+            });
+        }
+    }
+
+    FileGraph {
+        symbols,
+        edges: edges.into_iter().collect(),
+        nodes,
+    }
+}
+
+/// Qualified and bare name of each function or method a C or C++ header
+/// chunk declares without a body
+fn header_declarations(
+    parsers: &mut ParserPool,
+    extractors: &ExtractorRegistry,
+    grammar: &str,
+    chunk: &IndexedChunk,
+) -> Vec<(String, String)> {
+    if !ParserPool::is_header(Path::new(&chunk.file_path)) {
+        return Vec::new();
+    }
+    let Some(extractor) = chunk.language.as_deref().and_then(|l| extractors.get(l)) else {
+        return Vec::new();
+    };
+    let Some(tree) = parsers
+        .get_parser(grammar)
+        .and_then(|parser| parser.parse(chunk.content.as_bytes(), None))
+    else {
+        return Vec::new();
+    };
+
+    extractor
+        .extract(&tree, chunk.content.as_bytes())
+        .into_iter()
+        .filter(|unit| matches!(unit.kind, SemanticKind::Function | SemanticKind::Method))
+        .filter(|unit| !unit.content.contains('{'))
+        .filter_map(|unit| {
+            let name = unit.name?;
+            // Parsed alone, a method declaration has lost its class; the
+            // chunk's own qualified name still has it
+            let is_chunk = unit.start_line == 1 && chunk.symbol_name.as_ref() == Some(&name);
+            let qualified = match &chunk.qualified_name {
+                Some(qualified) if is_chunk => qualified.clone(),
+                _ => extractor.qualified_name(None, unit.parent.as_deref(), &name),
+            };
+            Some((qualified, name))
+        })
+        .collect()
+}
+
+/// Whether a chunk's symbol is exported by its language's visibility rules
+/// (capitalized in Go, `export`ed in TypeScript, `pub` in Rust, ...)
+fn is_exported(extractors: &ExtractorRegistry, chunk: &IndexedChunk) -> bool {
+    let Some(kind) = chunk.semantic_kind.as_deref().and_then(SemanticKind::parse) else {
+        return false;
+    };
+    let Some(extractor) = chunk.language.as_deref().and_then(|l| extractors.get(l)) else {
+        return false;
+    };
+    extractor.is_exported(&SemanticUnit {
+        kind,
+        name: chunk.symbol_name.clone(),
+        content: chunk.content.clone(),
+        docs: None,
+        start_line: chunk.start_line,
+        end_line: chunk.end_line,
+        start_byte: 0,
+        end_byte: chunk.content.len(),
+        signature: chunk.signature.clone(),
+        parent: chunk.parent.clone(),
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::tempdir;
+
+    fn chunk(file_path: &str, start_line: usize, name: &str, content: &str) -> IndexedChunk {
+        IndexedChunk {
+            id: format!("{}:{}", file_path, start_line),
+            content: content.to_string(),
+            file_path: file_path.to_string(),
+            start_line,
+            end_line: start_line + content.lines().count() - 1,
+            language: Some("go".to_string()),
+            vector: Vec::new(),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    fn type_chunk(file_path: &str, kind: &str, content: &str) -> IndexedChunk {
+        let language = if file_path.ends_with(".ts") {
+            "typescript"
+        } else {
+            "go"
+        };
+        let name = content
+            .split_whitespace()
+            .find(|word| !matches!(*word, "export" | "pub" | "type" | "struct" | "interface"))
+            .unwrap();
+        IndexedChunk {
+            language: Some(language.to_string()),
+            semantic_kind: Some(kind.to_string()),
+            ..chunk(file_path, 1, name, content)
+        }
+    }
+
+    fn edges(graph: &SymbolGraph) -> Vec<(&str, &str, EdgeKind)> {
+        graph
+            .edges()
+            .map(|e| (e.source.as_str(), e.target.as_str(), e.kind))
+            .collect()
+    }
+
+    fn library() -> Vec<IndexedChunk> {
+        vec![
+            chunk("pool.go", 1, "Pool", "type Pool struct {\n\tsize int\n}"),
+            chunk(
+                "pool.go",
+                5,
+                "acquire",
+                "func acquire(n int) *Pool {\n\treturn &Pool{size: n}\n}",
+            ),
+            chunk(
+                "pool.go",
+                9,
+                "release",
+                "func release(p *Pool) {\n\tp.Close()\n}",
+            ),
+            chunk("pool.go", 13, "Close", "func (p *Pool) Close() {}"),
+        ]
+    }
+
+    #[test]
+    fn test_reindexed_file_replaces_its_edges() {
+        let mut chunks = library();
+        chunks.push(chunk(
+            "main.go",
+            1,
+            "run",
+            "func run() {\n\tp := acquire(4)\n\tacquire(8)\n\tfmt.Println(p)\n}",
+        ));
+        let mut graph = SymbolGraph::build(&chunks);
+        // Called twice, one edge; calls outside the index are not edges
+        assert_eq!(
+            graph
+                .file_edges("main.go")
+                .iter()
+                .map(|e| e.target.as_str())
+                .collect::<Vec<_>>(),
+            vec!["acquire"]
+        );
+
+        // Edit run to release the pool instead
+        let edited = chunk(
+            "main.go",
+            1,
+            "run",
+            "func run() {\n\tp := acquire(4)\n\trelease(p)\n}",
+        );
+        let mut parsers = ParserPool::new();
+        graph.replace_file(&mut parsers, "main.go", &[edited.clone()]);
+        graph.replace_file(&mut parsers, "main.go", &[edited]);
+        graph.prune();
+        assert_eq!(
+            edges(&graph),
+            vec![
+                ("run", "acquire", EdgeKind::Calls),
+                ("run", "release", EdgeKind::Calls),
+                ("Close", "Pool", EdgeKind::References),
+                ("acquire", "Pool", EdgeKind::References),
+                ("release", "Close", EdgeKind::Calls),
+                ("release", "Pool", EdgeKind::References),
+            ]
+        );
+
+        // Deleting release drops the edges from and to it
+        let mut library = library();
+        library.remove(2);
+        graph.replace_file(&mut parsers, "pool.go", &library);
+        assert_eq!(graph.prune(), 1);
+        assert_eq!(
+            graph
+                .file_edges("main.go")
+                .iter()
+                .map(|e| e.target.as_str())
+                .collect::<Vec<_>>(),
+            vec!["acquire"]
+        );
+        assert!(!edges(&graph)
+            .iter()
+            .any(|(source, _, _)| *source == "release"));
+    }
+
+    #[test]
+    fn test_graph_round_trip() {
+        let mut graph = SymbolGraph::build(&library());
+        assert!(!graph.is_empty());
+        let dir = tempdir().unwrap();
+        graph.save(dir.path()).unwrap();
+        assert_eq!(SymbolGraph::load(dir.path()).unwrap(), graph);
+
+        graph.remove_file("pool.go");
+        assert!(graph.is_empty());
+        graph.save(dir.path()).unwrap();
+        assert!(!dir.path().join(GRAPH_FILE).exists());
+        assert_eq!(
+            SymbolGraph::load(dir.path()).unwrap(),
+            SymbolGraph::default()
+        );
+    }
+
+    #[test]
+    fn test_counterparts_link_exported_types_across_languages() {
+        let chunks = vec![
+            type_chunk("api/task.go", "struct", "Task struct {\n\tID string\n}"),
+            type_chunk("api/task.go", "type_alias", "TaskId string"),
+            type_chunk("api/queue.go", "struct", "queue struct{}"),
+            type_chunk("api/status.go", "struct", "Task struct{}"),
+            type_chunk("web/task.ts", "interface", "export interface Task {}"),
+            type_chunk("web/ids.ts", "type_alias", "export type TaskID = string;"),
+            type_chunk("web/queue.ts", "interface", "interface queue {}"),
+        ];
+        let mut graph = SymbolGraph::build(&chunks);
+        let located = |types: Vec<&SymbolNode>| {
+            types
+                .into_iter()
+                .map(|t| (t.file.clone(), t.language.clone().unwrap()))
+                .collect::<Vec<_>>()
+        };
+
+        assert_eq!(graph.link_counterparts(CounterpartMatch::Off), 0);
+        assert!(graph.counterparts("Task").is_empty());
+
+        // Both Go definitions link with the TypeScript one, never each other
+        assert_eq!(graph.link_counterparts(CounterpartMatch::Exact), 4);
+        assert_eq!(
+            located(graph.counterparts("Task")),
+            vec![
+                ("api/status.go".to_string(), "go".to_string()),
+                ("api/task.go".to_string(), "go".to_string()),
+                ("web/task.ts".to_string(), "typescript".to_string()),
+            ]
+        );
+        assert!(graph.counterparts("TaskId").is_empty());
+        // Unexported types are never linked
+        assert!(graph.counterparts("queue").is_empty());
+
+        graph.link_counterparts(CounterpartMatch::Normalized);
+        assert_eq!(
+            located(graph.counterparts("TaskID")),
+            vec![
+                ("api/task.go".to_string(), "go".to_string()),
+                ("web/ids.ts".to_string(), "typescript".to_string()),
+            ]
+        );
+        assert!(edges(&graph).contains(&("TaskId", "TaskID", EdgeKind::Corresponds)));
+    }
+
+    #[test]
+    fn test_header_declarations_link_to_their_definitions() {
+        let c_chunk = |file: &str, line: usize, name: &str, content: &str| IndexedChunk {
+            language: Some("c".to_string()),
+            ..chunk(file, line, name, content)
+        };
+        let header = c_chunk(
+            "include/pool.h",
+            3,
+            "pool_create",
+            "struct pool *pool_create(int size);\nvoid pool_trace(int conn);",
+        );
+        let source = c_chunk(
+            "src/pool.c",
+            5,
+            "pool_create",
+            "struct pool *pool_create(int size) {\n\treturn NULL;\n}",
+        );
+        let declares = |graph: &SymbolGraph| {
+            graph
+                .edges()
+                .filter(|e| e.kind == EdgeKind::Declares)
+                .map(|e| (e.source.clone(), e.file.clone(), e.line))
+                .collect::<Vec<_>>()
+        };
+
+        // pool_trace is defined nowhere, so its declaration links to nothing
+        let graph = SymbolGraph::build(&[header.clone(), source]);
+        assert_eq!(
+            declares(&graph),
+            vec![("pool_create".to_string(), "include/pool.h".to_string(), 3)]
+        );
+
+        // The declaration alone does not define the function
+        assert!(declares(&SymbolGraph::build(&[header])).is_empty());
+    }
+
+    #[test]
+    fn test_edge_kind_names() {
+        assert_eq!(
+            CounterpartMatch::parse("Normalized"),
+            Some(CounterpartMatch::Normalized)
+        );
+        for kind in [
+            EdgeKind::Calls,
+            EdgeKind::Tests,
+            EdgeKind::References,
+            EdgeKind::Corresponds,
+            EdgeKind::Declares,
+        ] {
+            assert_eq!(EdgeKind::parse(kind.as_str()), Some(kind));
+        }
+        assert_eq!(EdgeKind::parse("imports"), None);
+    }
+}
@@ -0,0 +1,430 @@
+//! Symbol graph export
+//!
+//! Serializes the [`SymbolGraph`] for graph tools: DOT for Graphviz,
+//! GraphML for Gephi and yEd, and JSON. Nodes are the indexed symbols,
+//! identified as `<file>:<line>` and carrying their name, kind, language
+//! and location. Edges carry their kind.
+//!
+//! Graph edges name their target by bare name; the export connects calls
+//! and references to every symbol of that name in the source's language,
+//! `corresponds` edges to the exported types of other languages, and
+//! `declares` edges to the C or C++ definitions outside headers. Filters
+//! select the nodes by path and language and the edges by kind; edges to a
+//! node that is filtered out are dropped.
+
+use anyhow::Result;
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeSet, HashMap};
+use std::path::Path;
+
+use super::graph::{EdgeKind, SymbolGraph, SymbolNode};
+use crate::indexer::ast_chunker::parser_pool::ParserPool;
+use crate::search::escape_html;
+
+/// Graph file format
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum GraphFormat {
+    /// Graphviz DOT
+    Dot,
+    /// GraphML XML
+    Graphml,
+    /// `{ "nodes": [...], "edges": [...] }`
+    #[default]
+    Json,
+}
+
+impl GraphFormat {
+    /// Parse format from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "dot" => Some(Self::Dot),
+            "graphml" => Some(Self::Graphml),
+            "json" => Some(Self::Json),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Dot => "dot",
+            Self::Graphml => "graphml",
+            Self::Json => "json",
+        }
+    }
+}
+
+/// Which part of the graph to export
+#[derive(Debug, Clone, Default)]
+pub struct GraphFilter {
+    /// Only symbols in files under this path
+    pub path: Option<String>,
+    /// Only symbols in this language
+    pub language: Option<String>,
+    /// Only edges of these kinds; all kinds when empty
+    pub kinds: Vec<EdgeKind>,
+}
+
+impl GraphFilter {
+    /// Whether `node` is under the path and in the language
+    pub fn keeps_node(&self, node: &SymbolNode) -> bool {
+        let in_path = self.path.as_deref().map_or(true, |path| {
+            let path = path.trim_start_matches("./").trim_end_matches('/');
+            node.file == path || node.file.starts_with(&format!("{}/", path)) || path.is_empty()
+        });
+        let in_language = self.language.as_deref().map_or(true, |language| {
+            node.language
+                .as_deref()
+                .is_some_and(|l| l.eq_ignore_ascii_case(language))
+        });
+        in_path && in_language
+    }
+
+    fn keeps_edge(&self, kind: EdgeKind) -> bool {
+        self.kinds.is_empty() || self.kinds.contains(&kind)
+    }
+}
+
+/// An exported symbol
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ExportNode {
+    /// `<file>:<line>`
+    pub id: String,
+    #[serde(flatten)]
+    pub symbol: SymbolNode,
+}
+
+/// An exported edge between node ids
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+pub struct ExportEdge {
+    pub source: String,
+    pub target: String,
+    pub kind: EdgeKind,
+}
+
+/// Nodes and resolved edges of a (filtered) symbol graph
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GraphExport {
+    pub nodes: Vec<ExportNode>,
+    pub edges: Vec<ExportEdge>,
+}
+
+impl GraphExport {
+    /// The part of `graph` selected by `filter`
+    pub fn new(graph: &SymbolGraph, filter: &GraphFilter) -> Self {
+        let nodes: Vec<&SymbolNode> = graph.nodes().filter(|n| filter.keeps_node(n)).collect();
+        let mut by_name: HashMap<&str, Vec<&SymbolNode>> = HashMap::new();
+        let mut by_id: HashMap<String, &SymbolNode> = HashMap::new();
+        for &node in &nodes {
+            by_name.entry(node.name.as_str()).or_default().push(node);
+            by_id.insert(node_id(&node.file, node.line), node);
+        }
+
+        let mut edges = BTreeSet::new();
+        for edge in graph.edges().filter(|e| filter.keeps_edge(e.kind)) {
+            let source = node_id(&edge.file, edge.line);
+            let Some(source_node) = by_id.get(&source) else {
+                continue;
+            };
+            for target in by_name.get(edge.target.as_str()).into_iter().flatten() {
+                let same_language = target.language == source_node.language;
+                let linked = match edge.kind {
+                    EdgeKind::Calls | EdgeKind::Tests | EdgeKind::References => same_language,
+                    EdgeKind::Corresponds => target.is_exported_type() && !same_language,
+                    EdgeKind::Declares => {
+                        matches!(target.language.as_deref(), Some("c" | "cpp"))
+                            && !ParserPool::is_header(Path::new(&target.file))
+                    }
+                };
+                let target = node_id(&target.file, target.line);
+                if linked && target != source {
+                    edges.insert(ExportEdge {
+                        source: source.clone(),
+                        target,
+                        kind: edge.kind,
+                    });
+                }
+            }
+        }
+
+        Self {
+            nodes: nodes
+                .into_iter()
+                .map(|node| ExportNode {
+                    id: node_id(&node.file, node.line),
+                    symbol: node.clone(),
+                })
+                .collect(),
+            edges: edges.into_iter().collect(),
+        }
+    }
+
+    /// Parse an export written as JSON
+    pub fn from_json(json: &str) -> Result<Self> {
+        Ok(serde_json::from_str(json)?)
+    }
+
+    /// The graph in `format`
+    pub fn render(&self, format: GraphFormat) -> Result<String> {
+        match format {
+            GraphFormat::Dot => Ok(self.to_dot()),
+            GraphFormat::Graphml => Ok(self.to_graphml()),
+            GraphFormat::Json => Ok(serde_json::to_string_pretty(self)?),
+        }
+    }
+
+    /// Graphviz DOT, with the metadata as node and edge attributes
+    pub fn to_dot(&self) -> String {
+        let mut dot = String::from("digraph symbols {\n");
+        for node in &self.nodes {
+            let symbol = &node.symbol;
+            dot.push_str(&format!(
+                "  {} [label={}, kind={}, language={}, file={}, line={}, exported={}];\n",
+                dot_string(&node.id),
+                dot_string(&symbol.name),
+                dot_string(symbol.kind.as_deref().unwrap_or_default()),
+                dot_string(symbol.language.as_deref().unwrap_or_default()),
+                dot_string(&symbol.file),
+                symbol.line,
+                symbol.exported
+            ));
+        }
+        for edge in &self.edges {
+            dot.push_str(&format!(
+                "  {} -> {} [kind={}];\n",
+                dot_string(&edge.source),
+                dot_string(&edge.target),
+                edge.kind.as_str()
+            ));
+        }
+        dot.push_str("}\n");
+        dot
+    }
+
+    /// GraphML, with the metadata as typed `<data>` keys
+    pub fn to_graphml(&self) -> String {
+        let mut xml = String::from(
+            "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n\
+             <graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n",
+        );
+        for (key, target, ty) in [
+            ("name", "node", "string"),
+            ("qualified_name", "node", "string"),
+            ("kind", "node", "string"),
+            ("language", "node", "string"),
+            ("file", "node", "string"),
+            ("line", "node", "int"),
+            ("exported", "node", "boolean"),
+            ("edge_kind", "edge", "string"),
+        ] {
+            xml.push_str(&format!(
+                "  <key id=\"{0}\" for=\"{1}\" attr.name=\"{0}\" attr.type=\"{2}\"/>\n",
+                key, target, ty
+            ));
+        }
+        xml.push_str("  <graph id=\"symbols\" edgedefault=\"directed\">\n");
+        for node in &self.nodes {
+            let symbol = &node.symbol;
+            xml.push_str(&format!("    <node id=\"{}\">\n", escape_html(&node.id)));
+            let data = [
+                ("name", Some(symbol.name.clone())),
+                ("qualified_name", symbol.qualified_name.clone()),
+                ("kind", symbol.kind.clone()),
+                ("language", symbol.language.clone()),
+                ("file", Some(symbol.file.clone())),
+                ("line", Some(symbol.line.to_string())),
+                ("exported", Some(symbol.exported.to_string())),
+            ];
+            for (key, value) in data {
+                if let Some(value) = value {
+                    xml.push_str(&format!(
+                        "      <data key=\"{}\">{}</data>\n",
+                        key,
+                        escape_html(&value)
+                    ));
+                }
+            }
+            xml.push_str("    </node>\n");
+        }
+        for edge in &self.edges {
+            xml.push_str(&format!(
+                "    <edge source=\"{}\" target=\"{}\"><data key=\"edge_kind\">{}</data></edge>\n",
+                escape_html(&edge.source),
+                escape_html(&edge.target),
+                edge.kind.as_str()
+            ));
+        }
+        xml.push_str("  </graph>\n</graphml>\n");
+        xml
+    }
+}
+
+/// Id of the node of the symbol starting at `line` of `file`
+fn node_id(file: &str, line: usize) -> String {
+    format!("{}:{}", file, line)
+}
+
+/// Quoted DOT string
+fn dot_string(text: &str) -> String {
+    format!("\"{}\"", text.replace('\\', "\\\\").replace('"', "\\\""))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::storage::IndexedChunk;
+    use crate::symbol::CounterpartMatch;
+
+    fn chunk(
+        file_path: &str,
+        start_line: usize,
+        kind: &str,
+        name: &str,
+        content: &str,
+    ) -> IndexedChunk {
+        let language = if file_path.ends_with(".ts") {
+            "typescript"
+        } else {
+            "go"
+        };
+        IndexedChunk {
+            id: format!("{}:{}", file_path, start_line),
+            content: content.to_string(),
+            file_path: file_path.to_string(),
+            start_line,
+            end_line: start_line + content.lines().count() - 1,
+            language: Some(language.to_string()),
+            vector: Vec::new(),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some(kind.to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    /// A Go pool, a Go caller and a TypeScript mirror of the pool type
+    fn graph() -> SymbolGraph {
+        let mut graph = SymbolGraph::build(&[
+            chunk(
+                "pool/pool.go",
+                1,
+                "struct",
+                "Pool",
+                "type Pool struct {\n\tsize int\n}",
+            ),
+            chunk(
+                "pool/pool.go",
+                5,
+                "function",
+                "acquire",
+                "func acquire(n int) *Pool {\n\treturn &Pool{size: n}\n}",
+            ),
+            chunk(
+                "cmd/main.go",
+                1,
+                "function",
+                "run",
+                "func run() {\n\tacquire(4)\n}",
+            ),
+            chunk(
+                "web/pool.ts",
+                1,
+                "interface",
+                "Pool",
+                "export interface Pool {}",
+            ),
+        ]);
+        graph.link_counterparts(CounterpartMatch::Exact);
+        graph
+    }
+
+    fn edges(export: &GraphExport) -> Vec<(&str, &str, EdgeKind)> {
+        export
+            .edges
+            .iter()
+            .map(|e| (e.source.as_str(), e.target.as_str(), e.kind))
+            .collect()
+    }
+
+    #[test]
+    fn test_json_export_round_trips() {
+        let export = GraphExport::new(&graph(), &GraphFilter::default());
+        assert_eq!(export.nodes.len(), 4);
+        assert_eq!(
+            edges(&export),
+            vec![
+                ("cmd/main.go:1", "pool/pool.go:5", EdgeKind::Calls),
+                ("pool/pool.go:1", "web/pool.ts:1", EdgeKind::Corresponds),
+                ("pool/pool.go:5", "pool/pool.go:1", EdgeKind::References),
+                ("web/pool.ts:1", "pool/pool.go:1", EdgeKind::Corresponds),
+            ]
+        );
+
+        let json = export.render(GraphFormat::Json).unwrap();
+        let parsed = GraphExport::from_json(&json).unwrap();
+        assert_eq!((parsed.nodes.len(), parsed.edges.len()), (4, 4));
+        assert_eq!(parsed, export);
+        // Node metadata is flattened next to the id
+        let value: serde_json::Value = serde_json::from_str(&json).unwrap();
+        assert_eq!(value["nodes"][0]["id"], "cmd/main.go:1");
+        assert_eq!(value["nodes"][0]["name"], "run");
+        assert_eq!(value["edges"][0]["kind"], "calls");
+    }
+
+    #[test]
+    fn test_filters_drop_nodes_and_their_edges() {
+        let graph = graph();
+        let filter = GraphFilter {
+            path: Some("./pool/".to_string()),
+            ..Default::default()
+        };
+        let export = GraphExport::new(&graph, &filter);
+        assert_eq!(export.nodes.len(), 2);
+        assert_eq!(
+            edges(&export),
+            vec![("pool/pool.go:5", "pool/pool.go:1", EdgeKind::References)]
+        );
+
+        let filter = GraphFilter {
+            language: Some("TypeScript".to_string()),
+            ..Default::default()
+        };
+        let export = GraphExport::new(&graph, &filter);
+        assert_eq!((export.nodes.len(), export.edges.len()), (1, 0));
+
+        let filter = GraphFilter {
+            kinds: vec![EdgeKind::Calls],
+            ..Default::default()
+        };
+        let export = GraphExport::new(&graph, &filter);
+        assert_eq!((export.nodes.len(), export.edges.len()), (4, 1));
+    }
+
+    #[test]
+    fn test_dot_and_graphml() {
+        let export = GraphExport::new(&graph(), &GraphFilter::default());
+        let dot = export.to_dot();
+        assert!(dot.starts_with("digraph symbols {\n"));
+        assert!(dot.contains("\"cmd/main.go:1\" -> \"pool/pool.go:5\" [kind=calls];"));
+        assert!(dot.contains("[label=\"Pool\", kind=\"interface\", language=\"typescript\""));
+        assert_eq!(dot_string("say \"hi\"\\"), "\"say \\\"hi\\\"\\\\\"");
+
+        let graphml = export.to_graphml();
+        assert_eq!(graphml.matches("<node ").count(), 4);
+        assert_eq!(graphml.matches("<edge ").count(), 4);
+        assert!(graphml.contains("<data key=\"exported\">true</data>"));
+
+        for format in [GraphFormat::Dot, GraphFormat::Graphml, GraphFormat::Json] {
+            assert_eq!(GraphFormat::parse(format.as_str()), Some(format));
+        }
+        assert_eq!(GraphFormat::parse("gexf"), None);
+    }
+}
@@ -3,6 +3,7 @@
 use std::collections::HashMap;
 use tracing::info;
 
+use crate::indexer::ast_chunker::extractors::normalize_parent;
 use crate::storage::IndexedChunk;
 
 /// Reference to a symbol in the index
@@ -26,6 +27,8 @@ pub struct SymbolRef {
     pub parent: Option<String>,
     /// Visibility modifier
     pub visibility: Option<String>,
+    /// Fully qualified name (e.g., `pkg.Type.Method`)
+    pub qualified_name: Option<String>,
 }
 
 /// In-memory index for fast symbol lookups
@@ -36,6 +39,8 @@ pub struct SymbolIndex {
     by_kind: HashMap<String, Vec<SymbolRef>>,
     /// Index by file path
     by_file: HashMap<String, Vec<SymbolRef>>,
+    /// Index by fully qualified name
+    by_qualified_name: HashMap<String, Vec<SymbolRef>>,
     /// Total number of indexed symbols
     symbol_count: usize,
 }
@@ -47,6 +52,7 @@ impl SymbolIndex {
             by_name: HashMap::new(),
             by_kind: HashMap::new(),
             by_file: HashMap::new(),
+            by_qualified_name: HashMap::new(),
             symbol_count: 0,
         }
     }
@@ -68,6 +74,7 @@ impl SymbolIndex {
                     signature: chunk.signature.clone(),
                     parent: chunk.parent.clone(),
                     visibility: chunk.visibility.clone(),
+                    qualified_name: chunk.qualified_name.clone(),
                 };
 
                 index.add_symbol(symbol_ref);
@@ -97,6 +104,14 @@ impl SymbolIndex {
             .or_default()
             .push(symbol.clone());
 
+        // Index by qualified name
+        if let Some(ref qualified_name) = symbol.qualified_name {
+            self.by_qualified_name
+                .entry(qualified_name.clone())
+                .or_default()
+                .push(symbol.clone());
+        }
+
         // Index by file
         self.by_file
             .entry(symbol.file_path.clone())
@@ -107,9 +122,40 @@ impl SymbolIndex {
     }
 
     /// Find symbols by exact name
+    ///
+    /// Accepts a simple name (`getName`), a fully qualified one
+    /// (`com.example.User.getName`), or the end of a qualified name
+    /// (`User.getName`).
     pub fn find_by_name(&self, name: &str) -> Vec<SymbolRef> {
         self.by_name
             .get(name)
+            .or_else(|| self.by_qualified_name.get(name))
+            .cloned()
+            .unwrap_or_else(|| self.find_by_qualified_suffix(name))
+    }
+
+    /// Find symbols whose qualified name ends with `suffix` at a separator
+    /// (`PaymentService.refund` finds `com.example.PaymentService.refund`,
+    /// `Invoice::total` finds `App\Billing\Invoice::total`)
+    pub fn find_by_qualified_suffix(&self, suffix: &str) -> Vec<SymbolRef> {
+        let mut results: Vec<SymbolRef> = self
+            .by_qualified_name
+            .iter()
+            .filter(|(qualified_name, _)| {
+                qualified_name.strip_suffix(suffix).is_some_and(|prefix| {
+                    prefix.ends_with('.') || prefix.ends_with("::") || prefix.ends_with('\\')
+                })
+            })
+            .flat_map(|(_, refs)| refs.iter().cloned())
+            .collect();
+        results.sort_by(|a, b| a.qualified_name.cmp(&b.qualified_name));
+        results
+    }
+
+    /// Find symbols by exact fully qualified name
+    pub fn find_by_qualified_name(&self, qualified_name: &str) -> Vec<SymbolRef> {
+        self.by_qualified_name
+            .get(qualified_name)
             .cloned()
             .unwrap_or_default()
     }
@@ -129,7 +175,18 @@ impl SymbolIndex {
     }
 
     /// Find symbols by fuzzy matching
+    ///
+    /// Names are compared by edit distance. A qualified query
+    /// (`PaymentService.refund`) matches the symbols whose qualified name
+    /// ends with it, at distance 0.
     pub fn find_fuzzy(&self, query: &str, max_distance: usize) -> Vec<(SymbolRef, usize)> {
+        if query.contains('.') || query.contains("::") {
+            let qualified = self.find_by_name(query);
+            if !qualified.is_empty() {
+                return qualified.into_iter().map(|s| (s, 0)).collect();
+            }
+        }
+
         let query_lower = query.to_lowercase();
         let mut results = Vec::new();
 
@@ -165,6 +222,48 @@ impl SymbolIndex {
             .unwrap_or_default()
     }
 
+    /// Find the symbol of a chunk by its location: the symbol spanning
+    /// exactly these lines, else the first one starting inside them (the
+    /// lead unit of a merged chunk)
+    pub fn symbol_at(
+        &self,
+        file_path: &str,
+        start_line: usize,
+        end_line: usize,
+    ) -> Option<SymbolRef> {
+        let symbols = self.by_file.get(file_path)?;
+        symbols
+            .iter()
+            .find(|s| s.start_line == start_line && s.end_line == end_line)
+            .or_else(|| {
+                symbols
+                    .iter()
+                    .filter(|s| (start_line..=end_line).contains(&s.start_line))
+                    .min_by_key(|s| s.start_line)
+            })
+            .cloned()
+    }
+
+    /// Other symbols with the same parent, ordered by file and line.
+    ///
+    /// Symbols without a parent have no siblings.
+    pub fn siblings(&self, symbol: &SymbolRef) -> Vec<SymbolRef> {
+        let Some(parent) = ParentId::of(symbol) else {
+            return Vec::new();
+        };
+        let mut siblings: Vec<SymbolRef> = self
+            .by_file
+            .values()
+            .flatten()
+            .filter(|s| s.chunk_id != symbol.chunk_id && s.name != symbol.name)
+            .filter(|s| ParentId::of(s).as_ref() == Some(&parent))
+            .cloned()
+            .collect();
+        siblings.sort_by(|a, b| (&a.file_path, a.start_line).cmp(&(&b.file_path, b.start_line)));
+        siblings.dedup_by(|a, b| a.name == b.name && a.file_path == b.file_path);
+        siblings
+    }
+
     /// List all files with symbols
     pub fn list_files(&self) -> Vec<String> {
         self.by_file.keys().cloned().collect()
@@ -185,6 +284,7 @@ impl SymbolIndex {
         self.by_name.clear();
         self.by_kind.clear();
         self.by_file.clear();
+        self.by_qualified_name.clear();
         self.symbol_count = 0;
     }
 }
@@ -195,6 +295,31 @@ impl Default for SymbolIndex {
     }
 }
 
+/// Identity of a symbol's parent
+#[derive(Debug, PartialEq, Eq)]
+enum ParentId<'a> {
+    /// Qualified name without the symbol's own name (`pool.WorkerPool.`),
+    /// which also groups Go methods declared in other files of the package
+    Qualified(&'a str),
+    /// File and bare parent name, for symbols without a qualified name
+    InFile(&'a str, String),
+}
+
+impl<'a> ParentId<'a> {
+    fn of(symbol: &'a SymbolRef) -> Option<Self> {
+        let parent = symbol.parent.as_deref()?;
+        let qualified_prefix = symbol
+            .qualified_name
+            .as_deref()
+            .and_then(|q| q.strip_suffix(symbol.name.as_str()))
+            .filter(|prefix| !prefix.is_empty());
+        Some(match qualified_prefix {
+            Some(prefix) => Self::Qualified(prefix),
+            None => Self::InFile(&symbol.file_path, normalize_parent(parent)),
+        })
+    }
+}
+
 /// Calculate Levenshtein distance between two strings
 fn levenshtein_distance(a: &str, b: &str) -> usize {
     let a_chars: Vec<char> = a.chars().collect();
@@ -266,6 +391,7 @@ mod tests {
             signature: Some("fn test_function() -> Result<()>".to_string()),
             parent: None,
             visibility: Some("pub".to_string()),
+            qualified_name: None,
         };
 
         index.add_symbol(symbol.clone());
@@ -276,6 +402,128 @@ mod tests {
         assert_eq!(index.get_by_file("src/test.rs").len(), 1);
     }
 
+    #[test]
+    fn test_find_by_qualified_name() {
+        let mut index = SymbolIndex::new();
+
+        index.add_symbol(SymbolRef {
+            chunk_id: "id-1".to_string(),
+            name: "getName".to_string(),
+            kind: "method".to_string(),
+            file_path: "User.java".to_string(),
+            start_line: 5,
+            end_line: 7,
+            signature: None,
+            parent: Some("User".to_string()),
+            visibility: None,
+            qualified_name: Some("com.example.User.getName".to_string()),
+        });
+
+        assert_eq!(index.find_by_qualified_name("com.example.User.getName").len(), 1);
+        assert_eq!(index.find_by_name("com.example.User.getName").len(), 1);
+        assert_eq!(index.find_by_name("getName").len(), 1);
+        assert!(index.find_by_qualified_name("getName").is_empty());
+
+        // The end of a qualified name, at a separator
+        assert_eq!(index.find_by_name("User.getName").len(), 1);
+        assert_eq!(
+            index.find_by_qualified_suffix("example.User.getName").len(),
+            1
+        );
+        assert!(index.find_by_name("ser.getName").is_empty());
+        let fuzzy = index.find_fuzzy("User.getName", 3);
+        assert_eq!(fuzzy.len(), 1);
+        assert_eq!(fuzzy[0].1, 0);
+    }
+
+    #[test]
+    fn test_find_by_php_qualified_suffix() {
+        let mut index = SymbolIndex::new();
+
+        index.add_symbol(SymbolRef {
+            chunk_id: "id-1".to_string(),
+            name: "total".to_string(),
+            kind: "method".to_string(),
+            file_path: "src/Billing/Invoice.php".to_string(),
+            start_line: 12,
+            end_line: 15,
+            signature: None,
+            parent: Some("Invoice".to_string()),
+            visibility: None,
+            qualified_name: Some("App\\Billing\\Invoice::total".to_string()),
+        });
+
+        assert_eq!(index.find_by_name("Invoice::total").len(), 1);
+        assert_eq!(index.find_by_name("Billing\\Invoice::total").len(), 1);
+        assert!(index.find_by_name("voice::total").is_empty());
+    }
+
+    fn go_method(id: &str, file_path: &str, line: usize, parent: &str, name: &str) -> SymbolRef {
+        let receiver = parent.trim_start_matches('*');
+        SymbolRef {
+            chunk_id: id.to_string(),
+            name: name.to_string(),
+            kind: "method".to_string(),
+            file_path: file_path.to_string(),
+            start_line: line,
+            end_line: line + 5,
+            signature: Some(format!("func (p {}) {}()", parent, name)),
+            parent: Some(parent.to_string()),
+            visibility: None,
+            qualified_name: Some(format!("pool.{}.{}", receiver, name)),
+        }
+    }
+
+    #[test]
+    fn test_siblings_share_parent() {
+        let mut index = SymbolIndex::new();
+        index.add_symbol(go_method("1", "pool/pool.go", 10, "*WorkerPool", "Submit"));
+        index.add_symbol(go_method("2", "pool/pool.go", 20, "*WorkerPool", "Resize"));
+        index.add_symbol(go_method("3", "pool/stop.go", 5, "WorkerPool", "Shutdown"));
+        index.add_symbol(go_method("4", "pool/task.go", 5, "*Task", "Run"));
+
+        let matched = index.symbol_at("pool/pool.go", 20, 25).unwrap();
+        assert_eq!(matched.name, "Resize");
+
+        let siblings = index.siblings(&matched);
+        let names: Vec<&str> = siblings.iter().map(|s| s.name.as_str()).collect();
+        assert_eq!(names, vec!["Submit", "Shutdown"]);
+        assert_eq!(
+            siblings[0].signature.as_deref(),
+            Some("func (p *WorkerPool) Submit()")
+        );
+    }
+
+    #[test]
+    fn test_siblings_without_qualified_names() {
+        let mut index = SymbolIndex::new();
+        let method = |id: &str, file_path: &str, parent: Option<&str>, name: &str| SymbolRef {
+            chunk_id: id.to_string(),
+            name: name.to_string(),
+            kind: "method".to_string(),
+            file_path: file_path.to_string(),
+            start_line: id.parse().unwrap(),
+            end_line: id.parse().unwrap(),
+            signature: None,
+            parent: parent.map(str::to_string),
+            visibility: None,
+            qualified_name: None,
+        };
+        index.add_symbol(method("1", "a.rs", Some("Stack<T>"), "push"));
+        index.add_symbol(method("2", "a.rs", Some("Stack<T>"), "pop"));
+        index.add_symbol(method("3", "b.rs", Some("Stack"), "peek"));
+        index.add_symbol(method("4", "a.rs", None, "helper"));
+
+        let push = index.symbol_at("a.rs", 1, 1).unwrap();
+        let names: Vec<String> = index.siblings(&push).into_iter().map(|s| s.name).collect();
+        assert_eq!(names, vec!["pop"]);
+
+        // A merged chunk resolves to its first symbol
+        assert_eq!(index.symbol_at("a.rs", 2, 4).unwrap().name, "pop");
+        let helper = index.symbol_at("a.rs", 4, 4).unwrap();
+        assert!(index.siblings(&helper).is_empty());
+    }
+
     #[test]
     fn test_prefix_search() {
         let mut index = SymbolIndex::new();
@@ -291,6 +539,7 @@ mod tests {
                 signature: None,
                 parent: None,
                 visibility: None,
+                qualified_name: None,
             });
         }
 
@@ -312,6 +561,7 @@ mod tests {
             signature: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
         });
 
         let results = index.find_fuzzy("helo_world", 2);
@@ -0,0 +1,289 @@
+//! Symbol listing for browsing the index
+//!
+//! Unlike [`SymbolSearcher`](super::SymbolSearcher), which ranks symbols
+//! against a query, listing enumerates every indexed symbol that matches the
+//! filters in a stable order. It backs the `coderag symbols` command.
+
+use serde::Serialize;
+
+use crate::storage::IndexedChunk;
+
+/// Keywords and operators counted as decision points by [`complexity`]
+const BRANCH_KEYWORDS: &[&str] = &[
+    "if", "elif", "for", "foreach", "while", "loop", "case", "catch", "except",
+];
+
+/// Sort order for listed symbols
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum SymbolSort {
+    /// Alphabetically by name
+    #[default]
+    Name,
+    /// Largest first, by line count
+    Size,
+    /// Most complex first, by approximate cyclomatic complexity
+    Complexity,
+}
+
+impl SymbolSort {
+    /// Parse sort order from string.
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.to_lowercase().as_str() {
+            "name" => Some(Self::Name),
+            "size" => Some(Self::Size),
+            "complexity" => Some(Self::Complexity),
+            _ => None,
+        }
+    }
+
+    /// Convert to string representation.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Name => "name",
+            Self::Size => "size",
+            Self::Complexity => "complexity",
+        }
+    }
+}
+
+/// Filters and paging for a symbol listing
+#[derive(Debug, Clone, Default)]
+pub struct SymbolFilter {
+    /// Only include symbols in this language
+    pub language: Option<String>,
+    /// Only include symbols of this semantic kind
+    pub kind: Option<String>,
+    /// Sort order
+    pub sort: SymbolSort,
+    /// Number of matching symbols to skip
+    pub offset: usize,
+    /// Maximum number of symbols to return
+    pub limit: Option<usize>,
+}
+
+/// A symbol in a listing
+#[derive(Debug, Clone, Serialize)]
+pub struct SymbolEntry {
+    pub name: String,
+    pub kind: String,
+    pub language: Option<String>,
+    pub file_path: String,
+    pub start_line: usize,
+    pub end_line: usize,
+    pub qualified_name: Option<String>,
+    /// Number of source lines
+    pub size: usize,
+    /// Approximate cyclomatic complexity
+    pub complexity: usize,
+}
+
+/// One page of a symbol listing
+#[derive(Debug, Clone, Serialize)]
+pub struct SymbolListing {
+    /// Number of symbols matching the filters, before paging
+    pub total: usize,
+    pub offset: usize,
+    pub symbols: Vec<SymbolEntry>,
+}
+
+/// List indexed symbols matching `filter`.
+pub fn list_symbols(chunks: &[IndexedChunk], filter: &SymbolFilter) -> SymbolListing {
+    let mut symbols: Vec<SymbolEntry> = chunks
+        .iter()
+        .filter_map(|chunk| {
+            let name = chunk.symbol_name.clone()?;
+            let kind = chunk
+                .semantic_kind
+                .clone()
+                .unwrap_or_else(|| "unknown".to_string());
+            Some(SymbolEntry {
+                name,
+                kind,
+                language: chunk.language.clone(),
+                file_path: chunk.file_path.clone(),
+                start_line: chunk.start_line,
+                end_line: chunk.end_line,
+                qualified_name: chunk.qualified_name.clone(),
+                size: chunk.end_line.saturating_sub(chunk.start_line) + 1,
+                complexity: complexity(&chunk.content),
+            })
+        })
+        .filter(|s| {
+            filter.language.as_deref().map_or(true, |lang| {
+                s.language
+                    .as_deref()
+                    .map_or(false, |l| l.eq_ignore_ascii_case(lang))
+            })
+        })
+        .filter(|s| {
+            filter
+                .kind
+                .as_deref()
+                .map_or(true, |kind| s.kind.eq_ignore_ascii_case(kind))
+        })
+        .collect();
+
+    let by_name = |a: &SymbolEntry, b: &SymbolEntry| {
+        a.name
+            .to_lowercase()
+            .cmp(&b.name.to_lowercase())
+            .then_with(|| a.file_path.cmp(&b.file_path))
+            .then_with(|| a.start_line.cmp(&b.start_line))
+    };
+    match filter.sort {
+        SymbolSort::Name => symbols.sort_by(by_name),
+        SymbolSort::Size => symbols.sort_by(|a, b| b.size.cmp(&a.size).then_with(|| by_name(a, b))),
+        SymbolSort::Complexity => {
+            symbols.sort_by(|a, b| b.complexity.cmp(&a.complexity).then_with(|| by_name(a, b)))
+        }
+    }
+
+    let total = symbols.len();
+    let symbols = symbols
+        .into_iter()
+        .skip(filter.offset)
+        .take(filter.limit.unwrap_or(usize::MAX))
+        .collect();
+
+    SymbolListing {
+        total,
+        offset: filter.offset,
+        symbols,
+    }
+}
+
+/// Approximate cyclomatic complexity of a code snippet.
+///
+/// Counts branching keywords and short-circuit operators, plus one for the
+/// entry path. This is language-agnostic and deliberately rough; it is meant
+/// for ranking symbols, not for exact metrics.
+pub fn complexity(content: &str) -> usize {
+    let keywords = content
+        .split(|c: char| !(c.is_alphanumeric() || c == '_'))
+        .filter(|word| BRANCH_KEYWORDS.contains(word))
+        .count();
+    let operators = content.matches("&&").count() + content.matches("||").count();
+    1 + keywords + operators
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::indexer::AstChunker;
+    use std::path::Path;
+
+    /// Index the Go fixture the way the indexer would, without embeddings
+    fn fixture_chunks() -> Vec<IndexedChunk> {
+        let source = include_str!("../../tests/fixtures/languages/go/sample_go.go");
+        // No minimum size, so small methods are not merged together
+        let mut chunker = AstChunker::with_limits(0, 1500);
+        chunker
+            .chunk_file(Path::new("sample_go.go"), source)
+            .into_iter()
+            .enumerate()
+            .map(|(i, chunk)| IndexedChunk {
+                id: i.to_string(),
+                content: chunk.content,
+                file_path: "sample_go.go".to_string(),
+                start_line: chunk.start_line,
+                end_line: chunk.end_line,
+                language: chunk.language,
+                vector: Vec::new(),
+                mtime: 0,
+                file_header: None,
+                semantic_kind: chunk.semantic_kind.map(|k| k.as_str().to_string()),
+                symbol_name: chunk.name,
+                signature: chunk.signature,
+                doc: None,
+                parent: chunk.parent,
+                visibility: None,
+                qualified_name: chunk.qualified_name,
+                tags: chunk.tags,
+                branch: None,
+                duplicate_of: None,
+            })
+            .collect()
+    }
+
+    fn names(listing: &SymbolListing) -> Vec<&str> {
+        listing.symbols.iter().map(|s| s.name.as_str()).collect()
+    }
+
+    fn go_methods(sort: SymbolSort) -> SymbolFilter {
+        SymbolFilter {
+            language: Some("go".to_string()),
+            kind: Some("method".to_string()),
+            sort,
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_filter_and_sort_by_name() {
+        let listing = list_symbols(&fixture_chunks(), &go_methods(SymbolSort::Name));
+        assert_eq!(
+            names(&listing),
+            vec!["GetResult", "Shutdown", "Start", "Submit", "worker"]
+        );
+        assert!(listing.symbols.iter().all(|s| s.kind == "method"));
+        assert_eq!(listing.total, 5);
+    }
+
+    #[test]
+    fn test_sort_by_size_and_complexity() {
+        let chunks = fixture_chunks();
+
+        let by_size = list_symbols(&chunks, &go_methods(SymbolSort::Size));
+        assert_eq!(
+            names(&by_size),
+            vec!["worker", "GetResult", "Submit", "Shutdown", "Start"]
+        );
+
+        let by_complexity = list_symbols(&chunks, &go_methods(SymbolSort::Complexity));
+        assert_eq!(
+            names(&by_complexity),
+            vec!["worker", "Submit", "GetResult", "Start", "Shutdown"]
+        );
+    }
+
+    #[test]
+    fn test_offset_and_limit() {
+        let filter = SymbolFilter {
+            offset: 1,
+            limit: Some(2),
+            ..go_methods(SymbolSort::Name)
+        };
+        let listing = list_symbols(&fixture_chunks(), &filter);
+        assert_eq!(names(&listing), vec!["Shutdown", "Start"]);
+        assert_eq!(listing.total, 5);
+        assert_eq!(listing.offset, 1);
+    }
+
+    #[test]
+    fn test_language_filter_excludes_other_languages() {
+        let filter = SymbolFilter {
+            language: Some("rust".to_string()),
+            ..Default::default()
+        };
+        assert_eq!(list_symbols(&fixture_chunks(), &filter).total, 0);
+    }
+
+    #[test]
+    fn test_complexity() {
+        assert_eq!(complexity("fn f() { x }"), 1);
+        assert_eq!(complexity("if a && b { } else if c || d { }"), 5);
+        assert_eq!(complexity("for x in y { while z {} }"), 3);
+        // Identifiers containing keywords are not counted
+        assert_eq!(complexity("let iffy = format(verify);"), 1);
+    }
+
+    #[test]
+    fn test_sort_parse() {
+        assert_eq!(SymbolSort::parse("Size"), Some(SymbolSort::Size));
+        assert_eq!(
+            SymbolSort::parse("complexity"),
+            Some(SymbolSort::Complexity)
+        );
+        assert_eq!(SymbolSort::parse("lines"), None);
+    }
+}
@@ -4,8 +4,20 @@
 //! extracted during the AST chunking process. It enables fast symbol lookup and search
 //! for MCP tools.
 
+pub mod branch_diff;
+pub mod flags;
+pub mod graph;
+pub mod graph_export;
 pub mod index;
+pub mod listing;
 pub mod search;
+pub mod untested;
 
+pub use branch_diff::{diff_branches, ChangeKind, SymbolChange};
+pub use flags::{flag_locations, FlagLocation};
+pub use graph::{CounterpartMatch, Edge, EdgeKind, SymbolGraph, SymbolNode};
+pub use graph_export::{GraphExport, GraphFilter, GraphFormat};
 pub use index::{SymbolIndex, SymbolRef};
-pub use search::{FindSymbolRequest, FindReferencesRequest, ListSymbolsRequest, SymbolSearcher};
\ No newline at end of file
+pub use listing::{list_symbols, SymbolEntry, SymbolFilter, SymbolListing, SymbolSort};
+pub use search::{FindSymbolRequest, FindReferencesRequest, ListSymbolsRequest, SymbolSearcher};
+pub use untested::untested;
\ No newline at end of file
@@ -10,12 +10,12 @@ use tracing::debug;
 
 use super::index::{SymbolIndex, SymbolRef};
 use crate::search::{SearchEngine, traits::Search};
-use crate::storage::Storage;
+use crate::storage::VectorStore;
 
 /// Request for finding symbol definitions
 #[derive(Debug, Deserialize, JsonSchema)]
 pub struct FindSymbolRequest {
-    /// Symbol name or pattern to search for
+    /// Symbol name or pattern to search for (simple or fully qualified name)
     pub query: String,
     /// Filter by semantic kind (function, class, struct, etc.)
     pub kind: Option<String>,
@@ -48,6 +48,7 @@ pub struct SymbolResult {
     pub signature: Option<String>,
     pub parent: Option<String>,
     pub visibility: Option<String>,
+    pub qualified_name: Option<String>,
     pub relevance_score: f32,
 }
 
@@ -60,6 +61,8 @@ pub struct ListSymbolsRequest {
     pub kind_filter: Option<Vec<String>>,
     /// Filter by visibility (public, private, etc.)
     pub visibility: Option<String>,
+    /// Maximum number of symbols to list (default: all)
+    pub limit: Option<usize>,
 }
 
 /// Response for listing symbols
@@ -115,7 +118,7 @@ pub struct SymbolSearcher {
     symbol_index: Arc<SymbolIndex>,
     search_engine: Arc<SearchEngine>,
     #[allow(dead_code)]
-    storage: Arc<Storage>,
+    storage: Arc<dyn VectorStore>,
 }
 
 impl SymbolSearcher {
@@ -123,7 +126,7 @@ impl SymbolSearcher {
     pub fn new(
         symbol_index: Arc<SymbolIndex>,
         search_engine: Arc<SearchEngine>,
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
     ) -> Self {
         Self {
             symbol_index,
@@ -198,6 +201,7 @@ impl SymbolSearcher {
                 signature: s.signature.clone(),
                 parent: s.parent.clone(),
                 visibility: s.visibility.clone(),
+                qualified_name: s.qualified_name.clone(),
                 relevance_score: *score,
             })
             .collect();
@@ -240,6 +244,12 @@ impl SymbolSearcher {
         // Sort by line number
         symbols.sort_by_key(|s| s.start_line);
 
+        // Count before paging so the response reports every match
+        let total_symbols = symbols.len();
+        if let Some(limit) = request.limit {
+            symbols.truncate(limit);
+        }
+
         // Convert to summary format
         let summaries: Vec<SymbolSummary> = symbols
             .iter()
@@ -268,7 +278,7 @@ impl SymbolSearcher {
 
         Ok(ListSymbolsResponse {
             file_path: request.file_path,
-            total_symbols: summaries.len(),
+            total_symbols,
             symbols: summaries,
             by_kind,
         })
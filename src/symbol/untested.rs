@@ -0,0 +1,146 @@
+//! Test coverage gaps
+//!
+//! Lists the exported functions and methods that no test calls, from the
+//! `tests` edges of the [`SymbolGraph`]: a function counts as tested when a
+//! test in the same language calls a function of that name.
+//!
+//! This is a static heuristic, not measured coverage:
+//! - Calls are matched by bare name, so a test calling one `Close` marks
+//!   every `Close` of its language tested
+//! - Only direct calls from a test count. A function exercised through a
+//!   helper, a public entry point, an interface or trait object (dynamic
+//!   dispatch), a callback or reflection is listed as untested
+//! - Tests are the chunks the extractors classify as `test` (Go `TestXxx`,
+//!   Rust `#[test]`, Python `test_*`, ...); table-driven cases and test
+//!   frameworks the extractors do not know are not recognized
+
+use std::collections::{HashMap, HashSet};
+
+use super::graph::{EdgeKind, SymbolGraph, SymbolNode};
+use super::graph_export::GraphFilter;
+
+/// Symbol kinds that tests call
+const CALLABLE_KINDS: &[&str] = &["function", "method"];
+
+/// Exported functions and methods selected by `filter` with no inbound
+/// `tests` edge, by file
+pub fn untested<'a>(graph: &'a SymbolGraph, filter: &GraphFilter) -> Vec<&'a SymbolNode> {
+    let languages: HashMap<(&str, usize), Option<&str>> = graph
+        .nodes()
+        .map(|n| ((n.file.as_str(), n.line), n.language.as_deref()))
+        .collect();
+    let tested: HashSet<(Option<&str>, &str)> = graph
+        .edges()
+        .filter(|e| e.kind == EdgeKind::Tests)
+        .map(|e| {
+            let language = languages.get(&(e.file.as_str(), e.line)).copied().flatten();
+            (language, e.target.as_str())
+        })
+        .collect();
+
+    graph
+        .nodes()
+        .filter(|n| {
+            n.exported
+                && n.kind
+                    .as_deref()
+                    .is_some_and(|kind| CALLABLE_KINDS.contains(&kind))
+                && filter.keeps_node(n)
+                && !tested.contains(&(n.language.as_deref(), n.name.as_str()))
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::storage::IndexedChunk;
+
+    fn chunk(
+        file_path: &str,
+        start_line: usize,
+        kind: &str,
+        name: &str,
+        content: &str,
+    ) -> IndexedChunk {
+        IndexedChunk {
+            id: format!("{}:{}", file_path, start_line),
+            content: content.to_string(),
+            file_path: file_path.to_string(),
+            start_line,
+            end_line: start_line + content.lines().count() - 1,
+            language: Some("go".to_string()),
+            vector: Vec::new(),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some(kind.to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        }
+    }
+
+    fn names(nodes: &[&SymbolNode]) -> Vec<String> {
+        nodes
+            .iter()
+            .map(|n| format!("{}:{}", n.file, n.name))
+            .collect()
+    }
+
+    #[test]
+    fn test_lists_exported_functions_no_test_calls() {
+        let graph = SymbolGraph::build(&[
+            chunk(
+                "pool/pool.go",
+                1,
+                "function",
+                "Acquire",
+                "func Acquire() {}",
+            ),
+            chunk(
+                "pool/pool.go",
+                3,
+                "function",
+                "Release",
+                "func Release() {\n\tAcquire()\n}",
+            ),
+            chunk("pool/pool.go", 7, "function", "drain", "func drain() {}"),
+            chunk("pool/pool.go", 9, "struct", "Pool", "type Pool struct{}"),
+            chunk(
+                "pool/pool_test.go",
+                1,
+                "test",
+                "TestAcquire",
+                "func TestAcquire(t *testing.T) {\n\tAcquire()\n}",
+            ),
+            chunk("cmd/main.go", 1, "function", "Run", "func Run() {}"),
+        ]);
+
+        // Release only calls Acquire outside a test; drain is unexported,
+        // Pool is a type, and TestAcquire is the test itself
+        assert_eq!(
+            names(&untested(&graph, &GraphFilter::default())),
+            vec!["cmd/main.go:Run", "pool/pool.go:Release"]
+        );
+
+        let filter = GraphFilter {
+            path: Some("pool".to_string()),
+            ..Default::default()
+        };
+        assert_eq!(
+            names(&untested(&graph, &filter)),
+            vec!["pool/pool.go:Release"]
+        );
+        let filter = GraphFilter {
+            language: Some("rust".to_string()),
+            ..Default::default()
+        };
+        assert!(untested(&graph, &filter).is_empty());
+    }
+}
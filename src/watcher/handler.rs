@@ -3,16 +3,26 @@
 //! This module handles the actual re-indexing of files when changes are detected.
 
 use anyhow::{Context, Result};
+use serde::Serialize;
 use std::fs;
-use std::path::PathBuf;
+use std::path::{Path, PathBuf};
 use std::sync::Arc;
 use std::time::UNIX_EPOCH;
 use tracing::{debug, error, info, warn};
 
 use crate::config::Config;
-use crate::embeddings::EmbeddingGenerator;
-use crate::indexer::Chunker;
-use crate::storage::{IndexedChunk, Storage};
+use crate::embeddings::{tokenizer_for_config, EmbeddingGenerator};
+use crate::indexer::ast_chunker::parser_pool::ParserPool;
+use crate::indexer::comments::embedding_text;
+use crate::indexer::context_header::{with_context_header, ChunkContext};
+use crate::indexer::field_vectors::{embed_fields, enabled_fields};
+use crate::indexer::imports::{chunk_import_contexts, with_import_context};
+use crate::indexer::summaries::Summarizer;
+use crate::indexer::{Chunker, FlagDetector, HeaderMetadata};
+use crate::storage::{
+    assign_ids, DeterministicIds, IdGenerator, IndexedChunk, VectorField, VectorStore,
+};
+use crate::symbol::SymbolGraph;
 
 use super::accumulator::{ChangeType, FileChange};
 
@@ -31,6 +41,12 @@ pub struct ProcessingStats {
     pub chunks_removed: usize,
     /// Number of errors encountered
     pub errors: usize,
+    /// Files that were indexed or removed
+    pub changed_files: Vec<ChangedFile>,
+    /// Symbols that appeared in the changed files
+    pub symbols_added: Vec<SymbolChange>,
+    /// Symbols that disappeared from the changed files
+    pub symbols_removed: Vec<SymbolChange>,
 }
 
 impl ProcessingStats {
@@ -42,6 +58,10 @@ impl ProcessingStats {
         self.chunks_created += other.chunks_created;
         self.chunks_removed += other.chunks_removed;
         self.errors += other.errors;
+        self.changed_files.extend_from_slice(&other.changed_files);
+        self.symbols_added.extend_from_slice(&other.symbols_added);
+        self.symbols_removed
+            .extend_from_slice(&other.symbols_removed);
     }
 
     /// Check if any files were processed
@@ -55,26 +75,78 @@ impl ProcessingStats {
     }
 }
 
+/// A file indexed or removed by the watcher
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct ChangedFile {
+    /// Path relative to the project root
+    pub path: String,
+    /// `created`, `modified`, `deleted` or `renamed`
+    pub change: String,
+    /// Path before a rename, relative to the project root
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub previous_path: Option<String>,
+}
+
+/// A symbol added to or removed from the index
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct SymbolChange {
+    /// File path relative to the project root
+    pub file: String,
+    /// Qualified name when known, else the bare name
+    pub name: String,
+    /// Semantic kind (`function`, `struct`, ...)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+}
+
 /// Handles file changes and triggers re-indexing
 pub struct ChangeHandler {
-    storage: Arc<Storage>,
+    storage: Arc<dyn VectorStore>,
     embedder: Arc<EmbeddingGenerator>,
     chunker: Chunker,
-    #[allow(dead_code)]
     root: PathBuf,
     #[allow(dead_code)]
     config: Config,
+    ids: Arc<dyn IdGenerator>,
+    flags: Arc<FlagDetector>,
+    header_meta: Arc<HeaderMetadata>,
+    /// Writes chunk summaries when `indexer.summaries` is enabled
+    summarizer: Option<Arc<Summarizer>>,
+    /// Symbol graph, updated with each changed file
+    graph: SymbolGraph,
+    /// Storage directory the graph is saved to
+    graph_dir: Option<PathBuf>,
+    parsers: ParserPool,
 }
 
 impl ChangeHandler {
     /// Create a new change handler
     pub fn new(
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
+        db_path: &Path,
         embedder: Arc<EmbeddingGenerator>,
         root: PathBuf,
         config: Config,
     ) -> Result<Self> {
-        let chunker = Chunker::new(config.indexer.chunk_size);
+        let chunker = Chunker::new(config.indexer.chunk_size.min(config.chunk_token_limit()))
+            .with_tokenizer(tokenizer_for_config(&config.embeddings));
+
+        let flags = Arc::new(FlagDetector::new(&config.indexer.flag_accessors));
+        let header_meta = Arc::new(HeaderMetadata::new(&config.indexer.header_keys));
+        let summarizer = match config.indexer.summaries.enabled {
+            true => Some(Arc::new(Summarizer::new(&config.indexer.summaries)?)),
+            false => None,
+        };
+
+        // The graph is stored next to the database
+        let graph_dir = db_path.parent().map(Path::to_path_buf);
+        let graph = match &graph_dir {
+            Some(dir) => SymbolGraph::load(dir).unwrap_or_else(|e| {
+                warn!("Failed to load symbol graph, starting empty: {}", e);
+                SymbolGraph::default()
+            }),
+            None => SymbolGraph::default(),
+        };
 
         Ok(Self {
             storage,
@@ -82,9 +154,22 @@ impl ChangeHandler {
             chunker,
             root,
             config,
+            ids: Arc::new(DeterministicIds),
+            flags,
+            header_meta,
+            summarizer,
+            graph,
+            graph_dir,
+            parsers: ParserPool::new(),
         })
     }
 
+    /// Generate chunk IDs with `ids` instead of [`DeterministicIds`]
+    pub fn with_id_generator(mut self, ids: Arc<dyn IdGenerator>) -> Self {
+        self.ids = ids;
+        self
+    }
+
     /// Process a batch of file changes
     ///
     /// # Arguments
@@ -107,9 +192,29 @@ impl ChangeHandler {
             }
         }
 
+        if stats.has_changes() {
+            self.save_graph();
+        }
+
         Ok(stats)
     }
 
+    /// Drop graph edges to symbols that no longer exist, relink exported
+    /// types across languages and save the graph
+    fn save_graph(&mut self) {
+        let dropped = self.graph.prune();
+        if dropped > 0 {
+            debug!("Dropped {} edges to removed symbols", dropped);
+        }
+        self.graph
+            .link_counterparts(self.config.indexer.counterparts);
+        if let Some(dir) = &self.graph_dir {
+            if let Err(e) = self.graph.save(dir) {
+                warn!("Failed to save symbol graph: {}", e);
+            }
+        }
+    }
+
     /// Process a single file change
     async fn process_single(&mut self, change: &FileChange) -> Result<ProcessingStats> {
         let mut stats = ProcessingStats::default();
@@ -119,54 +224,107 @@ impl ChangeHandler {
             change.change_type, change.path
         );
 
+        // Symbols of the previously indexed version
+        let mut before = Vec::new();
+        let mut after = Vec::new();
+        let mut previous_path = None;
+
         match &change.change_type {
             ChangeType::Created => {
-                stats.chunks_created = self.index_file(&change.path).await?;
+                (stats.chunks_created, after) = self.index_file(&change.path).await?;
                 stats.files_added = 1;
                 info!("Indexed new file: {:?}", change.path);
             }
             ChangeType::Modified => {
+                before = self.indexed_symbols(&change.path).await?;
                 // Delete existing chunks first
                 stats.chunks_removed = self.delete_file_chunks(&change.path).await?;
                 // Then re-index
-                stats.chunks_created = self.index_file(&change.path).await?;
+                (stats.chunks_created, after) = self.index_file(&change.path).await?;
                 stats.files_modified = 1;
                 info!("Re-indexed modified file: {:?}", change.path);
             }
             ChangeType::Deleted => {
+                before = self.indexed_symbols(&change.path).await?;
                 stats.chunks_removed = self.delete_file_chunks(&change.path).await?;
                 stats.files_deleted = 1;
                 info!("Removed deleted file from index: {:?}", change.path);
             }
             ChangeType::Renamed { from } => {
+                before = self.indexed_symbols(from).await?;
                 // Delete chunks from old location
                 stats.chunks_removed = self.delete_file_chunks(from).await?;
                 // Index at new location
-                stats.chunks_created = self.index_file(&change.path).await?;
+                (stats.chunks_created, after) = self.index_file(&change.path).await?;
                 stats.files_modified = 1;
+                previous_path = Some(self.relative(from));
                 info!("Re-indexed renamed file: {:?} -> {:?}", from, change.path);
             }
         }
 
+        stats.changed_files.push(ChangedFile {
+            path: self.relative(&change.path),
+            change: change.change_type.to_string().to_lowercase(),
+            previous_path,
+        });
+        (stats.symbols_added, stats.symbols_removed) = diff_symbols(&before, &after);
+
         Ok(stats)
     }
 
+    /// Path relative to the project root, as reported to webhooks
+    fn relative(&self, path: &Path) -> String {
+        path.strip_prefix(&self.root)
+            .unwrap_or(path)
+            .to_string_lossy()
+            .into_owned()
+    }
+
+    /// Symbols currently indexed for a file
+    async fn indexed_symbols(&self, path: &Path) -> Result<Vec<SymbolChange>> {
+        let chunks = self
+            .storage
+            .file_chunks(path)
+            .await
+            .with_context(|| format!("Failed to read indexed chunks for {:?}", path))?;
+        Ok(self.symbols(&chunks))
+    }
+
+    /// Named symbols of a file's chunks, without duplicates
+    fn symbols(&self, chunks: &[IndexedChunk]) -> Vec<SymbolChange> {
+        let mut symbols: Vec<SymbolChange> = Vec::new();
+        for chunk in chunks {
+            let Some(name) = chunk.qualified_name.as_ref().or(chunk.symbol_name.as_ref()) else {
+                continue;
+            };
+            let symbol = SymbolChange {
+                file: self.relative(Path::new(&chunk.file_path)),
+                name: name.clone(),
+                kind: chunk.semantic_kind.clone(),
+            };
+            if !symbols.contains(&symbol) {
+                symbols.push(symbol);
+            }
+        }
+        symbols
+    }
+
     /// Index a single file
     ///
-    /// Returns the number of chunks created
-    async fn index_file(&mut self, path: &PathBuf) -> Result<usize> {
+    /// Returns the number of chunks created and the file's symbols
+    async fn index_file(&mut self, path: &PathBuf) -> Result<(usize, Vec<SymbolChange>)> {
         // Read file content
         let content = match fs::read_to_string(path) {
             Ok(c) => c,
             Err(e) => {
                 warn!("Could not read file {:?}: {}", path, e);
-                return Ok(0);
+                return Ok((0, Vec::new()));
             }
         };
 
         if content.trim().is_empty() {
             debug!("Skipping empty file: {:?}", path);
-            return Ok(0);
+            return Ok((0, Vec::new()));
         }
 
         // Get file mtime
@@ -176,15 +334,42 @@ impl ChangeHandler {
         let file_header = extract_file_header(&content, 50);
 
         // Chunk the file
-        let chunks = self.chunker.chunk_file(path, &content);
+        let mut chunks = self.chunker.chunk_file(path, &content);
+        for chunk in &mut chunks {
+            self.flags.tag(chunk);
+        }
+        self.header_meta.tag(&content, &mut chunks);
 
         if chunks.is_empty() {
             debug!("No chunks generated for file: {:?}", path);
-            return Ok(0);
+            return Ok((0, Vec::new()));
         }
 
         // Prepare chunks for embedding
-        let chunk_contents: Vec<String> = chunks.iter().map(|c| c.content.clone()).collect();
+        let embed_comments = self.config.indexer.embed_comments;
+        let context_headers = self.config.indexer.context_headers;
+        let relative_path = path
+            .strip_prefix(&self.root)
+            .unwrap_or(path)
+            .to_string_lossy()
+            .into_owned();
+        let imports = chunk_import_contexts(&content, &chunks, self.config.indexer.import_context);
+        let chunk_contents: Vec<String> = chunks
+            .iter()
+            .zip(&imports)
+            .map(|(c, imports)| {
+                let text = embedding_text(&c.content, c.language.as_deref(), embed_comments);
+                let text = match imports {
+                    Some(imports) => with_import_context(&text, imports),
+                    None => text,
+                };
+                if context_headers {
+                    with_context_header(&ChunkContext::of(&relative_path, c), &c.content, &text)
+                } else {
+                    text
+                }
+            })
+            .collect();
 
         // Generate embeddings using async method to avoid runtime nesting
         let embeddings = self
@@ -195,11 +380,11 @@ impl ChangeHandler {
 
         // Create indexed chunks
         let file_path_str = path.to_string_lossy().to_string();
-        let indexed_chunks: Vec<IndexedChunk> = chunks
+        let mut indexed_chunks: Vec<IndexedChunk> = chunks
             .iter()
             .zip(embeddings.into_iter())
             .map(|(chunk, embedding)| IndexedChunk {
-                id: uuid::Uuid::new_v4().to_string(),
+                id: String::new(),
                 content: chunk.content.clone(),
                 file_path: file_path_str.clone(),
                 start_line: chunk.start_line,
@@ -211,39 +396,84 @@ impl ChangeHandler {
                 semantic_kind: chunk.semantic_kind.map(|k| k.as_str().to_string()),
                 symbol_name: chunk.name.clone(),
                 signature: chunk.signature.clone(),
+                doc: chunk.doc.clone(),
                 parent: chunk.parent.clone(),
                 visibility: None, // TODO: Extract from AST
+                qualified_name: chunk.qualified_name.clone(),
+                tags: chunk.tags.clone(),
+                branch: None,
+                duplicate_of: None,
             })
             .collect();
+        assign_ids(&mut indexed_chunks, self.ids.as_ref());
 
         let chunk_count = indexed_chunks.len();
+        let symbols = self.symbols(&indexed_chunks);
+        self.graph
+            .replace_file(&mut self.parsers, &file_path_str, &indexed_chunks);
+        let mut field_vectors = embed_fields(
+            &self.embedder,
+            &indexed_chunks,
+            &enabled_fields(&self.config.indexer),
+            self.config.embeddings.batch_size,
+        )
+        .await?;
+        if let Some(summarizer) = &self.summarizer {
+            let vectors = summarizer
+                .embed(
+                    &self.embedder,
+                    &indexed_chunks,
+                    self.config.embeddings.batch_size,
+                )
+                .await?;
+            field_vectors.push((VectorField::Summary, vectors));
+        }
 
         // Insert chunks
         self.storage
-            .insert_chunks(indexed_chunks)
+            .upsert(indexed_chunks)
             .await
             .with_context(|| format!("Failed to insert chunks for {:?}", path))?;
+        for (field, vectors) in field_vectors {
+            self.storage
+                .upsert_field_vectors(field, vectors)
+                .await
+                .with_context(|| {
+                    format!("Failed to insert {} vectors for {:?}", field.as_str(), path)
+                })?;
+        }
 
-        Ok(chunk_count)
+        Ok((chunk_count, symbols))
     }
 
-    /// Delete all chunks for a file
+    /// Delete all chunks and graph edges for a file
     ///
     /// Returns the number of chunks that were deleted (estimated)
-    async fn delete_file_chunks(&self, path: &PathBuf) -> Result<usize> {
+    async fn delete_file_chunks(&mut self, path: &PathBuf) -> Result<usize> {
         // We don't have a way to count before deletion, so we'll just return 1
         // as a placeholder. In a real implementation, you might want to query
         // the count before deletion.
         self.storage
-            .delete_by_file(path)
+            .delete_file(path)
             .await
             .with_context(|| format!("Failed to delete chunks for {:?}", path))?;
+        self.graph.remove_file(&path.to_string_lossy());
 
         // Return 1 as a placeholder since we removed at least some chunks
         Ok(1)
     }
 }
 
+/// Symbols only in `after` (added) and only in `before` (removed)
+fn diff_symbols(
+    before: &[SymbolChange],
+    after: &[SymbolChange],
+) -> (Vec<SymbolChange>, Vec<SymbolChange>) {
+    let added = after.iter().filter(|s| !before.contains(s)).cloned();
+    let removed = before.iter().filter(|s| !after.contains(s)).cloned();
+    (added.collect(), removed.collect())
+}
+
 /// Get the modification time of a file as Unix timestamp
 fn get_file_mtime(path: &std::path::Path) -> Result<i64> {
     let metadata = fs::metadata(path)?;
@@ -277,6 +507,7 @@ mod tests {
             chunks_created: 10,
             chunks_removed: 5,
             errors: 0,
+            ..Default::default()
         };
 
         let stats2 = ProcessingStats {
@@ -286,6 +517,7 @@ mod tests {
             chunks_created: 8,
             chunks_removed: 3,
             errors: 1,
+            ..Default::default()
         };
 
         stats1.merge(&stats2);
@@ -309,4 +541,19 @@ mod tests {
         };
         assert!(with_added.has_changes());
     }
+
+    #[test]
+    fn test_diff_symbols() {
+        let symbol = |name: &str| SymbolChange {
+            file: "pool.go".to_string(),
+            name: name.to_string(),
+            kind: Some("method".to_string()),
+        };
+        let before = vec![symbol("pool.Pool.Get"), symbol("pool.Pool.Put")];
+        let after = vec![symbol("pool.Pool.Get"), symbol("pool.Pool.Drain")];
+
+        let (added, removed) = diff_symbols(&before, &after);
+        assert_eq!(added, vec![symbol("pool.Pool.Drain")]);
+        assert_eq!(removed, vec![symbol("pool.Pool.Put")]);
+    }
 }
@@ -9,27 +9,30 @@ pub mod debouncer;
 pub mod git_detector;
 pub mod handler;
 pub mod parallel_handler;
+pub mod webhook;
 
 use anyhow::{Context, Result};
 use notify::RecursiveMode;
 use notify_debouncer_full::{new_debouncer, DebouncedEvent};
 use std::path::PathBuf;
 use std::sync::Arc;
-use std::time::Duration;
+use std::time::{Duration, Instant};
 use tokio::sync::{mpsc, oneshot};
 use tracing::{debug, error, info, warn};
 
 use crate::config::Config;
 use crate::embeddings::EmbeddingGenerator;
+use crate::indexer::{dockerfile, dotenv};
 use crate::metrics::{BATCHED_FILES, MASS_CHANGES_DETECTED};
-use crate::storage::Storage;
+use crate::storage::VectorStore;
 
 pub use accumulator::{ChangeAccumulator, ChangeType, FileChange};
 pub use batch_detector::BatchDetector;
 pub use debouncer::ChangeType as DebouncerChangeType;
 pub use git_detector::{detect_git_operation_type, is_git_operation, suggest_delay_for_operation, DebouncedEvent as GitDebouncedEvent, GitOp};
-pub use handler::{ChangeHandler, ProcessingStats};
+pub use handler::{ChangeHandler, ChangedFile, ProcessingStats, SymbolChange};
 pub use parallel_handler::{BatchedEventProcessor, ParallelChangeHandler};
+pub use webhook::{Webhook, WebhookNotifier, WebhookPayload};
 
 /// Configuration for the file watcher
 #[derive(Debug, Clone)]
@@ -63,7 +66,7 @@ impl WatcherConfig {
     pub fn from_config(config: &Config, debounce_ms: u64) -> Self {
         Self {
             debounce_ms,
-            extensions: config.indexer.extensions.clone(),
+            extensions: config.indexer.indexed_extensions(),
             ignore_patterns: config.indexer.ignore_patterns.clone(),
             mass_change_threshold: 50,
             mass_change_delay_ms: 3000,
@@ -75,7 +78,9 @@ impl WatcherConfig {
 pub struct FileWatcher {
     root: PathBuf,
     config: WatcherConfig,
-    storage: Arc<Storage>,
+    storage: Arc<dyn VectorStore>,
+    /// Database path, next to which the symbol graph is stored
+    db_path: PathBuf,
     embedder: Arc<EmbeddingGenerator>,
     app_config: Config,
 }
@@ -85,7 +90,8 @@ impl FileWatcher {
     pub fn new(
         root: PathBuf,
         config: WatcherConfig,
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
+        db_path: PathBuf,
         embedder: Arc<EmbeddingGenerator>,
         app_config: Config,
     ) -> Self {
@@ -93,6 +99,7 @@ impl FileWatcher {
             root,
             config,
             storage,
+            db_path,
             embedder,
             app_config,
         }
@@ -150,6 +157,7 @@ impl FileWatcher {
         // Create change handler
         let mut handler = ChangeHandler::new(
             Arc::clone(&self.storage),
+            &self.db_path,
             Arc::clone(&self.embedder),
             self.root.clone(),
             self.app_config.clone(),
@@ -165,6 +173,15 @@ impl FileWatcher {
         // Create change accumulator
         let mut accumulator = ChangeAccumulator::new();
 
+        // Notify the configured webhook after each reindex cycle
+        let notifier = match &self.app_config.watcher.webhook {
+            Some(webhook) => {
+                info!("Webhook: {}", webhook.url);
+                Some(WebhookNotifier::spawn(Webhook::new(webhook.clone())?))
+            }
+            None => None,
+        };
+
         let mut total_stats = ProcessingStats::default();
 
         // Event loop
@@ -178,8 +195,10 @@ impl FileWatcher {
                     if !accumulator.is_empty() {
                         let batched_changes = accumulator.flush();
                         info!("Flushing {} batched changes before shutdown", batched_changes.len());
+                        let started = Instant::now();
                         if let Ok(stats) = handler.process_changes(batched_changes).await {
                             total_stats.merge(&stats);
+                            self.notify_webhook(notifier.as_ref(), &stats, started);
                         }
                     }
 
@@ -235,10 +254,12 @@ impl FileWatcher {
                             if accumulator.is_empty() {
                                 info!("Processing {} file changes", changes.len());
 
+                                let started = Instant::now();
                                 match handler.process_changes(changes).await {
                                     Ok(stats) => {
                                         total_stats.merge(&stats);
                                         Self::print_stats(&stats);
+                                        self.notify_webhook(notifier.as_ref(), &stats, started);
                                     }
                                     Err(e) => {
                                         error!("Failed to process changes: {}", e);
@@ -267,10 +288,12 @@ impl FileWatcher {
                                 // Track batch size metric
                                 BATCHED_FILES.observe(batched_changes.len() as f64);
 
+                                let started = Instant::now();
                                 match handler.process_changes(batched_changes).await {
                                     Ok(stats) => {
                                         total_stats.merge(&stats);
                                         Self::print_stats(&stats);
+                                        self.notify_webhook(notifier.as_ref(), &stats, started);
                                     }
                                     Err(e) => {
                                         error!("Failed to process batched changes: {}", e);
@@ -284,9 +307,29 @@ impl FileWatcher {
             }
         }
 
+        // Deliver pending notifications before returning
+        if let Some(notifier) = notifier {
+            notifier.finish().await;
+        }
+
         Ok(total_stats)
     }
 
+    /// Queue a webhook payload for a cycle that started at `started`, if it
+    /// changed the index
+    fn notify_webhook(
+        &self,
+        notifier: Option<&WebhookNotifier>,
+        stats: &ProcessingStats,
+        started: Instant,
+    ) {
+        if let Some(notifier) = notifier {
+            if stats.has_changes() {
+                notifier.notify(WebhookPayload::new(&self.root, stats, started.elapsed()));
+            }
+        }
+    }
+
     /// Convert notify debounced events to our FileChange type
     fn convert_events(&self, events: Vec<DebouncedEvent>) -> Vec<FileChange> {
         let mut changes = Vec::new();
@@ -342,10 +385,16 @@ impl FileWatcher {
             return true;
         }
 
-        path.extension()
+        let matches = path
+            .extension()
             .and_then(|ext| ext.to_str())
             .map(|ext| self.config.extensions.iter().any(|e| e == ext))
-            .unwrap_or(false)
+            .unwrap_or(false);
+        matches
+            || (self.config.extensions.iter().any(|e| e == dotenv::EXTENSION)
+                && dotenv::is_template(path))
+            || (self.config.extensions.iter().any(|e| e == dockerfile::EXTENSION)
+                && dockerfile::is_dockerfile(path))
     }
 
     /// Check if a path matches any ignore pattern
@@ -13,9 +13,16 @@ use tokio::sync::Semaphore;
 use tracing::{debug, error, info, warn};
 
 use crate::config::Config;
-use crate::embeddings::EmbeddingGenerator;
-use crate::indexer::Chunker;
-use crate::storage::{IndexedChunk, Storage};
+use crate::embeddings::{tokenizer_for_config, EmbeddingGenerator};
+use crate::indexer::comments::embedding_text;
+use crate::indexer::context_header::{with_context_header, ChunkContext};
+use crate::indexer::field_vectors::{embed_fields, enabled_fields};
+use crate::indexer::imports::{chunk_import_contexts, with_import_context};
+use crate::indexer::summaries::Summarizer;
+use crate::indexer::{Chunker, FlagDetector, HeaderMetadata};
+use crate::storage::{
+    assign_ids, DeterministicIds, IdGenerator, IndexedChunk, VectorField, VectorStore,
+};
 
 use super::debouncer::{ChangeType, FileChange};
 use super::handler::ProcessingStats;
@@ -23,7 +30,7 @@ use super::handler::ProcessingStats;
 /// Handles file changes and triggers re-indexing using parallel processing
 #[derive(Clone)]
 pub struct ParallelChangeHandler {
-    storage: Arc<Storage>,
+    storage: Arc<dyn VectorStore>,
     embedder: Arc<EmbeddingGenerator>,
     chunker: Arc<Chunker>,
     semaphore: Arc<Semaphore>,
@@ -31,19 +38,34 @@ pub struct ParallelChangeHandler {
     root: PathBuf,
     #[allow(dead_code)]
     config: Config,
+    ids: Arc<dyn IdGenerator>,
+    flags: Arc<FlagDetector>,
+    header_meta: Arc<HeaderMetadata>,
+    /// Writes chunk summaries when `indexer.summaries` is enabled
+    summarizer: Option<Arc<Summarizer>>,
 }
 
 impl ParallelChangeHandler {
     /// Create a new parallel change handler
     pub fn new(
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
         embedder: Arc<EmbeddingGenerator>,
         root: PathBuf,
         config: Config,
     ) -> Result<Self> {
-        let chunker = Arc::new(Chunker::new(config.indexer.chunk_size));
+        let chunker = Arc::new(
+            Chunker::new(config.indexer.chunk_size.min(config.chunk_token_limit()))
+                .with_tokenizer(tokenizer_for_config(&config.embeddings)),
+        );
         let semaphore = Arc::new(Semaphore::new(config.indexer.max_concurrent_files));
 
+        let flags = Arc::new(FlagDetector::new(&config.indexer.flag_accessors));
+        let header_meta = Arc::new(HeaderMetadata::new(&config.indexer.header_keys));
+        let summarizer = match config.indexer.summaries.enabled {
+            true => Some(Arc::new(Summarizer::new(&config.indexer.summaries)?)),
+            false => None,
+        };
+
         Ok(Self {
             storage,
             embedder,
@@ -51,9 +73,19 @@ impl ParallelChangeHandler {
             semaphore,
             root,
             config,
+            ids: Arc::new(DeterministicIds),
+            flags,
+            header_meta,
+            summarizer,
         })
     }
 
+    /// Generate chunk IDs with `ids` instead of [`DeterministicIds`]
+    pub fn with_id_generator(mut self, ids: Arc<dyn IdGenerator>) -> Self {
+        self.ids = ids;
+        self
+    }
+
     /// Process changes concurrently with controlled parallelism
     pub async fn process_changes_concurrent(
         &self,
@@ -144,7 +176,11 @@ impl ParallelChangeHandler {
         let file_header = extract_file_header(&content, 50);
 
         // Chunk the file
-        let chunks = self.chunker.chunk_file(path, &content);
+        let mut chunks = self.chunker.chunk_file(path, &content);
+        for chunk in &mut chunks {
+            self.flags.tag(chunk);
+        }
+        self.header_meta.tag(&content, &mut chunks);
 
         if chunks.is_empty() {
             debug!("No chunks generated for file: {:?}", path);
@@ -152,7 +188,30 @@ impl ParallelChangeHandler {
         }
 
         // Prepare chunks for embedding
-        let chunk_contents: Vec<String> = chunks.iter().map(|c| c.content.clone()).collect();
+        let embed_comments = self.config.indexer.embed_comments;
+        let context_headers = self.config.indexer.context_headers;
+        let relative_path = path
+            .strip_prefix(&self.root)
+            .unwrap_or(path)
+            .to_string_lossy()
+            .into_owned();
+        let imports = chunk_import_contexts(&content, &chunks, self.config.indexer.import_context);
+        let chunk_contents: Vec<String> = chunks
+            .iter()
+            .zip(&imports)
+            .map(|(c, imports)| {
+                let text = embedding_text(&c.content, c.language.as_deref(), embed_comments);
+                let text = match imports {
+                    Some(imports) => with_import_context(&text, imports),
+                    None => text,
+                };
+                if context_headers {
+                    with_context_header(&ChunkContext::of(&relative_path, c), &c.content, &text)
+                } else {
+                    text
+                }
+            })
+            .collect();
 
         // Generate embeddings using async method to avoid runtime nesting
         let embeddings = self
@@ -163,11 +222,11 @@ impl ParallelChangeHandler {
 
         // Create indexed chunks in parallel
         let file_path_str = path.to_string_lossy().to_string();
-        let indexed_chunks: Vec<IndexedChunk> = chunks
+        let mut indexed_chunks: Vec<IndexedChunk> = chunks
             .into_par_iter()
             .zip(embeddings.into_par_iter())
             .map(|(chunk, embedding)| IndexedChunk {
-                id: uuid::Uuid::new_v4().to_string(),
+                id: String::new(),
                 content: chunk.content,
                 file_path: file_path_str.clone(),
                 start_line: chunk.start_line,
@@ -179,18 +238,49 @@ impl ParallelChangeHandler {
                 semantic_kind: chunk.semantic_kind.map(|k| k.as_str().to_string()),
                 symbol_name: chunk.name,
                 signature: chunk.signature,
+                doc: chunk.doc,
                 parent: chunk.parent,
                 visibility: None, // TODO: Extract from AST
+                qualified_name: chunk.qualified_name,
+                tags: chunk.tags,
+                branch: None,
+                duplicate_of: None,
             })
             .collect();
+        assign_ids(&mut indexed_chunks, self.ids.as_ref());
 
         let chunk_count = indexed_chunks.len();
+        let mut field_vectors = embed_fields(
+            &self.embedder,
+            &indexed_chunks,
+            &enabled_fields(&self.config.indexer),
+            self.config.embeddings.batch_size,
+        )
+        .await?;
+        if let Some(summarizer) = &self.summarizer {
+            let vectors = summarizer
+                .embed(
+                    &self.embedder,
+                    &indexed_chunks,
+                    self.config.embeddings.batch_size,
+                )
+                .await?;
+            field_vectors.push((VectorField::Summary, vectors));
+        }
 
         // Insert chunks
         self.storage
-            .insert_chunks(indexed_chunks)
+            .upsert(indexed_chunks)
             .await
             .with_context(|| format!("Failed to insert chunks for {:?}", path))?;
+        for (field, vectors) in field_vectors {
+            self.storage
+                .upsert_field_vectors(field, vectors)
+                .await
+                .with_context(|| {
+                    format!("Failed to insert {} vectors for {:?}", field.as_str(), path)
+                })?;
+        }
 
         Ok(chunk_count)
     }
@@ -198,7 +288,7 @@ impl ParallelChangeHandler {
     /// Delete all chunks for a file
     async fn delete_file_chunks(&self, path: &PathBuf) -> Result<usize> {
         self.storage
-            .delete_by_file(path)
+            .delete_file(path)
             .await
             .with_context(|| format!("Failed to delete chunks for {:?}", path))?;
 
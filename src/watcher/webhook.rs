@@ -0,0 +1,316 @@
+//! Webhook notifications for watch mode
+//!
+//! With `[watcher.webhook]` configured, `coderag watch` POSTs a
+//! [`WebhookPayload`] as JSON to the webhook URL after every reindex cycle
+//! that changed the index, so CI jobs and dev tools can react to code
+//! changes. Payloads are delivered one at a time, in cycle order, by a
+//! background task; a failed delivery (connection error, timeout or non-2xx
+//! status) is retried with exponential backoff and then dropped with a
+//! warning, without stopping the watcher.
+//!
+//! The payload schema is versioned by [`PAYLOAD_VERSION`]: fields may be
+//! added within a version, but are never renamed, removed or retyped.
+
+use anyhow::{anyhow, Context, Result};
+use serde::Serialize;
+use std::path::Path;
+use std::time::Duration;
+use tokio::sync::mpsc;
+use tokio::task::JoinHandle;
+use tracing::{debug, warn};
+
+use super::handler::{ChangedFile, ProcessingStats, SymbolChange};
+use crate::config::WebhookConfig;
+
+/// Version of the payload schema
+pub const PAYLOAD_VERSION: u32 = 1;
+
+/// Event name of a reindex cycle
+pub const REINDEX_EVENT: &str = "reindex";
+
+/// JSON body sent after a reindex cycle
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct WebhookPayload {
+    /// Schema version ([`PAYLOAD_VERSION`])
+    pub version: u32,
+    /// Always `reindex`
+    pub event: String,
+    /// Absolute path of the watched project
+    pub root: String,
+    /// Files indexed or removed in this cycle
+    pub changed_files: Vec<ChangedFile>,
+    /// Symbols that appeared in the changed files
+    pub symbols_added: Vec<SymbolChange>,
+    /// Symbols that disappeared from the changed files
+    pub symbols_removed: Vec<SymbolChange>,
+    pub chunks_created: usize,
+    pub chunks_removed: usize,
+    /// Files that failed to reindex
+    pub errors: usize,
+    /// Time spent reindexing, in milliseconds
+    pub duration_ms: u64,
+    /// When the cycle finished, RFC 3339 in UTC
+    pub timestamp: String,
+}
+
+impl WebhookPayload {
+    /// Payload for a cycle that produced `stats` in `duration`
+    pub fn new(root: &Path, stats: &ProcessingStats, duration: Duration) -> Self {
+        Self {
+            version: PAYLOAD_VERSION,
+            event: REINDEX_EVENT.to_string(),
+            root: root.to_string_lossy().into_owned(),
+            changed_files: stats.changed_files.clone(),
+            symbols_added: stats.symbols_added.clone(),
+            symbols_removed: stats.symbols_removed.clone(),
+            chunks_created: stats.chunks_created,
+            chunks_removed: stats.chunks_removed,
+            errors: stats.errors,
+            duration_ms: duration.as_millis() as u64,
+            timestamp: chrono::Utc::now().to_rfc3339_opts(chrono::SecondsFormat::Millis, true),
+        }
+    }
+}
+
+/// HTTP client for one webhook
+pub struct Webhook {
+    client: reqwest::Client,
+    config: WebhookConfig,
+}
+
+impl Webhook {
+    /// Create a client for the configured webhook
+    ///
+    /// Offline mode only allows webhooks on this machine.
+    pub fn new(config: WebhookConfig) -> Result<Self> {
+        if !crate::embeddings::is_loopback(&config.url) {
+            crate::offline::ensure_network_allowed("webhook", &config.url)?;
+        }
+        let client = reqwest::Client::builder()
+            .timeout(Duration::from_millis(config.timeout_ms))
+            .user_agent(concat!("coderag/", env!("CARGO_PKG_VERSION")))
+            .build()
+            .with_context(|| "Failed to create webhook client")?;
+        Ok(Self { client, config })
+    }
+
+    /// POST `payload`, retrying failed attempts up to `retries` times.
+    ///
+    /// Returns the error of the last attempt when every attempt failed.
+    pub async fn send(&self, payload: &WebhookPayload) -> Result<()> {
+        let mut delay = Duration::from_millis(self.config.retry_delay_ms);
+        let mut attempt = 0;
+
+        loop {
+            attempt += 1;
+            let error = match self
+                .client
+                .post(&self.config.url)
+                .json(payload)
+                .send()
+                .await
+            {
+                Ok(response) if response.status().is_success() => {
+                    debug!("Webhook delivered on attempt {}", attempt);
+                    return Ok(());
+                }
+                Ok(response) => anyhow!("HTTP {}", response.status()),
+                Err(e) => anyhow!(e),
+            };
+
+            if attempt > self.config.retries {
+                return Err(error.context(format!(
+                    "Webhook {} failed after {} attempts",
+                    self.config.url, attempt
+                )));
+            }
+            debug!(
+                "Webhook attempt {} failed ({}), retrying in {}ms",
+                attempt,
+                error,
+                delay.as_millis()
+            );
+            tokio::time::sleep(delay).await;
+            delay *= 2;
+        }
+    }
+}
+
+/// Delivers payloads to a webhook in order from a background task
+pub struct WebhookNotifier {
+    sender: mpsc::UnboundedSender<WebhookPayload>,
+    task: JoinHandle<()>,
+}
+
+impl WebhookNotifier {
+    /// Start the delivery task
+    pub fn spawn(webhook: Webhook) -> Self {
+        let (sender, mut receiver) = mpsc::unbounded_channel::<WebhookPayload>();
+        let task = tokio::spawn(async move {
+            while let Some(payload) = receiver.recv().await {
+                if let Err(e) = webhook.send(&payload).await {
+                    warn!("{:#}", e);
+                }
+            }
+        });
+        Self { sender, task }
+    }
+
+    /// Queue a payload for delivery
+    pub fn notify(&self, payload: WebhookPayload) {
+        if self.sender.send(payload).is_err() {
+            warn!("Webhook delivery task has stopped; dropping payload");
+        }
+    }
+
+    /// Deliver the queued payloads, then stop
+    pub async fn finish(self) {
+        drop(self.sender);
+        let _ = self.task.await;
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::{Arc, Mutex};
+    use tokio::io::{AsyncReadExt, AsyncWriteExt};
+    use tokio::net::TcpListener;
+
+    /// Local HTTP server answering with `statuses` in turn (the last one
+    /// repeating) and recording the request bodies
+    async fn serve(statuses: Vec<u16>) -> (String, Arc<Mutex<Vec<String>>>) {
+        let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let url = format!("http://{}/hook", listener.local_addr().unwrap());
+        let bodies = Arc::new(Mutex::new(Vec::new()));
+
+        let received = Arc::clone(&bodies);
+        tokio::spawn(async move {
+            for i in 0.. {
+                let (mut socket, _) = listener.accept().await.unwrap();
+                let body = read_body(&mut socket).await;
+                received.lock().unwrap().push(body);
+
+                let status = statuses[i.min(statuses.len() - 1)];
+                let response = format!(
+                    "HTTP/1.1 {} Status\r\nContent-Length: 0\r\nConnection: close\r\n\r\n",
+                    status
+                );
+                socket.write_all(response.as_bytes()).await.unwrap();
+            }
+        });
+
+        (url, bodies)
+    }
+
+    async fn read_body(socket: &mut tokio::net::TcpStream) -> String {
+        let mut data = Vec::new();
+        let mut buf = [0u8; 4096];
+        loop {
+            let n = socket.read(&mut buf).await.unwrap();
+            data.extend_from_slice(&buf[..n]);
+            let text = String::from_utf8_lossy(&data).to_string();
+            let Some((head, body)) = text.split_once("\r\n\r\n") else {
+                continue;
+            };
+            let length: usize = head
+                .lines()
+                .find_map(|line| {
+                    let (name, value) = line.split_once(':')?;
+                    name.eq_ignore_ascii_case("content-length")
+                        .then(|| value.trim().parse().ok())?
+                })
+                .unwrap_or(0);
+            if body.len() >= length || n == 0 {
+                return body.to_string();
+            }
+        }
+    }
+
+    fn webhook(url: &str, retries: u32) -> Webhook {
+        Webhook::new(WebhookConfig {
+            url: url.to_string(),
+            retries,
+            retry_delay_ms: 10,
+            timeout_ms: 2000,
+        })
+        .unwrap()
+    }
+
+    #[tokio::test]
+    async fn test_webhook_fires_with_payload_after_change() {
+        let (url, bodies) = serve(vec![200]).await;
+
+        // What the change handler reports for an edited file
+        let symbol = |name: &str| SymbolChange {
+            file: "pool/pool.go".to_string(),
+            name: name.to_string(),
+            kind: Some("method".to_string()),
+        };
+        let stats = ProcessingStats {
+            files_modified: 1,
+            chunks_created: 3,
+            chunks_removed: 1,
+            changed_files: vec![ChangedFile {
+                path: "pool/pool.go".to_string(),
+                change: "modified".to_string(),
+                previous_path: None,
+            }],
+            symbols_added: vec![symbol("pool.Pool.Drain")],
+            symbols_removed: vec![symbol("pool.Pool.Put")],
+            ..Default::default()
+        };
+
+        let notifier = WebhookNotifier::spawn(webhook(&url, 0));
+        notifier.notify(WebhookPayload::new(
+            Path::new("/work/app"),
+            &stats,
+            Duration::from_millis(42),
+        ));
+        notifier.finish().await;
+
+        let bodies = bodies.lock().unwrap();
+        assert_eq!(bodies.len(), 1);
+        let mut payload: serde_json::Value = serde_json::from_str(&bodies[0]).unwrap();
+        let timestamp = payload["timestamp"].take();
+        assert!(chrono::DateTime::parse_from_rfc3339(timestamp.as_str().unwrap()).is_ok());
+        assert_eq!(
+            payload,
+            serde_json::json!({
+                "version": 1,
+                "event": "reindex",
+                "root": "/work/app",
+                "changed_files": [{"path": "pool/pool.go", "change": "modified"}],
+                "symbols_added": [
+                    {"file": "pool/pool.go", "name": "pool.Pool.Drain", "kind": "method"}
+                ],
+                "symbols_removed": [
+                    {"file": "pool/pool.go", "name": "pool.Pool.Put", "kind": "method"}
+                ],
+                "chunks_created": 3,
+                "chunks_removed": 1,
+                "errors": 0,
+                "duration_ms": 42,
+                "timestamp": null,
+            })
+        );
+    }
+
+    #[tokio::test]
+    async fn test_webhook_retries_failed_deliveries() {
+        let payload = WebhookPayload::new(
+            Path::new("/work/app"),
+            &ProcessingStats::default(),
+            Duration::ZERO,
+        );
+
+        let (url, bodies) = serve(vec![503, 500, 200]).await;
+        webhook(&url, 3).send(&payload).await.unwrap();
+        assert_eq!(bodies.lock().unwrap().len(), 3);
+
+        let (url, bodies) = serve(vec![500]).await;
+        let err = webhook(&url, 1).send(&payload).await.unwrap_err();
+        assert!(format!("{:#}", err).contains("failed after 2 attempts"));
+        assert_eq!(bodies.lock().unwrap().len(), 2);
+    }
+}
@@ -16,6 +16,7 @@ use tracing::{error, info};
 use super::state::AppState;
 use crate::config::SearchMode;
 use crate::metrics;
+use crate::search::{language_for_path, Highlighter, RetrievalSource};
 
 /// Embedded static files for the web UI.
 #[derive(Embed)]
@@ -57,10 +58,17 @@ pub struct SearchResultDto {
     pub end_line: usize,
     /// The content of the chunk
     pub content: String,
+    /// The content as highlighted HTML, when `search.highlight` is set
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub highlighted: Option<String>,
     /// Relevance score
     pub score: f32,
     /// First 50 lines of the file for context
     pub file_header: Option<String>,
+    /// Search paths that retrieved the result, with their scores
+    pub sources: Vec<RetrievalSource>,
+    /// Other locations of identical content, as `path:start-end`
+    pub duplicates: Vec<String>,
 }
 
 /// Statistics response payload.
@@ -121,7 +129,9 @@ pub async fn search(
     Json(request): Json<SearchRequest>,
 ) -> impl IntoResponse {
     let start = Instant::now();
-    let limit = request.limit.unwrap_or(state.config.search.default_limit);
+    let limit = state
+        .security
+        .clamp_limit(request.limit.unwrap_or(state.config.search.default_limit));
 
     info!(
         query = %request.query,
@@ -133,16 +143,23 @@ pub async fn search(
         Ok(results) => {
             let took_ms = start.elapsed().as_millis() as u64;
 
+            let mut highlighter = state.config.search.highlight.then(Highlighter::new);
             let response = SearchResponse {
                 results: results
                     .into_iter()
                     .map(|r| SearchResultDto {
+                        highlighted: highlighter
+                            .as_mut()
+                            .zip(language_for_path(&r.file_path))
+                            .map(|(h, language)| h.html(language, &r.content)),
                         file_path: r.file_path,
                         start_line: r.start_line,
                         end_line: r.end_line,
                         content: r.content,
                         score: r.score,
                         file_header: r.file_header,
+                        sources: r.sources,
+                        duplicates: r.duplicates,
                     })
                     .collect(),
                 query: request.query,
@@ -283,26 +300,19 @@ pub async fn get_file(
 ///
 /// GET /api/stats
 pub async fn stats(State(state): State<AppState>) -> impl IntoResponse {
-    let chunks = match state.storage.count_chunks().await {
-        Ok(count) => count,
+    let (chunks, files) = match state.storage.stats().await {
+        Ok(stats) => (stats.chunks, stats.files),
         Err(e) => {
-            error!(error = %e, "Failed to count chunks");
-            0
+            error!(error = %e, "Failed to count chunks and files");
+            (0, 0)
         }
     };
 
-    let files = match state.storage.list_files(None).await {
-        Ok(files) => files.len(),
-        Err(e) => {
-            error!(error = %e, "Failed to count files");
-            0
-        }
-    };
-
-    // Estimate index size from the database path
-    let index_size = std::fs::metadata(state.storage.path())
-        .map(|m| m.len())
-        .unwrap_or(0);
+    // Estimate index size from the database path; server backends have none
+    let db_path = state
+        .embedder
+        .index_path(&state.config.db_path(&state.root_path));
+    let index_size = std::fs::metadata(db_path).map(|m| m.len()).unwrap_or(0);
 
     let response = StatsResponse {
         files,
@@ -17,8 +17,10 @@
 
 pub mod handlers;
 pub mod routes;
+pub mod security;
 pub mod state;
 
+pub use security::SecurityPolicy;
 pub use state::AppState;
 
 use anyhow::{Context, Result};
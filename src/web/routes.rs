@@ -8,6 +8,7 @@ use axum::{
 };
 
 use super::handlers;
+use super::security;
 use super::state::AppState;
 
 /// Create the main router with all routes.
@@ -16,9 +17,11 @@ use super::state::AppState;
 /// * `state` - The shared application state
 ///
 /// # Returns
-/// An Axum router configured with all CodeRAG web endpoints
+/// An Axum router configured with all CodeRAG web endpoints, wrapped in the
+/// security middleware from `state.security`
 pub fn create_router(state: AppState) -> Router {
-    Router::new()
+    let policy = state.security.clone();
+    let router = Router::new()
         // Main page
         .route("/", get(handlers::index_page))
         // API endpoints
@@ -32,5 +35,7 @@ pub fn create_router(state: AppState) -> Router {
         .route("/metrics", get(handlers::metrics_handler))
         // Static files fallback
         .fallback(get(handlers::static_file))
-        .with_state(state)
+        .with_state(state);
+
+    security::protect(router, policy)
 }
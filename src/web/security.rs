@@ -0,0 +1,300 @@
+//! Access control for HTTP endpoints.
+//!
+//! Both the web UI and the MCP HTTP/SSE transport are plain axum routers, so
+//! they share one middleware that enforces the `[server.security]` settings:
+//! optional bearer-token authentication, a read-only mode that rejects
+//! index-mutating endpoints, and a request body size limit. Result-count
+//! clamping is applied by the handlers through [`SecurityPolicy::clamp_limit`].
+
+use anyhow::{bail, Result};
+use axum::{
+    extract::{DefaultBodyLimit, Request, State},
+    http::{header, Method, StatusCode},
+    middleware::{self, Next},
+    response::{IntoResponse, Response},
+    Json, Router,
+};
+use std::sync::Arc;
+use tracing::warn;
+
+use crate::config::SecurityConfig;
+
+/// Environment variable consulted when no token is configured
+const AUTH_TOKEN_ENV: &str = "CODERAG_AUTH_TOKEN";
+
+/// Endpoints that modify the index and are disabled in read-only mode
+const WRITE_ROUTES: &[&str] = &["/api/reindex"];
+
+/// Endpoints that stay reachable without a token (liveness probes)
+const PUBLIC_ROUTES: &[&str] = &["/health"];
+
+/// Resolved security settings shared by request handlers
+#[derive(Clone)]
+pub struct SecurityPolicy {
+    /// Reject requests to index-mutating endpoints
+    pub read_only: bool,
+    /// Bearer token required on requests, if any
+    auth_token: Option<String>,
+    /// Maximum number of results per request
+    pub max_limit: usize,
+    /// Maximum request body size in bytes
+    pub max_request_bytes: usize,
+}
+
+impl std::fmt::Debug for SecurityPolicy {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_struct("SecurityPolicy")
+            .field("read_only", &self.read_only)
+            .field("auth_token", &self.auth_token.as_ref().map(|_| "<redacted>"))
+            .field("max_limit", &self.max_limit)
+            .field("max_request_bytes", &self.max_request_bytes)
+            .finish()
+    }
+}
+
+impl Default for SecurityPolicy {
+    fn default() -> Self {
+        Self::from_config(&SecurityConfig::default())
+            .expect("default security config has no token reference")
+    }
+}
+
+impl SecurityPolicy {
+    /// Build a policy from configuration, resolving `${VAR}` token references
+    /// and the `CODERAG_AUTH_TOKEN` fallback.
+    ///
+    /// A `${VAR}` reference to a missing or empty variable is an error rather
+    /// than disabling authentication, so a misconfigured server refuses to
+    /// start instead of running unprotected.
+    pub fn from_config(config: &SecurityConfig) -> Result<Self> {
+        let auth_token = match config.auth_token.as_deref() {
+            Some(token) if token.starts_with("${") && token.ends_with('}') => {
+                let var = &token[2..token.len() - 1];
+                match std::env::var(var) {
+                    Ok(value) if !value.is_empty() => Some(value),
+                    _ => bail!(
+                        "server.security.auth_token references ${{{}}}, which is not set or empty",
+                        var
+                    ),
+                }
+            }
+            Some(token) if !token.is_empty() => Some(token.to_string()),
+            _ => std::env::var(AUTH_TOKEN_ENV).ok().filter(|t| !t.is_empty()),
+        };
+
+        Ok(Self {
+            read_only: config.read_only,
+            auth_token,
+            max_limit: config.max_limit.max(1),
+            max_request_bytes: config.max_request_bytes,
+        })
+    }
+
+    /// Set the bearer token explicitly
+    pub fn with_auth_token(mut self, token: impl Into<String>) -> Self {
+        self.auth_token = Some(token.into());
+        self
+    }
+
+    /// Whether requests must carry a bearer token
+    pub fn requires_auth(&self) -> bool {
+        self.auth_token.is_some()
+    }
+
+    /// Clamp a requested result count to the configured maximum
+    pub fn clamp_limit(&self, requested: usize) -> usize {
+        requested.clamp(1, self.max_limit)
+    }
+
+    /// Check whether a request targets an endpoint that mutates the index
+    pub fn is_write_request(method: &Method, path: &str) -> bool {
+        *method != Method::GET && *method != Method::HEAD && WRITE_ROUTES.contains(&path)
+    }
+
+    fn is_authorized(&self, request: &Request) -> bool {
+        let Some(expected) = self.auth_token.as_deref() else {
+            return true;
+        };
+        if PUBLIC_ROUTES.contains(&request.uri().path()) {
+            return true;
+        }
+
+        request
+            .headers()
+            .get(header::AUTHORIZATION)
+            .and_then(|v| v.to_str().ok())
+            .and_then(|v| v.strip_prefix("Bearer "))
+            .map(|token| constant_time_eq(token.as_bytes(), expected.as_bytes()))
+            .unwrap_or(false)
+    }
+}
+
+/// Apply the security middleware and body limit to a router
+pub fn protect(router: Router, policy: Arc<SecurityPolicy>) -> Router {
+    let body_limit = policy.max_request_bytes;
+    router
+        .layer(middleware::from_fn_with_state(policy, enforce))
+        .layer(DefaultBodyLimit::max(body_limit))
+}
+
+/// Middleware enforcing authentication and read-only mode
+async fn enforce(
+    State(policy): State<Arc<SecurityPolicy>>,
+    request: Request,
+    next: Next,
+) -> Response {
+    if !policy.is_authorized(&request) {
+        warn!(path = %request.uri().path(), "Rejected unauthenticated request");
+        return error_response(StatusCode::UNAUTHORIZED, "Missing or invalid bearer token");
+    }
+
+    if policy.read_only && SecurityPolicy::is_write_request(request.method(), request.uri().path())
+    {
+        warn!(path = %request.uri().path(), "Rejected write request in read-only mode");
+        return error_response(
+            StatusCode::FORBIDDEN,
+            "Server is running in read-only mode",
+        );
+    }
+
+    next.run(request).await
+}
+
+fn error_response(status: StatusCode, message: &str) -> Response {
+    (status, Json(serde_json::json!({ "error": message }))).into_response()
+}
+
+/// Compare two byte strings without short-circuiting on the first mismatch
+fn constant_time_eq(a: &[u8], b: &[u8]) -> bool {
+    if a.len() != b.len() {
+        return false;
+    }
+    a.iter().zip(b).fold(0u8, |acc, (x, y)| acc | (x ^ y)) == 0
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::body::Body;
+    use axum::routing::{get, post};
+    use tower::ServiceExt;
+
+    fn test_router(policy: SecurityPolicy) -> Router {
+        let router = Router::new()
+            .route("/api/search", post(|| async { "results" }))
+            .route("/api/reindex", post(|| async { "reindexed" }))
+            .route("/api/stats", get(|| async { "stats" }))
+            .route("/health", get(|| async { "ok" }));
+        protect(router, Arc::new(policy))
+    }
+
+    fn policy(read_only: bool) -> SecurityPolicy {
+        SecurityPolicy {
+            read_only,
+            auth_token: None,
+            max_limit: 100,
+            max_request_bytes: 1024,
+        }
+    }
+
+    async fn status(router: Router, method: Method, path: &str, token: Option<&str>) -> StatusCode {
+        let mut builder = Request::builder().method(method).uri(path);
+        if let Some(token) = token {
+            builder = builder.header(header::AUTHORIZATION, format!("Bearer {}", token));
+        }
+        let request = builder.body(Body::empty()).unwrap();
+        router.oneshot(request).await.unwrap().status()
+    }
+
+    #[tokio::test]
+    async fn test_write_endpoints_forbidden_in_read_only_mode() {
+        let router = test_router(policy(true));
+        assert_eq!(
+            status(router.clone(), Method::POST, "/api/reindex", None).await,
+            StatusCode::FORBIDDEN
+        );
+        // Read endpoints keep working
+        assert_eq!(
+            status(router.clone(), Method::POST, "/api/search", None).await,
+            StatusCode::OK
+        );
+        assert_eq!(
+            status(router, Method::GET, "/api/stats", None).await,
+            StatusCode::OK
+        );
+    }
+
+    #[tokio::test]
+    async fn test_write_endpoints_allowed_when_writable() {
+        let router = test_router(policy(false));
+        assert_eq!(
+            status(router, Method::POST, "/api/reindex", None).await,
+            StatusCode::OK
+        );
+    }
+
+    #[tokio::test]
+    async fn test_bearer_token_required() {
+        let router = test_router(policy(false).with_auth_token("secret"));
+        assert_eq!(
+            status(router.clone(), Method::GET, "/api/stats", None).await,
+            StatusCode::UNAUTHORIZED
+        );
+        assert_eq!(
+            status(router.clone(), Method::GET, "/api/stats", Some("wrong")).await,
+            StatusCode::UNAUTHORIZED
+        );
+        assert_eq!(
+            status(router.clone(), Method::GET, "/api/stats", Some("secret")).await,
+            StatusCode::OK
+        );
+        // Health checks stay public
+        assert_eq!(
+            status(router, Method::GET, "/health", None).await,
+            StatusCode::OK
+        );
+    }
+
+    #[test]
+    fn test_clamp_limit() {
+        let policy = policy(false);
+        assert_eq!(policy.clamp_limit(10), 10);
+        assert_eq!(policy.clamp_limit(10_000), 100);
+        assert_eq!(policy.clamp_limit(0), 1);
+    }
+
+    #[test]
+    fn test_from_config_resolves_token_reference() {
+        std::env::set_var("CODERAG_TEST_AUTH_TOKEN_SET", "secret");
+        let config = SecurityConfig {
+            auth_token: Some("${CODERAG_TEST_AUTH_TOKEN_SET}".to_string()),
+            ..SecurityConfig::default()
+        };
+        let policy = SecurityPolicy::from_config(&config).unwrap();
+        assert_eq!(policy.auth_token.as_deref(), Some("secret"));
+    }
+
+    #[test]
+    fn test_from_config_rejects_missing_token_reference() {
+        std::env::remove_var("CODERAG_TEST_AUTH_TOKEN_UNSET");
+        let config = SecurityConfig {
+            auth_token: Some("${CODERAG_TEST_AUTH_TOKEN_UNSET}".to_string()),
+            ..SecurityConfig::default()
+        };
+        let err = SecurityPolicy::from_config(&config).unwrap_err();
+        assert!(err.to_string().contains("CODERAG_TEST_AUTH_TOKEN_UNSET"));
+
+        std::env::set_var("CODERAG_TEST_AUTH_TOKEN_EMPTY", "");
+        let config = SecurityConfig {
+            auth_token: Some("${CODERAG_TEST_AUTH_TOKEN_EMPTY}".to_string()),
+            ..SecurityConfig::default()
+        };
+        assert!(SecurityPolicy::from_config(&config).is_err());
+    }
+
+    #[test]
+    fn test_debug_redacts_token() {
+        let policy = policy(false).with_auth_token("secret");
+        assert!(!format!("{:?}", policy).contains("secret"));
+    }
+}
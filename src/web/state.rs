@@ -8,7 +8,9 @@ use std::sync::Arc;
 use crate::config::Config;
 use crate::embeddings::EmbeddingGenerator;
 use crate::search::traits::Search;
-use crate::storage::Storage;
+use crate::storage::VectorStore;
+
+use super::security::SecurityPolicy;
 
 /// Shared application state for the web server.
 ///
@@ -19,30 +21,36 @@ pub struct AppState {
     /// The search engine (vector, BM25, or hybrid)
     pub search_engine: Arc<dyn Search>,
     /// Storage backend for the vector database
-    pub storage: Arc<Storage>,
+    pub storage: Arc<dyn VectorStore>,
     /// Embedding generator for query embeddings
     pub embedder: Arc<EmbeddingGenerator>,
     /// Configuration
     pub config: Config,
     /// Root path of the project
     pub root_path: PathBuf,
+    /// Access restrictions derived from `[server.security]`
+    pub security: Arc<SecurityPolicy>,
 }
 
 impl AppState {
     /// Create a new application state.
+    ///
+    /// Fails when `[server.security]` references an unset auth token variable.
     pub fn new(
         search_engine: Arc<dyn Search>,
-        storage: Arc<Storage>,
+        storage: Arc<dyn VectorStore>,
         embedder: Arc<EmbeddingGenerator>,
         config: Config,
         root_path: PathBuf,
-    ) -> Self {
-        Self {
+    ) -> anyhow::Result<Self> {
+        let security = Arc::new(SecurityPolicy::from_config(&config.server.security)?);
+        Ok(Self {
             search_engine,
             storage,
             embedder,
             config,
             root_path,
-        }
+            security,
+        })
     }
 }
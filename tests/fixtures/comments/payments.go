@@ -0,0 +1,27 @@
+package payments
+
+import "errors"
+
+// ErrDeclined is returned when the gateway declines a card.
+var ErrDeclined = errors.New("card declined")
+
+// Capture captures an authorized payment.
+func Capture(gw Gateway, id string) error {
+	var err error
+	// Retry the payment capture at most three times so a flaky gateway
+	// does not double-charge the customer.
+	for attempt := 0; attempt < 3; attempt++ {
+		if err = gw.Capture(id); err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrDeclined) {
+			return err
+		}
+	}
+	return err
+}
+
+// Refund refunds a captured payment.
+func Refund(gw Gateway, id string) error {
+	return gw.Refund(id)
+}
@@ -0,0 +1,24 @@
+package tasks
+
+import "time"
+
+// Task is a unit of work tracked by the scheduler.
+type Task struct {
+	ID       TaskId    `json:"id"`
+	Title    string    `json:"title"`
+	Status   string    `json:"status"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// TaskId identifies a task.
+type TaskId string
+
+// queue holds tasks waiting to run; it is not part of the API.
+type queue struct {
+	pending []Task
+}
+
+// NewTask creates a pending task.
+func NewTask(id TaskId, title string) Task {
+	return Task{ID: id, Title: title, Status: "pending"}
+}
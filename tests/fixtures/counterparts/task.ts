@@ -0,0 +1,18 @@
+// Task as returned by the scheduler API.
+export interface Task {
+  id: TaskID;
+  title: string;
+  status: string;
+  deadline: string;
+}
+
+export type TaskID = string;
+
+// Internal view state, not exported.
+interface queue {
+  pending: Task[];
+}
+
+export function newTask(id: TaskID, title: string): Task {
+  return { id, title, status: "pending", deadline: "" };
+}
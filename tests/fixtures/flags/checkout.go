@@ -0,0 +1,32 @@
+package checkout
+
+import (
+	"context"
+
+	"example.com/platform/flags"
+)
+
+// Checkout places an order, using the new flow when it is enabled.
+func Checkout(ctx context.Context, cart Cart) (Order, error) {
+	if flags.Enabled("new-checkout") {
+		return newCheckout(ctx, cart)
+	}
+	return legacyCheckout(ctx, cart)
+}
+
+// Receipt renders the order confirmation.
+func Receipt(order Order) string {
+	if flags.Enabled("dark-receipts") && flags.Enabled("new-checkout") {
+		return renderDark(order)
+	}
+	return render(order)
+}
+
+// Total sums the cart without any flag checks.
+func Total(cart Cart) int {
+	total := 0
+	for _, item := range cart.Items {
+		total += item.Price
+	}
+	return total
+}
@@ -0,0 +1,30 @@
+// Copyright 2024 Example Corp. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+// Owner: payments-team
+// Module: billing/invoices
+
+// Package invoices builds and totals customer invoices.
+package invoices
+
+// Owner: not-a-header
+
+// Invoice is a bill sent to a customer.
+type Invoice struct {
+	ID    string
+	Lines []Line
+}
+
+// Line is one billed item.
+type Line struct {
+	Amount int64
+}
+
+// Total sums the invoice lines.
+func (i *Invoice) Total() int64 {
+	var total int64
+	for _, line := range i.Lines {
+		total += line.Amount
+	}
+	return total
+}
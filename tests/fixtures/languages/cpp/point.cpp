@@ -0,0 +1,13 @@
+#include "point.h"
+
+#include <cmath>
+
+Point::Point(double x, double y) : x(x), y(y) {}
+
+double Point::norm() const {
+    return std::sqrt(x * x + y * y);
+}
+
+double distance(const Point &a, const Point &b) {
+    return Point(a.x - b.x, a.y - b.y).norm();
+}
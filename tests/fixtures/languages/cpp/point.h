@@ -0,0 +1,22 @@
+#ifndef GEOMETRY_POINT_H
+#define GEOMETRY_POINT_H
+
+/// A point in the plane
+class Point {
+public:
+    Point(double x, double y);
+
+    /// Distance from the origin
+    double norm() const;
+
+#ifdef GEOMETRY_DEBUG
+    void dump() const;
+#endif
+
+    double x;
+    double y;
+};
+
+double distance(const Point &a, const Point &b);
+
+#endif
@@ -0,0 +1,32 @@
+using System.Threading.Tasks;
+
+namespace Contoso.Billing;
+
+/// <summary>
+/// Charges and refunds orders.
+/// </summary>
+public class PaymentService
+{
+    private readonly IPaymentStore _store;
+
+    public PaymentService(IPaymentStore store)
+    {
+        _store = store;
+    }
+
+    /// <summary>Refunds a paid order.</summary>
+    /// <param name="orderId">The order to refund.</param>
+    public async Task<bool> Refund(string orderId)
+    {
+        var payment = await _store.Find(orderId);
+        return payment != null && await _store.Reverse(payment);
+    }
+
+    internal sealed record Receipt(string OrderId, decimal Amount);
+}
+
+public interface IPaymentStore
+{
+    Task<Payment> Find(string orderId);
+    Task<bool> Reverse(Payment payment);
+}
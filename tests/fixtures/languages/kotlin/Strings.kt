@@ -0,0 +1,16 @@
+package com.acme.text
+
+/**
+ * Whether this string looks like an email address.
+ */
+fun String.isEmail(): Boolean =
+    contains("@") && substringAfter("@").contains(".")
+
+fun String.slugify(): String =
+    lowercase().replace(Regex("[^a-z0-9]+"), "-").trim('-')
+
+class Slugger(private val separator: String) {
+    fun slug(text: String): String = text.slugify().replace("-", separator)
+}
+
+fun joinSlugs(parts: List<String>): String = parts.joinToString("/") { it.slugify() }
@@ -0,0 +1,37 @@
+<?php
+namespace App\Billing;
+
+trait FormatsMoney
+{
+    public function formatCents(int $cents): string
+    {
+        return number_format($cents / 100, 2);
+    }
+}
+
+class Invoice
+{
+    use FormatsMoney;
+
+    /**
+     * Total in cents, tax included.
+     */
+    public function total(): int
+    {
+        return array_sum(array_column($this->lines, 'amount'));
+    }
+}
+
+function render_invoice(Invoice $invoice): void
+{
+    ?>
+    <h1>Invoice</h1>
+    <p>Total: <?= $invoice->formatCents($invoice->total()) ?></p>
+    <?php
+}
+?>
+<html>
+<body>
+<?php render_invoice(new Invoice()); ?>
+</body>
+</html>
@@ -0,0 +1,21 @@
+module Billing
+  # An issued invoice and its lines.
+  class Invoice < ApplicationRecord
+    has_many :lines, dependent: :destroy
+    belongs_to :customer
+
+    scope :overdue, -> { where(paid: false).where("due_on < ?", Date.current) }
+
+    validates :number, presence: true
+
+    # Sum of line amounts plus tax.
+    def total(tax_rate: 0)
+      subtotal = lines.sum(&:amount)
+      subtotal + subtotal * tax_rate
+    end
+
+    def self.settle_all
+      overdue.find_each(&:settle)
+    end
+  end
+end
@@ -0,0 +1,22 @@
+package com.acme.etl
+
+import org.apache.spark.sql.{DataFrame, SparkSession}
+
+/** Reads raw invoices and writes daily totals. */
+object DailyTotals {
+  implicit val spark: SparkSession = SparkSession.builder().getOrCreate()
+
+  case class Total(day: String, cents: Long)
+
+  def run(input: String, output: String): Unit = {
+    val totals = load(input).groupBy("day").sum("cents")
+    totals.write.parquet(output)
+  }
+
+  private def load(path: String): DataFrame =
+    spark.read.json(path)
+
+  implicit class RichFrame(val df: DataFrame) {
+    def nonEmpty: Boolean = !df.isEmpty
+  }
+}
@@ -0,0 +1,20 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+REGISTRY="${REGISTRY:-ghcr.io/acme}"
+
+# Build and push the image for a git revision.
+build_image() {
+  local revision="$1"
+  docker build -t "${REGISTRY}/billing:${revision}" .
+  docker push "${REGISTRY}/billing:${revision}"
+}
+
+function rollout {
+  kubectl set image deployment/billing "billing=${REGISTRY}/billing:$1"
+  kubectl rollout status deployment/billing --timeout=120s
+}
+
+revision="$(git rev-parse --short HEAD)"
+build_image "$revision"
+rollout "$revision"
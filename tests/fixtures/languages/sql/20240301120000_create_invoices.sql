@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- Invoices issued to a customer account.
+CREATE TABLE invoices (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id),
+    total_cents integer NOT NULL DEFAULT 0,
+    paid_at timestamptz,
+    CONSTRAINT invoices_total_positive CHECK (total_cents >= 0)
+);
+
+CREATE INDEX invoices_user_id_idx ON invoices (user_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION mark_paid(invoice_id bigint) RETURNS void AS $$
+BEGIN
+    UPDATE invoices SET paid_at = now() WHERE id = invoice_id;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+INSERT INTO invoices (user_id, total_cents) VALUES (1, 1200);
+INSERT INTO invoices (user_id, total_cents) VALUES (2, 4500);
+
+-- +goose Down
+DROP FUNCTION mark_paid(bigint);
+DROP TABLE invoices;
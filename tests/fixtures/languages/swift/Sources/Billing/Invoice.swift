@@ -0,0 +1,31 @@
+import Foundation
+
+/// Rounds a money amount to whole cents.
+@propertyWrapper
+public struct Cents {
+    private var value: Decimal
+    public var wrappedValue: Decimal {
+        get { value }
+        set { value = (newValue * 100).rounded() / 100 }
+    }
+    public init(wrappedValue: Decimal) { value = wrappedValue }
+}
+
+public protocol Payable {
+    func pay() async throws
+}
+
+public struct Invoice {
+    @Cents public var subtotal: Decimal
+    public var taxRate: Decimal
+}
+
+extension Invoice: Payable {
+    /// Subtotal plus tax.
+    public var total: Decimal { subtotal * (1 + taxRate) }
+
+    /// Charges the invoice total.
+    public func pay() async throws {
+        try await PaymentGateway.shared.charge(total)
+    }
+}
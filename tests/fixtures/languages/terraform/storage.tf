@@ -0,0 +1,34 @@
+variable "environment" {
+  type        = string
+  description = "Deployment environment, e.g. staging or production"
+}
+
+# Access logs written by the CDN.
+resource "aws_s3_bucket" "logs" {
+  bucket = "acme-logs-${var.environment}"
+
+  tags = {
+    Environment = var.environment
+  }
+}
+
+# Keep every version of the log objects.
+resource "aws_s3_bucket_versioning" "logs" {
+  bucket = aws_s3_bucket.logs.id
+
+  versioning_configuration {
+    status = "Enabled"
+  }
+}
+
+module "cdn" {
+  source = "terraform-aws-modules/cloudfront/aws"
+
+  logging_config = {
+    bucket = aws_s3_bucket.logs.bucket_domain_name
+  }
+}
+
+output "logs_bucket_arn" {
+  value = aws_s3_bucket.logs.arn
+}
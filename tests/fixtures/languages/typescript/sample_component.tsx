@@ -0,0 +1,39 @@
+import React, { forwardRef, memo, useState } from "react";
+
+/** Props of the task list. */
+export interface TaskListProps {
+  tasks: string[];
+  onSelect: (task: string) => void;
+}
+
+/** Renders one task. */
+const TaskItem = memo(({ task }: { task: string }) => <li className="task">{task}</li>);
+
+/** Lists tasks and tracks the selected one. */
+export const TaskList = ({ tasks, onSelect }: TaskListProps) => {
+  const [selected, setSelected] = useState<string | null>(null);
+  return (
+    <ul>
+      {tasks.map((task) => (
+        <TaskItem key={task} task={task} />
+      ))}
+      {selected && <p>Selected: {selected}</p>}
+    </ul>
+  );
+};
+
+export const SearchInput = forwardRef<HTMLInputElement, { placeholder: string }>(
+  function SearchInput({ placeholder }, ref) {
+    return <input ref={ref} placeholder={placeholder} />;
+  }
+);
+
+export function App() {
+  return <TaskList tasks={["a", "b"]} onSelect={(task) => console.log(task)} />;
+}
+
+export class ErrorBoundary extends React.Component<{ children: React.ReactNode }> {
+  render() {
+    return <div className="boundary">{this.props.children}</div>;
+  }
+}
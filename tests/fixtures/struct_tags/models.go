@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// User is a row of the users table.
+type User struct {
+	ID        int64     `json:"id" db:"user_id" gorm:"column:user_id;primaryKey"`
+	Email     string    `json:"email,omitempty" db:"email"`
+	Password  string    `json:"-" db:"password_hash"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	Audit
+}
+
+// Audit records who changed a row.
+type Audit struct {
+	UpdatedBy string `json:"updated_by" db:"updated_by"`
+}
+
+// WorkerConfig is read from the service config file.
+type WorkerConfig struct {
+	Workers int    `json:"worker_count" yaml:"worker_count"`
+	Queue   string `json:"queue"`
+	Verbose bool
+}
+
+// Describe returns a raw string that only looks like a tag.
+func Describe() string {
+	return `json:"not_a_field"`
+}
@@ -0,0 +1,25 @@
+package pool
+
+// Pool hands out reusable connections.
+type Pool struct {
+	free []int
+}
+
+// Acquire takes a connection from the pool.
+func Acquire(p *Pool) int {
+	n := p.free[0]
+	p.free = p.free[1:]
+	return n
+}
+
+// Release returns a connection to the pool.
+func Release(p *Pool, n int) {
+	p.free = append(p.free, n)
+}
+
+// grow is internal, so it is never reported.
+func grow(p *Pool, n int) {
+	for i := 0; i < n; i++ {
+		p.free = append(p.free, i)
+	}
+}
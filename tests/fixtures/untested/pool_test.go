@@ -0,0 +1,10 @@
+package pool
+
+import "testing"
+
+func TestAcquire(t *testing.T) {
+	p := &Pool{free: []int{1, 2}}
+	if n := Acquire(p); n != 1 {
+		t.Fatalf("got %d, want 1", n)
+	}
+}
@@ -15,8 +15,13 @@ pub fn create_test_chunk(id: &str, content: &str, file_path: &str) -> IndexedChu
         semantic_kind: None,
         symbol_name: None,
         signature: None,
+        doc: None,
         parent: None,
         visibility: None,
+        qualified_name: None,
+        tags: Vec::new(),
+        branch: None,
+        duplicate_of: None,
     }
 }
 
@@ -52,7 +57,12 @@ pub fn create_test_chunk_with_vector(
         semantic_kind: None,
         symbol_name: None,
         signature: None,
+        doc: None,
         parent: None,
         visibility: None,
+        qualified_name: None,
+        tags: Vec::new(),
+        branch: None,
+        duplicate_of: None,
     }
 }
\ No newline at end of file
@@ -27,8 +27,13 @@ async fn setup_test_environment() -> Result<(TempDir, Arc<Storage>)> {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         },
         IndexedChunk {
             id: "chunk_2".to_string(),
@@ -43,8 +48,13 @@ async fn setup_test_environment() -> Result<(TempDir, Arc<Storage>)> {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         },
         IndexedChunk {
             id: "chunk_3".to_string(),
@@ -59,8 +69,13 @@ async fn setup_test_environment() -> Result<(TempDir, Arc<Storage>)> {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         },
     ];
 
@@ -212,8 +227,13 @@ async fn test_file_path_handling() -> Result<()> {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         },
         IndexedChunk {
             id: "2".to_string(),
@@ -228,8 +248,13 @@ async fn test_file_path_handling() -> Result<()> {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         },
         IndexedChunk {
             id: "3".to_string(),
@@ -244,8 +269,13 @@ async fn test_file_path_handling() -> Result<()> {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         },
     ];
 
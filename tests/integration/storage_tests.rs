@@ -2,7 +2,7 @@ use anyhow::Result;
 use std::path::PathBuf;
 use tempfile::TempDir;
 
-use coderag::storage::{IndexedChunk, Storage};
+use coderag::storage::{content_hash, IndexedChunk, Storage};
 
 /// Helper function to create test chunks
 fn create_test_chunk(id: &str, content: &str, file_path: &str) -> IndexedChunk {
@@ -19,8 +19,13 @@ fn create_test_chunk(id: &str, content: &str, file_path: &str) -> IndexedChunk {
         semantic_kind: None,
         symbol_name: None,
         signature: None,
+        doc: None,
         parent: None,
         visibility: None,
+        qualified_name: None,
+        tags: Vec::new(),
+        branch: None,
+        duplicate_of: None,
     }
 }
 
@@ -311,4 +316,185 @@ async fn test_duplicate_chunk_ids() -> Result<()> {
     assert!(contents.contains(&"second content".to_string()));
 
     Ok(())
-}
\ No newline at end of file
+}
+#[tokio::test]
+async fn test_integrity_check_reports_injected_inconsistency() -> Result<()> {
+    use coderag::storage::integrity::{check_chunks, Repair, Severity};
+
+    let temp_dir = TempDir::new()?;
+    let db_path = temp_dir.path().join("test.lance");
+    let storage = Storage::new(&db_path, 768).await?;
+
+    let mut backwards = create_test_chunk("broken", "fn broken() {}", "broken.rs");
+    backwards.start_line = 20;
+    backwards.end_line = 5;
+    storage
+        .insert_chunks(vec![create_test_chunk("ok", "fn ok() {}", "ok.rs"), backwards])
+        .await?;
+
+    let chunks = storage.get_all_chunks().await?;
+    let vectors = storage.vectors_by_id().await?;
+    assert_eq!(vectors.len(), 2);
+
+    let issues = check_chunks(&chunks, &vectors, storage.vector_dimension());
+    assert_eq!(issues.len(), 1, "Only the injected chunk is reported: {:?}", issues);
+    assert_eq!(issues[0].check, "line-range");
+    assert_eq!(issues[0].severity, Severity::Error);
+    assert_eq!(issues[0].repair, Some(Repair::DropFile("broken.rs".to_string())));
+
+    // Applying the repair leaves a consistent store
+    storage.delete_by_file(std::path::Path::new("broken.rs")).await?;
+    let chunks = storage.get_all_chunks().await?;
+    assert!(check_chunks(&chunks, &storage.vectors_by_id().await?, 768).is_empty());
+
+    Ok(())
+}
+
+#[tokio::test]
+async fn test_custom_id_generator_keys_store_and_symbols() -> Result<()> {
+    use coderag::storage::{assign_ids, ChunkKey, IdGenerator};
+    use coderag::symbol::SymbolIndex;
+
+    /// Keys chunks the way an external catalog does: `path#symbol@line`
+    struct CatalogIds;
+
+    impl IdGenerator for CatalogIds {
+        fn chunk_id(&self, key: &ChunkKey<'_>) -> String {
+            format!(
+                "acme:{}#{}@{}",
+                key.file_path,
+                key.symbol.unwrap_or("-"),
+                key.start_line
+            )
+        }
+    }
+
+    let temp_dir = TempDir::new()?;
+    let db_path = temp_dir.path().join("test.lance");
+    let storage = Storage::new(&db_path, 768).await?;
+
+    let mut submit = create_test_chunk("", "func (p *WorkerPool) Submit() {}", "pool.go");
+    submit.symbol_name = Some("Submit".to_string());
+    submit.qualified_name = Some("pool.WorkerPool.Submit".to_string());
+    let mut chunks = vec![submit, create_test_chunk("", "// notes", "notes.go")];
+    assign_ids(&mut chunks, &CatalogIds);
+    storage.insert_chunks(chunks).await?;
+
+    let mut ids: Vec<String> = storage
+        .get_all_chunks()
+        .await?
+        .into_iter()
+        .map(|c| c.id)
+        .collect();
+    ids.sort();
+    assert_eq!(
+        ids,
+        vec!["acme:notes.go#-@1", "acme:pool.go#pool.WorkerPool.Submit@1"]
+    );
+
+    // Symbol references point at the generated IDs
+    let symbols = SymbolIndex::build_from_chunks(&storage.get_all_chunks().await?);
+    assert_eq!(
+        symbols.find_by_name("Submit")[0].chunk_id,
+        "acme:pool.go#pool.WorkerPool.Submit@1"
+    );
+
+    Ok(())
+}
+
+#[tokio::test]
+async fn test_identical_input_gives_identical_store_and_ranking() -> Result<()> {
+    use coderag::search::RrfFusion;
+    use coderag::storage::{assign_ids, DeterministicIds};
+
+    // Two ties: the vectors of b.rs and c.rs are equally close to the query
+    let chunks = || {
+        let mut chunks = vec![
+            create_test_chunk_with_vector("", "fn a() {}", "a.rs", vec![1.0; 768]),
+            create_test_chunk_with_vector("", "fn b() {}", "b.rs", vec![0.5; 768]),
+            create_test_chunk_with_vector("", "fn c() {}", "c.rs", vec![0.5; 768]),
+        ];
+        assign_ids(&mut chunks, &DeterministicIds);
+        chunks
+    };
+
+    let mut runs = Vec::new();
+    for _ in 0..2 {
+        let temp_dir = TempDir::new()?;
+        let storage = Storage::new(&temp_dir.path().join("test.lance"), 768).await?;
+        storage.insert_chunks(chunks()).await?;
+
+        let stored: Vec<(String, String, Vec<f32>)> = storage
+            .get_all_chunks()
+            .await?
+            .into_iter()
+            .map(|c| (c.id, c.file_path, c.vector))
+            .collect();
+        let vector = storage.search(vec![0.9; 768], 3).await?;
+        let keyword: Vec<_> = vector.iter().rev().cloned().collect();
+        let fused: Vec<String> = RrfFusion::new()
+            .with_seed(42)
+            .fuse(vec![(vector.clone(), 0.5), (keyword, 0.5)], 3)
+            .into_iter()
+            .map(|r| r.file_path)
+            .collect();
+        let vector: Vec<String> = vector.into_iter().map(|r| r.file_path).collect();
+        runs.push((stored, vector, fused));
+    }
+
+    // Same chunk IDs and vectors, same vector ranking, same order of the
+    // fused ties
+    assert_eq!(runs[0], runs[1]);
+    assert_eq!(runs[0].0.len(), 3);
+
+    Ok(())
+}
+
+#[tokio::test]
+async fn test_identical_chunks_share_one_vector() -> Result<()> {
+    let temp_dir = TempDir::new()?;
+    let db_path = temp_dir.path().join("test.lance");
+    let storage = Storage::new(&db_path, 768).await?;
+
+    let body = "fn clamp(v: i32) -> i32 { v.max(0) }";
+    storage
+        .insert_chunks(vec![
+            create_test_chunk_with_vector("a", body, "src/clamp.rs", vec![1.0; 768]),
+            create_test_chunk("b", "fn other() {}", "src/other.rs"),
+        ])
+        .await?;
+    storage
+        .insert_chunks(vec![create_test_chunk("c", body, "vendor/clamp.rs")])
+        .await?;
+
+    // One result for both copies, listing the other location
+    let results = storage.search(vec![1.0; 768], 10).await?;
+    assert_eq!(results.len(), 2);
+    assert_eq!(results[0].file_path, "src/clamp.rs");
+    assert_eq!(results[0].duplicates, vec!["vendor/clamp.rs:1-10"]);
+
+    // Deleting the copy holding the vector hands it to the other one
+    storage
+        .delete_by_file(&PathBuf::from("src/clamp.rs"))
+        .await?;
+    let results = storage.search(vec![1.0; 768], 10).await?;
+    assert_eq!(results[0].file_path, "vendor/clamp.rs");
+    assert!(results[0].duplicates.is_empty());
+    assert_eq!(storage.vectors_by_id().await?["c"], vec![1.0; 768]);
+
+    Ok(())
+}
+
+#[test]
+fn test_content_hash_is_fixed() {
+    // Stored in every index, so a change would stop existing rows matching
+    assert_eq!(
+        content_hash("fn main() {}", Some("rust")),
+        0xde5e_daf2_48a4_83e6
+    );
+    assert_eq!(content_hash("fn main() {}", None), 0xa8ce_88be_6139_1918);
+    assert_ne!(
+        content_hash("fn main() {}", Some("rust")),
+        content_hash("fn main() {}", Some("go"))
+    );
+}
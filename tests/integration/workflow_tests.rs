@@ -1,10 +1,21 @@
 use anyhow::Result;
-use std::path::PathBuf;
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
 use tempfile::TempDir;
 
 use coderag::{
-    indexer::Chunker,
-    storage::{IndexedChunk, Storage},
+    config::StorageConfig,
+    embeddings::EmbeddingGenerator,
+    indexer::{
+        comments::body_comments, field_vectors::field_texts, name_variants::name_variants, Chunker,
+    },
+    indexing::ParallelIndexer,
+    search::{Search, SearchEngine},
+    storage::{
+        open_configured_store, open_existing_store, FieldVector, IndexedChunk, SearchFilter,
+        Storage, VectorField, SQLITE_BACKEND,
+    },
+    Config,
 };
 
 // Use a simple mock embedder for tests
@@ -126,8 +137,13 @@ pub mod sort {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         });
         chunk_id += 1;
     }
@@ -147,8 +163,13 @@ pub mod sort {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         });
         chunk_id += 1;
     }
@@ -222,8 +243,13 @@ fn initial_function() {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         });
     }
 
@@ -267,8 +293,13 @@ fn new_function() {
             semantic_kind: None,
             symbol_name: None,
             signature: None,
+            doc: None,
             parent: None,
             visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
         });
     }
 
@@ -354,8 +385,13 @@ function typescriptFunction(): number {
                 semantic_kind: None,
                 symbol_name: None,
                 signature: None,
+                doc: None,
                 parent: None,
                 visibility: None,
+                qualified_name: None,
+                tags: Vec::new(),
+                branch: None,
+                duplicate_of: None,
             });
             chunk_id += 1;
         }
@@ -430,4 +466,294 @@ fn function_{}() {{
     }
 
     Ok(())
-}
\ No newline at end of file
+}
+#[tokio::test]
+async fn test_name_variants_retrieve_symbol_by_short_query() -> Result<()> {
+    let temp_dir = TempDir::new()?;
+    let db_path = temp_dir.path().join("test.lance");
+    let storage = Storage::new(&db_path, 768).await?;
+
+    // (name, qualified name, body)
+    let symbols = [
+        (
+            "Shutdown",
+            "server.Server.Shutdown",
+            "func (s *Server) Shutdown(ctx context.Context) error {\n\treturn s.http.Close()\n}",
+        ),
+        (
+            "Start",
+            "server.Server.Start",
+            "func (s *Server) Start() error {\n\treturn s.http.ListenAndServe()\n}",
+        ),
+        (
+            "handleSignals",
+            "server.handleSignals",
+            "func handleSignals(s *Server) {\n\t// stop the server on shutdown signals\n\t<-sigs\n}",
+        ),
+    ];
+
+    let mut chunks = Vec::new();
+    let mut names = Vec::new();
+    for (i, (name, qualified, content)) in symbols.iter().enumerate() {
+        let id = format!("chunk_{}", i);
+        chunks.push(IndexedChunk {
+            id: id.clone(),
+            content: content.to_string(),
+            file_path: "server.go".to_string(),
+            start_line: i * 10 + 1,
+            end_line: i * 10 + 3,
+            language: Some("go".to_string()),
+            vector: generate_mock_embedding(content, 768),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("method".to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: Some(qualified.to_string()),
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        });
+        for variant in name_variants(name, Some(qualified)) {
+            names.push(FieldVector {
+                chunk_id: id.clone(),
+                file_path: "server.go".to_string(),
+                branch: None,
+                vector: generate_mock_embedding(&variant, 768),
+                text: variant,
+            });
+        }
+    }
+
+    storage.insert_chunks(chunks).await?;
+    assert!(!storage.has_field_vectors(VectorField::Name).await?);
+    storage
+        .insert_field_vectors(VectorField::Name, names)
+        .await?;
+    assert!(storage.has_field_vectors(VectorField::Name).await?);
+
+    // The subword-split variant of `Shutdown` is "shutdown"
+    let query = generate_mock_embedding("shutdown", 768);
+    let results = storage
+        .search_field(
+            VectorField::Name,
+            query.clone(),
+            2,
+            &SearchFilter::default(),
+        )
+        .await?;
+    assert_eq!(results.len(), 2, "each chunk should appear once");
+    assert!(results[0].content.contains("Shutdown(ctx"));
+    assert!(results[0].score > results[1].score);
+
+    // Name vectors are removed with their file
+    storage.delete_by_file(&PathBuf::from("server.go")).await?;
+    let results = storage
+        .search_field(VectorField::Name, query, 2, &SearchFilter::default())
+        .await?;
+    assert!(results.is_empty());
+
+    Ok(())
+}
+
+#[tokio::test]
+async fn test_comment_field_retrieves_chunk_by_intent() -> Result<()> {
+    let temp_dir = TempDir::new()?;
+    let db_path = temp_dir.path().join("test.lance");
+    let storage = Storage::new(&db_path, 768).await?;
+
+    let fixture = std::path::Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests/fixtures/comments/payments.go");
+    let source = std::fs::read_to_string(&fixture)?;
+
+    // One chunk per function, as the AST chunker would produce
+    let functions: Vec<(&str, &str)> = source
+        .split("\n// ")
+        .filter_map(|part| {
+            let body = part.split_once('\n')?.1;
+            let name = body.strip_prefix("func ")?.split('(').next()?;
+            Some((name, body))
+        })
+        .collect();
+    assert_eq!(functions.len(), 2);
+
+    let mut chunks = Vec::new();
+    for (i, (name, content)) in functions.iter().enumerate() {
+        chunks.push(IndexedChunk {
+            id: format!("chunk_{}", i),
+            content: content.to_string(),
+            file_path: "payments.go".to_string(),
+            start_line: i * 20 + 1,
+            end_line: i * 20 + content.lines().count(),
+            language: Some("go".to_string()),
+            vector: generate_mock_embedding(content, 768),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: Some(name.to_string()),
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        });
+    }
+
+    // Only the comment inside the body is embedded, not the doc comment
+    let intent = body_comments(&chunks[0].content, "go").expect("capture has a body comment");
+    assert!(intent.starts_with("Retry the payment capture at most three times"));
+    assert!(!intent.contains("captures an authorized payment"));
+    assert!(field_texts(VectorField::Comments, &chunks[1]).is_empty());
+
+    let comments: Vec<FieldVector> = chunks
+        .iter()
+        .flat_map(|chunk| {
+            field_texts(VectorField::Comments, chunk)
+                .into_iter()
+                .map(move |text| FieldVector {
+                    chunk_id: chunk.id.clone(),
+                    file_path: chunk.file_path.clone(),
+                    branch: None,
+                    vector: generate_mock_embedding(&text, 768),
+                    text,
+                })
+        })
+        .collect();
+    assert_eq!(comments.len(), 1);
+
+    storage.insert_chunks(chunks).await?;
+    storage
+        .insert_field_vectors(VectorField::Comments, comments)
+        .await?;
+    assert!(storage.has_field_vectors(VectorField::Comments).await?);
+    assert!(!storage.has_field_vectors(VectorField::Name).await?);
+
+    let results = storage
+        .search_field(
+            VectorField::Comments,
+            generate_mock_embedding(&intent, 768),
+            5,
+            &SearchFilter::default(),
+        )
+        .await?;
+    assert_eq!(results.len(), 1);
+    assert!(results[0].content.starts_with("func Capture("));
+
+    Ok(())
+}
+
+#[tokio::test]
+async fn test_workflow_on_configured_sqlite_store() -> Result<()> {
+    let temp_dir = TempDir::new()?;
+    let config = StorageConfig {
+        backend: SQLITE_BACKEND.to_string(),
+        ..StorageConfig::default()
+    };
+    let db_path = temp_dir.path().join("index.db");
+    assert!(open_existing_store(&config, &db_path).await?.is_none());
+
+    // Index through the configured backend, as the indexer does
+    let files = [
+        (
+            "search.rs",
+            "fn binary_search(arr: &[i32], target: i32) -> Option<usize> {}",
+        ),
+        ("sort.rs", "fn bubble_sort<T: Ord>(arr: &mut [T]) {}"),
+    ];
+    let writer = open_configured_store(&config, &db_path, Some(768)).await?;
+    assert_eq!(writer.backend(), SQLITE_BACKEND);
+    let chunks = files
+        .iter()
+        .map(|(path, content)| IndexedChunk {
+            id: format!("{}:1", path),
+            content: content.to_string(),
+            file_path: path.to_string(),
+            start_line: 1,
+            end_line: 1,
+            language: Some("rust".to_string()),
+            vector: generate_mock_embedding(content, 768),
+            mtime: 0,
+            file_header: None,
+            semantic_kind: Some("function".to_string()),
+            symbol_name: None,
+            signature: None,
+            doc: None,
+            parent: None,
+            visibility: None,
+            qualified_name: None,
+            tags: Vec::new(),
+            branch: None,
+            duplicate_of: None,
+        })
+        .collect();
+    writer.upsert(chunks).await?;
+
+    // Readers open the same store at the dimension it was written with
+    let reader = open_existing_store(&config, &db_path)
+        .await?
+        .expect("the index was written");
+    assert_eq!(reader.vector_dimension(), 768);
+    assert_eq!(reader.list_files(None).await?, vec!["search.rs", "sort.rs"]);
+
+    let query = generate_mock_embedding(files[0].1, 768);
+    let results = reader
+        .query(query.clone(), 2, &SearchFilter::default())
+        .await?;
+    assert_eq!(results.len(), 2);
+    assert_eq!(results[0].file_path, "search.rs");
+    assert!(results[0].score > results[1].score);
+
+    // Deleting a file drops its chunks from search
+    reader.delete_file(Path::new("search.rs")).await?;
+    let stats = reader.stats().await?;
+    assert_eq!((stats.files, stats.chunks), (1, 1));
+    let results = reader.query(query, 2, &SearchFilter::default()).await?;
+    assert!(results.iter().all(|r| r.file_path == "sort.rs"));
+
+    Ok(())
+}
+
+#[tokio::test]
+#[ignore] // Requires model download
+async fn test_index_and_search_on_sqlite_backend() -> Result<()> {
+    let temp_dir = TempDir::new()?;
+    let root = temp_dir.path().to_path_buf();
+    std::fs::create_dir_all(root.join(".coderag"))?;
+    let file = root.join("search.rs");
+    std::fs::write(
+        &file,
+        "fn binary_search(arr: &[i32], target: i32) -> Option<usize> {\n    None\n}\n",
+    )?;
+
+    let mut config = Config::default();
+    config.storage.backend = SQLITE_BACKEND.to_string();
+    config.storage.db_path = "index.db".to_string();
+
+    let indexer = ParallelIndexer::new(root.clone(), config.clone()).await?;
+    let result = indexer.index_files(vec![file]).await?;
+    assert!(result.chunks_created > 0);
+
+    // The search engine reads the store the indexer wrote
+    let embedder = Arc::new(EmbeddingGenerator::new_async(&config.embeddings).await?);
+    let db_path = embedder.index_path(&config.db_path(&root));
+    assert!(db_path.exists(), "the sqlite index should be a file");
+    let storage = open_configured_store(
+        &config.storage,
+        &db_path,
+        Some(embedder.embedding_dimension()),
+    )
+    .await?;
+    assert_eq!(storage.backend(), SQLITE_BACKEND);
+
+    let engine = SearchEngine::new(storage, embedder);
+    let results = engine.search("binary search", 3).await?;
+    assert!(results.iter().any(|r| r.content.contains("binary_search")));
+
+    Ok(())
+}